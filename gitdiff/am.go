@@ -0,0 +1,184 @@
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrEmptyPatch is the error recorded by an AmSession for a message without
+// files, like the cover letter of a series. Like "git am", the session stops
+// at the message, which can be skipped with Skip.
+var ErrEmptyPatch = errors.New("patch is empty")
+
+// AmTarget is where an AmSession applies patches, like a working directory or
+// the object store of a repository.
+type AmTarget interface {
+	// ApplyPatch applies the files of p in order, with options that
+	// configure the application of each file in the same way as for ApplyFS.
+	// If any file does not apply, ApplyPatch returns an error and does not
+	// change the target.
+	ApplyPatch(p *Patch, opts ...ApplierOption) error
+}
+
+// FSTarget returns an AmTarget that applies the files of each patch to fsys
+// with ApplyFS.
+func FSTarget(fsys WriteFS) AmTarget {
+	return fsTarget{fsys: fsys}
+}
+
+type fsTarget struct {
+	fsys WriteFS
+}
+
+func (t fsTarget) ApplyPatch(p *Patch, opts ...ApplierOption) error {
+	return ApplyFS(t.fsys, p.Files, opts...)
+}
+
+// AmStatus is the state of a patch in an AmSession.
+type AmStatus int
+
+const (
+	// AmPending indicates that the session has not tried to apply the patch
+	AmPending AmStatus = iota
+	// AmApplied indicates that the patch applied to the target
+	AmApplied
+	// AmFailed indicates that the patch did not apply and that the session
+	// stopped at the patch
+	AmFailed
+	// AmSkipped indicates that the patch was skipped with Skip
+	AmSkipped
+)
+
+func (s AmStatus) String() string {
+	switch s {
+	case AmPending:
+		return "pending"
+	case AmApplied:
+		return "applied"
+	case AmFailed:
+		return "failed"
+	case AmSkipped:
+		return "skipped"
+	}
+	return "unknown"
+}
+
+// AmResult is the result of a patch in an AmSession.
+type AmResult struct {
+	Status AmStatus
+	// Err is the reason the patch did not apply, if the status is AmFailed
+	// or if the patch was skipped after failing
+	Err error
+}
+
+// AmError is the error returned when a patch in an AmSession does not
+// apply. It identifies the patch where the session stopped.
+type AmError struct {
+	// Index is the zero-indexed position of the patch in the mailbox
+	Index int
+	// Patch is the patch that did not apply
+	Patch *Patch
+
+	err error
+}
+
+func (e *AmError) Error() string {
+	var title string
+	if e.Patch != nil && e.Patch.Header != nil {
+		title = e.Patch.Header.Title
+	}
+	return fmt.Sprintf("gitdiff: am: patch %d %q: %v", e.Index+1, title, e.err)
+}
+
+// Unwrap returns the wrapped error.
+func (e *AmError) Unwrap() error {
+	return e.err
+}
+
+// AmSession applies the patches from a mailbox in order, like "git am". When
+// a patch does not apply, the session stops at that patch, and the caller can
+// resolve the problem and resume the session with Continue or skip the patch
+// with Skip, like the "--continue" and "--skip" options of "git am". All of
+// the state of the session is in its exported fields, so a caller can save
+// the session and resume it later.
+type AmSession struct {
+	// Patches are the patches in the order they apply
+	Patches []*Patch
+	// Results contains the result of each patch, at the same index as the
+	// patch in Patches
+	Results []AmResult
+	// Next is the index of the next patch to apply. It is the index of the
+	// failed patch after a patch does not apply and the number of patches
+	// after all patches are applied or skipped.
+	Next int
+}
+
+// NewAmSession returns a session that applies patches in order, like the
+// patches returned by ParseMailbox.
+func NewAmSession(patches []*Patch) *AmSession {
+	return &AmSession{Patches: patches, Results: make([]AmResult, len(patches))}
+}
+
+// Am parses a mailbox from r with ParseMailbox and applies the patches to
+// target in order, like "git am". Options configure the application of the
+// files of each patch in the same way as for ApplyFS.
+//
+// Am returns the session and an *AmError if a patch does not apply. The
+// patches before it remain applied, and the session can continue at the
+// failed patch. If the mailbox can not be parsed, Am returns a nil session
+// and the error without applying any patches.
+func Am(r io.Reader, target AmTarget, opts ...ApplierOption) (*AmSession, error) {
+	patches, err := ParseMailbox(r)
+	if err != nil {
+		return nil, err
+	}
+	s := NewAmSession(patches)
+	return s, s.Continue(target, opts...)
+}
+
+// Done returns true if the session applied or skipped all patches.
+func (s *AmSession) Done() bool {
+	return s.Next >= len(s.Patches)
+}
+
+// Current returns the next patch to apply, or nil if the session is done.
+func (s *AmSession) Current() *Patch {
+	if s.Done() {
+		return nil
+	}
+	return s.Patches[s.Next]
+}
+
+// Continue applies the remaining patches to target in order, starting with
+// the next patch, and stops at the first patch that does not apply. Use it to
+// retry a failed patch after changing the target or with different options,
+// like WithFuzz. It returns an *AmError for the failed patch or nil if all
+// remaining patches apply.
+func (s *AmSession) Continue(target AmTarget, opts ...ApplierOption) error {
+	for ; !s.Done(); s.Next++ {
+		p := s.Patches[s.Next]
+
+		err := ErrEmptyPatch
+		if len(p.Files) > 0 {
+			err = target.ApplyPatch(p, opts...)
+		}
+		if err != nil {
+			s.Results[s.Next] = AmResult{Status: AmFailed, Err: err}
+			return &AmError{Index: s.Next, Patch: p, err: err}
+		}
+		s.Results[s.Next] = AmResult{Status: AmApplied}
+	}
+	return nil
+}
+
+// Skip skips the next patch without applying it, usually after it failed to
+// apply. It returns an error if the session is done.
+func (s *AmSession) Skip() error {
+	if s.Done() {
+		return errors.New("gitdiff: am: no patch to skip")
+	}
+	s.Results[s.Next].Status = AmSkipped
+	s.Next++
+	return nil
+}
@@ -0,0 +1,103 @@
+package gitdiff
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAm(t *testing.T) {
+	openMailbox := func(t *testing.T) *os.File {
+		f, err := os.Open(filepath.Join("testdata", "mailbox.patch"))
+		if err != nil {
+			t.Fatalf("failed to open mailbox: %v", err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+
+	statuses := func(s *AmSession) []AmStatus {
+		var statuses []AmStatus
+		for _, r := range s.Results {
+			statuses = append(statuses, r.Status)
+		}
+		return statuses
+	}
+
+	t.Run("skipCoverLetter", func(t *testing.T) {
+		fsys := &memFS{MapFS: fstest.MapFS{
+			"file.txt": {Data: []byte("one\ntwo\nthree\n"), Mode: 0644},
+		}}
+
+		s, err := Am(openMailbox(t), FSTarget(fsys))
+		if !errors.Is(err, ErrEmptyPatch) {
+			t.Fatalf("expected empty patch error, but got %v", err)
+		}
+		var aerr *AmError
+		if !errors.As(err, &aerr) || aerr.Index != 0 || aerr.Patch != s.Current() {
+			t.Fatalf("expected AmError for the cover letter, but got %v", err)
+		}
+
+		if err := s.Skip(); err != nil {
+			t.Fatalf("unexpected error skipping patch: %v", err)
+		}
+		if err := s.Continue(FSTarget(fsys)); err != nil {
+			t.Fatalf("unexpected error continuing: %v", err)
+		}
+		if !s.Done() || s.Current() != nil {
+			t.Errorf("expected session to be done at patch %d", s.Next)
+		}
+		if expected := []AmStatus{AmSkipped, AmApplied, AmApplied}; !reflect.DeepEqual(expected, statuses(s)) {
+			t.Errorf("incorrect results: expected %v, actual %v", expected, statuses(s))
+		}
+		assertMapFS(t, fstest.MapFS{
+			"file.txt": {Data: []byte("one\n2\n3\n"), Mode: 0644},
+			"new.txt":  {Data: []byte("new\n"), Mode: 0644},
+		}, fsys.MapFS)
+
+		if err := s.Skip(); err == nil {
+			t.Errorf("expected error skipping patch after the session is done")
+		}
+	})
+
+	t.Run("resumeAfterConflict", func(t *testing.T) {
+		fsys := &memFS{MapFS: fstest.MapFS{
+			"file.txt": {Data: []byte("one\ntwo\nthree\n"), Mode: 0644},
+			"new.txt":  {Data: []byte("exists\n"), Mode: 0644},
+		}}
+
+		patches, err := ParseMailbox(openMailbox(t))
+		if err != nil {
+			t.Fatalf("unexpected error parsing mailbox: %v", err)
+		}
+		s := NewAmSession(patches[1:])
+
+		err = s.Continue(FSTarget(fsys))
+		assertError(t, &Conflict{"file already exists"}, err, "applying patches")
+		if s.Next != 1 || s.Results[1].Err == nil {
+			t.Fatalf("expected session to stop at the second patch, but stopped at %d", s.Next)
+		}
+		if expected := []AmStatus{AmApplied, AmFailed}; !reflect.DeepEqual(expected, statuses(s)) {
+			t.Errorf("incorrect results: expected %v, actual %v", expected, statuses(s))
+		}
+		assertMapFS(t, fstest.MapFS{
+			"file.txt": {Data: []byte("one\n2\nthree\n"), Mode: 0644},
+			"new.txt":  {Data: []byte("exists\n"), Mode: 0644},
+		}, fsys.MapFS)
+
+		delete(fsys.MapFS, "new.txt")
+		if err := s.Continue(FSTarget(fsys)); err != nil {
+			t.Fatalf("unexpected error continuing: %v", err)
+		}
+		if expected := []AmStatus{AmApplied, AmApplied}; !reflect.DeepEqual(expected, statuses(s)) {
+			t.Errorf("incorrect results: expected %v, actual %v", expected, statuses(s))
+		}
+		assertMapFS(t, fstest.MapFS{
+			"file.txt": {Data: []byte("one\n2\n3\n"), Mode: 0644},
+			"new.txt":  {Data: []byte("new\n"), Mode: 0644},
+		}, fsys.MapFS)
+	})
+}
@@ -1,10 +1,12 @@
 package gitdiff
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 )
 
 // Conflict indicates an apply failed due to a conflict between the patch and
@@ -98,10 +100,106 @@ const (
 	applyFile
 )
 
-// Apply is a convenience function that creates an Applier for src with default
-// settings and applies the changes in f, writing the result to dst.
-func Apply(dst io.Writer, src io.ReaderAt, f *File) error {
-	return NewApplier(src).ApplyFile(dst, f)
+// Apply is a convenience function that creates an Applier for src with the
+// given options and applies the changes in f, writing the result to dst.
+func Apply(dst io.Writer, src io.ReaderAt, f *File, opts ...ApplierOption) error {
+	return NewApplier(src, opts...).ApplyFile(dst, f)
+}
+
+// ApplierOption configures the behavior of an Applier.
+type ApplierOption func(*Applier)
+
+// WithReverse configures an Applier to apply patches in reverse, like the -R
+// flag of "git apply". In reverse mode, the source must contain the result of
+// applying the patch and the Applier writes the original content. Binary
+// files can be applied in reverse if the patch includes a reverse binary
+// fragment, like the patches created by "git diff --binary".
+func WithReverse() ApplierOption {
+	return func(a *Applier) {
+		a.reverse = true
+	}
+}
+
+// WithFuzz configures an Applier to ignore up to fuzz lines of leading and
+// trailing context when a text fragment does not match the source exactly,
+// like the -F flag of "patch". Ignored context lines are not checked and are
+// not modified in the output. Use Matches to find the amount of fuzz used for
+// each fragment.
+func WithFuzz(fuzz int) ApplierOption {
+	return func(a *Applier) {
+		a.fuzz = fuzz
+	}
+}
+
+// WithReject configures an Applier to skip text fragments that do not apply
+// to the source instead of failing, like the --reject flag of "git apply".
+// ApplyFile applies the other fragments of the file and succeeds. Use Rejects
+// to find the skipped fragments and FormatRejects to write them in the format
+// of a ".rej" file. Fragments applied with ApplyTextFragment are never
+// skipped.
+func WithReject() ApplierOption {
+	return func(a *Applier) {
+		a.reject = true
+	}
+}
+
+// WithUnidiffZero configures an Applier to apply text fragments without
+// context, like the --unidiff-zero flag of "git apply" for patches created
+// with "git diff -U0". By default, like Git, text fragments that start at the
+// first line of the source must match at the start and fragments without
+// trailing context must match at the end, so patches without context rarely
+// apply. With this option, the Applier trusts the positions in the fragment
+// headers instead, and fragments that add lines at position 0 insert them at
+// the start of the source instead of requiring an empty source.
+func WithUnidiffZero() ApplierOption {
+	return func(a *Applier) {
+		a.unidiffZero = true
+	}
+}
+
+// WithMaxOffset configures an Applier to search up to lines before and after
+// the expected position for a location where a text fragment matches the
+// source. The expected position of each fragment includes the offset used for
+// the previous fragment. Use Matches to find the offset used for each
+// fragment.
+func WithMaxOffset(lines int64) ApplierOption {
+	return func(a *Applier) {
+		a.maxOffset = lines
+	}
+}
+
+// WithOIDCheck configures an Applier to verify that the source matches the
+// old object ID from the index line of a file before applying it. ApplyFile
+// computes the Git blob ID of the source and returns a Conflict if it does not
+// start with the recorded ID. Files without an index line or with an all-zero
+// old ID are not checked.
+func WithOIDCheck() ApplierOption {
+	return func(a *Applier) {
+		a.oidCheck = true
+	}
+}
+
+// WithNewOIDCheck configures an Applier to verify that the result of applying
+// a file matches the new object ID from its index line, in the same way as
+// WithOIDCheck verifies the source. ApplyFile buffers the result and does not
+// write anything to the destination if it does not match.
+func WithNewOIDCheck() ApplierOption {
+	return func(a *Applier) {
+		a.newOIDCheck = true
+	}
+}
+
+// FragmentMatch describes where a text fragment was applied in the source.
+type FragmentMatch struct {
+	// Line is the one-indexed line number in the source where the fragment
+	// applied, including any ignored context lines
+	Line int64
+	// Offset is the difference between Line and the position reported by
+	// the fragment header
+	Offset int64
+	// Fuzz is the number of leading and trailing context lines that were
+	// ignored to find the match
+	Fuzz int
 }
 
 // Applier applies changes described in fragments to source data. If changes
@@ -109,7 +207,9 @@ func Apply(dst io.Writer, src io.ReaderAt, f *File) error {
 // order, usually by calling ApplyFile.
 //
 // By default, Applier operates in "strict" mode, where fragment content and
-// positions must exactly match those of the source.
+// positions must exactly match those of the source. The WithFuzz,
+// WithMaxOffset, WithIgnoreWhitespace, and WithLineEndings options relax these
+// requirements for text fragments.
 //
 // If an error occurs while applying, methods on Applier return instances of
 // *ApplyError that annotate the wrapped error with additional information
@@ -121,16 +221,50 @@ func Apply(dst io.Writer, src io.ReaderAt, f *File) error {
 // sets the type for the Applier. Mixing fragment types or mixing
 // fragment-level and file-level applies results in an error.
 type Applier struct {
-	src       io.ReaderAt
-	lineSrc   LineReaderAt
-	nextLine  int64
-	applyType int
+	src        io.ReaderAt
+	lineSrc    LineReaderAt
+	nextLine   int64
+	applyType  int
+	offset     int64
+	matches    []FragmentMatch
+	wsErrors   []WhitespaceError
+	wsRule     WhitespaceRule
+	textAttr   AttrState
+	attrEOL    string
+	rejects    []*TextFragment
+	rejectErrs []error
+	reports    []FragmentReport
+
+	reverse          bool
+	reject           bool
+	report           bool
+	unidiffZero      bool
+	fuzz             int
+	maxOffset        int64
+	whitespace       WhitespaceAction
+	wsRules          []whitespaceRules
+	attrs            *Attributes
+	ignoreWhitespace bool
+	oidCheck         bool
+	newOIDCheck      bool
+	lineEndings      LineEndingPolicy
+	unsafePaths      bool
+	timestamps       bool
+	progress         progressReporter
+	workers          int
+
+	eol        string
+	eolChecked bool
 }
 
 // NewApplier creates an Applier that reads data from src. If src is a
-// LineReaderAt, it is used directly to apply text fragments.
-func NewApplier(src io.ReaderAt) *Applier {
+// LineReaderAt, it is used directly to apply text fragments. Options are
+// preserved by calls to Reset.
+func NewApplier(src io.ReaderAt, opts ...ApplierOption) *Applier {
 	a := new(Applier)
+	for _, opt := range opts {
+		opt(a)
+	}
 	a.Reset(src)
 	return a
 }
@@ -148,16 +282,70 @@ func (a *Applier) Reset(src io.ReaderAt) {
 	}
 	a.nextLine = 0
 	a.applyType = applyInitial
+	a.offset = 0
+	a.matches = nil
+	a.wsErrors = nil
+	a.wsRule = a.whitespaceRule("")
+	a.textAttr = AttrUnspecified
+	a.attrEOL = ""
+	a.rejects = nil
+	a.rejectErrs = nil
+	a.reports = nil
+	a.eol = ""
+	a.eolChecked = false
+	a.progress.reset()
+}
+
+// Rejects returns the text fragments that ApplyFile skipped because they did
+// not apply to the source since the last call to Reset. Rejects is always
+// empty unless the Applier was created with WithReject. The fragments are
+// from the applied File and are not reversed by WithReverse.
+func (a *Applier) Rejects() []*TextFragment {
+	return a.rejects
+}
+
+// Matches returns the locations where text fragments were applied since the
+// last call to Reset, in the order the fragments were applied.
+func (a *Applier) Matches() []FragmentMatch {
+	return a.matches
 }
 
 // ApplyFile applies the changes in all of the fragments of f and writes the
-// result to dst.
+// result to dst. Files without changes to their content, like mode changes
+// and renames, copy the source to dst. Files that create or delete an empty
+// file, which have no fragments, require an empty source.
 func (a *Applier) ApplyFile(dst io.Writer, f *File) error {
 	if a.applyType != applyInitial {
 		return applyError(errApplyInProgress)
 	}
 	defer func() { a.applyType = applyFile }()
 
+	if err := f.LoadFragments(); err != nil {
+		return applyError(err)
+	}
+
+	name := f.NewName
+	if name == "" {
+		name = f.OldName
+	}
+	if err := a.applyAttributes(name); err != nil {
+		return applyError(err)
+	}
+
+	if a.progress.fn != nil {
+		a.progress.start(f)
+		dst = progressWriter{w: dst, r: &a.progress}
+	}
+
+	if f.IsCombined {
+		return applyError(errors.New("cannot apply combined diff"))
+	}
+	if len(f.WordFragments) > 0 {
+		return applyError(errors.New("cannot apply word diff"))
+	}
+	if f.Submodule != nil {
+		return applyError(errors.New("cannot apply submodule change"))
+	}
 	if f.IsBinary && len(f.TextFragments) > 0 {
 		return applyError(errors.New("binary file contains text fragments"))
 	}
@@ -165,16 +353,73 @@ func (a *Applier) ApplyFile(dst io.Writer, f *File) error {
 		return applyError(errors.New("text file contains binary fragment"))
 	}
 
+	alg := f.HashAlgorithm
+	if alg == HashUnknown {
+		alg = detectHashAlgorithm(f.OldOIDPrefix, f.NewOIDPrefix)
+	}
+
+	oldOID, newOID := f.OldOIDPrefix, f.NewOIDPrefix
+	if a.reverse {
+		oldOID, newOID = newOID, oldOID
+	}
+
+	if a.oidCheck && !isZeroOID(oldOID) {
+		var src bytes.Buffer
+		if _, err := copyFrom(&src, a.src, 0); err != nil {
+			return applyError(err)
+		}
+		if oid := blobOID(alg, src.Bytes()); !strings.HasPrefix(oid, oldOID) {
+			return applyError(&Conflict{fmt.Sprintf("source %s does not match index %s", oid, oldOID)})
+		}
+	}
+
+	if a.newOIDCheck && !isZeroOID(newOID) {
+		var out bytes.Buffer
+		if err := a.applyFile(&out, f); err != nil {
+			return err
+		}
+		if oid := blobOID(alg, out.Bytes()); !strings.HasPrefix(oid, newOID) {
+			return applyError(&Conflict{fmt.Sprintf("result %s does not match index %s", oid, newOID)})
+		}
+		_, err := dst.Write(out.Bytes())
+		return applyError(err)
+	}
+	return a.applyFile(dst, f)
+}
+
+// isZeroOID returns true if oid is empty or contains only zeros, which Git
+// uses for the missing side of created and deleted files.
+func isZeroOID(oid string) bool {
+	return strings.Trim(oid, "0") == ""
+}
+
+func (a *Applier) applyFile(dst io.Writer, f *File) error {
 	switch {
 	case f.BinaryFragment != nil:
-		return a.ApplyBinaryFragment(dst, f.BinaryFragment)
+		if !a.reverse {
+			return a.ApplyBinaryFragment(dst, f.BinaryFragment)
+		}
+		if f.ReverseBinaryFragment == nil {
+			return applyError(errors.New("cannot reverse apply binary patch without reverse fragment"))
+		}
+		return a.applyBinaryFragment(dst, f.ReverseBinaryFragment)
 
 	case len(f.TextFragments) > 0:
 		frags := make([]*TextFragment, len(f.TextFragments))
-		copy(frags, f.TextFragments)
+		originals := make(map[*TextFragment]int, len(f.TextFragments))
+		for i, frag := range f.TextFragments {
+			if a.reverse {
+				frag = frag.Reverse()
+			}
+			frags[i] = frag
+			originals[frag] = i
+		}
 
-		sort.Slice(frags, func(i, j int) bool {
-			return frags[i].OldPosition < frags[j].OldPosition
+		// fragments without old lines insert after their position, so sort by
+		// the line where each fragment starts, keeping the order of fragments
+		// that start at the same line
+		sort.SliceStable(frags, func(i, j int) bool {
+			return fragmentStart(frags[i].OldPosition, frags[i].OldLines) < fragmentStart(frags[j].OldPosition, frags[j].OldLines)
 		})
 
 		// TODO(bkeyes): consider merging overlapping fragments
@@ -182,9 +427,30 @@ func (a *Applier) ApplyFile(dst io.Writer, f *File) error {
 		// possible to precompute the result of applying them in order
 
 		for i, frag := range frags {
-			if err := a.ApplyTextFragment(dst, frag); err != nil {
-				return applyError(err, fragNum(i))
+			nextLine, wsErrors := a.nextLine, len(a.wsErrors)
+			err := applyError(a.applyTextFragment(dst, frag), fragNum(i))
+			n := originals[frag]
+			a.reportFragment(f.TextFragments[n], n+1, err)
+
+			if err != nil {
+				if !a.reject || !isRejectable(err) {
+					return err
+				}
+				a.nextLine, a.wsErrors = nextLine, a.wsErrors[:wsErrors]
+				a.rejects = append(a.rejects, f.TextFragments[n])
+				a.rejectErrs = append(a.rejectErrs, err)
+			}
+		}
+
+	case f.IsEmptyNewFile() || f.IsEmptyDeletedFile():
+		// creating or deleting an empty file in either direction requires
+		// an empty source
+		var b [1]byte
+		if n, _ := a.src.ReadAt(b[:], 0); n > 0 {
+			if f.IsNew != a.reverse {
+				return applyError(&Conflict{"new file source is not empty"})
 			}
+			return applyError(&Conflict{"removal patch leaves file contents"})
 		}
 	}
 
@@ -197,6 +463,16 @@ func (a *Applier) ApplyFile(dst io.Writer, f *File) error {
 // order of increasing start position. As a result, each fragment can be
 // applied at most once before a call to Reset.
 func (a *Applier) ApplyTextFragment(dst io.Writer, f *TextFragment) error {
+	frag := f
+	if a.reverse {
+		frag = f.Reverse()
+	}
+	err := a.applyTextFragment(dst, frag)
+	a.reportFragment(f, len(a.reports)+1, err)
+	return err
+}
+
+func (a *Applier) applyTextFragment(dst io.Writer, f *TextFragment) error {
 	if a.applyType != applyInitial && a.applyType != applyText {
 		return applyError(errApplyInProgress)
 	}
@@ -206,12 +482,41 @@ func (a *Applier) ApplyTextFragment(dst io.Writer, f *TextFragment) error {
 	if err := f.Validate(); err != nil {
 		return applyError(err)
 	}
+	if err := a.checkLineEndings(); err != nil {
+		return err
+	}
+
+	match := FragmentMatch{Line: f.OldPosition}
+	anchorStart, anchorEnd := a.fragmentAnchors(f)
+	if f.OldPosition > 0 && (a.fuzz > 0 || a.maxOffset > 0) {
+		var err error
+		if f, match, err = a.findTextFragment(f); err != nil {
+			return applyError(err)
+		}
+		// the search checks the anchors of the fragment
+		anchorStart, anchorEnd = false, false
+	}
+
+	f, err := a.checkWhitespace(f)
+	if err != nil {
+		return err
+	}
 
-	// lines are 0-indexed, positions are 1-indexed (but new files have position = 0)
-	fragStart := f.OldPosition - 1
-	if fragStart < 0 {
-		fragStart = 0
+	if err := a.applyTextFragmentAt(dst, f, anchorStart, anchorEnd); err != nil {
+		return err
 	}
+	a.matches = append(a.matches, match)
+	return nil
+}
+
+// applyTextFragmentAt applies f at the exact position given by its header.
+// If anchorStart or anchorEnd is true, the fragment must also match at the
+// start or the end of the source.
+func (a *Applier) applyTextFragmentAt(dst io.Writer, f *TextFragment, anchorStart, anchorEnd bool) error {
+	// lines are 0-indexed, positions are 1-indexed (but new files have
+	// position = 0), and fragments without old lines start after their
+	// position
+	fragStart := fragmentStart(f.OldPosition, f.OldLines) - 1
 	fragEnd := fragStart + f.OldLines
 
 	start := a.nextLine
@@ -219,7 +524,7 @@ func (a *Applier) ApplyTextFragment(dst io.Writer, f *TextFragment) error {
 		return applyError(&Conflict{"fragment overlaps with an applied fragment"})
 	}
 
-	if f.OldPosition == 0 {
+	if f.OldPosition == 0 && !a.unidiffZero {
 		ok, err := isLen(a.src, 0)
 		if err != nil {
 			return applyError(err)
@@ -235,6 +540,35 @@ func (a *Applier) ApplyTextFragment(dst io.Writer, f *TextFragment) error {
 		return applyError(err, lineNum(start+int64(n)))
 	}
 
+	// check the old lines before writing any data, so that dst does not
+	// contain partial output if the fragment conflicts with the source
+	used := int64(0)
+	for i, line := range f.Lines {
+		if !line.Old() {
+			continue
+		}
+		if !a.matchLine(preimage[fragStart-start+used], line.Line) {
+			return applyError(&Conflict{"fragment line does not match src line"}, lineNum(fragStart+used), fragLineNum(i))
+		}
+		used++
+	}
+	if ok, err := a.matchAnchors(f, fragStart, anchorStart, anchorEnd); err != nil || !ok {
+		if err != nil {
+			return applyError(err, lineNum(fragEnd))
+		}
+		return applyError(&Conflict{"fragment does not match the start or end of src"}, lineNum(fragStart))
+	}
+
+	// a new last line without a newline must also be the last line of src
+	if f.NewMissingNewline() {
+		if ok, err := a.matchAnchors(f, fragStart, false, true); err != nil || !ok {
+			if err != nil {
+				return applyError(err, lineNum(fragEnd))
+			}
+			return applyError(&Conflict{"fragment removes the final newline, but src continues after it"}, lineNum(fragStart+f.OldLines))
+		}
+	}
+
 	// copy leading data before the fragment starts
 	for i, line := range preimage[:fragStart-start] {
 		if _, err := dst.Write(line); err != nil {
@@ -245,9 +579,9 @@ func (a *Applier) ApplyTextFragment(dst io.Writer, f *TextFragment) error {
 	preimage = preimage[fragStart-start:]
 
 	// apply the changes in the fragment
-	used := int64(0)
+	used = 0
 	for i, line := range f.Lines {
-		if err := applyTextLine(dst, line, preimage, used); err != nil {
+		if err := a.applyTextLine(dst, line, preimage, used); err != nil {
 			a.nextLine = fragStart + used
 			return applyError(err, lineNum(a.nextLine), fragLineNum(i))
 		}
@@ -257,8 +591,9 @@ func (a *Applier) ApplyTextFragment(dst io.Writer, f *TextFragment) error {
 	}
 	a.nextLine = fragStart + used
 
-	// new position of +0,0 mean a full delete, so check for leftovers
-	if f.NewPosition == 0 && f.NewLines == 0 {
+	// new position of +0,0 mean a full delete, so check for leftovers, except
+	// without context, where it may only delete the first lines of src
+	if f.NewPosition == 0 && f.NewLines == 0 && !a.unidiffZero {
 		var b [1][]byte
 		n, err := a.lineSrc.ReadLinesAt(b[:], a.nextLine)
 		if err != nil && err != io.EOF {
@@ -272,16 +607,186 @@ func (a *Applier) ApplyTextFragment(dst io.Writer, f *TextFragment) error {
 	return nil
 }
 
-func applyTextLine(dst io.Writer, line Line, preimage [][]byte, i int64) (err error) {
-	if line.Old() && string(preimage[i]) != line.Line {
-		return &Conflict{"fragment line does not match src line"}
+// findTextFragment searches the source for a position where the old lines of
+// f match, first with all context and then with increasing amounts of fuzz.
+// It returns a copy of f with any ignored context removed and a position
+// adjusted to the location of the match.
+func (a *Applier) findTextFragment(f *TextFragment) (*TextFragment, FragmentMatch, error) {
+	maxFuzz := a.fuzz
+	if maxContext := int(max(f.LeadingContext, f.TrailingContext)); maxFuzz > maxContext {
+		maxFuzz = maxContext
+	}
+
+	anchorStart, anchorEnd := a.fragmentAnchors(f)
+	for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+		frag := trimTextFragmentContext(f, int64(fuzz))
+
+		m, ok, err := a.searchTextFragment(f, frag, fuzz, anchorStart, anchorEnd)
+		if err != nil {
+			return nil, FragmentMatch{}, err
+		}
+		if ok {
+			return frag, m, nil
+		}
+
+		// like Git, search without the anchors before ignoring context
+		if (anchorStart || anchorEnd) && fuzz < maxFuzz {
+			anchorStart, anchorEnd = false, false
+			fuzz--
+		}
+	}
+
+	return nil, FragmentMatch{}, &Conflict{"fragment does not match src at any searched position"}
+}
+
+// searchTextFragment searches the source for a position where the old lines
+// of frag, which is f with fuzz lines of context removed, match and that
+// matches the anchors. If it finds a match, it adjusts the position of frag
+// to the location of the match and returns true.
+func (a *Applier) searchTextFragment(f, frag *TextFragment, fuzz int, anchorStart, anchorEnd bool) (FragmentMatch, bool, error) {
+	position := fragmentStart(frag.OldPosition, frag.OldLines) - 1
+	expected := position + a.offset
+	var beforeDone, afterDone bool
+
+	for delta := int64(0); delta <= a.maxOffset && !(beforeDone && afterDone); delta++ {
+		starts := []int64{expected - delta, expected + delta}
+		if delta == 0 {
+			starts = starts[:1]
+		}
+		for _, start := range starts {
+			switch {
+			case start < expected && beforeDone, start > expected && afterDone:
+				continue
+			case start < a.nextLine:
+				beforeDone = true
+				continue
+			}
+
+			ok, err := a.matchTextFragment(frag, start)
+			if err == io.EOF {
+				afterDone = true
+				continue
+			}
+			if ok && err == nil {
+				ok, err = a.matchAnchors(frag, start, anchorStart, anchorEnd)
+			}
+			if err != nil {
+				return FragmentMatch{}, false, err
+			}
+			if ok {
+				m := FragmentMatch{
+					Offset: start - position,
+					Fuzz:   fuzz,
+				}
+				m.Line = f.OldPosition + m.Offset
+				a.offset = m.Offset
+				frag.OldPosition = fragmentPosition(start+1, frag.OldLines)
+				return m, true, nil
+			}
+		}
+	}
+	return FragmentMatch{}, false, nil
+}
+
+// fragmentAnchors returns true for start if f must match at the start of the
+// source and true for end if it must match at the end. Like Git, fragments
+// that start at the first line must match at the start and fragments without
+// trailing context must match at the end, unless the Applier was created with
+// WithUnidiffZero.
+func (a *Applier) fragmentAnchors(f *TextFragment) (start, end bool) {
+	if a.unidiffZero {
+		return false, false
+	}
+	// fragments that delete all lines are checked after applying them
+	fullDelete := f.NewPosition == 0 && f.NewLines == 0
+	return f.OldPosition <= 1, f.TrailingContext == 0 && !fullDelete
+}
+
+// matchAnchors returns true if the old lines of f, starting at line start in
+// the source, match the anchors.
+func (a *Applier) matchAnchors(f *TextFragment, start int64, anchorStart, anchorEnd bool) (bool, error) {
+	if anchorStart && start != 0 {
+		return false, nil
+	}
+	if anchorEnd {
+		var b [1][]byte
+		n, err := a.lineSrc.ReadLinesAt(b[:], start+f.OldLines)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if n > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchTextFragment returns true if the old lines of f match the source
+// starting at line start. It returns io.EOF if the source ends before all of
+// the old lines are matched.
+func (a *Applier) matchTextFragment(f *TextFragment, start int64) (bool, error) {
+	preimage := make([][]byte, f.OldLines)
+	n, err := a.lineSrc.ReadLinesAt(preimage, start)
+	if n < len(preimage) {
+		if err == nil {
+			err = io.EOF
+		}
+		return false, err
 	}
-	if line.New() {
-		_, err = io.WriteString(dst, line.Line)
+
+	i := 0
+	for _, line := range f.Lines {
+		if line.Old() {
+			if !a.matchLine(preimage[i], line.Line) {
+				return false, nil
+			}
+			i++
+		}
+	}
+	return true, nil
+}
+
+// trimTextFragmentContext returns a copy of f with up to n lines of leading
+// and trailing context removed.
+func trimTextFragmentContext(f *TextFragment, n int64) *TextFragment {
+	leading, trailing := min(n, f.LeadingContext), min(n, f.TrailingContext)
+
+	frag := *f
+	frag.Lines = f.Lines[leading : int64(len(f.Lines))-trailing]
+	frag.OldLines -= leading + trailing
+	frag.OldPosition = fragmentPosition(fragmentStart(f.OldPosition, f.OldLines)+leading, frag.OldLines)
+	if frag.NewPosition > 0 {
+		frag.NewPosition = fragmentPosition(fragmentStart(f.NewPosition, f.NewLines)+leading, frag.NewLines)
+	}
+	frag.NewLines -= leading + trailing
+	frag.LeadingContext -= leading
+	frag.TrailingContext -= trailing
+	return &frag
+}
+
+// applyTextLine writes line to dst if it is a new line. Context lines are
+// written from the source line at i, which may differ from the fragment in
+// whitespace.
+func (a *Applier) applyTextLine(dst io.Writer, line Line, preimage [][]byte, i int64) (err error) {
+	switch line.Op {
+	case OpContext:
+		src := string(preimage[i])
+		if a.fixTrailingWhitespace() && src != line.Line {
+			src = trimTrailingWhitespace(src)
+		}
+		_, err = io.WriteString(dst, src)
+	case OpAdd:
+		_, err = io.WriteString(dst, a.fixLineEnding(line.Line))
 	}
 	return err
 }
 
+// isRejectable returns true if err means a text fragment does not apply to the
+// source, either because of a conflict or because the source is too short.
+func isRejectable(err error) bool {
+	return errors.Is(err, &Conflict{}) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
 // Flush writes any data following the last applied fragment to dst.
 func (a *Applier) Flush(dst io.Writer) (err error) {
 	switch a.applyType {
@@ -297,8 +802,17 @@ func (a *Applier) Flush(dst io.Writer) (err error) {
 
 // ApplyBinaryFragment applies the changes in the fragment f and writes the
 // result to dst. At most one binary fragment can be applied before a call to
-// Reset.
+// Reset. Binary fragments can not be reversed, so in reverse mode, use
+// ApplyFile or apply the ReverseBinaryFragment of the file without
+// WithReverse.
 func (a *Applier) ApplyBinaryFragment(dst io.Writer, f *BinaryFragment) error {
+	if a.reverse {
+		return applyError(errors.New("reverse apply is not supported for binary fragments"))
+	}
+	return a.applyBinaryFragment(dst, f)
+}
+
+func (a *Applier) applyBinaryFragment(dst io.Writer, f *BinaryFragment) error {
 	if a.applyType != applyInitial {
 		return applyError(errApplyInProgress)
 	}
@@ -307,6 +821,9 @@ func (a *Applier) ApplyBinaryFragment(dst io.Writer, f *BinaryFragment) error {
 	if f == nil {
 		return applyError(errors.New("nil fragment"))
 	}
+	if int64(len(f.Data)) != f.Size {
+		return applyError(fmt.Errorf("fragment size %d does not match data size %d", f.Size, len(f.Data)))
+	}
 
 	switch f.Method {
 	case BinaryPatchLiteral:
@@ -332,23 +849,22 @@ func applyBinaryDeltaFragment(dst io.Writer, src io.ReaderAt, frag []byte) error
 	dstSize, delta := readBinaryDeltaSize(delta)
 
 	for len(delta) > 0 {
-		op := delta[0]
-		if op == 0 {
-			return errors.New("invalid delta opcode 0")
+		var op DeltaOp
+		var err error
+		if op, delta, err = readBinaryDeltaOp(delta); err != nil {
+			return err
 		}
 
-		var n int64
-		var err error
-		switch op & 0x80 {
-		case 0x80:
-			n, delta, err = applyBinaryDeltaCopy(dst, op, delta[1:], src)
-		case 0x00:
-			n, delta, err = applyBinaryDeltaAdd(dst, op, delta[1:])
+		switch op.Type {
+		case DeltaOpCopy:
+			err = applyBinaryDeltaCopy(dst, op, src)
+		case DeltaOpInsert:
+			_, err = dst.Write(op.Data)
 		}
 		if err != nil {
 			return err
 		}
-		dstSize -= n
+		dstSize -= op.Size
 	}
 
 	if dstSize != 0 {
@@ -375,68 +891,20 @@ func readBinaryDeltaSize(d []byte) (size int64, rest []byte) {
 	return size, nil
 }
 
-// applyBinaryDeltaAdd applies an add opcode in a delta-encoded binary
-// fragment, returning the amount of data written and the usused part of the
-// fragment. An add operation takes the form:
-//
-//     [0xxxxxx][[data1]...]
-//
-// where the lower seven bits of the opcode is the number of data bytes
-// following the opcode. See also pack-format.txt in the Git source.
-func applyBinaryDeltaAdd(w io.Writer, op byte, delta []byte) (n int64, rest []byte, err error) {
-	size := int(op)
-	if len(delta) < size {
-		return 0, delta, errors.New("corrupt binary delta: incomplete add")
-	}
-	_, err = w.Write(delta[:size])
-	return int64(size), delta[size:], err
-}
-
-// applyBinaryDeltaCopy applies a copy opcode in a delta-encoded binary
-// fragment, returing the amount of data written and the unused part of the
-// fragment. A copy operation takes the form:
-//
-//     [1xxxxxxx][offset1][offset2][offset3][offset4][size1][size2][size3]
-//
-// where the lower seven bits of the opcode determine which non-zero offset and
-// size bytes are present in little-endian order: if bit 0 is set, offset1 is
-// present, etc. If no offset or size bytes are present, offset is 0 and size
-// is 0x10000. See also pack-format.txt in the Git source.
-func applyBinaryDeltaCopy(w io.Writer, op byte, delta []byte, src io.ReaderAt) (n int64, rest []byte, err error) {
-	const defaultSize = 0x10000
-
-	unpack := func(start, bits uint) (v int64) {
-		for i := uint(0); i < bits; i++ {
-			mask := byte(1 << (i + start))
-			if op&mask > 0 {
-				if len(delta) == 0 {
-					err = errors.New("corrupt binary delta: incomplete copy")
-					return
-				}
-				v |= int64(delta[0]) << (8 * i)
-				delta = delta[1:]
-			}
-		}
-		return
-	}
-
-	offset := unpack(0, 4)
-	size := unpack(4, 3)
-	if err != nil {
-		return 0, delta, err
-	}
-	if size == 0 {
-		size = defaultSize
-	}
-
+// applyBinaryDeltaCopy applies a copy instruction in a delta-encoded binary
+// fragment, writing the copied data from src.
+func applyBinaryDeltaCopy(w io.Writer, op DeltaOp, src io.ReaderAt) error {
 	// TODO(bkeyes): consider pooling these buffers
-	b := make([]byte, size)
-	if _, err := src.ReadAt(b, offset); err != nil {
-		return 0, delta, err
+	b := make([]byte, op.Size)
+	if n, err := src.ReadAt(b, op.Offset); n < len(b) {
+		if err == io.EOF {
+			err = errors.New("corrupt binary delta: copy out of bounds")
+		}
+		return err
 	}
 
-	_, err = w.Write(b)
-	return size, delta, err
+	_, err := w.Write(b)
+	return err
 }
 
 func checkBinarySrcSize(r io.ReaderAt, size int64) error {
@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -88,6 +89,32 @@ func TestApplyTextFragment(t *testing.T) {
 		"changeExact":       {Files: getApplyFiles("text_fragment_change_exact")},
 		"changeSingleNoEOL": {Files: getApplyFiles("text_fragment_change_single_noeol")},
 
+		"contextNoEOL": {Files: getApplyFiles("text_fragment_context_noeol")},
+		"removeEndEOL": {Files: getApplyFiles("text_fragment_remove_end_eol")},
+		"addEOL":       {Files: getApplyFiles("text_fragment_add_eol")},
+		"errorMissingEOL": {
+			Files: applyFiles{
+				Src:   "text_fragment_remove_end_eol.out",
+				Patch: "text_fragment_remove_end_eol.patch",
+			},
+			Err: &Conflict{},
+		},
+		"errorRemoveEOLNotAtEnd": {
+			Files: applyFiles{
+				Src:   "text_fragment_remove_end_eol_more.src",
+				Patch: "text_fragment_remove_end_eol.patch",
+			},
+			Options: []ApplierOption{WithUnidiffZero()},
+			Err:     &Conflict{"fragment removes the final newline, but src continues after it"},
+		},
+		"errorUnexpectedEOL": {
+			Files: applyFiles{
+				Src:   "text_fragment_add_eol.out",
+				Patch: "text_fragment_add_eol.patch",
+			},
+			Err: &Conflict{},
+		},
+
 		"errorShortSrcBefore": {
 			Files: applyFiles{
 				Src:   "text_fragment_error.src",
@@ -158,6 +185,13 @@ func TestApplyBinaryFragment(t *testing.T) {
 			},
 			Err: "incomplete copy",
 		},
+		"errorCopyOutOfBounds": {
+			Files: applyFiles{
+				Src:   "bin_fragment_delta_error.src",
+				Patch: "bin_fragment_delta_error_copy_bounds.patch",
+			},
+			Err: "copy out of bounds",
+		},
 		"errorSrcSize": {
 			Files: applyFiles{
 				Src:   "bin_fragment_delta_error.src",
@@ -212,6 +246,504 @@ func TestApplyFile(t *testing.T) {
 		"modeChange": {
 			Files: getApplyFiles("file_mode_change"),
 		},
+		"renameOnly": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_rename_only.patch",
+				Out:   "file_text.src",
+			},
+		},
+		"emptyNew": {
+			Files:   applyFiles{Patch: "file_empty_new.patch"},
+			Options: []ApplierOption{WithOIDCheck(), WithNewOIDCheck()},
+		},
+		"emptyDelete": {
+			Files:   applyFiles{Patch: "file_empty_delete.patch"},
+			Options: []ApplierOption{WithOIDCheck(), WithNewOIDCheck()},
+		},
+		"emptyNewErrorSource": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_empty_new.patch",
+			},
+			Err: &Conflict{"new file source is not empty"},
+		},
+		"emptyDeleteErrorSource": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_empty_delete.patch",
+			},
+			Err: &Conflict{"removal patch leaves file contents"},
+		},
+		"emptyNewReverseErrorSource": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_empty_new.patch",
+			},
+			Options: []ApplierOption{WithReverse()},
+			Err:     &Conflict{"removal patch leaves file contents"},
+		},
+		"textOIDCheck": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_oid.patch",
+				Out:   "file_text_modify.out",
+			},
+			Options: []ApplierOption{WithOIDCheck(), WithNewOIDCheck()},
+		},
+		"textOIDCheckMissingIndex": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_modify.patch",
+				Out:   "file_text_modify.out",
+			},
+			Options: []ApplierOption{WithOIDCheck(), WithNewOIDCheck()},
+		},
+		"textOIDCheckDelete": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_delete.patch",
+				Out:   "file_text_delete.out",
+			},
+			Options: []ApplierOption{WithOIDCheck(), WithNewOIDCheck()},
+		},
+		"textErrorOIDSource": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_oid_error_src.patch",
+			},
+			Options: []ApplierOption{WithOIDCheck()},
+			Err:     "source 3805ad4c93148145bc67b7006564c9c22df8d1e8 does not match index 3805ad5",
+		},
+		"textErrorOIDResult": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_oid_error_result.patch",
+			},
+			Options: []ApplierOption{WithOIDCheck(), WithNewOIDCheck()},
+			Err:     &Conflict{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(w io.Writer, applier *Applier, file *File) error {
+				return applier.ApplyFile(w, file)
+			})
+		})
+	}
+}
+
+func TestApplyReverse(t *testing.T) {
+	tests := map[string]applyTest{
+		"createFile":   {Files: getReverseApplyFiles("text_fragment_new")},
+		"deleteFile":   {Files: getReverseApplyFiles("text_fragment_delete_all")},
+		"addEndNoEOL":  {Files: getReverseApplyFiles("text_fragment_add_end_noeol")},
+		"contextNoEOL": {Files: getReverseApplyFiles("text_fragment_context_noeol")},
+		"removeEndEOL": {Files: getReverseApplyFiles("text_fragment_remove_end_eol")},
+		"addEOL":       {Files: getReverseApplyFiles("text_fragment_add_eol")},
+		"textModify": {
+			Files: applyFiles{
+				Src:   "file_text_modify.out",
+				Patch: "file_text_modify.patch",
+				Out:   "file_text.src",
+			},
+		},
+		"modeChange":    {Files: getReverseApplyFiles("file_mode_change")},
+		"binaryModify":  {Files: getReverseApplyFiles("file_bin_modify")},
+		"binaryLiteral": {Files: getReverseApplyFiles("bin_fragment_literal_modify")},
+		"binaryCreate":  {Files: getReverseApplyFiles("bin_fragment_literal_create")},
+		"binaryDelta":   {Files: getReverseApplyFiles("bin_fragment_delta_modify_large")},
+		"textOIDCheck": {
+			Files: applyFiles{
+				Src:   "file_text_modify.out",
+				Patch: "file_text_oid.patch",
+				Out:   "file_text.src",
+			},
+			Options: []ApplierOption{WithOIDCheck(), WithNewOIDCheck()},
+		},
+
+		"errorContextConflict": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_modify.patch",
+			},
+			Err: &Conflict{},
+		},
+		"errorBinaryNoReverse": {
+			Files: applyFiles{
+				Src:   "file_bin_modify.out",
+				Patch: "file_bin_modify_noreverse.patch",
+			},
+			Err: "without reverse fragment",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.Options = append(test.Options, WithReverse())
+			test.run(t, func(w io.Writer, applier *Applier, file *File) error {
+				return applier.ApplyFile(w, file)
+			})
+		})
+	}
+}
+
+func TestApplyUnidiffZero(t *testing.T) {
+	tests := map[string]applyTest{
+		"unidiffZero": {
+			Files:   getApplyFiles("text_fragment_unidiff_zero"),
+			Options: []ApplierOption{WithUnidiffZero()},
+		},
+		"adjacentFragments": {
+			Files: applyFiles{
+				Src:   "text_fragment_unidiff_zero.src",
+				Patch: "text_fragment_unidiff_zero_adjacent.patch",
+				Out:   "text_fragment_unidiff_zero_adjacent.out",
+			},
+			Options: []ApplierOption{WithUnidiffZero()},
+		},
+		"reverse": {
+			Files:   getReverseApplyFiles("text_fragment_unidiff_zero"),
+			Options: []ApplierOption{WithUnidiffZero(), WithReverse()},
+		},
+		"offset": {
+			Files:   getApplyFiles("text_fragment_unidiff_zero"),
+			Options: []ApplierOption{WithUnidiffZero(), WithMaxOffset(3)},
+		},
+		"errorWithoutOption": {
+			Files: applyFiles{
+				Src:   "text_fragment_unidiff_zero.src",
+				Patch: "text_fragment_unidiff_zero.patch",
+			},
+			Err: &Conflict{},
+		},
+		"errorAnchorEnd": {
+			Files: applyFiles{
+				Src:   "text_fragment_unidiff_zero.src",
+				Patch: "text_fragment_unidiff_zero_adjacent.patch",
+			},
+			Err: &Conflict{"fragment does not match the start or end of src"},
+		},
+		"errorAnchorEndOffset": {
+			Files: applyFiles{
+				Src:   "text_fragment_unidiff_zero.src",
+				Patch: "text_fragment_unidiff_zero_adjacent.patch",
+			},
+			Options: []ApplierOption{WithMaxOffset(3)},
+			Err:     &Conflict{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(w io.Writer, applier *Applier, file *File) error {
+				return applier.ApplyFile(w, file)
+			})
+		})
+	}
+}
+
+func TestApplyFuzzy(t *testing.T) {
+	tests := map[string]struct {
+		applyTest
+		Matches []FragmentMatch
+	}{
+		"exact": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text.src",
+					Patch: "file_text_modify.patch",
+					Out:   "file_text_modify.out",
+				},
+				Options: []ApplierOption{WithFuzz(2), WithMaxOffset(10)},
+			},
+			Matches: []FragmentMatch{
+				{Line: 1}, {Line: 17}, {Line: 53}, {Line: 130}, {Line: 161},
+			},
+		},
+		"offset": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_offset.src",
+					Patch: "file_text_modify.patch",
+					Out:   "file_text_offset.out",
+				},
+				Options: []ApplierOption{WithMaxOffset(3)},
+			},
+			Matches: []FragmentMatch{
+				{Line: 1}, {Line: 20, Offset: 3}, {Line: 56, Offset: 3}, {Line: 133, Offset: 3}, {Line: 164, Offset: 3},
+			},
+		},
+		"fuzz": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_fuzz.src",
+					Patch: "file_text_modify.patch",
+					Out:   "file_text_fuzz.out",
+				},
+				Options: []ApplierOption{WithFuzz(2)},
+			},
+			Matches: []FragmentMatch{
+				{Line: 1}, {Line: 17, Fuzz: 1}, {Line: 53}, {Line: 130, Fuzz: 2}, {Line: 161},
+			},
+		},
+		"errorOffsetTooSmall": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_offset.src",
+					Patch: "file_text_modify.patch",
+				},
+				Options: []ApplierOption{WithMaxOffset(2)},
+				Err:     &Conflict{},
+			},
+		},
+		"errorFuzzTooSmall": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_fuzz.src",
+					Patch: "file_text_modify.patch",
+				},
+				Options: []ApplierOption{WithFuzz(1)},
+				Err:     &Conflict{},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(w io.Writer, applier *Applier, file *File) error {
+				if err := applier.ApplyFile(w, file); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(test.Matches, applier.Matches()) {
+					t.Errorf("incorrect matches\nexpected: %+v\n  actual: %+v", test.Matches, applier.Matches())
+				}
+				return nil
+			})
+		})
+	}
+}
+
+func TestApplyWhitespace(t *testing.T) {
+	wsErrors := func(fixed bool) []WhitespaceError {
+		return []WhitespaceError{
+			{Fragment: 1, FragmentLine: 5, Line: "new line  \n", Kind: WhitespaceBlankAtEOL, Fixed: fixed},
+			{Fragment: 1, FragmentLine: 6, Line: "line four\t\n", Kind: WhitespaceBlankAtEOL, Fixed: fixed},
+		}
+	}
+
+	tests := map[string]struct {
+		applyTest
+		WhitespaceErrors []WhitespaceError
+	}{
+		"nowarn": {
+			applyTest: applyTest{
+				Files: getApplyFiles("file_text_whitespace"),
+			},
+		},
+		"warn": {
+			applyTest: applyTest{
+				Files:   getApplyFiles("file_text_whitespace"),
+				Options: []ApplierOption{WithWhitespace(WhitespaceWarn)},
+			},
+			WhitespaceErrors: wsErrors(false),
+		},
+		"fix": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_whitespace.src",
+					Patch: "file_text_whitespace.patch",
+					Out:   "file_text_whitespace_fix.out",
+				},
+				Options: []ApplierOption{WithWhitespace(WhitespaceFix)},
+			},
+			WhitespaceErrors: wsErrors(true),
+		},
+		"fixTrailingContext": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_whitespace_trailing.src",
+					Patch: "file_text_whitespace.patch",
+					Out:   "file_text_whitespace_fix.out",
+				},
+				Options: []ApplierOption{WithWhitespace(WhitespaceFix)},
+			},
+			WhitespaceErrors: wsErrors(true),
+		},
+		"ignoreWhitespace": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_whitespace_space.src",
+					Patch: "file_text_whitespace.patch",
+					Out:   "file_text_whitespace_space.out",
+				},
+				Options: []ApplierOption{WithIgnoreWhitespace()},
+			},
+		},
+		"errorReject": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_whitespace.src",
+					Patch: "file_text_whitespace.patch",
+				},
+				Options: []ApplierOption{WithWhitespace(WhitespaceReject)},
+				Err:     &WhitespaceError{},
+			},
+		},
+		"errorTrailingContext": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_whitespace_trailing.src",
+					Patch: "file_text_whitespace.patch",
+				},
+				Options: []ApplierOption{WithWhitespace(WhitespaceWarn)},
+				Err:     &Conflict{},
+			},
+		},
+		"errorIgnoreTrailingContext": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_whitespace_trailing.src",
+					Patch: "file_text_whitespace.patch",
+				},
+				Options: []ApplierOption{WithIgnoreWhitespace()},
+				Err:     &Conflict{},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(w io.Writer, applier *Applier, file *File) error {
+				if err := applier.ApplyFile(w, file); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(test.WhitespaceErrors, applier.WhitespaceErrors()) {
+					t.Errorf("incorrect whitespace errors\nexpected: %+v\n  actual: %+v", test.WhitespaceErrors, applier.WhitespaceErrors())
+				}
+				return nil
+			})
+		})
+	}
+}
+
+func TestApplyReject(t *testing.T) {
+	tests := map[string]struct {
+		applyTest
+		Rejects []int
+		Reject  string
+	}{
+		"reject": {
+			applyTest: applyTest{
+				Files:   getApplyFiles("file_text_reject"),
+				Options: []ApplierOption{WithReject()},
+			},
+			Rejects: []int{1},
+			Reject:  "file_text_reject.rej",
+		},
+		"cleanApply": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text.src",
+					Patch: "file_text_modify.patch",
+					Out:   "file_text_modify.out",
+				},
+				Options: []ApplierOption{WithReject()},
+			},
+		},
+		"errorWithoutReject": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_reject.src",
+					Patch: "file_text_reject.patch",
+				},
+				Err: &Conflict{},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(w io.Writer, applier *Applier, file *File) error {
+				if err := applier.ApplyFile(w, file); err != nil {
+					return err
+				}
+
+				var rejects []*TextFragment
+				for _, i := range test.Rejects {
+					rejects = append(rejects, file.TextFragments[i])
+				}
+				if !reflect.DeepEqual(rejects, applier.Rejects()) {
+					t.Errorf("incorrect rejects\nexpected: %+v\n  actual: %+v", rejects, applier.Rejects())
+				}
+
+				if test.Reject != "" {
+					_, _, expected := applyFiles{Out: test.Reject}.Load(t)
+
+					var b bytes.Buffer
+					if _, err := FormatRejects(&b, file, applier.Rejects()); err != nil {
+						t.Fatalf("unexpected error formatting rejects: %v", err)
+					}
+					if !bytes.Equal(expected, b.Bytes()) {
+						t.Errorf("incorrect reject file\nexpected:\n%q\nactual:\n%q", expected, b.Bytes())
+					}
+				}
+				return nil
+			})
+		})
+	}
+}
+
+func TestApplyLineEndings(t *testing.T) {
+	tests := map[string]applyTest{
+		"strictCRLFSource": {
+			Files: applyFiles{
+				Src:   "file_text_crlf.src",
+				Patch: "file_text_modify.patch",
+			},
+			Err: &Conflict{},
+		},
+		"crlfSource": {
+			Files: applyFiles{
+				Src:   "file_text_crlf.src",
+				Patch: "file_text_modify.patch",
+				Out:   "file_text_crlf.out",
+			},
+			Options: []ApplierOption{WithLineEndings(LineEndingPreserve)},
+		},
+		"crlfPatch": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_crlf.patch",
+				Out:   "file_text_modify.out",
+			},
+			Options: []ApplierOption{WithLineEndings(LineEndingPreserve)},
+		},
+		"mixedSource": {
+			Files: applyFiles{
+				Src:   "file_text_crlf_mixed.src",
+				Patch: "file_text_modify.patch",
+				Out:   "file_text_crlf_mixed.out",
+			},
+			Options: []ApplierOption{WithLineEndings(LineEndingPreserve)},
+		},
+		"reverse": {
+			Files: applyFiles{
+				Src:   "file_text_crlf.out",
+				Patch: "file_text_modify.patch",
+				Out:   "file_text_crlf.src",
+			},
+			Options: []ApplierOption{WithLineEndings(LineEndingPreserve), WithReverse()},
+		},
+		"fuzz": {
+			Files: applyFiles{
+				Src:   "file_text_crlf.src",
+				Patch: "file_text_crlf.patch",
+				Out:   "file_text_crlf.out",
+			},
+			Options: []ApplierOption{WithLineEndings(LineEndingPreserve), WithFuzz(2), WithMaxOffset(10)},
+		},
 	}
 
 	for name, test := range tests {
@@ -224,25 +756,27 @@ func TestApplyFile(t *testing.T) {
 }
 
 type applyTest struct {
-	Files applyFiles
-	Err   interface{}
+	Files   applyFiles
+	Options []ApplierOption
+	Err     interface{}
 }
 
 func (at applyTest) run(t *testing.T, apply func(io.Writer, *Applier, *File) error) {
 	src, patch, out := at.Files.Load(t)
 
-	files, _, err := Parse(bytes.NewReader(patch))
+	files, err := Parse(bytes.NewReader(patch))
 	if err != nil {
 		t.Fatalf("failed to parse patch file: %v", err)
 	}
-	if len(files) != 1 {
-		t.Fatalf("patch should contain exactly one file, but it has %d", len(files))
+	parsed := collectFiles(files)
+	if len(parsed) != 1 {
+		t.Fatalf("patch should contain exactly one file, but it has %d", len(parsed))
 	}
 
-	applier := NewApplier(bytes.NewReader(src))
+	applier := NewApplier(bytes.NewReader(src), at.Options...)
 
 	var dst bytes.Buffer
-	err = apply(&dst, applier, files[0])
+	err = apply(&dst, applier, parsed[0])
 	if at.Err != nil {
 		assertError(t, at.Err, err, "applying fragment")
 		return
@@ -275,6 +809,16 @@ func getApplyFiles(name string) applyFiles {
 	}
 }
 
+// getReverseApplyFiles returns files for applying the named patch in reverse,
+// using the normal output as the source and the normal source as the output.
+func getReverseApplyFiles(name string) applyFiles {
+	return applyFiles{
+		Src:   name + ".out",
+		Patch: name + ".patch",
+		Out:   name + ".src",
+	}
+}
+
 func (f applyFiles) Load(t *testing.T) (src []byte, patch []byte, out []byte) {
 	load := func(name, kind string) []byte {
 		d, err := ioutil.ReadFile(filepath.Join("testdata", "apply", name))
@@ -0,0 +1,844 @@
+package gitdiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// WriteFS is a file system that supports modifying files. Names are slash
+// separated paths that satisfy fs.ValidPath.
+type WriteFS interface {
+	fs.FS
+
+	// WriteFile creates or replaces the named file with data. The file has
+	// the permissions perm after the write, even if it already existed.
+	// WriteFile creates any missing parent directories.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+}
+
+// SymlinkFS is a WriteFS that supports symbolic links. ApplyFS reads and
+// creates symbolic links for files with mode 120000 only if the file system
+// implements SymlinkFS. The content of a link in a patch is its target.
+type SymlinkFS interface {
+	WriteFS
+
+	// Lstat returns information about the named file without following
+	// symbolic links.
+	Lstat(name string) (fs.FileInfo, error)
+
+	// ReadLink returns the target of the named symbolic link.
+	ReadLink(name string) (string, error)
+
+	// Symlink creates the named file as a symbolic link to target. Symlink
+	// creates any missing parent directories.
+	Symlink(target, name string) error
+}
+
+// ChmodFS is a WriteFS that can change the permissions of a file. ApplyFS
+// uses Chmod for files that only change mode if the file system implements
+// ChmodFS, instead of writing the content of the file again.
+type ChmodFS interface {
+	WriteFS
+
+	// Chmod changes the permissions of the named file to perm.
+	Chmod(name string, perm fs.FileMode) error
+}
+
+// ChtimesFS is a WriteFS that can change the modification time of a file.
+// ApplyFS uses Chtimes to set the time of files it writes if WithTimestamps
+// is set and the file system implements ChtimesFS.
+type ChtimesFS interface {
+	WriteFS
+
+	// Chtimes changes the access and modification times of the named file.
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// linkReader is the part of SymlinkFS that reads symbolic links.
+type linkReader interface {
+	Lstat(name string) (fs.FileInfo, error)
+	ReadLink(name string) (string, error)
+}
+
+// DirFS returns a WriteFS for the tree of files rooted at the directory dir.
+// The file system also implements SymlinkFS, ChmodFS, and ChtimesFS. Like
+// os.DirFS, it does not prevent access to files outside of dir through
+// symbolic links, but ApplyFS refuses to change files beyond a symbolic link
+// unless WithUnsafePaths is set.
+func DirFS(dir string) WriteFS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (d dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	path, err := d.join("write", name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return err
+	}
+	return os.Chmod(path, perm)
+}
+
+func (d dirFS) Remove(name string) error {
+	path, err := d.join("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (d dirFS) Chmod(name string, perm fs.FileMode) error {
+	path, err := d.join("chmod", name)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(path, perm)
+}
+
+func (d dirFS) Chtimes(name string, atime, mtime time.Time) error {
+	path, err := d.join("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(path, atime, mtime)
+}
+
+func (d dirFS) Lstat(name string) (fs.FileInfo, error) {
+	path, err := d.join("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(path)
+}
+
+func (d dirFS) ReadLink(name string) (string, error) {
+	path, err := d.join("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(path)
+}
+
+func (d dirFS) Symlink(target, name string) error {
+	path, err := d.join("symlink", name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	return os.Symlink(target, path)
+}
+
+func (d dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(name)), nil
+}
+
+// ApplyFS applies the changes in files to the files in fsys, like running
+// "git apply" in a working directory. It creates, deletes, renames, and copies
+// files, changes modes, and modifies content as described by each File.
+// Options configure the application of fragments in the same way as for
+// Apply. Files in the patch are applied in order, so a file may depend on
+// the changes made by earlier files. Like Git, files are applied in reverse
+// order when applying in reverse, and copies are undone by deleting the
+// copied file. Files with mode 120000 are symbolic links and require a file
+// system that implements SymlinkFS.
+//
+// ApplyFS handles the changes to the types of files described by
+// FindTypeChanges. A file can replace a directory if the patch deletes all of
+// the files in the directory, and ApplyFS removes the empty directory before
+// creating the file. Symbolic links are removed before they are replaced, so
+// ApplyFS never writes to the target of a link.
+//
+// ApplyFS computes the result of all files before modifying fsys. If any file
+// does not apply, ApplyFS returns an error and does not modify fsys. If
+// writing the result to fsys fails, ApplyFS attempts to restore all modified
+// files to their original state before returning the error.
+//
+// With WithReject, ApplyFS applies the fragments of each file that apply and
+// writes the other fragments to a file with the new name of the file and a
+// ".rej" suffix, replacing any existing file with that name. Like Git, a
+// deleted file with rejected fragments is still an error.
+//
+// Like Git, ApplyFS returns an error for files beyond a symbolic link, where a
+// leading directory of the name is a symbolic link in fsys or in the result,
+// so that a patch can't change files outside of fsys. Names must be valid
+// paths for fs.FS, so absolute paths and paths containing ".." are always
+// errors.
+func ApplyFS(fsys WriteFS, files []*File, opts ...ApplierOption) error {
+	a := NewApplier(nil, opts...)
+
+	s := &fsState{fsys: fsys, files: make(map[string]*fsFile), unsafePaths: a.unsafePaths}
+	if err := s.applyFiles(applyOrder(files, a.reverse), a.workers, a.reverse, opts); err != nil {
+		return err
+	}
+	return s.commit(fsys)
+}
+
+// ApplyToMap applies the changes in files to an in-memory set of files,
+// where the keys of m are slash separated paths and the values are their
+// content. It handles files in the same way as ApplyFS and returns a new map
+// with the result, without modifying m. File modes are not recorded, so the
+// value for a symbolic link is its target, and other mode changes must still
+// be for regular files. If any file does not apply,
+// ApplyToMap returns nil and the error.
+func ApplyToMap(m map[string][]byte, files []*File, opts ...ApplierOption) (map[string][]byte, error) {
+	a := NewApplier(nil, opts...)
+
+	s := &fsState{fsys: emptyFS{}, files: make(map[string]*fsFile, len(m)), unsafePaths: a.unsafePaths}
+	for name, data := range m {
+		s.files[name] = &fsFile{data: data, perm: 0644, exists: true}
+	}
+	if err := s.applyFiles(applyOrder(files, a.reverse), a.workers, a.reverse, opts); err != nil {
+		return nil, err
+	}
+	if _, err := s.checkDirectories(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte, len(s.files))
+	for name, f := range s.files {
+		if f.exists {
+			result[name] = f.data
+		}
+	}
+	return result, nil
+}
+
+// emptyFS is a file system that contains no files.
+type emptyFS struct{}
+
+func (emptyFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// WithUnsafePaths configures ApplyFS, ApplyToMap, and ApplyCheck to change
+// files beyond a symbolic link, which can change files outside of the file
+// system. Use it only for trusted patches, like "git apply --unsafe-paths".
+// Apply ignores this option.
+func WithUnsafePaths() ApplierOption {
+	return func(a *Applier) {
+		a.unsafePaths = true
+	}
+}
+
+// WithTimestamps configures ApplyFS and ApplyCheck to use the OldTime and
+// NewTime of files from traditional patches, like the -Z flag of "patch".
+// Before applying a file, they check that the modification time of the
+// original file matches OldTime and return a Conflict if it does not. Times
+// without fractional seconds match any time in the same second. After
+// writing a file, ApplyFS sets its modification time to NewTime if the file
+// system implements ChtimesFS. Files without times are not checked, and
+// Apply and ApplyToMap ignore this option.
+func WithTimestamps() ApplierOption {
+	return func(a *Applier) {
+		a.timestamps = true
+	}
+}
+
+// CheckResult describes whether a file from a patch applies.
+type CheckResult struct {
+	// File is the file from the patch
+	File *File
+	// Err is the reason the file does not apply, or nil if it applies
+	Err error
+	// Rejects contains the text fragments of the file that do not apply to
+	// the source, if any, and the reason for each one
+	Rejects []RejectedFragment
+}
+
+// RejectedFragment is a text fragment that does not apply to its source.
+type RejectedFragment struct {
+	Fragment *TextFragment
+	Err      error
+}
+
+// ApplyCheck checks if files apply to the files in fsys without modifying
+// fsys, like "git apply --check". Like ApplyFS, it applies files in order, so
+// a file may depend on the changes made by earlier files, but it checks all
+// files instead of stopping at the first that does not apply. Options
+// configure the application of fragments in the same way as for Apply, except
+// that ApplyCheck ignores WithReject and reports every text fragment that does
+// not apply.
+//
+// ApplyCheck returns a result for each file in order and the first error, if
+// any file does not apply.
+func ApplyCheck(fsys fs.FS, files []*File, opts ...ApplierOption) ([]CheckResult, error) {
+	opts = append(opts[:len(opts):len(opts)], func(a *Applier) { a.reject = false })
+	a := NewApplier(nil, opts...)
+
+	s := &fsState{fsys: fsys, files: make(map[string]*fsFile), unsafePaths: a.unsafePaths}
+	results := make([]CheckResult, len(files))
+
+	var firstErr error
+	for j := range files {
+		i := j
+		if a.reverse {
+			i = len(files) - 1 - j
+		}
+		f := files[i]
+		results[i].File = f
+		if err := s.applyFile(f, a.reverse, opts); err != nil {
+			results[i].Err = err
+			results[i].Rejects = s.checkFragments(f, a.reverse, opts)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if name, err := s.checkDirectories(); err != nil {
+		for i, f := range files {
+			if results[i].Err == nil && (f.NewName == name && !a.reverse || f.OldName == name && a.reverse) {
+				results[i].Err = err
+				break
+			}
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return results, firstErr
+}
+
+// applyOrder returns files in the order they apply, which is reversed when
+// applying in reverse.
+func applyOrder(files []*File, reverse bool) []*File {
+	if !reverse {
+		return files
+	}
+	reversed := make([]*File, len(files))
+	for i, f := range files {
+		reversed[len(files)-1-i] = f
+	}
+	return reversed
+}
+
+// fsFile is the content of a file in an fsState. The data of a symbolic link
+// is its target. Files that do not exist because the name is a directory in
+// the file system have dir set, and files that do not exist because a
+// leading directory is a file in the file system have the name of the file
+// in parent. The modification time is only set for regular files read from
+// the file system and for results with a time from the patch.
+type fsFile struct {
+	data    []byte
+	perm    fs.FileMode
+	exists  bool
+	symlink bool
+	dir     bool
+	parent  string
+	modTime time.Time
+}
+
+// fsState tracks the result of applying files to a file system without
+// modifying it. It records the original content of all files it reads so
+// that it can restore them if a later write fails.
+type fsState struct {
+	fsys     fs.FS
+	files    map[string]*fsFile
+	original map[string]fsFile
+	order    []string
+
+	unsafePaths bool
+}
+
+// get returns the current content of the named file.
+func (s *fsState) get(name string) (*fsFile, error) {
+	if f, ok := s.files[name]; ok {
+		return f, nil
+	}
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("invalid file name: %q", name)
+	}
+	if err := s.checkPath(name); err != nil {
+		return nil, err
+	}
+
+	var info fs.FileInfo
+	var err error
+	lr, canLink := s.fsys.(linkReader)
+	if canLink {
+		info, err = lr.Lstat(name)
+	} else {
+		info, err = fs.Stat(s.fsys, name)
+	}
+
+	f := &fsFile{}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		// the file does not exist if a leading directory is a file, which the
+		// patch may replace with a directory
+		if f.parent = leadingFile(s.fsys, name); f.parent != "" {
+			err = fs.ErrNotExist
+		}
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := lr.ReadLink(name)
+		if err != nil {
+			return nil, err
+		}
+		f.data, f.exists, f.symlink = []byte(target), true, true
+	case info.IsDir():
+		f.dir = true
+	case !info.Mode().IsRegular():
+		return nil, fmt.Errorf("%s: not a regular file", name)
+	default:
+		data, err := fs.ReadFile(s.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		f.data, f.perm, f.exists, f.modTime = data, info.Mode().Perm(), true, info.ModTime()
+	}
+
+	if s.original == nil {
+		s.original = make(map[string]fsFile)
+	}
+	s.original[name] = *f
+	s.order = append(s.order, name)
+	s.files[name] = f
+	return f, nil
+}
+
+// checkPath returns an error if a leading directory of name is a symbolic
+// link in the current state or in the file system, unless unsafe paths are
+// allowed.
+func (s *fsState) checkPath(name string) error {
+	if s.unsafePaths {
+		return nil
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' && s.isSymlink(name[:i]) {
+			return fmt.Errorf("%s: affected file is beyond a symbolic link", name)
+		}
+	}
+	return nil
+}
+
+// leadingFile returns the first leading directory of name that is a file in
+// fsys, or an empty string if there is none.
+func leadingFile(fsys fs.FS, name string) string {
+	for i := 0; i < len(name); i++ {
+		if name[i] != '/' {
+			continue
+		}
+		if info, err := fs.Stat(fsys, name[:i]); err == nil && !info.IsDir() {
+			return name[:i]
+		}
+	}
+	return ""
+}
+
+// checkDirectories returns an error and the name of the file if a file in
+// the current state is in a directory that is a file or replaces a directory
+// that still contains files. Files can only replace directories if the patch
+// deletes every file in them.
+func (s *fsState) checkDirectories() (string, error) {
+	names := make([]string, 0, len(s.files))
+	for name, f := range s.files {
+		if f.exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for i := 0; i < len(name); i++ {
+			if name[i] != '/' {
+				continue
+			}
+			if f, ok := s.files[name[:i]]; ok && f.exists {
+				return name, fmt.Errorf("%s: %w", name, &Conflict{"leading directory is a file"})
+			}
+		}
+		if parent := s.original[name].parent; parent != "" {
+			if f, ok := s.files[parent]; !ok || f.exists {
+				return name, fmt.Errorf("%s: %w", name, &Conflict{"leading directory is a file"})
+			}
+		}
+		if !s.original[name].dir {
+			continue
+		}
+
+		var found bool
+		err := fs.WalkDir(s.fsys, name, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if f, ok := s.files[path]; !d.IsDir() && (!ok || f.exists) {
+				found = true
+				return fs.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return name, err
+		}
+		if found {
+			return name, fmt.Errorf("%s: %w", name, &Conflict{"file replaces a directory that is not empty"})
+		}
+	}
+	return "", nil
+}
+
+// isSymlink returns true if the named file is a symbolic link in the current
+// state or, if the state does not contain the file, in the file system.
+func (s *fsState) isSymlink(name string) bool {
+	if f, ok := s.files[name]; ok {
+		return f.exists && f.symlink
+	}
+	lr, ok := s.fsys.(linkReader)
+	if !ok {
+		return false
+	}
+	info, err := lr.Lstat(name)
+	return err == nil && info.Mode()&fs.ModeSymlink != 0
+}
+
+// applyFile applies f to the current state.
+func (s *fsState) applyFile(f *File, reverse bool, opts []ApplierOption) error {
+	if err := f.LoadFragments(); err != nil {
+		return err
+	}
+	if reverse && f.IsCopy {
+		return s.applyReverseCopy(f, reverse, opts)
+	}
+	return s.apply(f, reverse, opts)
+}
+
+// checkFragments returns the text fragments of f that do not apply to the
+// content of its source in the current state.
+func (s *fsState) checkFragments(f *File, reverse bool, opts []ApplierOption) []RejectedFragment {
+	name := f.OldName
+	switch {
+	case reverse && f.IsCopy:
+		name = f.NewName
+	case reverse:
+		if name = f.NewName; f.IsDelete {
+			name = f.OldName
+		}
+	case f.IsNew:
+		name = f.NewName
+	}
+
+	src, ok := s.files[name]
+	if !ok || !src.exists || len(f.TextFragments) == 0 {
+		return nil
+	}
+
+	a := NewApplier(bytes.NewReader(src.data), append(opts, WithReject(), func(a *Applier) { a.progress.fn = nil })...)
+	if err := a.ApplyFile(io.Discard, f); err != nil {
+		return nil
+	}
+
+	var rejects []RejectedFragment
+	for i, frag := range a.Rejects() {
+		rejects = append(rejects, RejectedFragment{Fragment: frag, Err: a.rejectErrs[i]})
+	}
+	return rejects
+}
+
+func (s *fsState) apply(f *File, reverse bool, opts []ApplierOption) error {
+	// the reversed file has the names and modes of the result, while the
+	// content is reversed by the option when applying the patch
+	names := f
+	if reverse {
+		names = f.Reverse()
+	}
+
+	oldName, newName := names.OldName, names.NewName
+	if names.IsNew {
+		oldName = newName
+	}
+	if names.IsDelete {
+		newName = oldName
+	}
+
+	src, err := s.get(oldName)
+	if err != nil {
+		return err
+	}
+	switch {
+	case names.IsNew && src.exists:
+		return fmt.Errorf("%s: %w", newName, &Conflict{"file already exists"})
+	case !names.IsNew && !src.exists:
+		return fmt.Errorf("%s: %w", oldName, &Conflict{"file does not exist"})
+	}
+
+	dst := src
+	if newName != oldName {
+		if dst, err = s.get(newName); err != nil {
+			return err
+		}
+		if dst.exists {
+			return fmt.Errorf("%s: %w", newName, &Conflict{"file already exists"})
+		}
+	}
+
+	if f.IsBinary && f.BinaryFragment == nil && f.OldOIDPrefix != f.NewOIDPrefix {
+		return fmt.Errorf("%s: cannot apply binary patch without binary data", oldName)
+	}
+
+	a := NewApplier(bytes.NewReader(src.data), opts...)
+	if a.timestamps && !names.IsNew && !names.OldTime.IsZero() && !src.modTime.IsZero() && !matchTime(src.modTime, names.OldTime) {
+		return fmt.Errorf("%s: %w", oldName, &Conflict{"modification time does not match patch"})
+	}
+
+	var out bytes.Buffer
+	if err := a.ApplyFile(&out, f); err != nil {
+		return fmt.Errorf("%s: %w", oldName, err)
+	}
+
+	if names.IsDelete {
+		if out.Len() > 0 {
+			return fmt.Errorf("%s: %w", oldName, &Conflict{"removal patch leaves file contents"})
+		}
+		*src = fsFile{}
+		return nil
+	}
+
+	if rejects := a.Rejects(); len(rejects) > 0 {
+		if err := s.writeRejects(names, newName, rejects); err != nil {
+			return err
+		}
+	}
+
+	perm, symlink := src.perm, src.symlink
+	switch {
+	case names.NewMode == symlinkMode:
+		perm, symlink = 0, true
+	case names.NewMode != 0:
+		if perm, err = fileModePerm(names.NewMode); err != nil {
+			return fmt.Errorf("%s: %v", newName, err)
+		}
+		symlink = false
+	case !src.exists:
+		perm = 0644
+	}
+
+	if names.IsRename {
+		*src = fsFile{}
+	}
+	*dst = fsFile{data: out.Bytes(), perm: perm, exists: true, symlink: symlink}
+	if a.timestamps && !symlink {
+		dst.modTime = names.NewTime
+	}
+	return nil
+}
+
+// matchTime returns true if the modification time of a file matches the time
+// t from a patch, ignoring fractional seconds if t has none.
+func matchTime(modTime, t time.Time) bool {
+	if t.Nanosecond() == 0 {
+		modTime = modTime.Truncate(time.Second)
+	}
+	return modTime.Equal(t)
+}
+
+// writeRejects records a reject file for the fragments of f that did not apply
+// to the named file.
+func (s *fsState) writeRejects(f *File, name string, frags []*TextFragment) error {
+	rej, err := s.get(name + ".rej")
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if _, err := FormatRejects(&b, f, frags); err != nil {
+		return err
+	}
+	*rej = fsFile{data: b.Bytes(), perm: 0644, exists: true}
+	return nil
+}
+
+// applyReverseCopy undoes a copy by deleting the copy after checking that
+// reversing the changes in f produces the content of the original file.
+func (s *fsState) applyReverseCopy(f *File, reverse bool, opts []ApplierOption) error {
+	src, err := s.get(f.OldName)
+	if err != nil {
+		return err
+	}
+	cp, err := s.get(f.NewName)
+	if err != nil {
+		return err
+	}
+	if !cp.exists {
+		return fmt.Errorf("%s: %w", f.NewName, &Conflict{"file does not exist"})
+	}
+
+	var out bytes.Buffer
+	if err := Apply(&out, bytes.NewReader(cp.data), f, opts...); err != nil {
+		return fmt.Errorf("%s: %w", f.NewName, err)
+	}
+	if !src.exists || !bytes.Equal(out.Bytes(), src.data) {
+		return fmt.Errorf("%s: %w", f.NewName, &Conflict{"copy does not match original file"})
+	}
+
+	*cp = fsFile{}
+	return nil
+}
+
+// commit writes the current state to the file system, restoring the original
+// content of any modified files if a write fails.
+func (s *fsState) commit(fsys WriteFS) error {
+	// files may be beyond links created by later files in the patch
+	for _, name := range s.order {
+		if f := s.files[name]; f.exists && !f.equal(s.original[name]) {
+			if err := s.checkPath(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := s.checkDirectories(); err != nil {
+		return err
+	}
+
+	if _, ok := fsys.(SymlinkFS); !ok {
+		for _, name := range s.order {
+			if f := s.files[name]; f.exists && f.symlink && !f.equal(s.original[name]) {
+				return fmt.Errorf("%s: file system does not support symbolic links", name)
+			}
+		}
+	}
+
+	// like Git, remove files before writing others so that files can replace
+	// the directories that contained removed files
+	var order []string
+	for _, name := range s.order {
+		if !s.files[name].exists {
+			order = append(order, name)
+		}
+	}
+	for _, name := range s.order {
+		if s.files[name].exists {
+			order = append(order, name)
+		}
+	}
+
+	var written []string
+	for _, name := range order {
+		if s.files[name].equal(s.original[name]) {
+			continue
+		}
+		written = append(written, name)
+		if err := write(fsys, name, *s.files[name], s.original[name]); err != nil {
+			for i := len(written) - 1; i >= 0; i-- {
+				name := written[i]
+				_ = write(fsys, name, s.original[name], *s.files[name])
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// write replaces the previous content of the named file with f. It removes
+// symbolic links before replacing them so that it does not write to the
+// target of the link, and only changes the permissions of files with the same
+// content if fsys implements ChmodFS.
+func write(fsys WriteFS, name string, f, prev fsFile) error {
+	if prev.exists && (!f.exists || f.symlink || prev.symlink) {
+		if err := fsys.Remove(name); err != nil {
+			return err
+		}
+	}
+	if prev.dir && f.exists {
+		if err := removeDir(fsys, name); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case f.exists && prev.exists && !f.symlink && !prev.symlink && bytes.Equal(f.data, prev.data):
+		if cfs, ok := fsys.(ChmodFS); ok {
+			return cfs.Chmod(name, f.perm)
+		}
+		return fsys.WriteFile(name, f.data, f.perm)
+	case f.exists && f.symlink:
+		sfs, ok := fsys.(SymlinkFS)
+		if !ok {
+			return fmt.Errorf("%s: file system does not support symbolic links", name)
+		}
+		return sfs.Symlink(string(f.data), name)
+	case f.exists:
+		if err := fsys.WriteFile(name, f.data, f.perm); err != nil {
+			return err
+		}
+		if tfs, ok := fsys.(ChtimesFS); ok && !f.modTime.IsZero() {
+			return tfs.Chtimes(name, f.modTime, f.modTime)
+		}
+	}
+	return nil
+}
+
+// removeDir removes the named directory and any empty directories in it.
+// File systems without directories may not find the directory, so it is
+// not an error if it does not exist.
+func removeDir(fsys WriteFS, name string) error {
+	var dirs []string
+	err := fs.WalkDir(fsys, name, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return fmt.Errorf("%s: %w", name, &Conflict{"file replaces a directory that is not empty"})
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := fsys.Remove(dirs[i]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fsFile) equal(other fsFile) bool {
+	if !f.exists || !other.exists {
+		return f.exists == other.exists
+	}
+	return f.perm == other.perm && f.symlink == other.symlink && bytes.Equal(f.data, other.data)
+}
+
+const symlinkMode os.FileMode = 0120000
+
+// fileModePerm converts a Git file mode to file permissions. It returns an
+// error for modes other than regular files.
+func fileModePerm(mode os.FileMode) (fs.FileMode, error) {
+	switch mode {
+	case 0100644:
+		return 0644, nil
+	case 0100755:
+		return 0755, nil
+	}
+	return 0, fmt.Errorf("unsupported file mode: %o", mode)
+}
@@ -0,0 +1,1032 @@
+package gitdiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestApplyFS(t *testing.T) {
+	original := func() fstest.MapFS {
+		return fstest.MapFS{
+			"mod.txt":     {Data: []byte("a\nb\nc\n"), Mode: 0644},
+			"del.txt":     {Data: []byte("gone\n"), Mode: 0644},
+			"old.txt":     {Data: []byte("move\nme\n"), Mode: 0644},
+			"run.sh":      {Data: []byte("#!/bin/sh\n"), Mode: 0644},
+			"dir/src.txt": {Data: []byte("copy\n"), Mode: 0644},
+		}
+	}
+	result := func() fstest.MapFS {
+		return fstest.MapFS{
+			"mod.txt":             {Data: []byte("a\nB\nc\n"), Mode: 0644},
+			"new.txt":             {Data: []byte("move\nme\ntoo\n"), Mode: 0644},
+			"run.sh":              {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+			"dir/src.txt":         {Data: []byte("copy\n"), Mode: 0644},
+			"dir/dst.txt":         {Data: []byte("copy\n"), Mode: 0644},
+			"dir/sub/created.txt": {Data: []byte("hello\n"), Mode: 0644},
+		}
+	}
+
+	tests := map[string]struct {
+		Files   fstest.MapFS
+		Options []ApplierOption
+		FailOn  string
+		Output  fstest.MapFS
+		Err     interface{}
+	}{
+		"apply": {
+			Files:  original(),
+			Output: result(),
+		},
+		"reverse": {
+			Files:   result(),
+			Options: []ApplierOption{WithReverse()},
+			Output:  original(),
+		},
+		"reject": {
+			Files: func() fstest.MapFS {
+				files := original()
+				files["old.txt"] = &fstest.MapFile{Data: []byte("moved\nme\n"), Mode: 0644}
+				return files
+			}(),
+			Options: []ApplierOption{WithReject()},
+			Output: func() fstest.MapFS {
+				files := result()
+				files["new.txt"] = &fstest.MapFile{Data: []byte("moved\nme\n"), Mode: 0644}
+				files["new.txt.rej"] = &fstest.MapFile{
+					Data: []byte("diff a/new.txt b/new.txt\t(rejected hunks)\n@@ -1,2 +1,3 @@\n move\n me\n+too\n"),
+					Mode: 0644,
+				}
+				return files
+			}(),
+		},
+		"errorRejectDelete": {
+			Files: func() fstest.MapFS {
+				files := original()
+				files["del.txt"] = &fstest.MapFile{Data: []byte("stay\n"), Mode: 0644}
+				return files
+			}(),
+			Options: []ApplierOption{WithReject()},
+			Err:     &Conflict{},
+		},
+		"errorConflict": {
+			Files: func() fstest.MapFS {
+				files := original()
+				files["old.txt"] = &fstest.MapFile{Data: []byte("moved\nme\n"), Mode: 0644}
+				return files
+			}(),
+			Err: &Conflict{},
+		},
+		"errorCreateExists": {
+			Files: func() fstest.MapFS {
+				files := original()
+				files["dir/sub/created.txt"] = &fstest.MapFile{Data: []byte("hello\n"), Mode: 0644}
+				return files
+			}(),
+			Err: &Conflict{},
+		},
+		"errorRenameExists": {
+			Files: func() fstest.MapFS {
+				files := original()
+				files["new.txt"] = &fstest.MapFile{Data: []byte("existing\n"), Mode: 0644}
+				return files
+			}(),
+			Err: &Conflict{},
+		},
+		"errorDeleteMissing": {
+			Files: func() fstest.MapFS {
+				files := original()
+				delete(files, "del.txt")
+				return files
+			}(),
+			Err: &Conflict{},
+		},
+		"errorDeleteLeavesContent": {
+			Files: func() fstest.MapFS {
+				files := original()
+				files["del.txt"] = &fstest.MapFile{Data: []byte("gone\nextra\n"), Mode: 0644}
+				return files
+			}(),
+			Err: &Conflict{},
+		},
+		"errorWriteRestoresFiles": {
+			Files:  original(),
+			FailOn: "new.txt",
+			Err:    errWriteFailed,
+		},
+	}
+
+	patch, err := os.Open(filepath.Join("testdata", "apply_fs.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer patch.Close()
+
+	out, err := Parse(patch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+	files := collectFiles(out)
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fsys := &memFS{MapFS: test.Files, failOn: test.FailOn}
+			before := copyMapFS(test.Files)
+
+			err := ApplyFS(fsys, files, test.Options...)
+			if test.Err != nil {
+				assertError(t, test.Err, err, "applying patch")
+				assertMapFS(t, before, fsys.MapFS)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error applying patch: %v", err)
+			}
+			assertMapFS(t, test.Output, fsys.MapFS)
+		})
+	}
+}
+
+func TestApplyFSWorkers(t *testing.T) {
+	patch, err := os.Open(filepath.Join("testdata", "apply_fs.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer patch.Close()
+
+	files, _, err := ParseAll(patch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	original := fstest.MapFS{
+		"mod.txt":     {Data: []byte("a\nb\nc\n"), Mode: 0644},
+		"del.txt":     {Data: []byte("gone\n"), Mode: 0644},
+		"old.txt":     {Data: []byte("move\nme\n"), Mode: 0644},
+		"run.sh":      {Data: []byte("#!/bin/sh\n"), Mode: 0644},
+		"dir/src.txt": {Data: []byte("copy\n"), Mode: 0644},
+	}
+
+	tests := map[string]struct {
+		Files   fstest.MapFS
+		Options []ApplierOption
+	}{
+		"apply": {
+			Files: original,
+		},
+		"reject": {
+			Files: func() fstest.MapFS {
+				files := copyMapFS(original)
+				files["old.txt"] = &fstest.MapFile{Data: []byte("moved\nme\n"), Mode: 0644}
+				return files
+			}(),
+			Options: []ApplierOption{WithReject()},
+		},
+		"errorConflicts": {
+			Files: func() fstest.MapFS {
+				files := copyMapFS(original)
+				files["mod.txt"] = &fstest.MapFile{Data: []byte("x\ny\nz\n"), Mode: 0644}
+				files["old.txt"] = &fstest.MapFile{Data: []byte("moved\nme\n"), Mode: 0644}
+				delete(files, "del.txt")
+				return files
+			}(),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expectedFS := &memFS{MapFS: copyMapFS(test.Files)}
+			expectedErr := ApplyFS(expectedFS, files, test.Options...)
+
+			for _, workers := range []int{2, 8} {
+				fsys := &memFS{MapFS: copyMapFS(test.Files)}
+				err := ApplyFS(fsys, files, append(test.Options, WithWorkers(workers))...)
+				if fmt.Sprint(expectedErr) != fmt.Sprint(err) {
+					t.Fatalf("%d workers: incorrect error\nexpected: %v\n  actual: %v", workers, expectedErr, err)
+				}
+				assertMapFS(t, expectedFS.MapFS, fsys.MapFS)
+			}
+		})
+	}
+}
+
+func TestApplyToMapWorkers(t *testing.T) {
+	var files []*File
+	m := make(map[string][]byte)
+	expected := make(map[string][]byte)
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("file%d.txt", i)
+		m[name] = []byte("old\n")
+		expected[name] = []byte("new\n")
+		files = append(files, &File{
+			OldName: name,
+			NewName: name,
+			TextFragments: []*TextFragment{{
+				OldPosition:  1,
+				OldLines:     1,
+				NewPosition:  1,
+				NewLines:     1,
+				LinesAdded:   1,
+				LinesDeleted: 1,
+				Lines:        []Line{{OpDelete, "old\n"}, {OpAdd, "new\n"}},
+			}},
+		})
+	}
+
+	// rename a file and then change it again, which must apply in order
+	files = append(files, &File{OldName: "file3.txt", NewName: "moved.txt", IsRename: true}, &File{
+		OldName: "moved.txt",
+		NewName: "moved.txt",
+		TextFragments: []*TextFragment{{
+			OldPosition:  1,
+			OldLines:     1,
+			NewPosition:  1,
+			NewLines:     1,
+			LinesAdded:   1,
+			LinesDeleted: 1,
+			Lines:        []Line{{OpDelete, "new\n"}, {OpAdd, "moved\n"}},
+		}},
+	})
+	delete(expected, "file3.txt")
+	expected["moved.txt"] = []byte("moved\n")
+
+	result, err := ApplyToMap(m, files, WithWorkers(4))
+	if err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("incorrect result\nexpected: %q\n  actual: %q", expected, result)
+	}
+
+	// with conflicts in many files, the error is for the first file
+	m["file5.txt"], m["file12.txt"] = []byte("other\n"), []byte("other\n")
+	for i := 0; i < 10; i++ {
+		if _, err := ApplyToMap(m, files, WithWorkers(4)); err == nil || !strings.HasPrefix(err.Error(), "file5.txt: ") {
+			t.Fatalf("expected error for file5.txt, but got %v", err)
+		}
+	}
+}
+
+func TestApplyGroups(t *testing.T) {
+	files := []*File{
+		{OldName: "a", NewName: "a"},
+		{OldName: "b", NewName: "c", IsRename: true},
+		{OldName: "d", NewName: "d"},
+		{OldName: "c", NewName: "c"},
+		{NewName: "e", IsNew: true},
+		{OldName: "a", NewName: "f", IsCopy: true},
+		{OldName: "e", IsDelete: true},
+	}
+
+	expected := [][]int{{0, 5}, {1, 3}, {2}, {4, 6}}
+	if groups := applyGroups(files); !reflect.DeepEqual(expected, groups) {
+		t.Errorf("incorrect groups\nexpected: %v\n  actual: %v", expected, groups)
+	}
+}
+
+func TestApplyCheck(t *testing.T) {
+	original := fstest.MapFS{
+		"mod.txt":     {Data: []byte("a\nb\nc\n"), Mode: 0644},
+		"del.txt":     {Data: []byte("gone\n"), Mode: 0644},
+		"old.txt":     {Data: []byte("move\nme\n"), Mode: 0644},
+		"run.sh":      {Data: []byte("#!/bin/sh\n"), Mode: 0644},
+		"dir/src.txt": {Data: []byte("copy\n"), Mode: 0644},
+	}
+	conflicts := copyMapFS(original)
+	conflicts["mod.txt"] = &fstest.MapFile{Data: []byte("a\nx\nc\n"), Mode: 0644}
+	delete(conflicts, "del.txt")
+
+	tests := map[string]struct {
+		Files   fstest.MapFS
+		Options []ApplierOption
+		Errs    map[int]int
+	}{
+		"clean": {
+			Files: original,
+		},
+		"conflicts": {
+			Files: conflicts,
+			Errs:  map[int]int{0: 0, 3: 1},
+		},
+		"conflictsIgnoreReject": {
+			Files:   conflicts,
+			Options: []ApplierOption{WithReject()},
+			Errs:    map[int]int{0: 0, 3: 1},
+		},
+	}
+
+	patch, err := os.Open(filepath.Join("testdata", "apply_fs.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer patch.Close()
+
+	out, err := Parse(patch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+	files := collectFiles(out)
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			before := copyMapFS(test.Files)
+
+			results, err := ApplyCheck(test.Files, files, test.Options...)
+			if len(test.Errs) > 0 {
+				assertError(t, &Conflict{}, err, "checking patch")
+			} else if err != nil {
+				t.Fatalf("unexpected error checking patch: %v", err)
+			}
+			assertMapFS(t, before, test.Files)
+
+			if len(results) != len(files) {
+				t.Fatalf("incorrect number of results: expected %d, actual %d", len(files), len(results))
+			}
+			for i, res := range results {
+				if res.File != files[i] {
+					t.Errorf("result %d: incorrect file: %+v", i, res.File)
+				}
+
+				rejects, ok := test.Errs[i]
+				if !ok {
+					if res.Err != nil {
+						t.Errorf("result %d: unexpected error: %v", i, res.Err)
+					}
+					continue
+				}
+				if res.Err == nil {
+					t.Errorf("result %d: expected error, but got nil", i)
+				}
+				if len(res.Rejects) != rejects {
+					t.Errorf("result %d: incorrect number of rejects: expected %d, actual %d", i, rejects, len(res.Rejects))
+				}
+				for _, rej := range res.Rejects {
+					assertError(t, &Conflict{}, rej.Err, "checking fragment")
+					if rej.Fragment != files[i].TextFragments[0] {
+						t.Errorf("result %d: incorrect rejected fragment: %+v", i, rej.Fragment)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestApplyToMap(t *testing.T) {
+	original := map[string][]byte{
+		"mod.txt":     []byte("a\nb\nc\n"),
+		"del.txt":     []byte("gone\n"),
+		"old.txt":     []byte("move\nme\n"),
+		"run.sh":      []byte("#!/bin/sh\n"),
+		"dir/src.txt": []byte("copy\n"),
+	}
+	result := map[string][]byte{
+		"mod.txt":             []byte("a\nB\nc\n"),
+		"new.txt":             []byte("move\nme\ntoo\n"),
+		"run.sh":              []byte("#!/bin/sh\n"),
+		"dir/src.txt":         []byte("copy\n"),
+		"dir/dst.txt":         []byte("copy\n"),
+		"dir/sub/created.txt": []byte("hello\n"),
+	}
+
+	tests := map[string]struct {
+		Files   map[string][]byte
+		Options []ApplierOption
+		Output  map[string][]byte
+		Err     interface{}
+	}{
+		"apply": {
+			Files:  original,
+			Output: result,
+		},
+		"reverse": {
+			Files:   result,
+			Options: []ApplierOption{WithReverse()},
+			Output:  original,
+		},
+		"errorConflict": {
+			Files: map[string][]byte{
+				"mod.txt":     []byte("a\nx\nc\n"),
+				"del.txt":     []byte("gone\n"),
+				"old.txt":     []byte("move\nme\n"),
+				"run.sh":      []byte("#!/bin/sh\n"),
+				"dir/src.txt": []byte("copy\n"),
+			},
+			Err: &Conflict{},
+		},
+		"errorMissing": {
+			Files: map[string][]byte{},
+			Err:   &Conflict{},
+		},
+	}
+
+	patch, err := os.Open(filepath.Join("testdata", "apply_fs.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer patch.Close()
+
+	files, _, err := ParseAll(patch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			before := make(map[string][]byte, len(test.Files))
+			for name, data := range test.Files {
+				before[name] = data
+			}
+
+			out, err := ApplyToMap(test.Files, files, test.Options...)
+			if !reflect.DeepEqual(before, test.Files) {
+				t.Errorf("input files were modified")
+			}
+			if test.Err != nil {
+				assertError(t, test.Err, err, "applying patch")
+				if out != nil {
+					t.Errorf("expected nil result with error, but got %d files", len(out))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error applying patch: %v", err)
+			}
+			if !reflect.DeepEqual(test.Output, out) {
+				t.Errorf("incorrect files after apply\nexpected: %q\n  actual: %q", test.Output, out)
+			}
+		})
+	}
+}
+
+func TestApplyFSDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"mod.txt":     "a\nb\nc\n",
+		"del.txt":     "gone\n",
+		"old.txt":     "move\nme\n",
+		"run.sh":      "#!/bin/sh\n",
+		"dir/src.txt": "copy\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	patch, err := os.Open(filepath.Join("testdata", "apply_fs.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer patch.Close()
+
+	out, err := Parse(patch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	if err := ApplyFS(DirFS(dir), collectFiles(out)); err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"mod.txt":             "a\nB\nc\n",
+		"new.txt":             "move\nme\ntoo\n",
+		"dir/dst.txt":         "copy\n",
+		"dir/sub/created.txt": "hello\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("failed to read %s: %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("incorrect content for %s\nexpected: %q\n  actual: %q", name, content, data)
+		}
+	}
+	for _, name := range []string{"del.txt", "old.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected %s to be removed, but got %v", name, err)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, "run.sh")); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected run.sh to be executable, but got %v, %v", info.Mode(), err)
+	}
+}
+
+func TestApplyFSSymlink(t *testing.T) {
+	dir := t.TempDir()
+	for name, target := range map[string]string{
+		"old-link":  "target.txt",
+		"gone-link": "target.txt",
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Skipf("symbolic links are not supported: %v", err)
+		}
+	}
+	for name, content := range map[string]string{
+		"target.txt": "hi\n",
+		"typechange": "plain\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	patch, err := os.Open(filepath.Join("testdata", "apply_fs_symlink.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer patch.Close()
+
+	out, err := Parse(patch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+	files := collectFiles(out)
+
+	fsys := &memFS{MapFS: fstest.MapFS{
+		"old-link": {Data: []byte("target.txt"), Mode: fs.ModeSymlink | 0777},
+	}}
+	if err := ApplyFS(fsys, files[2:3]); err == nil || !strings.Contains(err.Error(), "does not support symbolic links") {
+		t.Fatalf("expected unsupported symbolic link error, but got %v", err)
+	}
+	if data := string(fsys.MapFS["old-link"].Data); data != "target.txt" {
+		t.Fatalf("expected old-link to be unchanged, but got %q", data)
+	}
+
+	if err := ApplyFS(DirFS(dir), files); err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+
+	for name, target := range map[string]string{
+		"new-link":   "dir/target.txt",
+		"old-link":   "other.txt",
+		"typechange": "target.txt",
+	} {
+		actual, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("failed to read link %s: %v", name, err)
+			continue
+		}
+		if actual != target {
+			t.Errorf("incorrect target for %s\nexpected: %q\n  actual: %q", name, target, actual)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "gone-link")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected gone-link to be removed, but got %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "target.txt")); err != nil || string(data) != "hi\n" {
+		t.Errorf("expected target.txt to be unchanged, but got %q, %v", data, err)
+	}
+}
+
+func TestApplyFSModeChange(t *testing.T) {
+	out, err := Parse(strings.NewReader("diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n"))
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+	files := collectFiles(out)
+
+	fsys := &chmodFS{memFS: &memFS{MapFS: fstest.MapFS{
+		"run.sh": {Data: []byte("#!/bin/sh\n"), Mode: 0644},
+	}, failOn: "run.sh"}}
+	if err := ApplyFS(fsys, files); err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+	assertMapFS(t, fstest.MapFS{
+		"run.sh": {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+	}, fsys.MapFS)
+	if !reflect.DeepEqual(fsys.chmods, []string{"run.sh"}) {
+		t.Errorf("expected chmod of run.sh, but got %v", fsys.chmods)
+	}
+
+	if err := ApplyFS(fsys, files, WithReverse()); err != nil {
+		t.Fatalf("unexpected error applying patch in reverse: %v", err)
+	}
+	assertMapFS(t, fstest.MapFS{
+		"run.sh": {Data: []byte("#!/bin/sh\n"), Mode: 0644},
+	}, fsys.MapFS)
+}
+
+func TestApplyFSTimestamps(t *testing.T) {
+	const patch = "--- file.txt\t2026-10-14 10:00:00.000000000 -0700\n" +
+		"+++ file.txt\t2026-10-14 10:05:30.250000000 -0700\n" +
+		"@@ -1 +1 @@\n" +
+		"-one\n" +
+		"+two\n"
+
+	oldTime := time.Date(2026, 10, 14, 17, 0, 0, 0, time.UTC)
+	newTime := time.Date(2026, 10, 14, 17, 5, 30, 250000000, time.UTC)
+
+	files, _, err := ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	newFS := func(data string, modTime time.Time) *chtimesFS {
+		return &chtimesFS{memFS: &memFS{MapFS: fstest.MapFS{
+			"file.txt": {Data: []byte(data), Mode: 0644, ModTime: modTime},
+		}}}
+	}
+
+	t.Run("matchingTime", func(t *testing.T) {
+		fsys := newFS("one\n", oldTime.Add(500*time.Millisecond))
+		if err := ApplyFS(fsys, files, WithTimestamps()); err != nil {
+			t.Fatalf("unexpected error applying patch: %v", err)
+		}
+		assertMapFS(t, fstest.MapFS{
+			"file.txt": {Data: []byte("two\n"), Mode: 0644, ModTime: newTime},
+		}, fsys.MapFS)
+	})
+
+	t.Run("reverse", func(t *testing.T) {
+		fsys := newFS("two\n", newTime)
+		if err := ApplyFS(fsys, files, WithTimestamps(), WithReverse()); err != nil {
+			t.Fatalf("unexpected error applying patch in reverse: %v", err)
+		}
+		if f := fsys.MapFS["file.txt"]; string(f.Data) != "one\n" || !f.ModTime.Equal(oldTime) {
+			t.Errorf("incorrect file after reverse apply: %q at %v", f.Data, f.ModTime)
+		}
+	})
+
+	t.Run("mismatchedTime", func(t *testing.T) {
+		fsys := newFS("one\n", oldTime.Add(time.Second))
+		err := ApplyFS(fsys, files, WithTimestamps())
+		assertError(t, &Conflict{"modification time does not match patch"}, err, "applying patch")
+		if string(fsys.MapFS["file.txt"].Data) != "one\n" {
+			t.Errorf("file was modified after error: %q", fsys.MapFS["file.txt"].Data)
+		}
+
+		results, err := ApplyCheck(fsys, files, WithTimestamps())
+		assertError(t, &Conflict{"modification time does not match patch"}, err, "checking patch")
+		if len(results) != 1 || results[0].Err == nil {
+			t.Errorf("expected the file to not apply, but got %+v", results)
+		}
+	})
+
+	t.Run("withoutOption", func(t *testing.T) {
+		fsys := newFS("one\n", oldTime.Add(time.Hour))
+		if err := ApplyFS(fsys, files); err != nil {
+			t.Fatalf("unexpected error applying patch: %v", err)
+		}
+		assertMapFS(t, fstest.MapFS{
+			"file.txt": {Data: []byte("two\n"), Mode: 0644},
+		}, fsys.MapFS)
+	})
+}
+
+func TestApplyFSInvalidName(t *testing.T) {
+	files := []*File{{
+		NewName: "../escape.txt",
+		IsNew:   true,
+		TextFragments: []*TextFragment{{
+			NewPosition: 1,
+			NewLines:    1,
+			LinesAdded:  1,
+			Lines:       []Line{{OpAdd, "escape\n"}},
+		}},
+	}}
+
+	fsys := &memFS{MapFS: fstest.MapFS{}}
+	if err := ApplyFS(fsys, files); err == nil || !strings.Contains(err.Error(), "invalid file name") {
+		t.Fatalf("expected invalid file name error, but got %v", err)
+	}
+	if len(fsys.MapFS) > 0 {
+		t.Fatalf("expected no files to be written, but got %d", len(fsys.MapFS))
+	}
+}
+
+func TestApplyFSBeyondSymlink(t *testing.T) {
+	newFile := func(name string) *File {
+		return &File{
+			NewName: name,
+			IsNew:   true,
+			TextFragments: []*TextFragment{{
+				NewPosition: 1,
+				NewLines:    1,
+				LinesAdded:  1,
+				Lines:       []Line{{OpAdd, "escape\n"}},
+			}},
+		}
+	}
+
+	t.Run("existingLink", func(t *testing.T) {
+		root, outside := t.TempDir(), t.TempDir()
+		if err := os.Symlink(outside, filepath.Join(root, "out")); err != nil {
+			t.Skipf("symbolic links are not supported: %v", err)
+		}
+
+		files := []*File{newFile("out/escape.txt")}
+		if err := ApplyFS(DirFS(root), files); err == nil || !strings.Contains(err.Error(), "beyond a symbolic link") {
+			t.Fatalf("expected symbolic link error, but got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "escape.txt")); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected no file outside of root, but got %v", err)
+		}
+
+		if _, err := ApplyCheck(DirFS(root), files); err == nil || !strings.Contains(err.Error(), "beyond a symbolic link") {
+			t.Fatalf("expected symbolic link error from check, but got %v", err)
+		}
+
+		if err := ApplyFS(DirFS(root), files, WithUnsafePaths()); err != nil {
+			t.Fatalf("unexpected error applying with unsafe paths: %v", err)
+		}
+		if data, err := os.ReadFile(filepath.Join(outside, "escape.txt")); err != nil || string(data) != "escape\n" {
+			t.Fatalf("expected file outside of root, but got %q, %v", data, err)
+		}
+	})
+
+	t.Run("linkInPatch", func(t *testing.T) {
+		link := &File{
+			NewName: "out",
+			IsNew:   true,
+			NewMode: symlinkMode,
+			TextFragments: []*TextFragment{{
+				NewPosition: 1,
+				NewLines:    1,
+				LinesAdded:  1,
+				Lines:       []Line{{OpAdd, ".."}},
+			}},
+		}
+
+		for name, files := range map[string][]*File{
+			"before": {link, newFile("out/escape.txt")},
+			"after":  {newFile("out/escape.txt"), link},
+		} {
+			fsys := &symlinkFS{memFS: &memFS{MapFS: fstest.MapFS{}}}
+			if err := ApplyFS(fsys, files); err == nil || !strings.Contains(err.Error(), "beyond a symbolic link") {
+				t.Fatalf("%s: expected symbolic link error, but got %v", name, err)
+			}
+			if len(fsys.MapFS) > 0 {
+				t.Fatalf("%s: expected no files to be written, but got %d", name, len(fsys.MapFS))
+			}
+		}
+	})
+}
+
+var errWriteFailed = errors.New("write failed")
+
+// memFS is an in-memory WriteFS that can fail writes to a specific file.
+type memFS struct {
+	fstest.MapFS
+	failOn string
+}
+
+func (m *memFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if name == m.failOn {
+		return errWriteFailed
+	}
+	m.MapFS[name] = &fstest.MapFile{Data: append([]byte(nil), data...), Mode: perm}
+	return nil
+}
+
+func (m *memFS) Remove(name string) error {
+	if _, ok := m.MapFS[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.MapFS, name)
+	return nil
+}
+
+// chmodFS is a memFS that implements ChmodFS and records changed files.
+type chmodFS struct {
+	*memFS
+	chmods []string
+}
+
+func (c *chmodFS) Chmod(name string, perm fs.FileMode) error {
+	f, ok := c.MapFS[name]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	f.Mode = f.Mode&^fs.ModePerm | perm
+	c.chmods = append(c.chmods, name)
+	return nil
+}
+
+// chtimesFS is a memFS that implements ChtimesFS.
+type chtimesFS struct {
+	*memFS
+}
+
+func (c *chtimesFS) Chtimes(name string, atime, mtime time.Time) error {
+	f, ok := c.MapFS[name]
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+	f.ModTime = mtime.UTC()
+	return nil
+}
+
+// symlinkFS is a memFS that implements SymlinkFS.
+type symlinkFS struct {
+	*memFS
+}
+
+func (l *symlinkFS) Lstat(name string) (fs.FileInfo, error) {
+	return l.MapFS.Stat(name)
+}
+
+func (l *symlinkFS) ReadLink(name string) (string, error) {
+	f, ok := l.MapFS[name]
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	return string(f.Data), nil
+}
+
+func (l *symlinkFS) Symlink(target, name string) error {
+	l.MapFS[name] = &fstest.MapFile{Data: []byte(target), Mode: fs.ModeSymlink | 0777}
+	return nil
+}
+
+func copyMapFS(files fstest.MapFS) fstest.MapFS {
+	c := make(fstest.MapFS, len(files))
+	for name, f := range files {
+		fc := *f
+		c[name] = &fc
+	}
+	return c
+}
+
+func assertMapFS(t *testing.T, expected, actual fstest.MapFS) {
+	if !reflect.DeepEqual(expected, actual) {
+		var b strings.Builder
+		for name, f := range actual {
+			b.WriteString(name + ": " + f.Mode.String() + " " + string(f.Data))
+		}
+		t.Errorf("incorrect files after apply\nactual:\n%s", b.String())
+	}
+}
+
+func TestApplyFSTypeChange(t *testing.T) {
+	original := map[string]string{
+		"d/x": "x\n",
+		"d/y": "y\n",
+		"f":   "f\n",
+		"l":   "l\n",
+	}
+	result := map[string]string{
+		"d":    "dfile\n",
+		"f/in": "in\n",
+		"l":    "-> target",
+	}
+
+	patch, err := os.ReadFile(filepath.Join("testdata", "typechange.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+	files, _, err := ParseAll(bytes.NewReader(patch))
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	for name, opts := range map[string][]ApplierOption{
+		"default": nil,
+		"workers": {WithWorkers(4)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, original)
+
+			if err := ApplyFS(DirFS(dir), files, opts...); err != nil {
+				t.Fatalf("unexpected error applying patch: %v", err)
+			}
+			if actual := readTree(t, dir); !reflect.DeepEqual(result, actual) {
+				t.Fatalf("incorrect files after apply\nexpected: %q\n  actual: %q", result, actual)
+			}
+
+			if _, err := ApplyCheck(DirFS(dir), files, append(opts, WithReverse())...); err != nil {
+				t.Fatalf("unexpected error checking patch in reverse: %v", err)
+			}
+			if err := ApplyFS(DirFS(dir), files, append(opts, WithReverse())...); err != nil {
+				t.Fatalf("unexpected error applying patch in reverse: %v", err)
+			}
+			if actual := readTree(t, dir); !reflect.DeepEqual(original, actual) {
+				t.Fatalf("incorrect files after reverse apply\nexpected: %q\n  actual: %q", original, actual)
+			}
+		})
+	}
+
+	t.Run("errorDirectoryNotEmpty", func(t *testing.T) {
+		dir := t.TempDir()
+		before := map[string]string{"d/z": "z\n"}
+		for name, content := range original {
+			before[name] = content
+		}
+		writeTree(t, dir, before)
+
+		err := ApplyFS(DirFS(dir), files)
+		assertError(t, &Conflict{"file replaces a directory that is not empty"}, err, "applying patch")
+		if actual := readTree(t, dir); !reflect.DeepEqual(before, actual) {
+			t.Fatalf("files changed after failed apply\nexpected: %q\n  actual: %q", before, actual)
+		}
+
+		results, err := ApplyCheck(DirFS(dir), files)
+		assertError(t, &Conflict{}, err, "checking patch")
+		if results[0].Err == nil {
+			t.Errorf("expected error for %s, but it applies", results[0].File.NewName)
+		}
+	})
+
+	t.Run("errorLeadingFile", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTree(t, dir, original)
+
+		// without deleting "f", the file can't become a directory
+		var partial []*File
+		for _, f := range files {
+			if f.OldName != "f" || !f.IsDelete {
+				partial = append(partial, f)
+			}
+		}
+		err := ApplyFS(DirFS(dir), partial)
+		assertError(t, &Conflict{"leading directory is a file"}, err, "applying patch")
+		if actual := readTree(t, dir); !reflect.DeepEqual(original, actual) {
+			t.Fatalf("files changed after failed apply\nexpected: %q\n  actual: %q", original, actual)
+		}
+	})
+
+	t.Run("map", func(t *testing.T) {
+		files := files[:len(files)-2]
+		m := map[string][]byte{"d/x": []byte("x\n"), "d/y": []byte("y\n"), "f": []byte("f\n")}
+
+		out, err := ApplyToMap(m, files)
+		if err != nil {
+			t.Fatalf("unexpected error applying patch: %v", err)
+		}
+		expected := map[string][]byte{"d": []byte("dfile\n"), "f/in": []byte("in\n")}
+		if !reflect.DeepEqual(expected, out) {
+			t.Fatalf("incorrect files after apply\nexpected: %q\n  actual: %q", expected, out)
+		}
+
+		m["d/z"] = []byte("z\n")
+		_, err = ApplyToMap(m, files)
+		assertError(t, &Conflict{"leading directory is a file"}, err, "applying patch")
+	})
+}
+
+// writeTree creates the files in dir. Content starting with "-> " creates a
+// symbolic link to the rest of the content.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if strings.HasPrefix(content, "-> ") {
+			if err := os.Symlink(content[3:], path); err != nil {
+				t.Skipf("symbolic links are not supported: %v", err)
+			}
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+}
+
+// readTree returns the files in dir in the format used by writeTree.
+func readTree(t *testing.T, dir string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		var content string
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			content = "-> " + target
+		} else {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			content = string(data)
+		}
+		files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read files: %v", err)
+	}
+	return files
+}
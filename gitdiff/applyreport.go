@@ -0,0 +1,130 @@
+package gitdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FragmentStatus is the result of applying a text fragment.
+type FragmentStatus int
+
+const (
+	// FragmentApplied indicates that the fragment applied at the position
+	// from its header with all of its context
+	FragmentApplied FragmentStatus = iota + 1
+	// FragmentOffset indicates that the fragment applied with all of its
+	// context at a different position, found with WithMaxOffset
+	FragmentOffset
+	// FragmentFuzz indicates that the fragment applied after ignoring some of
+	// its context, with WithFuzz, possibly at a different position
+	FragmentFuzz
+	// FragmentFailed indicates that the fragment did not apply
+	FragmentFailed
+)
+
+func (s FragmentStatus) String() string {
+	switch s {
+	case FragmentApplied:
+		return "applied"
+	case FragmentOffset:
+		return "offset"
+	case FragmentFuzz:
+		return "fuzz"
+	case FragmentFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// FragmentReport describes the result of applying a text fragment, like the
+// messages printed by "git apply --verbose" and "patch".
+type FragmentReport struct {
+	// Number is the one-indexed position of the fragment in its file. For
+	// fragments applied with ApplyTextFragment, it is the number of fragments
+	// applied since the last call to Reset.
+	Number int
+
+	// Fragment is the fragment from the applied File. It is not reversed by
+	// WithReverse.
+	Fragment *TextFragment
+
+	Status FragmentStatus
+
+	// Match is where the fragment applied in the source. It is zero if the
+	// fragment failed.
+	Match FragmentMatch
+
+	// Err is the reason the fragment did not apply, or nil if it applied
+	Err error
+}
+
+// String returns a description of the result, like "Hunk #2 succeeded at 14
+// (offset 3 lines)."
+func (r FragmentReport) String() string {
+	var b strings.Builder
+	switch r.Status {
+	case FragmentApplied:
+		fmt.Fprintf(&b, "Hunk #%d applied cleanly.", r.Number)
+	case FragmentOffset, FragmentFuzz:
+		fmt.Fprintf(&b, "Hunk #%d succeeded at %d", r.Number, r.Match.Line)
+		if r.Match.Fuzz > 0 {
+			fmt.Fprintf(&b, " with fuzz %d", r.Match.Fuzz)
+		}
+		if r.Match.Offset != 0 {
+			fmt.Fprintf(&b, " (offset %d %s)", r.Match.Offset, plural(abs(r.Match.Offset), "line", "lines"))
+		}
+		b.WriteByte('.')
+	case FragmentFailed:
+		fmt.Fprintf(&b, "Rejected hunk #%d: %v", r.Number, r.Err)
+	}
+	return b.String()
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// WithReport configures an Applier to record the result of applying each text
+// fragment, like the -v flag of "git apply". Use Report to get the results.
+func WithReport() ApplierOption {
+	return func(a *Applier) {
+		a.report = true
+	}
+}
+
+// Report returns the results of applying text fragments since the last call
+// to Reset, in the order the fragments were applied. Report is always empty
+// unless the Applier was created with WithReport. If ApplyFile fails at a
+// fragment, the report includes the failed fragment but not the fragments
+// after it. With WithReject, it includes every fragment of the file.
+func (a *Applier) Report() []FragmentReport {
+	return a.reports
+}
+
+// reportFragment records the result of applying the fragment f with the
+// given number.
+func (a *Applier) reportFragment(f *TextFragment, n int, err error) {
+	if !a.report {
+		return
+	}
+
+	r := FragmentReport{Number: n, Fragment: f, Err: err}
+	switch {
+	case err != nil:
+		r.Status = FragmentFailed
+	default:
+		r.Match = a.matches[len(a.matches)-1]
+		switch {
+		case r.Match.Fuzz > 0:
+			r.Status = FragmentFuzz
+		case r.Match.Offset != 0:
+			r.Status = FragmentOffset
+		default:
+			r.Status = FragmentApplied
+		}
+	}
+	a.reports = append(a.reports, r)
+}
@@ -0,0 +1,162 @@
+package gitdiff
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestApplyReport(t *testing.T) {
+	type result struct {
+		Number int
+		Status FragmentStatus
+		Match  FragmentMatch
+	}
+
+	tests := map[string]struct {
+		applyTest
+		Results []result
+		Strings []string
+	}{
+		"exact": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text.src",
+					Patch: "file_text_modify.patch",
+					Out:   "file_text_modify.out",
+				},
+			},
+			Results: []result{
+				{1, FragmentApplied, FragmentMatch{Line: 1}},
+				{2, FragmentApplied, FragmentMatch{Line: 17}},
+				{3, FragmentApplied, FragmentMatch{Line: 53}},
+				{4, FragmentApplied, FragmentMatch{Line: 130}},
+				{5, FragmentApplied, FragmentMatch{Line: 161}},
+			},
+		},
+		"offset": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_offset.src",
+					Patch: "file_text_modify.patch",
+					Out:   "file_text_offset.out",
+				},
+				Options: []ApplierOption{WithMaxOffset(3)},
+			},
+			Results: []result{
+				{1, FragmentApplied, FragmentMatch{Line: 1}},
+				{2, FragmentOffset, FragmentMatch{Line: 20, Offset: 3}},
+				{3, FragmentOffset, FragmentMatch{Line: 56, Offset: 3}},
+				{4, FragmentOffset, FragmentMatch{Line: 133, Offset: 3}},
+				{5, FragmentOffset, FragmentMatch{Line: 164, Offset: 3}},
+			},
+			Strings: []string{
+				"Hunk #1 applied cleanly.",
+				"Hunk #2 succeeded at 20 (offset 3 lines).",
+				"Hunk #3 succeeded at 56 (offset 3 lines).",
+				"Hunk #4 succeeded at 133 (offset 3 lines).",
+				"Hunk #5 succeeded at 164 (offset 3 lines).",
+			},
+		},
+		"fuzz": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_fuzz.src",
+					Patch: "file_text_modify.patch",
+					Out:   "file_text_fuzz.out",
+				},
+				Options: []ApplierOption{WithFuzz(2)},
+			},
+			Results: []result{
+				{1, FragmentApplied, FragmentMatch{Line: 1}},
+				{2, FragmentFuzz, FragmentMatch{Line: 17, Fuzz: 1}},
+				{3, FragmentApplied, FragmentMatch{Line: 53}},
+				{4, FragmentFuzz, FragmentMatch{Line: 130, Fuzz: 2}},
+				{5, FragmentApplied, FragmentMatch{Line: 161}},
+			},
+			Strings: []string{
+				"Hunk #1 applied cleanly.",
+				"Hunk #2 succeeded at 17 with fuzz 1.",
+				"Hunk #3 applied cleanly.",
+				"Hunk #4 succeeded at 130 with fuzz 2.",
+				"Hunk #5 applied cleanly.",
+			},
+		},
+		"failed": {
+			applyTest: applyTest{
+				Files: applyFiles{
+					Src:   "file_text_offset.src",
+					Patch: "file_text_modify.patch",
+				},
+				Err: &Conflict{},
+			},
+			Results: []result{
+				{1, FragmentApplied, FragmentMatch{Line: 1}},
+				{2, FragmentFailed, FragmentMatch{}},
+			},
+		},
+		"reject": {
+			applyTest: applyTest{
+				Files:   getApplyFiles("file_text_reject"),
+				Options: []ApplierOption{WithReject()},
+			},
+			Results: []result{
+				{1, FragmentApplied, FragmentMatch{Line: 1}},
+				{2, FragmentFailed, FragmentMatch{}},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.Options = append(test.Options, WithReport())
+
+			var report []FragmentReport
+			test.run(t, func(w io.Writer, applier *Applier, file *File) error {
+				err := applier.ApplyFile(w, file)
+				report = applier.Report()
+				for _, r := range report {
+					if r.Fragment != file.TextFragments[r.Number-1] {
+						t.Errorf("hunk %d: report does not refer to the fragment from the file", r.Number)
+					}
+				}
+				return err
+			})
+
+			var results []result
+			for _, r := range report {
+				if (r.Status == FragmentFailed) != (r.Err != nil) {
+					t.Errorf("hunk %d: incorrect error for status %v: %v", r.Number, r.Status, r.Err)
+				}
+				results = append(results, result{r.Number, r.Status, r.Match})
+			}
+			if !reflect.DeepEqual(test.Results, results) {
+				t.Errorf("incorrect report\nexpected: %+v\n  actual: %+v", test.Results, results)
+			}
+
+			if test.Strings != nil {
+				var strs []string
+				for _, r := range report {
+					strs = append(strs, r.String())
+				}
+				if !reflect.DeepEqual(test.Strings, strs) {
+					t.Errorf("incorrect report messages\nexpected: %q\n  actual: %q", test.Strings, strs)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyReportDisabled(t *testing.T) {
+	applyTest{Files: applyFiles{
+		Src:   "file_text.src",
+		Patch: "file_text_modify.patch",
+		Out:   "file_text_modify.out",
+	}}.run(t, func(w io.Writer, applier *Applier, file *File) error {
+		err := applier.ApplyFile(w, file)
+		if report := applier.Report(); len(report) > 0 {
+			t.Errorf("expected empty report without WithReport, but got %d results", len(report))
+		}
+		return err
+	})
+}
@@ -0,0 +1,138 @@
+package gitdiff
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// Source provides the original content of files for ApplyTo, like the
+// objects in a storage bucket, the entries of an archive, or rows in a
+// database. Names are slash separated paths that satisfy fs.ValidPath.
+type Source interface {
+	// Open opens the named file for reading. If the file does not exist,
+	// Open returns an error that wraps fs.ErrNotExist.
+	Open(name string) (io.ReadCloser, error)
+}
+
+// Sink receives the result of ApplyTo. Names are slash separated paths that
+// satisfy fs.ValidPath.
+type Sink interface {
+	// Create creates or replaces the named file with the content written to
+	// the returned writer. The content is complete when Close returns nil.
+	Create(name string) (io.WriteCloser, error)
+
+	// Remove removes the named file.
+	Remove(name string) error
+}
+
+// ApplyTo applies the changes in files to the files in src and writes the
+// result to dst. It handles files in the same way as ApplyFS, including
+// renames, copies, and deletions, but only reads files from src and only
+// writes the created and modified files to dst, so src and dst may be the
+// same backend or different ones.
+//
+// Source and Sink do not record file modes, so like ApplyToMap, ApplyTo
+// treats all files as regular files and writes the content of files that
+// only change mode again. Patches that create symbolic links return an
+// error.
+//
+// ApplyTo computes the result of all files before writing to dst. If any file
+// does not apply, ApplyTo returns an error and does not modify dst. If writing
+// the result fails, ApplyTo attempts to restore the original content of all
+// files it wrote before returning the error.
+func ApplyTo(dst Sink, src Source, files []*File, opts ...ApplierOption) error {
+	return ApplyFS(&sourceFS{src: src, dst: dst}, files, opts...)
+}
+
+// sourceFS adapts a Source and a Sink to a WriteFS. It reads each file once
+// when ApplyFS checks if it exists and keeps the content for ReadFile.
+type sourceFS struct {
+	src  Source
+	dst  Sink
+	data map[string][]byte
+}
+
+func (s *sourceFS) Open(name string) (fs.File, error) {
+	data, err := s.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sourceFile{Reader: bytes.NewReader(data), info: sourceFileInfo{name: path.Base(name), size: int64(len(data))}}, nil
+}
+
+func (s *sourceFS) Stat(name string) (fs.FileInfo, error) {
+	data, err := s.read("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return sourceFileInfo{name: path.Base(name), size: int64(len(data))}, nil
+}
+
+func (s *sourceFS) ReadFile(name string) ([]byte, error) {
+	return s.read("open", name)
+}
+
+func (s *sourceFS) read(op, name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if data, ok := s.data[name]; ok {
+		return data, nil
+	}
+
+	rc, err := s.src.Open(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if s.data == nil {
+		s.data = make(map[string][]byte)
+	}
+	s.data[name] = data
+	return data, nil
+}
+
+func (s *sourceFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	w, err := s.dst.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *sourceFS) Remove(name string) error {
+	return s.dst.Remove(name)
+}
+
+type sourceFile struct {
+	*bytes.Reader
+	info sourceFileInfo
+}
+
+func (f *sourceFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *sourceFile) Close() error               { return nil }
+
+// sourceFileInfo describes a regular file from a Source.
+type sourceFileInfo struct {
+	name string
+	size int64
+}
+
+func (fi sourceFileInfo) Name() string       { return fi.name }
+func (fi sourceFileInfo) Size() int64        { return fi.size }
+func (fi sourceFileInfo) Mode() fs.FileMode  { return 0644 }
+func (fi sourceFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi sourceFileInfo) IsDir() bool        { return false }
+func (fi sourceFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,180 @@
+package gitdiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplyTo(t *testing.T) {
+	original := map[string]string{
+		"mod.txt":     "a\nb\nc\n",
+		"del.txt":     "gone\n",
+		"old.txt":     "move\nme\n",
+		"run.sh":      "#!/bin/sh\n",
+		"dir/src.txt": "copy\n",
+	}
+
+	tests := map[string]struct {
+		Files   map[string]string
+		Options []ApplierOption
+		FailOn  string
+		Output  map[string]string
+		Writes  []string
+		Err     interface{}
+	}{
+		"apply": {
+			Files: original,
+			Output: map[string]string{
+				"mod.txt":             "a\nB\nc\n",
+				"new.txt":             "move\nme\ntoo\n",
+				"run.sh":              "#!/bin/sh\n",
+				"dir/src.txt":         "copy\n",
+				"dir/dst.txt":         "copy\n",
+				"dir/sub/created.txt": "hello\n",
+			},
+			Writes: []string{"dir/dst.txt", "dir/sub/created.txt", "mod.txt", "new.txt", "run.sh"},
+		},
+		"errorConflict": {
+			Files: map[string]string{
+				"mod.txt":     "a\nx\nc\n",
+				"del.txt":     "gone\n",
+				"old.txt":     "move\nme\n",
+				"run.sh":      "#!/bin/sh\n",
+				"dir/src.txt": "copy\n",
+			},
+			Err: &Conflict{},
+		},
+		"errorMissing": {
+			Files: map[string]string{},
+			Err:   &Conflict{},
+		},
+		"errorWriteRestoresFiles": {
+			Files:  original,
+			FailOn: "new.txt",
+			Err:    errWriteFailed,
+		},
+	}
+
+	patch, err := os.Open(filepath.Join("testdata", "apply_fs.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer patch.Close()
+
+	files, _, err := ParseAll(patch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := &mapStore{files: make(map[string]string), failOn: test.FailOn}
+			for name, data := range test.Files {
+				store.files[name] = data
+			}
+
+			err := ApplyTo(store, store, files, test.Options...)
+			if test.Err != nil {
+				assertError(t, test.Err, err, "applying patch")
+				if !reflect.DeepEqual(test.Files, store.files) {
+					t.Errorf("files were modified after error\nexpected: %q\n  actual: %q", test.Files, store.files)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error applying patch: %v", err)
+			}
+			if !reflect.DeepEqual(test.Output, store.files) {
+				t.Errorf("incorrect files after apply\nexpected: %q\n  actual: %q", test.Output, store.files)
+			}
+			if !reflect.DeepEqual(test.Writes, store.writes) {
+				t.Errorf("incorrect files written\nexpected: %q\n  actual: %q", test.Writes, store.writes)
+			}
+			for name, n := range store.opens {
+				if n > 1 {
+					t.Errorf("file %s was opened %d times", name, n)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyToSymlink(t *testing.T) {
+	patch := `diff --git a/link b/link
+new file mode 120000
+index 0000000..1de5659
+--- /dev/null
++++ b/link
+@@ -0,0 +1 @@
++target
+\ No newline at end of file
+`
+	files, _, err := ParseAll(bytes.NewReader([]byte(patch)))
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	store := &mapStore{files: make(map[string]string)}
+	if err := ApplyTo(store, store, files); err == nil {
+		t.Fatalf("expected error applying symbolic link, but got nil")
+	}
+	if len(store.files) > 0 {
+		t.Errorf("expected no files after error, but got %q", store.files)
+	}
+}
+
+// mapStore is an in-memory Source and Sink that can fail writes to a specific
+// file. It records the number of times each file is opened and the names of
+// written files.
+type mapStore struct {
+	files  map[string]string
+	failOn string
+	opens  map[string]int
+	writes []string
+}
+
+func (m *mapStore) Open(name string) (io.ReadCloser, error) {
+	if m.opens == nil {
+		m.opens = make(map[string]int)
+	}
+	m.opens[name]++
+
+	data, ok := m.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader([]byte(data))), nil
+}
+
+func (m *mapStore) Create(name string) (io.WriteCloser, error) {
+	if name == m.failOn {
+		return nil, errWriteFailed
+	}
+	m.writes = append(m.writes, name)
+	return &mapStoreWriter{m: m, name: name}, nil
+}
+
+func (m *mapStore) Remove(name string) error {
+	if _, ok := m.files[name]; !ok {
+		return errors.New("file does not exist: " + name)
+	}
+	delete(m.files, name)
+	return nil
+}
+
+type mapStoreWriter struct {
+	bytes.Buffer
+	m    *mapStore
+	name string
+}
+
+func (w *mapStoreWriter) Close() error {
+	w.m.files[w.name] = w.String()
+	return nil
+}
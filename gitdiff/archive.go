@@ -0,0 +1,327 @@
+package gitdiff
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// ApplyTar applies the changes in files to the entries of the tar archive
+// read from src and writes the patched archive to dst. It handles files in
+// the same way as ApplyFS, including renames, copies, deletions, mode
+// changes, and symbolic links, with names relative to the root of the
+// archive. Options configure the application of fragments in the same way as
+// for Apply.
+//
+// The patched archive has the entries of src in the same order with the same
+// metadata, except that deleted entries are removed and changed entries have
+// new content and modes. New entries are added at the end of the archive
+// with the current time as their modification time. If any file does not
+// apply, ApplyTar returns an error without writing to dst.
+func ApplyTar(dst io.Writer, src io.Reader, files []*File, opts ...ApplierOption) error {
+	afs := newArchiveFS()
+
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("gitdiff: tar: %w", err)
+		}
+
+		e := &archiveEntry{tarHeader: hdr, mode: hdr.FileInfo().Mode()}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			if e.data, err = io.ReadAll(tr); err != nil {
+				return fmt.Errorf("gitdiff: tar: %s: %w", hdr.Name, err)
+			}
+		case tar.TypeSymlink:
+			e.data = []byte(hdr.Linkname)
+		}
+		afs.add(hdr.Name, e)
+	}
+
+	if err := ApplyFS(afs, files, opts...); err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(dst)
+	for _, e := range afs.entries {
+		if err := writeTarEntry(tw, e); err != nil {
+			return fmt.Errorf("gitdiff: tar: %s: %w", e.name, err)
+		}
+	}
+	return tw.Close()
+}
+
+func writeTarEntry(tw *tar.Writer, e *archiveEntry) error {
+	if e.deleted {
+		return nil
+	}
+
+	hdr := e.tarHeader
+	if e.changed {
+		if hdr == nil {
+			hdr = &tar.Header{Name: e.name, ModTime: e.modTime}
+		} else {
+			copied := *hdr
+			hdr = &copied
+		}
+
+		hdr.Mode = hdr.Mode&^int64(fs.ModePerm) | int64(e.mode.Perm())
+		if e.mode&fs.ModeSymlink != 0 {
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, string(e.data), 0
+		} else {
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeReg, "", int64(len(e.data))
+		}
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag == tar.TypeReg {
+		if _, err := tw.Write(e.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyZip applies the changes in files to the entries of the zip archive in
+// the first size bytes of src and writes the patched archive to dst. It
+// handles files in the same way as ApplyTar. Unchanged entries keep their
+// headers and compression methods and new entries use the Deflate method.
+func ApplyZip(dst io.Writer, src io.ReaderAt, size int64, files []*File, opts ...ApplierOption) error {
+	zr, err := zip.NewReader(src, size)
+	if err != nil {
+		return fmt.Errorf("gitdiff: zip: %w", err)
+	}
+
+	afs := newArchiveFS()
+	for _, f := range zr.File {
+		e := &archiveEntry{zipFile: f, mode: f.Mode()}
+		if e.mode.IsRegular() || e.mode&fs.ModeSymlink != 0 {
+			if e.data, err = readZipFile(f); err != nil {
+				return fmt.Errorf("gitdiff: zip: %s: %w", f.Name, err)
+			}
+		}
+		afs.add(f.Name, e)
+	}
+
+	if err := ApplyFS(afs, files, opts...); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(dst)
+	for _, e := range afs.entries {
+		if err := writeZipEntry(zw, e); err != nil {
+			return fmt.Errorf("gitdiff: zip: %s: %w", e.name, err)
+		}
+	}
+	return zw.Close()
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func writeZipEntry(zw *zip.Writer, e *archiveEntry) error {
+	if e.deleted {
+		return nil
+	}
+
+	var hdr zip.FileHeader
+	if e.zipFile != nil {
+		hdr = e.zipFile.FileHeader
+		hdr.CRC32, hdr.CompressedSize, hdr.CompressedSize64 = 0, 0, 0
+		hdr.UncompressedSize, hdr.UncompressedSize64 = 0, 0
+	} else {
+		hdr = zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: e.modTime}
+	}
+	if e.changed {
+		hdr.SetMode(e.mode)
+	}
+
+	w, err := zw.CreateHeader(&hdr)
+	if err != nil {
+		return err
+	}
+	if e.changed {
+		_, err = w.Write(e.data)
+		return err
+	}
+
+	rc, err := e.zipFile.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+// archiveEntry is an entry of an archive. The data of a symbolic link is its
+// target.
+type archiveEntry struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+
+	deleted bool
+	changed bool
+
+	tarHeader *tar.Header
+	zipFile   *zip.File
+}
+
+// archiveFS is an in-memory file system with the entries of an archive. It
+// implements SymlinkFS and ChmodFS so that ApplyFS can change all of the
+// properties of entries that archives record.
+type archiveFS struct {
+	entries []*archiveEntry
+	byName  map[string]*archiveEntry
+	now     time.Time
+}
+
+func newArchiveFS() *archiveFS {
+	return &archiveFS{byName: make(map[string]*archiveEntry), now: time.Now()}
+}
+
+// add adds an entry with the given name from the archive. Like extracting the
+// archive, later entries replace earlier entries with the same name.
+func (a *archiveFS) add(name string, e *archiveEntry) {
+	e.name = cleanArchiveName(name)
+	a.entries = append(a.entries, e)
+	a.byName[e.name] = e
+}
+
+// cleanArchiveName converts the name of an archive entry to a path relative
+// to the root of the archive, without leading "./" or trailing slashes.
+func cleanArchiveName(name string) string {
+	name = path.Clean("/" + strings.TrimSuffix(name, "/"))
+	return strings.TrimPrefix(name, "/")
+}
+
+func (a *archiveFS) lookup(op, name string) (*archiveEntry, error) {
+	if e, ok := a.byName[name]; ok && !e.deleted {
+		return e, nil
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	e, err := a.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &archiveFile{Reader: bytes.NewReader(e.data), e: e}, nil
+}
+
+func (a *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	e, err := a.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return archiveFileInfo{e}, nil
+}
+
+func (a *archiveFS) Lstat(name string) (fs.FileInfo, error) {
+	e, err := a.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return archiveFileInfo{e}, nil
+}
+
+func (a *archiveFS) ReadFile(name string) ([]byte, error) {
+	e, err := a.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return e.data, nil
+}
+
+func (a *archiveFS) ReadLink(name string) (string, error) {
+	e, err := a.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if e.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return string(e.data), nil
+}
+
+func (a *archiveFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	a.set(name, data, perm)
+	return nil
+}
+
+func (a *archiveFS) Symlink(target, name string) error {
+	a.set(name, []byte(target), fs.ModeSymlink|0777)
+	return nil
+}
+
+func (a *archiveFS) Chmod(name string, perm fs.FileMode) error {
+	e, err := a.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+	e.mode, e.changed = e.mode&^fs.ModePerm|perm, true
+	return nil
+}
+
+func (a *archiveFS) Remove(name string) error {
+	e, err := a.lookup("remove", name)
+	if err != nil {
+		return err
+	}
+	e.deleted = true
+	return nil
+}
+
+// set replaces the content and mode of the named entry, adding a new entry
+// if it does not exist. A removed entry keeps its position in the archive.
+func (a *archiveFS) set(name string, data []byte, mode fs.FileMode) {
+	e, ok := a.byName[name]
+	if !ok {
+		e = &archiveEntry{name: name, modTime: a.now}
+		a.entries = append(a.entries, e)
+		a.byName[name] = e
+	}
+	e.data, e.mode = data, mode
+	e.deleted, e.changed = false, true
+}
+
+type archiveFile struct {
+	*bytes.Reader
+	e *archiveEntry
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return archiveFileInfo{f.e}, nil }
+func (f *archiveFile) Close() error               { return nil }
+
+type archiveFileInfo struct {
+	e *archiveEntry
+}
+
+func (fi archiveFileInfo) Name() string       { return path.Base(fi.e.name) }
+func (fi archiveFileInfo) Size() int64        { return int64(len(fi.e.data)) }
+func (fi archiveFileInfo) Mode() fs.FileMode  { return fi.e.mode }
+func (fi archiveFileInfo) ModTime() time.Time { return fi.e.modTime }
+func (fi archiveFileInfo) IsDir() bool        { return fi.e.mode.IsDir() }
+func (fi archiveFileInfo) Sys() interface{}   { return nil }
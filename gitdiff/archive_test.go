@@ -0,0 +1,209 @@
+package gitdiff
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+// archiveTestEntry is an entry of an archive in the tests. The data of a
+// symbolic link is its target.
+type archiveTestEntry struct {
+	Name string
+	Mode fs.FileMode
+	Data string
+}
+
+var (
+	archiveOriginal = []archiveTestEntry{
+		{Name: "./dir/", Mode: fs.ModeDir | 0755},
+		{Name: "./dir/src.txt", Mode: 0644, Data: "copy\n"},
+		{Name: "./mod.txt", Mode: 0644, Data: "a\nb\nc\n"},
+		{Name: "./del.txt", Mode: 0644, Data: "gone\n"},
+		{Name: "./old.txt", Mode: 0644, Data: "move\nme\n"},
+		{Name: "./run.sh", Mode: 0644, Data: "#!/bin/sh\n"},
+	}
+	archiveResult = []archiveTestEntry{
+		{Name: "./dir/", Mode: fs.ModeDir | 0755},
+		{Name: "./dir/src.txt", Mode: 0644, Data: "copy\n"},
+		{Name: "./mod.txt", Mode: 0644, Data: "a\nB\nc\n"},
+		{Name: "./run.sh", Mode: 0755, Data: "#!/bin/sh\n"},
+		{Name: "dir/dst.txt", Mode: 0644, Data: "copy\n"},
+		{Name: "dir/sub/created.txt", Mode: 0644, Data: "hello\n"},
+		{Name: "new.txt", Mode: 0644, Data: "move\nme\ntoo\n"},
+	}
+)
+
+func TestApplyTar(t *testing.T) {
+	files := parseTestPatch(t, "apply_fs.patch")
+
+	t.Run("apply", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := ApplyTar(&out, bytes.NewReader(newTestTar(t, archiveOriginal)), files); err != nil {
+			t.Fatalf("unexpected error applying patch: %v", err)
+		}
+		assertArchiveEntries(t, archiveResult, readTestTar(t, out.Bytes()))
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		entries := append(archiveOriginal[:len(archiveOriginal):len(archiveOriginal)],
+			archiveTestEntry{Name: "link", Mode: fs.ModeSymlink | 0777, Data: "mod.txt"},
+		)
+		patch := "diff --git a/link b/link\nindex 2d5b3e8..1de5659 120000\n--- a/link\n+++ b/link\n" +
+			"@@ -1 +1 @@\n-mod.txt\n\\ No newline at end of file\n+run.sh\n\\ No newline at end of file\n"
+		files, _, err := ParseAll(bytes.NewReader([]byte(patch)))
+		if err != nil {
+			t.Fatalf("failed to parse patch: %v", err)
+		}
+
+		var out bytes.Buffer
+		if err := ApplyTar(&out, bytes.NewReader(newTestTar(t, entries)), files); err != nil {
+			t.Fatalf("unexpected error applying patch: %v", err)
+		}
+
+		expected := append(archiveOriginal[:len(archiveOriginal):len(archiveOriginal)],
+			archiveTestEntry{Name: "link", Mode: fs.ModeSymlink | 0777, Data: "run.sh"},
+		)
+		assertArchiveEntries(t, expected, readTestTar(t, out.Bytes()))
+	})
+
+	t.Run("errorConflict", func(t *testing.T) {
+		entries := append([]archiveTestEntry(nil), archiveOriginal...)
+		entries[2].Data = "a\nx\nc\n"
+
+		var out bytes.Buffer
+		err := ApplyTar(&out, bytes.NewReader(newTestTar(t, entries)), files)
+		assertError(t, &Conflict{}, err, "applying patch")
+		if out.Len() > 0 {
+			t.Errorf("expected no output after error, but got %d bytes", out.Len())
+		}
+	})
+
+	t.Run("errorInvalidArchive", func(t *testing.T) {
+		var out bytes.Buffer
+		err := ApplyTar(&out, bytes.NewReader([]byte("not a tar archive")), files)
+		assertError(t, "gitdiff: tar", err, "applying patch")
+	})
+}
+
+func TestApplyZip(t *testing.T) {
+	files := parseTestPatch(t, "apply_fs.patch")
+
+	t.Run("apply", func(t *testing.T) {
+		data := newTestZip(t, archiveOriginal)
+
+		var out bytes.Buffer
+		if err := ApplyZip(&out, bytes.NewReader(data), int64(len(data)), files); err != nil {
+			t.Fatalf("unexpected error applying patch: %v", err)
+		}
+		assertArchiveEntries(t, archiveResult, readTestZip(t, out.Bytes()))
+	})
+
+	t.Run("errorConflict", func(t *testing.T) {
+		entries := append([]archiveTestEntry(nil), archiveOriginal...)
+		entries[2].Data = "a\nx\nc\n"
+		data := newTestZip(t, entries)
+
+		var out bytes.Buffer
+		err := ApplyZip(&out, bytes.NewReader(data), int64(len(data)), files)
+		assertError(t, &Conflict{}, err, "applying patch")
+		if out.Len() > 0 {
+			t.Errorf("expected no output after error, but got %d bytes", out.Len())
+		}
+	})
+}
+
+func newTestTar(t *testing.T, entries []archiveTestEntry) []byte {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.Name, Mode: int64(e.Mode.Perm()), Typeflag: tar.TypeReg, Size: int64(len(e.Data))}
+		switch {
+		case e.Mode.IsDir():
+			hdr.Typeflag, hdr.Size = tar.TypeDir, 0
+		case e.Mode&fs.ModeSymlink != 0:
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.Data, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.Data)); err != nil {
+				t.Fatalf("failed to write tar entry: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	return b.Bytes()
+}
+
+func readTestTar(t *testing.T, data []byte) []archiveTestEntry {
+	var entries []archiveTestEntry
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("failed to read tar: %v", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("failed to read tar entry: %v", err)
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			content = []byte(hdr.Linkname)
+		}
+		entries = append(entries, archiveTestEntry{Name: hdr.Name, Mode: hdr.FileInfo().Mode(), Data: string(content)})
+	}
+}
+
+func newTestZip(t *testing.T, entries []archiveTestEntry) []byte {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.Name, Method: zip.Deflate}
+		hdr.SetMode(e.Mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatalf("failed to write zip header: %v", err)
+		}
+		if _, err := w.Write([]byte(e.Data)); err != nil {
+			t.Fatalf("failed to write zip entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	return b.Bytes()
+}
+
+func readTestZip(t *testing.T, data []byte) []archiveTestEntry {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to read zip: %v", err)
+	}
+
+	var entries []archiveTestEntry
+	for _, f := range zr.File {
+		content, err := readZipFile(f)
+		if err != nil {
+			t.Fatalf("failed to read zip entry: %v", err)
+		}
+		entries = append(entries, archiveTestEntry{Name: f.Name, Mode: f.Mode(), Data: string(content)})
+	}
+	return entries
+}
+
+func assertArchiveEntries(t *testing.T, expected, actual []archiveTestEntry) {
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("incorrect archive entries\nexpected: %+v\n  actual: %+v", expected, actual)
+	}
+}
@@ -2,6 +2,8 @@ package gitdiff
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -28,3 +30,32 @@ func assertError(t *testing.T, expected interface{}, actual error, action string
 		t.Fatalf("unsupported expected error type: %T", exp)
 	}
 }
+
+// readTestPatch returns the content of the file with the given name in
+// testdata and fails the test if it can not be read.
+func readTestPatch(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+	return string(data)
+}
+
+// parseTestPatch parses the patch in the file with the given name in
+// testdata. See parseTestFiles.
+func parseTestPatch(t *testing.T, name string, opts ...ParseOption) []*File {
+	return parseTestFiles(t, readTestPatch(t, name), opts...)
+}
+
+// parseTestFiles parses patch with opts and fails the test if the patch is
+// invalid or does not contain any files.
+func parseTestFiles(t *testing.T, patch string, opts ...ParseOption) []*File {
+	files, _, err := ParseAll(strings.NewReader(patch), opts...)
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("expected at least one file in patch, but got none")
+	}
+	return files
+}
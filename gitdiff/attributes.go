@@ -0,0 +1,262 @@
+package gitdiff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// AttrState is the state of an attribute for a path, like the output of "git
+// check-attr".
+type AttrState int
+
+const (
+	// AttrUnspecified indicates that no pattern sets or unsets the attribute
+	AttrUnspecified AttrState = iota
+	// AttrSet indicates that the attribute is set, like "text"
+	AttrSet
+	// AttrUnset indicates that the attribute is unset, like "-text"
+	AttrUnset
+	// AttrValue indicates that the attribute has a value, like "eol=crlf"
+	AttrValue
+)
+
+func (s AttrState) String() string {
+	switch s {
+	case AttrUnspecified:
+		return "unspecified"
+	case AttrSet:
+		return "set"
+	case AttrUnset:
+		return "unset"
+	case AttrValue:
+		return "value"
+	}
+	return "unknown"
+}
+
+// Attributes are the attributes of paths from .gitattributes files. Use
+// ParseAttributes to create Attributes.
+type Attributes struct {
+	rules  []attrRule
+	macros map[string][]attrAssign
+}
+
+type attrRule struct {
+	dir     string
+	pattern string
+	assigns []attrAssign
+}
+
+type attrAssign struct {
+	name  string
+	state AttrState
+	value string
+}
+
+// ParseAttributes parses attributes in the format of a .gitattributes file at
+// the root of a repository. Each line contains a pattern followed by
+// attributes that are set ("name"), unset ("-name"), unspecified ("!name"),
+// or have a value ("name=value"). Like Git, the "binary" macro unsets the
+// "diff", "merge", and "text" attributes and lines starting with "[attr]"
+// define other macros. Negative patterns are ignored.
+func ParseAttributes(r io.Reader) (*Attributes, error) {
+	a := &Attributes{
+		macros: map[string][]attrAssign{
+			"binary": {{"diff", AttrUnset, ""}, {"merge", AttrUnset, ""}, {"text", AttrUnset, ""}},
+		},
+	}
+	if err := a.Add("", r); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Add parses the attributes of a .gitattributes file in the directory dir of
+// a repository, which is empty for the root. Patterns in the file only match
+// paths in the directory and override all patterns added before them, so
+// callers must add files in parent directories before the files in their
+// subdirectories.
+func (a *Attributes) Add(dir string, r io.Reader) error {
+	dir = strings.Trim(dir, "/")
+
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var pattern string
+		if line[0] == '"' {
+			name, n, err := parseQuotedName(line)
+			if err != nil {
+				return fmt.Errorf("gitdiff: attributes: line %d: %w", lineno, err)
+			}
+			pattern, line = name, line[n:]
+		} else {
+			i := strings.IndexAny(line, " \t")
+			if i < 0 {
+				i = len(line)
+			}
+			pattern, line = line[:i], line[i:]
+		}
+
+		var assigns []attrAssign
+		for _, field := range strings.Fields(line) {
+			assign, err := parseAttrAssign(field)
+			if err != nil {
+				return fmt.Errorf("gitdiff: attributes: line %d: %w", lineno, err)
+			}
+			assigns = append(assigns, assign)
+		}
+
+		switch {
+		case strings.HasPrefix(pattern, "[attr]"):
+			name := strings.TrimPrefix(pattern, "[attr]")
+			if !isAttrName(name) {
+				return fmt.Errorf("gitdiff: attributes: line %d: invalid macro name %q", lineno, name)
+			}
+			a.macros[name] = assigns
+		case strings.HasPrefix(pattern, "!"):
+			// like Git, negative patterns are not allowed
+		default:
+			a.rules = append(a.rules, attrRule{dir: dir, pattern: pattern, assigns: assigns})
+		}
+	}
+	return s.Err()
+}
+
+func parseAttrAssign(field string) (attrAssign, error) {
+	assign := attrAssign{name: field, state: AttrSet}
+	switch {
+	case strings.HasPrefix(field, "-"):
+		assign.name, assign.state = field[1:], AttrUnset
+	case strings.HasPrefix(field, "!"):
+		assign.name, assign.state = field[1:], AttrUnspecified
+	default:
+		if i := strings.IndexByte(field, '='); i >= 0 {
+			assign.name, assign.value, assign.state = field[:i], field[i+1:], AttrValue
+		}
+	}
+	if !isAttrName(assign.name) {
+		return attrAssign{}, fmt.Errorf("invalid attribute name %q", assign.name)
+	}
+	return assign, nil
+}
+
+// isAttrName returns true if name is a valid attribute name: a non-empty
+// sequence of letters, digits, dashes, dots, and underscores that does not
+// start with a dash.
+func isAttrName(name string) bool {
+	if name == "" || name[0] == '-' {
+		return false
+	}
+	for _, c := range []byte(name) {
+		if !isAlphaNumeric(c) && c != '-' && c != '.' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the state of the attribute attr for the file with the given
+// name, which is relative to the root of the repository, and its value if the
+// state is AttrValue. Like Git, later patterns override earlier patterns and
+// attributes set by a macro apply when the macro is set.
+func (a *Attributes) Get(name, attr string) (AttrState, string) {
+	if a == nil {
+		return AttrUnspecified, ""
+	}
+
+	state, value := AttrUnspecified, ""
+	var apply func(assigns []attrAssign, depth int)
+	apply = func(assigns []attrAssign, depth int) {
+		for _, assign := range assigns {
+			if assign.name == attr {
+				state, value = assign.state, assign.value
+			}
+			if macro, ok := a.macros[assign.name]; ok && assign.state == AttrSet && depth < len(a.macros) {
+				apply(macro, depth+1)
+			}
+		}
+	}
+
+	for _, rule := range a.rules {
+		rel := name
+		if rule.dir != "" {
+			if !strings.HasPrefix(name, rule.dir+"/") {
+				continue
+			}
+			rel = name[len(rule.dir)+1:]
+		}
+		if matchAttrPath(rule.pattern, rel) {
+			apply(rule.assigns, 0)
+		}
+	}
+	return state, value
+}
+
+// WithAttributes configures an Applier to use the attributes of each file
+// from attrs when applying it with ApplyFile, like Git uses .gitattributes
+// files when applying patches to a working tree:
+//
+//   - Files with the "eol" attribute set to "lf" or "crlf" are applied like
+//     LineEndingPreserve, but added lines use the line ending from the
+//     attribute. Files with the "text" attribute are applied like
+//     LineEndingPreserve. Lines that the patch does not change keep their
+//     line endings.
+//   - Files with the "text" attribute unset, including files with the
+//     "binary" macro, are not text, so they are applied exactly, ignoring
+//     WithLineEndings and without checking for whitespace errors.
+//   - The "whitespace" attribute sets the whitespace errors for the file,
+//     overriding WithWhitespaceRules. If it is set, the Applier checks for
+//     all errors except WhitespaceTabInIndent, and if it is unset, the
+//     Applier does not check for errors. Otherwise, its value uses the syntax
+//     of ParseWhitespaceRules.
+//
+// Names are the new names of files, or the old names of deleted files.
+func WithAttributes(attrs *Attributes) ApplierOption {
+	return func(a *Applier) {
+		a.attrs = attrs
+	}
+}
+
+// allWhitespaceRules are the rules Git uses when the "whitespace" attribute is
+// set.
+const allWhitespaceRules = WhitespaceBlankAtEOL | WhitespaceSpaceBeforeTab | WhitespaceIndentWithNonTab | WhitespaceBlankAtEOF
+
+// applyAttributes configures the Applier for the file with the given name.
+func (a *Applier) applyAttributes(name string) error {
+	a.wsRule = a.whitespaceRule(name)
+	if a.attrs == nil {
+		return nil
+	}
+
+	switch state, value := a.attrs.Get(name, "whitespace"); state {
+	case AttrSet:
+		a.wsRule = allWhitespaceRules | a.wsRule&WhitespaceTabWidthMask
+	case AttrUnset:
+		a.wsRule &= WhitespaceTabWidthMask
+	case AttrValue:
+		rule, err := ParseWhitespaceRules(value)
+		if err != nil {
+			return err
+		}
+		a.wsRule = rule
+	}
+
+	a.textAttr, _ = a.attrs.Get(name, "text")
+	if a.textAttr == AttrUnset {
+		a.wsRule = 0
+		return nil
+	}
+	switch _, eol := a.attrs.Get(name, "eol"); eol {
+	case "lf":
+		a.attrEOL = "\n"
+	case "crlf":
+		a.attrEOL = "\r\n"
+	}
+	return nil
+}
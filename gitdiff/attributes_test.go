@@ -0,0 +1,198 @@
+package gitdiff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestAttributesGet compares attributes to the output of "git check-attr" for
+// the same .gitattributes files.
+func TestAttributesGet(t *testing.T) {
+	const (
+		root = `# comment
+*.txt text eol=crlf
+*.png binary
+docs/*.md whitespace=-blank-at-eol
+docs/**/*.md -whitespace
+/root.c diff=cpp
+"quoted name.txt" -text
+[attr]mine text -diff
+*.mine mine
+!*.neg text
+build/ -text
+*.bin !text
+`
+		sub = "*.txt -text eol\n"
+	)
+
+	attrs, err := ParseAttributes(strings.NewReader(root))
+	if err != nil {
+		t.Fatalf("unexpected error parsing attributes: %v", err)
+	}
+	if err := attrs.Add("sub", strings.NewReader(sub)); err != nil {
+		t.Fatalf("unexpected error parsing attributes: %v", err)
+	}
+
+	type attr struct {
+		State AttrState
+		Value string
+	}
+
+	tests := map[string]map[string]attr{
+		"a.txt":           {"text": {AttrSet, ""}, "eol": {AttrValue, "crlf"}},
+		"dir/a.txt":       {"text": {AttrSet, ""}, "eol": {AttrValue, "crlf"}},
+		"b.png":           {"text": {AttrUnset, ""}, "diff": {AttrUnset, ""}},
+		"docs/a.md":       {"whitespace": {AttrUnset, ""}},
+		"docs/x/y/z.md":   {"whitespace": {AttrUnset, ""}},
+		"root.c":          {"diff": {AttrValue, "cpp"}},
+		"dir/root.c":      {},
+		"quoted name.txt": {"text": {AttrUnset, ""}, "eol": {AttrValue, "crlf"}},
+		"f.mine":          {"text": {AttrSet, ""}, "diff": {AttrUnset, ""}},
+		"a.neg":           {},
+		"build/x.c":       {},
+		"x.bin":           {},
+		"sub/a.txt":       {"text": {AttrUnset, ""}, "eol": {AttrSet, ""}},
+		"sub/b.png":       {"text": {AttrUnset, ""}, "diff": {AttrUnset, ""}},
+	}
+
+	for name, expected := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, a := range []string{"text", "eol", "diff", "whitespace"} {
+				state, value := attrs.Get(name, a)
+				if (attr{state, value}) != expected[a] {
+					t.Errorf("incorrect %s attribute: expected %+v, actual %+v", a, expected[a], attr{state, value})
+				}
+			}
+		})
+	}
+}
+
+func TestParseAttributesError(t *testing.T) {
+	tests := map[string]struct {
+		Input string
+		Err   interface{}
+	}{
+		"invalidName":  {Input: "*.txt te$t\n", Err: "line 1: invalid attribute name"},
+		"invalidMacro": {Input: "\n[attr]-m text\n", Err: "line 2: invalid macro name"},
+		"invalidQuote": {Input: "\"a\\qb\" text\n", Err: "line 1: invalid escape sequence"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseAttributes(strings.NewReader(test.Input))
+			assertError(t, test.Err, err, "parsing attributes")
+		})
+	}
+}
+
+func TestApplyAttributes(t *testing.T) {
+	const attributes = `*.txt eol=crlf
+*.dat -text
+*.md whitespace=indent-with-non-tab
+*.sh -whitespace
+`
+
+	tests := map[string]struct {
+		Name    string
+		Src     string
+		Patch   string
+		Options []ApplierOption
+		Out     string
+		Errors  []WhitespaceRule
+		Err     interface{}
+	}{
+		"eolCRLF": {
+			Name:  "a.txt",
+			Src:   "one\r\ntwo\r\n",
+			Patch: "@@ -1,2 +1,3 @@\n one\n+one and a half\n two\n",
+			Out:   "one\r\none and a half\r\ntwo\r\n",
+		},
+		"eolCRLFEmpty": {
+			Name:  "a.txt",
+			Src:   "",
+			Patch: "@@ -0,0 +1 @@\n+one\n",
+			Out:   "one\r\n",
+		},
+		"notText": {
+			Name:    "a.dat",
+			Src:     "one\r\ntwo\r\n",
+			Patch:   "@@ -1,2 +1,3 @@\n one\n+one and a half\n two\n",
+			Options: []ApplierOption{WithLineEndings(LineEndingPreserve)},
+			Err:     &Conflict{},
+		},
+		"notTextWhitespace": {
+			Name:    "a.dat",
+			Src:     "one\n",
+			Patch:   "@@ -1 +1,2 @@\n one\n+two \n",
+			Options: []ApplierOption{WithWhitespace(WhitespaceFix)},
+			Out:     "one\ntwo \n",
+		},
+		"whitespaceValue": {
+			Name:    "a.md",
+			Src:     "one\n",
+			Patch:   "@@ -1 +1,3 @@\n one\n+two \n+        three\n",
+			Options: []ApplierOption{WithWhitespace(WhitespaceFix)},
+			Out:     "one\ntwo\n\tthree\n",
+			Errors:  []WhitespaceRule{WhitespaceBlankAtEOL, WhitespaceIndentWithNonTab},
+		},
+		"whitespaceUnset": {
+			Name:    "a.sh",
+			Src:     "one\n",
+			Patch:   "@@ -1 +1,2 @@\n one\n+two \n",
+			Options: []ApplierOption{WithWhitespace(WhitespaceWarn), WithWhitespaceRules(DefaultWhitespaceRules, "*.sh")},
+			Out:     "one\ntwo \n",
+		},
+		"unspecified": {
+			Name:  "a.go",
+			Src:   "one\r\ntwo\r\n",
+			Patch: "@@ -1,2 +1,3 @@\n one\n+one and a half\n two\n",
+			Err:   &Conflict{},
+		},
+	}
+
+	attrs, err := ParseAttributes(strings.NewReader(attributes))
+	if err != nil {
+		t.Fatalf("unexpected error parsing attributes: %v", err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			patch := "diff --git a/" + test.Name + " b/" + test.Name + "\n" +
+				"--- a/" + test.Name + "\n" +
+				"+++ b/" + test.Name + "\n" + test.Patch
+
+			files, _, err := ParseAll(strings.NewReader(patch))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			var out bytes.Buffer
+			applier := NewApplier(strings.NewReader(test.Src), append([]ApplierOption{WithAttributes(attrs)}, test.Options...)...)
+			err = applier.ApplyFile(&out, files[0])
+			if test.Err != nil {
+				assertError(t, test.Err, err, "applying patch")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error applying patch: %v", err)
+			}
+			if out.String() != test.Out {
+				t.Errorf("incorrect output: expected %q, actual %q", test.Out, out.String())
+			}
+
+			var errs []WhitespaceRule
+			for _, wsErr := range applier.WhitespaceErrors() {
+				errs = append(errs, wsErr.Kind)
+			}
+			if len(errs) != len(test.Errors) {
+				t.Fatalf("incorrect whitespace errors: expected %v, actual %v", test.Errors, errs)
+			}
+			for i := range errs {
+				if errs[i] != test.Errors[i] {
+					t.Errorf("incorrect whitespace error %d: expected %v, actual %v", i, test.Errors[i], errs[i])
+				}
+			}
+		})
+	}
+}
@@ -29,7 +29,7 @@ func base85Decode(dst, src []byte) error {
 			v = 85*v + uint32(b)
 			n++
 		} else {
-			return fmt.Errorf("invalid base85 byte at index %d: 0x%X", i, src[i])
+			return atColumn(fmt.Errorf("invalid base85 byte at index %d: 0x%X", i, src[i]), i)
 		}
 		if n == 5 {
 			rem := len(dst) - ndst
@@ -50,3 +50,43 @@ func base85Decode(dst, src []byte) error {
 	}
 	return nil
 }
+
+// base85Encode encodes src in Base85, writing the result to dst. It uses the
+// alphabet defined by base85.c in the Git source tree. dst must be at least
+// base85Len(len(src)) bytes long. If src is not a multiple of four bytes, it
+// is padded with zero bytes before encoding.
+func base85Encode(dst, src []byte) {
+	var di, si int
+
+	encode := func(v uint32) {
+		for i := 4; i >= 0; i-- {
+			dst[di+i] = b85Alpha[v%85]
+			v /= 85
+		}
+		di += 5
+	}
+
+	for i := 0; i < len(src)/4; i++ {
+		v := uint32(src[si])<<24 | uint32(src[si+1])<<16 | uint32(src[si+2])<<8 | uint32(src[si+3])
+		encode(v)
+		si += 4
+	}
+
+	var v uint32
+	switch rem := len(src) - si; rem {
+	case 3:
+		v |= uint32(src[si+2]) << 8
+		fallthrough
+	case 2:
+		v |= uint32(src[si+1]) << 16
+		fallthrough
+	case 1:
+		v |= uint32(src[si]) << 24
+		encode(v)
+	}
+}
+
+// base85Len returns the length of n bytes of Base85 encoded data.
+func base85Len(n int) int {
+	return (n + 3) / 4 * 5
+}
@@ -1,6 +1,8 @@
 package gitdiff
 
 import (
+	"bytes"
+	"math/rand"
 	"testing"
 )
 
@@ -58,3 +60,56 @@ func TestBase85Decode(t *testing.T) {
 		})
 	}
 }
+
+func TestBase85Encode(t *testing.T) {
+	tests := map[string]struct {
+		Input  []byte
+		Output string
+	}{
+		"zeroBytes": {
+			Input:  []byte{},
+			Output: "",
+		},
+		"twoBytes": {
+			Input:  []byte{0xCA, 0xFE},
+			Output: "%KiWV",
+		},
+		"fourBytes": {
+			Input:  []byte{0x0, 0x0, 0xCA, 0xFE},
+			Output: "007GV",
+		},
+		"sixBytes": {
+			Input:  []byte{0x0, 0x0, 0xCA, 0xFE, 0xCA, 0xFE},
+			Output: "007GV%KiWV",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dst := make([]byte, base85Len(len(test.Input)))
+			base85Encode(dst, test.Input)
+			if string(dst) != test.Output {
+				t.Errorf("incorrect encoding: expected %q, actual %q", test.Output, dst)
+			}
+		})
+	}
+}
+
+func TestBase85Roundtrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 64; n++ {
+		in := make([]byte, n)
+		r.Read(in)
+
+		dst := make([]byte, base85Len(n))
+		out := make([]byte, n)
+
+		base85Encode(dst, in)
+		if err := base85Decode(out, dst); err != nil {
+			t.Fatalf("unexpected error decoding base85 data: %v", err)
+		}
+		if !bytes.Equal(in, out) {
+			t.Errorf("decoded data differed from input data:\n   input: %x\n  output: %x\nencoding: %s", in, out, dst)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package gitdiff
 
 import (
+	"bufio"
 	"bytes"
 	"compress/zlib"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -26,7 +28,7 @@ func (p *parser) ParseBinaryFragments(f *File) (n int, err error) {
 		return 0, err
 	}
 	if forward == nil {
-		return 0, p.Errorf(0, "missing data for binary patch")
+		return 0, p.Errorf(0, KindBinaryData, "missing data for binary patch")
 	}
 	if err := p.ParseBinaryChunk(forward); err != nil {
 		return 0, err
@@ -50,11 +52,11 @@ func (p *parser) ParseBinaryFragments(f *File) (n int, err error) {
 }
 
 func (p *parser) ParseBinaryMarker() (isBinary bool, hasData bool, err error) {
-	switch p.Line(0) {
-	case "GIT binary patch\n":
+	line := p.Line(0)
+	switch {
+	case line == "GIT binary patch\n":
 		hasData = true
-	case "Binary files differ\n":
-	case "Files differ\n":
+	case isBinaryNoDataMarker(line):
 	default:
 		return false, false, nil
 	}
@@ -65,8 +67,37 @@ func (p *parser) ParseBinaryMarker() (isBinary bool, hasData bool, err error) {
 	return true, hasData, nil
 }
 
+func isBinaryNoDataMarker(line string) bool {
+	// git includes the file names in the marker, but older versions and other
+	// tools may omit them
+	if strings.HasSuffix(line, " differ\n") {
+		return strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "Files ")
+	}
+	return false
+}
+
 func (p *parser) ParseBinaryFragmentHeader() (*BinaryFragment, error) {
-	parts := strings.SplitN(strings.TrimSuffix(p.Line(0), "\n"), " ", 2)
+	frag, err := parseBinaryFragmentHeader(p.Line(0))
+	if err != nil {
+		return nil, p.Errorf(0, KindBinaryData, "binary patch: %w", err)
+	}
+	if frag == nil {
+		return nil, nil
+	}
+	if p.maxBinarySize > 0 && frag.Size > p.maxBinarySize {
+		return nil, p.limitErrorf(0, LimitBinarySize, p.maxBinarySize)
+	}
+
+	if err := p.Next(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return frag, nil
+}
+
+// parseBinaryFragmentHeader parses the "literal" or "delta" line that starts
+// a binary fragment. It returns nil if line is not a fragment header.
+func parseBinaryFragmentHeader(line string) (*BinaryFragment, error) {
+	parts := strings.SplitN(strings.TrimSuffix(line, "\n"), " ", 2)
 	if len(parts) < 2 {
 		return nil, nil
 	}
@@ -84,70 +115,39 @@ func (p *parser) ParseBinaryFragmentHeader() (*BinaryFragment, error) {
 	var err error
 	if frag.Size, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
 		nerr := err.(*strconv.NumError)
-		return nil, p.Errorf(0, "binary patch: invalid size: %v", nerr.Err)
-	}
-
-	if err := p.Next(); err != nil && err != io.EOF {
-		return nil, err
+		return nil, atColumn(fmt.Errorf("invalid size: %w", nerr.Err), len(parts[0])+1)
 	}
 	return frag, nil
 }
 
 func (p *parser) ParseBinaryChunk(frag *BinaryFragment) error {
-	// Binary fragments are encoded as a series of base85 encoded lines. Each
-	// line starts with a character in [A-Za-z] giving the number of bytes on
-	// the line, where A = 1 and z = 52, and ends with a newline character.
-	//
-	// The base85 encoding means each line is a multiple of 5 characters + 2
-	// additional characters for the length byte and the newline. The fragment
-	// ends with a blank line.
-	const (
-		shortestValidLine = "A00000\n"
-		maxBytesPerLine   = 52
-	)
-
 	var data bytes.Buffer
-	buf := make([]byte, maxBytesPerLine)
+	buf := make([]byte, maxBinaryLineBytes)
 	for {
 		line := p.Line(0)
 		if line == "\n" {
 			break
 		}
-		if len(line) < len(shortestValidLine) || (len(line)-2)%5 != 0 {
-			return p.Errorf(0, "binary patch: corrupt data line")
-		}
 
-		byteCount, seq := int(line[0]), line[1:len(line)-1]
-		switch {
-		case 'A' <= byteCount && byteCount <= 'Z':
-			byteCount = byteCount - 'A' + 1
-		case 'a' <= byteCount && byteCount <= 'z':
-			byteCount = byteCount - 'a' + 27
-		default:
-			return p.Errorf(0, "binary patch: invalid length byte")
+		n, err := decodeBinaryLine(buf, line)
+		if err != nil {
+			return p.Errorf(0, KindBinaryData, "binary patch: %w", err)
 		}
-
-		// base85 encodes every 4 bytes into 5 characters, with up to 3 bytes of end padding
-		maxByteCount := len(seq) / 5 * 4
-		if byteCount > maxByteCount || byteCount < maxByteCount-3 {
-			return p.Errorf(0, "binary patch: incorrect byte count")
+		data.Write(buf[:n])
+		if p.maxBinarySize > 0 && int64(data.Len()) > maxDeflateSize(p.maxBinarySize) {
+			return p.limitErrorf(0, LimitBinarySize, p.maxBinarySize)
 		}
 
-		if err := base85Decode(buf[:byteCount], []byte(seq)); err != nil {
-			return p.Errorf(0, "binary patch: %v", err)
-		}
-		data.Write(buf[:byteCount])
-
 		if err := p.Next(); err != nil {
 			if err == io.EOF {
-				return p.Errorf(0, "binary patch: unexpected EOF")
+				return p.Errorf(0, KindBinaryData, "binary patch: unexpected EOF")
 			}
 			return err
 		}
 	}
 
-	if err := inflateBinaryChunk(frag, &data); err != nil {
-		return p.Errorf(0, "binary patch: %v", err)
+	if err := inflateBinaryChunk(frag, &data, p.maxBinarySize); err != nil {
+		return p.Errorf(0, KindBinaryData, "binary patch: %w", err)
 	}
 
 	// consume the empty line that ended the fragment
@@ -157,13 +157,63 @@ func (p *parser) ParseBinaryChunk(frag *BinaryFragment) error {
 	return nil
 }
 
-func inflateBinaryChunk(frag *BinaryFragment, r io.Reader) error {
+// maxBinaryLineBytes is the maximum number of bytes on a line of a binary
+// fragment.
+const maxBinaryLineBytes = 52
+
+// decodeBinaryLine decodes a line of a binary fragment into dst, which must
+// have room for maxBinaryLineBytes, and returns the number of bytes.
+//
+// Binary fragments are encoded as a series of base85 encoded lines. Each line
+// starts with a character in [A-Za-z] giving the number of bytes on the line,
+// where A = 1 and z = 52, and ends with a newline character.
+//
+// The base85 encoding means each line is a multiple of 5 characters + 2
+// additional characters for the length byte and the newline. The fragment
+// ends with a blank line.
+func decodeBinaryLine(dst []byte, line string) (int, error) {
+	const shortestValidLine = "A00000\n"
+
+	if len(line) < len(shortestValidLine) || (len(line)-2)%5 != 0 {
+		return 0, errors.New("corrupt data line")
+	}
+
+	byteCount, seq := int(line[0]), line[1:len(line)-1]
+	switch {
+	case 'A' <= byteCount && byteCount <= 'Z':
+		byteCount = byteCount - 'A' + 1
+	case 'a' <= byteCount && byteCount <= 'z':
+		byteCount = byteCount - 'a' + 27
+	default:
+		return 0, atColumn(errors.New("invalid length byte"), 0)
+	}
+
+	// base85 encodes every 4 bytes into 5 characters, with up to 3 bytes of end padding
+	maxByteCount := len(seq) / 5 * 4
+	if byteCount > maxByteCount || byteCount < maxByteCount-3 {
+		return 0, atColumn(errors.New("incorrect byte count"), 0)
+	}
+
+	if err := base85Decode(dst[:byteCount], []byte(seq)); err != nil {
+		return 0, atColumn(err, 1)
+	}
+	return byteCount, nil
+}
+
+// inflateBinaryChunk decompresses the data of frag from r. If limit is
+// positive, it stops reading after more than limit bytes of decompressed data,
+// which can't match the size of the fragment.
+func inflateBinaryChunk(frag *BinaryFragment, r io.Reader, limit int64) error {
 	zr, err := zlib.NewReader(r)
 	if err != nil {
 		return err
 	}
 
-	data, err := ioutil.ReadAll(zr)
+	var lr io.Reader = zr
+	if limit > 0 {
+		lr = io.LimitReader(zr, limit+1)
+	}
+	data, err := ioutil.ReadAll(lr)
 	if err != nil {
 		return err
 	}
@@ -177,3 +227,143 @@ func inflateBinaryChunk(frag *BinaryFragment, r io.Reader) error {
 	frag.Data = data
 	return nil
 }
+
+// NewBinaryReader returns a reader for the data of a binary fragment that is
+// encoded in r in the format of a Git binary patch: lines of base85 data,
+// ending at an empty line or the end of r, that decompress with zlib to size
+// bytes. r starts with the line after the "literal" or "delta" header of the
+// fragment. The reader decodes the data as it is read, so it can copy large
+// binary files without keeping them in memory. It returns an error if the
+// data is invalid or does not decompress to exactly size bytes.
+func NewBinaryReader(r io.Reader, size int64) io.Reader {
+	sr, ok := r.(stringReader)
+	if !ok {
+		sr = bufio.NewReader(r)
+	}
+	return &binaryReader{lines: &binaryLineReader{r: sr}, size: size}
+}
+
+// OpenBinaryFragment returns the forward binary fragment of f and a reader
+// for its data. For files from WithLazyFragments with fragments that are not
+// loaded, the reader decodes the data from the patch as it is read, without
+// loading the fragments or keeping the decoded data in memory, and the
+// fragment has no Data. OpenBinaryFragment returns nil if f does not have a
+// binary fragment.
+func (f *File) OpenBinaryFragment() (*BinaryFragment, io.Reader, error) {
+	if f.lazy == nil {
+		if f.BinaryFragment == nil {
+			return nil, nil, nil
+		}
+		return f.BinaryFragment, bytes.NewReader(f.BinaryFragment.Data), nil
+	}
+
+	content := f.lazy.content
+	marker, content := cutLine(content)
+	if marker != "GIT binary patch\n" {
+		return nil, nil, nil
+	}
+	header, content := cutLine(content)
+	frag, err := parseBinaryFragmentHeader(header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("gitdiff: binary patch: %w", err)
+	}
+	if frag == nil {
+		return nil, nil, errors.New("gitdiff: missing data for binary patch")
+	}
+	if limit := f.lazy.config.maxBinarySize; limit > 0 && frag.Size > limit {
+		return nil, nil, &LimitExceededError{Limit: LimitBinarySize, Max: limit}
+	}
+	return frag, NewBinaryReader(&lineScanner{s: content}, frag.Size), nil
+}
+
+// cutLine splits s after the first newline.
+func cutLine(s string) (line, rest string) {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i+1], s[i+1:]
+	}
+	return s, ""
+}
+
+// binaryLineReader reads the compressed data of a binary fragment from its
+// base85 encoded lines.
+type binaryLineReader struct {
+	r    stringReader
+	buf  [maxBinaryLineBytes]byte
+	data []byte
+	done bool
+}
+
+func (lr *binaryLineReader) Read(p []byte) (int, error) {
+	for len(lr.data) == 0 {
+		if lr.done {
+			return 0, io.EOF
+		}
+		line, err := lr.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if line == "" || line == "\n" {
+			lr.done = true
+			continue
+		}
+		if err == io.EOF {
+			return 0, errors.New("gitdiff: binary patch: unexpected EOF")
+		}
+
+		n, err := decodeBinaryLine(lr.buf[:], line)
+		if err != nil {
+			return 0, fmt.Errorf("gitdiff: binary patch: %w", err)
+		}
+		lr.data = lr.buf[:n]
+	}
+
+	n := copy(p, lr.data)
+	lr.data = lr.data[n:]
+	return n, nil
+}
+
+// binaryReader decompresses the data of a binary fragment and checks its
+// size.
+type binaryReader struct {
+	lines *binaryLineReader
+	zr    io.ReadCloser
+	size  int64
+	n     int64
+	err   error
+}
+
+func (br *binaryReader) Read(p []byte) (int, error) {
+	if br.err != nil {
+		return 0, br.err
+	}
+	if br.zr == nil {
+		if br.zr, br.err = zlib.NewReader(br.lines); br.err != nil {
+			br.err = fmt.Errorf("gitdiff: binary patch: %w", br.err)
+			return 0, br.err
+		}
+	}
+
+	// read at most one byte past the size to detect data that is too long
+	if remaining := br.size - br.n + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := br.zr.Read(p)
+	br.n += int64(n)
+
+	switch {
+	case br.n > br.size:
+		n -= int(br.n - br.size)
+		err = fmt.Errorf("gitdiff: binary patch: %d byte fragment inflates to more bytes", br.size)
+	case err == io.EOF:
+		if err = br.zr.Close(); err == nil {
+			err = io.EOF
+		}
+		if br.n != br.size {
+			err = fmt.Errorf("gitdiff: binary patch: %d byte fragment inflated to %d", br.size, br.n)
+		}
+	case err != nil:
+		err = fmt.Errorf("gitdiff: binary patch: %w", err)
+	}
+	br.err = err
+	return n, err
+}
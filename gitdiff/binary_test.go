@@ -1,8 +1,11 @@
 package gitdiff
 
 import (
+	"bytes"
 	"encoding/binary"
 	"io"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -25,6 +28,16 @@ func TestParseBinaryMarker(t *testing.T) {
 			IsBinary: true,
 			HasData:  false,
 		},
+		"binaryFileNoPatchPaths": {
+			Input:    "Binary files a/foo.bin and b/foo.bin differ\n",
+			IsBinary: true,
+			HasData:  false,
+		},
+		"fileNoPatch": {
+			Input:    "Files differ\n",
+			IsBinary: true,
+			HasData:  false,
+		},
 		"textFile": {
 			Input:    "@@ -10,14 +22,31 @@\n",
 			IsBinary: false,
@@ -312,3 +325,139 @@ func fib(n int, ord binary.ByteOrder) []byte {
 	}
 	return buf
 }
+
+func TestNewBinaryReader(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Size   int64
+		Output []byte
+		Err    string
+	}{
+		"multiline": {
+			Input: "zcmZQzU|?i`U?w2V48*KJ%mKu_Kr9NxN<eH5#F0Qe0f=7$l~*z_FeL$%-)3N7vt?l5\n" +
+				"zl3-vE2xVZ9%4J~CI>f->s?WfX|B-=Vs{#X~svra7Ekg#T|4s}nH;WnAZ)|1Y*`&cB\n" +
+				"s(sh?X(Uz6L^!Ou&aF*u`J!eibJifSrv0z>$Q%Hd(^HIJ<Y?5`S0gT5UE&u=k\n\n",
+			Size:   160,
+			Output: fib(40, binary.BigEndian),
+		},
+		"noTrailingEmptyLine": {
+			Input:  "TcmZQzU|?i`U?w2V48*Je09XJG\n",
+			Size:   20,
+			Output: fib(5, binary.BigEndian),
+		},
+		"stopsAtEmptyLine": {
+			Input:  "TcmZQzU|?i`U?w2V48*Je09XJG\n\nliteral 0\n",
+			Size:   20,
+			Output: fib(5, binary.BigEndian),
+		},
+		"corruptLine": {
+			Input: "A00\n\n",
+			Err:   "corrupt data line",
+		},
+		"invalidEncoding": {
+			Input: "TcmZQzU|?i'U?w2V48*Je09XJG\n",
+			Err:   "invalid base85 byte",
+		},
+		"incompleteLine": {
+			Input: "TcmZQzU|?i`U?w2V48*Je09XJG",
+			Err:   "unexpected EOF",
+		},
+		"invalidCompression": {
+			Input: "F007GV%KiWV\n\n",
+			Err:   "zlib",
+		},
+		"sizeTooLarge": {
+			Input: "TcmZQzU|?i`U?w2V48*Je09XJG\n\n",
+			Size:  24,
+			Err:   "24 byte fragment inflated to 20",
+		},
+		"sizeTooSmall": {
+			Input: "TcmZQzU|?i`U?w2V48*Je09XJG\n\n",
+			Size:  16,
+			Err:   "16 byte fragment inflates to more bytes",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := io.ReadAll(NewBinaryReader(strings.NewReader(test.Input), test.Size))
+			if test.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.Err) {
+					t.Fatalf("expected error containing %q reading binary data, but got %v", test.Err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error reading binary data: %v", err)
+			}
+			if !bytes.Equal(test.Output, data) {
+				t.Errorf("incorrect binary data\nexpected: %+v\n  actual: %+v", test.Output, data)
+			}
+		})
+	}
+}
+
+func TestOpenBinaryFragment(t *testing.T) {
+	patch, err := os.ReadFile(filepath.Join("testdata", "apply", "bin_fragment_delta_modify_large.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+
+	files, _, err := ParseBytes(patch)
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	expected := files[0].BinaryFragment
+
+	tests := map[string]struct {
+		Options []ParseOption
+	}{
+		"loaded": {},
+		"lazy":   {Options: []ParseOption{WithLazyFragments()}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := ParseBytes(patch, test.Options...)
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			frag, r, err := files[0].OpenBinaryFragment()
+			if err != nil {
+				t.Fatalf("unexpected error opening binary fragment: %v", err)
+			}
+			if frag.Method != expected.Method || frag.Size != expected.Size {
+				t.Errorf("incorrect fragment: expected %v %d, actual %v %d", expected.Method, expected.Size, frag.Method, frag.Size)
+			}
+
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected error reading binary data: %v", err)
+			}
+			if !bytes.Equal(expected.Data, data) {
+				t.Errorf("incorrect binary data: expected %d bytes, actual %d bytes", len(expected.Data), len(data))
+			}
+		})
+	}
+
+	t.Run("lazyLimit", func(t *testing.T) {
+		files, _, err := ParseBytes(patch, WithLazyFragments(), WithMaxBinarySize(16))
+		if err != nil {
+			t.Fatalf("unexpected error parsing patch: %v", err)
+		}
+		_, _, err = files[0].OpenBinaryFragment()
+		assertError(t, &LimitExceededError{}, err, "opening binary fragment")
+	})
+
+	t.Run("text", func(t *testing.T) {
+		files, _, err := ParseBytes([]byte("diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n"), WithLazyFragments())
+		if err != nil {
+			t.Fatalf("unexpected error parsing patch: %v", err)
+		}
+		frag, r, err := files[0].OpenBinaryFragment()
+		if frag != nil || r != nil || err != nil {
+			t.Errorf("expected no binary fragment, but got %v, %v, %v", frag, r, err)
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package gitdiff
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// LabelGenerated is the label GeneratedClassifier assigns to generated files.
+const LabelGenerated = "generated"
+
+// Classifier assigns labels to files, like the language of a file or a
+// category such as LabelGenerated. Use WithClassifier to label files while
+// parsing.
+type Classifier interface {
+	// Classify returns the labels for f, which may be empty
+	Classify(f *File) []string
+}
+
+// ClassifierFunc is a function that implements Classifier.
+type ClassifierFunc func(f *File) []string
+
+// Classify calls fn(f).
+func (fn ClassifierFunc) Classify(f *File) []string {
+	return fn(f)
+}
+
+// WithClassifier configures Parse to add the labels returned by c to the
+// Labels of each file. If there are several classifiers, Parse calls them in
+// the order of the options.
+//
+// Parse classifies files after parsing their fragments, so classifiers can
+// use the content of the changes. With WithLazyFragments, classifiers see
+// files without fragments, which is efficient for classifiers that only use
+// the names and modes of files. Parse does not classify files skipped by
+// WithIncludePaths or WithExcludePaths.
+func WithClassifier(c Classifier) ParseOption {
+	return func(p *parser) {
+		p.classifiers = append(p.classifiers, c)
+	}
+}
+
+// WithIncludeLabels configures Parse to only return files that have at least
+// one of the labels after classification.
+func WithIncludeLabels(labels ...string) ParseOption {
+	return func(p *parser) {
+		p.includeLabels = append(p.includeLabels, labels...)
+	}
+}
+
+// WithExcludeLabels configures Parse to not return files that have any of the
+// labels after classification. Excluded labels take precedence over included
+// labels.
+func WithExcludeLabels(labels ...string) ParseOption {
+	return func(p *parser) {
+		p.excludeLabels = append(p.excludeLabels, labels...)
+	}
+}
+
+// classify labels f and returns true if Parse should return it.
+func (p *parser) classify(f *File) bool {
+	for _, c := range p.classifiers {
+		f.Labels = addLabels(f.Labels, c.Classify(f)...)
+	}
+	for _, label := range p.excludeLabels {
+		if f.HasLabel(label) {
+			return false
+		}
+	}
+	if len(p.includeLabels) == 0 {
+		return true
+	}
+	for _, label := range p.includeLabels {
+		if f.HasLabel(label) {
+			return true
+		}
+	}
+	return false
+}
+
+// addLabels appends the labels that are not already in labels.
+func addLabels(labels []string, add ...string) []string {
+	for _, label := range add {
+		if !hasLabel(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// HasLabel returns true if label is one of the Labels of f.
+func (f *File) HasLabel(label string) bool {
+	return hasLabel(f.Labels, label)
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtensionClassifier returns a Classifier that labels files by their name.
+// The keys of labels are extensions, including the dot, like ".go", or base
+// names of files without an extension, like "Makefile". Files are labeled by
+// their new name, or by their old name if they are deleted. Matching is
+// case-sensitive and base names take precedence over extensions.
+func ExtensionClassifier(labels map[string]string) Classifier {
+	return ClassifierFunc(func(f *File) []string {
+		base := path.Base(classifyName(f))
+		if label, ok := labels[base]; ok {
+			return []string{label}
+		}
+		if label, ok := labels[path.Ext(base)]; ok {
+			return []string{label}
+		}
+		return nil
+	})
+}
+
+// generatedRegexp matches the comment that marks generated Go files, as
+// described by https://golang.org/s/generatedcode.
+var generatedRegexp = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.\r?\n?$`)
+
+// generatedNames are patterns for the base names of files that are usually
+// generated, like compiled protocol buffers, minified code, and lock files.
+var generatedNames = []string{
+	"*.pb.go",
+	"*.pb.gw.go",
+	"*.pb.cc",
+	"*.pb.h",
+	"*_pb2.py",
+	"*_pb2_grpc.py",
+	"*.min.js",
+	"*.min.css",
+	"*.js.map",
+	"*.css.map",
+	"go.sum",
+	"package-lock.json",
+	"yarn.lock",
+	"Cargo.lock",
+}
+
+// generatedDirs are the names of directories that usually contain generated
+// or vendored files.
+var generatedDirs = []string{
+	"vendor",
+	"node_modules",
+}
+
+// minifiedLineLength is the average length of the new lines of a JavaScript
+// or CSS file above which the file is considered minified, from the
+// heuristic used by GitHub Linguist.
+const minifiedLineLength = 110
+
+// GeneratedClassifier returns a Classifier that labels generated files with
+// LabelGenerated. If the "linguist-generated" attribute from attrs is set or
+// unset for a file, like a .gitattributes file on GitHub, the attribute
+// decides if the file is generated. Otherwise, a file is generated if:
+//
+//   - its name matches a common pattern for generated files, like "*.pb.go",
+//     "*.min.js", or "go.sum", or it is in a "vendor" or "node_modules"
+//     directory
+//   - a new line in a text fragment is the conventional comment for generated
+//     code, "// Code generated ... DO NOT EDIT."
+//   - it is a JavaScript or CSS file and the new lines in its text fragments
+//     are long on average, like minified code
+//
+// The content checks only use the lines in the patch, so they can not detect
+// all generated files. attrs may be nil.
+func GeneratedClassifier(attrs *Attributes) Classifier {
+	return ClassifierFunc(func(f *File) []string {
+		generated, ok := isGeneratedName(attrs, classifyName(f))
+		if !ok {
+			generated = isGeneratedContent(f)
+		}
+		if generated {
+			return []string{LabelGenerated}
+		}
+		return nil
+	})
+}
+
+// WithSkipGenerated configures Parse to skip the fragments of files that
+// GeneratedClassifier with attrs identifies as generated from their names and
+// attributes, without using their content. Parse returns these files with
+// the LabelGenerated label and no fragments, which saves memory in tools that
+// do not show the changes to generated files. attrs may be nil.
+func WithSkipGenerated(attrs *Attributes) ParseOption {
+	return func(p *parser) {
+		p.skipGenerated, p.generatedAttrs = true, attrs
+	}
+}
+
+// skipGeneratedFile returns true if the parser should skip the fragments of f
+// because it is generated, adding LabelGenerated to the file.
+func (p *parser) skipGeneratedFile(f *File) bool {
+	if !p.skipGenerated {
+		return false
+	}
+	if generated, _ := isGeneratedName(p.generatedAttrs, classifyName(f)); !generated {
+		return false
+	}
+	f.Labels = addLabels(f.Labels, LabelGenerated)
+	return true
+}
+
+// isGeneratedName returns true if the file with name is generated according
+// to its attributes or its name. The second value is false if neither
+// decides, so the content of the file should be checked.
+func isGeneratedName(attrs *Attributes, name string) (generated, ok bool) {
+	switch state, value := attrs.Get(name, "linguist-generated"); state {
+	case AttrSet:
+		return true, true
+	case AttrUnset:
+		return false, true
+	case AttrValue:
+		return value != "false", true
+	}
+
+	base := path.Base(name)
+	for _, pattern := range generatedNames {
+		if ok, _ := path.Match(pattern, base); ok {
+			return true, true
+		}
+	}
+	for _, dir := range generatedDirs {
+		if strings.HasPrefix(name, dir+"/") || strings.Contains(name, "/"+dir+"/") {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// isGeneratedContent returns true if the new lines of f look like generated
+// code.
+func isGeneratedContent(f *File) bool {
+	var lines, size int64
+	for _, frag := range f.TextFragments {
+		for _, line := range frag.Lines {
+			if !line.New() {
+				continue
+			}
+			if generatedRegexp.MatchString(line.Line) {
+				return true
+			}
+			lines++
+			size += int64(len(strings.TrimRight(line.Line, "\r\n")))
+		}
+	}
+
+	switch path.Ext(classifyName(f)) {
+	case ".js", ".css":
+		return lines > 0 && size/lines > minifiedLineLength
+	}
+	return false
+}
+
+// classifyName returns the name used to classify f: the new name, or the old
+// name if the file is deleted.
+func classifyName(f *File) string {
+	if f.IsDelete || f.NewName == "" {
+		return f.OldName
+	}
+	return f.NewName
+}
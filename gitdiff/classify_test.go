@@ -0,0 +1,222 @@
+package gitdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseClassifier(t *testing.T) {
+	const patch = `diff --git a/main.go b/main.go
+index 1c23fcc..40a1b33 100644
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-package a
++package main
+diff --git a/api.pb.go b/api.pb.go
+new file mode 100644
+index 0000000..2b51d8b
+--- /dev/null
++++ b/api.pb.go
+@@ -0,0 +1,3 @@
++// Code generated by protoc-gen-go. DO NOT EDIT.
++
++package api
+diff --git a/Makefile b/Makefile
+index 1c23fcc..40a1b33 100644
+--- a/Makefile
++++ b/Makefile
+@@ -1 +1 @@
+-all:
++build:
+diff --git a/old.py b/old.py
+deleted file mode 100644
+index 1c23fcc..0000000
+--- a/old.py
++++ /dev/null
+@@ -1 +0,0 @@
+-print("old")
+diff --git a/notes.txt b/notes.txt
+index 1c23fcc..40a1b33 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -1 +1 @@
+-// Code generated by hand. DO NOT EDIT.
++notes
+`
+
+	languages := ExtensionClassifier(map[string]string{
+		".go":      "go",
+		".py":      "python",
+		"Makefile": "make",
+	})
+
+	tests := map[string]struct {
+		Options []ParseOption
+		Labels  map[string][]string
+	}{
+		"labels": {
+			Options: []ParseOption{WithClassifier(languages), WithClassifier(GeneratedClassifier(nil))},
+			Labels: map[string][]string{
+				"main.go":   {"go"},
+				"api.pb.go": {"go", LabelGenerated},
+				"Makefile":  {"make"},
+				"old.py":    {"python"},
+				"notes.txt": nil,
+			},
+		},
+		"includeLabels": {
+			Options: []ParseOption{WithClassifier(languages), WithIncludeLabels("go", "make")},
+			Labels: map[string][]string{
+				"main.go":   {"go"},
+				"api.pb.go": {"go"},
+				"Makefile":  {"make"},
+			},
+		},
+		"excludeLabels": {
+			Options: []ParseOption{
+				WithClassifier(languages),
+				WithClassifier(GeneratedClassifier(nil)),
+				WithIncludeLabels("go"),
+				WithExcludeLabels(LabelGenerated),
+			},
+			Labels: map[string][]string{
+				"main.go": {"go"},
+			},
+		},
+		"lazy": {
+			Options: []ParseOption{WithLazyFragments(), WithClassifier(GeneratedClassifier(nil)), WithIncludeLabels(LabelGenerated)},
+			Labels: map[string][]string{
+				"api.pb.go": {LabelGenerated},
+			},
+		},
+		"classifierFunc": {
+			Options: []ParseOption{
+				WithClassifier(ClassifierFunc(func(f *File) []string {
+					if f.IsNew || f.IsDelete {
+						return []string{"added-or-removed"}
+					}
+					return nil
+				})),
+				WithIncludeLabels("added-or-removed"),
+			},
+			Labels: map[string][]string{
+				"api.pb.go": {"added-or-removed"},
+				"old.py":    {"added-or-removed"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := ParseAll(strings.NewReader(patch), test.Options...)
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			labels := make(map[string][]string)
+			for _, f := range files {
+				name := f.NewName
+				if name == "" {
+					name = f.OldName
+				}
+				labels[name] = f.Labels
+			}
+			if !reflect.DeepEqual(test.Labels, labels) {
+				t.Errorf("incorrect labels\nexpected: %v\n  actual: %v", test.Labels, labels)
+			}
+		})
+	}
+}
+
+func TestFileHasLabel(t *testing.T) {
+	f := &File{Labels: []string{"go", LabelGenerated}}
+	if !f.HasLabel(LabelGenerated) {
+		t.Errorf("expected file to have label %q", LabelGenerated)
+	}
+	if f.HasLabel("python") {
+		t.Errorf("expected file to not have label %q", "python")
+	}
+}
+
+func TestGeneratedClassifier(t *testing.T) {
+	attrs, err := ParseAttributes(strings.NewReader("gen/** linguist-generated\nvendor/keep.go -linguist-generated\n*.out linguist-generated=false\n"))
+	if err != nil {
+		t.Fatalf("unexpected error parsing attributes: %v", err)
+	}
+
+	newLines := func(lines ...string) []*TextFragment {
+		frag := &TextFragment{}
+		for _, line := range lines {
+			frag.Lines = append(frag.Lines, Line{Op: OpAdd, Line: line + "\n"})
+		}
+		return []*TextFragment{frag}
+	}
+	minified := strings.Repeat("var a=1;", 20)
+
+	tests := map[string]struct {
+		File      *File
+		Generated bool
+	}{
+		"protobuf":       {File: &File{NewName: "api/api.pb.go"}, Generated: true},
+		"minifiedName":   {File: &File{NewName: "static/app.min.js"}, Generated: true},
+		"lockFile":       {File: &File{NewName: "web/package-lock.json"}, Generated: true},
+		"vendor":         {File: &File{NewName: "vendor/github.com/pkg/errors/errors.go"}, Generated: true},
+		"nestedVendor":   {File: &File{NewName: "tools/vendor/lib.go"}, Generated: true},
+		"notVendor":      {File: &File{NewName: "myvendor/lib.go"}},
+		"deleted":        {File: &File{OldName: "node_modules/x/index.js", IsDelete: true}, Generated: true},
+		"source":         {File: &File{NewName: "main.go", TextFragments: newLines("package main")}},
+		"generatedCode":  {File: &File{NewName: "zz_deepcopy.go", TextFragments: newLines("// Code generated by deepcopy-gen. DO NOT EDIT.", "", "package api")}, Generated: true},
+		"minifiedJS":     {File: &File{NewName: "static/app.js", TextFragments: newLines(minified, minified)}, Generated: true},
+		"longLinesNotJS": {File: &File{NewName: "data.txt", TextFragments: newLines(minified, minified)}},
+		"attrSet":        {File: &File{NewName: "gen/model.go"}, Generated: true},
+		"attrUnset":      {File: &File{NewName: "vendor/keep.go"}},
+		"attrFalse":      {File: &File{NewName: "test.out", TextFragments: newLines("// Code generated by hand. DO NOT EDIT.")}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			labels := GeneratedClassifier(attrs).Classify(test.File)
+			if generated := len(labels) > 0; generated != test.Generated {
+				t.Errorf("incorrect classification: expected generated = %t, actual labels = %v", test.Generated, labels)
+			}
+		})
+	}
+}
+
+func TestParseSkipGenerated(t *testing.T) {
+	const patch = `diff --git a/go.sum b/go.sum
+index 1c23fcc..40a1b33 100644
+--- a/go.sum
++++ b/go.sum
+@@ -1 +1 @@
+-example.com/a v1.0.0 h1:old
++example.com/a v1.1.0 h1:new
+diff --git a/main.go b/main.go
+index 1c23fcc..40a1b33 100644
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-package a
++package main
+`
+
+	files, _, err := ParseAll(strings.NewReader(patch), WithSkipGenerated(nil), WithClassifier(GeneratedClassifier(nil)))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("incorrect number of files: expected 2, actual %d", len(files))
+	}
+
+	if !reflect.DeepEqual(files[0].Labels, []string{LabelGenerated}) {
+		t.Errorf("incorrect labels for generated file: %v", files[0].Labels)
+	}
+	if len(files[0].TextFragments) != 0 {
+		t.Errorf("expected no fragments for generated file, but got %d", len(files[0].TextFragments))
+	}
+	if len(files[1].Labels) != 0 || len(files[1].TextFragments) != 1 {
+		t.Errorf("incorrect source file: labels %v, %d fragments", files[1].Labels, len(files[1].TextFragments))
+	}
+}
@@ -0,0 +1,211 @@
+package gitdiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Combine composes a series of patches into a single patch with the same
+// effect as applying the patches in order, like squashing the commits of a
+// series. Changes to the same file are merged into one file, following
+// renames and copies, so that the result can be applied to the original
+// content of the files.
+//
+// For files changed by more than one patch, Combine reconstructs the parts of
+// the original content included in the fragments of the patches and applies
+// each patch to them, so the result only contains context lines that appear
+// in the patches. Files are in the order they first appear in the series.
+// Files created and then deleted, and files with no changes after all
+// patches, are omitted.
+//
+// Combine returns an error if a patch does not apply to the result of the
+// previous patches, if changes depend on content that is not in any patch,
+// or if a file changed by more than one patch is binary.
+func Combine(patches ...[]*File) ([]*File, error) {
+	patches = append([][]*File(nil), patches...)
+	for i, patch := range patches {
+		var err error
+		if patches[i], err = loadedFiles(patch); err != nil {
+			return nil, err
+		}
+	}
+
+	var files []*File
+	current := make(map[string]int)
+	deleted := make(map[string]int)
+
+	for _, patch := range patches {
+		for _, f := range patch {
+			name := f.OldName
+			if f.IsNew {
+				name = f.NewName
+			}
+
+			var prev *File
+			idx, ok := current[name]
+			if f.IsNew {
+				if ok {
+					return nil, fmt.Errorf("%s: %w", name, &Conflict{"file already exists"})
+				}
+				idx, ok = deleted[name]
+				delete(deleted, name)
+			} else if _, isDeleted := deleted[name]; isDeleted && !ok {
+				return nil, fmt.Errorf("%s: %w", name, &Conflict{"file does not exist"})
+			}
+			if ok {
+				prev = files[idx]
+			}
+
+			c := f
+			if prev != nil {
+				var err error
+				if c, err = combineFile(name, prev, f); err != nil {
+					return nil, err
+				}
+			}
+
+			// copies leave the source unchanged, so they are always new files
+			if ok && !f.IsCopy {
+				delete(current, name)
+				files[idx] = c
+			} else {
+				idx = len(files)
+				files = append(files, c)
+			}
+
+			switch {
+			case c == nil:
+				files[idx] = nil
+			case f.IsDelete:
+				deleted[name] = idx
+			default:
+				current[f.NewName] = idx
+			}
+		}
+	}
+
+	var result []*File
+	for _, f := range files {
+		if f != nil && !isEmptyChange(f) {
+			result = append(result, f)
+		}
+	}
+	return result, nil
+}
+
+// combineFile returns a file with the changes of prev followed by the
+// changes of f, where f applies to the result of prev. It returns nil if prev
+// creates the file and f deletes it.
+func combineFile(name string, prev, f *File) (*File, error) {
+	if prev.IsNew && f.IsDelete {
+		return nil, nil
+	}
+
+	c := &File{
+		OldName:       prev.OldName,
+		NewName:       f.NewName,
+		OldMode:       prev.OldMode,
+		NewMode:       resultMode(f),
+		OldOIDPrefix:  prev.OldOIDPrefix,
+		NewOIDPrefix:  f.NewOIDPrefix,
+		HashAlgorithm: f.HashAlgorithm,
+		IsNew:         prev.IsNew,
+		IsDelete:      f.IsDelete,
+	}
+	if c.NewMode == 0 && !c.IsDelete {
+		c.NewMode = resultMode(prev)
+	}
+	if c.OldMode == 0 && !c.IsNew {
+		// patches without modes don't change them
+		c.OldMode = c.NewMode
+	}
+	if c.IsNew {
+		c.OldName = ""
+	}
+	if c.IsDelete {
+		c.NewName = ""
+	}
+	if !c.IsNew && !c.IsDelete && c.OldName != c.NewName {
+		c.IsCopy = f.IsCopy || prev.IsCopy
+		c.IsRename = !c.IsCopy
+	}
+
+	if prev.IsBinary || f.IsBinary {
+		return nil, fmt.Errorf("%s: cannot combine binary changes", name)
+	}
+
+	base, err := combineBase(name, prev, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var mid, out bytes.Buffer
+	if err := Apply(&mid, strings.NewReader(strings.Join(base, "")), prev); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := Apply(&out, bytes.NewReader(mid.Bytes()), f); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	if c.TextFragments, err = partialFragments(strings.Join(base, ""), out.String()); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return c, nil
+}
+
+// combineBase reconstructs the original content of a file from the old lines
+// of the fragments in prev and the old lines of the fragments in f that are
+// not changed by prev. Lines that are not in either patch are replaced by
+// unique placeholders.
+func combineBase(name string, prev, f *File) ([]string, error) {
+	known := make(map[int64]string)
+	var size int64
+	add := func(i int64, line string) error {
+		if l, ok := known[i]; ok && l != line {
+			return fmt.Errorf("%s: %w", name, &Conflict{fmt.Sprintf("patches have different content for line %d", i+1)})
+		}
+		known[i] = line
+		if i+1 > size {
+			size = i + 1
+		}
+		return nil
+	}
+
+	for _, frag := range prev.TextFragments {
+		i := fragmentStart(frag.OldPosition, frag.OldLines) - 1
+		for _, line := range frag.Lines {
+			if line.Old() {
+				if err := add(i, line.Line); err != nil {
+					return nil, err
+				}
+				i++
+			}
+		}
+		if i > size {
+			size = i
+		}
+	}
+
+	m := NewLineMapper(prev)
+	for _, frag := range f.TextFragments {
+		pos := fragmentStart(frag.OldPosition, frag.OldLines)
+		for _, line := range frag.Lines {
+			if !line.Old() {
+				continue
+			}
+			if old, op := m.NewToOld(pos); op == OpContext {
+				if err := add(old-1, line.Line); err != nil {
+					return nil, err
+				}
+			}
+			pos++
+		}
+
+		// the end of an insertion must be in the reconstructed content
+		if old, _ := m.NewToOld(pos); old-1 > size {
+			size = old - 1
+		}
+	}
+	return partialContent(known, size), nil
+}
@@ -0,0 +1,142 @@
+package gitdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCombine(t *testing.T) {
+	base := map[string][]byte{
+		"gone.txt": []byte("bye\n"),
+		"nums.txt": []byte(numberLines(1, 30)),
+		"old.txt":  []byte("move\nme\n"),
+	}
+
+	var patches [][]*File
+	for _, name := range []string{"combine_1.patch", "combine_2.patch", "combine_3.patch"} {
+		patches = append(patches, parseTestPatch(t, name))
+	}
+
+	files, err := Combine(patches...)
+	if err != nil {
+		t.Fatalf("unexpected error combining patches: %v", err)
+	}
+
+	var out strings.Builder
+	for _, f := range files {
+		out.WriteString(f.String())
+	}
+	expected, err := os.ReadFile(filepath.Join("testdata", "combine.patch"))
+	if err != nil {
+		t.Fatalf("failed to read expected patch: %v", err)
+	}
+	if out.String() != string(expected) {
+		t.Errorf("incorrect combined patch\nexpected:\n%s\nactual:\n%s", expected, out.String())
+	}
+
+	result := base
+	for _, patch := range patches {
+		if result, err = ApplyToMap(result, patch); err != nil {
+			t.Fatalf("unexpected error applying patch: %v", err)
+		}
+	}
+	actual, err := ApplyToMap(base, files)
+	if err != nil {
+		t.Fatalf("unexpected error applying combined patch: %v", err)
+	}
+	if !reflect.DeepEqual(result, actual) {
+		t.Errorf("applying combined patch does not produce the result of the series")
+	}
+}
+
+func TestCombineApply(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		orig := randomLines(r, 1+r.Intn(40))
+		mid := randomEdit(r, orig)
+		final := randomEdit(r, mid)
+
+		first := mustDiff(t, orig, mid, WithContext(1+r.Intn(3)))
+		second := mustDiff(t, mid, final, WithContext(1+r.Intn(3)))
+		if len(first.TextFragments) == 0 || len(second.TextFragments) == 0 {
+			continue
+		}
+
+		files, err := Combine([]*File{first}, []*File{second})
+		if err != nil {
+			// the second patch changes lines that neither patch includes
+			continue
+		}
+		if len(files) == 0 {
+			if orig != final {
+				t.Fatalf("expected combined patch for changed file\norig: %q\nfinal: %q", orig, final)
+			}
+			continue
+		}
+
+		var out bytes.Buffer
+		if err := Apply(&out, strings.NewReader(orig), files[0]); err != nil {
+			t.Fatalf("unexpected error applying combined patch: %v\norig: %q\nfinal: %q\ndiff:\n%s", err, orig, final, files[0])
+		}
+		if out.String() != final {
+			t.Fatalf("incorrect result after apply\nexpected: %q\n  actual: %q\ndiff:\n%s", final, out.String(), files[0])
+		}
+	}
+}
+
+func TestCombineErrors(t *testing.T) {
+	tests := map[string]struct {
+		Patches []string
+		Err     interface{}
+	}{
+		"conflict": {
+			Patches: []string{
+				"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+				"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+d\n",
+			},
+			Err: &Conflict{},
+		},
+		"createExists": {
+			Patches: []string{
+				"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n",
+				"diff --git a/f b/f\nnew file mode 100644\n--- /dev/null\n+++ b/f\n@@ -0,0 +1 @@\n+a\n",
+			},
+			Err: &Conflict{},
+		},
+		"modifyDeleted": {
+			Patches: []string{
+				"diff --git a/f b/f\ndeleted file mode 100644\n--- a/f\n+++ /dev/null\n@@ -1 +0,0 @@\n-a\n",
+				"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n",
+			},
+			Err: &Conflict{},
+		},
+		"binary": {
+			Patches: []string{
+				"diff --git a/f b/f\nindex 1111111..2222222 100644\nBinary files a/f and b/f differ\n",
+				"diff --git a/f b/f\nindex 2222222..3333333 100644\nBinary files a/f and b/f differ\n",
+			},
+			Err: "binary",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var patches [][]*File
+			for _, patch := range test.Patches {
+				files, _, err := ParseAll(strings.NewReader(patch))
+				if err != nil {
+					t.Fatalf("unexpected error parsing patch: %v", err)
+				}
+				patches = append(patches, files)
+			}
+
+			_, err := Combine(patches...)
+			assertError(t, test.Err, err, "combining patches")
+		})
+	}
+}
@@ -0,0 +1,442 @@
+package gitdiff
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CombinedFragment describes changed lines in a combined diff, which compares
+// the result of a merge with each of its parents at the same time. Combined
+// diffs are generated by "git diff --cc" and "git show" for merge commits.
+type CombinedFragment struct {
+	Comment string `json:"comment,omitempty"`
+
+	// OldPositions and OldLines contain the position and number of lines of
+	// the fragment in each parent, in the same order as the parents.
+	OldPositions []int64 `json:"old_positions,omitempty"`
+	OldLines     []int64 `json:"old_lines,omitempty"`
+
+	NewPosition int64 `json:"new_position,omitempty"`
+	NewLines    int64 `json:"new_lines,omitempty"`
+
+	Lines []CombinedLine `json:"lines,omitempty"`
+}
+
+// Parents returns the number of parents compared by the fragment.
+func (f *CombinedFragment) Parents() int {
+	return len(f.OldPositions)
+}
+
+// Header returns the canonical header of this fragment.
+func (f *CombinedFragment) Header() string {
+	var b strings.Builder
+	marker := strings.Repeat("@", f.Parents()+1)
+
+	b.WriteString(marker)
+	for i := range f.OldPositions {
+		fmt.Fprintf(&b, " -%d,%d", f.OldPositions[i], f.OldLines[i])
+	}
+	fmt.Fprintf(&b, " +%d,%d %s %s", f.NewPosition, f.NewLines, marker, f.Comment)
+	return b.String()
+}
+
+// Parent returns a text fragment with the changes between parent i and the
+// merge result, as they would appear in a regular diff against that parent.
+// The fragment may not contain any changes if the lines in the parent match
+// the result, but differ from the lines in another parent.
+func (f *CombinedFragment) Parent(i int) *TextFragment {
+	frag := &TextFragment{
+		Comment:     f.Comment,
+		OldPosition: f.OldPositions[i],
+		OldLines:    f.OldLines[i],
+		NewPosition: f.NewPosition,
+		NewLines:    f.NewLines,
+	}
+	for _, line := range f.Lines {
+		switch {
+		case line.New() && line.Ops[i] == OpAdd:
+			frag.addLines(OpAdd, []string{line.Line})
+		case line.New():
+			frag.addLines(OpContext, []string{line.Line})
+		case line.Ops[i] == OpDelete:
+			frag.addLines(OpDelete, []string{line.Line})
+		}
+	}
+	return frag
+}
+
+// CombinedLine is a line in a combined fragment. It has one operation for
+// each parent of the merge.
+//
+// For lines in the merge result, OpContext means the line is also in the
+// parent and OpAdd means it is not. For lines removed from all parents that
+// contained them, OpDelete means the line is in the parent and OpContext
+// means it is not. A line never has both OpAdd and OpDelete operations.
+type CombinedLine struct {
+	Ops  []LineOp `json:"ops"`
+	Line string   `json:"line"`
+}
+
+func (fl CombinedLine) String() string {
+	var b strings.Builder
+	for _, op := range fl.Ops {
+		b.WriteString(op.String())
+	}
+	b.WriteString(fl.Line)
+	return b.String()
+}
+
+// Old returns true if the line appears in the content of parent i.
+func (fl CombinedLine) Old(i int) bool {
+	if fl.New() {
+		return fl.Ops[i] == OpContext
+	}
+	return fl.Ops[i] == OpDelete
+}
+
+// New returns true if the line appears in the merge result.
+func (fl CombinedLine) New() bool {
+	for _, op := range fl.Ops {
+		if op == OpDelete {
+			return false
+		}
+	}
+	return true
+}
+
+// NoEOL returns true if the line is missing a trailing newline character.
+func (fl CombinedLine) NoEOL() bool {
+	return len(fl.Line) == 0 || fl.Line[len(fl.Line)-1] != '\n'
+}
+
+// ParseCombinedFileHeader parses a file header from a combined diff, which
+// starts with "diff --cc" or "diff --combined".
+func (p *parser) ParseCombinedFileHeader() (*File, error) {
+	var header string
+	var col int
+	for _, prefix := range []string{"diff --cc ", "diff --combined "} {
+		if strings.HasPrefix(p.Line(0), prefix) {
+			header = p.Line(0)[len(prefix):]
+			col = len(prefix)
+			break
+		}
+	}
+	if header == "" {
+		return nil, nil
+	}
+
+	name, _, err := parseName(header, 0, 0)
+	if err != nil {
+		return nil, p.Errorf(0, KindFileHeader, "combined file header: %w", atColumn(err, col))
+	}
+
+	f := &File{IsCombined: true, HashAlgorithm: p.hashAlgorithm}
+	for {
+		end, err := parseCombinedHeaderData(f, p.Line(1))
+		if err != nil {
+			return nil, p.Errorf(1, headerErrorKind(err), "combined file header: %w", err)
+		}
+
+		if err := p.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		if end {
+			break
+		}
+	}
+
+	if !f.IsNew {
+		f.OldName = name
+	}
+	if !f.IsDelete {
+		f.NewName = name
+	}
+	setHashAlgorithm(f)
+	return f, nil
+}
+
+// parseCombinedHeaderData parses a single line of metadata from a combined
+// file header. It returns true when header parsing is complete; in that
+// case, line was the first line of non-header content.
+func parseCombinedHeaderData(f *File, line string) (end bool, err error) {
+	line = strings.TrimSuffix(line, "\n")
+
+	for _, hdr := range []struct {
+		prefix string
+		end    bool
+		parse  func(*File, string) error
+	}{
+		{"@@@", true, nil},
+		// names are the same as the name in the first line
+		{"--- ", false, nil},
+		{"+++ ", false, nil},
+		{"index ", false, parseCombinedHeaderIndex},
+		{"mode ", false, parseCombinedHeaderMode},
+		{"new file mode ", false, parseCombinedHeaderCreatedMode},
+		{"deleted file mode ", false, parseCombinedHeaderDeletedMode},
+	} {
+		if strings.HasPrefix(line, hdr.prefix) {
+			if hdr.parse != nil {
+				err = atColumn(hdr.parse(f, line[len(hdr.prefix):]), len(hdr.prefix))
+			}
+			return hdr.end, err
+		}
+	}
+	return true, nil
+}
+
+func parseCombinedHeaderIndex(f *File, line string) error {
+	const sep = ".."
+
+	oids := strings.SplitN(line, sep, 2)
+	if len(oids) < 2 {
+		return fmt.Errorf("invalid index line: missing %q", sep)
+	}
+	parents := strings.Split(oids[0], ",")
+	col := 0
+	for _, oid := range parents {
+		if err := validateOID(oid, f.HashAlgorithm); err != nil {
+			return atColumn(err, col)
+		}
+		col += len(oid) + 1
+	}
+	if err := validateOID(oids[1], f.HashAlgorithm); err != nil {
+		return atColumn(err, len(oids[0])+len(sep))
+	}
+	f.ParentOIDPrefixes = parents
+	f.NewOIDPrefix = oids[1]
+	return nil
+}
+
+func parseCombinedHeaderMode(f *File, line string) (err error) {
+	const sep = ".."
+
+	modes := strings.SplitN(line, sep, 2)
+	if len(modes) < 2 {
+		return fmt.Errorf("%w: missing %q", errInvalidMode, sep)
+	}
+	if f.ParentModes, err = parseModes(modes[0]); err != nil {
+		return err
+	}
+	f.NewMode, err = parseMode(modes[1])
+	return atColumn(err, len(modes[0])+len(sep))
+}
+
+func parseCombinedHeaderCreatedMode(f *File, line string) (err error) {
+	f.IsNew = true
+	f.NewMode, err = parseMode(line)
+	return
+}
+
+func parseCombinedHeaderDeletedMode(f *File, line string) (err error) {
+	f.IsDelete = true
+	f.ParentModes, err = parseModes(line)
+	return
+}
+
+func parseModes(s string) ([]os.FileMode, error) {
+	var modes []os.FileMode
+	col := 0
+	for _, m := range strings.Split(s, ",") {
+		mode, err := parseMode(m)
+		if err != nil {
+			return nil, atColumn(err, col)
+		}
+		modes = append(modes, mode)
+		col += len(m) + 1
+	}
+	return modes, nil
+}
+
+// ParseCombinedFragments parses combined fragments until the next file header
+// or the end of the stream and attaches them to the given file. It returns
+// the number of fragments that were added.
+func (p *parser) ParseCombinedFragments(f *File) (n int, err error) {
+	for {
+		frag, err := p.ParseCombinedFragmentHeader()
+		if err != nil {
+			return n, err
+		}
+		if frag == nil {
+			return n, nil
+		}
+
+		if err := p.checkFragmentLimit(-1, n); err != nil {
+			return n, err
+		}
+
+		if len(f.ParentOIDPrefixes) > 0 && frag.Parents() != len(f.ParentOIDPrefixes) {
+			return n, p.Errorf(-1, KindInconsistentFragment, "fragment has %d parents, but file has %d", frag.Parents(), len(f.ParentOIDPrefixes))
+		}
+
+		if err := p.ParseCombinedChunk(frag); err != nil {
+			return n, err
+		}
+
+		f.CombinedFragments = append(f.CombinedFragments, frag)
+		n++
+	}
+}
+
+func (p *parser) ParseCombinedFragmentHeader() (*CombinedFragment, error) {
+	line := p.Line(0)
+	if !strings.HasPrefix(line, "@@@") {
+		return nil, nil
+	}
+
+	n := strings.IndexFunc(line, func(c rune) bool { return c != '@' })
+	if n < 0 || !strings.HasPrefix(line[n:], " -") {
+		return nil, p.Errorf(0, KindFragmentHeader, "invalid fragment header")
+	}
+	endMark := " " + line[:n]
+
+	parts := strings.SplitAfterN(line[n:], endMark, 2)
+	if len(parts) < 2 {
+		return nil, p.Errorf(0, KindFragmentHeader, "invalid fragment header")
+	}
+
+	f := &CombinedFragment{}
+	f.Comment = strings.TrimSpace(parts[1])
+
+	ranges := strings.Fields(parts[0][:len(parts[0])-len(endMark)])
+	if len(ranges) != n {
+		return nil, p.Errorf(0, KindFragmentHeader, "invalid fragment header")
+	}
+
+	for i, r := range ranges {
+		var start, lines int64
+		var err error
+
+		switch {
+		case i < n-1 && strings.HasPrefix(r, "-"):
+			if start, lines, err = parseRange(r[1:]); err != nil {
+				return nil, p.Errorf(0, KindFragmentHeader, "invalid fragment header: %w", err)
+			}
+			f.OldPositions = append(f.OldPositions, start)
+			f.OldLines = append(f.OldLines, lines)
+
+		case i == n-1 && strings.HasPrefix(r, "+"):
+			if f.NewPosition, f.NewLines, err = parseRange(r[1:]); err != nil {
+				return nil, p.Errorf(0, KindFragmentHeader, "invalid fragment header: %w", err)
+			}
+
+		default:
+			return nil, p.Errorf(0, KindFragmentHeader, "invalid fragment header")
+		}
+	}
+
+	if err := p.Next(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *parser) ParseCombinedChunk(frag *CombinedFragment) error {
+	if p.Line(0) == "" {
+		return p.Errorf(0, KindTruncatedFragment, "no content following fragment header")
+	}
+
+	parents := frag.Parents()
+	oldLines := make([]int64, parents)
+	copy(oldLines, frag.OldLines)
+	newLines := frag.NewLines
+
+	remaining := func() bool {
+		for _, n := range oldLines {
+			if n > 0 {
+				return true
+			}
+		}
+		return newLines > 0
+	}
+
+	// count lines to find the header if the fragment miscounts lines
+	var lines int64
+
+	changes := false
+	for remaining() {
+		line := p.Line(0)
+		lines++
+
+		if isNoNewlineMarker(line) {
+			// this may appear in middle of fragment if it's for a removed line
+			removeLastCombinedNewline(frag)
+		} else {
+			if len(line) <= parents {
+				return p.Errorf(0, KindInvalidLine, "invalid line operation: %q", line)
+			}
+
+			cl := CombinedLine{Ops: make([]LineOp, parents), Line: line[parents:]}
+			for i := 0; i < parents; i++ {
+				switch line[i] {
+				case ' ':
+					cl.Ops[i] = OpContext
+				case '-':
+					cl.Ops[i] = OpDelete
+					changes = true
+				case '+':
+					cl.Ops[i] = OpAdd
+					changes = true
+				default:
+					e := p.Errorf(0, KindInvalidLine, "invalid line operation: %q", line[i])
+					e.Column = i + 1
+					return e
+				}
+			}
+
+			isNew := cl.New()
+			for i, op := range cl.Ops {
+				if !isNew && op == OpAdd {
+					return p.Errorf(0, KindInvalidLine, "line is both added and removed")
+				}
+				if cl.Old(i) {
+					oldLines[i]--
+				}
+			}
+			if isNew {
+				newLines--
+			}
+			frag.Lines = append(frag.Lines, cl)
+		}
+
+		if err := p.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+	}
+
+	for i, n := range oldLines {
+		if n != 0 || newLines != 0 {
+			return p.Errorf(-(lines + 1), KindTruncatedFragment, "fragment header miscounts lines: %+d parent %d, %+d new", -n, i+1, -newLines)
+		}
+	}
+	if !changes {
+		return p.Errorf(0, KindEmptyFragment, "fragment contains no changes")
+	}
+
+	// check for a final "no newline" marker since it is not included in the
+	// counters used to stop the loop above
+	if isNoNewlineMarker(p.Line(0)) {
+		removeLastCombinedNewline(frag)
+		if err := p.Next(); err != nil && err != io.EOF {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func removeLastCombinedNewline(frag *CombinedFragment) {
+	if len(frag.Lines) > 0 {
+		last := &frag.Lines[len(frag.Lines)-1]
+		last.Line = strings.TrimSuffix(last.Line, "\n")
+	}
+}
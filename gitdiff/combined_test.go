@@ -0,0 +1,404 @@
+package gitdiff
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCombinedFileHeader(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Output *File
+		Err    bool
+	}{
+		"cc": {
+			Input: `diff --cc dir/file.txt
+index 5f25ea0,2b51d8b..56fe8f8
+--- a/dir/file.txt
++++ b/dir/file.txt
+@@@ -1,6 -1,6 +1,6 @@@
+`,
+			Output: &File{
+				OldName:           "dir/file.txt",
+				NewName:           "dir/file.txt",
+				IsCombined:        true,
+				ParentOIDPrefixes: []string{"5f25ea0", "2b51d8b"},
+				NewOIDPrefix:      "56fe8f8",
+			},
+		},
+		"combined": {
+			Input: `diff --combined file.txt
+index 5f25ea0,2b51d8b,9a1c33e..56fe8f8
+--- a/file.txt
++++ b/file.txt
+@@@@ -1,6 -1,6 -2,7 +1,6 @@@@
+`,
+			Output: &File{
+				OldName:           "file.txt",
+				NewName:           "file.txt",
+				IsCombined:        true,
+				ParentOIDPrefixes: []string{"5f25ea0", "2b51d8b", "9a1c33e"},
+				NewOIDPrefix:      "56fe8f8",
+			},
+		},
+		"modeChange": {
+			Input: `diff --cc script.sh
+index 56fe8f8,5f25ea0..56fe8f8
+mode 100644,100644..100755
+--- a/script.sh
++++ b/script.sh
+`,
+			Output: &File{
+				OldName:           "script.sh",
+				NewName:           "script.sh",
+				IsCombined:        true,
+				ParentOIDPrefixes: []string{"56fe8f8", "5f25ea0"},
+				ParentModes:       []os.FileMode{os.FileMode(0100644), os.FileMode(0100644)},
+				NewOIDPrefix:      "56fe8f8",
+				NewMode:           os.FileMode(0100755),
+			},
+		},
+		"newFile": {
+			Input: `diff --cc new.txt
+index 0000000,0000000..53c74cd
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@@ -1,0 -1,0 +1,1 @@@
+`,
+			Output: &File{
+				NewName:           "new.txt",
+				IsNew:             true,
+				IsCombined:        true,
+				ParentOIDPrefixes: []string{"0000000", "0000000"},
+				NewOIDPrefix:      "53c74cd",
+				NewMode:           os.FileMode(0100644),
+			},
+		},
+		"deletedFile": {
+			Input: `diff --cc old.txt
+index 975fbec,b680253..0000000
+deleted file mode 100644,100755
+--- a/old.txt
++++ /dev/null
+`,
+			Output: &File{
+				OldName:           "old.txt",
+				IsDelete:          true,
+				IsCombined:        true,
+				ParentOIDPrefixes: []string{"975fbec", "b680253"},
+				ParentModes:       []os.FileMode{os.FileMode(0100644), os.FileMode(0100755)},
+				NewOIDPrefix:      "0000000",
+			},
+		},
+		"quotedName": {
+			Input: `diff --cc "dir/file \"quoted\".txt"
+index 5f25ea0,2b51d8b..56fe8f8
+`,
+			Output: &File{
+				OldName:           `dir/file "quoted".txt`,
+				NewName:           `dir/file "quoted".txt`,
+				IsCombined:        true,
+				ParentOIDPrefixes: []string{"5f25ea0", "2b51d8b"},
+				NewOIDPrefix:      "56fe8f8",
+			},
+		},
+		"notCombined": {
+			Input: `diff --git a/file.txt b/file.txt
+`,
+			Output: nil,
+		},
+		"invalidIndex": {
+			Input: `diff --cc file.txt
+index 5f25ea0,2b51d8b
+`,
+			Err: true,
+		},
+		"invalidMode": {
+			Input: `diff --cc file.txt
+mode 100644,10064a..100755
+`,
+			Err: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTestParser(test.Input, true)
+
+			f, err := p.ParseCombinedFileHeader()
+			if test.Err {
+				if err == nil || err == io.EOF {
+					t.Fatalf("expected error parsing combined file header, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing combined file header: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.Output, f) {
+				t.Errorf("incorrect file\nexpected: %+v\nactual: %+v", test.Output, f)
+			}
+		})
+	}
+}
+
+func TestParseCombinedFragmentHeader(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Output *CombinedFragment
+		Err    bool
+	}{
+		"twoParents": {
+			Input: "@@@ -1,6 -2,5 +1,7 @@@\n",
+			Output: &CombinedFragment{
+				OldPositions: []int64{1, 2},
+				OldLines:     []int64{6, 5},
+				NewPosition:  1,
+				NewLines:     7,
+			},
+		},
+		"threeParents": {
+			Input: "@@@@ -1,6 -2,5 -3,4 +1,7 @@@@\n",
+			Output: &CombinedFragment{
+				OldPositions: []int64{1, 2, 3},
+				OldLines:     []int64{6, 5, 4},
+				NewPosition:  1,
+				NewLines:     7,
+			},
+		},
+		"trailingComment": {
+			Input: "@@@ -21,5 -21,5 +28,9 @@@ func test(n int) {\n",
+			Output: &CombinedFragment{
+				Comment:      "func test(n int) {",
+				OldPositions: []int64{21, 21},
+				OldLines:     []int64{5, 5},
+				NewPosition:  28,
+				NewLines:     9,
+			},
+		},
+		"textFragment": {
+			Input:  "@@ -1,6 +1,7 @@\n",
+			Output: nil,
+		},
+		"wrongParentCount": {
+			Input: "@@@ -1,6 -2,5 -3,4 +1,7 @@@\n",
+			Err:   true,
+		},
+		"missingNewRange": {
+			Input: "@@@ -1,6 -2,5 @@@\n",
+			Err:   true,
+		},
+		"incomplete": {
+			Input: "@@@ -1,6 -2,5 +1,7\n",
+			Err:   true,
+		},
+		"badNumbers": {
+			Input: "@@@ -1a,6 -2,5 +1,7 @@@\n",
+			Err:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTestParser(test.Input, true)
+
+			frag, err := p.ParseCombinedFragmentHeader()
+			if test.Err {
+				if err == nil || err == io.EOF {
+					t.Fatalf("expected error parsing header, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error parsing header: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.Output, frag) {
+				t.Errorf("incorrect fragment\nexpected: %+v\nactual: %+v", test.Output, frag)
+			}
+		})
+	}
+}
+
+func TestParseCombinedChunk(t *testing.T) {
+	tests := map[string]struct {
+		Input    string
+		Fragment CombinedFragment
+		Output   []CombinedLine
+		Err      bool
+	}{
+		"conflictResolution": {
+			Input: `  context line
+- ours
+ -theirs
+++resolved
+  context line
+`,
+			Fragment: CombinedFragment{
+				OldPositions: []int64{1, 1},
+				OldLines:     []int64{3, 3},
+				NewLines:     3,
+			},
+			Output: []CombinedLine{
+				{[]LineOp{OpContext, OpContext}, "context line\n"},
+				{[]LineOp{OpDelete, OpContext}, "ours\n"},
+				{[]LineOp{OpContext, OpDelete}, "theirs\n"},
+				{[]LineOp{OpAdd, OpAdd}, "resolved\n"},
+				{[]LineOp{OpContext, OpContext}, "context line\n"},
+			},
+		},
+		"changeFromOneParent": {
+			Input: `  context line
+ -old line
+ +new line
+`,
+			Fragment: CombinedFragment{
+				OldPositions: []int64{1, 1},
+				OldLines:     []int64{2, 2},
+				NewLines:     2,
+			},
+			Output: []CombinedLine{
+				{[]LineOp{OpContext, OpContext}, "context line\n"},
+				{[]LineOp{OpContext, OpDelete}, "old line\n"},
+				{[]LineOp{OpContext, OpAdd}, "new line\n"},
+			},
+		},
+		"threeParents": {
+			Input: `+  new line
+ - removed line
+`,
+			Fragment: CombinedFragment{
+				OldPositions: []int64{1, 1, 1},
+				OldLines:     []int64{0, 2, 1},
+				NewLines:     1,
+			},
+			Output: []CombinedLine{
+				{[]LineOp{OpAdd, OpContext, OpContext}, "new line\n"},
+				{[]LineOp{OpContext, OpDelete, OpContext}, "removed line\n"},
+			},
+		},
+		"noNewlineMarker": {
+			Input: `- old line
+++new line
+\ No newline at end of file
+`,
+			Fragment: CombinedFragment{
+				OldPositions: []int64{1, 1},
+				OldLines:     []int64{1, 0},
+				NewLines:     1,
+			},
+			Output: []CombinedLine{
+				{[]LineOp{OpDelete, OpContext}, "old line\n"},
+				{[]LineOp{OpAdd, OpAdd}, "new line"},
+			},
+		},
+		"addedAndRemoved": {
+			Input: `+-line
+`,
+			Fragment: CombinedFragment{
+				OldPositions: []int64{1, 1},
+				OldLines:     []int64{1, 1},
+				NewLines:     1,
+			},
+			Err: true,
+		},
+		"invalidOperation": {
+			Input: ` *line
+`,
+			Fragment: CombinedFragment{
+				OldPositions: []int64{1, 1},
+				OldLines:     []int64{1, 1},
+				NewLines:     1,
+			},
+			Err: true,
+		},
+		"miscountedParent": {
+			Input: `- old line
+++new line
+`,
+			Fragment: CombinedFragment{
+				OldPositions: []int64{1, 1},
+				OldLines:     []int64{1, 1},
+				NewLines:     1,
+			},
+			Err: true,
+		},
+		"onlyContext": {
+			Input: `  context line
+`,
+			Fragment: CombinedFragment{
+				OldPositions: []int64{1, 1},
+				OldLines:     []int64{1, 1},
+				NewLines:     1,
+			},
+			Err: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTestParser(test.Input, true)
+
+			frag := test.Fragment
+			err := p.ParseCombinedChunk(&frag)
+			if test.Err {
+				if err == nil || err == io.EOF {
+					t.Fatalf("expected error parsing combined chunk, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error parsing combined chunk: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.Output, frag.Lines) {
+				t.Errorf("incorrect lines\nexpected: %+v\nactual: %+v", test.Output, frag.Lines)
+			}
+		})
+	}
+}
+
+func TestCombinedFragmentParent(t *testing.T) {
+	const patch = `diff --cc f
+index 5f25ea0,2b51d8b..56fe8f8
+--- a/f
++++ b/f
+@@@ -1,6 -1,6 +1,6 @@@
+  1
+  2
+- main
+ -side
+++resolved
+  4
+  5
+  6
+`
+
+	files, err := Parse(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	parsed := collectFiles(files)
+	if len(parsed) != 1 || len(parsed[0].CombinedFragments) != 1 {
+		t.Fatalf("patch should contain exactly one file with one combined fragment")
+	}
+	frag := parsed[0].CombinedFragments[0]
+
+	for i, expected := range []string{
+		"@@ -1,6 +1,6 @@\n 1\n 2\n-main\n+resolved\n 4\n 5\n 6\n",
+		"@@ -1,6 +1,6 @@\n 1\n 2\n-side\n+resolved\n 4\n 5\n 6\n",
+	} {
+		parent := frag.Parent(i)
+		if err := parent.Validate(); err != nil {
+			t.Errorf("parent %d: invalid fragment: %v", i, err)
+		}
+		if out := parent.String(); out != expected {
+			t.Errorf("parent %d: incorrect fragment\nexpected:\n%s\nactual:\n%s", i, expected, out)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package gitdiff
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+)
+
+// ParseCommits parses the output of "git log -p" or "git show", which
+// contains a series of commits with the files changed by each one, and sends
+// each commit on the returned channel as a *Patch with its header and files.
+// Options configure the parsing of files in the same way as for ParseAll.
+// With "git log -p --stat", the message of each commit ends at the "---"
+// line before the diffstat, and the diffstat is not part of the header.
+//
+// A commit starts at a "commit" line with the commit ID, like "commit
+// 1234abcd", or at a mailbox separator line, like the messages generated by
+// "git log --pretty=email". Unlike Parse, ParseCommits does not depend on the
+// content between files to find the header of each file. If the input has
+// files before the first commit, ParseCommits sends them first in a patch
+// with a nil Header.
+//
+// If an error occurs while parsing a commit, ParseCommits sends all commits
+// parsed before the error, calls the function set by WithErrorHandler with
+// the error, and closes the channel. The line numbers of any *ParseError are
+// relative to the start of the input.
+func ParseCommits(r io.Reader, opts ...ParseOption) (<-chan *Patch, error) {
+	return ParseCommitsContext(context.Background(), r, opts...)
+}
+
+// ParseCommitsContext is like ParseCommits, but stops parsing and closes the
+// channel of patches when ctx is done.
+func ParseCommitsContext(ctx context.Context, r io.Reader, opts ...ParseOption) (<-chan *Patch, error) {
+	var config parser
+	for _, opt := range opts {
+		opt(&config)
+	}
+	// report errors once, after parsing stops
+	opts = append(opts[:len(opts):len(opts)], WithErrorHandler(nil))
+
+	out := make(chan *Patch)
+	go func() {
+		defer close(out)
+		err := splitMessages(r, isCommitSeparator, func(msg string, start int64) error {
+			var patch *Patch
+			if isCommitSeparator(firstLine(msg)) {
+				var err error
+				if patch, err = parseMailboxMessage(msg, start, opts); err != nil {
+					return err
+				}
+			} else {
+				// files before the first commit do not have a header
+				files, _, err := ParseAll(strings.NewReader(msg), opts...)
+				if err != nil {
+					return err
+				}
+				if len(files) == 0 {
+					return nil
+				}
+				patch = &Patch{Files: files}
+			}
+
+			select {
+			case out <- patch:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			config.handleError(err)
+		}
+	}()
+	return out, nil
+}
+
+// isCommitSeparator returns true if line starts a new commit in the output of
+// "git log", either with a "commit" line followed by a possibly abbreviated
+// commit ID or with a mailbox separator.
+func isCommitSeparator(line string) bool {
+	if isMailboxSeparator(line) {
+		return true
+	}
+	if !strings.HasPrefix(line, prettyHeaderPrefix) {
+		return false
+	}
+
+	fields := strings.Fields(line[len(prettyHeaderPrefix):])
+	if len(fields) == 0 || len(fields[0]) < 4 {
+		return false
+	}
+	return validateOID(fields[0], HashUnknown) == nil
+}
+
+// hasCommitSeparator returns true if any line of s starts a new commit.
+func hasCommitSeparator(s string) bool {
+	for len(s) > 0 {
+		line := firstLine(s)
+		if isCommitSeparator(line) {
+			return true
+		}
+		s = s[len(line):]
+	}
+	return false
+}
+
+// firstLine returns the first line of s, including the newline.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i+1]
+	}
+	return s
+}
+
+// splitMessages reads lines from r and calls fn with each message that
+// begins at a line for which isSeparator returns true, as well as any content
+// before the first separator, along with the zero-indexed line where the
+// message starts. It skips messages that only contain whitespace.
+func splitMessages(r io.Reader, isSeparator func(string) bool, fn func(msg string, start int64) error) error {
+	br, ok := r.(stringReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	var msg strings.Builder
+	var lineno, start int64
+
+	flush := func() error {
+		defer msg.Reset()
+		if strings.TrimSpace(msg.String()) == "" {
+			return nil
+		}
+		return fn(msg.String(), start)
+	}
+
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			if isSeparator(line) {
+				if err := flush(); err != nil {
+					return err
+				}
+				start = lineno
+			}
+			msg.WriteString(line)
+			lineno++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return flush()
+}
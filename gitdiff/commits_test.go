@@ -0,0 +1,202 @@
+package gitdiff
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommits(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "log.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer f.Close()
+
+	ch, err := ParseCommits(f, WithErrorHandler(func(err error) {
+		t.Errorf("unexpected error parsing commits: %v", err)
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error parsing commits: %v", err)
+	}
+
+	var patches []*Patch
+	for patch := range ch {
+		patches = append(patches, patch)
+	}
+
+	expected := []struct {
+		SHA   string
+		Title string
+		Files []string
+	}{
+		{"1df6c9b6106a4da17dd7cb85ffa61dc66e7b2de9", "Add the first file", []string{"file1.txt"}},
+		{"ae91f56ab9470fd63b36461c9218328d5b5cc0a1", "Change two files", []string{"file1.txt", "file2.txt"}},
+		{"41434448fe2a2c9866c8eead7b71bee185e43815", "Add a line", []string{"file2.txt"}},
+	}
+	if len(patches) != len(expected) {
+		t.Fatalf("incorrect number of patches: expected %d, actual %d", len(expected), len(patches))
+	}
+	for i, patch := range patches {
+		if patch.Header == nil {
+			t.Fatalf("patch %d has no header", i)
+		}
+		if patch.Header.SHA != expected[i].SHA || patch.Header.Title != expected[i].Title {
+			t.Errorf("incorrect header for patch %d: %s %q", i, patch.Header.SHA, patch.Header.Title)
+		}
+
+		var names []string
+		for _, f := range patch.Files {
+			names = append(names, f.NewName)
+			if f.PatchHeader != patch.Header {
+				t.Errorf("file %s in patch %d has a different header", f.NewName, i)
+			}
+		}
+		if !reflect.DeepEqual(expected[i].Files, names) {
+			t.Errorf("incorrect files for patch %d\nexpected: %v\n  actual: %v", i, expected[i].Files, names)
+		}
+	}
+}
+
+func TestParseCommitsStat(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "log_stat.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer f.Close()
+
+	ch, err := ParseCommits(f, WithErrorHandler(func(err error) {
+		t.Errorf("unexpected error parsing commits: %v", err)
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error parsing commits: %v", err)
+	}
+
+	var patches []*Patch
+	for patch := range ch {
+		patches = append(patches, patch)
+	}
+
+	expected := []struct {
+		SHA   string
+		Title string
+		Body  string
+		Files []string
+	}{
+		{"ae2a3b17367cf2f8829d5f80ee6fd96127ad3816", "third", "", []string{"a"}},
+		{"1dde4b7b0d7455afa5650b425dedef799b94e105", "second", "With a body.", []string{"a", "b"}},
+		{"1c49962eba034eb6ca47d4452ee3ee4c31a246fc", "first", "", []string{"a"}},
+	}
+	if len(patches) != len(expected) {
+		t.Fatalf("incorrect number of patches: expected %d, actual %d", len(expected), len(patches))
+	}
+	for i, patch := range patches {
+		h := patch.Header
+		if h == nil {
+			t.Fatalf("patch %d has no header", i)
+		}
+		if h.SHA != expected[i].SHA || h.Title != expected[i].Title || h.Body != expected[i].Body {
+			t.Errorf("incorrect header for patch %d: %s %q %q", i, h.SHA, h.Title, h.Body)
+		}
+
+		var names []string
+		for _, f := range patch.Files {
+			names = append(names, f.NewName)
+		}
+		if !reflect.DeepEqual(expected[i].Files, names) {
+			t.Errorf("incorrect files for patch %d\nexpected: %v\n  actual: %v", i, expected[i].Files, names)
+		}
+	}
+}
+
+func TestParseCommitsWithoutHeader(t *testing.T) {
+	patch := "diff --git a/file.txt b/file.txt\n" +
+		"--- a/file.txt\n" +
+		"+++ b/file.txt\n" +
+		"@@ -1 +1 @@\n" +
+		"-old\n" +
+		"+new\n" +
+		"commit 1df6c9b6106a4da17dd7cb85ffa61dc66e7b2de9\n" +
+		"Author: Morton Haypenny <dev@example.com>\n" +
+		"Date:   Sat Apr 11 15:21:23 2020 -0700\n" +
+		"\n" +
+		"    Empty commit\n"
+
+	ch, _ := ParseCommits(strings.NewReader(patch))
+
+	var patches []*Patch
+	for p := range ch {
+		patches = append(patches, p)
+	}
+	if len(patches) != 2 {
+		t.Fatalf("incorrect number of patches: expected 2, actual %d", len(patches))
+	}
+	if patches[0].Header != nil || len(patches[0].Files) != 1 {
+		t.Errorf("incorrect first patch: %+v", patches[0])
+	}
+	if patches[1].Header == nil || patches[1].Header.Title != "Empty commit" || len(patches[1].Files) != 0 {
+		t.Errorf("incorrect second patch: %+v", patches[1])
+	}
+}
+
+func TestParseCommitsError(t *testing.T) {
+	patch := "commit 1df6c9b6106a4da17dd7cb85ffa61dc66e7b2de9\n" +
+		"Author: Morton Haypenny <dev@example.com>\n" +
+		"\n" +
+		"    First commit\n" +
+		"\n" +
+		"commit ae91f56ab9470fd63b36461c9218328d5b5cc0a1\n" +
+		"Author: Morton Haypenny <dev@example.com>\n" +
+		"\n" +
+		"    Second commit\n" +
+		"\n" +
+		"diff --git a/file.txt b/file.txt\n" +
+		"--- a/file.txt\n" +
+		"+++ b/file.txt\n" +
+		"@@ -1 +1 @@\n" +
+		"*old\n"
+
+	var errs []error
+	ch, _ := ParseCommits(strings.NewReader(patch), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+
+	var patches []*Patch
+	for p := range ch {
+		patches = append(patches, p)
+	}
+	if len(patches) != 1 || patches[0].Header.Title != "First commit" {
+		t.Fatalf("incorrect patches before error: %+v", patches)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, but got %d", len(errs))
+	}
+	var perr *ParseError
+	if !errors.As(errs[0], &perr) {
+		t.Fatalf("expected parse error, but got %v", errs[0])
+	}
+	if perr.Line != 15 {
+		t.Errorf("incorrect error line: expected 15, actual %d", perr.Line)
+	}
+}
+
+func TestIsCommitSeparator(t *testing.T) {
+	tests := map[string]bool{
+		"commit 1df6c9b6106a4da17dd7cb85ffa61dc66e7b2de9\n":                true,
+		"commit 1df6c9b6106a4da17dd7cb85ffa61dc66e7b2de9 (HEAD -> main)\n": true,
+		"commit 1df6c9b\n": true,
+		"From 1df6c9b6106a4da17dd7cb85ffa61dc66e7b2de9 Mon Sep 17 00:00:00 2001\n": true,
+		"commit messages can mention commit abc\n":                                 false,
+		"    commit 1df6c9b6106a4da17dd7cb85ffa61dc66e7b2de9\n":                    false,
+		"commit\n": false,
+	}
+	for line, expected := range tests {
+		if actual := isCommitSeparator(line); actual != expected {
+			t.Errorf("incorrect result for %q: expected %t, actual %t", line, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package gitdiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Commute swaps the order of two patches, where b applies to the result of
+// a. It returns files equivalent to b that apply to the original content and
+// files equivalent to a that apply after them, so that applying the new b
+// and then the new a produces the same result as applying a and then b. This
+// is the operation that lets patch queue tools move a patch before another.
+//
+// Patches commute if they do not change overlapping or adjacent lines of the
+// same file. Commute rewrites the positions and context lines of the
+// fragments of files changed by both patches and clears their object IDs,
+// which no longer describe the intermediate content. It follows renames in a,
+// but files created, deleted, renamed, or copied by b, files created or
+// deleted by a, and binary files changed by both patches do not commute.
+//
+// If the patches do not commute, Commute returns a Conflict error.
+func Commute(a, b []*File) (newB, newA []*File, err error) {
+	if a, err = loadedFiles(a); err != nil {
+		return nil, nil, err
+	}
+	if b, err = loadedFiles(b); err != nil {
+		return nil, nil, err
+	}
+
+	results := make(map[string]int)
+	freed := make(map[string]bool)
+	for i, fa := range a {
+		if fa.IsDelete {
+			results[fa.OldName] = i
+		} else {
+			results[fa.NewName] = i
+		}
+		if fa.IsDelete || fa.IsRename {
+			freed[fa.OldName] = true
+		}
+	}
+
+	newA = append(newA, a...)
+	for _, fb := range b {
+		name := fb.OldName
+		if fb.IsNew {
+			name = fb.NewName
+		}
+		if (fb.IsNew || fb.IsRename || fb.IsCopy) && freed[fb.NewName] {
+			return nil, nil, commuteConflict(fb.NewName)
+		}
+
+		i, ok := results[name]
+		if !ok {
+			newB = append(newB, fb)
+			continue
+		}
+
+		cb, ca, err := commuteFile(name, newA[i], fb)
+		if err != nil {
+			return nil, nil, err
+		}
+		newB = append(newB, cb)
+		newA[i] = ca
+	}
+	return newB, newA, nil
+}
+
+func commuteConflict(name string) error {
+	return fmt.Errorf("%s: %w", name, &Conflict{"changes do not commute"})
+}
+
+// commuteFile swaps fa and fb, which change the same file.
+func commuteFile(name string, fa, fb *File) (*File, *File, error) {
+	if fa.IsNew || fa.IsDelete || fa.IsCopy || fb.IsNew || fb.IsDelete || fb.IsRename || fb.IsCopy || fa.IsBinary || fb.IsBinary {
+		return nil, nil, commuteConflict(name)
+	}
+
+	aMode, bMode := changesMode(fa), changesMode(fb)
+	if aMode && bMode {
+		return nil, nil, commuteConflict(name)
+	}
+
+	cb, ca := *fb, *fa
+	cb.OldName, cb.NewName = fa.OldName, fa.OldName
+	cb.OldOIDPrefix, cb.NewOIDPrefix = "", ""
+	ca.OldOIDPrefix, ca.NewOIDPrefix = "", ""
+	switch {
+	case aMode:
+		cb.OldMode, cb.NewMode = fa.OldMode, fa.OldMode
+	case bMode:
+		ca.OldMode, ca.NewMode = fb.NewMode, fb.NewMode
+	}
+
+	mid, err := commuteBase(name, fa, fb)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var orig, final bytes.Buffer
+	if err := Apply(&orig, strings.NewReader(strings.Join(mid, "")), fa, WithReverse()); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := Apply(&final, strings.NewReader(strings.Join(mid, "")), fb); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	origLines, finalLines := splitLines(orig.String()), splitLines(final.String())
+
+	// the changes of each patch in the coordinates of the intermediate content
+	aHunks, bHunks := diffLines(origLines, mid), diffLines(mid, finalLines)
+
+	var swapped []string
+	next, delta := 0, 0
+	for _, hb := range bHunks {
+		for _, ha := range aHunks {
+			if hb.AStart <= ha.BEnd && ha.BStart <= hb.AEnd {
+				return nil, nil, commuteConflict(name)
+			}
+			if ha.BEnd <= hb.AStart {
+				delta += (ha.AEnd - ha.AStart) - (ha.BEnd - ha.BStart)
+			}
+		}
+		start := hb.AStart + delta
+		swapped = append(swapped, origLines[next:start]...)
+		swapped = append(swapped, finalLines[hb.BStart:hb.BEnd]...)
+		next, delta = start+(hb.AEnd-hb.AStart), 0
+	}
+	swapped = append(swapped, origLines[next:]...)
+
+	content := strings.Join(swapped, "")
+	if cb.TextFragments, err = partialFragments(orig.String(), content); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if ca.TextFragments, err = partialFragments(content, final.String()); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &cb, &ca, nil
+}
+
+// changesMode returns true if f changes the mode of the file. Files without
+// a new mode keep the old mode.
+func changesMode(f *File) bool {
+	return f.NewMode != 0 && f.NewMode != f.OldMode
+}
+
+// commuteBase reconstructs the intermediate content of a file from the new
+// lines of the fragments in fa and the old lines of the fragments in fb.
+// Lines that are not in either patch are replaced by unique placeholders.
+func commuteBase(name string, fa, fb *File) ([]string, error) {
+	known := make(map[int64]string)
+	var size int64
+	add := func(frags []*TextFragment, useOld bool) error {
+		for _, frag := range frags {
+			start := fragmentStart(frag.NewPosition, frag.NewLines)
+			if useOld {
+				start = fragmentStart(frag.OldPosition, frag.OldLines)
+			}
+
+			i := start - 1
+			for _, line := range frag.Lines {
+				if (useOld && !line.Old()) || (!useOld && !line.New()) {
+					continue
+				}
+				if l, ok := known[i]; ok && l != line.Line {
+					return fmt.Errorf("%s: %w", name, &Conflict{fmt.Sprintf("patches have different content for line %d", i+1)})
+				}
+				known[i] = line.Line
+				i++
+			}
+			if i > size {
+				size = i
+			}
+		}
+		return nil
+	}
+
+	if err := add(fa.TextFragments, false); err != nil {
+		return nil, err
+	}
+	if err := add(fb.TextFragments, true); err != nil {
+		return nil, err
+	}
+	return partialContent(known, size), nil
+}
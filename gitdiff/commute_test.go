@@ -0,0 +1,89 @@
+package gitdiff
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommute(t *testing.T) {
+	base := map[string][]byte{
+		"nums.txt":  []byte(numberLines(1, 30)),
+		"old.txt":   []byte(numberLines(1, 6)),
+		"other.txt": []byte("x\n"),
+	}
+
+	a := parseTestPatch(t, "commute_a.patch")
+	b := parseTestPatch(t, "commute_b.patch")
+
+	newB, newA, err := Commute(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error commuting patches: %v", err)
+	}
+
+	var out strings.Builder
+	for _, f := range append(newB, newA...) {
+		out.WriteString(f.String())
+	}
+	expected, err := os.ReadFile(filepath.Join("testdata", "commute.patch"))
+	if err != nil {
+		t.Fatalf("failed to read expected patch: %v", err)
+	}
+	if out.String() != string(expected) {
+		t.Errorf("incorrect commuted patches\nexpected:\n%s\nactual:\n%s", expected, out.String())
+	}
+
+	apply := func(files ...[]*File) map[string][]byte {
+		result := base
+		for _, patch := range files {
+			if result, err = ApplyToMap(result, patch); err != nil {
+				t.Fatalf("unexpected error applying patch: %v", err)
+			}
+		}
+		return result
+	}
+	if !reflect.DeepEqual(apply(a, b), apply(newB, newA)) {
+		t.Errorf("applying commuted patches does not produce the same result")
+	}
+}
+
+func TestCommuteConflict(t *testing.T) {
+	tests := map[string]struct {
+		A, B string
+	}{
+		"overlap": {
+			A: "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			B: "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-B\n+b2\n c\n",
+		},
+		"adjacent": {
+			A: "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			B: "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n B\n-c\n+C\n",
+		},
+		"createdFile": {
+			A: "diff --git a/f b/f\nnew file mode 100644\n--- /dev/null\n+++ b/f\n@@ -0,0 +1 @@\n+a\n",
+			B: "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+		"reusedName": {
+			A: "diff --git a/f b/f\ndeleted file mode 100644\n--- a/f\n+++ /dev/null\n@@ -1 +0,0 @@\n-a\n",
+			B: "diff --git a/g b/f\nsimilarity index 100%\nrename from g\nrename to f\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a, _, err := ParseAll(strings.NewReader(test.A))
+			if err != nil {
+				t.Fatalf("unexpected error parsing first patch: %v", err)
+			}
+			b, _, err := ParseAll(strings.NewReader(test.B))
+			if err != nil {
+				t.Fatalf("unexpected error parsing second patch: %v", err)
+			}
+
+			_, _, err = Commute(a, b)
+			assertError(t, &Conflict{}, err, "commuting patches")
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package gitdiff
+
+import (
+	"math"
+	"strings"
+)
+
+// WithCompactLines configures Parse to call Compact on each file, reducing
+// the memory used by the lines of large patches. It has no effect on files
+// parsed with WithLazyFragments, which keep the content of their fragments in
+// the format of the patch until LoadFragments parses them.
+func WithCompactLines() ParseOption {
+	return func(p *parser) {
+		p.compact = true
+	}
+}
+
+// Compact stores the text fragments of f in a compact form until they are
+// loaded again. The compact form keeps the content of all lines in a single
+// buffer, with identical lines sharing the same content, and records the
+// operation and the offsets of each line in the buffer, instead of a string
+// for each Line. This uses less memory for patches with many short or
+// repeated lines.
+//
+// Like a file parsed with WithLazyFragments, a compacted file has no
+// TextFragments until LoadFragments restores them, and the functions and
+// methods that use fragments load them as needed. Loaded lines refer to the
+// shared buffer, so loading does not copy their content. Compact does
+// nothing for files without text fragments or with lazy fragments that are
+// not loaded.
+func (f *File) Compact() {
+	if f.lazy != nil || len(f.TextFragments) == 0 {
+		return
+	}
+
+	offsets := make(map[string]int)
+	size, n := 0, 0
+	for _, frag := range f.TextFragments {
+		for _, line := range frag.Lines {
+			if _, ok := offsets[line.Line]; !ok {
+				offsets[line.Line] = size
+				size += len(line.Line)
+			}
+		}
+		n += len(frag.Lines)
+	}
+	if size > math.MaxUint32 {
+		return
+	}
+
+	// unique lines have increasing offsets in order of their first use, so
+	// the first use of each line is the one at the end of the buffer
+	var b strings.Builder
+	b.Grow(size)
+	c := &compactFragments{
+		frags:  make([]compactFragment, len(f.TextFragments)),
+		ops:    make([]uint8, 0, n),
+		starts: make([]uint32, 0, n),
+		ends:   make([]uint32, 0, n),
+	}
+	for i, frag := range f.TextFragments {
+		c.frags[i] = compactFragment{header: *frag, lines: len(frag.Lines)}
+		c.frags[i].header.Lines = nil
+
+		for _, line := range frag.Lines {
+			start := offsets[line.Line]
+			if start == b.Len() {
+				b.WriteString(line.Line)
+			}
+			c.ops = append(c.ops, uint8(line.Op))
+			c.starts = append(c.starts, uint32(start))
+			c.ends = append(c.ends, uint32(start+len(line.Line)))
+		}
+	}
+	c.data = b.String()
+
+	f.TextFragments = nil
+	f.lazy = &lazyFragments{compact: c}
+}
+
+// compactFragments is the compact form of the text fragments of a file. The
+// lines of all fragments are in order, and line i is data[starts[i]:ends[i]]
+// with the operation ops[i].
+type compactFragments struct {
+	frags  []compactFragment
+	ops    []uint8
+	starts []uint32
+	ends   []uint32
+	data   string
+}
+
+// compactFragment is a text fragment without its lines and the number of
+// lines it has.
+type compactFragment struct {
+	header TextFragment
+	lines  int
+}
+
+// fragments returns new text fragments with the lines from c.
+func (c *compactFragments) fragments() []*TextFragment {
+	frags := make([]*TextFragment, len(c.frags))
+	i := 0
+	for j, cf := range c.frags {
+		frag := cf.header
+		frag.Lines = make([]Line, cf.lines)
+		for k := range frag.Lines {
+			frag.Lines[k] = Line{Op: LineOp(c.ops[i]), Line: c.data[c.starts[i]:c.ends[i]]}
+			i++
+		}
+		frags[j] = &frag
+	}
+	return frags
+}
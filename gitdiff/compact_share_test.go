@@ -0,0 +1,35 @@
+//go:build go1.20
+// +build go1.20
+
+package gitdiff
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestCompactSharedContent(t *testing.T) {
+	f := &File{
+		TextFragments: []*TextFragment{
+			{Lines: []Line{{OpContext, "a\n"}, {OpDelete, "b\n"}, {OpAdd, "c\n"}}},
+			{Lines: []Line{{OpContext, "c\n"}, {OpAdd, "a\n"}, {OpAdd, "\n"}}},
+		},
+	}
+	f.Compact()
+	if err := f.LoadFragments(); err != nil {
+		t.Fatalf("unexpected error loading fragments: %v", err)
+	}
+
+	first, second := f.TextFragments[0].Lines, f.TextFragments[1].Lines
+	if !sameString(first[0].Line, second[1].Line) || !sameString(first[2].Line, second[0].Line) {
+		t.Errorf("identical lines do not share content")
+	}
+	if uintptr(unsafe.Pointer(unsafe.StringData(first[1].Line))) != uintptr(unsafe.Pointer(unsafe.StringData(first[0].Line)))+uintptr(len(first[0].Line)) {
+		t.Errorf("lines are not stored in one buffer")
+	}
+}
+
+// sameString returns true if a and b have the same content in memory.
+func sameString(a, b string) bool {
+	return unsafe.StringData(a) == unsafe.StringData(b) && len(a) == len(b)
+}
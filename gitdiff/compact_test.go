@@ -0,0 +1,90 @@
+package gitdiff
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	expected := parseTestPatch(t, "apply_fs.patch")
+	files := parseTestPatch(t, "apply_fs.patch", WithCompactLines())
+	for i, f := range files {
+		if len(f.TextFragments) > 0 {
+			t.Errorf("file %d has text fragments before loading", i)
+		}
+		if err := f.LoadFragments(); err != nil {
+			t.Fatalf("unexpected error loading fragments: %v", err)
+		}
+	}
+	if !reflect.DeepEqual(expected, files) {
+		t.Errorf("incorrect files after loading\nexpected: %+v\n  actual: %+v", expected, files)
+	}
+
+	f := &File{
+		TextFragments: []*TextFragment{
+			{OldPosition: 1, LinesAdded: 1, LinesDeleted: 1, Lines: []Line{{OpContext, "a\n"}, {OpDelete, "b\n"}, {OpAdd, "c\n"}}},
+			{OldPosition: 5, LinesAdded: 2, Lines: []Line{{OpContext, "c\n"}, {OpAdd, "a\n"}, {OpAdd, "\n"}}},
+		},
+	}
+	expectedFrags := f.TextFragments
+	f.Compact()
+	if f.TextFragments != nil {
+		t.Fatalf("compacted file has text fragments: %v", f.TextFragments)
+	}
+
+	// functions that use the fragments load them without modifying the file
+	if s := f.Stat(); s.LinesAdded != 3 || s.LinesDeleted != 1 {
+		t.Errorf("incorrect stat for compacted file: %+v", s)
+	}
+	if f.TextFragments != nil {
+		t.Fatalf("compacted file has text fragments after Stat")
+	}
+
+	if err := f.LoadFragments(); err != nil {
+		t.Fatalf("unexpected error loading fragments: %v", err)
+	}
+	if !reflect.DeepEqual(expectedFrags, f.TextFragments) {
+		t.Errorf("incorrect fragments after loading\nexpected: %+v\n  actual: %+v", expectedFrags, f.TextFragments)
+	}
+}
+
+func BenchmarkCompact(b *testing.B) {
+	var patch strings.Builder
+	patch.WriteString("diff --git a/file.go b/file.go\n--- a/file.go\n+++ b/file.go\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&patch, "@@ -%d,6 +%d,6 @@ func f%d() {\n", i*10+1, i*10+1, i)
+		fmt.Fprintf(&patch, " \tif err != nil {\n \t\treturn err\n \t}\n-\tx := %d\n+\tx := %d\n \n \t}\n", i, i+1)
+	}
+	input := patch.String()
+
+	for _, test := range []struct {
+		Name    string
+		Options []ParseOption
+	}{
+		{"lines", nil},
+		{"compact", []ParseOption{WithCompactLines()}},
+	} {
+		b.Run(test.Name, func(b *testing.B) {
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+
+				files, _, err := ParseAll(strings.NewReader(input), test.Options...)
+				if err != nil {
+					b.Fatalf("unexpected error parsing patch: %v", err)
+				}
+
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				retained += after.HeapAlloc - before.HeapAlloc
+				runtime.KeepAlive(files)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+		})
+	}
+}
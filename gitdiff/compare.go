@@ -0,0 +1,236 @@
+package gitdiff
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// DifferenceKind is the category of a Difference between two files.
+type DifferenceKind int
+
+const (
+	// DifferenceMetadata indicates that the files have different names,
+	// operations, modes, object IDs, or scores
+	DifferenceMetadata DifferenceKind = iota + 1
+	// DifferenceContent indicates that the files make different changes to
+	// the content of the file
+	DifferenceContent
+	// DifferenceContext indicates that the text fragments of the files make
+	// the same changes, but with different context lines or fragment headers
+	DifferenceContext
+)
+
+func (k DifferenceKind) String() string {
+	switch k {
+	case DifferenceMetadata:
+		return "metadata"
+	case DifferenceContent:
+		return "content"
+	case DifferenceContext:
+		return "context"
+	}
+	return "unknown"
+}
+
+// Difference describes a way in which two files differ.
+type Difference struct {
+	Kind DifferenceKind
+
+	// OldLine is the one-indexed line in the original file where the changes
+	// of the files differ, for content differences in text fragments. It is
+	// zero for other differences.
+	OldLine int64
+
+	// Message describes the difference, like "new mode 100644 != 100755"
+	Message string
+}
+
+func (d Difference) String() string {
+	return d.Kind.String() + ": " + d.Message
+}
+
+// CompareOption configures Compare and Equal.
+type CompareOption func(*comparer)
+
+// WithIgnoreContext configures Compare to ignore differences in the context
+// lines and the function names of text fragments that make the same changes.
+func WithIgnoreContext() CompareOption {
+	return func(c *comparer) {
+		c.ignoreContext = true
+	}
+}
+
+// WithIgnoreOIDs configures Compare to ignore the object IDs and the hash
+// algorithm of files, like when comparing versions of a patch that are based
+// on different commits.
+func WithIgnoreOIDs() CompareOption {
+	return func(c *comparer) {
+		c.ignoreOIDs = true
+	}
+}
+
+type comparer struct {
+	ignoreContext bool
+	ignoreOIDs    bool
+
+	diffs []Difference
+}
+
+// Equal returns true if a and b describe the same change in the same way,
+// according to Compare. It returns false if the fragments of either file can
+// not be loaded.
+func Equal(a, b *File, opts ...CompareOption) bool {
+	diffs, err := Compare(a, b, opts...)
+	return err == nil && len(diffs) == 0
+}
+
+// Compare compares the changes described by a and b and returns their
+// differences, like for test assertions or to review the changes between two
+// versions of a patch. Differences in the metadata of the files are reported
+// first, followed by differences in their content. Text fragments are
+// compared by their changes, without context, so fragments that make the
+// same changes with different context report a single DifferenceContext,
+// while fragments that make different changes report a DifferenceContent for
+// each change that differs. Compare does not compare the headers and labels
+// of the files.
+//
+// Compare loads the fragments of files parsed with WithLazyFragments and
+// returns an error if loading fails.
+func Compare(a, b *File, opts ...CompareOption) ([]Difference, error) {
+	if err := a.LoadFragments(); err != nil {
+		return nil, err
+	}
+	if err := b.LoadFragments(); err != nil {
+		return nil, err
+	}
+
+	c := &comparer{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.compareMetadata(a, b)
+	c.compareContent(a, b)
+	return c.diffs, nil
+}
+
+func (c *comparer) add(kind DifferenceKind, line int64, format string, args ...interface{}) {
+	c.diffs = append(c.diffs, Difference{Kind: kind, OldLine: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *comparer) compareMetadata(a, b *File) {
+	compareString := func(field, x, y string) {
+		if x != y {
+			c.add(DifferenceMetadata, 0, "%s %q != %q", field, x, y)
+		}
+	}
+	compareBool := func(field string, x, y bool) {
+		if x != y {
+			c.add(DifferenceMetadata, 0, "%s %t != %t", field, x, y)
+		}
+	}
+	compareMode := func(field string, x, y os.FileMode) {
+		if x != y {
+			c.add(DifferenceMetadata, 0, "%s %o != %o", field, x, y)
+		}
+	}
+
+	compareString("old name", a.OldName, b.OldName)
+	compareString("new name", a.NewName, b.NewName)
+	compareBool("new file", a.IsNew, b.IsNew)
+	compareBool("deleted file", a.IsDelete, b.IsDelete)
+	compareBool("copy", a.IsCopy, b.IsCopy)
+	compareBool("rename", a.IsRename, b.IsRename)
+	compareMode("old mode", a.OldMode, b.OldMode)
+	compareMode("new mode", a.NewMode, b.NewMode)
+	if !c.ignoreOIDs {
+		compareString("old object ID", a.OldOIDPrefix, b.OldOIDPrefix)
+		compareString("new object ID", a.NewOIDPrefix, b.NewOIDPrefix)
+		if a.HashAlgorithm != b.HashAlgorithm {
+			c.add(DifferenceMetadata, 0, "hash algorithm %s != %s", a.HashAlgorithm, b.HashAlgorithm)
+		}
+	}
+	if a.Score != b.Score {
+		c.add(DifferenceMetadata, 0, "score %d != %d", a.Score, b.Score)
+	}
+	compareBool("binary", a.IsBinary, b.IsBinary)
+	compareBool("combined", a.IsCombined, b.IsCombined)
+	if !reflect.DeepEqual(a.ParentModes, b.ParentModes) || (!c.ignoreOIDs && !reflect.DeepEqual(a.ParentOIDPrefixes, b.ParentOIDPrefixes)) {
+		c.add(DifferenceMetadata, 0, "combined diff parents differ")
+	}
+}
+
+func (c *comparer) compareContent(a, b *File) {
+	if !reflect.DeepEqual(a.BinaryFragment, b.BinaryFragment) {
+		c.add(DifferenceContent, 0, "binary fragments differ")
+	}
+	if !reflect.DeepEqual(a.ReverseBinaryFragment, b.ReverseBinaryFragment) {
+		c.add(DifferenceContent, 0, "reverse binary fragments differ")
+	}
+	if !reflect.DeepEqual(a.Submodule, b.Submodule) {
+		c.add(DifferenceContent, 0, "submodule changes differ")
+	}
+	if !reflect.DeepEqual(a.CombinedFragments, b.CombinedFragments) {
+		c.add(DifferenceContent, 0, "combined fragments differ")
+	}
+	if !reflect.DeepEqual(a.WordFragments, b.WordFragments) {
+		c.add(DifferenceContent, 0, "word fragments differ")
+	}
+	c.compareTextFragments(a.TextFragments, b.TextFragments)
+}
+
+func (c *comparer) compareTextFragments(a, b []*TextFragment) {
+	if (len(a) == 0 && len(b) == 0) || reflect.DeepEqual(a, b) {
+		return
+	}
+
+	na, errA := normalizedFragments(a)
+	nb, errB := normalizedFragments(b)
+	if errA != nil || errB != nil {
+		c.add(DifferenceContent, 0, "text fragments differ")
+		return
+	}
+
+	n := len(c.diffs)
+	for len(na) > 0 || len(nb) > 0 {
+		var startA, startB int64
+		if len(na) > 0 {
+			startA = fragmentStart(na[0].OldPosition, na[0].OldLines)
+		}
+		if len(nb) > 0 {
+			startB = fragmentStart(nb[0].OldPosition, nb[0].OldLines)
+		}
+
+		switch {
+		case len(nb) == 0 || (len(na) > 0 && startA < startB):
+			c.add(DifferenceContent, startA, "change at old line %d is only in the first file", startA)
+			na = na[1:]
+		case len(na) == 0 || startB < startA:
+			c.add(DifferenceContent, startB, "change at old line %d is only in the second file", startB)
+			nb = nb[1:]
+		default:
+			if na[0].OldLines != nb[0].OldLines || !equalFragmentLines(na[0].Lines, nb[0].Lines) {
+				c.add(DifferenceContent, startA, "change at old line %d differs", startA)
+			}
+			na, nb = na[1:], nb[1:]
+		}
+	}
+
+	if len(c.diffs) == n && !c.ignoreContext {
+		c.add(DifferenceContext, 0, "text fragments make the same changes with different context")
+	}
+}
+
+// normalizedFragments returns copies of frags without context, as in the
+// result of Normalize.
+func normalizedFragments(frags []*TextFragment) ([]*TextFragment, error) {
+	f := &File{TextFragments: make([]*TextFragment, len(frags))}
+	for i, frag := range frags {
+		copied := *frag
+		f.TextFragments[i] = &copied
+	}
+	if err := Normalize(f); err != nil {
+		return nil, err
+	}
+	return f.TextFragments, nil
+}
@@ -0,0 +1,159 @@
+package gitdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	const base = `diff --git a/f.txt b/f.txt
+index 1c23fcc..40a1b33 100644
+--- a/f.txt
++++ b/f.txt
+@@ -1,5 +1,5 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+@@ -8,3 +8,4 @@
+ 8
+ 9
++nine a
+ 10
+`
+
+	tests := map[string]struct {
+		Patch   string
+		Options []CompareOption
+		Diffs   []Difference
+	}{
+		"equal": {
+			Patch: base,
+		},
+		"context": {
+			Patch: `diff --git a/f.txt b/f.txt
+index 1c23fcc..40a1b33 100644
+--- a/f.txt
++++ b/f.txt
+@@ -2,3 +2,3 @@ func main() {
+ 2
+-3
++three
+ 4
+@@ -9,2 +9,3 @@
+ 9
++nine a
+ 10
+`,
+			Diffs: []Difference{
+				{Kind: DifferenceContext, Message: "text fragments make the same changes with different context"},
+			},
+		},
+		"ignoreContext": {
+			Patch: `diff --git a/f.txt b/f.txt
+index 1c23fcc..40a1b33 100644
+--- a/f.txt
++++ b/f.txt
+@@ -3 +3 @@
+-3
++three
+@@ -9,0 +10 @@
++nine a
+`,
+			Options: []CompareOption{WithIgnoreContext()},
+		},
+		"content": {
+			Patch: `diff --git a/f.txt b/f.txt
+index 1c23fcc..7b3c1d1 100644
+--- a/f.txt
++++ b/f.txt
+@@ -1,5 +1,5 @@
+ 1
+ 2
+-3
++THREE
+ 4
+ 5
+@@ -14,3 +14,2 @@
+ 14
+-15
+ 16
+`,
+			Diffs: []Difference{
+				{Kind: DifferenceMetadata, Message: `new object ID "40a1b33" != "7b3c1d1"`},
+				{Kind: DifferenceContent, OldLine: 3, Message: "change at old line 3 differs"},
+				{Kind: DifferenceContent, OldLine: 10, Message: "change at old line 10 is only in the first file"},
+				{Kind: DifferenceContent, OldLine: 15, Message: "change at old line 15 is only in the second file"},
+			},
+		},
+		"metadata": {
+			Patch: `diff --git a/f.txt b/g.txt
+similarity index 90%
+rename from f.txt
+rename to g.txt
+old mode 100644
+new mode 100755
+index 1c23fcc..40a1b33
+--- a/f.txt
++++ b/g.txt
+@@ -1,5 +1,5 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+@@ -8,3 +8,4 @@
+ 8
+ 9
++nine a
+ 10
+`,
+			Options: []CompareOption{WithIgnoreOIDs()},
+			Diffs: []Difference{
+				{Kind: DifferenceMetadata, Message: `new name "f.txt" != "g.txt"`},
+				{Kind: DifferenceMetadata, Message: "rename false != true"},
+				{Kind: DifferenceMetadata, Message: "new mode 0 != 100755"},
+				{Kind: DifferenceMetadata, Message: "score 0 != 90"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := parseTestFiles(t, base)[0]
+			b := parseTestFiles(t, test.Patch)[0]
+
+			diffs, err := Compare(a, b, test.Options...)
+			if err != nil {
+				t.Fatalf("unexpected error comparing files: %v", err)
+			}
+			if !reflect.DeepEqual(test.Diffs, diffs) {
+				t.Errorf("incorrect differences\nexpected: %v\n  actual: %v", test.Diffs, diffs)
+			}
+			if eq := Equal(a, b, test.Options...); eq != (len(test.Diffs) == 0) {
+				t.Errorf("incorrect equality: expected %t, actual %t", len(test.Diffs) == 0, eq)
+			}
+		})
+	}
+}
+
+func TestCompareLazy(t *testing.T) {
+	const patch = `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1 +1 @@
+-a
++b
+`
+	files, _, err := ParseAll(strings.NewReader(patch), WithLazyFragments())
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if !Equal(files[0], parseTestFiles(t, patch)[0]) {
+		t.Errorf("expected lazy file to equal parsed file")
+	}
+}
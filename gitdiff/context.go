@@ -0,0 +1,180 @@
+package gitdiff
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ReduceContext removes context lines from the text fragments of the file so
+// that each change has at most n lines of context before and after it, like
+// generating the patch with "git diff -U<n>". Like git, it splits fragments
+// where changes are separated by more than twice n context lines. The
+// fragments must be valid and in order.
+func (f *File) ReduceContext(n int) {
+	if n < 0 {
+		n = 0
+	}
+	if err := f.LoadFragments(); err != nil {
+		return
+	}
+
+	known := make(map[int64]string)
+	for _, frag := range f.TextFragments {
+		i := fragmentStart(frag.OldPosition, frag.OldLines) - 1
+		for _, line := range frag.Lines {
+			if line.Old() {
+				known[i] = line.Line
+				i++
+			}
+		}
+	}
+
+	f.TextFragments = recontext(f.TextFragments, int64(n), func(i int64) (string, bool) {
+		line, ok := known[i]
+		return line, ok
+	})
+}
+
+// ExpandContext adds or removes context lines in the text fragments of the
+// file so that each change has n lines of context before and after it, like
+// generating the patch with "git diff -U<n>". It reads the additional lines
+// from src, which must contain the original content of the file, and merges
+// fragments that overlap or touch after adding context. If the lines of a
+// fragment do not match src, ExpandContext returns a Conflict error and does
+// not modify the file. The fragments must be valid and in order.
+func (f *File) ExpandContext(n int, src io.Reader) error {
+	if n < 0 {
+		n = 0
+	}
+
+	if err := f.LoadFragments(); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	lines := splitLines(string(data))
+
+	for i, frag := range f.TextFragments {
+		pos := fragmentStart(frag.OldPosition, frag.OldLines) - 1
+		for _, line := range frag.Lines {
+			if !line.Old() {
+				continue
+			}
+			if pos >= int64(len(lines)) || lines[pos] != line.Line {
+				return &Conflict{fmt.Sprintf("fragment %d line does not match src line %d", i+1, pos+1)}
+			}
+			pos++
+		}
+	}
+
+	f.TextFragments = recontext(f.TextFragments, int64(n), func(i int64) (string, bool) {
+		if i < 0 || i >= int64(len(lines)) {
+			return "", false
+		}
+		return lines[i], true
+	})
+	return nil
+}
+
+// contextHunk is a run of changed lines in a fragment. Positions are
+// zero-indexed line numbers in the old and new content.
+type contextHunk struct {
+	oldStart, oldEnd int64
+	newStart         int64
+	lines            []Line
+	comment          string
+}
+
+// recontext creates new fragments for the changes in frags with n lines of
+// context, using oldLine to look up lines of the old content. Changes are in
+// the same fragment if there are at most 2*n context lines between them and
+// all of those lines are available.
+func recontext(frags []*TextFragment, n int64, oldLine func(i int64) (string, bool)) []*TextFragment {
+	var hunks []contextHunk
+	for _, frag := range frags {
+		oldPos := fragmentStart(frag.OldPosition, frag.OldLines) - 1
+		newPos := fragmentStart(frag.NewPosition, frag.NewLines) - 1
+
+		for i := 0; i < len(frag.Lines); {
+			if frag.Lines[i].Op == OpContext {
+				oldPos++
+				newPos++
+				i++
+				continue
+			}
+
+			h := contextHunk{oldStart: oldPos, newStart: newPos, comment: frag.Comment}
+			for ; i < len(frag.Lines) && frag.Lines[i].Op != OpContext; i++ {
+				line := frag.Lines[i]
+				if line.Old() {
+					oldPos++
+				} else {
+					newPos++
+				}
+				h.lines = append(h.lines, line)
+			}
+			h.oldEnd = oldPos
+			hunks = append(hunks, h)
+		}
+	}
+
+	available := func(start, end int64) bool {
+		for i := start; i < end; i++ {
+			if _, ok := oldLine(i); !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	var result []*TextFragment
+	for len(hunks) > 0 {
+		k := 1
+		for k < len(hunks) {
+			prev, next := hunks[k-1], hunks[k]
+			if next.oldStart-prev.oldEnd > 2*n || !available(prev.oldEnd, next.oldStart) {
+				break
+			}
+			k++
+		}
+		first, last := hunks[0], hunks[k-1]
+
+		start := first.oldStart
+		for start > first.oldStart-n && available(start-1, start) {
+			start--
+		}
+		end := last.oldEnd
+		for end < last.oldEnd+n && available(end, end+1) {
+			end++
+		}
+
+		var lines []Line
+		addContext := func(from, to int64) {
+			for i := from; i < to; i++ {
+				line, _ := oldLine(i)
+				lines = append(lines, Line{OpContext, line})
+			}
+		}
+
+		next := start
+		for _, h := range hunks[:k] {
+			addContext(next, h.oldStart)
+			lines = append(lines, h.lines...)
+			next = h.oldEnd
+		}
+		addContext(next, end)
+
+		frag := &TextFragment{Comment: first.comment}
+		frag.setLines(lines)
+		frag.OldPosition = fragmentPosition(start+1, frag.OldLines)
+		frag.NewPosition = fragmentPosition(first.newStart-(first.oldStart-start)+1, frag.NewLines)
+
+		result = append(result, frag)
+		hunks = hunks[k:]
+	}
+	return result
+}
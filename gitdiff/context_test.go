@@ -0,0 +1,127 @@
+package gitdiff
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFileReduceContext(t *testing.T) {
+	tests := map[string]struct {
+		Input    string
+		Context  int
+		Expected string
+	}{
+		"threeToOne": {
+			Input:    "context_u3.patch",
+			Context:  1,
+			Expected: "testdata/context_u1.patch",
+		},
+		"threeToZero": {
+			Input:    "context_u3.patch",
+			Context:  0,
+			Expected: "testdata/context_u0.patch",
+		},
+		"tenToThree": {
+			Input:    "context_u10.patch",
+			Context:  3,
+			Expected: "testdata/context_u3.patch",
+		},
+		"tenToOne": {
+			Input:    "context_u10.patch",
+			Context:  1,
+			Expected: "testdata/context_u1.patch",
+		},
+		"unchanged": {
+			Input:    "context_u3.patch",
+			Context:  3,
+			Expected: "testdata/context_u3.patch",
+		},
+		"moreThanAvailable": {
+			Input:    "context_u1.patch",
+			Context:  5,
+			Expected: "testdata/context_u1.patch",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := parseTestPatch(t, test.Input)[0]
+			f.ReduceContext(test.Context)
+			assertContextFile(t, test.Expected, f)
+		})
+	}
+}
+
+func TestFileExpandContext(t *testing.T) {
+	tests := map[string]struct {
+		Input    string
+		Context  int
+		Expected string
+	}{
+		"zeroToThree": {
+			Input:    "context_u0.patch",
+			Context:  3,
+			Expected: "testdata/context_u3.patch",
+		},
+		"oneToFive": {
+			Input:    "context_u1.patch",
+			Context:  5,
+			Expected: "testdata/context_u5.patch",
+		},
+		"threeToTen": {
+			Input:    "context_u3.patch",
+			Context:  10,
+			Expected: "testdata/context_u10.patch",
+		},
+		"fiveToZero": {
+			Input:    "context_u5.patch",
+			Context:  0,
+			Expected: "testdata/context_u0.patch",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src, err := os.Open("testdata/context.src")
+			if err != nil {
+				t.Fatalf("unexpected error opening source: %v", err)
+			}
+			defer src.Close()
+
+			f := parseTestPatch(t, test.Input)[0]
+			if err := f.ExpandContext(test.Context, src); err != nil {
+				t.Fatalf("unexpected error expanding context: %v", err)
+			}
+			assertContextFile(t, test.Expected, f)
+		})
+	}
+}
+
+func TestFileExpandContextConflict(t *testing.T) {
+	f := parseTestPatch(t, "context_u1.patch")[0]
+	before := f.String()
+
+	err := f.ExpandContext(3, strings.NewReader("1\n2\n3\n4\n5\n6\n"))
+	assertError(t, &Conflict{}, err, "expanding context with incorrect source")
+
+	if f.String() != before {
+		t.Errorf("file changed after failed expansion")
+	}
+}
+
+func assertContextFile(t *testing.T, expectedFile string, f *File) {
+	expected, err := os.ReadFile(expectedFile)
+	if err != nil {
+		t.Fatalf("unexpected error reading expected patch: %v", err)
+	}
+
+	for i, frag := range f.TextFragments {
+		if err := frag.Validate(); err != nil {
+			t.Errorf("fragment %d is invalid: %v", i, err)
+		}
+	}
+	if actual := f.String(); actual != string(expected) {
+		t.Errorf("incorrect patch\nexpected:\n%s\nactual:\n%s", expected, actual)
+	}
+}
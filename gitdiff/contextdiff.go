@@ -0,0 +1,246 @@
+package gitdiff
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	contextOldPrefix     = "*** "
+	contextNewPrefix     = "--- "
+	contextFragmentStart = "***************"
+)
+
+// ParseContextFileHeader parses the header of a file in a "copied context"
+// diff, like the output of "diff -c". It returns nil if the current line does
+// not start a context diff header.
+func (p *parser) ParseContextFileHeader() (*File, error) {
+	oldLine, newLine := p.Line(0), p.Line(1)
+	if !strings.HasPrefix(oldLine, contextOldPrefix) || !strings.HasPrefix(newLine, contextNewPrefix) {
+		return nil, nil
+	}
+	// heuristic: only a file header if followed by a fragment separator
+	if !strings.HasPrefix(p.Line(2), contextFragmentStart) {
+		return nil, nil
+	}
+
+	if err := p.Next(); err != nil {
+		return nil, err
+	}
+	if err := p.Next(); err != nil {
+		return nil, err
+	}
+	return p.parseTraditionalNames(oldLine, newLine, contextOldPrefix, contextNewPrefix)
+}
+
+// ParseContextFragments parses the fragments of a context diff until the
+// next file header or the end of the stream and converts them to text
+// fragments attached to the given file. It returns the number of fragments
+// that were added.
+func (p *parser) ParseContextFragments(f *File) (n int, err error) {
+	for strings.HasPrefix(p.Line(0), contextFragmentStart) {
+		if err := p.checkFragmentLimit(0, n); err != nil {
+			return n, err
+		}
+
+		frag, err := p.ParseContextFragment()
+		if err != nil {
+			return n, err
+		}
+
+		if f.IsNew && frag.OldLines > 0 {
+			return n, p.Errorf(-1, KindInconsistentFragment, "new file depends on old contents")
+		}
+		if f.IsDelete && frag.NewLines > 0 {
+			return n, p.Errorf(-1, KindInconsistentFragment, "deleted file still has contents")
+		}
+
+		f.TextFragments = append(f.TextFragments, frag)
+		n++
+	}
+	return n, nil
+}
+
+// contextLine is a line in one section of a context diff fragment.
+type contextLine struct {
+	op   byte
+	line string
+}
+
+// ParseContextFragment parses a single fragment of a context diff, which
+// lists the old lines and the new lines of the fragment in separate sections.
+// A section without changes may be omitted.
+func (p *parser) ParseContextFragment() (*TextFragment, error) {
+	comment := strings.TrimSpace(strings.TrimLeft(p.Line(0), "*"))
+	if err := p.Next(); err != nil {
+		if err == io.EOF {
+			return nil, p.Errorf(0, KindTruncatedFragment, "no content following fragment separator")
+		}
+		return nil, err
+	}
+
+	oldStart, oldEnd, err := p.parseContextRange("*** ", " ****")
+	if err != nil {
+		return nil, err
+	}
+	oldLines, err := p.parseContextSection("- !")
+	if err != nil {
+		return nil, err
+	}
+
+	newStart, newEnd, err := p.parseContextRange("--- ", " ----")
+	if err != nil {
+		return nil, err
+	}
+	newLines, err := p.parseContextSection("+ !")
+	if err != nil {
+		return nil, err
+	}
+
+	// an omitted section has the context lines of the other section
+	switch {
+	case len(oldLines) == 0:
+		oldLines = contextOnly(newLines)
+	case len(newLines) == 0:
+		newLines = contextOnly(oldLines)
+	}
+
+	lines, err := mergeContextSections(oldLines, newLines)
+	if err != nil {
+		return nil, p.Errorf(-1, KindInconsistentFragment, "%v", err)
+	}
+
+	frag := &TextFragment{Comment: comment}
+	frag.setLines(lines)
+	if frag.LinesAdded == 0 && frag.LinesDeleted == 0 {
+		return nil, p.Errorf(-1, KindEmptyFragment, "fragment contains no changes")
+	}
+
+	if frag.OldPosition, err = contextPosition(oldStart, oldEnd, frag.OldLines); err != nil {
+		return nil, p.Errorf(-1, KindTruncatedFragment, "fragment header miscounts old lines: %v", err)
+	}
+	if frag.NewPosition, err = contextPosition(newStart, newEnd, frag.NewLines); err != nil {
+		return nil, p.Errorf(-1, KindTruncatedFragment, "fragment header miscounts new lines: %v", err)
+	}
+	return frag, nil
+}
+
+// parseContextRange parses a range line like "*** 1,5 ****" and advances to
+// the next line. The end is -1 if the range only has one number.
+func (p *parser) parseContextRange(prefix, suffix string) (start, end int64, err error) {
+	line := strings.TrimSuffix(p.Line(0), "\n")
+	if !strings.HasPrefix(line, prefix) || !strings.HasSuffix(line, suffix) || len(line) < len(prefix)+len(suffix) {
+		return 0, 0, p.Errorf(0, KindFragmentHeader, "invalid fragment header")
+	}
+
+	parts := strings.SplitN(line[len(prefix):len(line)-len(suffix)], ",", 2)
+	if start, err = strconv.ParseInt(parts[0], 10, 64); err != nil || start < 0 {
+		return 0, 0, p.Errorf(0, KindFragmentHeader, "invalid fragment header: bad start of range: %s", parts[0])
+	}
+	end = -1
+	if len(parts) > 1 {
+		if end, err = strconv.ParseInt(parts[1], 10, 64); err != nil || end < start {
+			return 0, 0, p.Errorf(0, KindFragmentHeader, "invalid fragment header: bad end of range: %s", parts[1])
+		}
+	}
+
+	if err := p.Next(); err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseContextSection parses the lines of one section of a fragment, which
+// start with two spaces for context lines or with one of the operations in
+// ops followed by a space.
+func (p *parser) parseContextSection(ops string) ([]contextLine, error) {
+	var lines []contextLine
+	for {
+		line := p.Line(0)
+		switch {
+		case isNoNewlineMarker(line) && len(lines) > 0:
+			last := &lines[len(lines)-1]
+			last.line = strings.TrimSuffix(last.line, "\n")
+		case len(line) > 2 && line[1] == ' ' && (line[0] == ' ' || strings.IndexByte(ops, line[0]) >= 0):
+			lines = append(lines, contextLine{op: line[0], line: line[2:]})
+		default:
+			return lines, nil
+		}
+		if err := p.Next(); err != nil {
+			if err == io.EOF {
+				return lines, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// contextOnly returns the context lines from a section.
+func contextOnly(lines []contextLine) []contextLine {
+	var ctx []contextLine
+	for _, l := range lines {
+		if l.op == ' ' {
+			ctx = append(ctx, l)
+		}
+	}
+	return ctx
+}
+
+// mergeContextSections combines the old and new sections of a fragment into
+// the lines of a unified fragment. Changed lines marked with "!" in the old
+// section become deletions and the matching run in the new section becomes
+// additions.
+func mergeContextSections(old, new []contextLine) ([]Line, error) {
+	var lines []Line
+	run := func(s []contextLine, i int, op LineOp) int {
+		for ; i < len(s) && s[i].op == '!'; i++ {
+			lines = append(lines, Line{op, s[i].line})
+		}
+		return i
+	}
+
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && old[i].op == '-':
+			lines = append(lines, Line{OpDelete, old[i].line})
+			i++
+		case j < len(new) && new[j].op == '+':
+			lines = append(lines, Line{OpAdd, new[j].line})
+			j++
+		case i < len(old) && old[i].op == '!':
+			i = run(old, i, OpDelete)
+			j = run(new, j, OpAdd)
+		case j < len(new) && new[j].op == '!':
+			j = run(new, j, OpAdd)
+		case i < len(old) && j < len(new):
+			if old[i].line != new[j].line {
+				return nil, fmt.Errorf("context lines do not match: %q and %q", old[i].line, new[j].line)
+			}
+			lines = append(lines, Line{OpContext, old[i].line})
+			i++
+			j++
+		default:
+			return nil, fmt.Errorf("sections have different context lines")
+		}
+	}
+	return lines, nil
+}
+
+// contextPosition returns the position of a range in a unified fragment
+// header given the parsed context range and the number of lines in it.
+func contextPosition(start, end, lines int64) (int64, error) {
+	count := end - start + 1
+	if end < 0 {
+		// a single number is one line or the line before an empty range
+		if count = 1; lines == 0 {
+			count = 0
+		}
+	}
+	if count != lines {
+		return 0, fmt.Errorf("expected %d lines, found %d", count, lines)
+	}
+	return start, nil
+}
@@ -0,0 +1,142 @@
+package gitdiff
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParseContextDiff(t *testing.T) {
+	files := parseTestPatch(t, "context_diff.patch")
+	expected := parseTestPatch(t, "context_diff_unified.patch")
+
+	if len(files) != len(expected) {
+		t.Fatalf("incorrect number of files: expected %d, actual %d", len(expected), len(files))
+	}
+	for i, f := range files {
+		exp := expected[i]
+		if f.OldName != exp.OldName || f.NewName != exp.NewName || f.IsNew != exp.IsNew || f.IsDelete != exp.IsDelete {
+			t.Errorf("incorrect file %d: expected %+v, actual %+v", i, exp, f)
+			continue
+		}
+		if !reflect.DeepEqual(exp.TextFragments, f.TextFragments) {
+			t.Errorf("incorrect fragments for %s:\nexpected: %+v\n  actual: %+v", f.NewName, exp.TextFragments, f.TextFragments)
+		}
+	}
+}
+
+func TestParseContextFragment(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Output *TextFragment
+		Err    bool
+	}{
+		"changeWithComment": {
+			Input: `*************** func main() {
+*** 2,3 ****
+  a
+! b
+--- 2,3 ----
+  a
+! c
+`,
+			Output: &TextFragment{
+				Comment:        "func main() {",
+				OldPosition:    2,
+				OldLines:       2,
+				NewPosition:    2,
+				NewLines:       2,
+				LinesAdded:     1,
+				LinesDeleted:   1,
+				LeadingContext: 1,
+				Lines: []Line{
+					{OpContext, "a\n"},
+					{OpDelete, "b\n"},
+					{OpAdd, "c\n"},
+				},
+			},
+		},
+		"deleteOnly": {
+			Input: `***************
+*** 1 ****
+- a
+--- 0 ----
+`,
+			Output: &TextFragment{
+				OldPosition:  1,
+				OldLines:     1,
+				NewPosition:  0,
+				NewLines:     0,
+				LinesDeleted: 1,
+				Lines: []Line{
+					{OpDelete, "a\n"},
+				},
+			},
+		},
+		"mismatchedContext": {
+			Input: `***************
+*** 1,2 ****
+  a
+! b
+--- 1,2 ----
+  x
+! c
+`,
+			Err: true,
+		},
+		"miscountedRange": {
+			Input: `***************
+*** 1,3 ****
+  a
+! b
+--- 1,2 ----
+  a
+! c
+`,
+			Err: true,
+		},
+		"noChanges": {
+			Input: `***************
+*** 1 ****
+--- 1 ----
+  a
+`,
+			Err: true,
+		},
+		"invalidRange": {
+			Input: `***************
+*** 1,a ****
+- a
+--- 0 ----
+`,
+			Err: true,
+		},
+		"missingNewRange": {
+			Input: `***************
+*** 1 ****
+- a
+`,
+			Err: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTestParser(test.Input, true)
+
+			frag, err := p.ParseContextFragment()
+			if test.Err {
+				if err == nil || err == io.EOF {
+					t.Fatalf("expected error parsing fragment, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error parsing fragment: %v", err)
+			}
+			if !reflect.DeepEqual(test.Output, frag) {
+				t.Errorf("incorrect fragment\nexpected: %+v\n  actual: %+v", test.Output, frag)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// deltaBlockSize is the size of the blocks used to find copies
+	deltaBlockSize = 16
+
+	// git limits the size of each copy for compatibility with old versions
+	deltaMaxCopy   = 0x10000
+	deltaMaxInsert = 0x7F
+)
+
+// NewBinaryFragment returns a binary fragment that transforms old into new.
+// Like Git, it uses a delta if the compressed delta is smaller than the
+// compressed content of new and otherwise uses a literal.
+func NewBinaryFragment(old, new []byte) *BinaryFragment {
+	literal := &BinaryFragment{
+		Method: BinaryPatchLiteral,
+		Size:   int64(len(new)),
+		Data:   new,
+	}
+	if len(old) == 0 || len(new) == 0 {
+		return literal
+	}
+
+	delta := encodeBinaryDelta(old, new)
+
+	literalData, err := deflateBinaryChunk(literal.Data)
+	if err != nil {
+		return literal
+	}
+	deltaData, err := deflateBinaryChunk(delta)
+	if err != nil || len(deltaData) >= len(literalData) {
+		return literal
+	}
+
+	return &BinaryFragment{
+		Method: BinaryPatchDelta,
+		Size:   int64(len(delta)),
+		Data:   delta,
+	}
+}
+
+// encodeBinaryDelta returns a delta in Git's packfile format that transforms
+// src into dst. The delta copies any blocks of dst that also appear at block
+// boundaries in src and inserts all other data.
+func encodeBinaryDelta(src, dst []byte) []byte {
+	index := make(map[string]int)
+	for i := 0; i+deltaBlockSize <= len(src); i += deltaBlockSize {
+		block := string(src[i : i+deltaBlockSize])
+		if _, ok := index[block]; !ok {
+			index[block] = i
+		}
+	}
+
+	delta := appendBinaryDeltaSize(nil, int64(len(src)))
+	delta = appendBinaryDeltaSize(delta, int64(len(dst)))
+
+	insert := 0
+	for i := 0; i < len(dst); {
+		offset, ok := -1, false
+		if i+deltaBlockSize <= len(dst) {
+			offset, ok = index[string(dst[i:i+deltaBlockSize])]
+		}
+		if !ok {
+			i++
+			continue
+		}
+
+		// extend the match backwards into data that would otherwise be inserted
+		for i > insert && offset > 0 && src[offset-1] == dst[i-1] {
+			i--
+			offset--
+		}
+		size := 0
+		for i+size < len(dst) && offset+size < len(src) && src[offset+size] == dst[i+size] {
+			size++
+		}
+
+		delta = appendBinaryDeltaInsert(delta, dst[insert:i])
+		delta = appendBinaryDeltaCopy(delta, offset, size)
+
+		i += size
+		insert = i
+	}
+	return appendBinaryDeltaInsert(delta, dst[insert:])
+}
+
+// appendBinaryDeltaSize appends a size in the variable length format read by
+// readBinaryDeltaSize.
+func appendBinaryDeltaSize(delta []byte, size int64) []byte {
+	for size > 0x7F {
+		delta = append(delta, byte(size&0x7F)|0x80)
+		size >>= 7
+	}
+	return append(delta, byte(size))
+}
+
+// appendBinaryDeltaInsert appends add opcodes that insert data.
+func appendBinaryDeltaInsert(delta, data []byte) []byte {
+	for len(data) > 0 {
+		n := len(data)
+		if n > deltaMaxInsert {
+			n = deltaMaxInsert
+		}
+		delta = append(delta, byte(n))
+		delta = append(delta, data[:n]...)
+		data = data[n:]
+	}
+	return delta
+}
+
+// appendBinaryDeltaCopy appends copy opcodes that copy size bytes starting at
+// offset in the source. Only non-zero offset and size bytes are included.
+func appendBinaryDeltaCopy(delta []byte, offset, size int) []byte {
+	for size > 0 {
+		n := size
+		if n > deltaMaxCopy {
+			n = deltaMaxCopy
+		}
+
+		op := byte(0x80)
+		args := make([]byte, 0, 7)
+		for i := uint(0); i < 4; i++ {
+			if b := byte(offset >> (8 * i)); b != 0 {
+				op |= 1 << i
+				args = append(args, b)
+			}
+		}
+		for i := uint(0); i < 3; i++ {
+			if b := byte(n >> (8 * i)); b != 0 {
+				op |= 1 << (4 + i)
+				args = append(args, b)
+			}
+		}
+
+		delta = append(delta, op)
+		delta = append(delta, args...)
+
+		offset += n
+		size -= n
+	}
+	return delta
+}
+
+// DeltaOpType is the type of an instruction in a binary delta.
+type DeltaOpType int
+
+const (
+	// DeltaOpCopy indicates an instruction that copies data from the source
+	DeltaOpCopy DeltaOpType = iota + 1
+	// DeltaOpInsert indicates an instruction that inserts data from the delta
+	DeltaOpInsert
+)
+
+func (t DeltaOpType) String() string {
+	switch t {
+	case DeltaOpCopy:
+		return "copy"
+	case DeltaOpInsert:
+		return "insert"
+	}
+	return "unknown"
+}
+
+// DeltaOp is an instruction in a binary delta.
+type DeltaOp struct {
+	Type DeltaOpType
+
+	// Offset is the position in the source of the data to copy. It is zero
+	// for insert instructions.
+	Offset int64
+
+	// Size is the number of bytes the instruction adds to the result
+	Size int64
+
+	// Data is the data to insert. It is nil for copy instructions.
+	Data []byte
+}
+
+// BinaryDelta is a binary delta in Git's packfile format, as used in binary
+// fragments with the BinaryPatchDelta method.
+type BinaryDelta struct {
+	// SrcSize is the size of the data the delta applies to
+	SrcSize int64
+
+	// DstSize is the size of the result of the delta
+	DstSize int64
+
+	// Ops are the instructions that create the result, in order
+	Ops []DeltaOp
+}
+
+// ParseBinaryDelta parses the instructions of a binary delta in Git's
+// packfile format, returning an error if the delta is corrupt: if an
+// instruction is incomplete, copies data outside of the source, or if the
+// instructions do not create exactly DstSize bytes.
+func ParseBinaryDelta(data []byte) (*BinaryDelta, error) {
+	d := &BinaryDelta{}
+	d.SrcSize, data = readBinaryDeltaSize(data)
+	d.DstSize, data = readBinaryDeltaSize(data)
+
+	size := int64(0)
+	for len(data) > 0 {
+		var op DeltaOp
+		var err error
+		if op, data, err = readBinaryDeltaOp(data); err != nil {
+			return nil, fmt.Errorf("gitdiff: %w", err)
+		}
+		if op.Type == DeltaOpCopy && op.Offset+op.Size > d.SrcSize {
+			return nil, errors.New("gitdiff: corrupt binary delta: copy out of bounds")
+		}
+		d.Ops = append(d.Ops, op)
+		size += op.Size
+	}
+
+	if size != d.DstSize {
+		return nil, errors.New("gitdiff: corrupt binary delta: insufficient or extra data")
+	}
+	return d, nil
+}
+
+// Delta parses the data of a fragment with the BinaryPatchDelta method. See
+// ParseBinaryDelta for details. It returns an error if the fragment uses a
+// different method.
+func (f *BinaryFragment) Delta() (*BinaryDelta, error) {
+	if f.Method != BinaryPatchDelta {
+		return nil, errors.New("gitdiff: binary fragment is not a delta")
+	}
+	return ParseBinaryDelta(f.Data)
+}
+
+// readBinaryDeltaOp reads an instruction from a delta-encoded binary
+// fragment, returning the instruction and the unused part of the fragment.
+// The Data of insert instructions refers to the fragment.
+//
+// An add (insert) operation takes the form:
+//
+//	[0xxxxxx][[data1]...]
+//
+// where the lower seven bits of the opcode is the number of data bytes
+// following the opcode.
+//
+// A copy operation takes the form:
+//
+//	[1xxxxxxx][offset1][offset2][offset3][offset4][size1][size2][size3]
+//
+// where the lower seven bits of the opcode determine which non-zero offset and
+// size bytes are present in little-endian order: if bit 0 is set, offset1 is
+// present, etc. If no offset or size bytes are present, offset is 0 and size
+// is 0x10000. See also pack-format.txt in the Git source.
+func readBinaryDeltaOp(delta []byte) (op DeltaOp, rest []byte, err error) {
+	code := delta[0]
+	delta = delta[1:]
+	if code == 0 {
+		return DeltaOp{}, delta, errors.New("invalid delta opcode 0")
+	}
+
+	if code&0x80 == 0 {
+		size := int(code)
+		if len(delta) < size {
+			return DeltaOp{}, delta, errors.New("corrupt binary delta: incomplete add")
+		}
+		return DeltaOp{Type: DeltaOpInsert, Size: int64(size), Data: delta[:size]}, delta[size:], nil
+	}
+
+	unpack := func(start, bits uint) (v int64) {
+		for i := uint(0); i < bits; i++ {
+			mask := byte(1 << (i + start))
+			if code&mask > 0 {
+				if len(delta) == 0 {
+					err = errors.New("corrupt binary delta: incomplete copy")
+					return
+				}
+				v |= int64(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		return
+	}
+
+	op = DeltaOp{Type: DeltaOpCopy}
+	op.Offset = unpack(0, 4)
+	op.Size = unpack(4, 3)
+	if err != nil {
+		return DeltaOp{}, delta, err
+	}
+	if op.Size == 0 {
+		op.Size = deltaMaxCopy
+	}
+	return op, delta, nil
+}
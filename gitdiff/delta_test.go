@@ -0,0 +1,190 @@
+package gitdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeBinaryDelta(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomBytes := func(n int) []byte {
+		b := make([]byte, n)
+		r.Read(b)
+		return b
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	src := randomBytes(1000)
+	large := randomBytes(3 * deltaMaxCopy)
+
+	tests := map[string]struct {
+		Src, Dst []byte
+	}{
+		"identical":    {Src: src, Dst: src},
+		"insertMiddle": {Src: src, Dst: concat(src[:400], randomBytes(50), src[400:])},
+		"deleteMiddle": {Src: src, Dst: concat(src[:300], src[700:])},
+		"reorder":      {Src: src, Dst: concat(src[500:], src[:500])},
+		"unrelated":    {Src: src, Dst: randomBytes(300)},
+		"largeCopy":    {Src: large, Dst: concat(large[1:], []byte("x"))},
+		"emptyDst":     {Src: src, Dst: []byte{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			delta := encodeBinaryDelta(test.Src, test.Dst)
+
+			var dst bytes.Buffer
+			if err := applyBinaryDeltaFragment(&dst, bytes.NewReader(test.Src), delta); err != nil {
+				t.Fatalf("unexpected error applying delta: %v", err)
+			}
+			if !bytes.Equal(test.Dst, dst.Bytes()) {
+				t.Errorf("incorrect result after applying delta: expected %d bytes, actual %d bytes", len(test.Dst), dst.Len())
+			}
+		})
+	}
+}
+
+func TestNewBinaryFragment(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	old := make([]byte, 4096)
+	r.Read(old)
+
+	similar := append(append([]byte{}, old[:2000]...), old[2100:]...)
+	different := make([]byte, 512)
+	r.Read(different)
+
+	tests := map[string]struct {
+		Old, New []byte
+		Method   BinaryPatchMethod
+	}{
+		"similar":   {Old: old, New: similar, Method: BinaryPatchDelta},
+		"different": {Old: old, New: different, Method: BinaryPatchLiteral},
+		"create":    {Old: nil, New: similar, Method: BinaryPatchLiteral},
+		"delete":    {Old: old, New: nil, Method: BinaryPatchLiteral},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			frag := NewBinaryFragment(test.Old, test.New)
+			if frag.Method != test.Method {
+				t.Errorf("incorrect method: expected %v, actual %v", test.Method, frag.Method)
+			}
+
+			var dst bytes.Buffer
+			if err := NewApplier(bytes.NewReader(test.Old)).ApplyBinaryFragment(&dst, frag); err != nil {
+				t.Fatalf("unexpected error applying fragment: %v", err)
+			}
+			if !bytes.Equal(test.New, dst.Bytes()) {
+				t.Errorf("incorrect result after applying fragment: expected %d bytes, actual %d bytes", len(test.New), dst.Len())
+			}
+		})
+	}
+}
+
+func TestParseBinaryDelta(t *testing.T) {
+	tests := map[string]struct {
+		Delta  []byte
+		Output *BinaryDelta
+		Err    string
+	}{
+		"copyAndInsert": {
+			Delta: []byte{0x20, 0x0A, 0x91, 0x04, 0x06, 0x04, 'a', 'b', 'c', 'd'},
+			Output: &BinaryDelta{
+				SrcSize: 32,
+				DstSize: 10,
+				Ops: []DeltaOp{
+					{Type: DeltaOpCopy, Offset: 4, Size: 6},
+					{Type: DeltaOpInsert, Size: 4, Data: []byte("abcd")},
+				},
+			},
+		},
+		"defaultCopySize": {
+			Delta: []byte{0x80, 0x80, 0x04, 0x80, 0x80, 0x04, 0x80},
+			Output: &BinaryDelta{
+				SrcSize: 0x10000,
+				DstSize: 0x10000,
+				Ops: []DeltaOp{
+					{Type: DeltaOpCopy, Offset: 0, Size: 0x10000},
+				},
+			},
+		},
+		"invalidOpcode": {
+			Delta: []byte{0x20, 0x01, 0x00},
+			Err:   "invalid delta opcode 0",
+		},
+		"incompleteInsert": {
+			Delta: []byte{0x20, 0x04, 0x04, 'a', 'b'},
+			Err:   "incomplete add",
+		},
+		"incompleteCopy": {
+			Delta: []byte{0x20, 0x04, 0x91, 0x04},
+			Err:   "incomplete copy",
+		},
+		"copyOutOfBounds": {
+			Delta: []byte{0x20, 0x06, 0x91, 0x1C, 0x06},
+			Err:   "copy out of bounds",
+		},
+		"extraData": {
+			Delta: []byte{0x20, 0x02, 0x04, 'a', 'b', 'c', 'd'},
+			Err:   "insufficient or extra data",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, err := ParseBinaryDelta(test.Delta)
+			if test.Err != "" {
+				assertError(t, test.Err, err, "parsing binary delta")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing binary delta: %v", err)
+			}
+			if !reflect.DeepEqual(test.Output, d) {
+				t.Errorf("incorrect binary delta\nexpected: %+v\n  actual: %+v", test.Output, d)
+			}
+		})
+	}
+}
+
+func TestBinaryFragmentDelta(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	src := make([]byte, 1000)
+	r.Read(src)
+	dst := append(append(append([]byte{}, src[:400]...), "inserted"...), src[400:]...)
+
+	frag := NewBinaryFragment(src, dst)
+	if frag.Method != BinaryPatchDelta {
+		t.Fatalf("expected delta fragment, but got %v", frag.Method)
+	}
+
+	d, err := frag.Delta()
+	if err != nil {
+		t.Fatalf("unexpected error parsing binary delta: %v", err)
+	}
+	if d.SrcSize != int64(len(src)) || d.DstSize != int64(len(dst)) {
+		t.Errorf("incorrect sizes: expected %d => %d, actual %d => %d", len(src), len(dst), d.SrcSize, d.DstSize)
+	}
+
+	var b bytes.Buffer
+	for _, op := range d.Ops {
+		switch op.Type {
+		case DeltaOpCopy:
+			b.Write(src[op.Offset : op.Offset+op.Size])
+		case DeltaOpInsert:
+			b.Write(op.Data)
+		}
+	}
+	if !bytes.Equal(dst, b.Bytes()) {
+		t.Errorf("incorrect result from delta instructions: expected %d bytes, actual %d bytes", len(dst), b.Len())
+	}
+
+	literal := &BinaryFragment{Method: BinaryPatchLiteral, Size: 1, Data: []byte("a")}
+	if _, err := literal.Delta(); err == nil {
+		t.Errorf("expected error parsing literal fragment as a delta, but got nil")
+	}
+}
@@ -0,0 +1,420 @@
+package gitdiff
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+const (
+	defaultDiffContext = 3
+
+	// git only checks the start of files for binary content
+	binaryCheckSize = 8000
+
+	// the minimum cost of the Myers heuristic, from XDL_MAX_COST_MIN in Git
+	minMyersCost = 256
+)
+
+// DiffOption configures the behavior of Diff.
+type DiffOption func(*differ)
+
+// WithContext sets the number of unchanged lines included before and after
+// each change, like the -U flag of "git diff". The default is 3 lines.
+func WithContext(lines int) DiffOption {
+	return func(d *differ) {
+		if lines >= 0 {
+			d.context = lines
+		}
+	}
+}
+
+// WithNames sets the old and new names of the File returned by Diff.
+func WithNames(oldName, newName string) DiffOption {
+	return func(d *differ) {
+		d.oldName = oldName
+		d.newName = newName
+	}
+}
+
+// WithBinary includes binary data in the File returned by Diff if the content
+// is binary, like the --binary flag of "git diff". The File has forward and
+// reverse binary fragments and the full object IDs of the old and new
+// content, which "git apply" requires to apply binary patches.
+func WithBinary() DiffOption {
+	return func(d *differ) {
+		d.binary = true
+	}
+}
+
+type differ struct {
+	context          int
+	oldName, newName string
+	binary           bool
+	algorithm        DiffAlgorithm
+	anchors          []string
+	funcPatterns     func(name string) *FuncPattern
+	renames, copies  int
+}
+
+// Diff computes the changes between the content of old and new and returns a
+// File with text fragments describing the changes, like the File returned
+// by Parse for a patch generated by "git diff". If the content is the same,
+// the File has no fragments. If either old or new appears to be binary, the
+// File is binary and has no fragments or binary data unless the WithBinary
+// option is set.
+//
+// By default, Diff uses Myers' algorithm with a heuristic like Git's to find
+// a small set of changes. Use WithAlgorithm to choose another algorithm.
+func Diff(old, new io.Reader, opts ...DiffOption) (*File, error) {
+	d := differ{context: defaultDiffContext}
+	for _, opt := range opts {
+		opt(&d)
+	}
+
+	oldData, err := ioutil.ReadAll(old)
+	if err != nil {
+		return nil, err
+	}
+	newData, err := ioutil.ReadAll(new)
+	if err != nil {
+		return nil, err
+	}
+	return d.diff(oldData, newData)
+}
+
+// diff computes the changes between oldData and newData.
+func (d *differ) diff(oldData, newData []byte) (*File, error) {
+	f := &File{
+		OldName: d.oldName,
+		NewName: d.newName,
+	}
+	if bytes.Equal(oldData, newData) {
+		return f, nil
+	}
+	if isBinaryContent(oldData) || isBinaryContent(newData) {
+		f.IsBinary = true
+		if d.binary {
+			f.OldOIDPrefix, f.NewOIDPrefix = blobOID(HashSHA1, oldData), blobOID(HashSHA1, newData)
+			f.HashAlgorithm = HashSHA1
+			f.BinaryFragment = NewBinaryFragment(oldData, newData)
+			f.ReverseBinaryFragment = NewBinaryFragment(newData, oldData)
+		}
+		return f, nil
+	}
+
+	a, b := splitLines(string(oldData)), splitLines(string(newData))
+	f.TextFragments = d.fragments(a, b, diffLinesWith(a, b, d.algorithm, d.anchors))
+	if d.funcPatterns != nil {
+		name := d.oldName
+		if name == "" {
+			name = d.newName
+		}
+		setFuncNames(f.TextFragments, a, d.funcPatterns(name))
+	}
+	return f, nil
+}
+
+// fragments converts changed regions into text fragments with context lines.
+// Like git, changes separated by fewer than twice the number of context lines
+// appear in the same fragment.
+func (d *differ) fragments(a, b []string, hunks []diffHunk) []*TextFragment {
+	var frags []*TextFragment
+	for len(hunks) > 0 {
+		n := 1
+		for n < len(hunks) && hunks[n].AStart-hunks[n-1].AEnd <= 2*d.context {
+			n++
+		}
+		first, last := hunks[0], hunks[n-1]
+
+		aStart := int(max(0, int64(first.AStart-d.context)))
+		bStart := first.BStart - (first.AStart - aStart)
+		aEnd := int(min(int64(len(a)), int64(last.AEnd+d.context)))
+		bEnd := last.BEnd + (aEnd - last.AEnd)
+
+		frag := &TextFragment{
+			OldPosition: int64(aStart),
+			OldLines:    int64(aEnd - aStart),
+			NewPosition: int64(bStart),
+			NewLines:    int64(bEnd - bStart),
+		}
+		// positions are 1-indexed, except empty ranges, which refer to the
+		// line before the range
+		if frag.OldLines > 0 {
+			frag.OldPosition++
+		}
+		if frag.NewLines > 0 {
+			frag.NewPosition++
+		}
+
+		next := aStart
+		for _, h := range hunks[:n] {
+			frag.addLines(OpContext, a[next:h.AStart])
+			frag.addLines(OpDelete, a[h.AStart:h.AEnd])
+			frag.addLines(OpAdd, b[h.BStart:h.BEnd])
+			next = h.AEnd
+		}
+		frag.addLines(OpContext, a[next:aEnd])
+
+		frags = append(frags, frag)
+		hunks = hunks[n:]
+	}
+	return frags
+}
+
+// addLines appends lines with the given operation to the fragment and updates
+// the line counts, except for OldLines and NewLines.
+func (f *TextFragment) addLines(op LineOp, lines []string) {
+	for _, line := range lines {
+		switch op {
+		case OpContext:
+			if f.LinesAdded == 0 && f.LinesDeleted == 0 {
+				f.LeadingContext++
+			} else {
+				f.TrailingContext++
+			}
+		case OpDelete:
+			f.LinesDeleted++
+			f.TrailingContext = 0
+		case OpAdd:
+			f.LinesAdded++
+			f.TrailingContext = 0
+		}
+		f.Lines = append(f.Lines, Line{op, line})
+	}
+}
+
+// isBinaryContent returns true if data appears to be binary, using the same
+// heuristic as git: binary data contains a NUL byte near the start.
+func isBinaryContent(data []byte) bool {
+	if len(data) > binaryCheckSize {
+		data = data[:binaryCheckSize]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// diffHunk describes a region of lines that differs between two sequences.
+// Lines [AStart, AEnd) in the first sequence are replaced by lines [BStart,
+// BEnd) in the second sequence.
+type diffHunk struct {
+	AStart, AEnd int
+	BStart, BEnd int
+}
+
+// diffLines computes the changes that transform the lines in a into the lines
+// in b using Myers' algorithm and returns the changed regions in order.
+func diffLines(a, b []string) []diffHunk {
+	return diffLinesWith(a, b, DiffMyers, nil)
+}
+
+// diffLinesWith computes the changes that transform the lines in a into the
+// lines in b using the given algorithm and returns the changed regions in
+// order. If there are anchors, it uses the patience algorithm and keeps the
+// unique lines of a that start with an anchor unchanged where possible.
+func diffLinesWith(a, b []string, alg DiffAlgorithm, anchors []string) []diffHunk {
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		seq := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			seq[i] = id
+		}
+		return seq
+	}
+
+	m := &myers{
+		a:        intern(a),
+		b:        intern(b),
+		changedA: make([]bool, len(a)),
+		changedB: make([]bool, len(b)),
+		minimal:  alg == DiffMinimal,
+	}
+	m.maxCost = int(max(int64(math.Sqrt(float64(len(a)+len(b)+3))), minMyersCost))
+
+	if len(anchors) > 0 {
+		alg = DiffPatience
+		m.anchorA = make([]bool, len(a))
+		for i, line := range a {
+			for _, anchor := range anchors {
+				if strings.HasPrefix(line, anchor) {
+					m.anchorA[i] = true
+					break
+				}
+			}
+		}
+	}
+
+	switch alg {
+	case DiffPatience:
+		m.patience(0, len(a), 0, len(b))
+	case DiffHistogram:
+		m.histogram(0, len(a), 0, len(b))
+	default:
+		m.compare(0, len(a), 0, len(b))
+	}
+	return m.hunks()
+}
+
+// myers implements the linear space variant of Myers' difference algorithm,
+// as described in "An O(ND) Difference Algorithm and Its Variations". It
+// marks each line in the input sequences as either changed or unchanged.
+//
+// Unless minimal is set, it uses a heuristic like Git's to limit the cost of
+// large diffs: if the search for the middle snake exceeds maxCost edits, it
+// splits the sequences at the furthest point reached instead.
+type myers struct {
+	a, b               []int
+	changedA, changedB []bool
+
+	minimal bool
+	maxCost int
+
+	// anchorA marks the lines of a that patience keeps unchanged if possible
+	anchorA []bool
+}
+
+// compare marks the changed lines in a[aLo:aHi] and b[bLo:bHi].
+func (m *myers) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && m.a[aLo] == m.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && m.a[aHi-1] == m.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			m.changedB[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			m.changedA[i] = true
+		}
+	default:
+		x, y := m.split(aLo, aHi, bLo, bHi)
+		if (x == aLo && y == bLo) || (x == aHi && y == bHi) {
+			// should not happen, but avoid infinite recursion if it does
+			m.compare(aLo, aLo, bLo, bHi)
+			m.compare(aLo, aHi, bHi, bHi)
+			return
+		}
+		m.compare(aLo, x, bLo, y)
+		m.compare(x, aHi, y, bHi)
+	}
+}
+
+// split finds the middle snake of an optimal path through the edit graph of
+// a[aLo:aHi] and b[bLo:bHi] and returns a point on that path. The sequences
+// must be non-empty and must not share a common prefix or suffix.
+func (m *myers) split(aLo, aHi, bLo, bHi int) (x, y int) {
+	n, mb := aHi-aLo, bHi-bLo
+	delta := n - mb
+	odd := delta&1 != 0
+
+	maxD := (n + mb + 1) / 2
+	offset := maxD + 1
+	vf := make([]int, 2*maxD+3)
+	vb := make([]int, 2*maxD+3)
+
+	for d := 0; d <= maxD; d++ {
+		// forward search from the start of the sequences
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < mb && m.a[aLo+x] == m.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			if kb := delta - k; odd && -(d-1) <= kb && kb <= d-1 && x+vb[offset+kb] >= n {
+				return aLo + x, bLo + y
+			}
+		}
+
+		// backward search from the end of the sequences, where x and y are
+		// distances from the end
+		for kb := -d; kb <= d; kb += 2 {
+			var x int
+			if kb == -d || (kb != d && vb[offset+kb-1] < vb[offset+kb+1]) {
+				x = vb[offset+kb+1]
+			} else {
+				x = vb[offset+kb-1] + 1
+			}
+			y := x - kb
+			for x < n && y < mb && m.a[aHi-1-x] == m.b[bHi-1-y] {
+				x++
+				y++
+			}
+			vb[offset+kb] = x
+
+			if k := delta - kb; !odd && -d <= k && k <= d && x+vf[offset+k] >= n {
+				return aHi - x, bHi - y
+			}
+		}
+
+		if !m.minimal && d >= m.maxCost {
+			return m.furthest(vf, vb, offset, d, aLo, aHi, bLo, bHi)
+		}
+	}
+
+	// unreachable for valid inputs: the searches always overlap by maxD
+	return aLo, bLo
+}
+
+// furthest returns the point reached by the forward or backward search for
+// the middle snake after d edits that is furthest from its starting point.
+func (m *myers) furthest(vf, vb []int, offset, d, aLo, aHi, bLo, bHi int) (x, y int) {
+	n, mb := aHi-aLo, bHi-bLo
+
+	best, x, y := 0, aLo, bLo
+	for k := -d; k <= d; k += 2 {
+		fx := vf[offset+k]
+		if fy := fx - k; fx <= n && fy >= 0 && fy <= mb && fx+fy > best && fx+fy < n+mb {
+			best, x, y = fx+fy, aLo+fx, bLo+fy
+		}
+		bx := vb[offset+k]
+		if by := bx - k; bx <= n && by >= 0 && by <= mb && bx+by > best && bx+by < n+mb {
+			best, x, y = bx+by, aHi-bx, bHi-by
+		}
+	}
+	return x, y
+}
+
+// hunks converts the changed lines into a list of changed regions.
+func (m *myers) hunks() []diffHunk {
+	var hunks []diffHunk
+	i, j := 0, 0
+	for i < len(m.a) || j < len(m.b) {
+		if i < len(m.a) && j < len(m.b) && !m.changedA[i] && !m.changedB[j] {
+			i++
+			j++
+			continue
+		}
+
+		h := diffHunk{AStart: i, BStart: j}
+		for i < len(m.a) && m.changedA[i] {
+			i++
+		}
+		for j < len(m.b) && m.changedB[j] {
+			j++
+		}
+		h.AEnd, h.BEnd = i, j
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
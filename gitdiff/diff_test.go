@@ -0,0 +1,431 @@
+package gitdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := map[string]struct {
+		Files   applyFiles
+		Options []DiffOption
+	}{
+		"modify": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_modify.patch",
+				Out:   "file_text_modify.out",
+			},
+		},
+		"delete": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_delete.patch",
+				Out:   "file_text_delete.out",
+			},
+		},
+		"createFile": {
+			Files: getApplyFiles("text_fragment_new"),
+		},
+		"addEndNoEOL": {
+			Files: getApplyFiles("text_fragment_add_end_noeol"),
+		},
+		"changeSingleNoEOL": {
+			Files: getApplyFiles("text_fragment_change_single_noeol"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src, patch, out := test.Files.Load(t)
+
+			files, err := Parse(bytes.NewReader(patch))
+			if err != nil {
+				t.Fatalf("failed to parse patch file: %v", err)
+			}
+			parsed := collectFiles(files)
+			if len(parsed) != 1 {
+				t.Fatalf("patch should contain exactly one file, but it has %d", len(parsed))
+			}
+
+			f, err := Diff(bytes.NewReader(src), bytes.NewReader(out), test.Options...)
+			if err != nil {
+				t.Fatalf("unexpected error computing diff: %v", err)
+			}
+
+			// git includes function names in fragment comments
+			expected := parsed[0].TextFragments
+			for _, frag := range expected {
+				frag.Comment = ""
+			}
+
+			if !reflect.DeepEqual(expected, f.TextFragments) {
+				t.Errorf("incorrect fragments\nexpected:\n%s\nactual:\n%s", formatFragments(expected), formatFragments(f.TextFragments))
+			}
+		})
+	}
+}
+
+func TestDiffOptions(t *testing.T) {
+	old := "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\n"
+	new := "line 1\nline two\nline 3\nline 4\nline 5\nline 6\nline seven\nline 8\n"
+
+	tests := map[string]struct {
+		Old, New string
+		Options  []DiffOption
+		Output   string
+		IsBinary bool
+	}{
+		"default": {
+			Old:     old,
+			New:     new,
+			Options: []DiffOption{WithNames("file.txt", "file.txt")},
+			Output: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,8 +1,8 @@
+ line 1
+-line 2
++line two
+ line 3
+ line 4
+ line 5
+ line 6
+-line 7
++line seven
+ line 8
+`,
+		},
+		"oneContextLine": {
+			Old:     old,
+			New:     new,
+			Options: []DiffOption{WithNames("file.txt", "file.txt"), WithContext(1)},
+			Output: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,3 +1,3 @@
+ line 1
+-line 2
++line two
+ line 3
+@@ -6,3 +6,3 @@
+ line 6
+-line 7
++line seven
+ line 8
+`,
+		},
+		"noContext": {
+			Old:     "a\nb\nc\n",
+			New:     "a\nc\nd\n",
+			Options: []DiffOption{WithNames("file.txt", "file.txt"), WithContext(0)},
+			Output: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -2 +1,0 @@
+-b
+@@ -3,0 +3 @@
++d
+`,
+		},
+		"patience": {
+			Old:     ".foo {\n    margin: 0;\n}\n\n.bar {\n    margin: 0;\n}\n",
+			New:     ".bar {\n    margin: 0;\n}\n\n.foo {\n    margin: 0;\n    color: red;\n}\n",
+			Options: []DiffOption{WithNames("style.css", "style.css"), WithAlgorithm(DiffPatience)},
+			Output: `diff --git a/style.css b/style.css
+--- a/style.css
++++ b/style.css
+@@ -1,7 +1,8 @@
+-.foo {
+-    margin: 0;
+-}
+-
+ .bar {
+     margin: 0;
++}
++
++.foo {
++    margin: 0;
++    color: red;
+ }
+`,
+		},
+		"histogram": {
+			Old:     ".foo {\n    margin: 0;\n}\n\n.bar {\n    margin: 0;\n}\n",
+			New:     ".bar {\n    margin: 0;\n}\n\n.foo {\n    margin: 0;\n    color: red;\n}\n",
+			Options: []DiffOption{WithNames("style.css", "style.css"), WithAlgorithm(DiffHistogram)},
+			Output: `diff --git a/style.css b/style.css
+--- a/style.css
++++ b/style.css
+@@ -1,7 +1,8 @@
+-.foo {
+-    margin: 0;
+-}
+-
+ .bar {
+     margin: 0;
+ }
++
++.foo {
++    margin: 0;
++    color: red;
++}
+`,
+		},
+		"anchored": {
+			Old:     "one\ntwo\nthree\nfour\nfive\n",
+			New:     "four\nfive\none\ntwo\nthree\n",
+			Options: []DiffOption{WithNames("file.txt", "file.txt"), WithAnchors("fi")},
+			Output: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,5 +1,5 @@
+-one
+-two
+-three
+ four
+ five
++one
++two
++three
+`,
+		},
+		"anchoredConflict": {
+			Old:     "one\ntwo\nthree\nfour\nfive\n",
+			New:     "four\nfive\none\ntwo\nthree\n",
+			Options: []DiffOption{WithNames("file.txt", "file.txt"), WithAnchors("one", "fi")},
+			Output: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,5 +1,5 @@
++four
++five
+ one
+ two
+ three
+-four
+-five
+`,
+		},
+		"identical": {
+			Old:     old,
+			New:     old,
+			Options: []DiffOption{WithNames("file.txt", "file.txt")},
+			Output: `diff --git a/file.txt b/file.txt
+`,
+		},
+		"binary": {
+			Old:      "text\n",
+			New:      "binary\x00data\n",
+			Options:  []DiffOption{WithNames("file.bin", "file.bin")},
+			IsBinary: true,
+			Output: `diff --git a/file.bin b/file.bin
+Binary files a/file.bin and b/file.bin differ
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := Diff(strings.NewReader(test.Old), strings.NewReader(test.New), test.Options...)
+			if err != nil {
+				t.Fatalf("unexpected error computing diff: %v", err)
+			}
+			if f.IsBinary != test.IsBinary {
+				t.Errorf("incorrect binary flag: expected %t, actual %t", test.IsBinary, f.IsBinary)
+			}
+			if out := f.String(); out != test.Output {
+				t.Errorf("incorrect diff\nexpected:\n%s\nactual:\n%s", test.Output, out)
+			}
+		})
+	}
+}
+
+func TestDiffBinary(t *testing.T) {
+	old, new := "binary\x00old\n", "binary\x00new data\n"
+
+	f, err := Diff(strings.NewReader(old), strings.NewReader(new), WithBinary(), WithNames("file.bin", "file.bin"))
+	if err != nil {
+		t.Fatalf("unexpected error computing diff: %v", err)
+	}
+	if f.OldOIDPrefix != "eda69c0c4a2b9a7f64429bf8ec5caa19dea08d2c" || f.NewOIDPrefix != "42aba158911dc39471a3bc809a93715eb0cb12f4" {
+		t.Errorf("incorrect object IDs: %s..%s", f.OldOIDPrefix, f.NewOIDPrefix)
+	}
+
+	files, err := Parse(strings.NewReader(f.String()))
+	if err != nil {
+		t.Fatalf("unexpected error parsing diff: %v", err)
+	}
+	parsed := collectFiles(files)
+	if len(parsed) != 1 {
+		t.Fatalf("diff should contain exactly one file, but it has %d", len(parsed))
+	}
+	parsed[0].PatchHeader = nil
+	if !reflect.DeepEqual(f, parsed[0]) {
+		t.Errorf("incorrect file after parsing\nexpected: %#v\nactual: %#v", f, parsed[0])
+	}
+
+	var out bytes.Buffer
+	if err := Apply(&out, strings.NewReader(old), f); err != nil {
+		t.Fatalf("unexpected error applying diff: %v", err)
+	}
+	if out.String() != new {
+		t.Errorf("incorrect result after apply\nexpected: %q\n  actual: %q", new, out.String())
+	}
+
+	out.Reset()
+	if err := NewApplier(strings.NewReader(new)).ApplyBinaryFragment(&out, f.ReverseBinaryFragment); err != nil {
+		t.Fatalf("unexpected error applying reverse fragment: %v", err)
+	}
+	if out.String() != old {
+		t.Errorf("incorrect result after reverse apply\nexpected: %q\n  actual: %q", old, out.String())
+	}
+}
+
+func TestDiffAlgorithmText(t *testing.T) {
+	for _, alg := range []DiffAlgorithm{DiffMyers, DiffMinimal, DiffPatience, DiffHistogram} {
+		text, err := alg.MarshalText()
+		if err != nil {
+			t.Fatalf("unexpected error marshaling %v: %v", alg, err)
+		}
+
+		var decoded DiffAlgorithm
+		if err := decoded.UnmarshalText(text); err != nil {
+			t.Fatalf("unexpected error unmarshaling %q: %v", text, err)
+		}
+		if decoded != alg {
+			t.Errorf("incorrect algorithm for %q: expected %v, actual %v", text, alg, decoded)
+		}
+	}
+
+	var alg DiffAlgorithm
+	if err := alg.UnmarshalText([]byte("default")); err != nil || alg != DiffMyers {
+		t.Errorf("incorrect default algorithm: %v, %v", alg, err)
+	}
+	assertError(t, "invalid diff algorithm", alg.UnmarshalText([]byte("bogus")), "unmarshaling algorithm")
+	if _, err := DiffAlgorithm(-1).MarshalText(); err == nil {
+		t.Error("expected error marshaling invalid algorithm, but got nil")
+	}
+}
+
+func TestDiffApply(t *testing.T) {
+	randomContent := func(r *rand.Rand, n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteString(string(rune('a' + r.Intn(5))))
+			b.WriteByte('\n')
+		}
+		s := b.String()
+		if n > 0 && r.Intn(4) == 0 {
+			s = s[:len(s)-1]
+		}
+		return s
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		old, new := randomContent(r, r.Intn(40)), randomContent(r, r.Intn(40))
+		// Apply treats fragments at position 0 as creating a new file, so always
+		// include context to keep insertions at the start of old anchored
+		context := 1 + r.Intn(3)
+
+		f, err := Diff(strings.NewReader(old), strings.NewReader(new), WithContext(context))
+		if err != nil {
+			t.Fatalf("unexpected error computing diff: %v", err)
+		}
+		for j, frag := range f.TextFragments {
+			if err := frag.Validate(); err != nil {
+				t.Fatalf("invalid fragment %d: %v\nold: %q\nnew: %q", j, err, old, new)
+			}
+		}
+
+		var out bytes.Buffer
+		if err := Apply(&out, strings.NewReader(old), f); err != nil {
+			t.Fatalf("unexpected error applying diff: %v\nold: %q\nnew: %q\ndiff:\n%s", err, old, new, f)
+		}
+		if out.String() != new {
+			t.Fatalf("incorrect result after apply\nexpected: %q\n  actual: %q\ndiff:\n%s", new, out.String(), f)
+		}
+	}
+}
+
+func formatFragments(frags []*TextFragment) string {
+	var b strings.Builder
+	for _, frag := range frags {
+		b.WriteString(frag.String())
+	}
+	return b.String()
+}
+
+func TestDiffLines(t *testing.T) {
+	lcs := func(a, b []string) int {
+		dp := make([][]int, len(a)+1)
+		for i := range dp {
+			dp[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				switch {
+				case a[i] == b[j]:
+					dp[i][j] = dp[i+1][j+1] + 1
+				case dp[i+1][j] > dp[i][j+1]:
+					dp[i][j] = dp[i+1][j]
+				default:
+					dp[i][j] = dp[i][j+1]
+				}
+			}
+		}
+		return dp[0][0]
+	}
+
+	randomLines := func(r *rand.Rand, n int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = string(rune('a'+r.Intn(4))) + "\n"
+		}
+		return lines
+	}
+
+	algorithms := []DiffAlgorithm{DiffMyers, DiffMinimal, DiffPatience, DiffHistogram}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(r, r.Intn(30)), randomLines(r, r.Intn(30))
+		alg := algorithms[i%len(algorithms)]
+
+		hunks := diffLinesWith(a, b, alg, nil)
+
+		var result []string
+		var changes, next int
+		for _, h := range hunks {
+			if h.AStart < next || h.AEnd < h.AStart || h.BEnd < h.BStart {
+				t.Fatalf("%s: invalid hunk %+v after line %d\na: %q\nb: %q", alg, h, next, a, b)
+			}
+			result = append(result, a[next:h.AStart]...)
+			result = append(result, b[h.BStart:h.BEnd]...)
+			changes += (h.AEnd - h.AStart) + (h.BEnd - h.BStart)
+			next = h.AEnd
+		}
+		result = append(result, a[next:]...)
+
+		if len(result) != len(b) {
+			t.Fatalf("%s: incorrect result length: expected %d, actual %d\na: %q\nb: %q", alg, len(b), len(result), a, b)
+		}
+		for j := range b {
+			if result[j] != b[j] {
+				t.Fatalf("%s: incorrect result at line %d\na: %q\nb: %q\nresult: %q", alg, j, a, b, result)
+			}
+		}
+		// only Myers' algorithm finds a minimal diff, but the heuristic does
+		// not apply to inputs this small
+		if alg != DiffMyers && alg != DiffMinimal {
+			continue
+		}
+		if minChanges := len(a) + len(b) - 2*lcs(a, b); changes != minChanges {
+			t.Fatalf("non-minimal diff: expected %d changes, actual %d\na: %q\nb: %q", minChanges, changes, a, b)
+		}
+	}
+}
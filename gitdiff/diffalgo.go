@@ -0,0 +1,287 @@
+package gitdiff
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DiffAlgorithm is the algorithm Diff uses to find the changes between two
+// files, like the --diff-algorithm flag of "git diff".
+type DiffAlgorithm int
+
+const (
+	// DiffMyers is Myers' algorithm with a heuristic like Git's to limit
+	// the cost of large diffs. It is the default algorithm.
+	DiffMyers DiffAlgorithm = iota
+	// DiffMinimal is Myers' algorithm without the heuristic, which always
+	// finds the smallest set of changes.
+	DiffMinimal
+	// DiffPatience matches lines that are unique in both files first, which
+	// often produces more readable diffs for code that moves or
+	// restructures blocks.
+	DiffPatience
+	// DiffHistogram extends the patience algorithm to also use lines that
+	// occur a few times, preferring the least frequent lines. It is usually
+	// faster than DiffPatience and produces similar results.
+	DiffHistogram
+)
+
+// WithAlgorithm sets the algorithm Diff and DiffMap use to compare the lines
+// of text files. The default is DiffMyers.
+func WithAlgorithm(alg DiffAlgorithm) DiffOption {
+	return func(d *differ) {
+		d.algorithm = alg
+	}
+}
+
+// WithAnchors configures Diff to keep lines that start with any of the
+// anchors unchanged if they occur once in both files, like the --anchored
+// flag of "git diff". This is useful when a block of lines moves, to show the
+// other lines as moved instead of the anchored lines. Like Git, Diff uses the
+// patience algorithm when there are anchors, ignoring WithAlgorithm.
+func WithAnchors(anchors ...string) DiffOption {
+	return func(d *differ) {
+		d.anchors = append(d.anchors, anchors...)
+	}
+}
+
+func (alg DiffAlgorithm) String() string {
+	switch alg {
+	case DiffMyers:
+		return "myers"
+	case DiffMinimal:
+		return "minimal"
+	case DiffPatience:
+		return "patience"
+	case DiffHistogram:
+		return "histogram"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the algorithm with the name used by Git, like
+// "histogram".
+func (alg DiffAlgorithm) MarshalText() ([]byte, error) {
+	switch alg {
+	case DiffMyers, DiffMinimal, DiffPatience, DiffHistogram:
+		return []byte(alg.String()), nil
+	}
+	return nil, fmt.Errorf("gitdiff: invalid diff algorithm: %d", alg)
+}
+
+// UnmarshalText decodes an algorithm from its name in Git, accepting the same
+// names as the --diff-algorithm flag, including "default" for DiffMyers.
+func (alg *DiffAlgorithm) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "myers", "default":
+		*alg = DiffMyers
+	case "minimal":
+		*alg = DiffMinimal
+	case "patience":
+		*alg = DiffPatience
+	case "histogram":
+		*alg = DiffHistogram
+	default:
+		return fmt.Errorf("gitdiff: invalid diff algorithm: %q", text)
+	}
+	return nil
+}
+
+// maxHistogramChain is the maximum number of times a line can occur in the
+// old lines for the histogram algorithm to use it, from xhistogram.c in Git.
+const maxHistogramChain = 64
+
+// changeEmpty marks the lines in a[aLo:aHi] and b[bLo:bHi] as changed and
+// returns true if either range is empty.
+func (m *myers) changeEmpty(aLo, aHi, bLo, bHi int) bool {
+	if aLo < aHi && bLo < bHi {
+		return false
+	}
+	for i := aLo; i < aHi; i++ {
+		m.changedA[i] = true
+	}
+	for j := bLo; j < bHi; j++ {
+		m.changedB[j] = true
+	}
+	return true
+}
+
+// patience marks the changed lines in a[aLo:aHi] and b[bLo:bHi] using the
+// patience algorithm. It matches the longest increasing sequence of lines
+// that occur once in each range, then compares the lines between the
+// matches. Ranges without unique lines use Myers' algorithm, like Git.
+func (m *myers) patience(aLo, aHi, bLo, bHi int) {
+	if m.changeEmpty(aLo, aHi, bLo, bHi) {
+		return
+	}
+
+	type occurrence struct {
+		countA, countB int
+		posB           int
+	}
+	lines := make(map[int]*occurrence)
+	for i := aLo; i < aHi; i++ {
+		o := lines[m.a[i]]
+		if o == nil {
+			o = &occurrence{}
+			lines[m.a[i]] = o
+		}
+		o.countA++
+	}
+	for j := bLo; j < bHi; j++ {
+		if o := lines[m.b[j]]; o != nil {
+			o.countB++
+			o.posB = j
+		}
+	}
+
+	var unique []diffMatch
+	for i := aLo; i < aHi; i++ {
+		if o := lines[m.a[i]]; o.countA == 1 && o.countB == 1 {
+			unique = append(unique, diffMatch{a: i, b: o.posB, anchor: m.anchorA != nil && m.anchorA[i]})
+		}
+	}
+	if len(unique) == 0 {
+		m.compare(aLo, aHi, bLo, bHi)
+		return
+	}
+
+	// like Git, extend each match with the equal lines around it before
+	// comparing the lines between matches
+	nextA, nextB := aLo, bLo
+	for _, match := range append(longestIncreasing(unique), diffMatch{a: aHi, b: bHi}) {
+		endA, endB := match.a, match.b
+		for endA > nextA && endB > nextB && m.a[endA-1] == m.b[endB-1] {
+			endA--
+			endB--
+		}
+		for nextA < endA && nextB < endB && m.a[nextA] == m.b[nextB] {
+			nextA++
+			nextB++
+		}
+		if nextA < endA || nextB < endB {
+			m.patience(nextA, endA, nextB, endB)
+		}
+		nextA, nextB = match.a+1, match.b+1
+	}
+}
+
+// diffMatch is a pair of equal lines at positions a and b. Anchored matches
+// are part of the result of longestIncreasing if possible.
+type diffMatch struct {
+	a, b   int
+	anchor bool
+}
+
+// longestIncreasing returns the longest subsequence of matches, which are
+// sorted by a, in which b is also increasing. Like Git, the subsequence
+// includes each anchored match unless it conflicts with an earlier one, even
+// if that makes the subsequence shorter.
+func longestIncreasing(matches []diffMatch) []diffMatch {
+	// tails[i] is the index of the match that ends the best subsequence of
+	// length i+1 found so far, while prev links each match to the one before
+	// it in its subsequence
+	var tails []int
+	prev := make([]int, len(matches))
+	anchor := -1
+	for i, match := range matches {
+		n := sort.Search(len(tails), func(j int) bool {
+			return matches[tails[j]].b > match.b
+		})
+		if n <= anchor {
+			// the match conflicts with the last anchored match
+			continue
+		}
+		if n > 0 {
+			prev[i] = tails[n-1]
+		} else {
+			prev[i] = -1
+		}
+		if n == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[n] = i
+		}
+		if match.anchor {
+			anchor, tails = n, tails[:n+1]
+		}
+	}
+
+	result := make([]diffMatch, len(tails))
+	for i, j := len(tails)-1, tails[len(tails)-1]; i >= 0; i, j = i-1, prev[j] {
+		result[i] = matches[j]
+	}
+	return result
+}
+
+// histogram marks the changed lines in a[aLo:aHi] and b[bLo:bHi] using the
+// histogram algorithm from Git. It finds the longest common region that
+// contains the line with the fewest occurrences in a, then compares the lines
+// before and after the region. Ranges without common lines that occur at most
+// maxHistogramChain times use Myers' algorithm.
+func (m *myers) histogram(aLo, aHi, bLo, bHi int) {
+	if m.changeEmpty(aLo, aHi, bLo, bHi) {
+		return
+	}
+
+	positions := make(map[int][]int)
+	for i := aLo; i < aHi; i++ {
+		positions[m.a[i]] = append(positions[m.a[i]], i)
+	}
+
+	var best struct {
+		aStart, aEnd, bStart, bEnd int
+	}
+	bestCount := maxHistogramChain + 1
+
+	for j := bLo; j < bHi; {
+		next := j + 1
+		occurrences := positions[m.b[j]]
+		if len(occurrences) > maxHistogramChain {
+			j = next
+			continue
+		}
+
+		// like Git, skip occurrences in the region found for the previous one
+		regionEnd := aLo
+		for _, i := range occurrences {
+			if i < regionEnd {
+				continue
+			}
+
+			aStart, bStart := i, j
+			for aStart > aLo && bStart > bLo && m.a[aStart-1] == m.b[bStart-1] {
+				aStart--
+				bStart--
+			}
+			aEnd, bEnd := i+1, j+1
+			for aEnd < aHi && bEnd < bHi && m.a[aEnd] == m.b[bEnd] {
+				aEnd++
+				bEnd++
+			}
+			if bEnd > next {
+				next = bEnd
+			}
+			regionEnd = aEnd
+
+			count := len(occurrences)
+			for k := aStart; k < aEnd && count > 1; k++ {
+				if n := len(positions[m.a[k]]); n < count {
+					count = n
+				}
+			}
+			if count < bestCount || (count == bestCount && aEnd-aStart > best.aEnd-best.aStart) {
+				bestCount = count
+				best.aStart, best.aEnd, best.bStart, best.bEnd = aStart, aEnd, bStart, bEnd
+			}
+		}
+		j = next
+	}
+
+	if bestCount > maxHistogramChain {
+		m.compare(aLo, aHi, bLo, bHi)
+		return
+	}
+	m.histogram(aLo, best.aStart, bLo, best.bStart)
+	m.histogram(best.aEnd, aHi, best.bEnd, bHi)
+}
@@ -0,0 +1,133 @@
+package gitdiff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var edCommandRegexp = regexp.MustCompile(`^(\d+)(?:,(\d+))?([acd])\n$`)
+
+// edCommand is a command from an ed script that changes lines [start, end]
+// of the original content, or appends after line start.
+type edCommand struct {
+	op         byte
+	start, end int64
+	lines      []string
+}
+
+// ParseEdScript parses an ed script diff, like the output of "diff -e", and
+// returns a File with text fragments for its changes, like "patch" does when
+// it applies an ed script. Scripts do not include file names or the content of
+// deleted lines, so ParseEdScript reads deleted lines from src, which must
+// contain the original content of the file. The fragments include context
+// lines from src, like the output of "diff -u". Only the append, change, and
+// delete commands produced by diff are supported.
+func ParseEdScript(script, src io.Reader) (*File, error) {
+	cmds, err := parseEdCommands(script)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(cmds, func(i, j int) bool {
+		return cmds[i].start < cmds[j].start
+	})
+
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	orig := splitLines(string(data))
+
+	f := &File{}
+	var delta, next int64
+	for _, cmd := range cmds {
+		start := cmd.start
+		if cmd.op == 'a' {
+			start++
+		}
+		if start < next || cmd.end > int64(len(orig)) {
+			return nil, fmt.Errorf("gitdiff: ed script: command at line %d overlaps another command or is outside the file", cmd.start)
+		}
+
+		var lines []Line
+		if cmd.op != 'a' {
+			for _, line := range orig[start-1 : cmd.end] {
+				lines = append(lines, Line{OpDelete, line})
+			}
+		}
+		for _, line := range cmd.lines {
+			lines = append(lines, Line{OpAdd, line})
+		}
+
+		frag := &TextFragment{}
+		frag.setLines(lines)
+		frag.OldPosition = fragmentPosition(start, frag.OldLines)
+		frag.NewPosition = fragmentPosition(start+delta, frag.NewLines)
+		f.TextFragments = append(f.TextFragments, frag)
+
+		delta += frag.NewLines - frag.OldLines
+		next = start + frag.OldLines
+	}
+
+	f.TextFragments = recontext(f.TextFragments, defaultDiffContext, func(i int64) (string, bool) {
+		if i < 0 || i >= int64(len(orig)) {
+			return "", false
+		}
+		return orig[i], true
+	})
+	return f, nil
+}
+
+func parseEdCommands(r io.Reader) ([]edCommand, error) {
+	var cmds []edCommand
+	br := bufio.NewReader(r)
+
+	var lineno int64
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" {
+			return cmds, nil
+		}
+		lineno++
+
+		m := edCommandRegexp.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("gitdiff: ed script: line %d: unsupported command: %q", lineno, line)
+		}
+
+		cmd := edCommand{op: m[3][0]}
+		cmd.start, _ = strconv.ParseInt(m[1], 10, 64)
+		cmd.end = cmd.start
+		if m[2] != "" {
+			cmd.end, _ = strconv.ParseInt(m[2], 10, 64)
+		}
+		if (cmd.op != 'a' && cmd.start < 1) || cmd.end < cmd.start || (cmd.op == 'a' && m[2] != "") {
+			return nil, fmt.Errorf("gitdiff: ed script: line %d: invalid range: %q", lineno, line)
+		}
+
+		if cmd.op != 'd' {
+			for {
+				text, err := br.ReadString('\n')
+				if err != nil && err != io.EOF {
+					return nil, err
+				}
+				if text == "" {
+					return nil, fmt.Errorf("gitdiff: ed script: line %d: missing end of text", lineno)
+				}
+				lineno++
+				if text == ".\n" || text == "." {
+					break
+				}
+				cmd.lines = append(cmd.lines, text)
+			}
+		}
+		cmds = append(cmds, cmd)
+	}
+}
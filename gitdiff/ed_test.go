@@ -0,0 +1,77 @@
+package gitdiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEdScript(t *testing.T) {
+	script, err := os.Open(filepath.Join("testdata", "ed_script.patch"))
+	if err != nil {
+		t.Fatalf("failed to open script: %v", err)
+	}
+	defer script.Close()
+
+	src, err := os.ReadFile(filepath.Join("testdata", "ed_script.src"))
+	if err != nil {
+		t.Fatalf("failed to read source: %v", err)
+	}
+
+	f, err := ParseEdScript(script, bytes.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error parsing script: %v", err)
+	}
+	if len(f.TextFragments) != 2 {
+		t.Fatalf("incorrect number of fragments: expected 2, actual %d", len(f.TextFragments))
+	}
+
+	var dst bytes.Buffer
+	if err := Apply(&dst, bytes.NewReader(src), f); err != nil {
+		t.Fatalf("unexpected error applying file: %v", err)
+	}
+
+	expected := "1\n2\nthree\n" + numberLines(4, 14) + "14.5\n15\n"
+	if dst.String() != expected {
+		t.Errorf("incorrect result\nexpected: %q\n  actual: %q", expected, dst.String())
+	}
+}
+
+func TestParseEdScriptErrors(t *testing.T) {
+	src := numberLines(1, 5)
+
+	tests := map[string]struct {
+		Script string
+		Err    string
+	}{
+		"unsupportedCommand": {
+			Script: "1s/1/one/\n",
+			Err:    "unsupported command",
+		},
+		"missingEndOfText": {
+			Script: "2a\nnew\n",
+			Err:    "missing end of text",
+		},
+		"invalidRange": {
+			Script: "3,2d\n",
+			Err:    "invalid range",
+		},
+		"outsideFile": {
+			Script: "5,6d\n",
+			Err:    "outside the file",
+		},
+		"overlapping": {
+			Script: "3,4d\n2,3d\n",
+			Err:    "overlaps",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseEdScript(strings.NewReader(test.Script), strings.NewReader(src))
+			assertError(t, test.Err, err, "parsing script")
+		})
+	}
+}
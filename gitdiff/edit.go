@@ -0,0 +1,433 @@
+package gitdiff
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RemoveFragment removes the text fragment at index i from the file, as if
+// the changes in the fragment were not part of the patch. It adjusts the new
+// positions of the following fragments so that the file still applies.
+// RemoveFragment panics if i is out of range.
+func (f *File) RemoveFragment(i int) {
+	_ = f.LoadFragments()
+	removed := f.TextFragments[i]
+	f.TextFragments = append(f.TextFragments[:i], f.TextFragments[i+1:]...)
+	for _, frag := range f.TextFragments[i:] {
+		frag.NewPosition += removed.OldLines - removed.NewLines
+	}
+}
+
+// Filter removes the text fragments of the file for which keep returns false,
+// adjusting the new positions of the remaining fragments in the same way as
+// RemoveFragment. Filter calls keep with the fragments in order.
+func (f *File) Filter(keep func(*TextFragment) bool) {
+	if err := f.LoadFragments(); err != nil {
+		return
+	}
+	var delta int64
+	frags := f.TextFragments[:0]
+	for _, frag := range f.TextFragments {
+		if !keep(frag) {
+			delta += frag.OldLines - frag.NewLines
+			continue
+		}
+		frag.NewPosition += delta
+		frags = append(frags, frag)
+	}
+	f.TextFragments = frags
+}
+
+// SelectFragments returns a copy of the file that only contains the text
+// fragments at the given indices, like the hunks chosen in "git add -p". The
+// copy adjusts the new positions of the selected fragments to account for the
+// fragments that are not selected, so that it applies to the original file
+// and produces a file with only the selected changes. Indices may be in any
+// order, but the copy keeps the fragments in the order of the file.
+// SelectFragments returns an error if an index is out of range or repeated.
+//
+// The copy shares the lines of the fragments with f, but modifying the other
+// fields of the copy or its fragments does not modify f. It has no new object
+// ID if any fragment is not selected, since the result no longer matches the
+// patch. A deleted file where not all fragments are selected becomes a
+// modification of the original file. To undo only the selected changes in a
+// file that contains all of them, like "git checkout -p", select fragments of
+// the reversed file returned by Reverse.
+func (f *File) SelectFragments(indices ...int) (*File, error) {
+	if err := f.LoadFragments(); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[int]bool, len(indices))
+	for _, i := range indices {
+		if i < 0 || i >= len(f.TextFragments) {
+			return nil, fmt.Errorf("cannot select fragment %d: fragment is not in the file", i)
+		}
+		if selected[i] {
+			return nil, fmt.Errorf("cannot select fragment %d: fragment is selected more than once", i)
+		}
+		selected[i] = true
+	}
+	return f.SelectFragmentsFunc(func(i int, _ *TextFragment) bool {
+		return selected[i]
+	})
+}
+
+// SelectFragmentsFunc returns a copy of the file that only contains the text
+// fragments for which keep returns true, in the same way as SelectFragments.
+// SelectFragmentsFunc calls keep with the index of each fragment and the
+// fragment in order. It loads the fragments of files parsed with
+// WithLazyFragments and returns an error if they can not be loaded.
+func (f *File) SelectFragmentsFunc(keep func(i int, frag *TextFragment) bool) (*File, error) {
+	return f.selectFragments(func(i int, frag *TextFragment) (*TextFragment, error) {
+		if !keep(i, frag) {
+			return nil, nil
+		}
+		c := *frag
+		return &c, nil
+	})
+}
+
+// SelectLines returns a copy of the file that only contains the added and
+// deleted lines for which keep returns true, like staging individual lines
+// in Git GUIs or editing a hunk in "git add -p". SelectLines calls keep in
+// order with the index of each fragment, the index of each added or deleted
+// line in the Lines of the fragment, and the line. It does not call keep for
+// context lines.
+//
+// Deleted lines that are not selected become context lines, since they stay
+// in the file, and added lines that are not selected are removed. The copy
+// adjusts the new positions of the fragments and removes fragments that no
+// longer contain changes, in the same way as SelectFragments. See
+// TextFragment.SelectLines for how the lines of each fragment change.
+func (f *File) SelectLines(keep func(frag, line int, l Line) bool) (*File, error) {
+	return f.selectFragments(func(i int, frag *TextFragment) (*TextFragment, error) {
+		c, err := frag.SelectLines(func(j int, l Line) bool {
+			return keep(i, j, l)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fragment %d: %w", i, err)
+		}
+		if c.LinesAdded == 0 && c.LinesDeleted == 0 {
+			return nil, nil
+		}
+		return c, nil
+	})
+}
+
+// selectFragments returns a copy of the file with the fragments returned by
+// sel, which returns a copy of each selected fragment with the same old lines
+// or nil if no changes in the fragment are selected.
+func (f *File) selectFragments(sel func(i int, frag *TextFragment) (*TextFragment, error)) (*File, error) {
+	if err := f.LoadFragments(); err != nil {
+		return nil, err
+	}
+
+	selected := *f
+	selected.TextFragments = nil
+
+	var delta int64
+	partial := false
+	for i, frag := range f.TextFragments {
+		c, err := sel(i, frag)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			delta += frag.OldLines - frag.NewLines
+			partial = true
+			continue
+		}
+		c.NewPosition = fragmentPosition(fragmentStart(frag.NewPosition, frag.NewLines)+delta, c.NewLines)
+		delta += c.NewLines - frag.NewLines
+		partial = partial || c.LinesAdded != frag.LinesAdded || c.LinesDeleted != frag.LinesDeleted
+		selected.TextFragments = append(selected.TextFragments, c)
+	}
+
+	if partial {
+		selected.NewOIDPrefix = ""
+		if selected.IsDelete {
+			selected.IsDelete = false
+			selected.NewName = selected.OldName
+			selected.NewMode = selected.OldMode
+		}
+	}
+	return &selected, nil
+}
+
+// SelectLines returns a copy of the fragment that only contains the added and
+// deleted lines for which keep returns true. SelectLines calls keep in order
+// with the index of each added or deleted line in Lines and the line. It does
+// not call keep for context lines.
+//
+// Deleted lines that are not selected become context lines and added lines
+// that are not selected are removed. Within each block of consecutive changes,
+// the deleted lines stay in their original order, so that the fragment still
+// matches the old content, and the selected added lines follow them. The copy
+// has updated line counts and the same old position as f, but does not
+// account for changes in other fragments of the file; use File.SelectLines to
+// select lines in all fragments of a file.
+//
+// SelectLines returns an error if the selection would leave a line without a
+// newline in the middle of the fragment, which happens when a change to the
+// final newline of a file is only partially selected.
+func (f *TextFragment) SelectLines(keep func(i int, line Line) bool) (*TextFragment, error) {
+	var lines, adds []Line
+	for i, line := range f.Lines {
+		switch {
+		case line.Op == OpContext:
+			lines = append(lines, adds...)
+			lines = append(lines, line)
+			adds = adds[:0]
+		case line.Op == OpAdd:
+			if keep(i, line) {
+				adds = append(adds, line)
+			}
+		case keep(i, line):
+			lines = append(lines, line)
+		default:
+			lines = append(lines, Line{OpContext, line.Line})
+		}
+	}
+	lines = append(lines, adds...)
+
+	for i, line := range lines {
+		if !line.NoEOL() {
+			continue
+		}
+		for _, next := range lines[i+1:] {
+			if (line.Old() && next.Old()) || (line.New() && next.New()) {
+				return nil, fmt.Errorf("cannot select lines: line %d would not end with a newline", i+1)
+			}
+		}
+	}
+
+	c := &TextFragment{Comment: f.Comment, OldPosition: f.OldPosition}
+	c.setLines(lines)
+	c.NewPosition = fragmentPosition(fragmentStart(f.NewPosition, f.NewLines), c.NewLines)
+	return c, nil
+}
+
+// SplitFragment splits the text fragment at index i into two fragments, where
+// the second fragment starts with the line at index line in the fragment. The
+// lines on both sides of the split must be context lines and both fragments
+// must contain changes, so that the fragments apply together or on their own.
+func (f *File) SplitFragment(i, line int) error {
+	if err := f.LoadFragments(); err != nil {
+		return err
+	}
+	frag := f.TextFragments[i]
+	if line <= 0 || line >= len(frag.Lines) {
+		return fmt.Errorf("cannot split fragment at line %d: line is not in the fragment", line)
+	}
+	if frag.Lines[line-1].Op != OpContext || frag.Lines[line].Op != OpContext {
+		return fmt.Errorf("cannot split fragment at line %d: split is not between context lines", line)
+	}
+
+	first := &TextFragment{Comment: frag.Comment}
+	first.setLines(frag.Lines[:line])
+	second := &TextFragment{Comment: frag.Comment}
+	second.setLines(frag.Lines[line:])
+
+	if first.LinesAdded == 0 && first.LinesDeleted == 0 {
+		return fmt.Errorf("cannot split fragment at line %d: no changes before the line", line)
+	}
+	if second.LinesAdded == 0 && second.LinesDeleted == 0 {
+		return fmt.Errorf("cannot split fragment at line %d: no changes after the line", line)
+	}
+
+	oldStart := fragmentStart(frag.OldPosition, frag.OldLines)
+	newStart := fragmentStart(frag.NewPosition, frag.NewLines)
+
+	first.OldPosition = fragmentPosition(oldStart, first.OldLines)
+	first.NewPosition = fragmentPosition(newStart, first.NewLines)
+
+	second.OldPosition = fragmentPosition(oldStart+first.OldLines, second.OldLines)
+	second.NewPosition = fragmentPosition(newStart+first.NewLines, second.NewLines)
+
+	frags := make([]*TextFragment, 0, len(f.TextFragments)+1)
+	frags = append(frags, f.TextFragments[:i]...)
+	frags = append(frags, first, second)
+	frags = append(frags, f.TextFragments[i+1:]...)
+	f.TextFragments = frags
+	return nil
+}
+
+// CoalesceFragments merges text fragments of the file that overlap or touch,
+// like fragments created by adding context or by editing a patch by hand,
+// into fragments that do not overlap, and recomputes the new positions of all
+// fragments from the old positions and the line counts. Fragments repeated
+// in the file are only merged once. If fragments change the same lines in
+// different ways or disagree about the content of a line, CoalesceFragments
+// returns an error and does not modify the file.
+func (f *File) CoalesceFragments() error {
+	if err := f.LoadFragments(); err != nil {
+		return err
+	}
+	if len(f.TextFragments) == 0 {
+		return nil
+	}
+
+	frags := make([]*TextFragment, len(f.TextFragments))
+	copy(frags, f.TextFragments)
+	sort.SliceStable(frags, func(i, j int) bool {
+		return fragmentStart(frags[i].OldPosition, frags[i].OldLines) < fragmentStart(frags[j].OldPosition, frags[j].OldLines)
+	})
+
+	var result []*TextFragment
+	for len(frags) > 0 {
+		start, end := fragmentRange(frags[0].OldPosition, frags[0].OldLines)
+		k := 1
+		for ; k < len(frags); k++ {
+			next, nextEnd := fragmentRange(frags[k].OldPosition, frags[k].OldLines)
+			if next > end {
+				break
+			}
+			if nextEnd > end {
+				end = nextEnd
+			}
+		}
+
+		frag := frags[0]
+		if k > 1 {
+			var err error
+			if frag, err = coalesceFragments(frags[:k], start, end); err != nil {
+				return err
+			}
+		}
+		result = append(result, frag)
+		frags = frags[k:]
+	}
+
+	first := f.TextFragments[0]
+	delta := fragmentStart(first.NewPosition, first.NewLines) - fragmentStart(first.OldPosition, first.OldLines)
+	for _, frag := range result {
+		frag.NewPosition = fragmentPosition(fragmentStart(frag.OldPosition, frag.OldLines)+delta, frag.NewLines)
+		delta += frag.NewLines - frag.OldLines
+	}
+
+	f.TextFragments = result
+	return nil
+}
+
+// coalesceFragments merges fragments that cover the old lines from start to
+// end, one-indexed and exclusive, into a single fragment. The fragments must
+// be sorted by their old positions. It does not set the new position of the
+// merged fragment.
+func coalesceFragments(frags []*TextFragment, start, end int64) (*TextFragment, error) {
+	known := make(map[int64]string)
+	setKnown := func(i int64, line string) error {
+		if prev, ok := known[i]; ok && prev != line {
+			return fmt.Errorf("cannot coalesce fragments: fragments do not agree on old line %d", i)
+		}
+		known[i] = line
+		return nil
+	}
+
+	var hunks []contextHunk
+	for _, frag := range frags {
+		pos := fragmentStart(frag.OldPosition, frag.OldLines)
+		for i := 0; i < len(frag.Lines); {
+			if line := frag.Lines[i]; line.Op == OpContext {
+				if err := setKnown(pos, line.Line); err != nil {
+					return nil, err
+				}
+				pos++
+				i++
+				continue
+			}
+
+			h := contextHunk{oldStart: pos}
+			for ; i < len(frag.Lines) && frag.Lines[i].Op != OpContext; i++ {
+				line := frag.Lines[i]
+				if line.Old() {
+					if err := setKnown(pos, line.Line); err != nil {
+						return nil, err
+					}
+					pos++
+				}
+				h.lines = append(h.lines, line)
+			}
+			h.oldEnd = pos
+			hunks = append(hunks, h)
+		}
+	}
+
+	sort.SliceStable(hunks, func(i, j int) bool {
+		return hunks[i].oldStart < hunks[j].oldStart
+	})
+
+	var lines []Line
+	next := start
+	for i, h := range hunks {
+		if i > 0 {
+			prev := hunks[i-1]
+			if h.oldStart == prev.oldStart && h.oldEnd == prev.oldEnd && equalFragmentLines(h.lines, prev.lines) {
+				continue
+			}
+			if h.oldStart < prev.oldEnd || h.oldStart == prev.oldStart {
+				return nil, fmt.Errorf("cannot coalesce fragments: fragments change old line %d in different ways", h.oldStart)
+			}
+		}
+		for ; next < h.oldStart; next++ {
+			lines = append(lines, Line{OpContext, known[next]})
+		}
+		lines = append(lines, h.lines...)
+		next = h.oldEnd
+	}
+	for ; next < end; next++ {
+		lines = append(lines, Line{OpContext, known[next]})
+	}
+
+	frag := &TextFragment{Comment: frags[0].Comment}
+	frag.setLines(lines)
+	frag.OldPosition = fragmentPosition(start, frag.OldLines)
+	return frag, nil
+}
+
+func equalFragmentLines(a, b []Line) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fragmentPosition returns the position in the header of one side of a
+// fragment that starts at line start. It is the inverse of fragmentStart.
+func fragmentPosition(start, lines int64) int64 {
+	if lines == 0 {
+		return start - 1
+	}
+	return start
+}
+
+// Recount updates the line counts of the fragment to match its lines, like
+// "git apply --recount". Use it after editing the lines of a fragment. Recount
+// does not change the positions of the fragment.
+func (f *TextFragment) Recount() {
+	f.setLines(f.Lines)
+}
+
+// setLines replaces the lines of the fragment and updates all of the line
+// counts to match the new lines.
+func (f *TextFragment) setLines(lines []Line) {
+	f.Lines = nil
+	f.OldLines, f.NewLines = 0, 0
+	f.LinesAdded, f.LinesDeleted = 0, 0
+	f.LeadingContext, f.TrailingContext = 0, 0
+
+	for _, l := range lines {
+		f.addLines(l.Op, []string{l.Line})
+		if l.Old() {
+			f.OldLines++
+		}
+		if l.New() {
+			f.NewLines++
+		}
+	}
+}
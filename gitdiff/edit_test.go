@@ -0,0 +1,586 @@
+package gitdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFileRemoveFragment(t *testing.T) {
+	f := parseTestPatch(t, "edit_three.patch")[0]
+	f.RemoveFragment(1)
+
+	expected := `@@ -2,3 +2,3 @@
+ 2
+-3
++three
+ 4
+@@ -16,3 +16,2 @@
+ 16
+-17
+ 18
+`
+	assertEditFragments(t, expected, f)
+	assertEditApply(t, f, "1 2 three 4 5 6 7 8 9 10 11 12 13 14 15 16 18 19 20")
+}
+
+func TestFileFilter(t *testing.T) {
+	f := parseTestPatch(t, "edit_three.patch")[0]
+
+	var seen []int64
+	f.Filter(func(frag *TextFragment) bool {
+		seen = append(seen, frag.OldPosition)
+		return frag.LinesDeleted == 0
+	})
+
+	if len(seen) != 3 || seen[0] != 2 || seen[1] != 10 || seen[2] != 16 {
+		t.Errorf("incorrect fragments passed to filter function: %v", seen)
+	}
+
+	expected := `@@ -10,2 +10,4 @@
+ 10
++ten a
++ten b
+ 11
+`
+	assertEditFragments(t, expected, f)
+	assertEditApply(t, f, "1 2 3 4 5 6 7 8 9 10 ten a ten b 11 12 13 14 15 16 17 18 19 20")
+}
+
+func TestFileSelectFragments(t *testing.T) {
+	f := parseTestPatch(t, "edit_three.patch")[0]
+
+	selected, err := f.SelectFragments(2, 0)
+	if err != nil {
+		t.Fatalf("unexpected error selecting fragments: %v", err)
+	}
+
+	expected := `@@ -2,3 +2,3 @@
+ 2
+-3
++three
+ 4
+@@ -16,3 +16,2 @@
+ 16
+-17
+ 18
+`
+	assertEditFragments(t, expected, selected)
+	assertEditApply(t, selected, "1 2 three 4 5 6 7 8 9 10 11 12 13 14 15 16 18 19 20")
+
+	if selected.NewOIDPrefix != "" {
+		t.Errorf("expected no new OID for partial selection, but got %q", selected.NewOIDPrefix)
+	}
+	if len(f.TextFragments) != 3 || f.TextFragments[2].NewPosition != 18 || f.NewOIDPrefix == "" {
+		t.Errorf("selecting fragments modified the original file")
+	}
+}
+
+func TestFileSelectFragmentsFunc(t *testing.T) {
+	f := parseTestPatch(t, "edit_three.patch")[0]
+
+	var seen []int
+	selected, err := f.SelectFragmentsFunc(func(i int, frag *TextFragment) bool {
+		seen = append(seen, i)
+		return frag.LinesAdded > 0
+	})
+	if err != nil {
+		t.Fatalf("unexpected error selecting fragments: %v", err)
+	}
+	if len(seen) != 3 || seen[0] != 0 || seen[1] != 1 || seen[2] != 2 {
+		t.Errorf("incorrect indices passed to select function: %v", seen)
+	}
+
+	expected := `@@ -2,3 +2,3 @@
+ 2
+-3
++three
+ 4
+@@ -10,2 +10,4 @@
+ 10
++ten a
++ten b
+ 11
+`
+	assertEditFragments(t, expected, selected)
+	assertEditApply(t, selected, "1 2 three 4 5 6 7 8 9 10 ten a ten b 11 12 13 14 15 16 17 18 19 20")
+
+	t.Run("reverse", func(t *testing.T) {
+		// undo only the last change in a file with all of the changes
+		r, err := f.Reverse().SelectFragments(2)
+		if err != nil {
+			t.Fatalf("unexpected error selecting fragments: %v", err)
+		}
+
+		var src strings.Builder
+		for _, line := range strings.Fields("1 2 three 4 5 6 7 8 9 10 ten_a ten_b 11 12 13 14 15 16 18 19 20") {
+			src.WriteString(strings.Replace(line, "_", " ", 1) + "\n")
+		}
+
+		var dst bytes.Buffer
+		if err := NewApplier(strings.NewReader(src.String())).ApplyFile(&dst, r); err != nil {
+			t.Fatalf("unexpected error applying selected fragments: %v", err)
+		}
+
+		expected := strings.Replace(src.String(), "16\n18\n", "16\n17\n18\n", 1)
+		if dst.String() != expected {
+			t.Errorf("incorrect result\nexpected:\n%s\nactual:\n%s", expected, dst.String())
+		}
+	})
+}
+
+func TestFileSelectFragmentsDelete(t *testing.T) {
+	f := &File{
+		OldName:  "f.txt",
+		OldMode:  0100644,
+		IsDelete: true,
+		TextFragments: []*TextFragment{
+			{OldPosition: 1, OldLines: 1, LinesDeleted: 1, Lines: []Line{{OpDelete, "a\n"}}},
+		},
+	}
+
+	selected, err := f.SelectFragments()
+	if err != nil {
+		t.Fatalf("unexpected error selecting fragments: %v", err)
+	}
+	if selected.IsDelete || selected.NewName != "f.txt" || selected.NewMode != 0100644 || len(selected.TextFragments) != 0 {
+		t.Errorf("expected modification without fragments, but got %+v", selected)
+	}
+}
+
+func TestFileSelectFragmentsErrors(t *testing.T) {
+	tests := map[string][]int{
+		"negativeIndex": {-1},
+		"indexTooLarge": {3},
+		"repeatedIndex": {1, 0, 1},
+	}
+
+	for name, indices := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := parseTestPatch(t, "edit_three.patch")[0]
+			if _, err := f.SelectFragments(indices...); err == nil {
+				t.Fatalf("expected error selecting fragments %v, but got nil", indices)
+			}
+		})
+	}
+}
+
+func TestTextFragmentSelectLines(t *testing.T) {
+	frag := &TextFragment{
+		OldPosition: 5,
+		OldLines:    4,
+		NewPosition: 5,
+		NewLines:    4,
+		Lines: []Line{
+			{OpContext, "context\n"},
+			{OpDelete, "a\n"},
+			{OpDelete, "b\n"},
+			{OpAdd, "A\n"},
+			{OpAdd, "B\n"},
+			{OpContext, "end\n"},
+		},
+	}
+	frag.Recount()
+
+	tests := map[string]struct {
+		Frag     *TextFragment
+		Selected []int
+		Output   string
+		Err      bool
+	}{
+		"all": {
+			Frag:     frag,
+			Selected: []int{1, 2, 3, 4},
+			Output:   "@@ -5,4 +5,4 @@\n context\n-a\n-b\n+A\n+B\n end\n",
+		},
+		"none": {
+			Frag:   frag,
+			Output: "@@ -5,4 +5,4 @@\n context\n a\n b\n end\n",
+		},
+		"replaceFirst": {
+			Frag:     frag,
+			Selected: []int{1, 3},
+			Output:   "@@ -5,4 +5,4 @@\n context\n-a\n b\n+A\n end\n",
+		},
+		"deleteOnly": {
+			Frag:     frag,
+			Selected: []int{2},
+			Output:   "@@ -5,4 +5,3 @@\n context\n a\n-b\n end\n",
+		},
+		"addOnly": {
+			Frag:     frag,
+			Selected: []int{4},
+			Output:   "@@ -5,4 +5,5 @@\n context\n a\n b\n+B\n end\n",
+		},
+		"newLinesEmpty": {
+			Frag: &TextFragment{
+				OldPosition: 1,
+				OldLines:    2,
+				NewPosition: 0,
+				NewLines:    0,
+				Lines:       []Line{{OpDelete, "a\n"}, {OpDelete, "b\n"}},
+			},
+			Selected: []int{1},
+			Output:   "@@ -1,2 +1 @@\n a\n-b\n",
+		},
+		"partialMissingNewline": {
+			Frag: &TextFragment{
+				OldPosition: 1,
+				OldLines:    1,
+				NewPosition: 1,
+				NewLines:    1,
+				Lines:       []Line{{OpDelete, "a"}, {OpAdd, "b\n"}},
+			},
+			Selected: []int{1},
+			Err:      true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.Frag.Recount()
+			selected := make(map[int]bool)
+			for _, i := range test.Selected {
+				selected[i] = true
+			}
+
+			var seen []int
+			c, err := test.Frag.SelectLines(func(i int, line Line) bool {
+				if line.Op == OpContext {
+					t.Errorf("select function called for context line %d", i)
+				}
+				seen = append(seen, i)
+				return selected[i]
+			})
+			if test.Err {
+				if err == nil {
+					t.Fatalf("expected error selecting lines, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error selecting lines: %v", err)
+			}
+			if err := c.Validate(); err != nil {
+				t.Errorf("selected fragment is invalid: %v", err)
+			}
+			if c.String() != test.Output {
+				t.Errorf("incorrect fragment\nexpected:\n%s\nactual:\n%s", test.Output, c.String())
+			}
+		})
+	}
+}
+
+func TestFileSelectLines(t *testing.T) {
+	f := parseTestPatch(t, "edit_three.patch")[0]
+
+	selected, err := f.SelectLines(func(frag, line int, l Line) bool {
+		return l.Line == "ten a\n" || l.Line == "17\n"
+	})
+	if err != nil {
+		t.Fatalf("unexpected error selecting lines: %v", err)
+	}
+
+	expected := `@@ -10,2 +10,3 @@
+ 10
++ten a
+ 11
+@@ -16,3 +17,2 @@
+ 16
+-17
+ 18
+`
+	assertEditFragments(t, expected, selected)
+	assertEditApply(t, selected, "1 2 3 4 5 6 7 8 9 10 ten a 11 12 13 14 15 16 18 19 20")
+
+	if selected.NewOIDPrefix != "" {
+		t.Errorf("expected no new OID for partial selection, but got %q", selected.NewOIDPrefix)
+	}
+	if len(f.TextFragments) != 3 || f.TextFragments[1].NewLines != 4 {
+		t.Errorf("selecting lines modified the original file")
+	}
+}
+
+func TestFileSelectLinesApply(t *testing.T) {
+	randomContent := func(r *rand.Rand, n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteString(string(rune('a' + r.Intn(5))))
+			b.WriteByte('\n')
+		}
+		return b.String()
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		old, new := randomContent(r, 1+r.Intn(30)), randomContent(r, r.Intn(30))
+
+		f, err := Diff(strings.NewReader(old), strings.NewReader(new), WithContext(1+r.Intn(3)))
+		if err != nil {
+			t.Fatalf("unexpected error computing diff: %v", err)
+		}
+
+		var adds, deletes int
+		selected, err := f.SelectLines(func(frag, line int, l Line) bool {
+			if r.Intn(2) == 0 {
+				return false
+			}
+			if l.Op == OpAdd {
+				adds++
+			} else {
+				deletes++
+			}
+			return true
+		})
+		if err != nil {
+			t.Fatalf("unexpected error selecting lines: %v", err)
+		}
+
+		var out bytes.Buffer
+		if err := Apply(&out, strings.NewReader(old), selected); err != nil {
+			t.Fatalf("unexpected error applying selected lines: %v\nold: %q\nnew: %q\ndiff:\n%s", err, old, new, selected)
+		}
+		if expected, actual := strings.Count(old, "\n")-deletes+adds, strings.Count(out.String(), "\n"); actual != expected {
+			t.Fatalf("incorrect number of lines after apply: expected %d, actual %d\nold: %q\nnew: %q\ndiff:\n%s", expected, actual, old, new, selected)
+		}
+	}
+}
+
+func TestFileSplitFragment(t *testing.T) {
+	f := parseTestPatch(t, "edit_split.patch")[0]
+
+	if err := f.SplitFragment(0, 5); err != nil {
+		t.Fatalf("unexpected error splitting fragment: %v", err)
+	}
+	if err := f.SplitFragment(1, 6); err != nil {
+		t.Fatalf("unexpected error splitting fragment: %v", err)
+	}
+
+	expected := `@@ -1,4 +1,4 @@
+ 1
+ 2
+-3
++three
+ 4
+@@ -5,5 +5,5 @@
+ 5
+-6
++six
+ 7
+ 8
+ 9
+@@ -10,11 +10,12 @@
+ 10
++ten a
++ten b
+ 11
+ 12
+ 13
+ 14
+ 15
+ 16
+-17
+ 18
+ 19
+ 20
+`
+	assertEditFragments(t, expected, f)
+	for i, frag := range f.TextFragments {
+		if err := frag.Validate(); err != nil {
+			t.Errorf("fragment %d is invalid after split: %v", i, err)
+		}
+	}
+	assertEditApply(t, f, "1 2 three 4 5 six 7 8 9 10 ten a ten b 11 12 13 14 15 16 18 19 20")
+
+	f.RemoveFragment(1)
+	assertEditApply(t, f, "1 2 three 4 5 6 7 8 9 10 ten a ten b 11 12 13 14 15 16 18 19 20")
+}
+
+func TestFileSplitFragmentErrors(t *testing.T) {
+	tests := map[string]struct {
+		Line int
+		Err  string
+	}{
+		"firstLine": {
+			Line: 0,
+			Err:  "not in the fragment",
+		},
+		"afterLastLine": {
+			Line: 24,
+			Err:  "not in the fragment",
+		},
+		"nextToChange": {
+			Line: 4,
+			Err:  "not between context lines",
+		},
+		"noChangesBefore": {
+			Line: 1,
+			Err:  "no changes before",
+		},
+		"noChangesAfter": {
+			Line: 22,
+			Err:  "no changes after",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := parseTestPatch(t, "edit_split.patch")[0]
+
+			err := f.SplitFragment(0, test.Line)
+			assertError(t, test.Err, err, "splitting fragment")
+
+			if len(f.TextFragments) != 1 {
+				t.Errorf("file has %d fragments after failed split", len(f.TextFragments))
+			}
+		})
+	}
+}
+
+func TestFileCoalesceFragments(t *testing.T) {
+	f := parseTestPatch(t, "edit_coalesce.patch")[0]
+
+	if err := f.CoalesceFragments(); err != nil {
+		t.Fatalf("unexpected error coalescing fragments: %v", err)
+	}
+
+	expected := `@@ -1,9 +1,10 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
++six a
+ 7
+-8
++eight
+ 9
+@@ -15,3 +16,2 @@
+ 15
+-16
+ 17
+`
+	assertEditFragments(t, expected, f)
+	for i, frag := range f.TextFragments {
+		if err := frag.Validate(); err != nil {
+			t.Errorf("fragment %d is invalid after coalescing: %v", i, err)
+		}
+	}
+	assertEditApply(t, f, "1 2 three 4 5 6 six a 7 eight 9 10 11 12 13 14 15 17 18 19 20")
+}
+
+func TestFileCoalesceFragmentsErrors(t *testing.T) {
+	tests := map[string]struct {
+		Fragments string
+		Err       string
+	}{
+		"differentChanges": {
+			Fragments: `@@ -2,2 +2,2 @@
+-2
++two
+ 3
+@@ -2,2 +2,2 @@
+-2
++TWO
+ 3
+`,
+			Err: "change old line 2 in different ways",
+		},
+		"differentInsertions": {
+			Fragments: `@@ -2,0 +3 @@
++two a
+@@ -2,0 +3 @@
++two b
+`,
+			Err: "change old line 3 in different ways",
+		},
+		"differentContent": {
+			Fragments: `@@ -1,3 +1,3 @@
+ 1
+-2
++two
+ 3
+@@ -3,2 +3,2 @@
+ three
+-4
++four
+`,
+			Err: "do not agree on old line 3",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			patch := "diff --git a/f.txt b/f.txt\n--- a/f.txt\n+++ b/f.txt\n" + test.Fragments
+			files, _, err := ParseAll(strings.NewReader(patch))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			f := files[0]
+
+			err = f.CoalesceFragments()
+			assertError(t, test.Err, err, "coalescing fragments")
+
+			if len(f.TextFragments) != 2 {
+				t.Errorf("file has %d fragments after failed coalesce", len(f.TextFragments))
+			}
+		})
+	}
+}
+
+func assertEditFragments(t *testing.T, expected string, f *File) {
+	var b strings.Builder
+	for _, frag := range f.TextFragments {
+		b.WriteString(frag.String())
+	}
+	if b.String() != expected {
+		t.Errorf("incorrect fragments\nexpected:\n%s\nactual:\n%s", expected, b.String())
+	}
+}
+
+// assertEditApply applies the file to the numbers from 1 to 20, one per line,
+// and compares the result with its lines joined by spaces to expected.
+func assertEditApply(t *testing.T, f *File, expected string) {
+	var src strings.Builder
+	for i := 1; i <= 20; i++ {
+		src.WriteString(strconv.Itoa(i) + "\n")
+	}
+
+	var dst bytes.Buffer
+	if err := NewApplier(strings.NewReader(src.String())).ApplyFile(&dst, f); err != nil {
+		t.Fatalf("unexpected error applying edited file: %v", err)
+	}
+
+	actual := strings.TrimSpace(strings.Replace(dst.String(), "\n", " ", -1))
+	if actual != expected {
+		t.Errorf("incorrect result of applying edited file\nexpected: %s\n  actual: %s", expected, actual)
+	}
+}
+
+func TestTextFragmentRecount(t *testing.T) {
+	frag := &TextFragment{
+		OldPosition: 3,
+		OldLines:    1,
+		NewPosition: 3,
+		NewLines:    1,
+		Lines: []Line{
+			{OpContext, "context\n"},
+			{OpDelete, "old\n"},
+			{OpAdd, "new 1\n"},
+			{OpAdd, "new 2\n"},
+			{OpContext, "context\n"},
+		},
+	}
+	frag.Recount()
+
+	if err := frag.Validate(); err != nil {
+		t.Fatalf("fragment is invalid after recount: %v", err)
+	}
+	if frag.OldPosition != 3 || frag.OldLines != 3 || frag.NewPosition != 3 || frag.NewLines != 4 {
+		t.Errorf("incorrect fragment header after recount: %s", frag.Header())
+	}
+}
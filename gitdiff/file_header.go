@@ -1,6 +1,7 @@
 package gitdiff
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -13,6 +14,8 @@ const (
 	devNull = "/dev/null"
 )
 
+var errInvalidMode = errors.New("invalid mode line")
+
 // ParseNextFileHeader finds and parses the next file header in the stream. If
 // a header is found, it returns a file and all input before the header. It
 // returns nil if no headers are found before the end of the input.
@@ -20,6 +23,8 @@ func (p *parser) ParseNextFileHeader() (*File, string, error) {
 	var preamble strings.Builder
 	var file *File
 	for {
+		p.fileOffset = p.offset()
+
 		// check for disconnected fragment headers (corrupt patch)
 		frag, err := p.ParseTextFragmentHeader()
 		if err != nil {
@@ -27,7 +32,7 @@ func (p *parser) ParseNextFileHeader() (*File, string, error) {
 			goto NextLine
 		}
 		if frag != nil {
-			return nil, "", p.Errorf(-1, "patch fragment without file header: %s", frag.Header())
+			return nil, "", p.Errorf(-1, KindOrphanFragment, "patch fragment without file header: %s", frag.Header())
 		}
 
 		// check for a git-generated patch
@@ -39,6 +44,33 @@ func (p *parser) ParseNextFileHeader() (*File, string, error) {
 			return file, preamble.String(), nil
 		}
 
+		// check for a combined diff of a merge
+		file, err = p.ParseCombinedFileHeader()
+		if err != nil {
+			return nil, "", err
+		}
+		if file != nil {
+			return file, preamble.String(), nil
+		}
+
+		// check for a submodule change from "git diff --submodule=log"
+		file, err = p.ParseSubmoduleLog()
+		if err != nil {
+			return nil, "", err
+		}
+		if file != nil {
+			return file, preamble.String(), nil
+		}
+
+		// check for a "copied context" patch
+		file, err = p.ParseContextFileHeader()
+		if err != nil {
+			return nil, "", err
+		}
+		if file != nil {
+			return file, preamble.String(), nil
+		}
+
 		// check for a "traditional" patch
 		file, err = p.ParseTraditionalFileHeader()
 		if err != nil {
@@ -57,6 +89,7 @@ func (p *parser) ParseNextFileHeader() (*File, string, error) {
 			return nil, "", err
 		}
 	}
+	p.trailing = preamble.String()
 	return nil, "", nil
 }
 
@@ -68,16 +101,21 @@ func (p *parser) ParseGitFileHeader() (*File, error) {
 	}
 	header := p.Line(0)[len(prefix):]
 
-	defaultName, err := parseGitHeaderName(header)
+	drop := 1
+	if p.strip >= 0 {
+		drop = p.strip
+	}
+
+	defaultName, err := parseGitHeaderName(header, drop)
 	if err != nil {
-		return nil, p.Errorf(0, "git file header: %v", err)
+		return nil, p.Errorf(0, KindFileHeader, "git file header: %w", atColumn(err, len(prefix)))
 	}
 
-	f := &File{}
+	f := &File{HashAlgorithm: p.hashAlgorithm}
 	for {
-		end, err := parseGitHeaderData(f, p.Line(1), defaultName)
+		end, err := parseGitHeaderData(f, p.Line(1), defaultName, drop)
 		if err != nil {
-			return nil, p.Errorf(1, "git file header: %v", err)
+			return nil, p.Errorf(1, headerErrorKind(err), "git file header: %w", err)
 		}
 
 		if err := p.Next(); err != nil {
@@ -94,21 +132,97 @@ func (p *parser) ParseGitFileHeader() (*File, error) {
 
 	if f.OldName == "" && f.NewName == "" {
 		if defaultName == "" {
-			return nil, p.Errorf(0, "git file header: missing filename information")
+			return nil, p.Errorf(0, KindFileHeader, "git file header: missing filename information")
 		}
 		f.OldName = defaultName
 		f.NewName = defaultName
 	}
 
 	if (f.NewName == "" && !f.IsDelete) || (f.OldName == "" && !f.IsNew) {
-		return nil, p.Errorf(0, "git file header: missing filename information")
+		return nil, p.Errorf(0, KindFileHeader, "git file header: missing filename information")
 	}
 
+	if err := verifyGitHeaderOperation(f, header, drop); err != nil {
+		return nil, p.Errorf(0, KindFileHeader, "git file header: %w", err)
+	}
+	setHashAlgorithm(f)
+
 	return f, nil
 }
 
+// verifyGitHeaderOperation checks that the copy and rename lines of a Git file
+// header are consistent with the other lines and with the names in the header
+// line.
+func verifyGitHeaderOperation(f *File, header string, drop int) error {
+	if !f.IsCopy && !f.IsRename {
+		return nil
+	}
+
+	op := "rename"
+	if f.IsCopy {
+		op = "copy"
+	}
+
+	switch {
+	case f.IsCopy && f.IsRename:
+		return fmt.Errorf("inconsistent copy and rename")
+	case f.IsNew:
+		return fmt.Errorf("inconsistent %s of new file", op)
+	case f.IsDelete:
+		return fmt.Errorf("inconsistent %s of deleted file", op)
+	}
+
+	if header = strings.TrimSuffix(header, "\n"); header != "" && !matchGitHeaderNames(header, f.OldName, f.NewName, drop) {
+		return fmt.Errorf("%s names do not match header", op)
+	}
+	return nil
+}
+
+// matchGitHeaderNames returns true if the header line contains a pair of names
+// that match oldName and newName after dropping drop leading components.
+// Because copy and rename lines do not have prefixes, a header name matches if
+// it is equal to the other name or if one of the names ends with the other.
+func matchGitHeaderNames(header, oldName, newName string, drop int) bool {
+	match := func(first, rest string) bool {
+		second := rest
+		if strings.HasPrefix(rest, "\"") {
+			name, n, err := parseQuotedName(rest)
+			if err != nil || n != len(rest) {
+				return false
+			}
+			second = name
+		}
+		return matchPathSuffix(trimTreePrefix(first, drop), oldName) &&
+			matchPathSuffix(trimTreePrefix(second, drop), newName)
+	}
+
+	if strings.HasPrefix(header, "\"") {
+		first, n, err := parseQuotedName(header)
+		if err != nil {
+			return false
+		}
+		for n < len(header) && isSpace(header[n]) {
+			n++
+		}
+		return match(first, header[n:])
+	}
+
+	// names may contain spaces, so check every possible split
+	for i := 0; i < len(header)-1; i++ {
+		if isSpace(header[i]) && match(header[:i], header[i+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathSuffix returns true if a and b are equal or if one ends with the
+// other after a slash.
+func matchPathSuffix(a, b string) bool {
+	return a == b || strings.HasSuffix(a, "/"+b) || strings.HasSuffix(b, "/"+a)
+}
+
 func (p *parser) ParseTraditionalFileHeader() (*File, error) {
-	const shortestValidFragHeader = "@@ -1 +1 @@\n"
 	const (
 		oldPrefix = "--- "
 		newPrefix = "+++ "
@@ -120,7 +234,7 @@ func (p *parser) ParseTraditionalFileHeader() (*File, error) {
 		return nil, nil
 	}
 	// heuristic: only a file header if followed by a (probable) fragment header
-	if len(p.Line(2)) < len(shortestValidFragHeader) || !strings.HasPrefix(p.Line(2), "@@ -") {
+	if !p.isTextFragmentHeader(p.Line(2)) {
 		return nil, nil
 	}
 
@@ -132,18 +246,31 @@ func (p *parser) ParseTraditionalFileHeader() (*File, error) {
 	if err := p.Next(); err != nil {
 		return nil, err
 	}
+	return p.parseTraditionalNames(oldLine, newLine, oldPrefix, newPrefix)
+}
 
-	oldName, _, err := parseName(oldLine[len(oldPrefix):], '\t', 0)
+// parseTraditionalNames parses the old and new names from the header lines of
+// a traditional or context patch, where the lines are after the parser.
+func (p *parser) parseTraditionalNames(oldLine, newLine, oldPrefix, newPrefix string) (*File, error) {
+	drop := 0
+	if p.strip >= 0 {
+		drop = p.strip
+	}
+
+	oldName, _, err := parseName(p.trimTimestamp(oldLine[len(oldPrefix):]), '\t', drop)
 	if err != nil {
-		return nil, p.Errorf(0, "file header: %v", err)
+		return nil, p.Errorf(0, KindFileHeader, "file header: %w", atColumn(err, len(oldPrefix)))
 	}
 
-	newName, _, err := parseName(newLine[len(newPrefix):], '\t', 0)
+	newName, _, err := parseName(p.trimTimestamp(newLine[len(newPrefix):]), '\t', drop)
 	if err != nil {
-		return nil, p.Errorf(1, "file header: %v", err)
+		return nil, p.Errorf(1, KindFileHeader, "file header: %w", atColumn(err, len(newPrefix)))
 	}
 
 	f := &File{}
+	f.OldTime, _ = parseTimestamp(oldLine)
+	f.NewTime, _ = parseTimestamp(newLine)
+
 	switch {
 	case oldName == devNull || hasEpochTimestamp(oldLine):
 		f.IsNew = true
@@ -170,8 +297,9 @@ func (p *parser) ParseTraditionalFileHeader() (*File, error) {
 // line. This is required for mode-only changes and creation/deletion of empty
 // files. Other types of patch include the file name(s) in the header data.
 // If the names in the header do not match because the patch is a rename,
-// return an empty default name.
-func parseGitHeaderName(header string) (string, error) {
+// return an empty default name. The default name does not include the first
+// drop components of the names in the header.
+func parseGitHeaderName(header string, drop int) (string, error) {
 	header = strings.TrimSuffix(header, "\n")
 	if len(header) == 0 {
 		return "", nil
@@ -197,12 +325,12 @@ func parseGitHeaderName(header string) (string, error) {
 		// case 2
 		first = header[:quote-1]
 		if !isSpace(header[quote-1]) {
-			return "", fmt.Errorf("missing separator")
+			return "", atColumn(fmt.Errorf("missing separator"), quote-1)
 		}
 
 		second, _, err = parseQuotedName(header[quote:])
 		if err != nil {
-			return "", err
+			return "", atColumn(err, quote)
 		}
 
 	case quote == 0:
@@ -225,16 +353,16 @@ func parseGitHeaderName(header string) (string, error) {
 		if header[n] == '"' {
 			second, _, err = parseQuotedName(header[n:])
 			if err != nil {
-				return "", err
+				return "", atColumn(err, n)
 			}
 		} else {
 			second = header[n:]
 		}
 	}
 
-	first = trimTreePrefix(first, 1)
+	first = trimTreePrefix(first, drop)
 	if second != "" {
-		if first == trimTreePrefix(second, 1) {
+		if first == trimTreePrefix(second, drop) {
 			return first, nil
 		}
 		return "", nil
@@ -248,7 +376,7 @@ func parseGitHeaderName(header string) (string, error) {
 		if !isSpace(first[i]) {
 			continue
 		}
-		second = trimTreePrefix(first[i+1:], 1)
+		second = trimTreePrefix(first[i+1:], drop)
 		if name := first[:i]; name == second {
 			return name, nil
 		}
@@ -256,10 +384,12 @@ func parseGitHeaderName(header string) (string, error) {
 	return "", nil
 }
 
-// parseGitHeaderData parses a single line of metadata from a Git file header.
+// parseGitHeaderData parses a single line of metadata from a Git file header,
+// dropping drop leading components from names in "---" and "+++" lines. Names
+// in copy and rename lines have no prefix, so one fewer component is dropped.
 // It returns true when header parsing is complete; in that case, line was the
 // first line of non-header content.
-func parseGitHeaderData(f *File, line, defaultName string) (end bool, err error) {
+func parseGitHeaderData(f *File, line, defaultName string, drop int) (end bool, err error) {
 	if len(line) > 0 && line[len(line)-1] == '\n' {
 		line = line[:len(line)-1]
 	}
@@ -267,7 +397,7 @@ func parseGitHeaderData(f *File, line, defaultName string) (end bool, err error)
 	for _, hdr := range []struct {
 		prefix string
 		end    bool
-		parse  func(*File, string, string) error
+		parse  func(*File, string, string, int) error
 	}{
 		{"@@ -", true, nil},
 		{"--- ", false, parseGitHeaderOldName},
@@ -288,7 +418,7 @@ func parseGitHeaderData(f *File, line, defaultName string) (end bool, err error)
 	} {
 		if strings.HasPrefix(line, hdr.prefix) {
 			if hdr.parse != nil {
-				err = hdr.parse(f, line[len(hdr.prefix):], defaultName)
+				err = atColumn(hdr.parse(f, line[len(hdr.prefix):], defaultName, drop), len(hdr.prefix))
 			}
 			return hdr.end, err
 		}
@@ -299,8 +429,8 @@ func parseGitHeaderData(f *File, line, defaultName string) (end bool, err error)
 	return true, nil
 }
 
-func parseGitHeaderOldName(f *File, line, defaultName string) error {
-	name, _, err := parseName(line, '\t', 1)
+func parseGitHeaderOldName(f *File, line, defaultName string, drop int) error {
+	name, _, err := parseName(line, '\t', drop)
 	if err != nil {
 		return err
 	}
@@ -311,8 +441,8 @@ func parseGitHeaderOldName(f *File, line, defaultName string) error {
 	return verifyGitHeaderName(name, f.OldName, f.IsNew, "old")
 }
 
-func parseGitHeaderNewName(f *File, line, defaultName string) error {
-	name, _, err := parseName(line, '\t', 1)
+func parseGitHeaderNewName(f *File, line, defaultName string, drop int) error {
+	name, _, err := parseName(line, '\t', drop)
 	if err != nil {
 		return err
 	}
@@ -323,53 +453,53 @@ func parseGitHeaderNewName(f *File, line, defaultName string) error {
 	return verifyGitHeaderName(name, f.NewName, f.IsDelete, "new")
 }
 
-func parseGitHeaderOldMode(f *File, line, defaultName string) (err error) {
+func parseGitHeaderOldMode(f *File, line, defaultName string, drop int) (err error) {
 	f.OldMode, err = parseMode(line)
 	return
 }
 
-func parseGitHeaderNewMode(f *File, line, defaultName string) (err error) {
+func parseGitHeaderNewMode(f *File, line, defaultName string, drop int) (err error) {
 	f.NewMode, err = parseMode(line)
 	return
 }
 
-func parseGitHeaderDeletedMode(f *File, line, defaultName string) error {
+func parseGitHeaderDeletedMode(f *File, line, defaultName string, drop int) error {
 	f.IsDelete = true
 	f.OldName = defaultName
-	return parseGitHeaderOldMode(f, line, defaultName)
+	return parseGitHeaderOldMode(f, line, defaultName, drop)
 }
 
-func parseGitHeaderCreatedMode(f *File, line, defaultName string) error {
+func parseGitHeaderCreatedMode(f *File, line, defaultName string, drop int) error {
 	f.IsNew = true
 	f.NewName = defaultName
-	return parseGitHeaderNewMode(f, line, defaultName)
+	return parseGitHeaderNewMode(f, line, defaultName, drop)
 }
 
-func parseGitHeaderCopyFrom(f *File, line, defaultName string) (err error) {
+func parseGitHeaderCopyFrom(f *File, line, defaultName string, drop int) (err error) {
 	f.IsCopy = true
-	f.OldName, _, err = parseName(line, 0, 0)
+	f.OldName, _, err = parseName(line, 0, int(max(int64(drop-1), 0)))
 	return
 }
 
-func parseGitHeaderCopyTo(f *File, line, defaultName string) (err error) {
+func parseGitHeaderCopyTo(f *File, line, defaultName string, drop int) (err error) {
 	f.IsCopy = true
-	f.NewName, _, err = parseName(line, 0, 0)
+	f.NewName, _, err = parseName(line, 0, int(max(int64(drop-1), 0)))
 	return
 }
 
-func parseGitHeaderRenameFrom(f *File, line, defaultName string) (err error) {
+func parseGitHeaderRenameFrom(f *File, line, defaultName string, drop int) (err error) {
 	f.IsRename = true
-	f.OldName, _, err = parseName(line, 0, 0)
+	f.OldName, _, err = parseName(line, 0, int(max(int64(drop-1), 0)))
 	return
 }
 
-func parseGitHeaderRenameTo(f *File, line, defaultName string) (err error) {
+func parseGitHeaderRenameTo(f *File, line, defaultName string, drop int) (err error) {
 	f.IsRename = true
-	f.NewName, _, err = parseName(line, 0, 0)
+	f.NewName, _, err = parseName(line, 0, int(max(int64(drop-1), 0)))
 	return
 }
 
-func parseGitHeaderScore(f *File, line, defaultName string) error {
+func parseGitHeaderScore(f *File, line, defaultName string, drop int) error {
 	score, err := strconv.ParseInt(strings.TrimSuffix(line, "%"), 10, 32)
 	if err != nil {
 		nerr := err.(*strconv.NumError)
@@ -381,12 +511,11 @@ func parseGitHeaderScore(f *File, line, defaultName string) error {
 	return nil
 }
 
-func parseGitHeaderIndex(f *File, line, defaultName string) error {
+func parseGitHeaderIndex(f *File, line, defaultName string, drop int) error {
 	const sep = ".."
 
-	// note that git stops parsing if the OIDs are too long to be valid
-	// checking this requires knowing if the repository uses SHA1 or SHA256
-	// hashes, which we don't know, so we just skip that check
+	// git stops parsing if the OIDs are too long for the hash algorithm of
+	// the repository, which is the algorithm of f if it is known
 
 	parts := strings.SplitN(line, " ", 2)
 	oids := strings.SplitN(parts[0], sep, 2)
@@ -394,19 +523,34 @@ func parseGitHeaderIndex(f *File, line, defaultName string) error {
 	if len(oids) < 2 {
 		return fmt.Errorf("invalid index line: missing %q", sep)
 	}
+	if err := validateOID(oids[0], f.HashAlgorithm); err != nil {
+		return err
+	}
+	if err := validateOID(oids[1], f.HashAlgorithm); err != nil {
+		return atColumn(err, len(oids[0])+len(sep))
+	}
 	f.OldOIDPrefix, f.NewOIDPrefix = oids[0], oids[1]
 
 	if len(parts) > 1 {
-		return parseGitHeaderOldMode(f, parts[1], defaultName)
+		return atColumn(parseGitHeaderOldMode(f, parts[1], defaultName, drop), len(parts[0])+1)
 	}
 	return nil
 }
 
+// headerErrorKind returns the kind of parse error for an error from parsing a
+// line of a file header.
+func headerErrorKind(err error) ParseErrorKind {
+	if errors.Is(err, errInvalidMode) {
+		return KindInvalidMode
+	}
+	return KindFileHeader
+}
+
 func parseMode(s string) (os.FileMode, error) {
 	mode, err := strconv.ParseInt(s, 8, 32)
 	if err != nil {
 		nerr := err.(*strconv.NumError)
-		return os.FileMode(0), fmt.Errorf("invalid mode line: %v", nerr.Err)
+		return os.FileMode(0), fmt.Errorf("%w: %v", errInvalidMode, nerr.Err)
 	}
 	return os.FileMode(mode), nil
 }
@@ -435,20 +579,59 @@ func parseName(s string, term byte, dropPrefix int) (name string, n int, err err
 	return cleanName(name, dropPrefix), n, nil
 }
 
+// parseQuotedName parses a name in double quotes that uses C-style escapes
+// for special characters and octal escapes for other bytes, like the names
+// quoted by Git. It returns the name and the number of bytes consumed.
 func parseQuotedName(s string) (name string, n int, err error) {
+	var b strings.Builder
 	for n = 1; n < len(s); n++ {
-		if s[n] == '"' && s[n-1] != '\\' {
-			n++
-			break
+		switch c := s[n]; c {
+		case '"':
+			if n == 1 {
+				return "", 0, fmt.Errorf("missing name")
+			}
+			return b.String(), n + 1, nil
+
+		case '\\':
+			if n++; n == len(s) {
+				break
+			}
+			switch c = s[n]; c {
+			case 'a':
+				b.WriteByte('\a')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'v':
+				b.WriteByte('\v')
+			case '"', '\\':
+				b.WriteByte(c)
+			case '0', '1', '2', '3':
+				if n+2 >= len(s) || !isOctal(s[n+1]) || !isOctal(s[n+2]) {
+					return "", 0, fmt.Errorf("invalid octal escape in name")
+				}
+				b.WriteByte((c-'0')<<6 | (s[n+1]-'0')<<3 | (s[n+2] - '0'))
+				n += 2
+			default:
+				return "", 0, fmt.Errorf("invalid escape sequence in name: \\%c", c)
+			}
+
+		default:
+			b.WriteByte(c)
 		}
 	}
-	if n == 2 {
-		return "", 0, fmt.Errorf("missing name")
-	}
-	if name, err = strconv.Unquote(s[:n]); err != nil {
-		return "", 0, err
-	}
-	return name, n, err
+	return "", 0, fmt.Errorf("unterminated quoted name")
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
 }
 
 func parseUnquotedName(s string, term byte) (name string, n int, err error) {
@@ -514,13 +697,33 @@ func trimTreePrefix(name string, n int) string {
 
 // hasEpochTimestamp returns true if the string ends with a POSIX-formatted
 // timestamp for the UNIX epoch after a tab character. According to git, this
-// is used by GNU diff to mark creations and deletions.
+// is used by GNU diff to mark creations and deletions. Context diffs use a
+// timestamp without a time zone, which matches if it is within a day of the
+// epoch.
 func hasEpochTimestamp(s string) bool {
+	t, zone := parseTimestamp(s)
+	if t.IsZero() {
+		return false
+	}
+	if !zone {
+		d := t.Sub(time.Unix(0, 0))
+		return d > -24*time.Hour && d < 24*time.Hour
+	}
+	return t.Equal(time.Unix(0, 0))
+}
+
+// parseTimestamp parses the timestamp after a tab character at the end of a
+// "---" or "+++" line, in the POSIX format used by unified diffs or the format
+// without a time zone used by context diffs, which is in the local time zone.
+// It returns the zero time if there is no timestamp in a known format and
+// returns true if the timestamp has a time zone.
+func parseTimestamp(s string) (t time.Time, zone bool) {
 	const posixTimeLayout = "2006-01-02 15:04:05.9 -0700"
+	const contextTimeLayout = "Mon Jan _2 15:04:05 2006"
 
 	start := strings.IndexRune(s, '\t')
 	if start < 0 {
-		return false
+		return time.Time{}, false
 	}
 
 	ts := strings.TrimSuffix(s[start+1:], "\n")
@@ -531,14 +734,13 @@ func hasEpochTimestamp(s string) bool {
 		ts = ts[:len(ts)-3] + ts[len(ts)-2:]
 	}
 
-	t, err := time.Parse(posixTimeLayout, ts)
-	if err != nil {
-		return false
+	if t, err := time.ParseInLocation(contextTimeLayout, ts, time.Local); err == nil {
+		return t, false
 	}
-	if !t.Equal(time.Unix(0, 0)) {
-		return false
+	if t, err := time.Parse(posixTimeLayout, ts); err == nil {
+		return t, true
 	}
-	return true
+	return time.Time{}, false
 }
 
 func isSpace(c byte) bool {
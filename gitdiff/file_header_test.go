@@ -4,7 +4,9 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseGitFileHeader(t *testing.T) {
@@ -109,6 +111,69 @@ copy to copy.txt
 				IsCopy:  true,
 			},
 		},
+		"renameNamesWithSpaces": {
+			Input: `diff --git a/dir/old file.txt b/dir/new file.txt
+similarity index 90%
+rename from dir/old file.txt
+rename to dir/new file.txt
+`,
+			Output: &File{
+				OldName:  "dir/old file.txt",
+				NewName:  "dir/new file.txt",
+				Score:    90,
+				IsRename: true,
+			},
+		},
+		"renameQuotedNames": {
+			Input: `diff --git "a/foo\tbar.txt" b/bar.txt
+similarity index 100%
+rename from "foo\tbar.txt"
+rename to bar.txt
+`,
+			Output: &File{
+				OldName:  "foo\tbar.txt",
+				NewName:  "bar.txt",
+				Score:    100,
+				IsRename: true,
+			},
+		},
+		"renameNoPrefix": {
+			Input: `diff --git dir/foo.txt dir/bar.txt
+similarity index 100%
+rename from dir/foo.txt
+rename to dir/bar.txt
+`,
+			Output: &File{
+				OldName:  "dir/foo.txt",
+				NewName:  "dir/bar.txt",
+				Score:    100,
+				IsRename: true,
+			},
+		},
+		"renameNamesMismatch": {
+			Input: `diff --git a/foo.txt b/bar.txt
+similarity index 100%
+rename from foo.txt
+rename to baz.txt
+`,
+			Err: true,
+		},
+		"copyAndRename": {
+			Input: `diff --git a/foo.txt b/bar.txt
+similarity index 100%
+copy from foo.txt
+rename to bar.txt
+`,
+			Err: true,
+		},
+		"renameNewFile": {
+			Input: `diff --git a/foo.txt b/bar.txt
+new file mode 100644
+rename from foo.txt
+rename to bar.txt
+`,
+			Err: true,
+		},
 		"missingDefaultFilename": {
 			Input: `diff --git a/foo.sh b/bar.sh
 old mode 100644
@@ -183,6 +248,8 @@ func TestParseTraditionalFileHeader(t *testing.T) {
 			Output: &File{
 				OldName: "dir/file_new.txt",
 				NewName: "dir/file_new.txt",
+				OldTime: time.Date(2019, 3, 22, 6, 0, 0, 0, time.UTC),
+				NewTime: time.Date(2019, 3, 22, 6, 30, 0, 0, time.UTC),
 			},
 		},
 		"newFile": {
@@ -193,6 +260,8 @@ func TestParseTraditionalFileHeader(t *testing.T) {
 			Output: &File{
 				NewName: "dir/file.txt",
 				IsNew:   true,
+				OldTime: time.Unix(0, 0).UTC(),
+				NewTime: time.Date(2019, 3, 22, 6, 30, 0, 0, time.UTC),
 			},
 		},
 		"newFileTimestamp": {
@@ -203,6 +272,8 @@ func TestParseTraditionalFileHeader(t *testing.T) {
 			Output: &File{
 				NewName: "dir/file.txt",
 				IsNew:   true,
+				OldTime: time.Unix(0, 0).UTC(),
+				NewTime: time.Date(2019, 3, 22, 6, 30, 0, 0, time.UTC),
 			},
 		},
 		"deleteFile": {
@@ -213,6 +284,8 @@ func TestParseTraditionalFileHeader(t *testing.T) {
 			Output: &File{
 				OldName:  "dir/file.txt",
 				IsDelete: true,
+				OldTime:  time.Date(2019, 3, 22, 6, 30, 0, 0, time.UTC),
+				NewTime:  time.Unix(0, 0).UTC(),
 			},
 		},
 		"deleteFileTimestamp": {
@@ -223,6 +296,8 @@ func TestParseTraditionalFileHeader(t *testing.T) {
 			Output: &File{
 				OldName:  "dir/file.txt",
 				IsDelete: true,
+				OldTime:  time.Date(2019, 3, 22, 6, 30, 0, 0, time.UTC),
+				NewTime:  time.Unix(0, 0).UTC(),
 			},
 		},
 		"useShortestPrefixName": {
@@ -233,6 +308,8 @@ func TestParseTraditionalFileHeader(t *testing.T) {
 			Output: &File{
 				OldName: "dir/file.txt",
 				NewName: "dir/file.txt",
+				OldTime: time.Date(2019, 3, 22, 6, 0, 0, 0, time.UTC),
+				NewTime: time.Date(2019, 3, 22, 6, 30, 0, 0, time.UTC),
 			},
 		},
 		"notTraditionalHeader": {
@@ -266,6 +343,7 @@ context line
 			if err != nil {
 				t.Fatalf("unexpected error parsing traditional file header: %v", err)
 			}
+			utcTimes(f)
 
 			if !reflect.DeepEqual(test.Output, f) {
 				t.Errorf("incorrect file\nexpected: %+v\n  actual: %+v", test.Output, f)
@@ -274,6 +352,14 @@ context line
 	}
 }
 
+// utcTimes converts the times of f to UTC, so that reflect.DeepEqual
+// compares them to the expected times independently of the local time zone.
+func utcTimes(f *File) {
+	if f != nil {
+		f.OldTime, f.NewTime = f.OldTime.UTC(), f.NewTime.UTC()
+	}
+}
+
 func TestCleanName(t *testing.T) {
 	tests := map[string]struct {
 		Input  string
@@ -328,6 +414,18 @@ func TestParseName(t *testing.T) {
 		"quotedWithSpaces": {
 			Input: `"dir/space file.txt"`, Output: "dir/space file.txt", N: 20,
 		},
+		"quotedWithOctalEscapes": {
+			Input: `"dir/u\303\244.txt"`, Output: "dir/uä.txt", N: 19,
+		},
+		"quotedWithCharEscapes": {
+			Input: `"a\tb\nc\\d"`, Output: "a\tb\nc\\d", N: 12,
+		},
+		"quotedWithTrailingBackslash": {
+			Input: `"dir\\" b/dir`, Output: `dir\`, N: 7,
+		},
+		"unquotedNonASCII": {
+			Input: "dir/uä.txt", Output: "dir/uä.txt", N: 11,
+		},
 		"tabTerminator": {
 			Input: "dir/space file.txt\tfile2.txt", Term: '\t', Output: "dir/space file.txt", N: 18,
 		},
@@ -355,6 +453,12 @@ func TestParseName(t *testing.T) {
 		"unterminatedQuotes": {
 			Input: `"dir/file.txt`, Err: true,
 		},
+		"invalidEscape": {
+			Input: `"dir/\x41.txt"`, Err: true,
+		},
+		"invalidOctalEscape": {
+			Input: `"dir/\4a.txt"`, Err: true,
+		},
 	}
 
 	for name, test := range tests {
@@ -598,7 +702,7 @@ func TestParseGitHeaderData(t *testing.T) {
 				f = *test.InputFile
 			}
 
-			end, err := parseGitHeaderData(&f, test.Line, test.DefaultName)
+			end, err := parseGitHeaderData(&f, test.Line, test.DefaultName, 1)
 			if test.Err {
 				if err == nil || err == io.EOF {
 					t.Fatalf("expected error parsing header data, but got %v", err)
@@ -669,7 +773,7 @@ func TestParseGitHeaderName(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			output, err := parseGitHeaderName(test.Input)
+			output, err := parseGitHeaderName(test.Input, 1)
 			if test.Err {
 				if err == nil {
 					t.Fatalf("expected error parsing header name, but got nil")
@@ -712,6 +816,18 @@ func TestHasEpochTimestamp(t *testing.T) {
 			Input:  "+++ file.txt\t1970-01-01 04:00:00 +0400\n",
 			Output: true,
 		},
+		"contextTimestamp": {
+			Input:  "*** file.txt\tThu Jan  1 00:00:00 1970\n",
+			Output: true,
+		},
+		"contextWestTimestamp": {
+			Input:  "*** file.txt\tWed Dec 31 16:00:00 1969\n",
+			Output: true,
+		},
+		"contextNotEpoch": {
+			Input:  "*** file.txt\tWed Oct 14 15:06:47 2026\n",
+			Output: false,
+		},
 		"noTab": {
 			Input:  "+++ file.txt 1970-01-01 00:00:00 +0000\n",
 			Output: false,
@@ -743,3 +859,137 @@ func TestHasEpochTimestamp(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFileNameOptions(t *testing.T) {
+	tests := map[string]struct {
+		Input   string
+		Options []ParseOption
+		OldName string
+		NewName string
+	}{
+		"gitDefault": {
+			Input:   "diff --git a/dir/file.txt b/dir/file.txt\nindex 1c23fcc..40a1b33 100644\n--- a/dir/file.txt\n+++ b/dir/file.txt\n@@ -1 +1 @@\n-old\n+new\n",
+			OldName: "dir/file.txt",
+			NewName: "dir/file.txt",
+		},
+		"gitNoPrefix": {
+			Input:   "diff --git dir/file.txt dir/file.txt\nindex 1c23fcc..40a1b33 100644\n--- dir/file.txt\n+++ dir/file.txt\n@@ -1 +1 @@\n-old\n+new\n",
+			Options: []ParseOption{WithStripComponents(0)},
+			OldName: "dir/file.txt",
+			NewName: "dir/file.txt",
+		},
+		"gitStripTwo": {
+			Input:   "diff --git a/dir/file.txt b/dir/file.txt\nindex 1c23fcc..40a1b33 100644\n--- a/dir/file.txt\n+++ b/dir/file.txt\n@@ -1 +1 @@\n-old\n+new\n",
+			Options: []ParseOption{WithStripComponents(2)},
+			OldName: "file.txt",
+			NewName: "file.txt",
+		},
+		"gitModeOnlyStripTwo": {
+			Input:   "diff --git a/dir/file.sh b/dir/file.sh\nold mode 100644\nnew mode 100755\n",
+			Options: []ParseOption{WithStripComponents(2)},
+			OldName: "file.sh",
+			NewName: "file.sh",
+		},
+		"gitRenameStripTwo": {
+			Input:   "diff --git a/dir/old.txt b/dir/new.txt\nsimilarity index 100%\nrename from dir/old.txt\nrename to dir/new.txt\n",
+			Options: []ParseOption{WithStripComponents(2)},
+			OldName: "old.txt",
+			NewName: "new.txt",
+		},
+		"traditionalDefault": {
+			Input:   "--- orig/dir/file.txt\n+++ new/dir/file.txt\n@@ -1 +1 @@\n-old\n+new\n",
+			OldName: "new/dir/file.txt",
+			NewName: "new/dir/file.txt",
+		},
+		"traditionalStripOne": {
+			Input:   "--- orig/dir/file.txt\n+++ new/dir/file.txt\n@@ -1 +1 @@\n-old\n+new\n",
+			Options: []ParseOption{WithStripComponents(1)},
+			OldName: "dir/file.txt",
+			NewName: "dir/file.txt",
+		},
+		"directory": {
+			Input:   "diff --git a/dir/old.txt b/dir/new.txt\nsimilarity index 100%\nrename from dir/old.txt\nrename to dir/new.txt\n",
+			Options: []ParseOption{WithDirectory("vendor/lib/")},
+			OldName: "vendor/lib/dir/old.txt",
+			NewName: "vendor/lib/dir/new.txt",
+		},
+		"directoryNewFile": {
+			Input:   "diff --git a/file.txt b/file.txt\nnew file mode 100644\nindex 0000000..40a1b33\n--- /dev/null\n+++ b/file.txt\n@@ -0,0 +1 @@\n+new\n",
+			Options: []ParseOption{WithStripComponents(1), WithDirectory("sub"), WithIncludePaths("sub/*")},
+			NewName: "sub/file.txt",
+		},
+		"pathRewrite": {
+			Input:   "diff --git a/dir/old.txt b/dir/new.txt\nsimilarity index 100%\nrename from dir/old.txt\nrename to dir/new.txt\n",
+			Options: []ParseOption{WithPathRewrite(vendorPath)},
+			OldName: "third_party/foo/old.txt",
+			NewName: "third_party/foo/new.txt",
+		},
+		"pathRewriteDeletedFile": {
+			Input:   "diff --git a/dir/file.txt b/dir/file.txt\ndeleted file mode 100644\nindex 40a1b33..0000000\n--- a/dir/file.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-old\n",
+			Options: []ParseOption{WithPathRewrite(vendorPath)},
+			OldName: "third_party/foo/file.txt",
+		},
+		"pathRewriteDirectory": {
+			Input:   "diff --git a/file.txt b/file.txt\nindex 1c23fcc..40a1b33 100644\n--- a/file.txt\n+++ b/file.txt\n@@ -1 +1 @@\n-old\n+new\n",
+			Options: []ParseOption{WithDirectory("dir"), WithPathRewrite(vendorPath), WithIncludePaths("third_party/*")},
+			OldName: "third_party/foo/file.txt",
+			NewName: "third_party/foo/file.txt",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := ParseAll(strings.NewReader(test.Input), test.Options...)
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, but got %d", len(files))
+			}
+			if files[0].OldName != test.OldName || files[0].NewName != test.NewName {
+				t.Errorf("incorrect names\nexpected: %q, %q\n  actual: %q, %q", test.OldName, test.NewName, files[0].OldName, files[0].NewName)
+			}
+		})
+	}
+}
+
+func TestParsePathRewriteFormat(t *testing.T) {
+	input := `diff --git a/dir/old.txt b/dir/new.txt
+similarity index 90%
+rename from dir/old.txt
+rename to dir/new.txt
+index 1c23fcc..40a1b33 100644
+--- a/dir/old.txt
++++ b/dir/new.txt
+@@ -1 +1 @@
+-old
++new
+`
+	expected := `diff --git a/third_party/foo/old.txt b/third_party/foo/new.txt
+similarity index 90%
+rename from third_party/foo/old.txt
+rename to third_party/foo/new.txt
+index 1c23fcc..40a1b33 100644
+--- a/third_party/foo/old.txt
++++ b/third_party/foo/new.txt
+@@ -1 +1 @@
+-old
++new
+`
+
+	files, _, err := ParseAll(strings.NewReader(input), WithPathRewrite(vendorPath))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, but got %d", len(files))
+	}
+	if actual := files[0].String(); actual != expected {
+		t.Errorf("incorrect formatted file\nexpected:\n%s\nactual:\n%s", expected, actual)
+	}
+}
+
+// vendorPath moves files from "dir/" into "third_party/foo/".
+func vendorPath(name string) string {
+	return "third_party/foo/" + strings.TrimPrefix(name, "dir/")
+}
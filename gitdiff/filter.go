@@ -0,0 +1,324 @@
+package gitdiff
+
+import (
+	"io"
+	"path"
+	"strings"
+)
+
+// pathRule is a pattern that includes or excludes matching files.
+type pathRule struct {
+	pattern string
+	include bool
+}
+
+// WithIncludePaths configures Parse to only parse files with names that match
+// one of the patterns, like "git apply --include". Parse skips the fragments
+// of other files without parsing them and does not return those files.
+//
+// Patterns use the same syntax as path.Match, except that '*' also matches
+// '/', like the patterns used by Git for this option. Parse compares patterns
+// to the new name of each file or to the old name for deleted files.
+//
+// WithIncludePaths and WithExcludePaths may be used together. Like Git, Parse
+// checks patterns in the order of the options and uses the first pattern that
+// matches a file. If no pattern matches, Parse skips the file if there are any
+// include patterns and parses it otherwise.
+func WithIncludePaths(patterns ...string) ParseOption {
+	return func(p *parser) {
+		p.addPathRules(patterns, true)
+	}
+}
+
+// WithExcludePaths configures Parse to skip files with names that match any
+// of the patterns, like "git apply --exclude". Parse skips the fragments of
+// these files without parsing them and does not return the files. See
+// WithIncludePaths for details about patterns.
+func WithExcludePaths(patterns ...string) ParseOption {
+	return func(p *parser) {
+		p.addPathRules(patterns, false)
+	}
+}
+
+func (p *parser) addPathRules(patterns []string, include bool) {
+	for _, pattern := range patterns {
+		p.pathRules = append(p.pathRules, pathRule{pattern: pattern, include: include})
+		p.hasInclude = p.hasInclude || include
+	}
+}
+
+// usePath returns true if the parser should parse the fragments of f.
+func (p *parser) usePath(f *File) bool {
+	name := f.NewName
+	if name == "" {
+		name = f.OldName
+	}
+	for _, rule := range p.pathRules {
+		if matchPath(rule.pattern, name) {
+			return rule.include
+		}
+	}
+	return !p.hasInclude
+}
+
+// SkipFragments advances the parser past the fragments of a file without
+// parsing their content. It uses the line counts in fragment headers to skip
+// the lines of text and combined fragments, so that the skipped content is
+// never mistaken for the header of the next file.
+func (p *parser) SkipFragments() error {
+	for {
+		line := p.Line(0)
+		switch {
+		case strings.HasPrefix(line, "@@@"):
+			frag, err := p.ParseCombinedFragmentHeader()
+			if err != nil {
+				return err
+			}
+			if err := p.skipChunk(append([]int64(nil), frag.OldLines...), frag.NewLines); err != nil {
+				return err
+			}
+
+		case p.isTextFragmentHeader(line):
+			frag, err := p.ParseTextFragmentHeader()
+			if err != nil {
+				return err
+			}
+			if p.recount {
+				// the counts may be wrong, so parse the lines to find the end
+				err = p.ParseTextChunk(frag)
+			} else {
+				err = p.skipChunk([]int64{frag.OldLines}, frag.NewLines)
+			}
+			if err != nil {
+				return err
+			}
+
+		case strings.HasPrefix(line, contextFragmentStart):
+			if _, err := p.ParseContextFragment(); err != nil {
+				return err
+			}
+
+		case line == "GIT binary patch\n":
+			return p.skipBinaryFragments()
+
+		case isBinaryNoDataMarker(line):
+			return ignoreEOF(p.Next())
+
+		default:
+			return nil
+		}
+	}
+}
+
+// skipChunk skips the lines of a fragment with the given line counts. It stops
+// at the first line that is not part of a fragment.
+func (p *parser) skipChunk(oldLines []int64, newLines int64) error {
+	remaining := func() bool {
+		for _, n := range oldLines {
+			if n > 0 {
+				return true
+			}
+		}
+		return newLines > 0
+	}
+
+	for remaining() {
+		line := p.Line(0)
+		if len(line) <= len(oldLines) && line != "\n" {
+			return nil
+		}
+
+		switch {
+		case line == "\n":
+			for i := range oldLines {
+				oldLines[i]--
+			}
+			newLines--
+		case line[0] == '\\':
+		default:
+			deleted := false
+			for i, op := range []byte(line[:len(oldLines)]) {
+				switch op {
+				case ' ':
+					oldLines[i]--
+				case '-':
+					oldLines[i]--
+					deleted = true
+				case '+':
+				default:
+					return nil
+				}
+			}
+			if !deleted {
+				newLines--
+			}
+		}
+
+		if err := p.Next(); err != nil {
+			return ignoreEOF(err)
+		}
+	}
+
+	if isNoNewlineMarker(p.Line(0)) {
+		return ignoreEOF(p.Next())
+	}
+	return nil
+}
+
+// skipBinaryFragments skips the marker of a binary patch and the forward and
+// reverse fragments that follow it, each of which ends at an empty line.
+func (p *parser) skipBinaryFragments() error {
+	if err := p.Next(); err != nil {
+		return ignoreEOF(err)
+	}
+	for i := 0; i < 2; i++ {
+		if line := p.Line(0); !strings.HasPrefix(line, "literal ") && !strings.HasPrefix(line, "delta ") {
+			return nil
+		}
+		for {
+			line := p.Line(0)
+			if err := p.Next(); err != nil {
+				return ignoreEOF(err)
+			}
+			if line == "\n" {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func ignoreEOF(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// matchPath reports whether name matches the shell pattern. It supports the
+// same syntax as path.Match, except that '*' also matches '/'. Invalid
+// patterns never match.
+func matchPath(pattern, name string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchPath(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if name == "" {
+				return false
+			}
+			pattern, name = pattern[1:], name[1:]
+
+		case '[':
+			if name == "" {
+				return false
+			}
+			matched, n, ok := matchClass(pattern, name[0])
+			if !ok || !matched {
+				return false
+			}
+			pattern, name = pattern[n:], name[1:]
+
+		case '\\':
+			if len(pattern) < 2 {
+				return false
+			}
+			pattern = pattern[1:]
+			fallthrough
+
+		default:
+			if name == "" || pattern[0] != name[0] {
+				return false
+			}
+			pattern, name = pattern[1:], name[1:]
+		}
+	}
+	return name == ""
+}
+
+// matchAttrPath reports whether name matches a pattern from a .gitattributes
+// file. Like Git, patterns without a slash match the base name of the file.
+// Other patterns match the full name, ignoring a leading slash, and '*' does
+// not match '/' in these patterns, but a "**" component matches any number of
+// directories. Patterns with a trailing slash only match directories, so they
+// never match files.
+func matchAttrPath(pattern, name string) bool {
+	switch {
+	case strings.HasSuffix(pattern, "/"):
+		return false
+	case !strings.Contains(pattern, "/"):
+		return matchPath(pattern, path.Base(name))
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	return matchComponents(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchComponents matches the components of a name to the components of a
+// pattern, where "**" matches zero or more components.
+func matchComponents(patterns, names []string) bool {
+	if len(patterns) == 0 {
+		return len(names) == 0
+	}
+	if patterns[0] == "**" {
+		for i := 0; i <= len(names); i++ {
+			if matchComponents(patterns[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return len(names) > 0 && matchPath(patterns[0], names[0]) && matchComponents(patterns[1:], names[1:])
+}
+
+// matchClass matches c against the character class at the start of pattern.
+// It returns the length of the class in the pattern and false if the class is
+// invalid.
+func matchClass(pattern string, c byte) (matched bool, n int, ok bool) {
+	i := 1
+	negate := i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^')
+	if negate {
+		i++
+	}
+
+	for first := true; i < len(pattern); first = false {
+		if pattern[i] == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+
+		lo := pattern[i]
+		if lo == '\\' {
+			if i++; i == len(pattern) {
+				return false, 0, false
+			}
+			lo = pattern[i]
+		}
+		i++
+
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			if hi = pattern[i+1]; hi == '\\' {
+				if i+2 == len(pattern) {
+					return false, 0, false
+				}
+				hi = pattern[i+2]
+				i++
+			}
+			i += 2
+		}
+
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+	return false, 0, false
+}
@@ -0,0 +1,149 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePathFilters(t *testing.T) {
+	// the fragment of tricky.txt contains lines that look like a file header
+	// if the parser does not skip the fragment correctly
+	const patch = `diff --git a/dir/a.go b/dir/a.go
+index 1c23fcc..40a1b33 100644
+--- a/dir/a.go
++++ b/dir/a.go
+@@ -1 +1 @@
+-package a
++package b
+diff --git a/tricky.txt b/tricky.txt
+index 1c23fcc..40a1b33 100644
+--- a/tricky.txt
++++ b/tricky.txt
+@@ -1,2 +1,2 @@
+ context
+--- a/fake.txt
++++ b/fake.txt
+@@ -5 +5 @@
+-five
++5
+diff --git a/image.png b/image.png
+index 0000000..aa8ffa5
+GIT binary patch
+literal 4
+LcmZQzWMT#Y01f~L
+
+literal 0
+HcmV?d00001
+
+diff --git a/dir/sub/b.go b/dir/sub/b.go
+deleted file mode 100644
+index 1c23fcc..0000000
+--- a/dir/sub/b.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package b
+\ No newline at end of file
+diff --cc merged.txt
+index 5f25ea0,2b51d8b..56fe8f8
+--- a/merged.txt
++++ b/merged.txt
+@@@ -1,2 -1,2 +1,2 @@@
+- main
+ -side
+++resolved
+  end
+diff --git a/README b/README
+index 1c23fcc..40a1b33 100644
+--- a/README
++++ b/README
+@@ -1 +1 @@
+-old
++new
+`
+
+	tests := map[string]struct {
+		Options []ParseOption
+		Files   []string
+	}{
+		"none": {
+			Files: []string{"dir/a.go", "tricky.txt", "image.png", "dir/sub/b.go", "merged.txt", "README"},
+		},
+		"include": {
+			Options: []ParseOption{WithIncludePaths("*.go")},
+			Files:   []string{"dir/a.go", "dir/sub/b.go"},
+		},
+		"includeMultiple": {
+			Options: []ParseOption{WithIncludePaths("README", "dir/*")},
+			Files:   []string{"dir/a.go", "dir/sub/b.go", "README"},
+		},
+		"exclude": {
+			Options: []ParseOption{WithExcludePaths("*.txt", "*.png")},
+			Files:   []string{"dir/a.go", "dir/sub/b.go", "README"},
+		},
+		"excludeThenInclude": {
+			Options: []ParseOption{WithExcludePaths("dir/sub/*"), WithIncludePaths("dir/*")},
+			Files:   []string{"dir/a.go"},
+		},
+		"includeThenExclude": {
+			Options: []ParseOption{WithIncludePaths("dir/*"), WithExcludePaths("dir/sub/*")},
+			Files:   []string{"dir/a.go", "dir/sub/b.go"},
+		},
+		"noMatch": {
+			Options: []ParseOption{WithIncludePaths("*.c")},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := ParseAll(strings.NewReader(patch), test.Options...)
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			var names []string
+			for _, f := range files {
+				name := f.NewName
+				if name == "" {
+					name = f.OldName
+				}
+				names = append(names, name)
+			}
+			if strings.Join(names, ",") != strings.Join(test.Files, ",") {
+				t.Errorf("incorrect files\nexpected: %v\n  actual: %v", test.Files, names)
+			}
+		})
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Name    string
+		Match   bool
+	}{
+		{"file.txt", "file.txt", true},
+		{"file.txt", "dir/file.txt", false},
+		{"*.txt", "file.txt", true},
+		{"*.txt", "dir/file.txt", true},
+		{"dir/*", "dir/sub/file.txt", true},
+		{"dir/*.go", "dir/file.txt", false},
+		{"*/file.txt", "file.txt", false},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file.txt", false},
+		{"file[0-9].txt", "file5.txt", true},
+		{"file[0-9].txt", "filex.txt", false},
+		{"file[!0-9].txt", "filex.txt", true},
+		{"file[^0-9].txt", "file5.txt", false},
+		{"file[]a].txt", "file].txt", true},
+		{`file\*.txt`, "file*.txt", true},
+		{`file\*.txt`, "file1.txt", false},
+		{"file[a-", "filea", false},
+		{"**", "any/path", true},
+	}
+
+	for _, test := range tests {
+		if match := matchPath(test.Pattern, test.Name); match != test.Match {
+			t.Errorf("incorrect match for %q against %q: expected %t, actual %t", test.Pattern, test.Name, test.Match, match)
+		}
+	}
+}
@@ -0,0 +1,1038 @@
+package gitdiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// String returns a Git patch representation of the file, as it would be
+// generated by "git diff --binary". Parse and Apply accept the output.
+func (f *File) String() string {
+	var b strings.Builder
+	_, _ = f.WriteTo(&b)
+	return b.String()
+}
+
+// WriteTo writes a Git patch representation of the file to w. It returns the
+// number of bytes written and the first error encountered.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	return Format(w, f)
+}
+
+// Format writes a Git patch representation of f to w, like WriteTo, with
+// options that configure the output. It returns the number of bytes written
+// and the first error encountered.
+func Format(w io.Writer, f *File, opts ...FormatOption) (int64, error) {
+	fm := newFormatter(w)
+	for _, opt := range opts {
+		opt(fm)
+	}
+	fm.FormatFile(f)
+	return fm.n, fm.err
+}
+
+// FormatRejects writes the text fragments of f that did not apply to w, in the
+// format of the ".rej" files written by "git apply --reject". Like Git, the
+// header uses the new name of the file, or the old name for deleted files,
+// and does not quote the name. Use Applier.Rejects to get the fragments.
+func FormatRejects(w io.Writer, f *File, frags []*TextFragment) (int64, error) {
+	fm := newFormatter(w)
+	fm.FormatRejects(f, frags)
+	return fm.n, fm.err
+}
+
+// FormatPatch writes h and files to w as a complete message in the mailbox
+// format of "git format-patch", which "git am" and ParseMailbox accept. The
+// message has the mbox "From" line, the mail headers and the body from
+// MailString, a "---" line followed by the notes of the header and the
+// diffstat and summary of the files, and the files as Git patches. If the
+// header has a base, the "base-commit:" and "prerequisite-patch-id:" lines
+// follow the files, and if it has a signature, the message ends with the
+// signature after a "-- " line. Like Git, FormatPatch adds MIME headers that
+// declare an 8-bit body if the message contains non-ASCII characters, using
+// the Charset of the header or UTF-8. The BodyAppendix of the header is
+// replaced by the diffstat. Options configure the output of the files in the
+// same way as for Format.
+func FormatPatch(w io.Writer, h *PatchHeader, files []*File, opts ...FormatOption) (int64, error) {
+	fm := newFormatter(w)
+	for _, opt := range opts {
+		opt(fm)
+	}
+	fm.FormatPatch(h, files)
+	return fm.n, fm.err
+}
+
+// FormatOption configures the output of Format.
+type FormatOption func(*formatter)
+
+// WithQuotePath configures Format to quote file names that contain bytes
+// outside of the ASCII range, like the "core.quotePath" setting in Git. By
+// default, Format quotes these names and uses octal escapes for the bytes. If
+// quote is false, Format writes these bytes unchanged, but still quotes names
+// that contain control characters, double quotes, or backslashes. Parse
+// accepts file names in either form.
+func WithQuotePath(quote bool) FormatOption {
+	return func(fm *formatter) {
+		fm.quotePath = quote
+	}
+}
+
+// WithTraditional configures Format to write a traditional unified diff, like
+// "diff -Nu" from GNU diff, instead of a Git patch. The output has no "diff
+// --git" line or extended header lines, so it does not include modes, object
+// IDs, copies, or renames, and files without text fragments only have a line
+// for binary files. The "---" and "+++" lines include the OldTime and NewTime
+// of the file, or the time set by WithDefaultTime. Like GNU diff, created and
+// deleted files use the name of the file with a timestamp for the Unix epoch
+// instead of "/dev/null". Combined diffs are always written as Git patches.
+func WithTraditional() FormatOption {
+	return func(fm *formatter) {
+		fm.traditional = true
+	}
+}
+
+// WithDefaultTime sets the time that Format writes in traditional diffs for
+// files without an OldTime or NewTime, like files from Git patches. POSIX
+// requires a timestamp on the "---" and "+++" lines, but by default, Format
+// omits the timestamps of these files. Format ignores this option unless
+// WithTraditional is also set.
+func WithDefaultTime(t time.Time) FormatOption {
+	return func(fm *formatter) {
+		fm.defaultTime = t
+	}
+}
+
+// FormatTraditional writes files to w as a traditional unified diff, like
+// calling Format with WithTraditional for each file, for tools that do not
+// understand Git patches. It returns the number of bytes written and the
+// first error encountered.
+func FormatTraditional(w io.Writer, files []*File, opts ...FormatOption) (int64, error) {
+	fm := newFormatter(w)
+	for _, opt := range opts {
+		opt(fm)
+	}
+	fm.traditional = true
+	for _, f := range files {
+		fm.FormatFile(f)
+	}
+	return fm.n, fm.err
+}
+
+// String returns a Git patch representation of the fragment, including the
+// fragment header.
+func (f *TextFragment) String() string {
+	var b strings.Builder
+	fm := newFormatter(&b)
+	fm.FormatTextFragment(f)
+	return b.String()
+}
+
+// String returns a Git patch representation of the fragment, including the
+// fragment header and the trailing empty line.
+func (f *BinaryFragment) String() string {
+	var b strings.Builder
+	fm := newFormatter(&b)
+	fm.FormatBinaryFragment(f)
+	return b.String()
+}
+
+// String returns a Git patch representation of the fragment, including the
+// fragment header.
+func (f *CombinedFragment) String() string {
+	var b strings.Builder
+	fm := newFormatter(&b)
+	fm.FormatCombinedFragment(f)
+	return b.String()
+}
+
+// String returns the fragment in the same format as "git diff
+// --word-diff=porcelain", including the fragment header.
+func (f *WordFragment) String() string {
+	var b strings.Builder
+	fm := newFormatter(&b)
+	fm.FormatWordFragment(f)
+	return b.String()
+}
+
+// String returns the header in the format of "git show", as parsed by
+// ParsePatchHeader. It uses the "fuller" format if the header has a committer
+// date and the "medium" format otherwise, adding a "Commit:" line if the
+// header has a committer. The mailbox appendix is not included. If the header
+// has no SHA, String uses a SHA of all zeros.
+func (h *PatchHeader) String() string {
+	var b strings.Builder
+	_, _ = h.WriteTo(&b)
+	return b.String()
+}
+
+// WriteTo writes the header to w in the format used by String. It returns the
+// number of bytes written and the first error encountered.
+func (h *PatchHeader) WriteTo(w io.Writer) (int64, error) {
+	fm := newFormatter(w)
+	fm.FormatPatchHeader(h)
+	return fm.n, fm.err
+}
+
+// MailString returns the header in the mailbox format of "git format-patch",
+// as parsed by ParsePatchHeader. Like Git, it encodes names and subjects with
+// non-ASCII characters and folds long subjects on to multiple lines. The
+// subject is the title with SubjectPrefix, which is not added if it is empty.
+// If the header has an appendix, it follows the body after a "---" line. If
+// the header has no SHA, MailString uses a SHA of all zeros. Notes are part
+// of the appendix, but the base and the signature are not included, because
+// they follow the files of a patch.
+func (h *PatchHeader) MailString() string {
+	var b strings.Builder
+	_, _ = h.WriteMailTo(&b)
+	return b.String()
+}
+
+// WriteMailTo writes the header to w in the format used by MailString. It
+// returns the number of bytes written and the first error encountered.
+func (h *PatchHeader) WriteMailTo(w io.Writer) (int64, error) {
+	fm := newFormatter(w)
+	fm.FormatPatchHeaderMail(h)
+	return fm.n, fm.err
+}
+
+// formatter writes patch content to an underlying writer. It records the
+// first error returned by the writer and stops writing after an error.
+type formatter struct {
+	w   io.Writer
+	n   int64
+	err error
+
+	quotePath   bool
+	traditional bool
+	defaultTime time.Time
+}
+
+func newFormatter(w io.Writer) *formatter {
+	return &formatter{w: w, quotePath: true}
+}
+
+func (fm *formatter) Write(p []byte) (int, error) {
+	if fm.err != nil {
+		return len(p), nil
+	}
+	n, err := fm.w.Write(p)
+	fm.n += int64(n)
+	fm.err = err
+	return len(p), nil
+}
+
+func (fm *formatter) WriteString(s string) (int, error) {
+	if fm.err != nil {
+		return len(s), nil
+	}
+	n, err := io.WriteString(fm.w, s)
+	fm.n += int64(n)
+	fm.err = err
+	return len(s), nil
+}
+
+func (fm *formatter) WriteByte(c byte) error {
+	_, _ = fm.Write([]byte{c})
+	return nil
+}
+
+func (fm *formatter) Format(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(fm, format, args...)
+}
+
+// WriteQuotedName writes a file name, quoting it in the same way as Git if it
+// contains special characters.
+func (fm *formatter) WriteQuotedName(s string) {
+	_, _ = fm.WriteString(quotePath(s, fm.quotePath))
+}
+
+func (fm *formatter) FormatPatchHeader(h *PatchHeader) {
+	const dateFormat = "Mon Jan 2 15:04:05 2006 -0700"
+
+	fm.Format("commit %s\n", shaOrDefault(h.SHA))
+	if h.SignatureStatus != "" {
+		fm.WriteString(h.SignatureStatus)
+		fm.WriteByte('\n')
+	}
+
+	if h.CommitterDate.IsZero() {
+		if h.Author != nil {
+			fm.Format("Author: %s\n", h.Author)
+		}
+		if h.Committer != nil {
+			fm.Format("Commit: %s\n", h.Committer)
+		}
+		if !h.AuthorDate.IsZero() {
+			fm.Format("Date:   %s\n", h.AuthorDate.Format(dateFormat))
+		}
+	} else {
+		if h.Author != nil {
+			fm.Format("Author:     %s\n", h.Author)
+		}
+		if !h.AuthorDate.IsZero() {
+			fm.Format("AuthorDate: %s\n", h.AuthorDate.Format(dateFormat))
+		}
+		if h.Committer != nil {
+			fm.Format("Commit:     %s\n", h.Committer)
+		}
+		fm.Format("CommitDate: %s\n", h.CommitterDate.Format(dateFormat))
+	}
+
+	if msg := h.Message(); msg != "" {
+		fm.WriteByte('\n')
+		for _, line := range strings.Split(msg, "\n") {
+			if line != "" {
+				fm.WriteString("    ")
+				fm.WriteString(line)
+			}
+			fm.WriteByte('\n')
+		}
+	}
+	if len(h.Notes) > 0 {
+		fm.WriteByte('\n')
+		fm.FormatNotes(h.Notes)
+	}
+}
+
+// FormatNotes writes notes in the format of "git log", with each note
+// indented by four spaces after a header with its ref and an empty line
+// between notes.
+func (fm *formatter) FormatNotes(notes []Note) {
+	for i, n := range notes {
+		if i > 0 {
+			fm.WriteByte('\n')
+		}
+		if n.Ref == "" {
+			fm.WriteString("Notes:\n")
+		} else {
+			fm.Format("Notes (%s):\n", n.Ref)
+		}
+		for _, line := range strings.Split(n.Text, "\n") {
+			if line != "" {
+				fm.WriteString("    ")
+				fm.WriteString(line)
+			}
+			fm.WriteByte('\n')
+		}
+	}
+}
+
+func (fm *formatter) FormatPatch(h *PatchHeader, files []*File) {
+	mail := *h
+	mail.BodyAppendix = ""
+	if len(files) > 0 {
+		mail.BodyAppendix = strings.TrimSuffix(NewStat(files).FormatWidth(mailStatWidth), "\n") + formatSummary(files)
+	}
+	fm.formatPatchHeaderMail(&mail, needs8BitMail(&mail))
+	if len(files) > 0 {
+		fm.WriteByte('\n')
+	}
+
+	for _, f := range files {
+		fm.FormatFile(f)
+	}
+	if h.Base != nil {
+		fm.Format("\nbase-commit: %s\n", h.Base.Commit)
+		for _, id := range h.Base.PrerequisitePatchIDs {
+			fm.Format("prerequisite-patch-id: %s\n", id)
+		}
+	}
+	if h.Signature != "" {
+		fm.Format("-- \n%s\n\n", h.Signature)
+	}
+}
+
+func (fm *formatter) FormatPatchHeaderMail(h *PatchHeader) {
+	fm.formatPatchHeaderMail(h, false)
+}
+
+func (fm *formatter) formatPatchHeaderMail(h *PatchHeader, mime bool) {
+	const dateFormat = "Mon, 2 Jan 2006 15:04:05 -0700"
+
+	fm.Format("From %s Mon Sep 17 00:00:00 2001\n", shaOrDefault(h.SHA))
+	if h.Author != nil {
+		fm.Format("From: %s <%s>\n", encodeMailName(h.Author.Name), h.Author.Email)
+	}
+	if !h.AuthorDate.IsZero() {
+		fm.Format("Date: %s\n", h.AuthorDate.Format(dateFormat))
+	}
+
+	subject := "Subject: " + h.SubjectPrefix
+	if needsMailEncoding(h.Title) {
+		subject = encodeMailWord(subject, h.Title, false)
+	} else {
+		subject = foldMailHeader(subject, h.Title)
+	}
+	fm.WriteString(subject)
+	fm.WriteByte('\n')
+	if mime {
+		charset := h.Charset
+		if charset == "" {
+			charset = "UTF-8"
+		}
+		fm.WriteString("MIME-Version: 1.0\n")
+		fm.Format("Content-Type: text/plain; charset=%s\n", charset)
+		fm.WriteString("Content-Transfer-Encoding: 8bit\n")
+	}
+	fm.WriteByte('\n')
+
+	if h.Body != "" {
+		fm.WriteString(h.Body)
+		fm.WriteByte('\n')
+	}
+	if h.BodyAppendix != "" || len(h.Notes) > 0 {
+		fm.WriteString("---\n")
+	}
+	if len(h.Notes) > 0 {
+		fm.WriteByte('\n')
+		fm.FormatNotes(h.Notes)
+		fm.WriteByte('\n')
+	}
+	if h.BodyAppendix != "" {
+		fm.WriteString(h.BodyAppendix)
+		fm.WriteByte('\n')
+	}
+}
+
+func (fm *formatter) FormatFile(f *File) {
+	f, err := f.loaded()
+	if err != nil {
+		if fm.err == nil {
+			fm.err = err
+		}
+		return
+	}
+	if f.IsCombined {
+		fm.FormatCombinedFile(f)
+		return
+	}
+	if f.Submodule != nil && f.Submodule.Log {
+		fm.FormatSubmoduleLog(f)
+		return
+	}
+	if fm.traditional {
+		fm.FormatTraditionalFile(f)
+		return
+	}
+
+	aName, bName := f.OldName, f.NewName
+	switch {
+	case aName == "":
+		aName = bName
+	case bName == "":
+		bName = aName
+	}
+
+	fm.WriteString("diff --git ")
+	fm.WriteQuotedName("a/" + aName)
+	fm.WriteByte(' ')
+	fm.WriteQuotedName("b/" + bName)
+	fm.WriteByte('\n')
+
+	switch {
+	case f.IsNew:
+		fm.Format("new file mode %o\n", fileModeOrDefault(f.NewMode))
+	case f.IsDelete:
+		fm.Format("deleted file mode %o\n", fileModeOrDefault(f.OldMode))
+	case f.OldMode != 0 && f.NewMode != 0 && f.OldMode != f.NewMode:
+		fm.Format("old mode %o\n", f.OldMode)
+		fm.Format("new mode %o\n", f.NewMode)
+	}
+
+	if f.IsCopy || f.IsRename {
+		if f.Score > 0 {
+			fm.Format("similarity index %d%%\n", f.Score)
+		}
+
+		op := "rename"
+		if f.IsCopy {
+			op = "copy"
+		}
+		fm.WriteString(op + " from ")
+		fm.WriteQuotedName(f.OldName)
+		fm.WriteByte('\n')
+		fm.WriteString(op + " to ")
+		fm.WriteQuotedName(f.NewName)
+		fm.WriteByte('\n')
+	} else if f.Score > 0 {
+		fm.Format("dissimilarity index %d%%\n", f.Score)
+	}
+
+	if f.OldOIDPrefix != "" && f.NewOIDPrefix != "" {
+		fm.Format("index %s..%s", f.OldOIDPrefix, f.NewOIDPrefix)
+		// the mode is only included when it does not change
+		if !f.IsNew && !f.IsDelete && f.OldMode != 0 && (f.NewMode == 0 || f.NewMode == f.OldMode) {
+			fm.Format(" %o", f.OldMode)
+		}
+		fm.WriteByte('\n')
+	}
+
+	if f.IsBinary {
+		if f.BinaryFragment == nil {
+			fm.WriteString("Binary files ")
+			fm.writeBinaryName("a/", aName, f.IsNew)
+			fm.WriteString(" and ")
+			fm.writeBinaryName("b/", bName, f.IsDelete)
+			fm.WriteString(" differ\n")
+			return
+		}
+
+		fm.WriteString("GIT binary patch\n")
+		fm.FormatBinaryFragment(f.BinaryFragment)
+		if f.ReverseBinaryFragment != nil {
+			fm.FormatBinaryFragment(f.ReverseBinaryFragment)
+		}
+		return
+	}
+
+	frags := f.TextFragments
+	if f.Submodule != nil {
+		frags = []*TextFragment{f.Submodule.TextFragment()}
+	}
+
+	// the file name lines only appear for text patches with fragments
+	if len(frags) > 0 || len(f.WordFragments) > 0 {
+		fm.WriteString("--- ")
+		fm.writeFragmentName("a/", f.OldName, f.IsNew, time.Time{})
+		fm.WriteString("+++ ")
+		fm.writeFragmentName("b/", f.NewName, f.IsDelete, time.Time{})
+		fm.formatFragments(frags, f.WordFragments)
+	}
+}
+
+// FormatTraditionalFile writes a file as a traditional unified diff, with
+// the times of the file or the default time on the "---" and "+++" lines.
+func (fm *formatter) FormatTraditionalFile(f *File) {
+	aName, bName := f.OldName, f.NewName
+	switch {
+	case aName == "":
+		aName = bName
+	case bName == "":
+		bName = aName
+	}
+
+	if f.IsBinary {
+		fm.WriteString("Binary files ")
+		fm.writeBinaryName("a/", aName, f.IsNew)
+		fm.WriteString(" and ")
+		fm.writeBinaryName("b/", bName, f.IsDelete)
+		fm.WriteString(" differ\n")
+		return
+	}
+
+	frags := f.TextFragments
+	if f.Submodule != nil {
+		frags = []*TextFragment{f.Submodule.TextFragment()}
+	}
+	if len(frags) == 0 && len(f.WordFragments) == 0 {
+		return
+	}
+
+	oldTime, newTime := f.OldTime, f.NewTime
+	if oldTime.IsZero() {
+		oldTime = fm.defaultTime
+	}
+	if newTime.IsZero() {
+		newTime = fm.defaultTime
+	}
+	switch {
+	case f.IsNew:
+		oldTime = time.Unix(0, 0).UTC()
+	case f.IsDelete:
+		newTime = time.Unix(0, 0).UTC()
+	}
+
+	fm.WriteString("--- ")
+	fm.writeFragmentName("a/", aName, false, oldTime)
+	fm.WriteString("+++ ")
+	fm.writeFragmentName("b/", bName, false, newTime)
+	fm.formatFragments(frags, f.WordFragments)
+}
+
+func (fm *formatter) formatFragments(frags []*TextFragment, wordFrags []*WordFragment) {
+	for _, frag := range frags {
+		fm.FormatTextFragment(frag)
+	}
+	for _, frag := range wordFrags {
+		fm.FormatWordFragment(frag)
+	}
+}
+
+// FormatSubmoduleLog writes a submodule change in the same format as "git
+// diff --submodule=log".
+func (fm *formatter) FormatSubmoduleLog(f *File) {
+	name, sm := f.NewName, f.Submodule
+	if f.IsDelete {
+		name = f.OldName
+	}
+
+	if sm.Untracked {
+		fm.Format("Submodule %s contains untracked content\n", name)
+	}
+	if sm.Dirty {
+		fm.Format("Submodule %s contains modified content\n", name)
+	}
+	if f.OldOIDPrefix == "" && f.NewOIDPrefix == "" {
+		return
+	}
+
+	sep := ".."
+	if sm.Diverged {
+		sep = "..."
+	}
+	fm.Format("Submodule %s %s%s%s", name, f.OldOIDPrefix, sep, f.NewOIDPrefix)
+	switch sm.Status {
+	case "":
+		fm.WriteString(":\n")
+	case SubmoduleStatusRewind:
+		fm.WriteString(" (rewind):\n")
+	default:
+		fm.Format(" (%s)\n", sm.Status)
+	}
+
+	for _, c := range sm.Commits {
+		if c.Op == OpDelete {
+			fm.WriteString("  < ")
+		} else {
+			fm.WriteString("  > ")
+		}
+		fm.WriteString(c.Title + "\n")
+	}
+}
+
+// FormatCombinedFile writes a file from a combined diff in the same format as
+// "git diff --cc".
+func (fm *formatter) FormatCombinedFile(f *File) {
+	name := f.NewName
+	if f.IsDelete {
+		name = f.OldName
+	}
+
+	fm.WriteString("diff --cc ")
+	fm.WriteQuotedName(name)
+	fm.WriteByte('\n')
+
+	if len(f.ParentOIDPrefixes) > 0 {
+		fm.Format("index %s..%s\n", strings.Join(f.ParentOIDPrefixes, ","), f.NewOIDPrefix)
+	}
+
+	modes := make([]string, len(f.ParentModes))
+	for i, mode := range f.ParentModes {
+		modes[i] = strconv.FormatUint(uint64(mode), 8)
+	}
+	switch {
+	case f.IsNew:
+		fm.Format("new file mode %o\n", fileModeOrDefault(f.NewMode))
+	case f.IsDelete:
+		fm.Format("deleted file mode %s\n", strings.Join(modes, ","))
+	case len(modes) > 0 && f.NewMode != 0:
+		for _, mode := range f.ParentModes {
+			if mode != f.NewMode {
+				fm.Format("mode %s..%o\n", strings.Join(modes, ","), f.NewMode)
+				break
+			}
+		}
+	}
+
+	fm.WriteString("--- ")
+	fm.writeFragmentName("a/", name, f.IsNew, time.Time{})
+	fm.WriteString("+++ ")
+	fm.writeFragmentName("b/", name, f.IsDelete, time.Time{})
+
+	for _, frag := range f.CombinedFragments {
+		fm.FormatCombinedFragment(frag)
+	}
+}
+
+// FormatCombinedFragment writes a combined fragment in the same format as Git.
+// Unlike text fragments, Git always includes line counts and does not mark
+// lines that are missing a trailing newline.
+func (fm *formatter) FormatCombinedFragment(f *CombinedFragment) {
+	marker := strings.Repeat("@", f.Parents()+1)
+
+	fm.WriteString(marker)
+	for i := range f.OldPositions {
+		fm.Format(" -%d,%d", f.OldPositions[i], f.OldLines[i])
+	}
+	fm.Format(" +%d,%d ", f.NewPosition, f.NewLines)
+	fm.WriteString(marker)
+	if f.Comment != "" {
+		fm.WriteByte(' ')
+		fm.WriteString(f.Comment)
+	}
+	fm.WriteByte('\n')
+
+	for _, line := range f.Lines {
+		fm.WriteString(line.String())
+		if line.NoEOL() {
+			fm.WriteByte('\n')
+		}
+	}
+}
+
+func (fm *formatter) writeBinaryName(prefix, name string, isNull bool) {
+	if isNull {
+		fm.WriteString(devNull)
+	} else {
+		fm.WriteQuotedName(prefix + name)
+	}
+}
+
+// writeFragmentName writes the name on a "---" or "+++" line, followed by a
+// tab and the timestamp t if it is set, in the format used by GNU diff.
+// Like Git, it adds a trailing tab if the name contains a space so that
+// parsers do not confuse the end of the name with a trailing timestamp.
+func (fm *formatter) writeFragmentName(prefix, name string, isNull bool, t time.Time) {
+	const timestampLayout = "2006-01-02 15:04:05.000000000 -0700"
+
+	if isNull {
+		fm.WriteString(devNull)
+	} else {
+		fm.WriteQuotedName(prefix + name)
+	}
+	switch {
+	case !t.IsZero():
+		fm.WriteByte('\t')
+		fm.WriteString(t.Format(timestampLayout))
+	case !isNull && strings.ContainsRune(name, ' '):
+		fm.WriteByte('\t')
+	}
+	fm.WriteByte('\n')
+}
+
+func (fm *formatter) FormatTextFragment(f *TextFragment) {
+	fm.FormatTextFragmentHeader(f)
+	fm.WriteByte('\n')
+
+	for _, line := range f.Lines {
+		fm.WriteString(line.Op.String())
+		fm.WriteString(line.Line)
+		if line.NoEOL() {
+			fm.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func (fm *formatter) FormatRejects(f *File, frags []*TextFragment) {
+	name := f.NewName
+	if name == "" {
+		name = f.OldName
+	}
+	fm.Format("diff a/%s b/%s\t(rejected hunks)\n", name, name)
+	for _, frag := range frags {
+		fm.FormatTextFragment(frag)
+	}
+}
+
+// FormatTextFragmentHeader writes a fragment header in the same format as
+// Git, omitting line counts equal to one.
+func (fm *formatter) FormatTextFragmentHeader(f *TextFragment) {
+	fm.WriteString("@@ -")
+	fm.formatRange(f.OldPosition, f.OldLines)
+	fm.WriteString(" +")
+	fm.formatRange(f.NewPosition, f.NewLines)
+	fm.WriteString(" @@")
+	if f.Comment != "" {
+		fm.WriteByte(' ')
+		fm.WriteString(f.Comment)
+	}
+}
+
+// FormatWordFragment writes a word fragment in the same format as "git diff
+// --word-diff=porcelain", with each chunk on its own line and a "~" line at
+// the end of each line of content.
+func (fm *formatter) FormatWordFragment(f *WordFragment) {
+	fm.FormatTextFragmentHeader(&TextFragment{
+		Comment:     f.Comment,
+		OldPosition: f.OldPosition,
+		OldLines:    f.OldLines,
+		NewPosition: f.NewPosition,
+		NewLines:    f.NewLines,
+	})
+	fm.WriteByte('\n')
+
+	for _, line := range f.Lines {
+		for _, c := range line.Chunks {
+			fm.WriteString(c.Op.String())
+			fm.WriteString(c.Text)
+			fm.WriteByte('\n')
+		}
+		fm.WriteString("~\n")
+	}
+}
+
+func (fm *formatter) formatRange(start, lines int64) {
+	fm.Write(strconv.AppendInt(nil, start, 10))
+	if lines != 1 {
+		fm.WriteByte(',')
+		fm.Write(strconv.AppendInt(nil, lines, 10))
+	}
+}
+
+func (fm *formatter) FormatBinaryFragment(f *BinaryFragment) {
+	const maxBytesPerLine = 52
+
+	switch f.Method {
+	case BinaryPatchDelta:
+		fm.WriteString("delta ")
+	case BinaryPatchLiteral:
+		fm.WriteString("literal ")
+	}
+	fm.Write(strconv.AppendInt(nil, f.Size, 10))
+	fm.WriteByte('\n')
+
+	data, err := deflateBinaryChunk(f.Data)
+	if err != nil {
+		if fm.err == nil {
+			fm.err = err
+		}
+		return
+	}
+
+	buf := make([]byte, base85Len(maxBytesPerLine))
+	for len(data) > 0 {
+		n := len(data)
+		if n > maxBytesPerLine {
+			n = maxBytesPerLine
+		}
+
+		var lengthByte byte
+		if n <= 26 {
+			lengthByte = 'A' + byte(n) - 1
+		} else {
+			lengthByte = 'a' + byte(n) - 27
+		}
+
+		line := buf[:base85Len(n)]
+		base85Encode(line, data[:n])
+
+		fm.WriteByte(lengthByte)
+		fm.Write(line)
+		fm.WriteByte('\n')
+
+		data = data[n:]
+	}
+	fm.WriteByte('\n')
+}
+
+// fileModeOrDefault returns mode or the mode of a regular, non-executable
+// file if mode is unset, as it is for files in traditional patches.
+func shaOrDefault(sha string) string {
+	if sha == "" {
+		return strings.Repeat("0", 40)
+	}
+	return sha
+}
+
+func fileModeOrDefault(mode os.FileMode) os.FileMode {
+	if mode == 0 {
+		return 0100644
+	}
+	return mode
+}
+
+func deflateBinaryChunk(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+
+	zw := zlib.NewWriter(&b)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// quoteName returns name quoted in the same way as Git, using C-style escapes
+// for special characters and octal escapes for bytes outside of the ASCII
+// range. If name contains no special characters, it is returned unchanged.
+func quoteName(name string) string {
+	return quotePath(name, true)
+}
+
+// quotePath is like quoteName, but only quotes bytes outside of the ASCII
+// range if nonASCII is true.
+func quotePath(name string, nonASCII bool) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !needsQuote(c, nonASCII) {
+			continue
+		}
+		if start == 0 {
+			b.WriteByte('"')
+		}
+		b.WriteString(name[start:i])
+		b.WriteByte('\\')
+		switch c {
+		case '\a':
+			b.WriteByte('a')
+		case '\b':
+			b.WriteByte('b')
+		case '\t':
+			b.WriteByte('t')
+		case '\n':
+			b.WriteByte('n')
+		case '\v':
+			b.WriteByte('v')
+		case '\f':
+			b.WriteByte('f')
+		case '\r':
+			b.WriteByte('r')
+		case '"', '\\':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('0' + (c>>6)&07)
+			b.WriteByte('0' + (c>>3)&07)
+			b.WriteByte('0' + c&07)
+		}
+		start = i + 1
+	}
+	if start == 0 {
+		return name
+	}
+	b.WriteString(name[start:])
+	b.WriteByte('"')
+	return b.String()
+}
+
+func needsQuote(c byte, nonASCII bool) bool {
+	return c == '"' || c == '\\' || c < 0x20 || c == 0x7F || (nonASCII && c > 0x7F)
+}
+
+// maxMailLineLength is the length at which Git folds long mail headers.
+const maxMailLineLength = 78
+
+// encodeMailName returns name in a form suitable for an address in a mail
+// header, encoding or quoting it in the same way as Git.
+func encodeMailName(name string) string {
+	if needsMailEncoding(name) {
+		return encodeMailWord("", name, true)
+	}
+	if !strings.ContainsAny(name, `()<>[]:;@\,."`) {
+		return name
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(name); i++ {
+		if name[i] == '"' || name[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(name[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// needs8BitMail returns true if the message of h contains non-ASCII
+// characters, so that a mail with the message must declare an 8-bit body.
+func needs8BitMail(h *PatchHeader) bool {
+	texts := []string{h.Title, h.Body, h.BodyAppendix}
+	for _, n := range h.Notes {
+		texts = append(texts, n.Text)
+	}
+	for _, s := range texts {
+		for i := 0; i < len(s); i++ {
+			if s[i] >= 0x80 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// needsMailEncoding returns true if s must use RFC 2047 encoding in a mail
+// header because it contains non-ASCII characters or looks like an encoded
+// word.
+func needsMailEncoding(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return true
+		}
+	}
+	return strings.Contains(s, "=?")
+}
+
+// encodeMailWord appends s to line as RFC 2047 encoded words in the same way
+// as Git, starting a new line when the encoded words would exceed the maximum
+// length. If address is true, s is encoded for use as the name in an address.
+func encodeMailWord(line, s string, address bool) string {
+	const (
+		start  = "=?UTF-8?q?"
+		end    = "?="
+		maxLen = 76
+	)
+
+	var b strings.Builder
+	b.WriteString(line)
+	b.WriteString(start)
+
+	n := len(line) + len(start)
+	for len(s) > 0 {
+		_, size := utf8.DecodeRuneInString(s)
+		c := s[0]
+
+		special := size > 1 || c == ' ' || c == '\n' || c == '=' || c == '?' || c == '_'
+		if address && !special {
+			special = !(isAlphaNumeric(c) || strings.IndexByte("!*+-/", c) >= 0)
+		}
+
+		encodedLen := size
+		if special {
+			encodedLen = 3 * size
+		}
+		if n+encodedLen+len(end) > maxLen {
+			b.WriteString(end + "\n " + start)
+			n = len(start) + 1
+		}
+
+		for i := 0; i < size; i++ {
+			if special {
+				fmt.Fprintf(&b, "=%02X", s[i])
+			} else {
+				b.WriteByte(s[i])
+			}
+		}
+		n += encodedLen
+		s = s[size:]
+	}
+
+	b.WriteString(end)
+	return b.String()
+}
+
+// foldMailHeader appends the words of s to line, starting a new line that is
+// indented by a space when a word would exceed the maximum line length.
+func foldMailHeader(line, s string) string {
+	var b strings.Builder
+	b.WriteString(line)
+
+	n := len(line)
+	for i, word := range strings.Split(s, " ") {
+		if i > 0 {
+			if n+1+len(word) > maxMailLineLength {
+				b.WriteString("\n")
+				n = 0
+			}
+			b.WriteByte(' ')
+			n++
+		}
+		b.WriteString(word)
+		n += len(word)
+	}
+	return b.String()
+}
+
+func isAlphaNumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
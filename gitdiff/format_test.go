@@ -0,0 +1,688 @@
+package gitdiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatRoundtrip(t *testing.T) {
+	tests := map[string]string{
+		"modify": `diff --git a/dir/file.txt b/dir/file.txt
+index 9540595..30e6333 100644
+--- a/dir/file.txt
++++ b/dir/file.txt
+@@ -1,3 +1,4 @@ func main() {
+ context line
+-old line
++new line 1
++new line 2
+ context line
+`,
+		"renameModeChange": `diff --git a/a b/b
+old mode 100644
+new mode 100755
+similarity index 90%
+rename from a
+rename to b
+index 0ff3bbb..fb3ced1
+--- a/a
++++ b/b
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`,
+		"copy": `diff --git a/a b/b
+similarity index 100%
+copy from a
+copy to b
+`,
+		"newFile": `diff --git a/n b/n
+new file mode 100644
+index 0000000..3e75765
+--- /dev/null
++++ b/n
+@@ -0,0 +1 @@
++new
+`,
+		"newEmptyFile": `diff --git a/empty b/empty
+new file mode 100644
+index 0000000..e69de29
+`,
+		"deleteFile": `diff --git a/d b/d
+deleted file mode 100755
+index 3e75765..0000000
+--- a/d
++++ /dev/null
+@@ -1,2 +0,0 @@
+-line 1
+-line 2
+`,
+		"modeChange": `diff --git a/script.sh b/script.sh
+old mode 100644
+new mode 100755
+`,
+		"spaceInName": `diff --git a/sp ace b/sp ace
+new file mode 100644
+index 0000000..7898192
+--- /dev/null
++++ b/sp ace	
+@@ -0,0 +1 @@
++a
+`,
+		"quotedName": `diff --git "a/u\303\244" "b/u\303\244"
+new file mode 100644
+index 0000000..63d8dbd
+--- /dev/null
++++ "b/u\303\244"
+@@ -0,0 +1 @@
++b
+\ No newline at end of file
+`,
+		"noNewlineBothSides": `diff --git a/f b/f
+index 2e65efe..63d8dbd 100644
+--- a/f
++++ b/f
+@@ -1 +1 @@
+-a
+\ No newline at end of file
++b
+\ No newline at end of file
+`,
+		"combined": `diff --cc f
+index 5f25ea0,2b51d8b..56fe8f8
+--- a/f
++++ b/f
+@@@ -1,9 -1,9 +1,9 @@@
+  1
+  2
+- main
+ -side
+++resolved
+  4
+  5
+  6
+  7
+  8
+ -9
+ +nine
+`,
+		"combinedNewFile": `diff --cc e
+index 0000000,0000000..53c74cd
+new file mode 100644
+--- /dev/null
++++ b/e
+@@@ -1,0 -1,0 +1,1 @@@
+++evil
+`,
+		"combinedModeChange": `diff --cc f
+index 56fe8f8,5f25ea0..56fe8f8
+mode 100644,100644..100755
+--- a/f
++++ b/f
+`,
+		"binaryNoData": `diff --git a/bin b/bin
+index d5d0b8b..4a27031 100644
+Binary files a/bin and b/bin differ
+`,
+	}
+
+	for name, patch := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, err := Parse(strings.NewReader(patch))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			parsed := collectFiles(files)
+			if len(parsed) != 1 {
+				t.Fatalf("patch should contain exactly one file, but it has %d", len(parsed))
+			}
+
+			if out := parsed[0].String(); out != patch {
+				t.Errorf("incorrect formatted patch\nexpected:\n%s\nactual:\n%s", patch, out)
+			}
+		})
+	}
+}
+
+func TestFormatBinaryRoundtrip(t *testing.T) {
+	patch := `diff --git a/bin b/bin
+index d5d0b8b4c4c9e936890870f6799cfbb5ba984470..4a270318359d8c2a960136495bceeae9eee22424 100644
+GIT binary patch
+literal 3
+Kcmb<mr~&{1<pA>l
+
+literal 3
+Kcmb<ms0083<N)#j
+
+`
+
+	large := &File{
+		OldName:  "large.bin",
+		NewName:  "large.bin",
+		IsBinary: true,
+		BinaryFragment: &BinaryFragment{
+			Method: BinaryPatchLiteral,
+			Size:   4096,
+			Data:   bytes.Repeat([]byte{0xCA, 0xFE, 0x00, 0x42, 0x13}, 4096)[:4096],
+		},
+	}
+
+	for name, input := range map[string]string{
+		"git":   patch,
+		"large": large.String(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			files, err := Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			parsed := collectFiles(files)
+			if len(parsed) != 1 {
+				t.Fatalf("patch should contain exactly one file, but it has %d", len(parsed))
+			}
+
+			files, err = Parse(strings.NewReader(parsed[0].String()))
+			if err != nil {
+				t.Fatalf("unexpected error parsing formatted patch: %v", err)
+			}
+			reparsed := collectFiles(files)
+			if len(reparsed) != 1 {
+				t.Fatalf("formatted patch should contain exactly one file, but it has %d", len(reparsed))
+			}
+
+			reparsed[0].PatchHeader = parsed[0].PatchHeader
+			if !reflect.DeepEqual(parsed[0], reparsed[0]) {
+				t.Errorf("formatted patch parsed differently\nexpected: %+v\n  actual: %+v", parsed[0], reparsed[0])
+			}
+		})
+	}
+}
+
+func TestQuoteName(t *testing.T) {
+	tests := map[string]string{
+		"dir/file.txt":   "dir/file.txt",
+		"sp ace":         "sp ace",
+		"tab\tname":      `"tab\tname"`,
+		"quote\"name":    `"quote\"name"`,
+		"back\\slash":    `"back\\slash"`,
+		"uä":             `"u\303\244"`,
+		"newline\nname":  `"newline\nname"`,
+		"bell\a":         `"bell\a"`,
+		"del\x7fname":    `"del\177name"`,
+		"\x01leadingctl": `"\001leadingctl"`,
+	}
+
+	for input, expected := range tests {
+		if actual := quoteName(input); actual != expected {
+			t.Errorf("incorrect quoted name for %q: expected %s, actual %s", input, expected, actual)
+		}
+	}
+}
+
+func TestQuotePath(t *testing.T) {
+	tests := map[string]string{
+		"dir/file.txt": "dir/file.txt",
+		"uä":           "uä",
+		"tab\tuä":      `"tab\tuä"`,
+		"back\\slash":  `"back\\slash"`,
+		"del\x7fname":  `"del\177name"`,
+	}
+
+	for input, expected := range tests {
+		if actual := quotePath(input, false); actual != expected {
+			t.Errorf("incorrect quoted name for %q: expected %s, actual %s", input, expected, actual)
+		}
+	}
+}
+
+func TestFormatQuotePath(t *testing.T) {
+	const patch = `diff --git "a/u\303\244.txt" "b/u\303\244.txt"
+new file mode 100644
+index 0000000..587be6b
+--- /dev/null
++++ "b/u\303\244.txt"
+@@ -0,0 +1 @@
++x
+`
+	const unquoted = `diff --git a/uä.txt b/uä.txt
+new file mode 100644
+index 0000000..587be6b
+--- /dev/null
++++ b/uä.txt
+@@ -0,0 +1 @@
++x
+`
+
+	files, _, err := ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, but got %d", len(files))
+	}
+	if files[0].NewName != "uä.txt" {
+		t.Fatalf("incorrect name: expected %q, actual %q", "uä.txt", files[0].NewName)
+	}
+
+	for name, test := range map[string]struct {
+		Opts   []FormatOption
+		Output string
+	}{
+		"default":     {Output: patch},
+		"quotePath":   {Opts: []FormatOption{WithQuotePath(true)}, Output: patch},
+		"noQuotePath": {Opts: []FormatOption{WithQuotePath(false)}, Output: unquoted},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var b strings.Builder
+			if _, err := Format(&b, files[0], test.Opts...); err != nil {
+				t.Fatalf("unexpected error formatting file: %v", err)
+			}
+			if b.String() != test.Output {
+				t.Errorf("incorrect output\nexpected: %q\n  actual: %q", test.Output, b.String())
+			}
+
+			reparsed, _, err := ParseAll(strings.NewReader(b.String()))
+			if err != nil {
+				t.Fatalf("unexpected error parsing formatted patch: %v", err)
+			}
+			if len(reparsed) != 1 || reparsed[0].NewName != files[0].NewName {
+				t.Errorf("formatted patch does not roundtrip: %+v", reparsed)
+			}
+		})
+	}
+}
+
+func TestFormatTraditional(t *testing.T) {
+	tests := map[string]struct {
+		Input   string
+		Options []FormatOption
+		Output  string
+	}{
+		"timestamps": {
+			Input: "--- file.txt\t2026-10-14 10:00:00.000000000 -0700\n" +
+				"+++ file.txt\t2026-10-14 10:05:30.250000000 -0700\n" +
+				"@@ -1 +1 @@\n" +
+				"-one\n" +
+				"+two\n",
+			Output: "--- a/file.txt\t2026-10-14 10:00:00.000000000 -0700\n" +
+				"+++ b/file.txt\t2026-10-14 10:05:30.250000000 -0700\n" +
+				"@@ -1 +1 @@\n" +
+				"-one\n" +
+				"+two\n",
+		},
+		"newFile": {
+			Input: `diff --git a/file name.txt b/file name.txt
+new file mode 100644
+index 0000000..5626abf
+--- /dev/null
++++ b/file name.txt	
+@@ -0,0 +1 @@
++one
+`,
+			Output: "--- a/file name.txt\t1970-01-01 00:00:00.000000000 +0000\n" +
+				"+++ b/file name.txt\t\n" +
+				"@@ -0,0 +1 @@\n" +
+				"+one\n",
+		},
+		"deletedFileDefaultTime": {
+			Input: `diff --git a/file.txt b/file.txt
+deleted file mode 100644
+index 5626abf..0000000
+--- a/file.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-one
+`,
+			Options: []FormatOption{WithDefaultTime(time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC))},
+			Output: "--- a/file.txt\t2026-10-14 10:00:00.000000000 +0000\n" +
+				"+++ b/file.txt\t1970-01-01 00:00:00.000000000 +0000\n" +
+				"@@ -1 +0,0 @@\n" +
+				"-one\n",
+		},
+		"modeChange": {
+			Input: `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`,
+			Output: "",
+		},
+		"binary": {
+			Input: `diff --git a/file.bin b/file.bin
+index 1111111..2222222 100644
+Binary files a/file.bin and b/file.bin differ
+`,
+			Output: "Binary files a/file.bin and b/file.bin differ\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := ParseAll(strings.NewReader(test.Input))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, but got %d", len(files))
+			}
+
+			var b strings.Builder
+			if _, err := Format(&b, files[0], append(test.Options, WithTraditional())...); err != nil {
+				t.Fatalf("unexpected error formatting file: %v", err)
+			}
+			if b.String() != test.Output {
+				t.Errorf("incorrect output\nexpected: %q\n  actual: %q", test.Output, b.String())
+			}
+		})
+	}
+}
+
+func TestFormatTraditionalFiles(t *testing.T) {
+	const patch = `diff --git a/a.txt b/a.txt
+index 5626abf..f719efd 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-one
++two
+diff --git a/b.txt b/b.txt
+new file mode 100644
+index 0000000..5626abf
+--- /dev/null
++++ b/b.txt
+@@ -0,0 +1 @@
++one
+`
+	const expected = `--- a/a.txt	2026-10-14 10:00:00.000000000 +0000
++++ b/a.txt	2026-10-14 10:00:00.000000000 +0000
+@@ -1 +1 @@
+-one
++two
+--- a/b.txt	1970-01-01 00:00:00.000000000 +0000
++++ b/b.txt	2026-10-14 10:00:00.000000000 +0000
+@@ -0,0 +1 @@
++one
+`
+
+	files, _, err := ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	var b strings.Builder
+	if _, err := FormatTraditional(&b, files, WithDefaultTime(time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC))); err != nil {
+		t.Fatalf("unexpected error formatting files: %v", err)
+	}
+	if b.String() != expected {
+		t.Errorf("incorrect output\nexpected: %q\n  actual: %q", expected, b.String())
+	}
+
+	reparsed, _, err := ParseAll(strings.NewReader(b.String()), WithStripComponents(1))
+	if err != nil {
+		t.Fatalf("unexpected error parsing formatted patch: %v", err)
+	}
+	if len(reparsed) != 2 || reparsed[0].NewName != "a.txt" || !reparsed[1].IsNew || reparsed[1].NewName != "b.txt" {
+		t.Errorf("formatted patch does not roundtrip: %+v", reparsed)
+	}
+}
+
+func TestFormatPatch(t *testing.T) {
+	author := &PatchIdentity{Name: "Jörg Tester", Email: "j@example.com"}
+	date := time.Date(2026, 10, 14, 10, 0, 0, 0, time.FixedZone("", 2*60*60))
+
+	tests := map[string]struct {
+		Patch  string
+		Header PatchHeader
+	}{
+		"baseAndSignature": {
+			Patch: "format_patch.patch",
+			Header: PatchHeader{
+				SHA:           "980987c4df144c625038c38e4425177d1a4a67b5",
+				Author:        author,
+				AuthorDate:    date,
+				SubjectPrefix: "[PATCH] ",
+				Title:         "Change a and add b",
+				Body:          "Body with ümlaut.",
+				Base:          &PatchSeriesBase{Commit: "f4bf16a1235183328572c20ed01bafab58da11f3"},
+				Signature:     "2.39.5",
+			},
+		},
+		"notes": {
+			Patch: "format_patch_notes.patch",
+			Header: PatchHeader{
+				SHA:           "5ac55b324ad16e5e2e383d2025b3ecdcb2656298",
+				Author:        author,
+				AuthorDate:    date,
+				SubjectPrefix: "[PATCH] ",
+				Title:         "ASCII only",
+				BodyAppendix:  "replaced by the diffstat",
+				Notes:         []Note{{Text: "A note"}},
+				Signature:     "2.39.5",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", test.Patch))
+			if err != nil {
+				t.Fatalf("failed to read patch: %v", err)
+			}
+			files, _, err := ParseAll(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			var b strings.Builder
+			if _, err := FormatPatch(&b, &test.Header, files); err != nil {
+				t.Fatalf("unexpected error formatting patch: %v", err)
+			}
+			if b.String() != string(data) {
+				t.Errorf("incorrect patch\nexpected:\n%s\nactual:\n%s", data, b.String())
+			}
+
+			patches, err := ParseMailbox(strings.NewReader(b.String()))
+			if err != nil {
+				t.Fatalf("unexpected error parsing formatted patch: %v", err)
+			}
+			if len(patches) != 1 || len(patches[0].Files) != len(files) {
+				t.Fatalf("formatted patch does not round trip: %+v", patches)
+			}
+			h := patches[0].Header
+			if h.Title != test.Header.Title || h.Body != test.Header.Body || !reflect.DeepEqual(h.Author, test.Header.Author) ||
+				!reflect.DeepEqual(h.Base, test.Header.Base) || h.Signature != test.Header.Signature {
+				t.Errorf("formatted header does not round trip\nexpected: %#v\nactual: %#v", test.Header, *h)
+			}
+		})
+	}
+}
+
+func TestFormatPatchHeader(t *testing.T) {
+	tz := time.FixedZone("", -7*60*60)
+	author := &PatchIdentity{Name: "Morton Haypenny", Email: "mhaypenny@example.com"}
+
+	tests := map[string]struct {
+		Header PatchHeader
+		Pretty string
+		Mail   string
+	}{
+		"full": {
+			Header: PatchHeader{
+				SHA:           "61f5cd90bed4d204ee3feb3aa41ee91d4734855b",
+				Author:        author,
+				AuthorDate:    time.Date(2020, 4, 5, 10, 0, 0, 0, tz),
+				Committer:     &PatchIdentity{Name: "Joe Smith", Email: "joe.smith@company.com"},
+				CommitterDate: time.Date(2020, 4, 11, 15, 21, 23, 0, tz),
+				SubjectPrefix: "[PATCH] ",
+				Title:         "A sample commit to test header formatting",
+				Body:          "The body may wrap on to\nmultiple lines.\n\nSigned-off-by: Morton Haypenny <mhaypenny@example.com>",
+				BodyAppendix:  " file.txt | 2 +-",
+			},
+			Pretty: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+Author:     Morton Haypenny <mhaypenny@example.com>
+AuthorDate: Sun Apr 5 10:00:00 2020 -0700
+Commit:     Joe Smith <joe.smith@company.com>
+CommitDate: Sat Apr 11 15:21:23 2020 -0700
+
+    A sample commit to test header formatting
+
+    The body may wrap on to
+    multiple lines.
+
+    Signed-off-by: Morton Haypenny <mhaypenny@example.com>
+`,
+			Mail: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sun, 5 Apr 2020 10:00:00 -0700
+Subject: [PATCH] A sample commit to test header formatting
+
+The body may wrap on to
+multiple lines.
+
+Signed-off-by: Morton Haypenny <mhaypenny@example.com>
+---
+ file.txt | 2 +-
+`,
+		},
+		"medium": {
+			Header: PatchHeader{
+				SHA:        "61f5cd90bed4d204ee3feb3aa41ee91d4734855b",
+				Author:     author,
+				AuthorDate: time.Date(2020, 4, 5, 10, 0, 0, 0, tz),
+				Title:      "A sample commit to test header formatting",
+			},
+			Pretty: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+Author: Morton Haypenny <mhaypenny@example.com>
+Date:   Sun Apr 5 10:00:00 2020 -0700
+
+    A sample commit to test header formatting
+`,
+			Mail: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sun, 5 Apr 2020 10:00:00 -0700
+Subject: A sample commit to test header formatting
+
+`,
+		},
+		"notes": {
+			Header: PatchHeader{
+				SHA:             "61f5cd90bed4d204ee3feb3aa41ee91d4734855b",
+				Author:          author,
+				SubjectPrefix:   "[PATCH] ",
+				Title:           "A sample commit to test header formatting",
+				BodyAppendix:    " file.txt | 2 +-",
+				Notes:           []Note{{Text: "A note about\n\nthe commit."}, {Ref: "review", Text: "Looks good."}},
+				SignatureStatus: "gpg: Good signature from \"Morton Haypenny <mhaypenny@example.com>\"",
+			},
+			Pretty: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+gpg: Good signature from "Morton Haypenny <mhaypenny@example.com>"
+Author: Morton Haypenny <mhaypenny@example.com>
+
+    A sample commit to test header formatting
+
+Notes:
+    A note about
+
+    the commit.
+
+Notes (review):
+    Looks good.
+`,
+			Mail: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] A sample commit to test header formatting
+
+---
+
+Notes:
+    A note about
+
+    the commit.
+
+Notes (review):
+    Looks good.
+
+ file.txt | 2 +-
+`,
+		},
+		"longSubject": {
+			Header: PatchHeader{
+				SHA:           "54a8542b3a55cc79fd6f5484e0b45a7fcc225a1a",
+				Author:        author,
+				SubjectPrefix: "[PATCH] ",
+				Title:         "This is a very long subject line that goes on and on so that it needs folding when written as an email header",
+			},
+			Mail: `From 54a8542b3a55cc79fd6f5484e0b45a7fcc225a1a Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] This is a very long subject line that goes on and on so that
+ it needs folding when written as an email header
+
+`,
+		},
+		"encodedSubject": {
+			Header: PatchHeader{
+				Author:        &PatchIdentity{Name: "Uwe Kleine-König", Email: "uwe@example.com"},
+				SubjectPrefix: "[PATCH] ",
+				Title:         "Add Ünïcödé support to a very long subject line that needs to be folded into several lines",
+			},
+			Mail: `From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001
+From: =?UTF-8?q?Uwe=20Kleine-K=C3=B6nig?= <uwe@example.com>
+Subject: [PATCH] =?UTF-8?q?Add=20=C3=9Cn=C3=AFc=C3=B6d=C3=A9=20support=20t?=
+ =?UTF-8?q?o=20a=20very=20long=20subject=20line=20that=20needs=20to=20be?=
+ =?UTF-8?q?=20folded=20into=20several=20lines?=
+
+`,
+		},
+		"quotedName": {
+			Header: PatchHeader{
+				Author:        &PatchIdentity{Name: "Doe, John", Email: "john@example.com"},
+				SubjectPrefix: "[PATCH] ",
+				Title:         "Short = subject? with_underscores",
+			},
+			Mail: `From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001
+From: "Doe, John" <john@example.com>
+Subject: [PATCH] Short = subject? with_underscores
+
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, f := range []struct {
+				Name     string
+				Output   string
+				Expected string
+			}{
+				{"pretty", test.Header.String(), test.Pretty},
+				{"mail", test.Header.MailString(), test.Mail},
+			} {
+				if f.Expected == "" {
+					continue
+				}
+				if f.Output != f.Expected {
+					t.Fatalf("incorrect %s header\nexpected:\n%s\nactual:\n%s", f.Name, f.Expected, f.Output)
+				}
+
+				h, err := ParsePatchHeader(f.Output)
+				if err != nil {
+					t.Fatalf("unexpected error parsing %s header: %v", f.Name, err)
+				}
+				if h.Title != test.Header.Title || h.Body != test.Header.Body || !reflect.DeepEqual(h.Author, test.Header.Author) ||
+					!reflect.DeepEqual(h.Notes, test.Header.Notes) {
+					t.Errorf("%s header does not round trip\nexpected: %#v\nactual: %#v", f.Name, test.Header, *h)
+				}
+			}
+		})
+	}
+}
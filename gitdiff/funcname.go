@@ -0,0 +1,232 @@
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// like git, function names in fragment headers are at most 80 bytes
+const maxFuncNameLength = 80
+
+// FuncPattern finds the lines that start functions or other sections of a
+// file, which Git shows after the "@@" of each fragment header. A nil
+// FuncPattern uses the default rule of Git, which matches lines that start
+// with a letter, an underscore, or a dollar sign.
+type FuncPattern struct {
+	patterns []funcPatternLine
+}
+
+type funcPatternLine struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+// CompileFuncPattern compiles a pattern with the syntax of the
+// diff.<driver>.xfuncname configuration in Git: one or more POSIX extended
+// regular expressions separated by newlines. A line is a function line if the
+// first expression that matches it does not start with "!". If that
+// expression has a group, the function name is the text of the first group;
+// otherwise, it is the text of the match.
+func CompileFuncPattern(pattern string) (*FuncPattern, error) {
+	var p FuncPattern
+	for _, expr := range strings.Split(pattern, "\n") {
+		negate := strings.HasPrefix(expr, "!")
+		if negate {
+			expr = expr[1:]
+		}
+		re, err := regexp.CompilePOSIX(expr)
+		if err != nil {
+			return nil, fmt.Errorf("gitdiff: invalid function pattern: %w", err)
+		}
+		p.patterns = append(p.patterns, funcPatternLine{re: re, negate: negate})
+	}
+	if p.patterns[len(p.patterns)-1].negate {
+		return nil, errors.New("gitdiff: invalid function pattern: last expression must not be negated")
+	}
+	return &p, nil
+}
+
+// builtinFuncPatterns are the function patterns of the built-in diff drivers
+// in Git. The css pattern matches letters of both cases instead of ignoring
+// case, which is not available with POSIX syntax.
+var builtinFuncPatterns = map[string]string{
+	"bash": "^[ \t]*(([a-zA-Z_][a-zA-Z0-9_]*[ \t]*\\([ \t]*\\))|(function[ \t]+[a-zA-Z_][a-zA-Z0-9_]*(([ \t]*\\([ \t]*\\))|([ \t]+)))[ \t]*(\\{|\\(\\(?|\\[\\[))",
+	"cpp": "!^[ \t]*[A-Za-z_][A-Za-z_0-9]*:[[:space:]]*($|/[/*])\n" +
+		"^((::[[:space:]]*)?[A-Za-z_].*)$",
+	"csharp": "!^[ \t]*(do|while|for|if|else|instanceof|new|return|switch|case|throw|catch|using)\n" +
+		"^[ \t]*(((static|public|internal|private|protected|new|virtual|sealed|override|unsafe|async)[ \t]+)*[][<>@.~_[:alnum:]]+[ \t]+[<>@._[:alnum:]]+[ \t]*\\(.*\\))[ \t]*$\n" +
+		"^[ \t]*(((static|public|internal|private|protected|new|virtual|sealed|override|unsafe)[ \t]+)*[][<>@.~_[:alnum:]]+[ \t]+[@._[:alnum:]]+)[ \t]*$\n" +
+		"^[ \t]*(((static|public|internal|private|protected|new|unsafe|sealed|abstract|partial)[ \t]+)*(class|enum|interface|struct|record)[ \t]+.*)$\n" +
+		"^[ \t]*(namespace[ \t]+.*)$",
+	"css": "![:;][[:space:]]*$\n" +
+		"^[:[@.#]?[_a-zA-Z0-9].*$",
+	"golang": "^[ \t]*(func[ \t]*.*(\\{[ \t]*)?)\n" +
+		"^[ \t]*(type[ \t].*(struct|interface)[ \t]*(\\{[ \t]*)?)",
+	"html": "^[ \t]*(<[Hh][1-6]([ \t].*)?>.*)$",
+	"java": "!^[ \t]*(catch|do|for|if|instanceof|new|return|switch|throw|while)\n" +
+		"^[ \t]*(([a-z]+[ \t]+)*(class|enum|interface)[ \t]+[A-Za-z][A-Za-z0-9_$]*[ \t]+.*)$\n" +
+		"^[ \t]*(([A-Za-z_<>&][][?&<>.,A-Za-z_0-9]*[ \t]+)+[A-Za-z_][A-Za-z_0-9]*[ \t]*\\([^;]*)$",
+	"kotlin":   "^[ \t]*(([a-z]+[ \t]+)*(fun|class|interface)[ \t]+.*)$",
+	"markdown": "^ {0,3}#{1,6}[ \t].*",
+	"perl": "^package .*\n" +
+		"^sub [[:alnum:]_':]+[ \t]*(\\([^)]*\\)[ \t]*)?(:[^;#]*)?(\\{[ \t]*)?(#.*)?$\n" +
+		"^(BEGIN|END|INIT|CHECK|UNITCHECK|AUTOLOAD|DESTROY)[ \t]*(\\{[ \t]*)?(#.*)?$\n" +
+		"^=head[0-9] .*",
+	"php": "^[\t ]*(((public|protected|private|static|abstract|final)[\t ]+)*function.*)$\n" +
+		"^[\t ]*((((final|abstract)[\t ]+)?class|enum|interface|trait).*)$",
+	"python": "^[ \t]*((class|(async[ \t]+)?def)[ \t].*)$",
+	"ruby":   "^[ \t]*((class|module|def)[ \t].*)$",
+	"rust":   "^[\t ]*((pub(\\([^\\)]+\\))?[\t ]+)?((async|const|unsafe|extern([\t ]+\"[^\"]+\"))[\t ]+)?(struct|enum|union|mod|trait|fn|impl|macro_rules!)[< \t]+[^;]*)$",
+}
+
+// BuiltinFuncPattern returns the function pattern of the built-in diff driver
+// with the given name in Git, like "golang" or "python", or nil if there is
+// no such driver.
+func BuiltinFuncPattern(driver string) *FuncPattern {
+	pattern, ok := builtinFuncPatterns[driver]
+	if !ok {
+		return nil
+	}
+	p, err := CompileFuncPattern(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in function pattern %q: %v", driver, err))
+	}
+	return p
+}
+
+// funcPatternDrivers maps file extensions to the built-in diff drivers.
+var funcPatternDrivers = map[string]string{
+	".bash":     "bash",
+	".sh":       "bash",
+	".c":        "cpp",
+	".cc":       "cpp",
+	".cpp":      "cpp",
+	".cxx":      "cpp",
+	".h":        "cpp",
+	".hpp":      "cpp",
+	".cs":       "csharp",
+	".css":      "css",
+	".go":       "golang",
+	".htm":      "html",
+	".html":     "html",
+	".java":     "java",
+	".kt":       "kotlin",
+	".kts":      "kotlin",
+	".markdown": "markdown",
+	".md":       "markdown",
+	".pl":       "perl",
+	".pm":       "perl",
+	".php":      "php",
+	".py":       "python",
+	".rb":       "ruby",
+	".rs":       "rust",
+}
+
+// FuncPatternForFile returns the function pattern of the built-in diff driver
+// for the language of the named file, based on its extension, or nil if the
+// language does not have a driver. Git only uses drivers set with the diff
+// attribute, so it is like a .gitattributes file that sets the driver for the
+// common extensions of each language.
+func FuncPatternForFile(name string) *FuncPattern {
+	return BuiltinFuncPattern(funcPatternDrivers[path.Ext(name)])
+}
+
+// Match returns the function name in line and true if line is a function
+// line. Like Git, the name does not include trailing whitespace and is
+// truncated to 80 bytes.
+func (p *FuncPattern) Match(line string) (string, bool) {
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+
+	var name string
+	if p == nil {
+		if line == "" || !(isAlpha(line[0]) || line[0] == '_' || line[0] == '$') {
+			return "", false
+		}
+		name = line
+	} else {
+		var match []int
+		var negate bool
+		for _, pl := range p.patterns {
+			if match = pl.re.FindStringSubmatchIndex(line); match != nil {
+				negate = pl.negate
+				break
+			}
+		}
+		if match == nil || negate {
+			return "", false
+		}
+		if len(match) > 2 && match[2] >= 0 {
+			name = line[match[2]:match[3]]
+		} else {
+			name = line[match[0]:match[1]]
+		}
+	}
+
+	if len(name) > maxFuncNameLength {
+		name = name[:maxFuncNameLength]
+	}
+	return strings.TrimRight(name, " \t\n\v\f\r"), true
+}
+
+// WithFuncPattern configures Diff to set the Comment of each fragment to the
+// name of the function that contains the start of the fragment, like the
+// function names that Git shows in fragment headers. It uses p to find the
+// closest function line before the fragment in the old content. If p is nil,
+// Diff uses the default rule of Git.
+func WithFuncPattern(p *FuncPattern) DiffOption {
+	return WithFuncPatterns(func(string) *FuncPattern { return p })
+}
+
+// WithFuncPatterns is like WithFuncPattern, but calls patterns with the old
+// name of each file, or the new name if the file is new, to get its function
+// pattern. FuncPatternForFile is a possible value for patterns.
+func WithFuncPatterns(patterns func(name string) *FuncPattern) DiffOption {
+	return func(d *differ) {
+		d.funcPatterns = patterns
+	}
+}
+
+// SetFuncNames sets the Comment of each text fragment of the file to the name
+// of the function that contains the start of the fragment, like WithFuncPattern
+// for Diff. It reads the old content of the file from src. Fragments without a
+// function line before them have an empty Comment. The fragments must be
+// valid and in order.
+func (f *File) SetFuncNames(src io.Reader, p *FuncPattern) error {
+	if err := f.LoadFragments(); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	setFuncNames(f.TextFragments, splitLines(string(data)), p)
+	return nil
+}
+
+// setFuncNames sets the Comment of each fragment using the old lines of a
+// file. Like Git, it searches backwards from the line before each fragment.
+func setFuncNames(frags []*TextFragment, lines []string, p *FuncPattern) {
+	for _, frag := range frags {
+		frag.Comment = ""
+		start := fragmentStart(frag.OldPosition, frag.OldLines) - 2
+		if max := int64(len(lines)) - 1; start > max {
+			start = max
+		}
+		for i := start; i >= 0; i-- {
+			if name, ok := p.Match(lines[i]); ok {
+				frag.Comment = name
+				break
+			}
+		}
+	}
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
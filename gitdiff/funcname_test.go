@@ -0,0 +1,187 @@
+package gitdiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFuncPatternMatch(t *testing.T) {
+	tests := map[string]struct {
+		Pattern *FuncPattern
+		Line    string
+		Name    string
+		OK      bool
+	}{
+		"defaultLetter": {
+			Line: "func main() {\n",
+			Name: "func main() {",
+			OK:   true,
+		},
+		"defaultDollar": {
+			Line: "$var = 1;\n",
+			Name: "$var = 1;",
+			OK:   true,
+		},
+		"defaultIndented": {
+			Line: "\treturn nil\n",
+		},
+		"defaultEmpty": {
+			Line: "\n",
+		},
+		"trailingWhitespace": {
+			Line: "section \t\r\n",
+			Name: "section",
+			OK:   true,
+		},
+		"truncated": {
+			Line: strings.Repeat("x", 100) + "\n",
+			Name: strings.Repeat("x", 80),
+			OK:   true,
+		},
+		"group": {
+			Pattern: BuiltinFuncPattern("python"),
+			Line:    "    async def run(self):\n",
+			Name:    "async def run(self):",
+			OK:      true,
+		},
+		"wholeMatch": {
+			Pattern: BuiltinFuncPattern("markdown"),
+			Line:    "## Usage\n",
+			Name:    "## Usage",
+			OK:      true,
+		},
+		"noMatch": {
+			Pattern: BuiltinFuncPattern("python"),
+			Line:    "    return x\n",
+		},
+		"negated": {
+			Pattern: BuiltinFuncPattern("cpp"),
+			Line:    "public:\n",
+		},
+		"afterNegated": {
+			Pattern: BuiltinFuncPattern("cpp"),
+			Line:    "int main(void)\n",
+			Name:    "int main(void)",
+			OK:      true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			name, ok := test.Pattern.Match(test.Line)
+			if ok != test.OK {
+				t.Fatalf("incorrect match result: expected %t, actual %t", test.OK, ok)
+			}
+			if name != test.Name {
+				t.Errorf("incorrect function name: expected %q, actual %q", test.Name, name)
+			}
+		})
+	}
+}
+
+func TestCompileFuncPattern(t *testing.T) {
+	tests := map[string]struct {
+		Pattern string
+		Err     interface{}
+	}{
+		"single":       {Pattern: "^sub .*"},
+		"multiple":     {Pattern: "!^_\n^[a-z]+"},
+		"invalid":      {Pattern: "^(func", Err: "invalid function pattern"},
+		"lastNegated":  {Pattern: "^func\n!^type", Err: "must not be negated"},
+		"posixClasses": {Pattern: "^[[:alpha:]_][[:alnum:]_]*:"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileFuncPattern(test.Pattern)
+			if test.Err != nil {
+				assertError(t, test.Err, err, "compiling pattern")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error compiling pattern: %v", err)
+			}
+		})
+	}
+}
+
+func TestBuiltinFuncPattern(t *testing.T) {
+	for driver := range builtinFuncPatterns {
+		if p := BuiltinFuncPattern(driver); p == nil {
+			t.Errorf("missing built-in pattern %q", driver)
+		}
+	}
+	if p := BuiltinFuncPattern("unknown"); p != nil {
+		t.Errorf("expected nil pattern for unknown driver, but got %v", p)
+	}
+	if p := FuncPatternForFile("README"); p != nil {
+		t.Errorf("expected nil pattern for file without extension, but got %v", p)
+	}
+}
+
+// TestDiffFuncPatterns compares the output of Diff to a patch generated by
+// Git with the golang and python drivers set in .gitattributes.
+func TestDiffFuncPatterns(t *testing.T) {
+	expected, err := os.ReadFile(filepath.Join("testdata", "funcname.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+
+	var out bytes.Buffer
+	for _, name := range []string{"a.go", "b.py", "c.txt"} {
+		old, new := readFuncNameFile(t, "old", name), readFuncNameFile(t, "new", name)
+
+		f, err := Diff(bytes.NewReader(old), bytes.NewReader(new), WithNames(name, name), WithFuncPatterns(FuncPatternForFile))
+		if err != nil {
+			t.Fatalf("unexpected error computing diff: %v", err)
+		}
+		out.WriteString(f.String())
+	}
+
+	if out.String() != string(expected) {
+		t.Errorf("incorrect diff\nexpected:\n%s\nactual:\n%s", expected, out.String())
+	}
+}
+
+func TestSetFuncNames(t *testing.T) {
+	patch, err := os.Open(filepath.Join("testdata", "funcname.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer patch.Close()
+
+	files, _, err := ParseAll(patch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	for _, f := range files {
+		var expected []string
+		for _, frag := range f.TextFragments {
+			expected = append(expected, frag.Comment)
+			frag.Comment = "stale"
+		}
+
+		src := bytes.NewReader(readFuncNameFile(t, "old", f.OldName))
+		if err := f.SetFuncNames(src, FuncPatternForFile(f.OldName)); err != nil {
+			t.Fatalf("unexpected error setting function names: %v", err)
+		}
+
+		for i, frag := range f.TextFragments {
+			if frag.Comment != expected[i] {
+				t.Errorf("%s: fragment %d: incorrect comment: expected %q, actual %q", f.OldName, i+1, expected[i], frag.Comment)
+			}
+		}
+	}
+}
+
+func readFuncNameFile(t *testing.T, side, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", "funcname", side, name))
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,43 @@
+//go:build go1.18
+// +build go1.18
+
+package gitdiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzParseLenient(f *testing.F) {
+	for _, pattern := range []string{"testdata/*.patch", "testdata/apply/*.patch"} {
+		names, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatalf("invalid pattern: %v", err)
+		}
+		for _, name := range names {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				f.Fatalf("failed to read %s: %v", name, err)
+			}
+			f.Add(data)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		res := ParseLenient(bytes.NewReader(data), WithMaxLineLength(1<<16), WithMaxBinarySize(1<<20))
+		if res.Err != nil && strings.Contains(res.Err.Error(), "internal error") {
+			t.Fatalf("parser panicked: %v", res.Err)
+		}
+		for _, err := range res.Errors {
+			if err.Line < 1 || err.Offset < -1 || err.Offset > int64(len(data)) {
+				t.Errorf("invalid location for error: line %d, offset %d: %v", err.Line, err.Offset, err)
+			}
+		}
+		for _, file := range res.Files {
+			_ = file.String()
+		}
+	})
+}
@@ -5,59 +5,227 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 )
 
 // File describes changes to a single file. It can be either a text file or a
 // binary file.
 type File struct {
-	OldName string
-	NewName string
+	OldName string `json:"old_name,omitempty"`
+	NewName string `json:"new_name,omitempty"`
 
-	IsNew    bool
-	IsDelete bool
-	IsCopy   bool
-	IsRename bool
+	IsNew    bool `json:"is_new,omitempty"`
+	IsDelete bool `json:"is_delete,omitempty"`
+	IsCopy   bool `json:"is_copy,omitempty"`
+	IsRename bool `json:"is_rename,omitempty"`
 
-	OldMode os.FileMode
-	NewMode os.FileMode
+	OldMode os.FileMode `json:"old_mode,omitempty"`
+	NewMode os.FileMode `json:"new_mode,omitempty"`
 
-	OldOIDPrefix string
-	NewOIDPrefix string
-	Score        int
+	OldOIDPrefix string `json:"old_oid_prefix,omitempty"`
+	NewOIDPrefix string `json:"new_oid_prefix,omitempty"`
 
-	PatchHeader *PatchHeader
+	// HashAlgorithm is the algorithm of the object IDs. Parse detects it from
+	// the length of full object IDs, like those in the headers of binary
+	// patches, and leaves it unknown if all IDs are abbreviated.
+	HashAlgorithm HashAlgorithm `json:"hash_algorithm,omitempty"`
+
+	// Score is the similarity index of a copy or rename or the dissimilarity
+	// index of a rewritten file, as a percentage from 0 to 100.
+	Score int `json:"score,omitempty"`
+
+	// OldTime and NewTime are the modification times of the original and new
+	// files from the timestamps on the "---" and "+++" lines of traditional
+	// and context patches, like those created by GNU diff. They are the zero
+	// time if the patch has no timestamps or uses an unknown format.
+	OldTime time.Time `json:"old_time,omitempty"`
+	NewTime time.Time `json:"new_time,omitempty"`
+
+	PatchHeader *PatchHeader `json:"patch_header,omitempty"`
 
 	// TextFragments contains the fragments describing changes to a text file. It
 	// may be empty if the file is empty or if only the mode changes.
-	TextFragments []*TextFragment
+	TextFragments []*TextFragment `json:"text_fragments,omitempty"`
 
 	// IsBinary is true if the file is a binary file. If the patch includes
 	// binary data, BinaryFragment will be non-nil and describe the changes to
 	// the data. If the patch is reversible, ReverseBinaryFragment will also be
 	// non-nil and describe the changes needed to restore the original file
 	// after applying the changes in BinaryFragment.
-	IsBinary              bool
-	BinaryFragment        *BinaryFragment
-	ReverseBinaryFragment *BinaryFragment
+	IsBinary              bool            `json:"is_binary,omitempty"`
+	BinaryFragment        *BinaryFragment `json:"binary_fragment,omitempty"`
+	ReverseBinaryFragment *BinaryFragment `json:"reverse_binary_fragment,omitempty"`
+
+	// IsCombined is true if the file is from a combined diff, which compares
+	// the result of a merge with each of its parents. Combined files describe
+	// changes using CombinedFragments instead of TextFragments. The OIDs and
+	// modes of the parents are in ParentOIDPrefixes and ParentModes, while
+	// NewOIDPrefix and NewMode describe the merge result.
+	IsCombined        bool                `json:"is_combined,omitempty"`
+	ParentOIDPrefixes []string            `json:"parent_oid_prefixes,omitempty"`
+	ParentModes       []os.FileMode       `json:"parent_modes,omitempty"`
+	CombinedFragments []*CombinedFragment `json:"combined_fragments,omitempty"`
+
+	// WordFragments contains the fragments of a text file from a word diff
+	// generated by "git diff --word-diff=porcelain". ParseWordDiff parses word
+	// fragments instead of TextFragments.
+	WordFragments []*WordFragment `json:"word_fragments,omitempty"`
+
+	// Submodule describes the change if the file is a submodule. Parse
+	// replaces the text fragment of a submodule with Submodule.
+	Submodule *SubmoduleChange `json:"submodule,omitempty"`
+
+	// Labels are the labels assigned to the file by the classifiers set with
+	// WithClassifier, like the language of the file.
+	Labels []string `json:"labels,omitempty"`
+
+	lazy *lazyFragments
+}
+
+// Operation returns the type of change the file describes.
+func (f *File) Operation() FileOperation {
+	switch {
+	case f.IsNew:
+		return FileCreate
+	case f.IsDelete:
+		return FileDelete
+	case f.IsCopy:
+		return FileCopy
+	case f.IsRename:
+		return FileRename
+	}
+	return FileModify
+}
+
+// IsModeOnlyChange returns true if f changes the mode of an existing file
+// without changing its name or content, like a patch that makes a script
+// executable.
+func (f *File) IsModeOnlyChange() bool {
+	return f.Operation() == FileModify && f.OldMode != 0 && f.NewMode != 0 && f.OldMode != f.NewMode && !f.hasContentChanges()
+}
+
+// IsEmptyNewFile returns true if f creates a file without content. Git
+// patches for empty files have a header but no fragments.
+func (f *File) IsEmptyNewFile() bool {
+	return f.IsNew && !f.hasContentChanges()
+}
+
+// IsEmptyDeletedFile returns true if f deletes a file without content. Git
+// patches for empty files have a header but no fragments.
+func (f *File) IsEmptyDeletedFile() bool {
+	return f.IsDelete && !f.hasContentChanges()
+}
+
+// IsRenameOnly returns true if f renames a file without changing its content
+// or mode.
+func (f *File) IsRenameOnly() bool {
+	return f.Operation() == FileRename && (f.OldMode == 0 || f.NewMode == 0 || f.OldMode == f.NewMode) && !f.hasContentChanges()
+}
+
+// hasContentChanges returns true if f has fragments or other changes to the
+// content of the file, including fragments that are not loaded yet and
+// binary files without binary data.
+func (f *File) hasContentChanges() bool {
+	return f.lazy != nil ||
+		f.IsBinary ||
+		f.Submodule != nil ||
+		len(f.TextFragments) > 0 ||
+		len(f.CombinedFragments) > 0 ||
+		len(f.WordFragments) > 0
+}
+
+// FileOperation is the type of change to a file.
+type FileOperation int
+
+const (
+	// FileModify indicates changes to the content or mode of an existing file
+	FileModify FileOperation = iota
+	// FileCreate indicates the creation of a new file
+	FileCreate
+	// FileDelete indicates the deletion of a file
+	FileDelete
+	// FileRename indicates a file that moves to a new name, possibly with
+	// changes to its content or mode
+	FileRename
+	// FileCopy indicates a new file that is a copy of an existing file,
+	// possibly with changes to its content or mode
+	FileCopy
+)
+
+func (op FileOperation) String() string {
+	switch op {
+	case FileModify:
+		return "modify"
+	case FileCreate:
+		return "create"
+	case FileDelete:
+		return "delete"
+	case FileRename:
+		return "rename"
+	case FileCopy:
+		return "copy"
+	}
+	return "unknown"
+}
+
+// Reverse returns a new File that undoes the changes in f. The old and new
+// names, modes, and OIDs are swapped, creations become deletions (and vice
+// versa), and all fragments are reversed. If f is binary, the forward and
+// reverse binary fragments are swapped, so the reversed file has no binary
+// data if f did not include a reverse fragment.
+func (f *File) Reverse() *File {
+	if f == nil {
+		return nil
+	}
+	if f.lazy != nil {
+		// reverse loaded fragments, or only the header of invalid fragments
+		loaded := *f
+		if err := loaded.LoadFragments(); err != nil {
+			loaded.lazy = nil
+		}
+		return loaded.Reverse()
+	}
+
+	r := *f
+	r.OldName, r.NewName = f.NewName, f.OldName
+	r.IsNew, r.IsDelete = f.IsDelete, f.IsNew
+	r.OldMode, r.NewMode = f.NewMode, f.OldMode
+	r.OldOIDPrefix, r.NewOIDPrefix = f.NewOIDPrefix, f.OldOIDPrefix
+	r.OldTime, r.NewTime = f.NewTime, f.OldTime
+
+	if f.TextFragments != nil {
+		r.TextFragments = make([]*TextFragment, len(f.TextFragments))
+		for i, frag := range f.TextFragments {
+			r.TextFragments[i] = frag.Reverse()
+		}
+	}
+	r.BinaryFragment, r.ReverseBinaryFragment = f.ReverseBinaryFragment, f.BinaryFragment
+
+	return &r
 }
 
 // TextFragment describes changed lines starting at a specific line in a text file.
 type TextFragment struct {
-	Comment string
+	Comment string `json:"comment,omitempty"`
 
-	OldPosition int64
-	OldLines    int64
+	OldPosition int64 `json:"old_position,omitempty"`
+	OldLines    int64 `json:"old_lines,omitempty"`
 
-	NewPosition int64
-	NewLines    int64
+	NewPosition int64 `json:"new_position,omitempty"`
+	NewLines    int64 `json:"new_lines,omitempty"`
 
-	LinesAdded   int64
-	LinesDeleted int64
+	LinesAdded   int64 `json:"lines_added,omitempty"`
+	LinesDeleted int64 `json:"lines_deleted,omitempty"`
 
-	LeadingContext  int64
-	TrailingContext int64
+	LeadingContext  int64 `json:"leading_context,omitempty"`
+	TrailingContext int64 `json:"trailing_context,omitempty"`
 
-	Lines []Line
+	Lines []Line `json:"lines,omitempty"`
+
+	// Origin is the location of the fragment in the patch if it was parsed
+	// with WithPatchPositions. Methods that create new fragments do not set
+	// it, so it may be nil even for parsed patches.
+	Origin *FragmentOrigin `json:"origin,omitempty"`
 }
 
 func (f *TextFragment) Raw(op LineOp) string {
@@ -70,6 +238,62 @@ func (f *TextFragment) Raw(op LineOp) string {
 	return sb.String()
 }
 
+// OldMissingNewline returns true if the text fragments of f show that the
+// old content of the file does not end with a newline. It is false if the
+// fragments do not include the end of the old content.
+func (f *File) OldMissingNewline() bool {
+	if l, err := f.loaded(); err == nil {
+		f = l
+	}
+	for _, frag := range f.TextFragments {
+		if frag.OldMissingNewline() {
+			return true
+		}
+	}
+	return false
+}
+
+// NewMissingNewline returns true if the text fragments of f show that the
+// new content of the file does not end with a newline. It is false if the
+// fragments do not include the end of the new content.
+func (f *File) NewMissingNewline() bool {
+	if l, err := f.loaded(); err == nil {
+		f = l
+	}
+	for _, frag := range f.TextFragments {
+		if frag.NewMissingNewline() {
+			return true
+		}
+	}
+	return false
+}
+
+// OldMissingNewline returns true if the last old line of the fragment does
+// not end with a newline, which patches show with a "\ No newline at end of
+// file" marker. This means the fragment ends at the end of the old content
+// and the old content does not end with a newline.
+func (f *TextFragment) OldMissingNewline() bool {
+	return missingNewline(f.Lines, Line.Old)
+}
+
+// NewMissingNewline returns true if the last new line of the fragment does
+// not end with a newline. This means the fragment ends at the end of the new
+// content and the new content does not end with a newline.
+func (f *TextFragment) NewMissingNewline() bool {
+	return missingNewline(f.Lines, Line.New)
+}
+
+// missingNewline returns true if the last line of lines for which side
+// returns true does not end with a newline.
+func missingNewline(lines []Line, side func(Line) bool) bool {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if side(lines[i]) {
+			return lines[i].NoEOL()
+		}
+	}
+	return false
+}
+
 // Header returns the canonical header of this fragment.
 func (f *TextFragment) Header() string {
 	return fmt.Sprintf("@@ -%d,%d +%d,%d @@ %s", f.OldPosition, f.OldLines, f.NewPosition, f.NewLines, f.Comment)
@@ -141,14 +365,56 @@ func (f *TextFragment) Validate() error {
 	return nil
 }
 
+// Reverse returns a new fragment that undoes the changes in f. Added lines
+// become deleted lines and deleted lines become added lines. Within each
+// block of changes, the new deleted lines appear before the new added lines.
+func (f *TextFragment) Reverse() *TextFragment {
+	if f == nil {
+		return nil
+	}
+
+	r := &TextFragment{
+		Comment:         f.Comment,
+		OldPosition:     f.NewPosition,
+		OldLines:        f.NewLines,
+		NewPosition:     f.OldPosition,
+		NewLines:        f.OldLines,
+		LinesAdded:      f.LinesDeleted,
+		LinesDeleted:    f.LinesAdded,
+		LeadingContext:  f.LeadingContext,
+		TrailingContext: f.TrailingContext,
+	}
+	if f.Lines == nil {
+		return r
+	}
+
+	r.Lines = make([]Line, 0, len(f.Lines))
+	var adds []Line
+	for _, line := range f.Lines {
+		switch line.Op {
+		case OpAdd:
+			r.Lines = append(r.Lines, Line{OpDelete, line.Line})
+		case OpDelete:
+			adds = append(adds, Line{OpAdd, line.Line})
+		default:
+			r.Lines = append(r.Lines, adds...)
+			r.Lines = append(r.Lines, line)
+			adds = adds[:0]
+		}
+	}
+	r.Lines = append(r.Lines, adds...)
+
+	return r
+}
+
 func lineCountErr(kind string, actual, reported int64) error {
 	return fmt.Errorf("fragment contains %d %s lines but reports %d", actual, kind, reported)
 }
 
 // Line is a line in a text fragment.
 type Line struct {
-	Op   LineOp
-	Line string
+	Op   LineOp `json:"op"`
+	Line string `json:"line"`
 }
 
 func (fl Line) String() string {
@@ -196,9 +462,9 @@ func (op LineOp) String() string {
 
 // BinaryFragment describes changes to a binary file.
 type BinaryFragment struct {
-	Method BinaryPatchMethod
-	Size   int64
-	Data   []byte
+	Method BinaryPatchMethod `json:"method"`
+	Size   int64             `json:"size"`
+	Data   []byte            `json:"data"`
 }
 
 // BinaryPatchMethod is the method used to create and apply the binary patch.
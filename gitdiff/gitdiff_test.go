@@ -1,6 +1,9 @@
 package gitdiff
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -159,3 +162,220 @@ func TestTextFragmentValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestTextFragmentReverse(t *testing.T) {
+	frag := &TextFragment{
+		Comment:         "section",
+		OldPosition:     3,
+		OldLines:        5,
+		NewPosition:     3,
+		NewLines:        5,
+		LinesAdded:      2,
+		LinesDeleted:    2,
+		LeadingContext:  1,
+		TrailingContext: 1,
+		Lines: []Line{
+			{OpContext, "line 3\n"},
+			{OpDelete, "old line 4\n"},
+			{OpAdd, "new line 4\n"},
+			{OpContext, "line 5\n"},
+			{OpDelete, "old line 6\n"},
+			{OpAdd, "new line 6\n"},
+			{OpContext, "line 7\n"},
+		},
+	}
+
+	expected := &TextFragment{
+		Comment:         "section",
+		OldPosition:     3,
+		OldLines:        5,
+		NewPosition:     3,
+		NewLines:        5,
+		LinesAdded:      2,
+		LinesDeleted:    2,
+		LeadingContext:  1,
+		TrailingContext: 1,
+		Lines: []Line{
+			{OpContext, "line 3\n"},
+			{OpDelete, "new line 4\n"},
+			{OpAdd, "old line 4\n"},
+			{OpContext, "line 5\n"},
+			{OpDelete, "new line 6\n"},
+			{OpAdd, "old line 6\n"},
+			{OpContext, "line 7\n"},
+		},
+	}
+
+	reversed := frag.Reverse()
+	if !reflect.DeepEqual(expected, reversed) {
+		t.Fatalf("incorrect reversed fragment\nexpected: %+v\n  actual: %+v", expected, reversed)
+	}
+	if err := reversed.Validate(); err != nil {
+		t.Fatalf("reversed fragment is invalid: %v", err)
+	}
+	if !reflect.DeepEqual(frag, reversed.Reverse()) {
+		t.Fatalf("reversing twice did not produce the original fragment\nexpected: %+v\n  actual: %+v", frag, reversed.Reverse())
+	}
+}
+
+func TestFileOperation(t *testing.T) {
+	tests := map[string]struct {
+		File *File
+		Op   FileOperation
+	}{
+		"modify": {
+			File: &File{OldName: "file.txt", NewName: "file.txt"},
+			Op:   FileModify,
+		},
+		"create": {
+			File: &File{NewName: "file.txt", IsNew: true},
+			Op:   FileCreate,
+		},
+		"delete": {
+			File: &File{OldName: "file.txt", IsDelete: true},
+			Op:   FileDelete,
+		},
+		"rename": {
+			File: &File{OldName: "old.txt", NewName: "new.txt", IsRename: true},
+			Op:   FileRename,
+		},
+		"copy": {
+			File: &File{OldName: "old.txt", NewName: "new.txt", IsCopy: true},
+			Op:   FileCopy,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if op := test.File.Operation(); op != test.Op {
+				t.Errorf("incorrect operation: expected %v, actual %v", test.Op, op)
+			}
+		})
+	}
+}
+
+func TestFileChangePredicates(t *testing.T) {
+	const patch = `diff --git a/empty.txt b/empty.txt
+new file mode 100644
+index 0000000..e69de29
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index e69de29..0000000
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git a/edit.sh b/edit.sh
+old mode 100644
+new mode 100755
+index 366226f..7be68db
+--- a/edit.sh
++++ b/edit.sh
+@@ -1 +1,2 @@
+ edit
++more
+diff --git a/old.txt b/new.txt
+similarity index 100%
+rename from old.txt
+rename to new.txt
+diff --git a/old.sh b/new.sh
+old mode 100644
+new mode 100755
+similarity index 100%
+rename from old.sh
+rename to new.sh
+diff --git a/created.txt b/created.txt
+new file mode 100644
+index 0000000..7898192
+--- /dev/null
++++ b/created.txt
+@@ -0,0 +1 @@
++a
+diff --git a/bin.dat b/bin.dat
+deleted file mode 100644
+index 7898192..0000000
+Binary files a/bin.dat and /dev/null differ
+`
+
+	type predicates struct {
+		ModeOnly, EmptyNew, EmptyDeleted, RenameOnly bool
+	}
+	tests := map[string]predicates{
+		"empty.txt":   {EmptyNew: true},
+		"gone.txt":    {EmptyDeleted: true},
+		"run.sh":      {ModeOnly: true},
+		"edit.sh":     {},
+		"new.txt":     {RenameOnly: true},
+		"new.sh":      {},
+		"created.txt": {},
+		"bin.dat":     {},
+	}
+
+	for _, lazy := range []bool{false, true} {
+		var opts []ParseOption
+		if lazy {
+			opts = append(opts, WithLazyFragments())
+		}
+		files, _, err := ParseAll(strings.NewReader(patch), opts...)
+		if err != nil {
+			t.Fatalf("unexpected error parsing patch: %v", err)
+		}
+		if len(files) != len(tests) {
+			t.Fatalf("expected %d files, but got %d", len(tests), len(files))
+		}
+
+		for _, f := range files {
+			name := f.NewName
+			if name == "" {
+				name = f.OldName
+			}
+			actual := predicates{
+				ModeOnly:     f.IsModeOnlyChange(),
+				EmptyNew:     f.IsEmptyNewFile(),
+				EmptyDeleted: f.IsEmptyDeletedFile(),
+				RenameOnly:   f.IsRenameOnly(),
+			}
+			if expected := tests[name]; actual != expected {
+				t.Errorf("%s (lazy=%t): incorrect predicates\nexpected: %+v\n  actual: %+v", name, lazy, expected, actual)
+			}
+		}
+	}
+}
+
+func TestMissingNewline(t *testing.T) {
+	tests := map[string]struct {
+		Old, New bool
+	}{
+		"text_fragment_add_end_noeol":       {Old: true},
+		"text_fragment_add_eol":             {Old: true},
+		"text_fragment_remove_end_eol":      {New: true},
+		"text_fragment_context_noeol":       {Old: true, New: true},
+		"text_fragment_change_single_noeol": {Old: true, New: true},
+		"text_fragment_change_middle":       {},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			patch, err := os.Open(filepath.Join("testdata", "apply", name+".patch"))
+			if err != nil {
+				t.Fatalf("unexpected error opening patch: %v", err)
+			}
+			defer patch.Close()
+
+			files, _, err := ParseAll(patch)
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			f := files[0]
+			if f.OldMissingNewline() != test.Old || f.NewMissingNewline() != test.New {
+				t.Errorf("incorrect missing newlines: expected old %t, new %t; actual old %t, new %t",
+					test.Old, test.New, f.OldMissingNewline(), f.NewMissingNewline())
+			}
+
+			frag := f.TextFragments[len(f.TextFragments)-1]
+			if frag.OldMissingNewline() != test.Old || frag.NewMissingNewline() != test.New {
+				t.Errorf("incorrect missing newlines for fragment: old %t, new %t", frag.OldMissingNewline(), frag.NewMissingNewline())
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package gitdiff
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
+)
+
+// HashAlgorithm is the hash function a repository uses to compute object IDs.
+type HashAlgorithm int
+
+const (
+	// HashUnknown means the algorithm is not known, usually because a patch
+	// only contains abbreviated object IDs.
+	HashUnknown HashAlgorithm = iota
+	// HashSHA1 is the SHA-1 algorithm used by most repositories.
+	HashSHA1
+	// HashSHA256 is the SHA-256 algorithm used by repositories created with
+	// "git init --object-format=sha256".
+	HashSHA256
+)
+
+func (h HashAlgorithm) String() string {
+	switch h {
+	case HashSHA1:
+		return "sha1"
+	case HashSHA256:
+		return "sha256"
+	}
+	return "unknown"
+}
+
+// HexSize returns the number of hexadecimal characters in a full object ID
+// computed with the algorithm, or 0 if the algorithm is unknown.
+func (h HashAlgorithm) HexSize() int {
+	switch h {
+	case HashSHA1:
+		return 2 * sha1.Size
+	case HashSHA256:
+		return 2 * sha256.Size
+	}
+	return 0
+}
+
+// MarshalText encodes the algorithm as "sha1" or "sha256".
+func (h HashAlgorithm) MarshalText() ([]byte, error) {
+	switch h {
+	case HashSHA1, HashSHA256:
+		return []byte(h.String()), nil
+	}
+	return nil, fmt.Errorf("gitdiff: invalid hash algorithm: %d", h)
+}
+
+// UnmarshalText decodes an algorithm encoded by MarshalText.
+func (h *HashAlgorithm) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "sha1":
+		*h = HashSHA1
+	case "sha256":
+		*h = HashSHA256
+	default:
+		return fmt.Errorf("gitdiff: invalid hash algorithm: %q", text)
+	}
+	return nil
+}
+
+// detectHashAlgorithm returns the algorithm of the full object IDs in oids. A
+// full SHA-256 ID takes precedence over an ID with the length of a SHA-1 ID,
+// which may be an abbreviated SHA-256 ID. It returns HashUnknown if all of
+// the IDs are abbreviated.
+func detectHashAlgorithm(oids ...string) HashAlgorithm {
+	alg := HashUnknown
+	for _, oid := range oids {
+		switch len(oid) {
+		case HashSHA256.HexSize():
+			return HashSHA256
+		case HashSHA1.HexSize():
+			alg = HashSHA1
+		}
+	}
+	return alg
+}
+
+// validateOID checks that oid is a possibly abbreviated object ID for the
+// algorithm. If the algorithm is unknown, oid may have the length of any
+// supported algorithm.
+func validateOID(oid string, alg HashAlgorithm) error {
+	size := alg.HexSize()
+	if size == 0 {
+		size = HashSHA256.HexSize()
+	}
+	if len(oid) > size {
+		return fmt.Errorf("object ID is too long: %s", oid)
+	}
+	for i, c := range oid {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return atColumn(fmt.Errorf("invalid object ID: %s", oid), i)
+		}
+	}
+	return nil
+}
+
+// setHashAlgorithm sets the algorithm of f to the algorithm detected from its
+// object IDs if the algorithm is unknown. The IDs must be valid.
+func setHashAlgorithm(f *File) {
+	if f.HashAlgorithm == HashUnknown {
+		oids := append([]string{f.OldOIDPrefix, f.NewOIDPrefix}, f.ParentOIDPrefixes...)
+		f.HashAlgorithm = detectHashAlgorithm(oids...)
+	}
+}
+
+// blobOID returns the object ID of a Git blob with the given content. It uses
+// SHA-1 if the algorithm is unknown.
+func blobOID(alg HashAlgorithm, data []byte) string {
+	var h hash.Hash
+	if alg == HashSHA256 {
+		h = sha256.New()
+	} else {
+		h = sha1.New()
+	}
+	h.Write([]byte("blob " + strconv.Itoa(len(data)) + "\x00"))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
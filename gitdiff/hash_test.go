@@ -0,0 +1,131 @@
+package gitdiff
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseHashAlgorithm(t *testing.T) {
+	tests := map[string]struct {
+		Options []ParseOption
+		Files   []HashAlgorithm
+		Err     interface{}
+	}{
+		"detect": {
+			Files: []HashAlgorithm{HashSHA256, HashUnknown},
+		},
+		"sha256": {
+			Options: []ParseOption{WithHashAlgorithm(HashSHA256)},
+			Files:   []HashAlgorithm{HashSHA256, HashSHA256},
+		},
+		"sha1": {
+			Options: []ParseOption{WithHashAlgorithm(HashSHA1)},
+			Err:     "object ID is too long",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open("testdata/sha256.patch")
+			if err != nil {
+				t.Fatalf("unexpected error opening patch: %v", err)
+			}
+			defer f.Close()
+
+			files, preamble, err := ParseAll(f, test.Options...)
+			if test.Err != nil {
+				assertError(t, test.Err, err, "parsing patch")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			if len(files) != len(test.Files) {
+				t.Fatalf("incorrect number of files: expected %d, actual %d", len(test.Files), len(files))
+			}
+			for i, f := range files {
+				if f.HashAlgorithm != test.Files[i] {
+					t.Errorf("file %d: incorrect hash algorithm: expected %v, actual %v", i, test.Files[i], f.HashAlgorithm)
+				}
+			}
+
+			h, err := ParsePatchHeader(preamble)
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch header: %v", err)
+			}
+			if h.HashAlgorithm != HashSHA256 {
+				t.Errorf("incorrect header hash algorithm: expected %v, actual %v", HashSHA256, h.HashAlgorithm)
+			}
+		})
+	}
+}
+
+func TestParseInvalidOID(t *testing.T) {
+	const patch = `diff --git a/f.txt b/f.txt
+index 55531ax..d6dc9d4 100644
+--- a/f.txt
++++ b/f.txt
+@@ -1 +1 @@
+-a
++b
+`
+	_, _, err := ParseAll(strings.NewReader(patch))
+	assertError(t, "invalid object ID", err, "parsing patch")
+}
+
+func TestApplyOIDCheckSHA256(t *testing.T) {
+	f, err := os.Open("testdata/sha256.patch")
+	if err != nil {
+		t.Fatalf("unexpected error opening patch: %v", err)
+	}
+	defer f.Close()
+
+	files, _, err := ParseAll(f, WithHashAlgorithm(HashSHA256))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	var out bytes.Buffer
+	opts := []ApplierOption{WithOIDCheck(), WithNewOIDCheck()}
+	if err := Apply(&out, bytes.NewReader(nil), files[0], opts...); err != nil {
+		t.Fatalf("unexpected error applying binary file: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), []byte{0, 1, 2}) {
+		t.Errorf("incorrect binary result: %q", out.Bytes())
+	}
+
+	out.Reset()
+	if err := Apply(&out, strings.NewReader("a\nb\n"), files[1], opts...); err != nil {
+		t.Fatalf("unexpected error applying text file: %v", err)
+	}
+	if out.String() != "a\nB\n" {
+		t.Errorf("incorrect text result: %q", out.String())
+	}
+
+	err = Apply(&out, strings.NewReader("a\nb\n"), files[1], WithOIDCheck(), WithReverse())
+	assertError(t, &Conflict{}, err, "applying text file with incorrect source")
+}
+
+func TestHashAlgorithmText(t *testing.T) {
+	for _, alg := range []HashAlgorithm{HashSHA1, HashSHA256} {
+		text, err := alg.MarshalText()
+		if err != nil {
+			t.Fatalf("unexpected error marshaling %v: %v", alg, err)
+		}
+
+		var decoded HashAlgorithm
+		if err := decoded.UnmarshalText(text); err != nil {
+			t.Fatalf("unexpected error unmarshaling %q: %v", text, err)
+		}
+		if decoded != alg {
+			t.Errorf("incorrect algorithm after round trip: expected %v, actual %v", alg, decoded)
+		}
+	}
+
+	if _, err := HashUnknown.MarshalText(); err == nil {
+		t.Errorf("expected error marshaling unknown algorithm, but got nil")
+	}
+}
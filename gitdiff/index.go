@@ -0,0 +1,99 @@
+package gitdiff
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// Index is an index of the files in a patch that supports random access. It
+// records where each file is in the patch so that File can parse one file
+// without reading the patch from the start.
+type Index struct {
+	r       io.ReaderAt
+	opts    []ParseOption
+	entries []IndexEntry
+}
+
+// IndexEntry describes the location of a file in a patch.
+type IndexEntry struct {
+	OldName string
+	NewName string
+
+	// Offset is the byte offset of the first line of the file header and
+	// Size is the number of bytes of the header and the fragments
+	Offset int64
+	Size   int64
+
+	// the commit message or email that precedes the file, if any
+	headerOffset int64
+	headerSize   int64
+}
+
+// NewIndex reads the patch in the first size bytes of r and returns an index
+// of its files. It reads the whole patch, but does not parse the content of
+// any fragments. NewIndex accepts the same options as Parse, and File uses
+// them to parse each file. Files skipped because of WithIncludePaths or
+// WithExcludePaths are not in the index.
+//
+// NewIndex returns an error if parsing the patch fails. If the patch has
+// invalid fragments, NewIndex may succeed and File returns the error.
+func NewIndex(r io.ReaderAt, size int64, opts ...ParseOption) (*Index, error) {
+	x := &Index{r: r, opts: opts}
+
+	p := newParser(io.NewSectionReader(r, 0, size), opts...)
+	p.lazy, p.indexOnly = true, true
+
+	_, err := p.ParseFiles(func(f *File) error {
+		x.entries = append(x.entries, IndexEntry{
+			OldName:      f.OldName,
+			NewName:      f.NewName,
+			Offset:       p.fileOffset,
+			Size:         p.offset() - p.fileOffset,
+			headerOffset: p.headerOffset,
+			headerSize:   p.headerSize,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Entries returns the entries of the index in the order of the files in the
+// patch.
+func (x *Index) Entries() []IndexEntry {
+	return x.entries
+}
+
+// File parses the first file in the patch with the given old or new name. It
+// returns an error that wraps fs.ErrNotExist if no file has the name.
+func (x *Index) File(name string) (*File, error) {
+	for i, e := range x.entries {
+		if e.NewName == name || e.OldName == name {
+			return x.FileAt(i)
+		}
+	}
+	return nil, fmt.Errorf("gitdiff: index: %s: %w", name, fs.ErrNotExist)
+}
+
+// FileAt parses the file at index i in the patch. Line numbers in parse
+// errors are relative to the start of the file header, or the preceding
+// commit message if the file has one.
+func (x *Index) FileAt(i int) (*File, error) {
+	e := x.entries[i]
+	r := io.MultiReader(
+		io.NewSectionReader(x.r, e.headerOffset, e.headerSize),
+		io.NewSectionReader(x.r, e.Offset, e.Size),
+	)
+
+	files, _, err := ParseAll(r, x.opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("gitdiff: index: expected 1 file at offset %d, but parsed %d", e.Offset, len(files))
+	}
+	return files[0], nil
+}
@@ -0,0 +1,82 @@
+package gitdiff
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	for _, name := range []string{
+		"one_file.patch",
+		"two_files.patch",
+		"mailbox.patch",
+		"new_binary_file.patch",
+		"context_diff.patch",
+		"submodule.patch",
+		"apply_fs.patch",
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatalf("failed to read patch: %v", err)
+			}
+
+			expected, _, err := ParseAll(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			x, err := NewIndex(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("unexpected error indexing patch: %v", err)
+			}
+
+			entries := x.Entries()
+			if len(entries) != len(expected) {
+				t.Fatalf("incorrect number of entries: expected %d, actual %d", len(expected), len(entries))
+			}
+			for i, e := range entries {
+				if e.OldName != expected[i].OldName || e.NewName != expected[i].NewName {
+					t.Errorf("incorrect names for entry %d: %q, %q", i, e.OldName, e.NewName)
+				}
+
+				f, err := x.FileAt(i)
+				if err != nil {
+					t.Fatalf("unexpected error parsing file %d: %v", i, err)
+				}
+				if !reflect.DeepEqual(expected[i], f) {
+					t.Errorf("incorrect file %d\nexpected: %+v\n  actual: %+v", i, expected[i], f)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexFile(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "two_files.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+
+	x, err := NewIndex(bytes.NewReader(data), int64(len(data)), WithStripComponents(2))
+	if err != nil {
+		t.Fatalf("unexpected error indexing patch: %v", err)
+	}
+
+	f, err := x.File("file2.txt")
+	if err != nil {
+		t.Fatalf("unexpected error finding file: %v", err)
+	}
+	if f.NewName != "file2.txt" || len(f.TextFragments) == 0 {
+		t.Errorf("incorrect file: %+v", f)
+	}
+
+	if _, err := x.File("dir/file2.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not exist error for missing file, but got %v", err)
+	}
+}
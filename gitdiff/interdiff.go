@@ -0,0 +1,301 @@
+package gitdiff
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// gapLine is the prefix of placeholder lines for the parts of a file that are
+// not included in a patch. Text files never contain NUL bytes, so placeholders
+// can't match real lines.
+const gapLine = "\x00gitdiff gap "
+
+// Interdiff computes the changes between two versions of a patch, like the
+// "interdiff" tool, and returns files that transform the result of applying a
+// into the result of applying b. Use it to review the changes between two
+// versions of a patch series.
+//
+// Both patches must apply to the same original content. Files are matched by
+// their original name, or by their new name for new and copied files. Files
+// changed in only one of the patches are reverted or changed as in that
+// patch. For files changed by both patches, Interdiff reconstructs the parts
+// of the original content included in the fragments of either patch and
+// compares the results of applying each patch to them, so the result only
+// contains context lines that appear in the patches.
+//
+// The result contains the files of a followed by the files only in b, in
+// order. Files with the same content after both patches are omitted.
+// Interdiff returns an error if the patches disagree about the original
+// content of a file or if a file changed by both patches is binary and the
+// results differ.
+func Interdiff(a, b []*File) ([]*File, error) {
+	a, err := loadedFiles(a)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = loadedFiles(b); err != nil {
+		return nil, err
+	}
+
+	aFiles, err := interdiffIndex(a)
+	if err != nil {
+		return nil, err
+	}
+	bFiles, err := interdiffIndex(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*File
+	for _, fa := range a {
+		fb, ok := bFiles[interdiffKey(fa)]
+		if !ok {
+			files = append(files, fa.Reverse())
+			continue
+		}
+
+		f, err := interdiffFile(fa, fb)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+	for _, fb := range b {
+		if _, ok := aFiles[interdiffKey(fb)]; !ok {
+			files = append(files, fb)
+		}
+	}
+	return files, nil
+}
+
+// interdiffKey returns the name that identifies the original file changed by
+// f. New and copied files are identified by their new name.
+func interdiffKey(f *File) string {
+	if f.IsNew || f.IsCopy {
+		return f.NewName
+	}
+	return f.OldName
+}
+
+func interdiffIndex(files []*File) (map[string]*File, error) {
+	index := make(map[string]*File, len(files))
+	for _, f := range files {
+		key := interdiffKey(f)
+		if _, ok := index[key]; ok {
+			return nil, fmt.Errorf("gitdiff: interdiff: %s: file appears more than once in patch", key)
+		}
+		index[key] = f
+	}
+	return index, nil
+}
+
+// interdiffFile returns a file that transforms the result of fa into the
+// result of fb, or nil if the results are the same.
+func interdiffFile(fa, fb *File) (*File, error) {
+	name := interdiffKey(fa)
+	if fa.IsNew != fb.IsNew {
+		return nil, fmt.Errorf("gitdiff: interdiff: %s: patches have different original files", name)
+	}
+	if fa.IsDelete && fb.IsDelete {
+		return nil, nil
+	}
+
+	f := &File{
+		OldName:       fa.NewName,
+		NewName:       fb.NewName,
+		OldMode:       resultMode(fa),
+		NewMode:       resultMode(fb),
+		OldOIDPrefix:  fa.NewOIDPrefix,
+		NewOIDPrefix:  fb.NewOIDPrefix,
+		HashAlgorithm: fb.HashAlgorithm,
+		IsNew:         fa.IsDelete,
+		IsDelete:      fb.IsDelete,
+	}
+	if f.IsNew {
+		f.OldName = ""
+	}
+	if f.IsDelete {
+		f.NewName = ""
+	}
+	f.IsRename = !f.IsNew && !f.IsDelete && f.OldName != f.NewName
+
+	if fa.IsBinary || fb.IsBinary {
+		if fa.IsBinary != fb.IsBinary || fa.NewOIDPrefix == "" || fa.NewOIDPrefix != fb.NewOIDPrefix {
+			return nil, fmt.Errorf("gitdiff: interdiff: %s: cannot compare binary changes", name)
+		}
+		if f.IsNew || f.IsDelete || f.IsRename || f.OldMode != f.NewMode {
+			f.IsBinary = true
+			return f, nil
+		}
+		return nil, nil
+	}
+
+	base, err := interdiffBase(name, fa, fb)
+	if err != nil {
+		return nil, err
+	}
+
+	var outA, outB bytes.Buffer
+	src := strings.Join(base, "")
+	if err := Apply(&outA, strings.NewReader(src), fa); err != nil {
+		return nil, fmt.Errorf("gitdiff: interdiff: %s: %w", name, err)
+	}
+	if err := Apply(&outB, strings.NewReader(src), fb); err != nil {
+		return nil, fmt.Errorf("gitdiff: interdiff: %s: %w", name, err)
+	}
+
+	if f.TextFragments, err = partialFragments(outA.String(), outB.String()); err != nil {
+		return nil, fmt.Errorf("gitdiff: interdiff: %s: %v", name, err)
+	}
+	if isEmptyChange(f) {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// interdiffBase reconstructs the original content of a file from the old
+// lines of the fragments in fa and fb. Lines that are not in either patch are
+// replaced by unique placeholders.
+func interdiffBase(name string, fa, fb *File) ([]string, error) {
+	known := make(map[int64]string)
+	var size int64
+	for _, f := range []*File{fa, fb} {
+		for _, frag := range f.TextFragments {
+			i := fragmentStart(frag.OldPosition, frag.OldLines) - 1
+			for _, line := range frag.Lines {
+				if !line.Old() {
+					continue
+				}
+				if prev, ok := known[i]; ok && prev != line.Line {
+					return nil, fmt.Errorf("gitdiff: interdiff: %s: patches have different content for line %d", name, i+1)
+				}
+				known[i] = line.Line
+				i++
+			}
+			if i > size {
+				size = i
+			}
+		}
+	}
+
+	return partialContent(known, size), nil
+}
+
+// partialContent returns the first size lines of a file with the known lines
+// and unique placeholders for the other lines.
+func partialContent(known map[int64]string, size int64) []string {
+	lines := make([]string, size)
+	for i := range lines {
+		line, ok := known[int64(i)]
+		if !ok {
+			line = gapLine + strconv.Itoa(i) + "\n"
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// partialFragments returns the text fragments for the changes between old
+// and new, which may contain placeholders from partialContent. The fragments
+// only include context lines that are not placeholders. It returns an error
+// if a placeholder line changes.
+func partialFragments(old, new string) ([]*TextFragment, error) {
+	oldLines, newLines := splitLines(old), splitLines(new)
+	hunks, err := partialHunks(oldLines, newLines)
+	if err != nil || len(hunks) == 0 {
+		return nil, err
+	}
+
+	slidePartialHunks(oldLines, newLines, hunks)
+
+	d := differ{context: len(oldLines)}
+	return recontext(d.fragments(oldLines, newLines, hunks), defaultDiffContext, func(i int64) (string, bool) {
+		if i < 0 || i >= int64(len(oldLines)) || strings.HasPrefix(oldLines[i], gapLine) {
+			return "", false
+		}
+		return oldLines[i], true
+	}), nil
+}
+
+// partialHunks returns the changes between oldLines and newLines. The
+// placeholders are unique and the patches do not change them, so they are
+// fixed anchors: partialHunks diffs the lines between each pair of matching
+// placeholders separately, so that the diff can not align a changed line
+// with a placeholder. It returns an error if the placeholders do not match.
+func partialHunks(oldLines, newLines []string) ([]diffHunk, error) {
+	nextGap := func(lines []string, i int) int {
+		for i < len(lines) && !strings.HasPrefix(lines[i], gapLine) {
+			i++
+		}
+		return i
+	}
+
+	var hunks []diffHunk
+	for a, b := 0, 0; ; {
+		aEnd, bEnd := nextGap(oldLines, a), nextGap(newLines, b)
+		for _, h := range diffLines(oldLines[a:aEnd], newLines[b:bEnd]) {
+			hunks = append(hunks, diffHunk{
+				AStart: a + h.AStart, AEnd: a + h.AEnd,
+				BStart: b + h.BStart, BEnd: b + h.BEnd,
+			})
+		}
+		if aEnd == len(oldLines) && bEnd == len(newLines) {
+			return hunks, nil
+		}
+		if aEnd == len(oldLines) || bEnd == len(newLines) || oldLines[aEnd] != newLines[bEnd] {
+			return nil, fmt.Errorf("changes overlap content that is not in the patches")
+		}
+		a, b = aEnd+1, bEnd+1
+	}
+}
+
+// slidePartialHunks moves hunks that end right before a placeholder or at
+// the end of old to earlier positions with the same effect, so that their
+// fragments have trailing context. Without trailing context, a fragment must
+// apply at the end of the file, but the reconstructed content usually ends
+// before the file does. A hunk can move up by one line if the line before it
+// is the same as the last line it deletes and the last line it adds.
+func slidePartialHunks(oldLines, newLines []string, hunks []diffHunk) {
+	available := func(i int) bool {
+		return i < len(oldLines) && !strings.HasPrefix(oldLines[i], gapLine)
+	}
+
+	prevEnd := 0
+	for i := range hunks {
+		h := &hunks[i]
+		for !available(h.AEnd) && h.AStart > prevEnd && h.BStart > 0 {
+			before := oldLines[h.AStart-1]
+			if h.AEnd > h.AStart && oldLines[h.AEnd-1] != before {
+				break
+			}
+			if h.BEnd > h.BStart && newLines[h.BEnd-1] != before {
+				break
+			}
+			h.AStart, h.AEnd = h.AStart-1, h.AEnd-1
+			h.BStart, h.BEnd = h.BStart-1, h.BEnd-1
+		}
+		prevEnd = h.AEnd
+	}
+}
+
+// isEmptyChange returns true if f does not change the file.
+func isEmptyChange(f *File) bool {
+	return !f.IsNew && !f.IsDelete && f.OldName == f.NewName && f.OldMode == f.NewMode &&
+		!f.IsBinary && len(f.TextFragments) == 0
+}
+
+// resultMode returns the mode of the file after applying f.
+func resultMode(f *File) os.FileMode {
+	switch {
+	case f.IsDelete:
+		return 0
+	case f.NewMode != 0:
+		return f.NewMode
+	}
+	return f.OldMode
+}
@@ -0,0 +1,191 @@
+package gitdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestInterdiff(t *testing.T) {
+	base := map[string][]byte{
+		"drop.txt": []byte("keep\n"),
+		"mode.sh":  []byte("a\nb\n"),
+		"nums.txt": []byte(numberLines(1, 30)),
+	}
+
+	a := parseTestPatch(t, "interdiff_v1.patch")
+	b := parseTestPatch(t, "interdiff_v2.patch")
+
+	files, err := Interdiff(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error computing interdiff: %v", err)
+	}
+
+	var out strings.Builder
+	for _, f := range files {
+		out.WriteString(f.String())
+	}
+	expected, err := os.ReadFile(filepath.Join("testdata", "interdiff.patch"))
+	if err != nil {
+		t.Fatalf("failed to read expected patch: %v", err)
+	}
+	if out.String() != string(expected) {
+		t.Errorf("incorrect interdiff\nexpected:\n%s\nactual:\n%s", expected, out.String())
+	}
+
+	resultA, err := ApplyToMap(base, a)
+	if err != nil {
+		t.Fatalf("unexpected error applying first patch: %v", err)
+	}
+	resultB, err := ApplyToMap(base, b)
+	if err != nil {
+		t.Fatalf("unexpected error applying second patch: %v", err)
+	}
+	actual, err := ApplyToMap(resultA, files)
+	if err != nil {
+		t.Fatalf("unexpected error applying interdiff: %v", err)
+	}
+	if !reflect.DeepEqual(resultB, actual) {
+		t.Errorf("applying interdiff does not produce the result of the second patch")
+	}
+
+	same, err := Interdiff(a, a)
+	if err != nil {
+		t.Fatalf("unexpected error computing interdiff of the same patch: %v", err)
+	}
+	if len(same) > 0 {
+		t.Errorf("expected no files for the same patch, but got %d", len(same))
+	}
+}
+
+func TestInterdiffErrors(t *testing.T) {
+	tests := map[string]struct {
+		A, B string
+		Err  string
+	}{
+		"differentBase": {
+			A:   "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			B:   "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-x\n+c\n",
+			Err: "different content for line 2",
+		},
+		"newAndModified": {
+			A:   "diff --git a/f b/f\nnew file mode 100644\n--- /dev/null\n+++ b/f\n@@ -0,0 +1 @@\n+a\n",
+			B:   "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n",
+			Err: "different original files",
+		},
+		"duplicateFile": {
+			A:   "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n",
+			B:   "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\ndiff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-b\n+c\n",
+			Err: "more than once",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a, _, err := ParseAll(strings.NewReader(test.A))
+			if err != nil {
+				t.Fatalf("unexpected error parsing first patch: %v", err)
+			}
+			b, _, err := ParseAll(strings.NewReader(test.B))
+			if err != nil {
+				t.Fatalf("unexpected error parsing second patch: %v", err)
+			}
+
+			_, err = Interdiff(a, b)
+			assertError(t, test.Err, err, "computing interdiff")
+		})
+	}
+}
+
+func TestInterdiffApply(t *testing.T) {
+	// a pair of patches where diffing across the placeholders aligns a
+	// changed line with one
+	orig := "a\nd\nb\nb\nb\nb\nb\nc\nc\ne\nb\ne\na\nb\nc\nd\na\nb\na\nd\nc\nb\nb\nb\nb\nb\n"
+	a := "a\nd\nb\nb\nb\nb\nc\nc\ne\nb\nd\ne\na\nc\nd\na\nb\na\nd\nc\nb\nb\nb\ne\nb\n"
+	b := "e\na\nd\nb\nb\nb\nb\nc\nc\ne\ne\na\nb\nb\nd\na\nb\na\nd\nc\nb\nb\nb\na\nb\nb\n"
+	assertInterdiffApply(t, orig, a, b, 1, 1)
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		orig := randomLines(r, 1+r.Intn(40))
+		a, b := randomEdit(r, orig), randomEdit(r, orig)
+		assertInterdiffApply(t, orig, a, b, 1+r.Intn(3), 1+r.Intn(3))
+	}
+}
+
+// assertInterdiffApply checks that the interdiff of the patches from orig to
+// a and from orig to b changes a into b.
+func assertInterdiffApply(t *testing.T, orig, a, b string, contextA, contextB int) {
+	fa := mustDiff(t, orig, a, WithContext(contextA))
+	fb := mustDiff(t, orig, b, WithContext(contextB))
+	if len(fa.TextFragments) == 0 || len(fb.TextFragments) == 0 {
+		return
+	}
+
+	files, err := Interdiff([]*File{fa}, []*File{fb})
+	if err != nil {
+		t.Fatalf("unexpected error computing interdiff: %v\norig: %q\na: %q\nb: %q", err, orig, a, b)
+	}
+	if len(files) == 0 {
+		if a != b {
+			t.Fatalf("expected interdiff for different results\norig: %q\na: %q\nb: %q", orig, a, b)
+		}
+		return
+	}
+
+	var out bytes.Buffer
+	if err := Apply(&out, strings.NewReader(a), files[0]); err != nil {
+		t.Fatalf("unexpected error applying interdiff: %v\na: %q\nb: %q\ndiff:\n%s", err, a, b, files[0])
+	}
+	if out.String() != b {
+		t.Fatalf("incorrect result after apply\nexpected: %q\n  actual: %q\ndiff:\n%s", b, out.String(), files[0])
+	}
+}
+
+func numberLines(start, end int) string {
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		b.WriteString(strconv.Itoa(i) + "\n")
+	}
+	return b.String()
+}
+
+// randomLines returns n random lines from a small set, so that the lines
+// repeat often and diffs have ambiguous alignments.
+func randomLines(r *rand.Rand, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString("l" + strconv.Itoa(r.Intn(4)) + "\n")
+	}
+	return b.String()
+}
+
+// randomEdit returns s with a few random lines deleted, inserted, or replaced.
+func randomEdit(r *rand.Rand, s string) string {
+	var b strings.Builder
+	for _, line := range splitLines(s) {
+		switch r.Intn(14) {
+		case 0:
+		case 1:
+			b.WriteString(randomLines(r, 1) + line)
+		case 2:
+			b.WriteString(randomLines(r, 1))
+		default:
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+func mustDiff(t *testing.T, old, new string, opts ...DiffOption) *File {
+	f, err := Diff(strings.NewReader(old), strings.NewReader(new), append([]DiffOption{WithNames("f", "f")}, opts...)...)
+	if err != nil {
+		t.Fatalf("unexpected error computing diff: %v", err)
+	}
+	return f
+}
@@ -0,0 +1,195 @@
+package gitdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// MarshalJSON encodes the file as a JSON object with the same content as the
+// struct fields, except that modes are strings with octal values, like
+// "100644". Times use the RFC 3339 format and are omitted if they are the
+// zero time.
+func (f File) MarshalJSON() ([]byte, error) {
+	type file File
+
+	// f is a copy, so loading does not modify the original file
+	if err := f.LoadFragments(); err != nil {
+		return nil, err
+	}
+
+	parentModes := make([]jsonMode, len(f.ParentModes))
+	for i, mode := range f.ParentModes {
+		parentModes[i] = jsonMode(mode)
+	}
+	if len(parentModes) == 0 {
+		parentModes = nil
+	}
+
+	return json.Marshal(struct {
+		file
+		OldMode     jsonMode   `json:"old_mode,omitempty"`
+		NewMode     jsonMode   `json:"new_mode,omitempty"`
+		ParentModes []jsonMode `json:"parent_modes,omitempty"`
+		OldTime     *time.Time `json:"old_time,omitempty"`
+		NewTime     *time.Time `json:"new_time,omitempty"`
+	}{
+		file:        file(f),
+		OldMode:     jsonMode(f.OldMode),
+		NewMode:     jsonMode(f.NewMode),
+		ParentModes: parentModes,
+		OldTime:     timeOrNil(f.OldTime),
+		NewTime:     timeOrNil(f.NewTime),
+	})
+}
+
+// UnmarshalJSON decodes a file encoded by MarshalJSON.
+func (f *File) UnmarshalJSON(data []byte) error {
+	type file File
+
+	var v struct {
+		*file
+		OldMode     jsonMode   `json:"old_mode"`
+		NewMode     jsonMode   `json:"new_mode"`
+		ParentModes []jsonMode `json:"parent_modes"`
+		OldTime     *time.Time `json:"old_time"`
+		NewTime     *time.Time `json:"new_time"`
+	}
+	v.file = (*file)(f)
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	f.OldMode = os.FileMode(v.OldMode)
+	f.NewMode = os.FileMode(v.NewMode)
+	f.ParentModes = nil
+	for _, mode := range v.ParentModes {
+		f.ParentModes = append(f.ParentModes, os.FileMode(mode))
+	}
+
+	f.OldTime, f.NewTime = time.Time{}, time.Time{}
+	if v.OldTime != nil {
+		f.OldTime = *v.OldTime
+	}
+	if v.NewTime != nil {
+		f.NewTime = *v.NewTime
+	}
+	return nil
+}
+
+// jsonMode encodes a file mode as a string with an octal value.
+type jsonMode os.FileMode
+
+func (m jsonMode) MarshalText() ([]byte, error) {
+	return strconv.AppendUint(nil, uint64(m), 8), nil
+}
+
+func (m *jsonMode) UnmarshalText(text []byte) error {
+	mode, err := strconv.ParseUint(string(text), 8, 32)
+	if err != nil {
+		return fmt.Errorf("gitdiff: invalid mode: %q", text)
+	}
+	*m = jsonMode(mode)
+	return nil
+}
+
+// MarshalJSON encodes the header as a JSON object with the same content as the
+// struct fields. Dates use the RFC 3339 format and are omitted if they are the
+// zero time.
+func (h PatchHeader) MarshalJSON() ([]byte, error) {
+	type header PatchHeader
+	return json.Marshal(struct {
+		header
+		AuthorDate    *time.Time `json:"author_date,omitempty"`
+		CommitterDate *time.Time `json:"committer_date,omitempty"`
+	}{
+		header:        header(h),
+		AuthorDate:    timeOrNil(h.AuthorDate),
+		CommitterDate: timeOrNil(h.CommitterDate),
+	})
+}
+
+// UnmarshalJSON decodes a header encoded by MarshalJSON.
+func (h *PatchHeader) UnmarshalJSON(data []byte) error {
+	type header PatchHeader
+
+	var v struct {
+		*header
+		AuthorDate    *time.Time `json:"author_date"`
+		CommitterDate *time.Time `json:"committer_date"`
+	}
+	v.header = (*header)(h)
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	h.AuthorDate, h.CommitterDate = time.Time{}, time.Time{}
+	if v.AuthorDate != nil {
+		h.AuthorDate = *v.AuthorDate
+	}
+	if v.CommitterDate != nil {
+		h.CommitterDate = *v.CommitterDate
+	}
+	return nil
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// MarshalText encodes the operation as "context", "delete", or "add".
+func (op LineOp) MarshalText() ([]byte, error) {
+	switch op {
+	case OpContext:
+		return []byte("context"), nil
+	case OpDelete:
+		return []byte("delete"), nil
+	case OpAdd:
+		return []byte("add"), nil
+	}
+	return nil, fmt.Errorf("gitdiff: invalid line operation: %d", op)
+}
+
+// UnmarshalText decodes an operation encoded by MarshalText.
+func (op *LineOp) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "context":
+		*op = OpContext
+	case "delete":
+		*op = OpDelete
+	case "add":
+		*op = OpAdd
+	default:
+		return fmt.Errorf("gitdiff: invalid line operation: %q", text)
+	}
+	return nil
+}
+
+// MarshalText encodes the method as "delta" or "literal".
+func (m BinaryPatchMethod) MarshalText() ([]byte, error) {
+	switch m {
+	case BinaryPatchDelta:
+		return []byte("delta"), nil
+	case BinaryPatchLiteral:
+		return []byte("literal"), nil
+	}
+	return nil, fmt.Errorf("gitdiff: invalid binary patch method: %d", m)
+}
+
+// UnmarshalText decodes a method encoded by MarshalText.
+func (m *BinaryPatchMethod) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "delta":
+		*m = BinaryPatchDelta
+	case "literal":
+		*m = BinaryPatchLiteral
+	default:
+		return fmt.Errorf("gitdiff: invalid binary patch method: %q", text)
+	}
+	return nil
+}
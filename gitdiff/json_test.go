@@ -0,0 +1,236 @@
+package gitdiff
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileJSON(t *testing.T) {
+	for _, name := range []string{
+		"testdata/one_file.patch",
+		"testdata/two_files.patch",
+		"testdata/new_binary_file.patch",
+		"testdata/apply_fs.patch",
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(name)
+			if err != nil {
+				t.Fatalf("unexpected error opening patch: %v", err)
+			}
+			defer f.Close()
+
+			files, _, err := ParseAll(f)
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			data, err := json.Marshal(files)
+			if err != nil {
+				t.Fatalf("unexpected error marshaling files: %v", err)
+			}
+
+			var decoded []*File
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("unexpected error unmarshaling files: %v", err)
+			}
+
+			for i := range files {
+				assertJSONFile(t, files[i], decoded[i])
+			}
+		})
+	}
+}
+
+func assertJSONFile(t *testing.T, expected, actual *File) {
+	// times do not preserve their location, so compare them separately
+	if !expected.OldTime.Equal(actual.OldTime) || !expected.NewTime.Equal(actual.NewTime) {
+		t.Errorf("incorrect times: expected %v, %v, actual %v, %v", expected.OldTime, expected.NewTime, actual.OldTime, actual.NewTime)
+	}
+	e, a := *expected, *actual
+	e.OldTime, e.NewTime = time.Time{}, time.Time{}
+	a.OldTime, a.NewTime = time.Time{}, time.Time{}
+	expected, actual = &e, &a
+
+	if expected.PatchHeader != nil && actual.PatchHeader != nil {
+		exp, act := *expected.PatchHeader, *actual.PatchHeader
+		if !exp.AuthorDate.Equal(act.AuthorDate) || !exp.CommitterDate.Equal(act.CommitterDate) {
+			t.Errorf("incorrect dates: expected %v, %v, actual %v, %v", exp.AuthorDate, exp.CommitterDate, act.AuthorDate, act.CommitterDate)
+		}
+		exp.AuthorDate, exp.CommitterDate = time.Time{}, time.Time{}
+		act.AuthorDate, act.CommitterDate = time.Time{}, time.Time{}
+
+		e, a := *expected, *actual
+		e.PatchHeader, a.PatchHeader = &exp, &act
+		expected, actual = &e, &a
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("incorrect decoded file\nexpected: %+v\n  actual: %+v", expected, actual)
+	}
+}
+
+func TestFileJSONFormat(t *testing.T) {
+	f := &File{
+		OldName:      "a.txt",
+		NewName:      "b.txt",
+		IsRename:     true,
+		OldMode:      0100644,
+		NewMode:      0100755,
+		OldOIDPrefix: "1111111",
+		NewOIDPrefix: "2222222",
+		Score:        90,
+		OldTime:      time.Date(2019, 4, 2, 22, 50, 0, 0, time.FixedZone("PDT", -7*60*60)),
+		NewTime:      time.Date(2019, 4, 2, 22, 55, 40, 500, time.FixedZone("PDT", -7*60*60)),
+		PatchHeader: &PatchHeader{
+			SHA:        "5d9790fec7d95aa223f3d20936340bf55ff3dcbe",
+			Author:     &PatchIdentity{Name: "Morton Haypenny", Email: "mhaypenny@example.com"},
+			AuthorDate: time.Date(2019, 4, 2, 22, 55, 40, 0, time.FixedZone("PDT", -7*60*60)),
+			Title:      "A rename",
+		},
+		TextFragments: []*TextFragment{
+			{
+				OldPosition:     1,
+				OldLines:        2,
+				NewPosition:     1,
+				NewLines:        2,
+				LinesAdded:      1,
+				LinesDeleted:    1,
+				LeadingContext:  1,
+				TrailingContext: 0,
+				Lines: []Line{
+					{OpContext, "line 1\n"},
+					{OpDelete, "line 2\n"},
+					{OpAdd, "line two\n"},
+				},
+			},
+		},
+		ReverseBinaryFragment: &BinaryFragment{Method: BinaryPatchDelta, Size: 3, Data: []byte("abc")},
+	}
+
+	expected := `{
+  "old_name": "a.txt",
+  "new_name": "b.txt",
+  "is_rename": true,
+  "old_oid_prefix": "1111111",
+  "new_oid_prefix": "2222222",
+  "score": 90,
+  "patch_header": {
+    "sha": "5d9790fec7d95aa223f3d20936340bf55ff3dcbe",
+    "author": {
+      "name": "Morton Haypenny",
+      "email": "mhaypenny@example.com"
+    },
+    "title": "A rename",
+    "author_date": "2019-04-02T22:55:40-07:00"
+  },
+  "text_fragments": [
+    {
+      "old_position": 1,
+      "old_lines": 2,
+      "new_position": 1,
+      "new_lines": 2,
+      "lines_added": 1,
+      "lines_deleted": 1,
+      "leading_context": 1,
+      "lines": [
+        {
+          "op": "context",
+          "line": "line 1\n"
+        },
+        {
+          "op": "delete",
+          "line": "line 2\n"
+        },
+        {
+          "op": "add",
+          "line": "line two\n"
+        }
+      ]
+    }
+  ],
+  "reverse_binary_fragment": {
+    "method": "delta",
+    "size": 3,
+    "data": "YWJj"
+  },
+  "old_mode": "100644",
+  "new_mode": "100755",
+  "old_time": "2019-04-02T22:50:00-07:00",
+  "new_time": "2019-04-02T22:55:40.0000005-07:00"
+}`
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		t.Fatalf("unexpected error marshaling file: %v", err)
+	}
+	if string(data) != expected {
+		t.Errorf("incorrect JSON\nexpected:\n%s\nactual:\n%s", expected, data)
+	}
+
+	var decoded File
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error unmarshaling file: %v", err)
+	}
+	assertJSONFile(t, f, &decoded)
+}
+
+func TestFileJSONErrors(t *testing.T) {
+	tests := map[string]struct {
+		Input string
+		Err   string
+	}{
+		"invalidMode": {
+			Input: `{"old_mode": "100689"}`,
+			Err:   "invalid mode",
+		},
+		"numericMode": {
+			Input: `{"old_mode": 33188}`,
+			Err:   "cannot unmarshal number",
+		},
+		"invalidLineOp": {
+			Input: `{"text_fragments": [{"lines": [{"op": "+", "line": "a\n"}]}]}`,
+			Err:   "invalid line operation",
+		},
+		"invalidBinaryMethod": {
+			Input: `{"binary_fragment": {"method": "zlib"}}`,
+			Err:   "invalid binary patch method",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var f File
+			err := json.Unmarshal([]byte(test.Input), &f)
+			assertError(t, test.Err, err, "unmarshaling file")
+		})
+	}
+}
+
+func TestLineOpJSON(t *testing.T) {
+	data, err := json.Marshal([]LineOp{OpContext, OpDelete, OpAdd})
+	if err != nil {
+		t.Fatalf("unexpected error marshaling operations: %v", err)
+	}
+	if expected := `["context","delete","add"]`; string(data) != expected {
+		t.Errorf("incorrect JSON: expected %s, actual %s", expected, data)
+	}
+
+	_, err = json.Marshal(LineOp(7))
+	assertError(t, "invalid line operation", err, "marshaling invalid operation")
+
+	if !strings.Contains(string(mustMarshal(t, CombinedLine{Ops: []LineOp{OpAdd, OpContext}, Line: "a\n"})), `"ops":["add","context"]`) {
+		t.Errorf("combined line does not encode operations as strings")
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling value: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,163 @@
+package gitdiff
+
+import (
+	"io"
+	"strings"
+)
+
+// WithLazyFragments configures Parse to skip the fragments of each file and
+// record where they are in the patch instead of parsing them. Parse returns
+// files with all of the information from their headers, but without any
+// fragments, until LoadFragments parses them. Files are not known to be
+// binary until their fragments are loaded. This avoids keeping the lines of
+// every fragment in memory for tools that only need the names, modes, or
+// stats of the files in large patches.
+//
+// Parse still reads each fragment to find the end of the file, but only
+// checks the fragment headers and line counts. Other errors in the fragments
+// and exceeded fragment and binary size limits are reported by LoadFragments.
+// For patches parsed with ParseBytes, files refer to the patch data instead of
+// copying their fragments. ParseWordDiff ignores this option.
+func WithLazyFragments() ParseOption {
+	return func(p *parser) {
+		p.lazy = true
+	}
+}
+
+// lazyFragments is the unparsed content of the fragments of a file, or the
+// compact form of its text fragments from Compact.
+type lazyFragments struct {
+	content string
+	line    int64
+	offset  int64
+	config  *parser
+	compact *compactFragments
+}
+
+// LoadFragments parses the fragments of a file from a patch parsed with
+// WithLazyFragments. It returns the error that Parse would return for the
+// fragments, with line numbers relative to the start of the patch. For files
+// compacted with Compact, it restores the text fragments and never fails. If
+// the fragments are already loaded, it does nothing.
+//
+// Functions and methods that use the fragments of a file load them as
+// needed. Those that return an error return the error from loading the
+// fragments, while the others treat fragments that can not be loaded as
+// missing, like Stat. Methods that modify the file and functions that return
+// references to its fragments, like DetectMoves, load the fragments into the
+// file, and other functions load a copy and leave the file unchanged. Call
+// LoadFragments before reading the fragments of the file directly. Loading
+// fragments modifies the file, so callers must not load the fragments of the
+// same file concurrently.
+func (f *File) LoadFragments() error {
+	lf := f.lazy
+	if lf == nil {
+		return nil
+	}
+	if lf.compact != nil {
+		f.TextFragments, f.lazy = lf.compact.fragments(), nil
+		return nil
+	}
+
+	p := *lf.config
+	p.r = &lineScanner{s: lf.content}
+	if err := p.Next(); err != nil && err != io.EOF {
+		return err
+	}
+	p.lineno = lf.line
+	p.nbytes += lf.offset
+
+	loaded := *f
+	loaded.lazy = nil
+	if err := p.parseFragments(&loaded); err != nil {
+		return err
+	}
+	*f = loaded
+	return nil
+}
+
+// loaded returns f if its fragments are loaded, or a copy of f with loaded
+// fragments for files with lazy fragments, without modifying f.
+func (f *File) loaded() (*File, error) {
+	if f.lazy == nil {
+		return f, nil
+	}
+	c := *f
+	if err := c.LoadFragments(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// loadedFiles returns files with the fragments of all files loaded, in the
+// same way as loaded.
+func loadedFiles(files []*File) ([]*File, error) {
+	var result []*File
+	for i, f := range files {
+		l, err := f.loaded()
+		if err != nil {
+			return nil, err
+		}
+		if l != f && result == nil {
+			result = append(make([]*File, 0, len(files)), files[:i]...)
+		}
+		if result != nil {
+			result = append(result, l)
+		}
+	}
+	if result == nil {
+		return files, nil
+	}
+	return result, nil
+}
+
+// deferFragments advances the parser past the fragments of f and records
+// their content so that LoadFragments can parse them later.
+func (p *parser) deferFragments(f *File) error {
+	if p.indexOnly {
+		return p.SkipFragments()
+	}
+	if p.lazyConfig == nil {
+		p.lazyConfig = &parser{
+			recount:       p.recount,
+			positions:     p.positions,
+			hashAlgorithm: p.hashAlgorithm,
+			maxFragments:  p.maxFragments,
+			maxBinarySize: p.maxBinarySize,
+		}
+	}
+	lf := &lazyFragments{line: p.lineno, offset: p.offset(), config: p.lazyConfig}
+
+	// lines from a lineScanner are already in memory, so record their offsets
+	// instead of copying them unless a transform changed their content
+	ls, ok := p.r.(*lineScanner)
+	if ok && p.transform == nil {
+		start := p.offset()
+		if err := p.SkipFragments(); err != nil {
+			return err
+		}
+		lf.content = ls.s[start:p.offset()]
+	} else {
+		p.lazyRecord = &strings.Builder{}
+		err := p.SkipFragments()
+		lf.content, p.lazyRecord = p.lazyRecord.String(), nil
+		if err != nil {
+			return err
+		}
+	}
+
+	if lf.content != "" {
+		f.lazy = lf
+	}
+	return nil
+}
+
+// offset returns the byte offset of the current line in the input, before
+// any transform.
+func (p *parser) offset() int64 {
+	n := p.nbytes
+	for _, size := range p.sizes {
+		n -= int64(size)
+	}
+	return n
+}
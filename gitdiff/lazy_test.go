@@ -0,0 +1,274 @@
+package gitdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLazyFragments(t *testing.T) {
+	for _, name := range []string{
+		"one_file.patch",
+		"two_files.patch",
+		"new_binary_file.patch",
+		"context_diff.patch",
+		"submodule.patch",
+		"sha256.patch",
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatalf("failed to read patch: %v", err)
+			}
+
+			expected, _, err := ParseAll(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			parsers := map[string]func() ([]*File, string, error){
+				"reader": func() ([]*File, string, error) {
+					return ParseAll(bytes.NewReader(data), WithLazyFragments())
+				},
+				"bytes": func() ([]*File, string, error) {
+					return ParseBytes(data, WithLazyFragments())
+				},
+			}
+			for pname, parse := range parsers {
+				files, _, err := parse()
+				if err != nil {
+					t.Fatalf("%s: unexpected error parsing patch: %v", pname, err)
+				}
+				if len(files) != len(expected) {
+					t.Fatalf("%s: incorrect number of files: expected %d, actual %d", pname, len(expected), len(files))
+				}
+
+				for i, f := range files {
+					if len(f.TextFragments) > 0 || f.BinaryFragment != nil {
+						t.Errorf("%s: file %d has fragments before loading", pname, i)
+					}
+					if !reflect.DeepEqual(expected[i].Stat(), f.Stat()) {
+						t.Errorf("%s: incorrect stat for file %d\nexpected: %+v\n  actual: %+v", pname, i, expected[i].Stat(), f.Stat())
+					}
+					if err := f.LoadFragments(); err != nil {
+						t.Fatalf("%s: unexpected error loading fragments: %v", pname, err)
+					}
+					if !reflect.DeepEqual(expected[i], f) {
+						t.Errorf("%s: incorrect file %d after loading\nexpected: %+v\n  actual: %+v", pname, i, expected[i], f)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLazyFragmentsError(t *testing.T) {
+	patch := "preamble\n" +
+		"diff --git a/file.txt b/file.txt\n" +
+		"new file mode 100644\n" +
+		"--- /dev/null\n" +
+		"+++ b/file.txt\n" +
+		"@@ -1 +1 @@\n" +
+		"-old\n" +
+		"+new\n"
+
+	_, _, expected := ParseAll(strings.NewReader(patch))
+	if expected == nil {
+		t.Fatal("expected error parsing patch, but got nil")
+	}
+
+	files, _, err := ParseAll(strings.NewReader(patch), WithLazyFragments())
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("incorrect number of files: expected 1, actual %d", len(files))
+	}
+
+	err = files[0].LoadFragments()
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected parse error loading fragments, but got %v", err)
+	}
+	if !reflect.DeepEqual(expected, err) {
+		t.Errorf("incorrect error\nexpected: %v\n  actual: %v", expected, err)
+	}
+	if len(files[0].TextFragments) > 0 {
+		t.Errorf("file has fragments after failed load")
+	}
+}
+
+func TestLazyFragmentsApply(t *testing.T) {
+	src, patch, out := applyFiles{
+		Src:   "file_text.src",
+		Patch: "file_text_modify.patch",
+		Out:   "file_text_modify.out",
+	}.Load(t)
+
+	files, _, err := ParseBytes(patch, WithLazyFragments())
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	var dst bytes.Buffer
+	if err := Apply(&dst, bytes.NewReader(src), files[0]); err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+	if !bytes.Equal(out, dst.Bytes()) {
+		t.Errorf("incorrect result after apply")
+	}
+}
+
+// TestLazyFragmentsConsumers checks that the functions that use fragments load
+// them for files with lazy and compact fragments.
+func TestLazyFragmentsConsumers(t *testing.T) {
+	formatFiles := func(files []*File) string {
+		var b strings.Builder
+		for _, f := range files {
+			b.WriteString(f.String())
+		}
+		return b.String()
+	}
+
+	tests := map[string]func(parse func(name string) []*File) (string, error){
+		"Format": func(parse func(string) []*File) (string, error) {
+			var b bytes.Buffer
+			_, err := Format(&b, parse("edit_three.patch")[0])
+			return b.String(), err
+		},
+		"FormatTraditional": func(parse func(string) []*File) (string, error) {
+			var b bytes.Buffer
+			_, err := FormatTraditional(&b, parse("two_files.patch"))
+			return b.String(), err
+		},
+		"FormatPatch": func(parse func(string) []*File) (string, error) {
+			var b bytes.Buffer
+			_, err := FormatPatch(&b, &PatchHeader{Title: "title"}, parse("two_files.patch"))
+			return b.String(), err
+		},
+		"Split": func(parse func(string) []*File) (string, error) {
+			parts, err := Split(parse("two_files.patch"), &PatchHeader{Title: "title"})
+			return string(bytes.Join(parts, nil)), err
+		},
+		"PatchID": func(parse func(string) []*File) (string, error) {
+			return PatchID(parse("two_files.patch"))
+		},
+		"MarshalJSON": func(parse func(string) []*File) (string, error) {
+			data, err := json.Marshal(parse("two_files.patch"))
+			return string(data), err
+		},
+		"Stat": func(parse func(string) []*File) (string, error) {
+			return fmt.Sprintf("%+v", *parse("edit_three.patch")[0].Stat()), nil
+		},
+		"Reverse": func(parse func(string) []*File) (string, error) {
+			return parse("edit_three.patch")[0].Reverse().String(), nil
+		},
+		"OldMissingNewline": func(parse func(string) []*File) (string, error) {
+			f := parse("edit_three.patch")[0]
+			return fmt.Sprint(f.OldMissingNewline(), f.NewMissingNewline()), nil
+		},
+		"ReduceContext": func(parse func(string) []*File) (string, error) {
+			f := parse("edit_three.patch")[0]
+			f.ReduceContext(1)
+			return f.String(), nil
+		},
+		"NewPositionMap": func(parse func(string) []*File) (string, error) {
+			return fmt.Sprintf("%+v", NewPositionMap(parse("edit_three.patch")[0]).Lines()), nil
+		},
+		"NewLineMapper": func(parse func(string) []*File) (string, error) {
+			m := NewLineMapper(parse("edit_three.patch")[0])
+			var b strings.Builder
+			for line := int64(1); line <= 30; line++ {
+				n, op := m.OldToNew(line)
+				fmt.Fprintf(&b, "%d:%d:%v ", line, n, op)
+			}
+			return b.String(), nil
+		},
+		"DetectMoves": func(parse func(string) []*File) (string, error) {
+			files := parse("two_files.patch")
+			m := DetectMoves(files)
+			var b strings.Builder
+			for _, f := range files {
+				for _, frag := range f.TextFragments {
+					fmt.Fprint(&b, m.Moved(frag), " ")
+				}
+			}
+			return b.String(), nil
+		},
+		"RemoveFragment": func(parse func(string) []*File) (string, error) {
+			f := parse("edit_three.patch")[0]
+			f.RemoveFragment(1)
+			return f.String(), nil
+		},
+		"Filter": func(parse func(string) []*File) (string, error) {
+			f := parse("edit_three.patch")[0]
+			f.Filter(func(frag *TextFragment) bool { return frag.OldPosition > 1 })
+			return f.String(), nil
+		},
+		"SplitFragment": func(parse func(string) []*File) (string, error) {
+			f := parse("edit_three.patch")[0]
+			err := f.SplitFragment(0, 4)
+			return f.String(), err
+		},
+		"CoalesceFragments": func(parse func(string) []*File) (string, error) {
+			f := parse("edit_three.patch")[0]
+			err := f.CoalesceFragments()
+			return f.String(), err
+		},
+		"SelectFragments": func(parse func(string) []*File) (string, error) {
+			f, err := parse("edit_three.patch")[0].SelectFragments(0, 2)
+			if err != nil {
+				return "", err
+			}
+			return f.String(), nil
+		},
+		"Compact": func(parse func(string) []*File) (string, error) {
+			f := parse("edit_three.patch")[0]
+			f.Compact()
+			return f.String(), nil
+		},
+		"Combine": func(parse func(string) []*File) (string, error) {
+			files, err := Combine(parse("combine_1.patch"), parse("combine_2.patch"), parse("combine_3.patch"))
+			return formatFiles(files), err
+		},
+		"Commute": func(parse func(string) []*File) (string, error) {
+			b, a, err := Commute(parse("commute_a.patch"), parse("commute_b.patch"))
+			return formatFiles(b) + formatFiles(a), err
+		},
+		"Interdiff": func(parse func(string) []*File) (string, error) {
+			files, err := Interdiff(parse("interdiff_v1.patch"), parse("interdiff_v2.patch"))
+			return formatFiles(files), err
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			parse := func(opts ...ParseOption) func(string) []*File {
+				return func(name string) []*File {
+					files, _, err := ParseAll(strings.NewReader(readTestPatch(t, name)), opts...)
+					if err != nil {
+						t.Fatalf("failed to parse patch: %v", err)
+					}
+					return files
+				}
+			}
+
+			expected, expectedErr := test(parse())
+			for _, opt := range []ParseOption{WithLazyFragments(), WithCompactLines()} {
+				actual, err := test(parse(opt))
+				if !reflect.DeepEqual(expectedErr, err) {
+					t.Fatalf("incorrect error\nexpected: %v\n  actual: %v", expectedErr, err)
+				}
+				if expected != actual {
+					t.Errorf("incorrect result\nexpected: %q\n  actual: %q", expected, actual)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package gitdiff
+
+import (
+	"io"
+)
+
+// ParseResult is the result of ParseLenient.
+type ParseResult struct {
+	// Files are the files parsed from the patch, including files with
+	// invalid fragments, which only have the fragments before the invalid
+	// one.
+	Files []*File
+
+	// Preamble is the content before the first file
+	Preamble string
+
+	// Errors are the problems ParseLenient recovered from, in the order of
+	// the input. Each error reports the line and the byte offset of the first
+	// invalid line.
+	Errors []*ParseError
+
+	// Err is the error that stopped parsing before the end of the input, like
+	// an error reading the patch or an exceeded limit, or nil if ParseLenient
+	// reached the end of the input.
+	Err error
+}
+
+// ParseLenient parses a patch like ParseAll, but continues after invalid
+// content and returns everything it parsed, for services that parse patches
+// from untrusted sources. It skips files with invalid headers and, when the
+// fragments of a file are invalid, returns the file with the fragments before
+// the invalid fragment and continues at the next file header. It records each
+// of these problems in the Errors of the result.
+//
+// ParseLenient stops at errors reading r and at exceeded limits, which are
+// never recoverable, and reports them in the Err of the result. It does not
+// panic for any input; use the limit options, like WithMaxLineLength and
+// WithMaxFiles, to bound the memory used for large inputs. WithStrict has no
+// effect, and with WithLazyFragments, errors in fragments are reported when
+// loading the fragments instead of by ParseLenient.
+func ParseLenient(r io.Reader, opts ...ParseOption) (res *ParseResult) {
+	res = &ParseResult{}
+
+	p := newParser(r, opts...)
+	p.strict, p.lenient = false, true
+
+	defer func() {
+		res.Errors = p.recovered
+		if v := recover(); v != nil {
+			res.Err = p.Errorf(0, KindUnknown, "internal error: %v", v)
+		}
+	}()
+
+	if err := p.Next(); err != nil {
+		if err != io.EOF {
+			res.Err = err
+		}
+		return res
+	}
+
+	res.Preamble, res.Err = p.ParseFiles(func(f *File) error {
+		res.Files = append(res.Files, f)
+		return nil
+	})
+	return res
+}
+
+// recoverError returns true if a lenient parser can continue after err,
+// recording the error.
+func (p *parser) recoverError(err *ParseError) bool {
+	if !p.lenient || err.Kind == KindLimitExceeded {
+		return false
+	}
+	p.recovered = append(p.recovered, err)
+	return true
+}
@@ -0,0 +1,104 @@
+package gitdiff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLenient(t *testing.T) {
+	const patch = `preamble
+diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-a
++b
+@@ -5,2 +5,2 @@
+-e
++f
+diff --git a/b.txt b/b.txt
+old mode 10o644
+new mode 100755
+@@ -1 +1 @@
+-a
++b
+diff --git a/c.txt b/c.txt
+--- a/c.txt
++++ b/c.txt
+@@ -1 +1 @@
+-c
++d
+`
+
+	res := ParseLenient(strings.NewReader(patch))
+	if res.Err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", res.Err)
+	}
+	if res.Preamble != "preamble\n" {
+		t.Errorf("incorrect preamble: %q", res.Preamble)
+	}
+
+	var names []string
+	for _, f := range res.Files {
+		names = append(names, f.NewName)
+	}
+	if strings.Join(names, ",") != "a.txt,c.txt" {
+		t.Fatalf("incorrect files: %v", names)
+	}
+	if len(res.Files[0].TextFragments) != 1 {
+		t.Errorf("incorrect number of fragments for invalid file: %d", len(res.Files[0].TextFragments))
+	}
+
+	expected := []struct {
+		Line   int64
+		Offset int64
+		Kind   ParseErrorKind
+	}{
+		{Line: 11, Offset: 100, Kind: KindInvalidLine},
+		{Line: 12, Offset: 127, Kind: KindInvalidMode},
+		{Line: 14, Offset: 159, Kind: KindOrphanFragment},
+	}
+	if len(res.Errors) != len(expected) {
+		t.Fatalf("incorrect number of errors: expected %d, actual %d: %v", len(expected), len(res.Errors), res.Errors)
+	}
+	for i, exp := range expected {
+		err := res.Errors[i]
+		if err.Line != exp.Line || err.Offset != exp.Offset || err.Kind != exp.Kind {
+			t.Errorf("incorrect error %d: expected line %d, offset %d, %s; actual line %d, offset %d, %s (%v)",
+				i, exp.Line, exp.Offset, exp.Kind, err.Line, err.Offset, err.Kind, err)
+		}
+		if !strings.HasPrefix(patch[err.Offset:], err.Text) {
+			t.Errorf("error %d text %q is not at offset %d", i, err.Text, err.Offset)
+		}
+	}
+}
+
+func TestParseLenientLimit(t *testing.T) {
+	const patch = `diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-a
++b
+diff --git a/b.txt b/b.txt
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1 @@
+-a
++b
+`
+
+	res := ParseLenient(strings.NewReader(patch), WithMaxFiles(1))
+
+	var lerr *LimitExceededError
+	if !errors.As(res.Err, &lerr) {
+		t.Fatalf("expected limit error, but got %v", res.Err)
+	}
+	if len(res.Files) != 1 {
+		t.Errorf("incorrect number of files: expected 1, actual %d", len(res.Files))
+	}
+	if len(res.Errors) != 0 {
+		t.Errorf("unexpected recovered errors: %v", res.Errors)
+	}
+}
@@ -0,0 +1,146 @@
+package gitdiff
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// Limit identifies a limit on the size of a patch set by a ParseOption.
+type Limit int
+
+const (
+	// LimitLineLength is the maximum length of a line, set by
+	// WithMaxLineLength
+	LimitLineLength Limit = iota + 1
+	// LimitFragments is the maximum number of fragments in a file, set by
+	// WithMaxFragments
+	LimitFragments
+	// LimitFiles is the maximum number of files in a patch, set by
+	// WithMaxFiles
+	LimitFiles
+	// LimitBinarySize is the maximum size of binary data, set by
+	// WithMaxBinarySize
+	LimitBinarySize
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitLineLength:
+		return "line length"
+	case LimitFragments:
+		return "fragments per file"
+	case LimitFiles:
+		return "files per patch"
+	case LimitBinarySize:
+		return "binary size"
+	}
+	return "unknown"
+}
+
+// LimitExceededError is the error returned when a patch exceeds a limit set
+// by a ParseOption. Parse wraps it in a *ParseError with the KindLimitExceeded
+// kind and the line where parsing stopped. Users can test for this by using
+// errors.Is with an empty LimitExceededError.
+type LimitExceededError struct {
+	// Limit is the limit that was exceeded
+	Limit Limit
+	// Max is the configured value of the limit
+	Max int64
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s exceeds limit of %d", e.Limit, e.Max)
+}
+
+// Is implements error matching for LimitExceededError. Passing an empty
+// instance of LimitExceededError always returns true.
+func (e *LimitExceededError) Is(other error) bool {
+	if other, ok := other.(*LimitExceededError); ok {
+		return *other == LimitExceededError{} || *other == *e
+	}
+	return false
+}
+
+// WithMaxLineLength configures Parse to stop with a *LimitExceededError at
+// the first line that is longer than n bytes, not including the newline.
+// Parse does not read more than n bytes of a line into memory.
+func WithMaxLineLength(n int) ParseOption {
+	return func(p *parser) {
+		p.maxLineLength = n
+	}
+}
+
+// WithMaxFragments configures Parse to stop with a *LimitExceededError at the
+// first file with more than n fragments.
+func WithMaxFragments(n int) ParseOption {
+	return func(p *parser) {
+		p.maxFragments = n
+	}
+}
+
+// WithMaxFiles configures Parse to stop with a *LimitExceededError at the
+// first file after n files, including files that Parse skips because of
+// WithIncludePaths or WithExcludePaths.
+func WithMaxFiles(n int) ParseOption {
+	return func(p *parser) {
+		p.maxFiles = n
+	}
+}
+
+// WithMaxBinarySize configures Parse to stop with a *LimitExceededError at the
+// first binary fragment with more than n bytes of data. The limit applies to
+// the size of the data after decompression and also bounds the size of the
+// compressed data in the patch.
+func WithMaxBinarySize(n int64) ParseOption {
+	return func(p *parser) {
+		p.maxBinarySize = n
+	}
+}
+
+// limitErrorf returns a *ParseError for a LimitExceededError at the line at
+// delta from the current line.
+func (p *parser) limitErrorf(delta int64, limit Limit, max int64) *ParseError {
+	return p.Errorf(delta, KindLimitExceeded, "%w", &LimitExceededError{Limit: limit, Max: max})
+}
+
+// checkFragmentLimit returns an error if a file with n fragments can not have
+// another fragment. The fragment header is at delta from the current line.
+func (p *parser) checkFragmentLimit(delta int64, n int) error {
+	if p.maxFragments > 0 && n >= p.maxFragments {
+		return p.limitErrorf(delta, LimitFragments, int64(p.maxFragments))
+	}
+	return nil
+}
+
+// maxDeflateSize returns the maximum size of zlib data for n bytes of
+// uncompressed data, including the headers and the overhead of stored
+// blocks for data that does not compress.
+func maxDeflateSize(n int64) int64 {
+	return n + (n/65535+1)*5 + 64
+}
+
+// limitedLineReader reads lines from a bufio.Reader and fails if a line is
+// longer than max bytes, not including the delimiter, without reading the
+// rest of the line.
+type limitedLineReader struct {
+	r   *bufio.Reader
+	max int
+}
+
+func (r *limitedLineReader) ReadString(delim byte) (string, error) {
+	var line []byte
+	for {
+		b, err := r.r.ReadSlice(delim)
+		n := len(line) + len(b)
+		if len(b) > 0 && b[len(b)-1] == delim {
+			n--
+		}
+		if n > r.max {
+			return "", &LimitExceededError{Limit: LimitLineLength, Max: int64(r.max)}
+		}
+		line = append(line, b...)
+		if err != bufio.ErrBufferFull {
+			return string(line), err
+		}
+	}
+}
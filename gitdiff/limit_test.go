@@ -0,0 +1,130 @@
+package gitdiff
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLimits(t *testing.T) {
+	const binaryLine = "gcmZQzU|?i`U?w2V48*KJ%mKu_Kr9NxN<eH500b)lkN^Mx\n"
+
+	tests := map[string]struct {
+		Patch   string
+		Input   string
+		Options []ParseOption
+		Limit   Limit
+		Line    int64
+	}{
+		"underLimits": {
+			Patch: "two_files.patch",
+			Options: []ParseOption{
+				WithMaxFiles(2),
+				WithMaxFragments(2),
+				WithMaxLineLength(100),
+			},
+		},
+		"files": {
+			Patch:   "two_files.patch",
+			Options: []ParseOption{WithMaxFiles(1)},
+			Limit:   LimitFiles,
+			Line:    32,
+		},
+		"fragments": {
+			Patch:   "one_file.patch",
+			Options: []ParseOption{WithMaxFragments(1)},
+			Limit:   LimitFragments,
+			Line:    25,
+		},
+		"lineLength": {
+			Input:   "preamble\n" + strings.Repeat("x", 21) + "\n",
+			Options: []ParseOption{WithMaxLineLength(20)},
+			Limit:   LimitLineLength,
+			Line:    2,
+		},
+		"lineLengthExact": {
+			Input:   "preamble\n" + strings.Repeat("x", 20) + "\n",
+			Options: []ParseOption{WithMaxLineLength(20)},
+		},
+		"binarySizeExact": {
+			Patch:   "new_binary_file.patch",
+			Options: []ParseOption{WithMaxBinarySize(40)},
+		},
+		"binarySize": {
+			Patch:   "new_binary_file.patch",
+			Options: []ParseOption{WithMaxBinarySize(39)},
+			Limit:   LimitBinarySize,
+			Line:    11,
+		},
+		"binaryCompressedSize": {
+			Input: "diff --git a/file.bin b/file.bin\n" +
+				"GIT binary patch\n" +
+				"literal 1\n" +
+				strings.Repeat(binaryLine, 3) +
+				"\n",
+			Options: []ParseOption{WithMaxBinarySize(1)},
+			Limit:   LimitBinarySize,
+			Line:    6,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			input := test.Input
+			if test.Patch != "" {
+				data, err := os.ReadFile(filepath.Join("testdata", test.Patch))
+				if err != nil {
+					t.Fatalf("failed to read patch: %v", err)
+				}
+				input = string(data)
+			}
+
+			_, _, err := ParseAll(strings.NewReader(input), test.Options...)
+			if test.Limit == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error parsing patch: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, &LimitExceededError{}) {
+				t.Fatalf("expected limit error, but got: %v", err)
+			}
+
+			var lerr *LimitExceededError
+			errors.As(err, &lerr)
+			if lerr.Limit != test.Limit {
+				t.Errorf("incorrect limit: expected %v, actual %v", test.Limit, lerr.Limit)
+			}
+
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected parse error, but got: %T", err)
+			}
+			if perr.Kind != KindLimitExceeded {
+				t.Errorf("incorrect error kind: expected %v, actual %v", KindLimitExceeded, perr.Kind)
+			}
+			if perr.Line != test.Line {
+				t.Errorf("incorrect error line: expected %d, actual %d", test.Line, perr.Line)
+			}
+			if perr.Recoverable() {
+				t.Errorf("limit error should not be recoverable")
+			}
+		})
+	}
+}
+
+func TestLimitExceededError(t *testing.T) {
+	err := &LimitExceededError{Limit: LimitFiles, Max: 10}
+	if msg := err.Error(); msg != "files per patch exceeds limit of 10" {
+		t.Errorf("incorrect message: %q", msg)
+	}
+	if !errors.Is(err, &LimitExceededError{}) || !errors.Is(err, &LimitExceededError{Limit: LimitFiles, Max: 10}) {
+		t.Errorf("error does not match itself or an empty error")
+	}
+	if errors.Is(err, &LimitExceededError{Limit: LimitFragments, Max: 10}) {
+		t.Errorf("error matches a different limit")
+	}
+}
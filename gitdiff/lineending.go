@@ -0,0 +1,116 @@
+package gitdiff
+
+import (
+	"io"
+	"strings"
+)
+
+// LineEndingPolicy controls how an Applier handles differences between the
+// line endings of a patch and the line endings of the source.
+type LineEndingPolicy int
+
+const (
+	// LineEndingStrict requires line endings in the patch to match the
+	// source exactly
+	LineEndingStrict LineEndingPolicy = iota
+	// LineEndingPreserve ignores the difference between LF and CRLF line
+	// endings when matching context and deleted lines to the source and
+	// writes added lines with the dominant line ending of the source
+	LineEndingPreserve
+)
+
+func (p LineEndingPolicy) String() string {
+	switch p {
+	case LineEndingStrict:
+		return "strict"
+	case LineEndingPreserve:
+		return "preserve"
+	}
+	return "unknown"
+}
+
+// WithLineEndings configures how an Applier handles patches with different
+// line endings than the source, for example a patch with LF line endings
+// generated from a file that was checked out with CRLF line endings.
+//
+// With LineEndingPreserve, the dominant line ending of the source is the line
+// ending used by most lines of the source, or LF if there is a tie. Context
+// lines keep the line ending from the source, so files with mixed line
+// endings only change in added lines. Added lines are written unchanged if
+// the source is empty.
+func WithLineEndings(policy LineEndingPolicy) ApplierOption {
+	return func(a *Applier) {
+		a.lineEndings = policy
+	}
+}
+
+// lineEndingPolicy returns the line ending policy for the current file, which
+// depends on its attributes.
+func (a *Applier) lineEndingPolicy() LineEndingPolicy {
+	switch {
+	case a.textAttr == AttrUnset:
+		return LineEndingStrict
+	case a.textAttr != AttrUnspecified || a.attrEOL != "":
+		return LineEndingPreserve
+	}
+	return a.lineEndings
+}
+
+// checkLineEndings finds the dominant line ending of the source if the line
+// ending policy needs it and it is not already known.
+func (a *Applier) checkLineEndings() error {
+	if a.lineEndingPolicy() != LineEndingPreserve || a.eolChecked {
+		return nil
+	}
+	if a.attrEOL != "" {
+		a.eol, a.eolChecked = a.attrEOL, true
+		return nil
+	}
+
+	var lf, crlf int
+	lines := make([][]byte, 64)
+	for offset := int64(0); ; {
+		n, err := a.lineSrc.ReadLinesAt(lines, offset)
+		for _, line := range lines[:n] {
+			switch {
+			case strings.HasSuffix(string(line), "\r\n"):
+				crlf++
+			case strings.HasSuffix(string(line), "\n"):
+				lf++
+			}
+		}
+		offset += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return applyError(err, lineNum(offset))
+		}
+	}
+
+	// an empty source has no line ending to preserve
+	switch {
+	case crlf > lf:
+		a.eol = "\r\n"
+	case lf > 0:
+		a.eol = "\n"
+	}
+	a.eolChecked = true
+	return nil
+}
+
+// fixLineEnding returns line with the dominant line ending of the source.
+func (a *Applier) fixLineEnding(line string) string {
+	if a.eol == "" || !strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return strings.TrimSuffix(line[:len(line)-1], "\r") + a.eol
+}
+
+// normalizeLineEnding replaces a CRLF line ending in line with LF.
+func normalizeLineEnding(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2] + "\n"
+	}
+	return line
+}
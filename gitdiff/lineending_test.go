@@ -0,0 +1,53 @@
+package gitdiff
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDominantLineEnding(t *testing.T) {
+	tests := map[string]struct {
+		Src string
+		EOL string
+	}{
+		"empty":      {Src: "", EOL: ""},
+		"noEOL":      {Src: "line", EOL: ""},
+		"lf":         {Src: "a\nb\n", EOL: "\n"},
+		"crlf":       {Src: "a\r\nb\r\n", EOL: "\r\n"},
+		"mostlyCRLF": {Src: "a\r\nb\nc\r\n", EOL: "\r\n"},
+		"tie":        {Src: "a\r\nb\n", EOL: "\n"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := NewApplier(bytes.NewReader([]byte(test.Src)), WithLineEndings(LineEndingPreserve))
+			if err := a.checkLineEndings(); err != nil {
+				t.Fatalf("unexpected error checking line endings: %v", err)
+			}
+			if a.eol != test.EOL {
+				t.Errorf("incorrect line ending: expected %q, actual %q", test.EOL, a.eol)
+			}
+		})
+	}
+}
+
+func TestFixLineEnding(t *testing.T) {
+	tests := map[string]struct {
+		EOL, Line, Output string
+	}{
+		"toCRLF":    {EOL: "\r\n", Line: "a\n", Output: "a\r\n"},
+		"toLF":      {EOL: "\n", Line: "a\r\n", Output: "a\n"},
+		"unchanged": {EOL: "\r\n", Line: "a\r\n", Output: "a\r\n"},
+		"noEOL":     {EOL: "\r\n", Line: "a", Output: "a"},
+		"unknown":   {EOL: "", Line: "a\r\n", Output: "a\r\n"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := &Applier{eol: test.EOL}
+			if out := a.fixLineEnding(test.Line); out != test.Output {
+				t.Errorf("incorrect line: expected %q, actual %q", test.Output, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package gitdiff
+
+import (
+	"sort"
+)
+
+// LineMapper maps line numbers between the old and new content of a text
+// file using the fragments of the file. Line numbers are one-indexed.
+type LineMapper struct {
+	frags []*TextFragment
+}
+
+// NewLineMapper creates a LineMapper for the text fragments of f. The
+// fragments must be valid and must not overlap.
+func NewLineMapper(f *File) *LineMapper {
+	if l, err := f.loaded(); err == nil {
+		f = l
+	}
+	frags := append([]*TextFragment(nil), f.TextFragments...)
+	sort.Slice(frags, func(i, j int) bool {
+		return frags[i].OldPosition < frags[j].OldPosition
+	})
+	return &LineMapper{frags: frags}
+}
+
+// OldToNew returns the line in the new content that corresponds to line in
+// the old content. If the line is unchanged, it returns the new line number
+// and OpContext. If the line was deleted, it returns the number of the first
+// new line after the deletion and OpDelete.
+func (m *LineMapper) OldToNew(line int64) (int64, LineOp) {
+	return m.mapLine(line, true)
+}
+
+// NewToOld returns the line in the old content that corresponds to line in
+// the new content. If the line is unchanged, it returns the old line number
+// and OpContext. If the line was added, it returns the number of the first
+// old line after the addition and OpAdd.
+func (m *LineMapper) NewToOld(line int64) (int64, LineOp) {
+	return m.mapLine(line, false)
+}
+
+func (m *LineMapper) mapLine(line int64, fromOld bool) (int64, LineOp) {
+	// the operation for lines in the source that have no counterpart
+	missing := OpAdd
+	if fromOld {
+		missing = OpDelete
+	}
+
+	var delta int64
+	for _, frag := range m.frags {
+		oldStart, newStart := fragmentStart(frag.OldPosition, frag.OldLines), fragmentStart(frag.NewPosition, frag.NewLines)
+		srcStart, srcLines, dstStart := newStart, frag.NewLines, oldStart
+		if fromOld {
+			srcStart, srcLines, dstStart = oldStart, frag.OldLines, newStart
+		}
+
+		switch {
+		case line < srcStart:
+			return line + delta, OpContext
+
+		case line < srcStart+srcLines:
+			src, dst := srcStart, dstStart
+			for _, fl := range frag.Lines {
+				switch {
+				case fl.Op == OpContext:
+					if src == line {
+						return dst, OpContext
+					}
+					src++
+					dst++
+				case fl.Op == missing:
+					if src == line {
+						return dst, missing
+					}
+					src++
+				default:
+					dst++
+				}
+			}
+		}
+
+		if fromOld {
+			delta = (newStart + frag.NewLines) - (oldStart + frag.OldLines)
+		} else {
+			delta = (oldStart + frag.OldLines) - (newStart + frag.NewLines)
+		}
+	}
+	return line + delta, OpContext
+}
+
+// fragmentStart returns the first line of one side of a fragment. If the side
+// has no lines, the position in the header is the line before the fragment.
+func fragmentStart(position, lines int64) int64 {
+	if lines == 0 {
+		return position + 1
+	}
+	return position
+}
@@ -0,0 +1,97 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineMapper(t *testing.T) {
+	patches := map[string]string{
+		"context": `diff --git a/lines.txt b/lines.txt
+--- a/lines.txt
++++ b/lines.txt
+@@ -1,4 +1,6 @@
++0
+ 1
+ 2
+-3
++3a
++3b
+ 4
+@@ -8,3 +10,2 @@
+ 8
+-9
+ 10
+@@ -12 +13,2 @@
+ 12
++13
+`,
+		"noContext": `diff --git a/lines.txt b/lines.txt
+--- a/lines.txt
++++ b/lines.txt
+@@ -0,0 +1 @@
++0
+@@ -9 +10,0 @@
+-9
+@@ -3 +4,2 @@
+-3
++3a
++3b
+@@ -12,0 +14 @@
++13
+`,
+	}
+
+	type mapping struct {
+		Line int64
+		Op   LineOp
+	}
+
+	oldToNew := map[int64]mapping{
+		1:  {2, OpContext},
+		2:  {3, OpContext},
+		3:  {4, OpDelete},
+		4:  {6, OpContext},
+		7:  {9, OpContext},
+		8:  {10, OpContext},
+		9:  {11, OpDelete},
+		10: {11, OpContext},
+		12: {13, OpContext},
+		20: {22, OpContext},
+	}
+	newToOld := map[int64]mapping{
+		1:  {1, OpAdd},
+		2:  {1, OpContext},
+		4:  {4, OpAdd},
+		5:  {4, OpAdd},
+		6:  {4, OpContext},
+		10: {8, OpContext},
+		11: {10, OpContext},
+		13: {12, OpContext},
+		14: {13, OpAdd},
+		22: {20, OpContext},
+	}
+
+	for name, patch := range patches {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := ParseAll(strings.NewReader(patch))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			m := NewLineMapper(files[0])
+
+			for line, expected := range oldToNew {
+				n, op := m.OldToNew(line)
+				if n != expected.Line || op != expected.Op {
+					t.Errorf("old line %d: expected %d (%q), actual %d (%q)", line, expected.Line, expected.Op, n, op)
+				}
+			}
+			for line, expected := range newToOld {
+				n, op := m.NewToOld(line)
+				if n != expected.Line || op != expected.Op {
+					t.Errorf("new line %d: expected %d (%q), actual %d (%q)", line, expected.Line, expected.Op, n, op)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Patch is a single patch from a series, like one message in the output of
+// "git format-patch".
+type Patch struct {
+	// Header describes the commit the patch was generated from
+	Header *PatchHeader
+
+	// Files contains the files changed by the patch. It is empty for messages
+	// without a diff, like the cover letter of a series.
+	Files []*File
+}
+
+// ParseMailbox parses a mailbox containing a series of patches, like the
+// output of "git format-patch --stdout", and returns the patches in order. It
+// splits the mailbox into messages at "From " separator lines in the same way
+// as "git mailsplit" and parses the header and files of each message. Options
+// configure the parsing of files in the same way as for ParseAll.
+//
+// If an error occurs, ParseMailbox returns the patches parsed before the
+// error. The line numbers of any *ParseError are relative to the start of the
+// mailbox.
+func ParseMailbox(r io.Reader, opts ...ParseOption) ([]*Patch, error) {
+	var patches []*Patch
+	err := splitMessages(r, isMailboxSeparator, func(msg string, start int64) error {
+		patch, err := parseMailboxMessage(msg, start, opts)
+		if err != nil {
+			return err
+		}
+		patches = append(patches, patch)
+		return nil
+	})
+	return patches, err
+}
+
+// parseMailboxMessage parses a single message that starts at the given line
+// offset in a mailbox.
+func parseMailboxMessage(msg string, offset int64, opts []ParseOption) (*Patch, error) {
+	files, preamble, trailing, err := parseAll(strings.NewReader(msg), opts)
+	if err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			perr.Line += offset
+		}
+		return nil, err
+	}
+	if len(files) == 0 {
+		preamble = msg
+	}
+
+	h, err := ParsePatchHeader(preamble)
+	if err != nil {
+		return nil, fmt.Errorf("gitdiff: line %d: invalid patch header: %w", offset+1, err)
+	}
+	if h.Signature == "" && len(files) > 0 {
+		h.Signature = parseSignature(trailing)
+	}
+	if h.Base == nil && len(files) > 0 {
+		_, h.Base = parseSeriesBase(trailing)
+	}
+	for _, f := range files {
+		f.PatchHeader = h
+	}
+	return &Patch{Header: h, Files: files}, nil
+}
+
+// parseSignature returns the text after the first "-- " line in s, which is
+// the signature of an email, or an empty string if there is no signature.
+func parseSignature(s string) string {
+	const delim = "-- \n"
+
+	i := strings.Index(s, "\n"+delim)
+	switch {
+	case strings.HasPrefix(s, delim):
+		i = 0
+	case i >= 0:
+		i++
+	default:
+		return ""
+	}
+	return strings.TrimSpace(s[i+len(delim):])
+}
+
+// isMailboxSeparator returns true if line starts a new message in a mailbox.
+// Like "git mailsplit", it looks for a "From " line that ends with a time and
+// a year, like "From 1234abcd Mon Sep 17 00:00:00 2001".
+func isMailboxSeparator(line string) bool {
+	line = strings.TrimSuffix(line, "\n")
+	if len(line) < 20 || !strings.HasPrefix(line, mailHeaderPrefix) {
+		return false
+	}
+
+	colon := strings.LastIndexByte(line[:len(line)-2], ':')
+	if colon < len(mailHeaderPrefix)+4 {
+		return false
+	}
+	for _, i := range []int{colon - 4, colon - 2, colon - 1, colon + 1, colon + 2} {
+		if !isDigit(line[i]) {
+			return false
+		}
+	}
+
+	fields := strings.Fields(line[colon+3:])
+	if len(fields) == 0 {
+		return false
+	}
+	year, err := strconv.Atoi(fields[0])
+	return err == nil && year > 90
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
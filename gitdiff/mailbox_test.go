@@ -0,0 +1,187 @@
+package gitdiff
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMailbox(t *testing.T) {
+	mbox, err := os.Open(filepath.Join("testdata", "mailbox.patch"))
+	if err != nil {
+		t.Fatalf("failed to open mailbox: %v", err)
+	}
+	defer mbox.Close()
+
+	patches, err := ParseMailbox(mbox)
+	if err != nil {
+		t.Fatalf("unexpected error parsing mailbox: %v", err)
+	}
+	if len(patches) != 3 {
+		t.Fatalf("expected 3 patches, but got %d", len(patches))
+	}
+
+	author := &PatchIdentity{Name: "Morton Haypenny", Email: "dev@example.com"}
+	tz := time.FixedZone("", -7*60*60)
+
+	tests := []struct {
+		SubjectPrefix string
+		Series        PatchSeries
+		Title         string
+		Body          string
+		AuthorDate    time.Time
+		Files         []string
+	}{
+		{
+			SubjectPrefix: "[PATCH 0/2] ",
+			Series:        PatchSeries{Prefix: "PATCH", Version: 1, Index: 0, Total: 2},
+			Title:         "*** SUBJECT HERE ***",
+			AuthorDate:    time.Date(2020, 4, 13, 12, 0, 0, 0, tz),
+		},
+		{
+			SubjectPrefix: "[PATCH 1/2] ",
+			Series:        PatchSeries{Prefix: "PATCH", Version: 1, Index: 1, Total: 2},
+			Title:         "Change the second line",
+			Body:          "This replaces the word with a number.\nFrom now on, numbers are preferred.",
+			AuthorDate:    time.Date(2020, 4, 12, 10, 0, 0, 0, tz),
+			Files:         []string{"file.txt"},
+		},
+		{
+			SubjectPrefix: "[PATCH 2/2] ",
+			Series:        PatchSeries{Prefix: "PATCH", Version: 1, Index: 2, Total: 2},
+			Title:         "Add a new file and change the third line",
+			AuthorDate:    time.Date(2020, 4, 13, 10, 0, 0, 0, tz),
+			Files:         []string{"file.txt", "new.txt"},
+		},
+	}
+
+	for i, test := range tests {
+		h := patches[i].Header
+		if h.SHA != "0000000000000000000000000000000000000000" {
+			t.Errorf("patch %d: incorrect SHA: %s", i, h.SHA)
+		}
+		if h.Author == nil || *h.Author != *author {
+			t.Errorf("patch %d: incorrect author: %v", i, h.Author)
+		}
+		if !h.AuthorDate.Equal(test.AuthorDate) {
+			t.Errorf("patch %d: incorrect author date: %v", i, h.AuthorDate)
+		}
+		if h.SubjectPrefix != test.SubjectPrefix {
+			t.Errorf("patch %d: incorrect subject prefix: %q", i, h.SubjectPrefix)
+		}
+		if h.Series == nil || *h.Series != test.Series {
+			t.Errorf("patch %d: incorrect series: %+v", i, h.Series)
+		}
+		if h.Title != test.Title {
+			t.Errorf("patch %d: incorrect title: %q", i, h.Title)
+		}
+		if test.Body != "" && h.Body != test.Body {
+			t.Errorf("patch %d: incorrect body: %q", i, h.Body)
+		}
+		if h.Signature != "2.27.0" {
+			t.Errorf("patch %d: incorrect signature: %q", i, h.Signature)
+		}
+
+		files := patches[i].Files
+		if len(files) != len(test.Files) {
+			t.Errorf("patch %d: expected %d files, but got %d", i, len(test.Files), len(files))
+			continue
+		}
+		for j, f := range files {
+			if f.NewName != test.Files[j] {
+				t.Errorf("patch %d: incorrect name for file %d: %s", i, j, f.NewName)
+			}
+			if f.PatchHeader != h {
+				t.Errorf("patch %d: file %d does not have the patch header", i, j)
+			}
+		}
+	}
+}
+
+func TestParseMailboxBase(t *testing.T) {
+	mbox, err := os.Open(filepath.Join("testdata", "mailbox_base.patch"))
+	if err != nil {
+		t.Fatalf("failed to open mailbox: %v", err)
+	}
+	defer mbox.Close()
+
+	patches, err := ParseMailbox(mbox)
+	if err != nil {
+		t.Fatalf("unexpected error parsing mailbox: %v", err)
+	}
+	if len(patches) != 2 {
+		t.Fatalf("expected 2 patches, but got %d", len(patches))
+	}
+
+	expected := &PatchSeriesBase{
+		Commit:               "fbe14f58ad27a629666a5562d0c4eb6f0148b8cb",
+		PrerequisitePatchIDs: []string{"5f3f67a30b8a8df3ba78b4739d7c3708d10b2925"},
+	}
+	if base := patches[0].Header.Base; !reflect.DeepEqual(expected, base) {
+		t.Errorf("incorrect base for patch 0:\n  expected: %+v\n    actual: %+v", expected, base)
+	}
+	if sig := patches[0].Header.Signature; sig != "2.39.5" {
+		t.Errorf("incorrect signature for patch 0: %q", sig)
+	}
+	if base := patches[1].Header.Base; base != nil {
+		t.Errorf("expected no base for patch 1, but got %+v", base)
+	}
+}
+
+func TestParseMailboxError(t *testing.T) {
+	mbox := `From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <dev@example.com>
+Subject: [PATCH 1/2] First change
+
+diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1 @@
+-one
++1
+From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <dev@example.com>
+Subject: [PATCH 2/2] Second change
+
+diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,2 @@
+-1
++one
+`
+
+	patches, err := ParseMailbox(strings.NewReader(mbox))
+	if len(patches) != 1 {
+		t.Errorf("expected 1 patch before the error, but got %d", len(patches))
+	}
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected *ParseError, but got %v", err)
+	}
+	if perr.Line != 18 {
+		t.Errorf("incorrect error line: %d", perr.Line)
+	}
+}
+
+func TestIsMailboxSeparator(t *testing.T) {
+	tests := map[string]bool{
+		"From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001\n": true,
+		"From dev@example.com Sat Apr 11 15:21:23 2020\n":                          true,
+		"From now on, numbers are preferred.\n":                                    false,
+		"From: Morton Haypenny <dev@example.com>\n":                                false,
+		"From dev@example.com Sat Apr 11 15:21:23 90\n":                            false,
+		" From dev@example.com Sat Apr 11 15:21:23 2020\n":                         false,
+	}
+
+	for line, expected := range tests {
+		if actual := isMailboxSeparator(line); actual != expected {
+			t.Errorf("incorrect result for %q: expected %t, actual %t", line, expected, actual)
+		}
+	}
+}
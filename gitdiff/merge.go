@@ -0,0 +1,245 @@
+package gitdiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+const (
+	mergeMarkerOurs   = "<<<<<<< ours\n"
+	mergeMarkerSep    = "=======\n"
+	mergeMarkerTheirs = ">>>>>>> theirs\n"
+)
+
+// BlobReader provides the content of Git blobs. ReadBlob returns the content
+// of the blob with the given object ID, which may be abbreviated, or an error
+// if the blob does not exist.
+type BlobReader interface {
+	ReadBlob(oid string) ([]byte, error)
+}
+
+// BlobReaderFunc is a function that implements BlobReader.
+type BlobReaderFunc func(oid string) ([]byte, error)
+
+// ReadBlob calls fn(oid).
+func (fn BlobReaderFunc) ReadBlob(oid string) ([]byte, error) {
+	return fn(oid)
+}
+
+// MergeConflict is returned by ThreeWayApply when the merged content contains
+// conflicts. The merged content, including conflict markers, is still written
+// to the destination.
+type MergeConflict struct {
+	// Conflicts is the number of conflicting regions in the merged content
+	Conflicts int
+}
+
+func (e *MergeConflict) Error() string {
+	return fmt.Sprintf("merge conflict: %d conflicting region(s)", e.Conflicts)
+}
+
+// ThreeWayApply applies the changes in f to src and writes the result to dst,
+// falling back to a three-way merge if the changes do not apply cleanly, like
+// the --3way flag of "git apply".
+//
+// To merge, ThreeWayApply uses blobs to read the original content of the file
+// identified by the old OID in the patch, applies f to the original content,
+// and then merges that result with src. If both src and the patch change the
+// same lines, the merged content contains standard conflict markers:
+//
+//	<<<<<<< ours
+//	lines from src
+//	=======
+//	lines from the patch
+//	>>>>>>> theirs
+//
+// and ThreeWayApply returns a *MergeConflict after writing it to dst. It
+// merges after any failure of the initial apply, including a conflict or a
+// src that ends before the fragments do. If the patch does not include an old
+// OID, is binary, or does not apply to the original content, ThreeWayApply
+// returns the error from the initial apply and writes nothing to dst. It
+// returns errors from reading src without applying the patch.
+func ThreeWayApply(dst io.Writer, src io.ReaderAt, f *File, blobs BlobReader) error {
+	// read src first, so that any error from the initial apply is a failure
+	// of the patch, like a conflict or a short source, and not an I/O error
+	ours, err := ioutil.ReadAll(io.NewSectionReader(src, 0, math.MaxInt64))
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	applyErr := Apply(&out, bytes.NewReader(ours), f)
+	if applyErr == nil {
+		_, err := dst.Write(out.Bytes())
+		return err
+	}
+	if f.IsBinary || f.IsNew || f.OldOIDPrefix == "" {
+		return applyErr
+	}
+
+	base, err := blobs.ReadBlob(f.OldOIDPrefix)
+	if err != nil {
+		return fmt.Errorf("gitdiff: 3-way merge: reading blob %s: %v", f.OldOIDPrefix, err)
+	}
+
+	var theirs bytes.Buffer
+	if err := Apply(&theirs, bytes.NewReader(base), f); err != nil {
+		return applyErr
+	}
+
+	conflicts, err := mergeLines(dst, splitLines(string(base)), splitLines(string(ours)), splitLines(theirs.String()))
+	if err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return &MergeConflict{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// mergeLines performs a three-way merge of the changes from base to ours and
+// from base to theirs and writes the result to dst. It returns the number of
+// conflicting regions in the result.
+func mergeLines(dst io.Writer, base, ours, theirs []string) (conflicts int, err error) {
+	w := &lineWriter{w: dst}
+
+	oursHunks, theirsHunks := diffLines(base, ours), diffLines(base, theirs)
+	var oursDelta, theirsDelta int
+
+	next := 0
+	for len(oursHunks) > 0 || len(theirsHunks) > 0 {
+		// find the next group of overlapping or adjacent changes
+		var start, end int
+		switch {
+		case len(theirsHunks) == 0 || (len(oursHunks) > 0 && oursHunks[0].AStart <= theirsHunks[0].AStart):
+			start, end = oursHunks[0].AStart, oursHunks[0].AEnd
+		default:
+			start, end = theirsHunks[0].AStart, theirsHunks[0].AEnd
+		}
+
+		var oursCount, theirsCount int
+		for {
+			n := oursCount + theirsCount
+			for oursCount < len(oursHunks) && oursHunks[oursCount].AStart <= end {
+				end = int(max(int64(end), int64(oursHunks[oursCount].AEnd)))
+				oursCount++
+			}
+			for theirsCount < len(theirsHunks) && theirsHunks[theirsCount].AStart <= end {
+				end = int(max(int64(end), int64(theirsHunks[theirsCount].AEnd)))
+				theirsCount++
+			}
+			if oursCount+theirsCount == n {
+				break
+			}
+		}
+
+		// unchanged lines in the group are the same in all versions, so the
+		// position of the group in each version only depends on earlier changes
+		oursStart := start + oursDelta
+		for _, h := range oursHunks[:oursCount] {
+			oursDelta += (h.BEnd - h.BStart) - (h.AEnd - h.AStart)
+		}
+		theirsStart := start + theirsDelta
+		for _, h := range theirsHunks[:theirsCount] {
+			theirsDelta += (h.BEnd - h.BStart) - (h.AEnd - h.AStart)
+		}
+
+		oursLines := ours[oursStart : end+oursDelta]
+		theirsLines := theirs[theirsStart : end+theirsDelta]
+
+		w.WriteLines(base[next:start])
+		switch {
+		case theirsCount == 0:
+			w.WriteLines(oursLines)
+		case oursCount == 0 || equalLines(oursLines, theirsLines):
+			w.WriteLines(theirsLines)
+		default:
+			// move any common leading and trailing lines out of the conflict
+			prefix := 0
+			for prefix < len(oursLines) && prefix < len(theirsLines) && oursLines[prefix] == theirsLines[prefix] {
+				prefix++
+			}
+			suffix := 0
+			for suffix < len(oursLines)-prefix && suffix < len(theirsLines)-prefix &&
+				oursLines[len(oursLines)-1-suffix] == theirsLines[len(theirsLines)-1-suffix] {
+				suffix++
+			}
+
+			w.WriteLines(oursLines[:prefix])
+			w.WriteConflict(oursLines[prefix:len(oursLines)-suffix], theirsLines[prefix:len(theirsLines)-suffix])
+			w.WriteLines(oursLines[len(oursLines)-suffix:])
+			conflicts++
+		}
+
+		next = end
+		oursHunks, theirsHunks = oursHunks[oursCount:], theirsHunks[theirsCount:]
+	}
+	w.WriteLines(base[next:])
+
+	return conflicts, w.err
+}
+
+// lineWriter writes lines to an underlying writer, recording the first error.
+type lineWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (lw *lineWriter) WriteLines(lines []string) {
+	for _, line := range lines {
+		lw.WriteString(line)
+	}
+}
+
+func (lw *lineWriter) WriteString(s string) {
+	if lw.err == nil {
+		_, lw.err = io.WriteString(lw.w, s)
+	}
+}
+
+// WriteConflict writes conflicting lines between conflict markers. The
+// markers are always on their own lines, even if the last conflicting line
+// does not end with a newline.
+func (lw *lineWriter) WriteConflict(ours, theirs []string) {
+	lw.WriteString(mergeMarkerOurs)
+	lw.writeTerminated(ours)
+	lw.WriteString(mergeMarkerSep)
+	lw.writeTerminated(theirs)
+	lw.WriteString(mergeMarkerTheirs)
+}
+
+func (lw *lineWriter) writeTerminated(lines []string) {
+	lw.WriteLines(lines)
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lw.WriteString("\n")
+	}
+}
+
+// splitLines splits s into lines, keeping the newline characters. The last
+// line does not have a newline character if s does not end with one.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
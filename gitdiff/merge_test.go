@@ -0,0 +1,194 @@
+package gitdiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestThreeWayApply(t *testing.T) {
+	tests := map[string]struct {
+		Files     applyFiles
+		Conflicts int
+		Err       interface{}
+	}{
+		"clean": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_3way.patch",
+				Out:   "file_text_modify.out",
+			},
+		},
+		"merge": {
+			Files: applyFiles{
+				Src:   "file_text_3way_clean.src",
+				Patch: "file_text_3way.patch",
+				Out:   "file_text_3way_clean.out",
+			},
+		},
+		"mergeConflict": {
+			Files: applyFiles{
+				Src:   "file_text_3way_conflict.src",
+				Patch: "file_text_3way.patch",
+				Out:   "file_text_3way_conflict.out",
+			},
+			Conflicts: 1,
+		},
+		"errorNoOID": {
+			Files: applyFiles{
+				Src:   "file_text_3way_clean.src",
+				Patch: "file_text_modify.patch",
+			},
+			Err: &Conflict{},
+		},
+	}
+
+	base, _, _ := applyFiles{Src: "file_text.src"}.Load(t)
+	blobs := BlobReaderFunc(func(oid string) ([]byte, error) {
+		if strings.HasPrefix("3805ad4c93148145bc67b7006564c9c22df8d1e8", oid) {
+			return base, nil
+		}
+		return nil, fmt.Errorf("blob %s not found", oid)
+	})
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src, patch, out := test.Files.Load(t)
+
+			files, err := Parse(bytes.NewReader(patch))
+			if err != nil {
+				t.Fatalf("failed to parse patch file: %v", err)
+			}
+			parsed := collectFiles(files)
+			if len(parsed) != 1 {
+				t.Fatalf("patch should contain exactly one file, but it has %d", len(parsed))
+			}
+
+			var dst bytes.Buffer
+			err = ThreeWayApply(&dst, bytes.NewReader(src), parsed[0], blobs)
+			if test.Err != nil {
+				assertError(t, test.Err, err, "applying file")
+				if dst.Len() > 0 {
+					t.Errorf("expected no output after error, but got %d bytes", dst.Len())
+				}
+				return
+			}
+
+			var mc *MergeConflict
+			switch {
+			case test.Conflicts > 0 && errors.As(err, &mc):
+				if mc.Conflicts != test.Conflicts {
+					t.Errorf("incorrect number of conflicts: expected %d, actual %d", test.Conflicts, mc.Conflicts)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error applying file: %v", err)
+			case test.Conflicts > 0:
+				t.Fatalf("expected %d conflicts, but got nil error", test.Conflicts)
+			}
+
+			if !bytes.Equal(out, dst.Bytes()) {
+				t.Errorf("incorrect result after apply\nexpected:\n%s\nactual:\n%s", out, dst.Bytes())
+			}
+		})
+	}
+}
+
+func TestThreeWayApplyShortSource(t *testing.T) {
+	patch := "diff --git a/file.txt b/file.txt\n" +
+		"index 71ac1b5..b9a82af 100644\n" +
+		"--- a/file.txt\n" +
+		"+++ b/file.txt\n" +
+		"@@ -5,4 +5,4 @@ d\n" +
+		" e\n" +
+		" f\n" +
+		" g\n" +
+		"-h\n" +
+		"+H\n"
+
+	files, _, err := ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	blobs := BlobReaderFunc(func(oid string) ([]byte, error) {
+		return []byte("a\nb\nc\nd\ne\nf\ng\nh\n"), nil
+	})
+
+	// the source ends before the fragment, so the initial apply fails with
+	// io.ErrUnexpectedEOF instead of a conflict
+	var dst bytes.Buffer
+	err = ThreeWayApply(&dst, strings.NewReader("A\nb\nc\n"), files[0], blobs)
+
+	var mc *MergeConflict
+	if !errors.As(err, &mc) {
+		t.Fatalf("expected merge conflict, but got: %v", err)
+	}
+	if mc.Conflicts != 1 {
+		t.Errorf("incorrect number of conflicts: expected 1, actual %d", mc.Conflicts)
+	}
+
+	expected := "A\nb\nc\n<<<<<<< ours\n=======\nd\ne\nf\ng\nH\n>>>>>>> theirs\n"
+	if dst.String() != expected {
+		t.Errorf("incorrect result after apply\nexpected:\n%s\nactual:\n%s", expected, dst.String())
+	}
+}
+
+func TestMergeLines(t *testing.T) {
+	tests := map[string]struct {
+		Base, Ours, Theirs string
+		Output             string
+		Conflicts          int
+	}{
+		"identical": {
+			Base:   "a\nb\nc\n",
+			Ours:   "a\nB\nc\n",
+			Theirs: "a\nB\nc\n",
+			Output: "a\nB\nc\n",
+		},
+		"separate": {
+			Base:   "a\nb\nc\nd\ne\n",
+			Ours:   "A\nb\nc\nd\ne\n",
+			Theirs: "a\nb\nc\nd\nE\n",
+			Output: "A\nb\nc\nd\nE\n",
+		},
+		"adjacent": {
+			Base:      "a\nb\nc\n",
+			Ours:      "a\nB\nc\n",
+			Theirs:    "a\nb\nC\n",
+			Output:    "a\n<<<<<<< ours\nB\nc\n=======\nb\nC\n>>>>>>> theirs\n",
+			Conflicts: 1,
+		},
+		"trimCommon": {
+			Base:      "a\nb\nc\n",
+			Ours:      "a\nx\nB\ny\nc\n",
+			Theirs:    "a\nx\nC\ny\nc\n",
+			Output:    "a\nx\n<<<<<<< ours\nB\n=======\nC\n>>>>>>> theirs\ny\nc\n",
+			Conflicts: 1,
+		},
+		"noEOL": {
+			Base:      "a\nb",
+			Ours:      "a\nB",
+			Theirs:    "a\nC",
+			Output:    "a\n<<<<<<< ours\nB\n=======\nC\n>>>>>>> theirs\n",
+			Conflicts: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var dst bytes.Buffer
+			conflicts, err := mergeLines(&dst, splitLines(test.Base), splitLines(test.Ours), splitLines(test.Theirs))
+			if err != nil {
+				t.Fatalf("unexpected error merging: %v", err)
+			}
+			if conflicts != test.Conflicts {
+				t.Errorf("incorrect number of conflicts: expected %d, actual %d", test.Conflicts, conflicts)
+			}
+			if dst.String() != test.Output {
+				t.Errorf("incorrect merge output\nexpected: %q\n  actual: %q", test.Output, dst.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package gitdiff
+
+// like git, moved blocks must contain at least this many alphanumeric
+// characters so that short or blank lines are not reported as moves
+const minMovedAlnum = 20
+
+// MoveKind describes whether a deleted or added line is part of a block of
+// lines that moves to or from another place in a diff.
+type MoveKind int
+
+const (
+	// LineNotMoved indicates a line that is not part of a moved block
+	LineNotMoved MoveKind = iota
+	// LineMoved indicates a line in a moved block
+	LineMoved
+	// LineMovedAlt indicates a line in a moved block that directly follows
+	// another moved block with the same operation, which renderers can show
+	// with an alternate color, like the zebra mode of --color-moved in Git
+	LineMovedAlt
+)
+
+func (k MoveKind) String() string {
+	switch k {
+	case LineNotMoved:
+		return "none"
+	case LineMoved:
+		return "moved"
+	case LineMovedAlt:
+		return "moved-alt"
+	}
+	return "unknown"
+}
+
+// MoveMap records the moved lines of a set of files. Use DetectMoves to
+// create a MoveMap.
+type MoveMap struct {
+	lines map[*TextFragment][]MoveKind
+}
+
+// Line returns the kind of move for the line at index i of frag, or
+// LineNotMoved if the line is not moved or frag is not part of the files used
+// to create the map.
+func (m *MoveMap) Line(frag *TextFragment, i int) MoveKind {
+	if kinds := m.lines[frag]; i >= 0 && i < len(kinds) {
+		return kinds[i]
+	}
+	return LineNotMoved
+}
+
+// Moved returns true if any line of frag is part of a moved block.
+func (m *MoveMap) Moved(frag *TextFragment) bool {
+	for _, k := range m.lines[frag] {
+		if k != LineNotMoved {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MoveMap) set(frag *TextFragment, i int, k MoveKind) {
+	kinds := m.lines[frag]
+	if kinds == nil {
+		kinds = make([]MoveKind, len(frag.Lines))
+		m.lines[frag] = kinds
+	}
+	kinds[i] = k
+}
+
+// movedLine is a line in the sequence of lines that DetectMoves checks. Lines
+// with a nil fragment are the headers of fragments.
+type movedLine struct {
+	frag  *TextFragment
+	index int
+	Line
+}
+
+// DetectMoves finds blocks of lines that are deleted in one place and added in
+// another in the text fragments of files, either in the same file or in
+// different files, like the --color-moved flag of "git diff" in the default
+// zebra mode.
+//
+// Like Git, a block is a sequence of consecutive deleted or added lines that
+// match a sequence of consecutive added or deleted lines elsewhere, where
+// matching lines are identical. Blocks must include at least 20 alphanumeric
+// characters and context lines and fragment headers end blocks. Adjacent
+// blocks with the same operation alternate between LineMoved and
+// LineMovedAlt.
+func DetectMoves(files []*File) *MoveMap {
+	var lines []movedLine
+	for _, f := range files {
+		// the map refers to the fragments, so load them into the file
+		_ = f.LoadFragments()
+		for _, frag := range f.TextFragments {
+			lines = append(lines, movedLine{})
+			for i, line := range frag.Lines {
+				lines = append(lines, movedLine{frag: frag, index: i, Line: line})
+			}
+		}
+	}
+
+	// index the deleted and added lines by content and link each line to the
+	// next line with the same operation, which continues a block
+	index := map[LineOp]map[string][]int{
+		OpDelete: make(map[string][]int),
+		OpAdd:    make(map[string][]int),
+	}
+	next := make([]int, len(lines))
+	last := -1
+	for n, l := range lines {
+		next[n] = -1
+		if l.Op != OpDelete && l.Op != OpAdd {
+			continue
+		}
+		if last >= 0 && lines[last].Op != l.Op {
+			last = -1
+		}
+		if last >= 0 {
+			next[last] = n
+		}
+		last = n
+		index[l.Op][l.Line.Line] = append(index[l.Op][l.Line.Line], n)
+	}
+
+	m := &MoveMap{lines: make(map[*TextFragment][]MoveKind)}
+
+	// endBlock checks the block of length lines before n and removes the
+	// marks from its lines if it is too short to be a move
+	endBlock := func(n, length int) bool {
+		alnum := 0
+		for i := n - length; i < n; i++ {
+			for _, c := range []byte(lines[i].Line.Line) {
+				if isAlphaNumeric(c) {
+					alnum++
+				}
+			}
+			if alnum >= minMovedAlnum {
+				return true
+			}
+		}
+		for i := n - length; i < n; i++ {
+			m.set(lines[i].frag, lines[i].index, LineNotMoved)
+		}
+		return false
+	}
+
+	// candidates are the lines matched by the current line in the blocks that
+	// could be the source or destination of the current block
+	var candidates []int
+	var length int
+	var alt bool
+	lastOp := OpContext
+
+	for n := 0; n < len(lines); n++ {
+		l := lines[n]
+
+		var matches []int
+		switch l.Op {
+		case OpDelete:
+			matches = index[OpAdd][l.Line.Line]
+		case OpAdd:
+			matches = index[OpDelete][l.Line.Line]
+		default:
+			alt = false
+		}
+
+		if len(matches) == 0 {
+			if !endBlock(n, length) && length > 1 {
+				// retry in case another block starts at the second line
+				n -= length
+			}
+			candidates, length, lastOp = candidates[:0], 0, l.Op
+			continue
+		}
+
+		continued := candidates[:0]
+		for _, c := range candidates {
+			if c = next[c]; c >= 0 && lines[c].Line.Line == l.Line.Line {
+				continued = append(continued, c)
+			}
+		}
+		candidates = continued
+
+		if len(candidates) == 0 {
+			adjacent := endBlock(n, length)
+			if !adjacent && length > 1 {
+				// retry in case another block starts at the second line
+				n -= length
+			} else {
+				candidates = append(candidates, matches...)
+			}
+
+			alt = adjacent && len(candidates) > 0 && lastOp == l.Op && !alt
+			if len(candidates) > 0 {
+				lastOp = l.Op
+			} else {
+				lastOp = OpContext
+			}
+			length = 0
+		}
+
+		if len(candidates) > 0 {
+			length++
+			if alt {
+				m.set(l.frag, l.index, LineMovedAlt)
+			} else {
+				m.set(l.frag, l.index, LineMoved)
+			}
+		}
+	}
+	endBlock(len(lines), length)
+
+	return m
+}
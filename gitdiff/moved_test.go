@@ -0,0 +1,83 @@
+package gitdiff
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectMoves(t *testing.T) {
+	const (
+		n = LineNotMoved
+		m = LineMoved
+		a = LineMovedAlt
+	)
+
+	tests := map[string]struct {
+		File  string
+		Moves [][]MoveKind
+	}{
+		"moved": {
+			File: "moved.patch",
+			Moves: [][]MoveKind{
+				{n, n, m, m, m, m, n, n, n, n, n, n, m, m, n, n, n, n, n, n},
+				{n, n, n, n, m, m, n},
+			},
+		},
+		"zebra": {
+			File: "moved_zebra.patch",
+			Moves: [][]MoveKind{
+				{n, m, m, a, a, n},
+				{m, m, a, a},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			patch, err := os.Open(filepath.Join("testdata", test.File))
+			if err != nil {
+				t.Fatalf("failed to open patch: %v", err)
+			}
+			defer patch.Close()
+
+			files, _, err := ParseAll(patch)
+			if err != nil {
+				t.Fatalf("failed to parse patch: %v", err)
+			}
+
+			moves := DetectMoves(files)
+
+			var actual [][]MoveKind
+			for _, f := range files {
+				for _, frag := range f.TextFragments {
+					kinds := make([]MoveKind, len(frag.Lines))
+					for i := range frag.Lines {
+						kinds[i] = moves.Line(frag, i)
+					}
+					actual = append(actual, kinds)
+				}
+			}
+			if !reflect.DeepEqual(test.Moves, actual) {
+				t.Errorf("incorrect moved lines\nexpected: %v\n  actual: %v", test.Moves, actual)
+			}
+		})
+	}
+}
+
+func TestDetectMovesShortBlock(t *testing.T) {
+	f := &File{
+		TextFragments: []*TextFragment{
+			{Lines: []Line{{OpDelete, "}\n"}, {OpContext, "x\n"}}},
+			{Lines: []Line{{OpContext, "y\n"}, {OpAdd, "}\n"}}},
+		},
+	}
+
+	moves := DetectMoves([]*File{f})
+	for _, frag := range f.TextFragments {
+		if moves.Moved(frag) {
+			t.Errorf("short block should not be moved: %v", frag.Lines)
+		}
+	}
+}
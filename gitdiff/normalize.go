@@ -0,0 +1,74 @@
+package gitdiff
+
+// Normalize converts f to a canonical form, so that the String of two files
+// that make the same changes is the same, even if the patches were generated
+// with different options or edited. This is useful to compare patches, like
+// for caching the results of applying them or in snapshot tests. Normalize:
+//
+//   - loads the fragments of files parsed with WithLazyFragments
+//   - removes the object IDs and the hash algorithm, which String writes in
+//     the "index" line, unless the file is a submodule or a binary file
+//     without data, where the IDs identify the change
+//   - removes the similarity or dissimilarity score
+//   - merges overlapping text fragments with CoalesceFragments and then
+//     removes all context lines with ReduceContext
+//   - writes the deleted lines of each change before the added lines, like
+//     Git, and removes the function names from the fragment headers
+//
+// String always writes the extended header lines in the same order and
+// quotes file names in the same way, so the parsed order and quoting of the
+// original patch do not matter. The normalized file has no context, so apply
+// it with WithUnidiffZero. Normalize returns an error if the fragments can not
+// be loaded or merged, and may modify the file before returning an error.
+func Normalize(f *File) error {
+	if err := f.LoadFragments(); err != nil {
+		return err
+	}
+
+	if f.Submodule == nil && (!f.IsBinary || f.BinaryFragment != nil) {
+		f.OldOIDPrefix, f.NewOIDPrefix = "", ""
+		f.HashAlgorithm = HashUnknown
+	}
+	f.Score = 0
+
+	if len(f.TextFragments) == 0 {
+		return nil
+	}
+	if err := f.CoalesceFragments(); err != nil {
+		return err
+	}
+	f.ReduceContext(0)
+
+	for _, frag := range f.TextFragments {
+		frag.Comment = ""
+		frag.setLines(sortChangeLines(frag.Lines))
+	}
+	return nil
+}
+
+// sortChangeLines returns lines with the deleted lines of each run of changed
+// lines before the added lines.
+func sortChangeLines(lines []Line) []Line {
+	sorted := make([]Line, 0, len(lines))
+	for i := 0; i < len(lines); {
+		if lines[i].Op == OpContext {
+			sorted = append(sorted, lines[i])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(lines) && lines[end].Op != OpContext {
+			end++
+		}
+		for _, op := range []LineOp{OpDelete, OpAdd} {
+			for _, line := range lines[i:end] {
+				if line.Op == op {
+					sorted = append(sorted, line)
+				}
+			}
+		}
+		i = end
+	}
+	return sorted
+}
@@ -0,0 +1,135 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	patches := map[string]string{
+		"gitDiff": `diff --git "a/caf\303\251.txt" "b/caf\303\251.txt"
+index 1c23fcc..40a1b33 100644
+--- "a/caf\303\251.txt"
++++ "b/caf\303\251.txt"
+@@ -1,7 +1,7 @@ func main() {
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+ 7
+@@ -5,6 +5,7 @@
+ 5
+ 6
+ 7
++seven a
+ 8
+ 9
+ 10
+`,
+		"edited": `diff --git a/café.txt b/café.txt
+--- a/café.txt
++++ b/café.txt
+@@ -2,3 +2,3 @@
+ 2
+-3
++three
+ 4
+@@ -7,2 +7,3 @@ func main() {
+ 7
++seven a
+ 8
+`,
+	}
+
+	expected := `diff --git "a/caf\303\251.txt" "b/caf\303\251.txt"
+--- "a/caf\303\251.txt"
++++ "b/caf\303\251.txt"
+@@ -3 +3 @@
+-3
++three
+@@ -7,0 +8 @@
++seven a
+`
+
+	for name, patch := range patches {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := ParseAll(strings.NewReader(patch), WithLazyFragments())
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			f := files[0]
+			if err := Normalize(f); err != nil {
+				t.Fatalf("unexpected error normalizing file: %v", err)
+			}
+			if actual := f.String(); actual != expected {
+				t.Errorf("incorrect normalized file\nexpected:\n%s\nactual:\n%s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestNormalizeChangeOrder(t *testing.T) {
+	const patch = `diff --git a/old.txt b/new.txt
+similarity index 80%
+rename from old.txt
+rename to new.txt
+index 1c23fcc..40a1b33 100644
+--- a/old.txt
++++ b/new.txt
+@@ -1,3 +1,3 @@
+-1
++one
+-2
++two
+ 3
+`
+
+	expected := `diff --git a/old.txt b/new.txt
+rename from old.txt
+rename to new.txt
+--- a/old.txt
++++ b/new.txt
+@@ -1,2 +1,2 @@
+-1
+-2
++one
++two
+`
+
+	files, _, err := ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	f := files[0]
+	if err := Normalize(f); err != nil {
+		t.Fatalf("unexpected error normalizing file: %v", err)
+	}
+	if actual := f.String(); actual != expected {
+		t.Errorf("incorrect normalized file\nexpected:\n%s\nactual:\n%s", expected, actual)
+	}
+}
+
+func TestNormalizeBinary(t *testing.T) {
+	const patch = `diff --git a/image.png b/image.png
+index 1c23fcc..40a1b33 100644
+Binary files a/image.png and b/image.png differ
+`
+
+	files, _, err := ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	f := files[0]
+	if err := Normalize(f); err != nil {
+		t.Fatalf("unexpected error normalizing file: %v", err)
+	}
+	if actual := f.String(); actual != patch {
+		t.Errorf("incorrect normalized file\nexpected:\n%s\nactual:\n%s", patch, actual)
+	}
+}
@@ -0,0 +1,191 @@
+package gitdiff
+
+import (
+	"sync"
+)
+
+// WithWorkers configures ApplyFS and ApplyToMap to apply up to n independent
+// files at the same time. Files are independent if they do not have any old
+// or new names in common, including the names of reject files, so renames,
+// copies, and files changed more than once in a patch still apply in order.
+// Files in a directory with the name of another file in the patch also apply
+// in order, because the file may replace the directory or be replaced by it.
+// The result and any error are the same as when applying the files in order:
+// if files do not apply, the error is for the first of them in the patch.
+//
+// Functions passed to WithApplyProgress may be called concurrently when n is
+// greater than 1. Apply and ApplyCheck ignore this option.
+func WithWorkers(n int) ApplierOption {
+	return func(a *Applier) {
+		a.workers = n
+	}
+}
+
+// applyFiles applies files to the current state, using up to workers
+// goroutines for independent files.
+func (s *fsState) applyFiles(files []*File, workers int, reverse bool, opts []ApplierOption) error {
+	if workers <= 1 || len(files) <= 1 {
+		for _, f := range files {
+			if err := s.applyFile(f, reverse, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	groups := applyGroups(files)
+	results := make([]groupResult, len(groups))
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(groups); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = s.applyGroup(files, groups[i], reverse, opts)
+			}
+		}()
+	}
+	for i := range groups {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	// report the error from the first file in the patch, like applying in
+	// order, before merging any results
+	failed := -1
+	for i, r := range results {
+		if r.err != nil && (failed < 0 || r.failed < results[failed].failed) {
+			failed = i
+		}
+	}
+	if failed >= 0 {
+		return results[failed].err
+	}
+
+	for _, r := range results {
+		s.merge(r.state)
+	}
+	return nil
+}
+
+// groupResult is the result of applying a group of files.
+type groupResult struct {
+	state  *fsState
+	failed int
+	err    error
+}
+
+// applyGroup applies the files at the given indices in order to a new state
+// that starts with the files of s that they change.
+func (s *fsState) applyGroup(files []*File, indices []int, reverse bool, opts []ApplierOption) groupResult {
+	sub := &fsState{fsys: s.fsys, files: make(map[string]*fsFile), unsafePaths: s.unsafePaths}
+	for _, i := range indices {
+		for _, name := range fileNames(files[i]) {
+			if f, ok := s.files[name]; ok {
+				c := *f
+				sub.files[name] = &c
+			}
+		}
+	}
+
+	for _, i := range indices {
+		if err := sub.applyFile(files[i], reverse, opts); err != nil {
+			return groupResult{failed: i, err: err}
+		}
+	}
+	return groupResult{state: sub}
+}
+
+// merge adds the files of sub to s. The files of sub must not be modified by
+// any other state merged into s.
+func (s *fsState) merge(sub *fsState) {
+	for name, f := range sub.files {
+		s.files[name] = f
+	}
+	for _, name := range sub.order {
+		if s.original == nil {
+			s.original = make(map[string]fsFile)
+		}
+		s.original[name] = sub.original[name]
+		s.order = append(s.order, name)
+	}
+}
+
+// fileNames returns the names that applying f can read or change.
+func fileNames(f *File) []string {
+	var names []string
+	for _, name := range []string{f.OldName, f.NewName} {
+		if name != "" {
+			names = append(names, name, name+".rej")
+		}
+	}
+	return names
+}
+
+// applyGroups splits files into groups of files that share names, so that
+// files in different groups can apply independently. Each group lists the
+// indices of its files in order and groups are in order of their first file.
+func applyGroups(files []*File) [][]int {
+	parent := make([]int, len(files))
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	union := func(i, j int) {
+		// keep the earliest file as the root of each group
+		ri, rj := find(i), find(j)
+		if ri < rj {
+			parent[rj] = ri
+		} else {
+			parent[ri] = rj
+		}
+	}
+
+	owners := make(map[string]int)
+	for i, f := range files {
+		parent[i] = i
+		for _, name := range fileNames(f) {
+			if j, ok := owners[name]; ok {
+				union(i, j)
+			} else {
+				owners[name] = i
+			}
+		}
+	}
+
+	// files in a directory depend on any file with the name of the
+	// directory, which may replace it or be replaced by it
+	for i, f := range files {
+		for _, name := range fileNames(f) {
+			for k := 0; k < len(name); k++ {
+				if name[k] != '/' {
+					continue
+				}
+				if j, ok := owners[name[:k]]; ok {
+					union(i, j)
+				}
+			}
+		}
+	}
+
+	var groups [][]int
+	index := make(map[int]int)
+	for i := range files {
+		root := find(i)
+		g, ok := index[root]
+		if !ok {
+			g = len(groups)
+			index[root] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
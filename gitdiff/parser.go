@@ -5,18 +5,33 @@ package gitdiff
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"path"
+	"regexp"
 	"strings"
 )
 
-const commitPrefix = "commit"
+// Parse parses a patch with changes to one or more files and sends the files
+// on the returned channel. If an error occurs while parsing, it sends all
+// files parsed before the error and closes the channel. Use ParseAll to get
+// the files, the content before the first file, and any error directly.
+//
+// By default, Parse skips files with invalid headers and stops at the first
+// invalid fragment without reporting either problem. Use WithErrorHandler to
+// receive these errors and WithStrict to stop at the first invalid file.
+func Parse(r io.Reader, opts ...ParseOption) (<-chan *File, error) {
+	return ParseContext(context.Background(), r, opts...)
+}
 
-// Parse parses a patch with changes to one or more files. Any content before
-// the first file is returned as the second value. If an error occurs while
-// parsing, it returns all files parsed before the error.
-func Parse(r io.Reader) (<-chan *File, error) {
-	p := newParser(r)
+// ParseContext is like Parse, but stops parsing and closes the channel of
+// files when ctx is done, so callers can stop reading from the channel
+// without leaking the parsing goroutine. ParseContext does not interrupt
+// reads from r that are in progress when ctx is done.
+func ParseContext(ctx context.Context, r io.Reader, opts ...ParseOption) (<-chan *File, error) {
+	p := newParser(r, opts...)
 	out := make(chan *File)
 
 	if err := p.Next(); err != nil {
@@ -29,45 +44,250 @@ func Parse(r io.Reader) (<-chan *File, error) {
 
 	go func() {
 		defer close(out)
+		_, _ = p.ParseFiles(func(f *File) error {
+			select {
+			case out <- f:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	return out, nil
+}
+
+// ParseAll parses a patch with changes to one or more files. It returns the
+// parsed files and any content before the first file. Unlike Parse, ParseAll
+// stops at the first error, including errors in file headers, and returns
+// the error along with all files parsed before it.
+func ParseAll(r io.Reader, opts ...ParseOption) ([]*File, string, error) {
+	files, preamble, _, err := parseAll(r, opts)
+	return files, preamble, err
+}
+
+// parseAll is like ParseAll, but also returns any content after the last
+// file that is not part of a file.
+func parseAll(r io.Reader, opts []ParseOption) (files []*File, preamble, trailing string, err error) {
+	p := newParser(r, append(opts[:len(opts):len(opts)], WithStrict())...)
+
+	if err := p.Next(); err != nil {
+		if err == io.EOF {
+			return nil, "", "", nil
+		}
+		return nil, "", "", err
+	}
+
+	preamble, err = p.ParseFiles(func(f *File) error {
+		files = append(files, f)
+		return nil
+	})
+	return files, preamble, p.trailing, err
+}
+
+// ParseBytes is like ParseAll, but parses a patch that is already in memory.
+// It copies data once and does not allocate memory for each line of the
+// patch, so it is faster than ParseAll for large patches. The content of the
+// returned files shares memory with the copy of data.
+func ParseBytes(data []byte, opts ...ParseOption) ([]*File, string, error) {
+	return ParseAll(&lineScanner{s: string(data)}, opts...)
+}
 
-		ph := &PatchHeader{}
-		for {
-			file, pre, err := p.ParseNextFileHeader()
-			if err != nil {
-				if err == io.EOF {
-					return
-				}
-				p.Next()
-				continue
+// ParseFiles parses all files in the stream and calls emit with each one. It
+// returns any content before the first file and the error that stopped
+// parsing, if any. Unless the parser is strict, it skips files with
+// recoverable errors in their headers. If emit returns an error, parsing
+// stops and ParseFiles returns that error.
+func (p *parser) ParseFiles(emit func(*File) error) (preamble string, err error) {
+	ph := &PatchHeader{}
+	nfiles := 0
+	for found := false; ; {
+		start := p.offset()
+		file, pre, err := p.ParseNextFileHeader()
+		if err != nil {
+			if err == io.EOF {
+				return preamble, nil
 			}
+			p.handleError(err)
 
-			if strings.Contains(pre, commitPrefix) {
-				ph, _ = ParsePatchHeader(pre)
+			var perr *ParseError
+			if p.strict || !errors.As(err, &perr) || !(p.recoverError(perr) || perr.Recoverable()) {
+				return preamble, err
 			}
+			p.Next()
+			continue
+		}
 
-			if file == nil {
-				break
+		if !found {
+			preamble, found = pre, true
+		}
+		if hasCommitSeparator(pre) {
+			ph, _ = ParsePatchHeader(pre)
+			p.headerOffset, p.headerSize = start, p.fileOffset-start
+			if ph != nil && p.hashAlgorithm != HashUnknown {
+				ph.HashAlgorithm = p.hashAlgorithm
 			}
+		}
 
-			for _, fn := range []func(*File) (int, error){
-				p.ParseTextFragments,
-				p.ParseBinaryFragments,
-			} {
-				n, err := fn(file)
-				if err != nil {
-					return
-				}
-				if n > 0 {
-					break
-				}
+		if file == nil {
+			return preamble, nil
+		}
+
+		if p.maxFiles > 0 && nfiles >= p.maxFiles {
+			err := p.limitErrorf(-1, LimitFiles, int64(p.maxFiles))
+			p.handleError(err)
+			return preamble, err
+		}
+		nfiles++
+		p.progress.start(file)
+
+		p.addDirectory(file)
+		p.rewritePaths(file)
+		if !p.usePath(file) {
+			if err := p.SkipFragments(); err != nil {
+				p.handleError(err)
+				return preamble, err
 			}
+			continue
+		}
 
-			file.PatchHeader = ph
-			out <- file
+		parse := p.parseFragments
+		if p.lazy && !p.wordDiff {
+			parse = p.deferFragments
 		}
-	}()
+		if p.skipGeneratedFile(file) {
+			parse = func(*File) error { return p.SkipFragments() }
+		}
+		if err := parse(file); err != nil {
+			p.handleError(err)
 
-	return out, nil
+			var perr *ParseError
+			if !errors.As(err, &perr) || !p.recoverError(perr) {
+				return preamble, err
+			}
+		}
+
+		file.PatchHeader = ph
+		p.progress.file = nil
+		if !p.classify(file) {
+			continue
+		}
+		if p.compact {
+			file.Compact()
+		}
+		if err := emit(file); err != nil {
+			p.handleError(err)
+			return preamble, err
+		}
+	}
+}
+
+// parseFragments parses the fragments of f, which may be of any type.
+func (p *parser) parseFragments(f *File) error {
+	parseText := p.ParseTextFragments
+	if p.wordDiff {
+		parseText = p.ParseWordFragments
+	}
+
+	for _, fn := range []func(*File) (int, error){
+		parseText,
+		p.ParseContextFragments,
+		p.ParseCombinedFragments,
+		p.ParseBinaryFragments,
+	} {
+		n, err := fn(f)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			break
+		}
+	}
+
+	parseSubmoduleFragments(f)
+	return nil
+}
+
+// ParseOption configures the behavior of Parse.
+type ParseOption func(*parser)
+
+// WithErrorHandler configures Parse to call fn with each error that occurs
+// while parsing, including the context error if ParseContext stops early.
+// Errors for files that Parse skips are recoverable *ParseError values. After
+// any other error, Parse stops and closes the channel of files. Parse calls
+// fn from the goroutine that sends files.
+func WithErrorHandler(fn func(error)) ParseOption {
+	return func(p *parser) {
+		p.onError = fn
+	}
+}
+
+// WithStrict configures Parse to stop at the first file with an invalid
+// header instead of skipping it.
+func WithStrict() ParseOption {
+	return func(p *parser) {
+		p.strict = true
+	}
+}
+
+// WithStripComponents configures Parse to remove n leading components from the
+// file names in each header, like "git apply -p<n>". By default, Parse removes
+// one component from the names in Git patches, like the "a/" and "b/" prefixes,
+// and does not remove any components from the names in traditional patches.
+// Names in the "rename" and "copy" lines of Git patches do not have prefixes,
+// so Parse removes one fewer component from these names.
+func WithStripComponents(n int) ParseOption {
+	return func(p *parser) {
+		if n >= 0 {
+			p.strip = n
+		}
+	}
+}
+
+// WithDirectory configures Parse to add dir to the start of all file names,
+// like "git apply --directory". Parse adds the directory after removing any
+// prefixes from the names and before checking names against the patterns of
+// WithIncludePaths and WithExcludePaths.
+func WithDirectory(dir string) ParseOption {
+	return func(p *parser) {
+		p.directory = dir
+	}
+}
+
+// WithPathRewrite configures Parse to replace the file names in each header
+// with the result of calling fn with the name, like moving the files of a
+// vendored project into "third_party/foo/". Parse calls fn for the old and new
+// names of every file, including the sources and targets of renames and
+// copies, but not for the missing names of new and deleted files.
+// The rewritten names are used everywhere, including when formatting or
+// applying the files. Parse rewrites names after WithStripComponents and
+// WithDirectory and before checking names against the patterns of
+// WithIncludePaths and WithExcludePaths.
+func WithPathRewrite(fn func(name string) string) ParseOption {
+	return func(p *parser) {
+		p.rewrite = fn
+	}
+}
+
+// WithRecount configures Parse to ignore the line counts in the headers of
+// text fragments and count the lines of each fragment instead, like "git
+// apply --recount". A fragment ends at the first line that does not start
+// with a space, "-", "+", or a backslash. Use this option to parse patches
+// edited by hand, which often have incorrect counts.
+func WithRecount() ParseOption {
+	return func(p *parser) {
+		p.recount = true
+	}
+}
+
+// WithHashAlgorithm configures Parse to expect object IDs computed with alg,
+// instead of detecting the algorithm from the length of the IDs. Files with
+// object IDs that are too long for alg are invalid, and all files and patch
+// headers have the given algorithm.
+func WithHashAlgorithm(alg HashAlgorithm) ParseOption {
+	return func(p *parser) {
+		p.hashAlgorithm = alg
+	}
 }
 
 // TODO(bkeyes): consider exporting the parser type with configuration
@@ -89,16 +309,119 @@ type stringReader interface {
 type parser struct {
 	r stringReader
 
+	strict  bool
+	onError func(error)
+
+	pathRules  []pathRule
+	hasInclude bool
+
+	strip     int
+	directory string
+	rewrite   func(string) string
+
+	classifiers    []Classifier
+	includeLabels  []string
+	excludeLabels  []string
+	skipGenerated  bool
+	generatedAttrs *Attributes
+
+	recount  bool
+	wordDiff bool
+	compact  bool
+
+	positions bool
+
+	hashAlgorithm HashAlgorithm
+	transform     Transformer
+
+	fragmentPattern  *regexp.Regexp
+	fragmentGroups   [3]int
+	timestampPattern *regexp.Regexp
+
+	maxLineLength int
+	maxFragments  int
+	maxFiles      int
+	maxBinarySize int64
+
+	progress progressReporter
+
+	lazy       bool
+	lazyConfig *parser
+	lazyRecord *strings.Builder
+	indexOnly  bool
+
+	trailing string
+
+	fileOffset   int64
+	headerOffset int64
+	headerSize   int64
+
+	lenient   bool
+	recovered []*ParseError
+
 	eof    bool
 	lineno int64
+	nread  int64
+	nbytes int64
 	lines  [3]string
+	sizes  [3]int
+	prev   int
+}
+
+func newParser(r io.Reader, opts ...ParseOption) *parser {
+	p := &parser{strip: -1}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if ls, ok := r.(*lineScanner); ok {
+		ls.max = p.maxLineLength
+		p.r = ls
+	} else if p.maxLineLength > 0 {
+		br, ok := r.(*bufio.Reader)
+		if !ok {
+			br = bufio.NewReader(r)
+		}
+		p.r = &limitedLineReader{r: br, max: p.maxLineLength}
+	} else if sr, ok := r.(stringReader); ok {
+		p.r = sr
+	} else {
+		p.r = &internReader{r: bufio.NewReader(r)}
+	}
+	return p
 }
 
-func newParser(r io.Reader) *parser {
-	if r, ok := r.(stringReader); ok {
-		return &parser{r: r}
+// addDirectory adds the directory configured by WithDirectory to the names
+// of f.
+func (p *parser) addDirectory(f *File) {
+	if p.directory == "" {
+		return
+	}
+	if f.OldName != "" {
+		f.OldName = path.Join(p.directory, f.OldName)
+	}
+	if f.NewName != "" {
+		f.NewName = path.Join(p.directory, f.NewName)
+	}
+}
+
+// rewritePaths replaces the names of f using the function configured by
+// WithPathRewrite.
+func (p *parser) rewritePaths(f *File) {
+	if p.rewrite == nil {
+		return
+	}
+	if f.OldName != "" {
+		f.OldName = p.rewrite(f.OldName)
+	}
+	if f.NewName != "" {
+		f.NewName = p.rewrite(f.NewName)
+	}
+}
+
+func (p *parser) handleError(err error) {
+	if p.onError != nil {
+		p.onError(err)
 	}
-	return &parser{r: bufio.NewReader(r)}
 }
 
 // Next advances the parser by one line. It returns any error encountered while
@@ -107,6 +430,9 @@ func (p *parser) Next() error {
 	if p.eof {
 		return io.EOF
 	}
+	if p.lazyRecord != nil {
+		p.lazyRecord.WriteString(p.lines[0])
+	}
 
 	if p.lineno == 0 {
 		// on first call to next, need to shift in all lines
@@ -130,12 +456,33 @@ func (p *parser) Next() error {
 	return nil
 }
 
-func (p *parser) shiftLines() (err error) {
+func (p *parser) shiftLines() error {
+	p.prev = p.sizes[0]
 	for i := 0; i < len(p.lines)-1; i++ {
 		p.lines[i] = p.lines[i+1]
+		p.sizes[i] = p.sizes[i+1]
+	}
+	p.sizes[len(p.sizes)-1] = 0
+
+	line, err := p.r.ReadString('\n')
+	var lerr *LimitExceededError
+	if errors.As(err, &lerr) {
+		return &ParseError{Line: p.nread + 1, Offset: p.nbytes, Kind: KindLimitExceeded, err: fmt.Errorf("%w", lerr)}
+	}
+	if line != "" {
+		p.nread++
+		p.nbytes += int64(len(line))
+		p.sizes[len(p.sizes)-1] = len(line)
+		p.progress.add(len(line))
 	}
-	p.lines[len(p.lines)-1], err = p.r.ReadString('\n')
-	return
+	if p.transform != nil && line != "" {
+		var terr error
+		if line, terr = transformLine(p.transform, line); terr != nil {
+			return terr
+		}
+	}
+	p.lines[len(p.lines)-1] = line
+	return err
 }
 
 // Line returns a line from the parser without advancing it. A delta of 0
@@ -147,7 +494,162 @@ func (p *parser) Line(delta uint) string {
 	return p.lines[delta]
 }
 
-// Errorf generates an error and appends the current line information.
-func (p *parser) Errorf(delta int64, msg string, args ...interface{}) error {
-	return fmt.Errorf("gitdiff: line %d: %s", p.lineno+delta, fmt.Sprintf(msg, args...))
+// Errorf generates a *ParseError of the given kind for the line at delta
+// from the current line. Use the %w verb in msg to wrap an underlying error.
+func (p *parser) Errorf(delta int64, kind ParseErrorKind, msg string, args ...interface{}) *ParseError {
+	e := &ParseError{
+		Line:   p.lineno + delta,
+		Offset: -1,
+		Kind:   kind,
+		err:    fmt.Errorf(msg, args...),
+	}
+	var cerr *columnError
+	if errors.As(e.err, &cerr) {
+		e.Column = cerr.col + 1
+	}
+	switch {
+	case delta == -1:
+		e.Offset = p.offset() - int64(p.prev)
+	case delta >= 0 && delta < int64(len(p.lines)):
+		e.Text = p.lines[delta]
+		e.Offset = p.offset()
+		for _, size := range p.sizes[:delta] {
+			e.Offset += int64(size)
+		}
+	}
+	return e
+}
+
+// ParseError is the error returned when parsing a patch fails. It includes
+// the location of the invalid content and the kind of problem.
+type ParseError struct {
+	// Line is the one-indexed line number of the invalid content
+	Line int64
+	// Column is the one-indexed column of the invalid content in the line, or
+	// zero if the error applies to the whole line or the column is unknown
+	Column int
+	// Offset is the byte offset of the start of the invalid line in the
+	// patch, or -1 if it is not available
+	Offset int64
+	// Text is the content of the invalid line, if it is available
+	Text string
+	// Kind describes the problem with the content
+	Kind ParseErrorKind
+
+	err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("gitdiff: line %d: %v", e.Line, e.err)
+}
+
+// Unwrap returns the wrapped error, if any.
+func (e *ParseError) Unwrap() error {
+	return errors.Unwrap(e.err)
+}
+
+// columnError is an error for invalid content that starts at a zero-indexed
+// column of a line.
+type columnError struct {
+	col int
+	err error
+}
+
+func (e *columnError) Error() string {
+	return e.err.Error()
+}
+
+func (e *columnError) Unwrap() error {
+	return e.err
+}
+
+// atColumn returns err with its column moved right by col, for errors from
+// parsing the part of a line that starts at col. If err has no column, the
+// column is col. It returns nil if err is nil.
+func atColumn(err error, col int) error {
+	if err == nil {
+		return nil
+	}
+	var cerr *columnError
+	if errors.As(err, &cerr) {
+		cerr.col += col
+		return err
+	}
+	return &columnError{col: col, err: err}
+}
+
+// Recoverable returns true if the parser can skip the invalid content and
+// continue parsing the next file. Errors in file headers are recoverable,
+// while errors in the fragments of a file are not.
+//
+// Errors for exceeded limits are never recoverable.
+func (e *ParseError) Recoverable() bool {
+	switch e.Kind {
+	case KindFileHeader, KindInvalidMode, KindOrphanFragment:
+		return true
+	}
+	return false
+}
+
+// ParseErrorKind describes the kind of problem that caused a ParseError.
+type ParseErrorKind int
+
+const (
+	// KindUnknown indicates an error that does not have a specific kind
+	KindUnknown ParseErrorKind = iota
+	// KindFileHeader indicates a malformed file header
+	KindFileHeader
+	// KindInvalidMode indicates an invalid file mode in a file header
+	KindInvalidMode
+	// KindOrphanFragment indicates a fragment that is not part of a file
+	KindOrphanFragment
+	// KindFragmentHeader indicates a malformed fragment header
+	KindFragmentHeader
+	// KindInvalidLine indicates a fragment line with an invalid operation
+	KindInvalidLine
+	// KindTruncatedFragment indicates a fragment with fewer or more lines
+	// than reported in its header. If the patch ends before the end of the
+	// fragment, the wrapped error is a *TruncatedPatchError.
+	KindTruncatedFragment
+	// KindEmptyFragment indicates a fragment that does not contain changes
+	KindEmptyFragment
+	// KindInconsistentFragment indicates a fragment that is inconsistent
+	// with its file, like a fragment with old content in a new file
+	KindInconsistentFragment
+	// KindBinaryData indicates missing or corrupt binary patch data
+	KindBinaryData
+	// KindStat indicates a malformed line in the output of "git diff --stat"
+	// or "git diff --numstat"
+	KindStat
+	// KindLimitExceeded indicates a patch that exceeds a limit set by a
+	// ParseOption. The wrapped error is a *LimitExceededError.
+	KindLimitExceeded
+)
+
+func (k ParseErrorKind) String() string {
+	switch k {
+	case KindFileHeader:
+		return "file header"
+	case KindInvalidMode:
+		return "invalid mode"
+	case KindOrphanFragment:
+		return "orphan fragment"
+	case KindFragmentHeader:
+		return "fragment header"
+	case KindInvalidLine:
+		return "invalid line"
+	case KindTruncatedFragment:
+		return "truncated fragment"
+	case KindEmptyFragment:
+		return "empty fragment"
+	case KindInconsistentFragment:
+		return "inconsistent fragment"
+	case KindBinaryData:
+		return "binary data"
+	case KindStat:
+		return "stat"
+	case KindLimitExceeded:
+		return "limit exceeded"
+	}
+	return "unknown"
 }
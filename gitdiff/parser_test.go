@@ -2,12 +2,17 @@ package gitdiff
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLineOperations(t *testing.T) {
@@ -271,6 +276,8 @@ Date:	Tue Apr 2 22:30:00 2019 -0700
 			Output: &File{
 				OldName: "file.txt",
 				NewName: "file.txt",
+				OldTime: time.Date(2019, 4, 2, 5, 58, 14, 833597918, time.UTC),
+				NewTime: time.Date(2019, 4, 2, 5, 58, 14, 833597918, time.UTC),
 			},
 			Preamble: "\n",
 		},
@@ -318,6 +325,7 @@ a wild fragment appears?
 			if test.Preamble != pre {
 				t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", test.Preamble, pre)
 			}
+			utcTimes(f)
 			if !reflect.DeepEqual(test.Output, f) {
 				t.Errorf("incorrect file\nexpected: %+v\n  actual: %+v", test.Output, f)
 			}
@@ -325,7 +333,358 @@ a wild fragment appears?
 	}
 }
 
+func TestParseError(t *testing.T) {
+	tests := map[string]struct {
+		Input       string
+		Parse       func(p *parser) error
+		Line        int64
+		Column      int
+		Text        string
+		Kind        ParseErrorKind
+		Recoverable bool
+	}{
+		"invalidMode": {
+			Input: "diff --git a/file.txt b/file.txt\nold mode 10064x\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseGitFileHeader()
+				return err
+			},
+			Line:        2,
+			Column:      10,
+			Text:        "old mode 10064x\n",
+			Kind:        KindInvalidMode,
+			Recoverable: true,
+		},
+		"invalidIndexMode": {
+			Input: "diff --git a/file.txt b/file.txt\nindex 1c23fcc..40a1b33 10064x\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseGitFileHeader()
+				return err
+			},
+			Line:        2,
+			Column:      24,
+			Text:        "index 1c23fcc..40a1b33 10064x\n",
+			Kind:        KindInvalidMode,
+			Recoverable: true,
+		},
+		"invalidOID": {
+			Input: "diff --git a/file.txt b/file.txt\nindex 1c23fcc..40a1x33 100644\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseGitFileHeader()
+				return err
+			},
+			Line:        2,
+			Column:      20,
+			Text:        "index 1c23fcc..40a1x33 100644\n",
+			Kind:        KindFileHeader,
+			Recoverable: true,
+		},
+		"longOID": {
+			Input: "diff --git a/file.txt b/file.txt\nindex 1c23fcc..4d15f4fb4c6bd4ae3e5b1b6ed4ad8c2f6b8d4e1a000 100644\n",
+			Parse: func(p *parser) error {
+				p.hashAlgorithm = HashSHA1
+				_, err := p.ParseGitFileHeader()
+				return err
+			},
+			Line:        2,
+			Column:      16,
+			Text:        "index 1c23fcc..4d15f4fb4c6bd4ae3e5b1b6ed4ad8c2f6b8d4e1a000 100644\n",
+			Kind:        KindFileHeader,
+			Recoverable: true,
+		},
+		"headerName": {
+			Input: "diff --git a/file.txt\"b/file.txt\"\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseGitFileHeader()
+				return err
+			},
+			Line:        1,
+			Column:      21,
+			Text:        "diff --git a/file.txt\"b/file.txt\"\n",
+			Kind:        KindFileHeader,
+			Recoverable: true,
+		},
+		"combinedMode": {
+			Input: "diff --cc file.txt\nmode 100644,10064x..100644\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseCombinedFileHeader()
+				return err
+			},
+			Line:        2,
+			Column:      13,
+			Text:        "mode 100644,10064x..100644\n",
+			Kind:        KindInvalidMode,
+			Recoverable: true,
+		},
+		"combinedOID": {
+			Input: "diff --cc file.txt\nindex 1c23fcc,40a1x33..7b3c1d1\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseCombinedFileHeader()
+				return err
+			},
+			Line:        2,
+			Column:      19,
+			Text:        "index 1c23fcc,40a1x33..7b3c1d1\n",
+			Kind:        KindFileHeader,
+			Recoverable: true,
+		},
+		"fileHeader": {
+			Input: "diff --git a/file.txt b/file.txt\nrename from a.txt\n--- a/b.txt\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseGitFileHeader()
+				return err
+			},
+			Line:        3,
+			Column:      5,
+			Text:        "--- a/b.txt\n",
+			Kind:        KindFileHeader,
+			Recoverable: true,
+		},
+		"fragmentHeader": {
+			Input: "@@ -1,2 +1a @@\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseTextFragmentHeader()
+				return err
+			},
+			Line: 1,
+			Text: "@@ -1,2 +1a @@\n",
+			Kind: KindFragmentHeader,
+		},
+		"invalidLine": {
+			Input: " context\n*invalid\n",
+			Parse: func(p *parser) error {
+				return p.ParseTextChunk(&TextFragment{OldLines: 2, NewLines: 2})
+			},
+			Line:   2,
+			Column: 1,
+			Text:   "*invalid\n",
+			Kind:   KindInvalidLine,
+		},
+		"truncatedFragment": {
+			Input: "@@ -1,3 +1,3 @@\n context\n-old\n+new\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseTextFragments(&File{})
+				return err
+			},
+			Line: 1,
+			Kind: KindTruncatedFragment,
+		},
+		"emptyFragment": {
+			Input: " context\n",
+			Parse: func(p *parser) error {
+				return p.ParseTextChunk(&TextFragment{OldLines: 1, NewLines: 1})
+			},
+			Line: 2,
+			Kind: KindEmptyFragment,
+		},
+		"orphanFragment": {
+			Input: "@@ -1 +1 @@\n-old\n+new\n",
+			Parse: func(p *parser) error {
+				_, _, err := p.ParseNextFileHeader()
+				return err
+			},
+			Line:        1,
+			Kind:        KindOrphanFragment,
+			Recoverable: true,
+		},
+		"binaryData": {
+			Input: "GIT binary patch\nliteral 5\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseBinaryFragments(&File{})
+				return err
+			},
+			Line: 3,
+			Kind: KindBinaryData,
+		},
+		"binarySize": {
+			Input: "GIT binary patch\nliteral 5x\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseBinaryFragments(&File{})
+				return err
+			},
+			Line:   2,
+			Column: 9,
+			Text:   "literal 5x\n",
+			Kind:   KindBinaryData,
+		},
+		"binaryLength": {
+			Input: "GIT binary patch\nliteral 5\n0zcmZ?\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseBinaryFragments(&File{})
+				return err
+			},
+			Line:   3,
+			Column: 1,
+			Text:   "0zcmZ?\n",
+			Kind:   KindBinaryData,
+		},
+		"binaryByte": {
+			Input: "GIT binary patch\nliteral 5\nDzcm\"?\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseBinaryFragments(&File{})
+				return err
+			},
+			Line:   3,
+			Column: 5,
+			Text:   "Dzcm\"?\n",
+			Kind:   KindBinaryData,
+		},
+		"binaryLine": {
+			Input: "GIT binary patch\nliteral 5\nDzcm\n",
+			Parse: func(p *parser) error {
+				_, err := p.ParseBinaryFragments(&File{})
+				return err
+			},
+			Line: 3,
+			Text: "Dzcm\n",
+			Kind: KindBinaryData,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTestParser(test.Input, true)
+
+			err := test.Parse(p)
+			if err == nil {
+				t.Fatal("expected error parsing, but got nil")
+			}
+
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected *ParseError, but got %T: %v", err, err)
+			}
+			if perr.Line != test.Line {
+				t.Errorf("incorrect line: expected %d, actual %d", test.Line, perr.Line)
+			}
+			if perr.Column != test.Column {
+				t.Errorf("incorrect column: expected %d, actual %d", test.Column, perr.Column)
+			}
+			if perr.Text != test.Text {
+				t.Errorf("incorrect text: expected %q, actual %q", test.Text, perr.Text)
+			}
+			if perr.Kind != test.Kind {
+				t.Errorf("incorrect kind: expected %v, actual %v", test.Kind, perr.Kind)
+			}
+			if perr.Recoverable() != test.Recoverable {
+				t.Errorf("incorrect recoverable: expected %t, actual %t", test.Recoverable, perr.Recoverable())
+			}
+		})
+	}
+}
+
 func TestParse(t *testing.T) {
+	for name, test := range parseTests() {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(test.InputFile)
+			if err != nil {
+				t.Fatalf("unexpected error opening input file: %v", err)
+			}
+
+			out, err := Parse(f)
+			if test.Err {
+				if err == nil || err == io.EOF {
+					t.Fatalf("expected error parsing patch, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			files := collectFiles(out)
+
+			header, err := ParsePatchHeader(test.Preamble)
+			if err != nil {
+				t.Fatalf("unexpected error parsing preamble: %v", err)
+			}
+
+			if len(test.Output) != len(files) {
+				t.Fatalf("incorrect number of parsed files: expected %d, actual %d", len(test.Output), len(files))
+			}
+			// Parse does not return the preamble, but sets the header parsed
+			// from it on each file
+			for i, f := range files {
+				if !reflect.DeepEqual(header, f.PatchHeader) {
+					t.Errorf("incorrect preamble header for file %d\nexpected: %+v\n  actual: %+v", i, header, f.PatchHeader)
+				}
+			}
+			for i := range test.Output {
+				test.Output[i].PatchHeader = header
+				if !reflect.DeepEqual(test.Output[i], files[i]) {
+					exp, _ := json.MarshalIndent(test.Output[i], "", "  ")
+					act, _ := json.MarshalIndent(files[i], "", "  ")
+					t.Errorf("incorrect file at position %d\nexpected: %s\n  actual: %s", i, exp, act)
+				}
+			}
+		})
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	for name, test := range parseTests() {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(test.InputFile)
+			if err != nil {
+				t.Fatalf("unexpected error opening input file: %v", err)
+			}
+
+			files, pre, err := ParseAll(f)
+			if test.Err {
+				if err == nil || err == io.EOF {
+					t.Fatalf("expected error parsing patch, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			header, err := ParsePatchHeader(test.Preamble)
+			if err != nil {
+				t.Fatalf("unexpected error parsing preamble: %v", err)
+			}
+
+			if len(test.Output) != len(files) {
+				t.Fatalf("incorrect number of parsed files: expected %d, actual %d", len(test.Output), len(files))
+			}
+			if test.Preamble != pre {
+				t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", test.Preamble, pre)
+			}
+			for i := range test.Output {
+				test.Output[i].PatchHeader = header
+				if !reflect.DeepEqual(test.Output[i], files[i]) {
+					exp, _ := json.MarshalIndent(test.Output[i], "", "  ")
+					act, _ := json.MarshalIndent(files[i], "", "  ")
+					t.Errorf("incorrect file at position %d\nexpected: %s\n  actual: %s", i, exp, act)
+				}
+			}
+		})
+	}
+}
+
+func TestParseAllOptions(t *testing.T) {
+	// the options have room for another option, which ParseAll must not use
+	opts := make([]ParseOption, 1, 2)
+	opts[0] = WithErrorHandler(nil)
+
+	if _, _, err := ParseAll(strings.NewReader(readTestPatch(t, "one_file.patch")), opts...); err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if opts[:2][1] != nil {
+		t.Errorf("ParseAll modified the array of the options")
+	}
+}
+
+// parseTest is a test case for TestParse and TestParseAll.
+type parseTest struct {
+	InputFile string
+	Output    []*File
+	Preamble  string
+	Err       bool
+}
+
+// parseTests returns new test cases for TestParse and TestParseAll.
+func parseTests() map[string]parseTest {
 	textFragments := []*TextFragment{
 		{
 			OldPosition: 3,
@@ -384,12 +743,7 @@ Date:   Tue Apr 2 22:55:40 2019 -0700
     A binary file with the first 10 fibonacci numbers.
 
 `
-	tests := map[string]struct {
-		InputFile string
-		Output    []*File
-		Preamble  string
-		Err       bool
-	}{
+	return map[string]parseTest{
 		"oneFile": {
 			InputFile: "testdata/one_file.patch",
 			Output: []*File{
@@ -430,13 +784,14 @@ Date:   Tue Apr 2 22:55:40 2019 -0700
 			InputFile: "testdata/new_binary_file.patch",
 			Output: []*File{
 				{
-					OldName:      "",
-					NewName:      "dir/ten.bin",
-					NewMode:      os.FileMode(0100644),
-					OldOIDPrefix: "0000000000000000000000000000000000000000",
-					NewOIDPrefix: "77b068ba48c356156944ea714740d0d5ca07bfec",
-					IsNew:        true,
-					IsBinary:     true,
+					OldName:       "",
+					NewName:       "dir/ten.bin",
+					NewMode:       os.FileMode(0100644),
+					OldOIDPrefix:  "0000000000000000000000000000000000000000",
+					NewOIDPrefix:  "77b068ba48c356156944ea714740d0d5ca07bfec",
+					HashAlgorithm: HashSHA1,
+					IsNew:         true,
+					IsBinary:      true,
 					BinaryFragment: &BinaryFragment{
 						Method: BinaryPatchLiteral,
 						Size:   40,
@@ -452,46 +807,223 @@ Date:   Tue Apr 2 22:55:40 2019 -0700
 			Preamble: binaryPreamble,
 		},
 	}
+}
+
+func collectFiles(out <-chan *File) []*File {
+	var files []*File
+	for f := range out {
+		files = append(files, f)
+	}
+	return files
+}
+
+func newTestParser(input string, init bool) *parser {
+	p := newParser(bytes.NewBufferString(input))
+	if init {
+		_ = p.Next()
+	}
+	return p
+}
+
+func TestParseErrorHandling(t *testing.T) {
+	const (
+		validFile = `diff --git a/%[1]s b/%[1]s
+index 9540595..30e6333 100644
+--- a/%[1]s
++++ b/%[1]s
+@@ -1 +1 @@
+-old
++new
+`
+		invalidHeader = `diff --git a/invalid.txt b/invalid.txt
+old mode 10064x
+new mode 100755
+`
+		invalidFragment = `diff --git a/invalid.txt b/invalid.txt
+index 9540595..30e6333 100644
+--- a/invalid.txt
++++ b/invalid.txt
+@@ -1 +1 @@
+*old
+`
+	)
+
+	tests := map[string]struct {
+		Patch   string
+		Options []ParseOption
+		Files   []string
+		Errors  []ParseErrorKind
+	}{
+		"skipInvalidHeader": {
+			Patch:  fmt.Sprintf(validFile, "first.txt") + invalidHeader + fmt.Sprintf(validFile, "second.txt"),
+			Files:  []string{"first.txt", "second.txt"},
+			Errors: []ParseErrorKind{KindInvalidMode},
+		},
+		"strictInvalidHeader": {
+			Patch:   fmt.Sprintf(validFile, "first.txt") + invalidHeader + fmt.Sprintf(validFile, "second.txt"),
+			Options: []ParseOption{WithStrict()},
+			Files:   []string{"first.txt"},
+			Errors:  []ParseErrorKind{KindInvalidMode},
+		},
+		"stopInvalidFragment": {
+			Patch:  fmt.Sprintf(validFile, "first.txt") + invalidFragment + fmt.Sprintf(validFile, "second.txt"),
+			Files:  []string{"first.txt"},
+			Errors: []ParseErrorKind{KindInvalidLine},
+		},
+	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			f, err := os.Open(test.InputFile)
-			if err != nil {
-				t.Fatalf("unexpected error opening input file: %v", err)
-			}
-
-			files, pre, err := Parse(f)
-			if test.Err {
-				if err == nil || err == io.EOF {
-					t.Fatalf("expected error parsing patch, but got %v", err)
+			var kinds []ParseErrorKind
+			opts := append([]ParseOption{WithErrorHandler(func(err error) {
+				var perr *ParseError
+				if !errors.As(err, &perr) {
+					t.Errorf("expected *ParseError, but got %T: %v", err, err)
+					return
 				}
-				return
-			}
+				kinds = append(kinds, perr.Kind)
+			})}, test.Options...)
+
+			out, err := Parse(strings.NewReader(test.Patch), opts...)
 			if err != nil {
 				t.Fatalf("unexpected error parsing patch: %v", err)
 			}
 
-			if len(test.Output) != len(files) {
-				t.Fatalf("incorrect number of parsed files: expected %d, actual %d", len(test.Output), len(files))
+			var names []string
+			for _, f := range collectFiles(out) {
+				names = append(names, f.NewName)
 			}
-			if test.Preamble != pre {
-				t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", test.Preamble, pre)
+
+			if !reflect.DeepEqual(test.Files, names) {
+				t.Errorf("incorrect files: expected %v, actual %v", test.Files, names)
 			}
-			for i := range test.Output {
-				if !reflect.DeepEqual(test.Output[i], files[i]) {
-					exp, _ := json.MarshalIndent(test.Output[i], "", "  ")
-					act, _ := json.MarshalIndent(files[i], "", "  ")
-					t.Errorf("incorrect file at position %d\nexpected: %s\n  actual: %s", i, exp, act)
-				}
+			if !reflect.DeepEqual(test.Errors, kinds) {
+				t.Errorf("incorrect errors: expected %v, actual %v", test.Errors, kinds)
 			}
 		})
 	}
 }
 
-func newTestParser(input string, init bool) *parser {
-	p := newParser(bytes.NewBufferString(input))
-	if init {
-		_ = p.Next()
+func TestParseAllError(t *testing.T) {
+	const patch = `preamble content
+diff --git a/first.txt b/first.txt
+index 9540595..30e6333 100644
+--- a/first.txt
++++ b/first.txt
+@@ -1 +1 @@
+-old
++new
+diff --git a/invalid.txt b/invalid.txt
+old mode 10064x
+`
+
+	files, pre, err := ParseAll(strings.NewReader(patch))
+
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Kind != KindInvalidMode {
+		t.Fatalf("expected invalid mode error, but got %v", err)
+	}
+	if len(files) != 1 || files[0].NewName != "first.txt" {
+		t.Errorf("expected the file before the error, but got %d files", len(files))
+	}
+	if pre != "preamble content\n" {
+		t.Errorf("incorrect preamble: %q", pre)
+	}
+}
+
+func TestParseRecount(t *testing.T) {
+	const patch = `diff --git a/first.txt b/first.txt
+index 9540595..30e6333 100644
+--- a/first.txt
++++ b/first.txt
+@@ -1,2 +1,9 @@ first
+ context
+-old
++new 1
++new 2
+@@ -10 +10 @@
+-old
+
+ context
+\ No newline at end of file
+diff --git a/second.txt b/second.txt
+index 9540595..30e6333 100644
+--- a/second.txt
++++ b/second.txt
+@@ -1 +1 @@
+-old
++new
+`
+
+	if _, _, err := ParseAll(strings.NewReader(patch)); err == nil {
+		t.Fatal("expected error parsing patch with incorrect counts, but got nil")
+	}
+
+	files, _, err := ParseAll(strings.NewReader(patch), WithRecount())
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("incorrect number of files: expected 2, actual %d", len(files))
+	}
+
+	frags := files[0].TextFragments
+	if len(frags) != 2 {
+		t.Fatalf("incorrect number of fragments: expected 2, actual %d", len(frags))
+	}
+	for i, frag := range frags {
+		if err := frag.Validate(); err != nil {
+			t.Errorf("fragment %d is invalid after recount: %v", i, err)
+		}
+	}
+	if frags[0].OldLines != 2 || frags[0].NewLines != 3 {
+		t.Errorf("incorrect counts for fragment 0: -%d +%d", frags[0].OldLines, frags[0].NewLines)
+	}
+	if frags[1].OldLines != 3 || frags[1].NewLines != 2 || frags[1].Lines[2].Line != "context" {
+		t.Errorf("incorrect fragment 1: %+v", frags[1])
+	}
+
+	files, _, err = ParseAll(strings.NewReader(patch), WithRecount(), WithExcludePaths("first.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch with excluded file: %v", err)
+	}
+	if len(files) != 1 || files[0].NewName != "second.txt" {
+		t.Errorf("incorrect files after excluding first file: %v", files)
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	var patch strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&patch, "diff --git a/file%[1]d.txt b/file%[1]d.txt\n@@ -1 +1 @@\n-old\n+new\n", i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 1)
+	out, err := ParseContext(ctx, strings.NewReader(patch.String()), WithErrorHandler(func(err error) {
+		errs <- err
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	if f := <-out; f == nil || f.NewName != "file0.txt" {
+		t.Fatalf("incorrect first file: %v", f)
+	}
+	cancel()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context error, but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("parsing did not stop after cancelling the context")
+	}
+
+	// the channel is closed after the parser stops
+	for range out {
 	}
-	return p
 }
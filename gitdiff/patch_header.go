@@ -5,7 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"mime/quotedprintable"
+	"mime"
 	"net/mail"
 	"strconv"
 	"strings"
@@ -25,32 +25,114 @@ const (
 type PatchHeader struct {
 	// The SHA of the commit the patch was generated from. Empty if the SHA is
 	// not included in the header.
-	SHA string
+	SHA string `json:"sha,omitempty"`
+
+	// HashAlgorithm is the algorithm of the SHA, detected from its length.
+	HashAlgorithm HashAlgorithm `json:"hash_algorithm,omitempty"`
 
 	// The author details of the patch. If these details are not included in
 	// the header, Author is nil and AuthorDate is the zero time.
-	Author     *PatchIdentity
-	AuthorDate time.Time
+	Author     *PatchIdentity `json:"author,omitempty"`
+	AuthorDate time.Time      `json:"author_date,omitempty"`
 
 	// The committer details of the patch. If these details are not included in
 	// the header, Committer is nil and CommitterDate is the zero time.
-	Committer     *PatchIdentity
-	CommitterDate time.Time
+	Committer     *PatchIdentity `json:"committer,omitempty"`
+	CommitterDate time.Time      `json:"committer_date,omitempty"`
 
 	// The title and body of the commit message describing the changes in the
 	// patch. Empty if no message is included in the header.
-	Title string
-	Body  string
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
 
 	// If the preamble looks like an email, ParsePatchHeader will
 	// remove prefixes such as `Re: ` and `[PATCH v3 5/17]` from the
 	// Title and place them here.
-	SubjectPrefix string
+	SubjectPrefix string `json:"subject_prefix,omitempty"`
+
+	// If SubjectPrefix contains a patch prefix like `[PATCH v3 5/17]`,
+	// Series describes the position of the patch in its series. Nil if the
+	// subject has no patch prefix.
+	Series *PatchSeries `json:"series,omitempty"`
 
 	// If the preamble looks like an email, and it contains a `---`
 	// line, that line will be removed and everything after it will be
 	// placed in BodyAppendix.
-	BodyAppendix string
+	BodyAppendix string `json:"body_appendix,omitempty"`
+
+	// The trailers from the last paragraph of the body, like
+	// `Signed-off-by: Name <email>`, in the order they appear. The
+	// trailers are not removed from Body.
+	Trailers []Trailer `json:"trailers,omitempty"`
+
+	// If the preamble looks like an email that declares a charset in its
+	// Content-Type header, Charset is the declared name, like "ISO-8859-1".
+	// The other fields contain the text of the preamble without conversion;
+	// use WithTransform to decode patches that are not UTF-8.
+	Charset string `json:"charset,omitempty"`
+
+	// The notes attached to the commit, from the `Notes:` sections added by
+	// `git log` and `git format-patch --notes`. The notes are not included
+	// in Body or BodyAppendix.
+	Notes []Note `json:"notes,omitempty"`
+
+	// If the preamble looks like an email with a signature, like the Git
+	// version that `git format-patch` adds after a `-- ` line, Signature is
+	// the text after that line. The signature is not included in Body or
+	// BodyAppendix. ParseMailbox also finds signatures that follow the
+	// files of a patch.
+	Signature string `json:"signature,omitempty"`
+
+	// The base tree information added by `git format-patch --base`, from
+	// the `base-commit:` line and any `prerequisite-patch-id:` lines. The
+	// lines are not included in Body or BodyAppendix. Nil if the header does
+	// not have a base. ParseMailbox also finds the base information that
+	// follows the files of the last patch in a series.
+	Base *PatchSeriesBase `json:"base,omitempty"`
+
+	// The output of checking the signature of the commit, like the `gpg:`
+	// lines added by `git log --show-signature`, without the final newline.
+	SignatureStatus string `json:"signature_status,omitempty"`
+}
+
+// PatchSeries is the version and position of a patch in a series of patches,
+// parsed from a subject prefix like `[RFC PATCH v3 5/17]`.
+type PatchSeries struct {
+	// The words of the prefix other than the version and the position, like
+	// `RFC PATCH`.
+	Prefix string `json:"prefix,omitempty"`
+
+	// The version of the series, like 3 for `v3`. Version is 1 if the prefix
+	// does not include a version.
+	Version int `json:"version"`
+
+	// The position of the patch and the number of patches in the series, like
+	// 5 and 17 for `5/17`. Index is 0 for the cover letter of a series. Both
+	// are 0 if the prefix does not include a position.
+	Index int `json:"index"`
+	Total int `json:"total"`
+}
+
+// PatchSeriesBase is the commit that a series of patches applies to, as
+// recorded by `git format-patch --base`.
+type PatchSeriesBase struct {
+	// The ID of the base commit.
+	Commit string `json:"commit"`
+
+	// The patch IDs of patches that are not in the series, but must be
+	// applied to the base commit before the series, as computed by
+	// StablePatchID.
+	PrerequisitePatchIDs []string `json:"prerequisite_patch_ids,omitempty"`
+}
+
+// Note is a note attached to a commit in a patch header.
+type Note struct {
+	// The name of the notes ref, like `review` for `refs/notes/review`.
+	// Empty for notes from the default ref.
+	Ref string `json:"ref,omitempty"`
+
+	// The text of the note, without indentation.
+	Text string `json:"text"`
 }
 
 // Message returns the commit message for the header. The message consists of
@@ -67,10 +149,63 @@ func (h *PatchHeader) Message() string {
 	return msg.String()
 }
 
+// TrailerValues returns the values of all trailers with the given key, in the
+// order they appear. Like Git, it compares keys without regard to case.
+func (h *PatchHeader) TrailerValues(key string) []string {
+	var values []string
+	if h != nil {
+		for _, t := range h.Trailers {
+			if strings.EqualFold(t.Key, key) {
+				values = append(values, t.Value)
+			}
+		}
+	}
+	return values
+}
+
+// SignedOffBy returns the identities from the `Signed-off-by` trailers of the
+// header. Trailers with values that are not valid identities are ignored.
+func (h *PatchHeader) SignedOffBy() []PatchIdentity {
+	return h.trailerIdentities("Signed-off-by")
+}
+
+// ReviewedBy returns the identities from the `Reviewed-by` trailers of the
+// header. Trailers with values that are not valid identities are ignored.
+func (h *PatchHeader) ReviewedBy() []PatchIdentity {
+	return h.trailerIdentities("Reviewed-by")
+}
+
+// CoAuthoredBy returns the identities from the `Co-authored-by` trailers of
+// the header. Trailers with values that are not valid identities are ignored.
+func (h *PatchHeader) CoAuthoredBy() []PatchIdentity {
+	return h.trailerIdentities("Co-authored-by")
+}
+
+func (h *PatchHeader) trailerIdentities(key string) []PatchIdentity {
+	var ids []PatchIdentity
+	for _, v := range h.TrailerValues(key) {
+		if id, err := ParsePatchIdentity(v); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Trailer is a key-value pair from the end of a commit message, like
+// `Signed-off-by: Name <email>`.
+type Trailer struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (t Trailer) String() string {
+	return t.Key + ": " + t.Value
+}
+
 // PatchIdentity identifies a person who authored or committed a patch.
 type PatchIdentity struct {
-	Name  string
-	Email string
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
 func (i PatchIdentity) String() string {
@@ -164,6 +299,32 @@ func ParsePatchDate(s string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unknown date format: %s", s)
 }
 
+// PatchHeaderOption modifies the behavior of ParsePatchHeader.
+type PatchHeaderOption func(*patchHeaderOptions)
+
+type patchHeaderOptions struct {
+	noScissors bool
+	noAppendix bool
+}
+
+// WithoutScissors configures ParsePatchHeader to treat scissors lines in
+// emails as part of the message, like `git am --no-scissors`, instead of
+// removing the content above them.
+func WithoutScissors() PatchHeaderOption {
+	return func(o *patchHeaderOptions) {
+		o.noScissors = true
+	}
+}
+
+// WithoutAppendix configures ParsePatchHeader to discard the content after
+// the `---` line in emails, like `git am`, instead of placing it in
+// BodyAppendix. Notes in the discarded content are still parsed.
+func WithoutAppendix() PatchHeaderOption {
+	return func(o *patchHeaderOptions) {
+		o.noAppendix = true
+	}
+}
+
 // ParsePatchHeader parses a preamble string as returned by Parse into a
 // PatchHeader. Due to the variety of header formats, some fields of the parsed
 // PatchHeader may be unset after parsing.
@@ -175,13 +336,17 @@ func ParsePatchDate(s string) (time.Time, error) {
 // If ParsePatchHeader detects that it is handling an email, it will
 // remove extra content at the beginning of the title line, such as
 // `[PATCH]` or `Re:` in the same way that `git mailinfo` does.
-// SubjectPrefix will be set to the value of this removed string.
+// SubjectPrefix will be set to the value of this removed string and
+// Series will be set to the version and position it contains.
 // (`git mailinfo` is the core part of `git am` that pulls information
 // out of an individual mail.)
 //
 // Additionally, if ParsePatchHeader detects that it's handling an
 // email, it will remove a `---` line and put anything after it into
-// BodyAppendix.
+// BodyAppendix. If the body of the email has a scissors line, like
+// `-- >8 --`, before the `---` line, the scissors line and everything above
+// it are removed, like `git am --scissors`. Use WithoutScissors and
+// WithoutAppendix to change how ParsePatchHeader handles this content.
 //
 // Those wishing the effect of a plain `git am` should use
 // `PatchHeader.Title + "\n" + PatchHeader.Body` (or
@@ -189,7 +354,12 @@ func ParsePatchDate(s string) (time.Time, error) {
 // prefix and appendix material should use `PatchHeader.SubjectPrefix
 // + PatchHeader.Title + "\n" + PatchHeader.Body + "\n" +
 // PatchHeader.BodyAppendix`.
-func ParsePatchHeader(s string) (*PatchHeader, error) {
+func ParsePatchHeader(s string, opts ...PatchHeaderOption) (*PatchHeader, error) {
+	var o patchHeaderOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	r := bufio.NewReader(strings.NewReader(s))
 
 	var line string
@@ -209,22 +379,31 @@ func ParsePatchHeader(s string) (*PatchHeader, error) {
 		}
 	}
 
+	var h *PatchHeader
+	var err error
 	switch {
 	case strings.HasPrefix(line, mailHeaderPrefix):
-		return parseHeaderMail(line, r)
+		h, err = parseHeaderMail(line, r, o)
 	case strings.HasPrefix(line, mailMinimumHeaderPrefix):
 		r = bufio.NewReader(strings.NewReader(s))
-		return parseHeaderMail("", r)
+		h, err = parseHeaderMail("", r, o)
 	case strings.HasPrefix(line, prettyHeaderPrefix):
-		return parseHeaderPretty(line, r)
+		h, err = parseHeaderPretty(line, r)
+	default:
+		return nil, errors.New("unrecognized patch header format")
+	}
+	if h != nil {
+		h.HashAlgorithm = detectHashAlgorithm(h.SHA)
 	}
-	return nil, errors.New("unrecognized patch header format")
+	return h, err
 }
 
 func parseHeaderPretty(prettyLine string, r io.Reader) (*PatchHeader, error) {
 	const (
 		authorPrefix     = "Author:"
 		commitPrefix     = "Commit:"
+		mergePrefix      = "Merge:"
+		reflogPrefix     = "Reflog:"
 		datePrefix       = "Date:"
 		authorDatePrefix = "AuthorDate:"
 		commitDatePrefix = "CommitDate:"
@@ -239,6 +418,9 @@ func parseHeaderPretty(prettyLine string, r io.Reader) (*PatchHeader, error) {
 		h.SHA = prettyLine
 	}
 
+	var status []string
+	fields := false
+
 	s := bufio.NewScanner(r)
 	for s.Scan() {
 		line := s.Text()
@@ -249,12 +431,16 @@ func parseHeaderPretty(prettyLine string, r io.Reader) (*PatchHeader, error) {
 		}
 
 		switch {
+		case strings.HasPrefix(line, mergePrefix), strings.HasPrefix(line, reflogPrefix):
+			fields = true
+
 		case strings.HasPrefix(line, authorPrefix):
 			u, err := ParsePatchIdentity(line[len(authorPrefix):])
 			if err != nil {
 				return nil, err
 			}
 			h.Author = &u
+			fields = true
 
 		case strings.HasPrefix(line, commitPrefix):
 			u, err := ParsePatchIdentity(line[len(commitPrefix):])
@@ -283,11 +469,37 @@ func parseHeaderPretty(prettyLine string, r io.Reader) (*PatchHeader, error) {
 				return nil, err
 			}
 			h.CommitterDate = d
+
+		case !fields:
+			// signature checks follow the commit line, like "gpg: Good
+			// signature from ..." for "git log --show-signature"
+			status = append(status, line)
+		}
+	}
+	if s.Err() != nil {
+		return nil, s.Err()
+	}
+	h.SignatureStatus = strings.Join(status, "\n")
+
+	// with --stat, an unindented "---" line separates the indented message
+	// from the diffstat, which is not part of the message
+	var msg strings.Builder
+	indented := false
+	for first := true; s.Scan(); {
+		line := s.Text()
+		if first && strings.TrimSpace(line) != "" {
+			indented, first = line[0] == ' ' || line[0] == '\t', false
 		}
+		if indented && line == "---" {
+			break
+		}
+		msg.WriteString(line)
+		msg.WriteByte('\n')
 	}
 	if s.Err() != nil {
 		return nil, s.Err()
 	}
+	s = bufio.NewScanner(strings.NewReader(msg.String()))
 
 	title, indent := scanMessageTitle(s)
 	if s.Err() != nil {
@@ -297,11 +509,13 @@ func parseHeaderPretty(prettyLine string, r io.Reader) (*PatchHeader, error) {
 
 	if title != "" {
 		// Don't check for an appendix
-		body, _ := scanMessageBody(s, indent, false)
+		msg := scanMessageBody(s, indent, false)
 		if s.Err() != nil {
 			return nil, s.Err()
 		}
-		h.Body = body
+		h.Body = msg.body
+		h.Trailers = parseTrailers(msg.body)
+		_, h.Notes = parseNotes(msg.notes)
 	}
 
 	return h, nil
@@ -329,14 +543,45 @@ func scanMessageTitle(s *bufio.Scanner) (title string, indent string) {
 	return b.String(), indent
 }
 
-func scanMessageBody(s *bufio.Scanner, indent string, separateAppendix bool) (string, string) {
+// message is the content of a commit message after the title.
+type message struct {
+	body      string
+	appendix  string
+	notes     string
+	signature string
+}
+
+// scanMessageBody scans the body of a commit message with the given indent.
+// If separateAppendix is true, the message is from an email and may have an
+// appendix after a "---" line and a signature after a "-- " line. Otherwise,
+// lines without the indent that start notes end the body.
+func scanMessageBody(s *bufio.Scanner, indent string, separateAppendix bool) message {
 	// Body and appendix
 	var body, appendix strings.Builder
+	var notes, signature []string
 	c := &body
 	var empty int
 	for i := 0; s.Scan(); i++ {
 		line := s.Text()
 
+		switch {
+		case signature != nil || notes != nil:
+			if signature != nil {
+				signature = append(signature, strings.TrimRightFunc(line, unicode.IsSpace))
+			} else {
+				notes = append(notes, strings.TrimRightFunc(line, unicode.IsSpace))
+			}
+			continue
+
+		case separateAppendix && line == "-- ":
+			signature = []string{}
+			continue
+
+		case !separateAppendix && indent != "" && !strings.HasPrefix(line, indent) && isNotesHeader(line):
+			notes = []string{line}
+			continue
+		}
+
 		line = strings.TrimRightFunc(line, unicode.IsSpace)
 		line = strings.TrimPrefix(line, indent)
 
@@ -362,10 +607,200 @@ func scanMessageBody(s *bufio.Scanner, indent string, separateAppendix bool) (st
 
 		c.WriteString(line)
 	}
-	return body.String(), appendix.String()
+
+	return message{
+		body:      body.String(),
+		appendix:  appendix.String(),
+		notes:     strings.Join(notes, "\n"),
+		signature: strings.TrimSpace(strings.Join(signature, "\n")),
+	}
+}
+
+// parseSeriesBase removes the base tree information added by "git
+// format-patch --base" from s and returns the remaining content and the base.
+// It returns s and nil if s has no base information.
+func parseSeriesBase(s string) (string, *PatchSeriesBase) {
+	const (
+		basePrefix         = "base-commit: "
+		prerequisitePrefix = "prerequisite-patch-id: "
+	)
+
+	isID := func(id string) bool {
+		return id != "" && validateOID(id, HashUnknown) == nil
+	}
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, basePrefix) || !isID(line[len(basePrefix):]) {
+			continue
+		}
+
+		base := &PatchSeriesBase{Commit: line[len(basePrefix):]}
+		end := i + 1
+		for ; end < len(lines); end++ {
+			line := lines[end]
+			if !strings.HasPrefix(line, prerequisitePrefix) || !isID(line[len(prerequisitePrefix):]) {
+				break
+			}
+			base.PrerequisitePatchIDs = append(base.PrerequisitePatchIDs, line[len(prerequisitePrefix):])
+		}
+
+		before, after := trimBlankLines(lines[:i]), trimBlankLines(lines[end:])
+		if len(before) > 0 && len(after) > 0 {
+			before = append(before, "")
+		}
+		return strings.Join(append(before, after...), "\n"), base
+	}
+	return s, nil
+}
+
+// isNotesHeader returns true if line starts a section of notes, like
+// "Notes:" for the default notes ref or "Notes (review):" for other refs.
+func isNotesHeader(line string) bool {
+	_, ok := parseNotesHeader(line)
+	return ok
+}
+
+func parseNotesHeader(line string) (ref string, ok bool) {
+	const prefix = "Notes"
+
+	line = strings.TrimRightFunc(line, unicode.IsSpace)
+	if !strings.HasPrefix(line, prefix) || !strings.HasSuffix(line, ":") {
+		return "", false
+	}
+	ref = line[len(prefix) : len(line)-1]
+	if ref == "" {
+		return "", true
+	}
+	if len(ref) > 3 && strings.HasPrefix(ref, " (") && strings.HasSuffix(ref, ")") {
+		return ref[2 : len(ref)-1], true
+	}
+	return "", false
+}
+
+// parseNotes removes the sections of notes from s and returns the remaining
+// content and the notes. Each section starts with a notes header and
+// contains the following lines that are empty or indented by four spaces.
+func parseNotes(s string) (string, []Note) {
+	const indent = "    "
+
+	var rest []string
+	var notes []Note
+	var text []string
+
+	inNote := false
+	endNote := func() {
+		if inNote {
+			notes[len(notes)-1].Text = strings.Join(trimBlankLines(text), "\n")
+		}
+		text, inNote = nil, false
+	}
+
+	for _, line := range strings.Split(s, "\n") {
+		if ref, ok := parseNotesHeader(line); ok {
+			endNote()
+			notes = append(notes, Note{Ref: ref})
+			inNote = true
+			continue
+		}
+		if inNote && (line == "" || strings.HasPrefix(line, indent)) {
+			text = append(text, strings.TrimPrefix(line, indent))
+			continue
+		}
+		endNote()
+		rest = append(rest, line)
+	}
+	endNote()
+
+	if notes == nil {
+		return s, nil
+	}
+	return strings.Join(trimBlankLines(rest), "\n"), notes
+}
+
+// trimBlankLines removes empty lines from the start and end of lines.
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// parseTrailers returns the trailers in the last paragraph of a commit message
+// body. Like "git interpret-trailers", it considers the paragraph a trailer
+// block if all of its lines are trailers or if it contains a trailer added by
+// Git and at least a quarter of its lines are trailers. Continuation lines,
+// which start with whitespace, are joined to the value of the previous trailer.
+func parseTrailers(body string) []Trailer {
+	block := body
+	if i := strings.LastIndex(body, "\n\n"); i >= 0 {
+		block = body[i+2:]
+	}
+
+	var trailers []Trailer
+	var other int
+	var recognized bool
+	last := -1
+	for _, line := range strings.Split(block, "\n") {
+		if line != "" && (line[0] == ' ' || line[0] == '\t') {
+			if last >= 0 {
+				trailers[last].Value += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		// (cherry picked from commit ...) counts as a Git trailer, but is not
+		// in the key-value format
+		if strings.HasPrefix(line, "(cherry picked from commit ") {
+			recognized = true
+			other++
+			last = -1
+			continue
+		}
+
+		t, ok := parseTrailer(line)
+		if !ok {
+			other++
+			last = -1
+			continue
+		}
+		if strings.EqualFold(t.Key, "Signed-off-by") {
+			recognized = true
+		}
+		trailers = append(trailers, t)
+		last = len(trailers) - 1
+	}
+
+	if len(trailers) == 0 || (other > 0 && (!recognized || 3*len(trailers) < other)) {
+		return nil
+	}
+	return trailers
 }
 
-func parseHeaderMail(mailLine string, r io.Reader) (*PatchHeader, error) {
+// parseTrailer parses a line in the format `Key: value`, where the key
+// contains only letters, digits, and hyphens.
+func parseTrailer(line string) (Trailer, bool) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return Trailer{}, false
+	}
+
+	key := strings.TrimRight(line[:i], " \t")
+	for _, c := range key {
+		if !(c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			return Trailer{}, false
+		}
+	}
+	if key == "" {
+		return Trailer{}, false
+	}
+	return Trailer{Key: key, Value: strings.TrimSpace(line[i+1:])}, true
+}
+
+func parseHeaderMail(mailLine string, r io.Reader, o patchHeaderOptions) (*PatchHeader, error) {
 	msg, err := mail.ReadMessage(r)
 	if err != nil {
 		return nil, err
@@ -401,14 +836,64 @@ func parseHeaderMail(mailLine string, r io.Reader) (*PatchHeader, error) {
 		h.AuthorDate = d
 	}
 
+	if ct := msg.Header.Get("Content-Type"); ct != "" {
+		if _, params, err := mime.ParseMediaType(ct); err == nil {
+			h.Charset = params["charset"]
+		}
+	}
+
 	subject := msg.Header.Get("Subject")
 	h.SubjectPrefix, h.Title = parseSubject(subject)
 
-	s := bufio.NewScanner(msg.Body)
-	h.Body, h.BodyAppendix = scanMessageBody(s, "", true)
+	content, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := string(content)
+	if !o.noScissors {
+		rest = cutScissors(rest)
+	}
+
+	fields, rest := parseInBodyHeaders(rest)
+	if from, ok := fields["From"]; ok {
+		author, err := parseInBodyAuthor(from)
+		if err != nil {
+			return nil, err
+		}
+		h.Author = author
+	}
+	if date, ok := fields["Date"]; ok {
+		d, err := ParsePatchDate(date)
+		if err != nil {
+			return nil, err
+		}
+		h.AuthorDate = d
+	}
+	if subject, ok := fields["Subject"]; ok {
+		h.SubjectPrefix, h.Title = parseSubject(subject)
+	}
+
+	h.Series = parseSubjectSeries(h.SubjectPrefix)
+
+	s := bufio.NewScanner(strings.NewReader(rest))
+	body := scanMessageBody(s, "", true)
 	if s.Err() != nil {
 		return nil, s.Err()
 	}
+	var appendix string
+	h.Body, h.Base = parseSeriesBase(body.body)
+	if h.Base == nil {
+		appendix, h.Base = parseSeriesBase(body.appendix)
+	} else {
+		appendix = body.appendix
+	}
+	h.BodyAppendix, h.Notes = parseNotes(appendix)
+	if o.noAppendix {
+		h.BodyAppendix = ""
+	}
+	h.Signature = body.signature
+	h.Trailers = parseTrailers(h.Body)
 
 	return h, nil
 }
@@ -458,30 +943,245 @@ func parseSubject(s string) (string, string) {
 		break
 	}
 
-	return s[:at], decodeSubject(s[at:])
+	return s[:at], decodeMailHeader(s[at:])
+}
+
+// parseSubjectSeries parses the patch series in a subject prefix returned by
+// parseSubject. It uses the last bracketed section of the prefix that
+// contains a `PATCH` word or a position, so mailing list tags like `[list]`
+// are ignored. It returns nil if no section describes a patch.
+func parseSubjectSeries(prefix string) *PatchSeries {
+	var series *PatchSeries
+	for {
+		start := strings.IndexByte(prefix, '[')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(prefix[start:], ']')
+		if end < 0 {
+			break
+		}
+		if s := parseSubjectSeriesSection(prefix[start+1 : start+end]); s != nil {
+			series = s
+		}
+		prefix = prefix[start+end+1:]
+	}
+	return series
+}
+
+func parseSubjectSeriesSection(section string) *PatchSeries {
+	s := &PatchSeries{Version: 1}
+	isPatch := false
+
+	var words []string
+	for _, word := range strings.Fields(section) {
+		if len(word) > len("PATCH") && strings.EqualFold(word[:len("PATCH")], "PATCH") {
+			// some authors write the version without a space, like "PATCHv2"
+			if v, ok := parseSubjectSeriesVersion(word[len("PATCH"):]); ok {
+				s.Version = v
+				word = word[:len("PATCH")]
+			}
+		}
+
+		if v, ok := parseSubjectSeriesVersion(word); ok {
+			s.Version = v
+			continue
+		}
+		if i := strings.IndexByte(word, '/'); i > 0 {
+			index, indexErr := strconv.Atoi(word[:i])
+			total, totalErr := strconv.Atoi(word[i+1:])
+			if indexErr == nil && totalErr == nil && index >= 0 && total > 0 {
+				s.Index, s.Total = index, total
+				isPatch = true
+				continue
+			}
+		}
+		if strings.EqualFold(word, "PATCH") {
+			isPatch = true
+		}
+		words = append(words, word)
+	}
+
+	if !isPatch {
+		return nil
+	}
+	s.Prefix = strings.Join(words, " ")
+	return s
 }
 
-// Decodes a subject line. Currently only supports quoted-printable UTF-8. This format is the result
-// of a `git format-patch` when the commit title has a non-ASCII character (i.e. an emoji).
-// See for reference: https://stackoverflow.com/questions/27695749/gmail-api-not-respecting-utf-encoding-in-subject
-func decodeSubject(encoded string) string {
-	if !strings.HasPrefix(encoded, "=?UTF-8?q?") {
-		// not UTF-8 encoded
-		return encoded
+// parseSubjectSeriesVersion parses a version like "v3".
+func parseSubjectSeriesVersion(word string) (int, bool) {
+	if len(word) < 2 || (word[0] != 'v' && word[0] != 'V') {
+		return 0, false
 	}
+	for i := 1; i < len(word); i++ {
+		if word[i] < '0' || word[i] > '9' {
+			return 0, false
+		}
+	}
+	v, err := strconv.Atoi(word[1:])
+	if err != nil || v == 0 {
+		return 0, false
+	}
+	return v, true
+}
 
-	// If the subject is too long, `git format-patch` may produce a subject line across
-	// multiple lines. When parsed, this can look like the following:
-	// <UTF8-prefix><first-line> <UTF8-prefix><second-line>
-	payload := " " + encoded
-	payload = strings.ReplaceAll(payload, " =?UTF-8?q?", "")
-	payload = strings.ReplaceAll(payload, "?=", "")
+// cutScissors returns the content of an email body after the last scissors
+// line that appears before the `---` line, or the original body if it does
+// not have a scissors line.
+func cutScissors(body string) string {
+	cut := 0
+	for rest := body; rest != ""; {
+		line := firstLine(rest)
+		rest = rest[len(line):]
+
+		text := strings.TrimRight(line, "\r\n")
+		if text == "---" {
+			break
+		}
+		if isScissorsLine(text) {
+			cut = len(body) - len(rest)
+		}
+	}
+	return body[cut:]
+}
+
+// isScissorsLine returns true if line is a scissors line, like `-- >8 --`,
+// using the rules from is_scissors_line() in Git's mailinfo.c: the line must
+// contain a scissors mark and the perforation of dashes and marks must take
+// up most of the line, but it may also contain text like "cut here".
+func isScissorsLine(line string) bool {
+	var scissors, gap, perforation int
+	first, last := -1, -1
+	inPerforation := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f' {
+			if inPerforation {
+				perforation++
+				gap++
+			}
+			continue
+		}
 
-	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(payload)))
+		last = i
+		if first < 0 {
+			first = i
+		}
+		if c == '-' {
+			inPerforation = true
+			perforation++
+			continue
+		}
+		if mark := line[i:]; strings.HasPrefix(mark, ">8") || strings.HasPrefix(mark, "8<") ||
+			strings.HasPrefix(mark, ">%") || strings.HasPrefix(mark, "%<") {
+			inPerforation = true
+			perforation += 2
+			scissors += 2
+			i++
+			continue
+		}
+		inPerforation = false
+	}
+
+	visible := 0
+	if first >= 0 {
+		visible = last - first + 1
+	}
+	return scissors > 0 && visible >= 8 && visible < perforation*3 && gap*2 < perforation
+}
+
+// inBodyHeaders are the header fields that may appear at the start of the
+// body of an email to replace the fields in the email header. For example,
+// "git format-patch" adds a "From:" line to the body when the author of a
+// commit is not the sender of the email.
+var inBodyHeaders = []string{"From", "Date", "Subject"}
+
+// parseInBodyHeaders parses the header fields at the start of an email body,
+// like "git am", and returns them along with the rest of the body. Values are
+// not decoded. If the body does not start with a header field, it returns no
+// fields and the original body.
+func parseInBodyHeaders(body string) (map[string]string, string) {
+	var fields map[string]string
+	var key string
+
+	rest := body
+	for rest != "" {
+		line := firstLine(rest)
+		text := strings.TrimRight(line, "\r\n")
+
+		switch {
+		case text == "":
+			if fields != nil {
+				return fields, rest[len(line):]
+			}
+		case key != "" && (text[0] == ' ' || text[0] == '\t'):
+			fields[key] += " " + strings.TrimSpace(text)
+		default:
+			if key = inBodyHeaderKey(text); key == "" {
+				if fields == nil {
+					return nil, body
+				}
+				return fields, rest
+			}
+			if fields == nil {
+				fields = make(map[string]string)
+			}
+			fields[key] = strings.TrimSpace(text[len(key)+1:])
+		}
+		rest = rest[len(line):]
+	}
+	if fields == nil {
+		return nil, body
+	}
+	return fields, rest
+}
+
+// inBodyHeaderKey returns the canonical name of the in-body header field
+// that starts line, or the empty string if line does not start a field.
+func inBodyHeaderKey(line string) string {
+	for _, key := range inBodyHeaders {
+		if len(line) > len(key) && line[len(key)] == ':' && strings.EqualFold(line[:len(key)], key) {
+			return key
+		}
+	}
+	return ""
+}
+
+// parseInBodyAuthor parses the value of an in-body "From:" field. Git does not
+// encode the field, so the value is usually a plain identity, but it may also
+// be an encoded email address.
+func parseInBodyAuthor(s string) (*PatchIdentity, error) {
+	if id, err := ParsePatchIdentity(s); err == nil {
+		id.Name = decodeMailHeader(id.Name)
+		return &id, nil
+	}
+
+	addr, err := mail.ParseAddress(s)
 	if err != nil {
-		// if err, abort decoding and return original subject
-		return encoded
+		return nil, fmt.Errorf("invalid in-body From: %w", err)
+	}
+	if addr.Name == "" {
+		addr.Name = addr.Address
 	}
+	return &PatchIdentity{Name: addr.Name, Email: addr.Address}, nil
+}
 
-	return string(decoded)
+// decodeMailHeader decodes the RFC 2047 encoded words in a header value, like
+// the subjects generated by "git format-patch" for commit titles with
+// non-ASCII characters (i.e. an emoji). It supports the B and Q encodings
+// with the UTF-8, ISO-8859-1, and US-ASCII character sets and returns the
+// original value if decoding fails.
+func decodeMailHeader(s string) string {
+	if !strings.Contains(s, "=?") {
+		return s
+	}
+
+	var dec mime.WordDecoder
+	decoded, err := dec.DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+	return decoded
 }
@@ -1,6 +1,7 @@
 package gitdiff
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
@@ -144,9 +145,10 @@ func TestParsePatchHeader(t *testing.T) {
 	expectedBodyAppendix := "CC: Joe Smith <joe.smith@company.com>"
 
 	tests := map[string]struct {
-		Input  string
-		Header PatchHeader
-		Err    interface{}
+		Input   string
+		Options []PatchHeaderOption
+		Header  PatchHeader
+		Err     interface{}
 	}{
 		"prettyShort": {
 			Input: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
@@ -180,6 +182,26 @@ Date:   Sat Apr 11 15:21:23 2020 -0700
 				Body:       expectedBody,
 			},
 		},
+		"prettyTrailers": {
+			Input: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+Author: Morton Haypenny <mhaypenny@example.com>
+
+    A sample commit to test header parsing
+
+    Another body line.
+
+    Co-authored-by: Joe Smith <joe.smith@company.com>
+`,
+			Header: PatchHeader{
+				SHA:    expectedSHA,
+				Author: expectedIdentity,
+				Title:  expectedTitle,
+				Body:   "Another body line.\n\nCo-authored-by: Joe Smith <joe.smith@company.com>",
+				Trailers: []Trailer{
+					{Key: "Co-authored-by", Value: "Joe Smith <joe.smith@company.com>"},
+				},
+			},
+		},
 		"prettyFull": {
 			Input: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
 Author: Morton Haypenny <mhaypenny@example.com>
@@ -330,6 +352,431 @@ CC: Joe Smith <joe.smith@company.com>
 				BodyAppendix: expectedBodyAppendix,
 			},
 		},
+		"mailboxTrailers": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+
+Another body line.
+
+Reviewed-by: Joe Smith <joe.smith@company.com>
+Signed-off-by: Morton Haypenny <mhaypenny@example.com>
+---
+CC: Joe Smith <joe.smith@company.com>
+`,
+			Header: PatchHeader{
+				SHA:        expectedSHA,
+				Author:     expectedIdentity,
+				AuthorDate: expectedDate,
+				Title:      expectedTitle,
+				Body: "Another body line.\n\n" +
+					"Reviewed-by: Joe Smith <joe.smith@company.com>\n" +
+					"Signed-off-by: Morton Haypenny <mhaypenny@example.com>",
+				BodyAppendix: expectedBodyAppendix,
+				Trailers: []Trailer{
+					{Key: "Reviewed-by", Value: "Joe Smith <joe.smith@company.com>"},
+					{Key: "Signed-off-by", Value: "Morton Haypenny <mhaypenny@example.com>"},
+				},
+			},
+		},
+		"mailboxCharset": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+MIME-Version: 1.0
+Content-Type: text/plain; charset="ISO-8859-1"
+Content-Transfer-Encoding: 8bit
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+Another body line.
+`,
+			Header: PatchHeader{
+				SHA:        expectedSHA,
+				Author:     expectedIdentity,
+				AuthorDate: expectedDate,
+				Title:      expectedTitle,
+				Body:       expectedBody,
+				Charset:    "ISO-8859-1",
+			},
+		},
+		"prettySignatureStatus": {
+			Input: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+gpg: Signature made Sat Apr 11 15:21:23 2020 PDT
+gpg:                using RSA key 0123456789ABCDEF
+gpg: Good signature from "Morton Haypenny <mhaypenny@example.com>" [ultimate]
+Merge: 1234567 89abcde
+Author: Morton Haypenny <mhaypenny@example.com>
+
+    A sample commit to test header parsing
+`,
+			Header: PatchHeader{
+				SHA:    expectedSHA,
+				Author: expectedIdentity,
+				Title:  expectedTitle,
+				SignatureStatus: "gpg: Signature made Sat Apr 11 15:21:23 2020 PDT\n" +
+					"gpg:                using RSA key 0123456789ABCDEF\n" +
+					`gpg: Good signature from "Morton Haypenny <mhaypenny@example.com>" [ultimate]`,
+			},
+		},
+		"prettyNotes": {
+			Input: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+Author: Morton Haypenny <mhaypenny@example.com>
+
+    A sample commit to test header parsing
+
+    The medium format shows the body, which
+    may wrap on to multiple lines.
+
+    Another body line.
+
+Notes:
+    A note about the commit.
+
+      With an indented line.
+
+Notes (review):
+    Reviewed in person.
+`,
+			Header: PatchHeader{
+				SHA:    expectedSHA,
+				Author: expectedIdentity,
+				Title:  expectedTitle,
+				Body:   expectedBody,
+				Notes: []Note{
+					{Text: "A note about the commit.\n\n  With an indented line."},
+					{Ref: "review", Text: "Reviewed in person."},
+				},
+			},
+		},
+		"prettyNotesInBody": {
+			Input: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+Author: Morton Haypenny <mhaypenny@example.com>
+
+    A sample commit to test header parsing
+
+    Notes:
+    This is part of the message.
+`,
+			Header: PatchHeader{
+				SHA:    expectedSHA,
+				Author: expectedIdentity,
+				Title:  expectedTitle,
+				Body:   "Notes:\nThis is part of the message.",
+			},
+		},
+		"mailboxNotesAndSignature": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+Another body line.
+---
+
+Notes:
+    A note about the commit.
+
+ file.txt | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+-- 
+2.39.5
+
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+				Body:          expectedBody,
+				BodyAppendix:  " file.txt | 2 +-\n 1 file changed, 1 insertion(+), 1 deletion(-)",
+				Notes:         []Note{{Text: "A note about the commit."}},
+				Signature:     "2.39.5",
+			},
+		},
+		"mailboxEncodedHeaders": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: =?UTF-8?q?Mort=C3=B3n=20Haypenny?= <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] =?UTF-8?B?QSBzYW1wbGUgY29tbWl0IHRvIHRlc3Qg?=
+ =?UTF-8?B?aGVhZGVyIHBhcnNpbmcg8J+klg==?=
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+Another body line.
+`,
+			Header: PatchHeader{
+				SHA: expectedSHA,
+				Author: &PatchIdentity{
+					Name:  "Mortón Haypenny",
+					Email: expectedIdentity.Email,
+				},
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle + " 🤖",
+				SubjectPrefix: "[PATCH] ",
+				Body:          expectedBody,
+			},
+		},
+		"mailboxInBodyHeaders": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Sender <sender@example.com>
+Date: Mon, 13 Apr 2020 09:00:00 -0700
+Subject: [PATCH] Replaced subject
+
+From: Mortón Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test
+ header parsing
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+Another body line.
+`,
+			Header: PatchHeader{
+				SHA: expectedSHA,
+				Author: &PatchIdentity{
+					Name:  "Mortón Haypenny",
+					Email: expectedIdentity.Email,
+				},
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+				Body:          expectedBody,
+			},
+		},
+		"mailboxInBodyFromOnly": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Sender <sender@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+
+From: <mhaypenny@example.com>
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+Another body line.
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        &PatchIdentity{expectedIdentity.Email, expectedIdentity.Email},
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+				Body:          expectedBody,
+			},
+		},
+		"mailboxInBodyText": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+
+The body mentions From: addresses.
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+				Body:          "The body mentions From: addresses.",
+			},
+		},
+		"mailboxInBodyInvalidFrom": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] A sample commit to test header parsing
+
+From: Morton Haypenny
+
+Another body line.
+`,
+			Err: true,
+		},
+		"mailboxScissors": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Sender <sender@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: Re: [PATCH] Fix the thing
+
+Thanks for the report, does this fix it?
+
+-- >8 --
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] A sample commit to test header parsing
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+Another body line.
+---
+-- 8< -- cut here -- 8< --
+ file.txt | 2 +-
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+				Body:          expectedBody,
+				BodyAppendix:  "-- 8< -- cut here -- 8< --\n file.txt | 2 +-",
+			},
+		},
+		"mailboxWithoutScissors": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Sender <sender@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: Re: [PATCH] Fix the thing
+
+Thanks for the report, does this fix it?
+
+-- >8 --
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] A sample commit to test header parsing
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+Another body line.
+---
+-- 8< -- cut here -- 8< --
+ file.txt | 2 +-
+`,
+			Options: []PatchHeaderOption{WithoutScissors()},
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        &PatchIdentity{Name: "Sender", Email: "sender@example.com"},
+				AuthorDate:    expectedDate,
+				Title:         "Fix the thing",
+				SubjectPrefix: "Re: [PATCH] ",
+				Body: "Thanks for the report, does this fix it?\n\n-- >8 --\n" +
+					"From: Morton Haypenny <mhaypenny@example.com>\n" +
+					"Subject: [PATCH] A sample commit to test header parsing\n\n" + expectedBody,
+				BodyAppendix: "-- 8< -- cut here -- 8< --\n file.txt | 2 +-",
+			},
+		},
+		"mailboxWithoutAppendix": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+Another body line.
+---
+Notes:
+    A note about the commit.
+
+ file.txt | 2 +-
+`,
+			Options: []PatchHeaderOption{WithoutAppendix()},
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+				Body:          expectedBody,
+				Notes:         []Note{{Text: "A note about the commit."}},
+			},
+		},
+		"mailboxCoverLetterBase": {
+			Input: `From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH 0/2] A sample commit to test header parsing
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+Another body line.
+
+
+base-commit: fbe14f58ad27a629666a5562d0c4eb6f0148b8cb
+prerequisite-patch-id: 5f3f67a30b8a8df3ba78b4739d7c3708d10b2925
+prerequisite-patch-id: 8244558b4533633399db8af6b9516721e51279bc
+-- 
+2.39.5
+`,
+			Header: PatchHeader{
+				SHA:           "0000000000000000000000000000000000000000",
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH 0/2] ",
+				Body:          expectedBody,
+				Base: &PatchSeriesBase{
+					Commit: "fbe14f58ad27a629666a5562d0c4eb6f0148b8cb",
+					PrerequisitePatchIDs: []string{
+						"5f3f67a30b8a8df3ba78b4739d7c3708d10b2925",
+						"8244558b4533633399db8af6b9516721e51279bc",
+					},
+				},
+				Signature: "2.39.5",
+			},
+		},
+		"mailboxAppendixBase": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+Another body line.
+---
+ file.txt | 2 +-
+
+base-commit: fbe14f58ad27a629666a5562d0c4eb6f0148b8cb
+
+Reviewed in a branch.
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+				Body:          expectedBody,
+				BodyAppendix:  " file.txt | 2 +-\n\nReviewed in a branch.",
+				Base:          &PatchSeriesBase{Commit: "fbe14f58ad27a629666a5562d0c4eb6f0148b8cb"},
+			},
+		},
+		"mailboxInvalidBase": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+
+base-commit: the main branch
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+				Body:          "The medium format shows the body, which\nmay wrap on to multiple lines.\n\nbase-commit: the main branch",
+				Trailers:      []Trailer{{Key: "base-commit", Value: "the main branch"}},
+			},
+		},
 		"mailboxMinimalNoName": {
 			Input: `From: <mhaypenny@example.com>
 Subject: [PATCH] A sample commit to test header parsing
@@ -418,7 +865,7 @@ Author: Morton Haypenny <mhaypenny@example.com>
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			h, err := ParsePatchHeader(test.Input)
+			h, err := ParsePatchHeader(test.Input, test.Options...)
 			if test.Err != nil {
 				assertError(t, test.Err, err, "parsing patch header")
 				return
@@ -457,6 +904,24 @@ Author: Morton Haypenny <mhaypenny@example.com>
 				t.Errorf("incorrect parsed body appendix:\n  expected: %q\n    actual: %q",
 					exp.BodyAppendix, act.BodyAppendix)
 			}
+			if !reflect.DeepEqual(exp.Trailers, act.Trailers) {
+				t.Errorf("incorrect parsed trailers:\n  expected: %+v\n    actual: %+v", exp.Trailers, act.Trailers)
+			}
+			if exp.Charset != act.Charset {
+				t.Errorf("incorrect parsed charset: expected %q, actual %q", exp.Charset, act.Charset)
+			}
+			if !reflect.DeepEqual(exp.Notes, act.Notes) {
+				t.Errorf("incorrect parsed notes:\n  expected: %+v\n    actual: %+v", exp.Notes, act.Notes)
+			}
+			if exp.Signature != act.Signature {
+				t.Errorf("incorrect parsed signature: expected %q, actual %q", exp.Signature, act.Signature)
+			}
+			if !reflect.DeepEqual(exp.Base, act.Base) {
+				t.Errorf("incorrect parsed base:\n  expected: %+v\n    actual: %+v", exp.Base, act.Base)
+			}
+			if exp.SignatureStatus != act.SignatureStatus {
+				t.Errorf("incorrect parsed signature status:\n  expected: %q\n    actual: %q", exp.SignatureStatus, act.SignatureStatus)
+			}
 		})
 	}
 }
@@ -520,3 +985,179 @@ func TestCleanupSubject(t *testing.T) {
 		}
 	}
 }
+
+func TestParseSubjectSeries(t *testing.T) {
+	tests := map[string]struct {
+		Prefix string
+		Series *PatchSeries
+	}{
+		"empty": {
+			Prefix: "",
+		},
+		"reply": {
+			Prefix: "Re: ",
+		},
+		"patch": {
+			Prefix: "[PATCH] ",
+			Series: &PatchSeries{Prefix: "PATCH", Version: 1},
+		},
+		"version": {
+			Prefix: "[PATCH v3] ",
+			Series: &PatchSeries{Prefix: "PATCH", Version: 3},
+		},
+		"position": {
+			Prefix: "[PATCH 2/7] ",
+			Series: &PatchSeries{Prefix: "PATCH", Version: 1, Index: 2, Total: 7},
+		},
+		"coverLetter": {
+			Prefix: "[PATCH v2 0/7] ",
+			Series: &PatchSeries{Prefix: "PATCH", Version: 2, Index: 0, Total: 7},
+		},
+		"versionAndPosition": {
+			Prefix: "[PATCH v3 2/7] ",
+			Series: &PatchSeries{Prefix: "PATCH", Version: 3, Index: 2, Total: 7},
+		},
+		"customPrefix": {
+			Prefix: "[RFC PATCH net-next v4 10/12] ",
+			Series: &PatchSeries{Prefix: "RFC PATCH net-next", Version: 4, Index: 10, Total: 12},
+		},
+		"attachedVersion": {
+			Prefix: "[PATCHv2 1/3] ",
+			Series: &PatchSeries{Prefix: "PATCH", Version: 2, Index: 1, Total: 3},
+		},
+		"positionWithoutPatch": {
+			Prefix: "[RFC 1/3] ",
+			Series: &PatchSeries{Prefix: "RFC", Version: 1, Index: 1, Total: 3},
+		},
+		"listTag": {
+			Prefix: "Re: [dev-list] [PATCH v2 1/3] ",
+			Series: &PatchSeries{Prefix: "PATCH", Version: 2, Index: 1, Total: 3},
+		},
+		"onlyListTag": {
+			Prefix: "[dev-list] ",
+		},
+		"invalidPosition": {
+			Prefix: "[v2 1/0] ",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			series := parseSubjectSeries(test.Prefix)
+			if !reflect.DeepEqual(test.Series, series) {
+				t.Errorf("incorrect series:\n  expected: %+v\n    actual: %+v", test.Series, series)
+			}
+		})
+	}
+}
+
+func TestIsScissorsLine(t *testing.T) {
+	tests := map[string]bool{
+		"-- >8 --":                         true,
+		"-- 8< --":                         true,
+		"--- >% ---":                       true,
+		"  -- >8 -- ":                      true,
+		"-->8--------":                     true,
+		"-- >8 -- cut here -- >8 --":       true,
+		"--------8<--------":               true,
+		"-- >8 ------":                     true,
+		"---":                              false,
+		"--------":                         false,
+		"-- >8":                            false,
+		">8 cut here, this is a long line": false,
+		"Signed-off-by: A <a@b.c>":         false,
+	}
+
+	for line, expected := range tests {
+		if actual := isScissorsLine(line); actual != expected {
+			t.Errorf("incorrect result for %q: expected %t, actual %t", line, expected, actual)
+		}
+	}
+}
+
+func TestParseTrailers(t *testing.T) {
+	tests := map[string]struct {
+		Body     string
+		Trailers []Trailer
+	}{
+		"allTrailers": {
+			Body: "Fix the thing.\n\nFixes: #123\nAcked-by: Joe Smith <joe.smith@company.com>",
+			Trailers: []Trailer{
+				{Key: "Fixes", Value: "#123"},
+				{Key: "Acked-by", Value: "Joe Smith <joe.smith@company.com>"},
+			},
+		},
+		"onlyParagraph": {
+			Body: "Signed-off-by: Morton Haypenny <mhaypenny@example.com>",
+			Trailers: []Trailer{
+				{Key: "Signed-off-by", Value: "Morton Haypenny <mhaypenny@example.com>"},
+			},
+		},
+		"continuation": {
+			Body: "Fix the thing.\n\nLink: https://example.com/a/very/long\n  /url\nSigned-off-by: Morton Haypenny <mhaypenny@example.com>",
+			Trailers: []Trailer{
+				{Key: "Link", Value: "https://example.com/a/very/long /url"},
+				{Key: "Signed-off-by", Value: "Morton Haypenny <mhaypenny@example.com>"},
+			},
+		},
+		"mixedWithGitTrailer": {
+			Body: "Fix the thing.\n\nThis was a backport.\n(cherry picked from commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b)\nSigned-off-by: Morton Haypenny <mhaypenny@example.com>",
+			Trailers: []Trailer{
+				{Key: "Signed-off-by", Value: "Morton Haypenny <mhaypenny@example.com>"},
+			},
+		},
+		"mixedWithoutGitTrailer": {
+			Body: "Fix the thing.\n\nThis was hard.\nReviewed-by: Joe Smith <joe.smith@company.com>",
+		},
+		"tooFewTrailers": {
+			Body: "Fix the thing.\n\nThis was\nhard\nto do\nand took\na long time.\nSigned-off-by: Morton Haypenny <mhaypenny@example.com>",
+		},
+		"notLastParagraph": {
+			Body: "Fixes: #123\n\nThis is the end.",
+		},
+		"invalidKey": {
+			Body: "Fix the thing.\n\nSee also: the other thing",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			trailers := parseTrailers(test.Body)
+			if !reflect.DeepEqual(test.Trailers, trailers) {
+				t.Errorf("incorrect trailers\nexpected: %+v\n  actual: %+v", test.Trailers, trailers)
+			}
+		})
+	}
+}
+
+func TestPatchHeaderTrailers(t *testing.T) {
+	h := &PatchHeader{
+		Trailers: []Trailer{
+			{Key: "Signed-off-by", Value: "Morton Haypenny <mhaypenny@example.com>"},
+			{Key: "reviewed-by", Value: "Joe Smith <joe.smith@company.com>"},
+			{Key: "Co-authored-by", Value: "not an identity"},
+			{Key: "Signed-off-by", Value: "Joe Smith <joe.smith@company.com>"},
+		},
+	}
+
+	morton := PatchIdentity{Name: "Morton Haypenny", Email: "mhaypenny@example.com"}
+	joe := PatchIdentity{Name: "Joe Smith", Email: "joe.smith@company.com"}
+
+	if ids := h.SignedOffBy(); !reflect.DeepEqual([]PatchIdentity{morton, joe}, ids) {
+		t.Errorf("incorrect signed-off-by identities: %+v", ids)
+	}
+	if ids := h.ReviewedBy(); !reflect.DeepEqual([]PatchIdentity{joe}, ids) {
+		t.Errorf("incorrect reviewed-by identities: %+v", ids)
+	}
+	if ids := h.CoAuthoredBy(); len(ids) > 0 {
+		t.Errorf("expected no co-authored-by identities, but got %+v", ids)
+	}
+	if values := h.TrailerValues("CO-AUTHORED-BY"); !reflect.DeepEqual([]string{"not an identity"}, values) {
+		t.Errorf("incorrect trailer values: %+v", values)
+	}
+
+	var empty *PatchHeader
+	if values := empty.TrailerValues("Signed-off-by"); len(values) > 0 {
+		t.Errorf("expected no values for nil header, but got %+v", values)
+	}
+}
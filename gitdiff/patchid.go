@@ -0,0 +1,200 @@
+package gitdiff
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"strings"
+)
+
+// PatchID returns the ID of the changes in files, as computed by "git
+// patch-id" for the output of Format. The ID ignores whitespace, line
+// numbers, and object IDs of text files, so equivalent changes, like a commit
+// and its cherry-pick, usually have the same ID. It depends on the order of
+// the files, like the default "unstable" ID in Git. PatchID returns an empty
+// string if files do not contain any changes.
+//
+// The ID uses SHA-256 if any of the files uses SHA-256 object IDs and uses
+// SHA-1 otherwise.
+func PatchID(files []*File) (string, error) {
+	return patchID(files, false)
+}
+
+// StablePatchID returns the ID of the changes in files like PatchID, but the
+// ID does not depend on the order of the files, like "git patch-id --stable".
+func StablePatchID(files []*File) (string, error) {
+	return patchID(files, true)
+}
+
+func patchID(files []*File, stable bool) (string, error) {
+	var b bytes.Buffer
+	alg := HashSHA1
+	for _, f := range files {
+		if f.HashAlgorithm == HashSHA256 {
+			alg = HashSHA256
+		}
+		if _, err := Format(&b, f); err != nil {
+			return "", err
+		}
+	}
+
+	id := newPatchIDHash(alg)
+	id.stable = stable
+	for _, line := range strings.SplitAfter(b.String(), "\n") {
+		if line != "" && !id.writeLine(line) {
+			break
+		}
+	}
+	id.flush()
+
+	if id.n == 0 {
+		return "", nil
+	}
+	return hex.EncodeToString(id.sum), nil
+}
+
+// patchIDHash computes a patch ID from the lines of a patch. It follows the
+// implementation of "git patch-id", which hashes the file headers and the
+// fragment lines without whitespace and sums the hashes of each file for
+// stable IDs.
+type patchIDHash struct {
+	h      hash.Hash
+	sum    []byte
+	stable bool
+
+	n             int
+	before, after int64
+	binary        bool
+	oldOID        string
+	newOID        string
+}
+
+func newPatchIDHash(alg HashAlgorithm) *patchIDHash {
+	h := sha1.New()
+	if alg == HashSHA256 {
+		h = sha256.New()
+	}
+	return &patchIDHash{h: h, sum: make([]byte, h.Size()), before: -1, after: -1}
+}
+
+// writeLine adds a line to the ID. It returns false at the end of the patch.
+func (id *patchIDHash) writeLine(line string) bool {
+	if strings.HasPrefix(line, "\\ ") && len(line) > 12 {
+		return true
+	}
+	if id.n == 0 && !strings.HasPrefix(line, "diff ") {
+		return true
+	}
+
+	if id.before == -1 {
+		switch {
+		case strings.HasPrefix(line, "GIT binary patch"), strings.HasPrefix(line, "Binary files"):
+			id.binary = true
+			id.before = 0
+			id.h.Write([]byte(id.oldOID))
+			id.h.Write([]byte(id.newOID))
+			if id.stable {
+				id.flush()
+			}
+			return true
+
+		case strings.HasPrefix(line, "index "):
+			oids := strings.TrimSuffix(line[len("index "):], "\n")
+			if dots := strings.Index(oids, ".."); dots >= 0 {
+				newOID := oids[dots+2:]
+				if sp := strings.IndexByte(newOID, ' '); sp >= 0 {
+					newOID = newOID[:sp]
+				}
+				id.oldOID, id.newOID = oids[:dots], newOID
+			}
+			return true
+
+		case strings.HasPrefix(line, "--- "):
+			id.before, id.after = 1, 1
+
+		case !isASCIILetter(line[0]):
+			return false
+		}
+	}
+
+	if id.binary {
+		// like Git, skip the header of the file after a binary file
+		if strings.HasPrefix(line, "diff ") {
+			id.binary = false
+			id.before = -1
+		}
+		return true
+	}
+
+	if id.before == 0 && id.after == 0 {
+		if strings.HasPrefix(line, "@@ -") {
+			id.before, id.after = scanPatchIDRange(line)
+			return true
+		}
+		if !strings.HasPrefix(line, "diff ") {
+			return false
+		}
+		if id.stable {
+			id.flush()
+		}
+		id.before, id.after = -1, -1
+	}
+
+	if line[0] == '-' || line[0] == ' ' {
+		id.before--
+	}
+	if line[0] == '+' || line[0] == ' ' {
+		id.after--
+	}
+
+	data := removeSpace(line)
+	id.n += len(data)
+	id.h.Write(data)
+	return true
+}
+
+// flush adds the current hash to the sum, as a little-endian number, and
+// resets the hash.
+func (id *patchIDHash) flush() {
+	var carry uint
+	for i, c := range id.h.Sum(nil) {
+		carry += uint(id.sum[i]) + uint(c)
+		id.sum[i] = byte(carry)
+		carry >>= 8
+	}
+	id.h.Reset()
+}
+
+// scanPatchIDRange returns the old and new line counts of a fragment header,
+// or zero for both if the header is invalid.
+func scanPatchIDRange(line string) (before, after int64) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return 0, 0
+	}
+	_, before, errBefore := parseRange(strings.TrimPrefix(fields[1], "-"))
+	_, after, errAfter := parseRange(strings.TrimPrefix(fields[2], "+"))
+	if errBefore != nil || errAfter != nil {
+		return 0, 0
+	}
+	return before, after
+}
+
+// removeSpace returns line without the space characters recognized by Git.
+func removeSpace(line string) []byte {
+	data := make([]byte, 0, len(line))
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case ' ', '\t', '\n', '\r':
+		default:
+			data = append(data, c)
+		}
+	}
+	return data
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,98 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatchID(t *testing.T) {
+	// IDs from "git patch-id --unstable" and "git patch-id --stable" for the
+	// formatted files of each patch
+	tests := map[string]struct {
+		Patch    string
+		Unstable string
+		Stable   string
+	}{
+		"oneFile": {
+			Patch:    "one_file.patch",
+			Unstable: "a2f32e174945221b8416d2bd9c93544563af7cf7",
+			Stable:   "a2f32e174945221b8416d2bd9c93544563af7cf7",
+		},
+		"twoFiles": {
+			Patch:    "two_files.patch",
+			Unstable: "9c2091150cdfc32f8514af5a958f4536ed180c4c",
+			Stable:   "4c66bd1022e1950575b242fb8035fe6230224ca6",
+		},
+		"binary": {
+			Patch:    "new_binary_file.patch",
+			Unstable: "9e6d2ed24fb2e44e9a9e9ad91765302c44150eb1",
+			Stable:   "78a7d1c0ae1d305cccf359c9adc548bcf3ed15ba",
+		},
+		"operations": {
+			Patch:    "apply_fs.patch",
+			Unstable: "4a8e11e07551c9f23b339de48b7c71311319538a",
+			Stable:   "063277fa1af66f6427a62cd6a83567bc1e24934e",
+		},
+		"stat": {
+			Patch:    "stat.patch",
+			Unstable: "951cf10d7dff2c9cba80d614d6ca2240637bf3dc",
+			Stable:   "1f0881bb9a48f70408d55d94b346b15ebb64b45b",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files := parseTestPatch(t, test.Patch)
+
+			unstable, err := PatchID(files)
+			if err != nil {
+				t.Fatalf("unexpected error computing patch ID: %v", err)
+			}
+			if unstable != test.Unstable {
+				t.Errorf("incorrect unstable ID: expected %s, actual %s", test.Unstable, unstable)
+			}
+
+			stable, err := StablePatchID(files)
+			if err != nil {
+				t.Fatalf("unexpected error computing patch ID: %v", err)
+			}
+			if stable != test.Stable {
+				t.Errorf("incorrect stable ID: expected %s, actual %s", test.Stable, stable)
+			}
+		})
+	}
+}
+
+func TestPatchIDEquivalent(t *testing.T) {
+	files := parseTestPatch(t, "two_files.patch")
+	unstable, _ := PatchID(files)
+	stable, _ := StablePatchID(files)
+
+	moved := parseTestPatch(t, "two_files.patch")
+	for _, f := range moved {
+		f.OldOIDPrefix, f.NewOIDPrefix = "1234567", "89abcde"
+		for _, frag := range f.TextFragments {
+			frag.OldPosition += 10
+			frag.NewPosition += 10
+			frag.Comment = "func moved()"
+			for i, line := range frag.Lines {
+				frag.Lines[i].Line = strings.Replace(line.Line, "\n", " \n", 1)
+			}
+		}
+	}
+	if id, _ := PatchID(moved); id != unstable {
+		t.Errorf("moved files have a different unstable ID: expected %s, actual %s", unstable, id)
+	}
+
+	reordered := []*File{moved[1], moved[0]}
+	if id, _ := PatchID(reordered); id == unstable {
+		t.Errorf("reordered files have the same unstable ID: %s", id)
+	}
+	if id, _ := StablePatchID(reordered); id != stable {
+		t.Errorf("reordered files have a different stable ID: expected %s, actual %s", stable, id)
+	}
+
+	if id, err := PatchID(nil); id != "" || err != nil {
+		t.Errorf("expected empty ID for no files, but got %q, %v", id, err)
+	}
+}
@@ -0,0 +1,45 @@
+package gitdiff
+
+// WithPatchPositions configures Parse to record where the header and each
+// line of every text fragment appear in the patch, in the Origin field of
+// the fragment. Linters and review tools can use the positions to refer to
+// the exact location of a problem in the patch.
+func WithPatchPositions() ParseOption {
+	return func(p *parser) {
+		p.positions = true
+	}
+}
+
+// PatchPosition is the location of a line in a patch.
+type PatchPosition struct {
+	// Line is the one-indexed line number in the patch
+	Line int64 `json:"line"`
+	// Offset is the byte offset of the start of the line in the patch,
+	// before any transform configured with WithTransform
+	Offset int64 `json:"offset"`
+}
+
+// FragmentOrigin records the locations of the lines of a text fragment in
+// the patch it was parsed from.
+type FragmentOrigin struct {
+	// Header is the location of the fragment header
+	Header PatchPosition `json:"header"`
+	// Lines contains the location of each line in the Lines of the fragment.
+	// Markers for missing newlines at the end of files do not have
+	// locations.
+	Lines []PatchPosition `json:"lines,omitempty"`
+}
+
+// Line returns the location of the line at index i of the fragment. It
+// returns false if i is out of range.
+func (o *FragmentOrigin) Line(i int) (PatchPosition, bool) {
+	if o == nil || i < 0 || i >= len(o.Lines) {
+		return PatchPosition{}, false
+	}
+	return o.Lines[i], true
+}
+
+// position returns the location of the current line.
+func (p *parser) position() PatchPosition {
+	return PatchPosition{Line: p.lineno, Offset: p.offset()}
+}
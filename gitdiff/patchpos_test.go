@@ -0,0 +1,119 @@
+package gitdiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPatchPositions(t *testing.T) {
+	for _, name := range []string{
+		"two_files.patch",
+		"context_diff_unified.patch",
+		"apply/text_fragment_change_single_noeol.patch",
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatalf("failed to read patch: %v", err)
+			}
+
+			lines := strings.SplitAfter(string(data), "\n")
+			offsets := make([]int64, len(lines))
+			for i := 1; i < len(lines); i++ {
+				offsets[i] = offsets[i-1] + int64(len(lines[i-1]))
+			}
+
+			checkPosition := func(t *testing.T, pos PatchPosition, text string) {
+				if pos.Line < 1 || pos.Line > int64(len(lines)) {
+					t.Fatalf("invalid line number: %d", pos.Line)
+				}
+				if pos.Offset != offsets[pos.Line-1] {
+					t.Errorf("line %d: incorrect offset: expected %d, actual %d", pos.Line, offsets[pos.Line-1], pos.Offset)
+				}
+				if actual := lines[pos.Line-1]; actual != text {
+					t.Errorf("line %d: incorrect content: expected %q, actual %q", pos.Line, text, actual)
+				}
+			}
+
+			parsers := map[string]func() ([]*File, string, error){
+				"reader": func() ([]*File, string, error) {
+					return ParseAll(bytes.NewReader(data), WithPatchPositions())
+				},
+				"bytes": func() ([]*File, string, error) {
+					return ParseBytes(data, WithPatchPositions())
+				},
+				"lazy": func() ([]*File, string, error) {
+					return ParseAll(bytes.NewReader(data), WithPatchPositions(), WithLazyFragments())
+				},
+				"lazyBytes": func() ([]*File, string, error) {
+					return ParseBytes(data, WithPatchPositions(), WithLazyFragments())
+				},
+			}
+			for pname, parse := range parsers {
+				t.Run(pname, func(t *testing.T) {
+					files, _, err := parse()
+					if err != nil {
+						t.Fatalf("unexpected error parsing patch: %v", err)
+					}
+
+					for _, f := range files {
+						if err := f.LoadFragments(); err != nil {
+							t.Fatalf("unexpected error loading fragments: %v", err)
+						}
+						for _, frag := range f.TextFragments {
+							if frag.Origin == nil {
+								t.Fatalf("fragment %q has no origin", frag.Header())
+							}
+							if len(frag.Origin.Lines) != len(frag.Lines) {
+								t.Fatalf("incorrect number of positions: expected %d, actual %d", len(frag.Lines), len(frag.Origin.Lines))
+							}
+
+							header := lines[frag.Origin.Header.Line-1]
+							if !strings.HasPrefix(header, "@@ -") {
+								t.Errorf("line %d: expected fragment header, but found %q", frag.Origin.Header.Line, header)
+							}
+							checkPosition(t, frag.Origin.Header, header)
+
+							for i, line := range frag.Lines {
+								pos, ok := frag.Origin.Line(i)
+								if !ok {
+									t.Fatalf("missing position for line %d", i)
+								}
+								text := line.Op.String() + line.Line
+								if line.NoEOL() {
+									text += "\n"
+								}
+								checkPosition(t, pos, text)
+							}
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestPatchPositionsDisabled(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "two_files.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+
+	files, _, err := ParseAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	for _, f := range files {
+		for _, frag := range f.TextFragments {
+			if frag.Origin != nil {
+				t.Errorf("fragment %q has origin without WithPatchPositions", frag.Header())
+			}
+			if _, ok := frag.Origin.Line(0); ok {
+				t.Errorf("nil origin returned a position")
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package gitdiff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// WithFragmentHeaderPattern configures the parser to also accept text
+// fragment headers that match re, for diff producers that write custom or
+// localized text in fragment headers. The pattern must have the named groups
+// "old" and "new", which match the old and new ranges in the "start,count"
+// form of a standard header, and may have the named group "comment", which
+// matches the function name or other text that Git writes after the ranges.
+// The pattern is matched against the header line without the trailing line
+// ending.
+//
+// The parser tries re before the standard header form, so headers that match
+// neither are still invalid. If a matching header has no valid ranges, the
+// parser returns a KindFragmentHeader error.
+func WithFragmentHeaderPattern(re *regexp.Regexp) ParseOption {
+	return func(p *parser) {
+		p.fragmentPattern = re
+		p.fragmentGroups = [3]int{-1, -1, -1}
+		for i, name := range re.SubexpNames() {
+			switch name {
+			case "old":
+				p.fragmentGroups[0] = i
+			case "new":
+				p.fragmentGroups[1] = i
+			case "comment":
+				p.fragmentGroups[2] = i
+			}
+		}
+	}
+}
+
+// WithTimestampPattern configures the parser to remove timestamps that match
+// re from the end of the "---" and "+++" lines of traditional and context
+// diff headers, for diff producers that write timestamps in non-standard
+// formats or separate them from the file name with spaces instead of a tab.
+// The parser removes the match at the end of the line and any spaces or tabs
+// before it, and then parses the file name as usual.
+//
+// The parser only sets the OldTime and NewTime of files and detects new and
+// deleted files by their timestamps if the timestamps use a standard format.
+func WithTimestampPattern(re *regexp.Regexp) ParseOption {
+	return func(p *parser) {
+		p.timestampPattern = regexp.MustCompile(`(?:` + re.String() + `)$`)
+	}
+}
+
+// isTextFragmentHeader returns true if line is probably a text fragment
+// header, in the standard form or matching the configured pattern.
+func (p *parser) isTextFragmentHeader(line string) bool {
+	const shortestValidFragHeader = "@@ -1 +1 @@\n"
+
+	if len(line) >= len(shortestValidFragHeader) && strings.HasPrefix(line, "@@ -") {
+		return true
+	}
+	_, _, _, ok := p.matchFragmentHeader(line)
+	return ok
+}
+
+// matchFragmentHeader matches line against the configured fragment header
+// pattern and returns the old and new ranges and the comment.
+func (p *parser) matchFragmentHeader(line string) (oldRange, newRange, comment string, ok bool) {
+	if p.fragmentPattern == nil {
+		return "", "", "", false
+	}
+
+	m := p.fragmentPattern.FindStringSubmatch(strings.TrimSuffix(line, "\n"))
+	if m == nil {
+		return "", "", "", false
+	}
+
+	group := func(i int) string {
+		if i < 0 {
+			return ""
+		}
+		return m[i]
+	}
+	return group(p.fragmentGroups[0]), group(p.fragmentGroups[1]), group(p.fragmentGroups[2]), true
+}
+
+// trimTimestamp removes a timestamp matching the configured pattern from the
+// end of the name text of a traditional header line.
+func (p *parser) trimTimestamp(s string) string {
+	if p.timestampPattern == nil {
+		return s
+	}
+
+	text := strings.TrimSuffix(s, "\n")
+	loc := p.timestampPattern.FindStringIndex(text)
+	if loc == nil {
+		return s
+	}
+	return strings.TrimRight(text[:loc[0]], " \t")
+}
@@ -0,0 +1,188 @@
+package gitdiff
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseHeaderPatterns(t *testing.T) {
+	fragmentPattern := regexp.MustCompile(`^## Zeilen alt (?P<old>\S+) neu (?P<new>\S+) ##(?P<comment>.*)$`)
+	timestampPattern := regexp.MustCompile(`\d{1,2}\. \S+ \d{4} \d{2}:\d{2}`)
+
+	tests := map[string]struct {
+		Input   string
+		Options []ParseOption
+		Files   []*File
+		Err     interface{}
+	}{
+		"fragmentPattern": {
+			Input: `--- file.txt
++++ file.txt
+## Zeilen alt 1,2 neu 1,2 ## main()
+ one
+-two
++2
+`,
+			Options: []ParseOption{WithFragmentHeaderPattern(fragmentPattern)},
+			Files: []*File{
+				{
+					OldName: "file.txt",
+					NewName: "file.txt",
+					TextFragments: []*TextFragment{
+						{
+							Comment:     "main()",
+							OldPosition: 1,
+							OldLines:    2,
+							NewPosition: 1,
+							NewLines:    2,
+							Lines: []Line{
+								{OpContext, "one\n"},
+								{OpDelete, "two\n"},
+								{OpAdd, "2\n"},
+							},
+							LinesAdded:     1,
+							LinesDeleted:   1,
+							LeadingContext: 1,
+						},
+					},
+				},
+			},
+		},
+		"fragmentPatternMixed": {
+			Input: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+## Zeilen alt 1 neu 1 ##
+-one
++1
+@@ -3 +3 @@
+-three
++3
+`,
+			Options: []ParseOption{WithFragmentHeaderPattern(fragmentPattern)},
+			Files: []*File{
+				{
+					OldName: "file.txt",
+					NewName: "file.txt",
+					TextFragments: []*TextFragment{
+						{
+							OldPosition:  1,
+							OldLines:     1,
+							NewPosition:  1,
+							NewLines:     1,
+							Lines:        []Line{{OpDelete, "one\n"}, {OpAdd, "1\n"}},
+							LinesAdded:   1,
+							LinesDeleted: 1,
+						},
+						{
+							OldPosition:  3,
+							OldLines:     1,
+							NewPosition:  3,
+							NewLines:     1,
+							Lines:        []Line{{OpDelete, "three\n"}, {OpAdd, "3\n"}},
+							LinesAdded:   1,
+							LinesDeleted: 1,
+						},
+					},
+				},
+			},
+		},
+		"fragmentPatternInvalidRange": {
+			Input: `--- file.txt
++++ file.txt
+## Zeilen alt eins neu 1 ##
+-one
++1
+`,
+			Options: []ParseOption{WithFragmentHeaderPattern(fragmentPattern)},
+			Err:     "invalid fragment header",
+		},
+		"fragmentPatternNotSet": {
+			Input: `--- file.txt
++++ file.txt
+## Zeilen alt 1 neu 1 ##
+-one
++1
+`,
+		},
+		"timestampPattern": {
+			Input: `--- file name.txt  14. Oktober 2026 10:00
++++ file name.txt	14. Oktober 2026 10:05
+@@ -1 +1 @@
+-one
++1
+`,
+			Options: []ParseOption{WithTimestampPattern(timestampPattern)},
+			Files: []*File{
+				{
+					OldName: "file name.txt",
+					NewName: "file name.txt",
+					TextFragments: []*TextFragment{
+						{
+							OldPosition:  1,
+							OldLines:     1,
+							NewPosition:  1,
+							NewLines:     1,
+							Lines:        []Line{{OpDelete, "one\n"}, {OpAdd, "1\n"}},
+							LinesAdded:   1,
+							LinesDeleted: 1,
+						},
+					},
+				},
+			},
+		},
+		"timestampPatternNoMatch": {
+			Input: `--- file.txt
++++ file.txt	2026-10-14 10:05:00.000000000 +0200
+@@ -1 +1 @@
+-one
++1
+`,
+			Options: []ParseOption{WithTimestampPattern(timestampPattern)},
+			Files: []*File{
+				{
+					OldName: "file.txt",
+					NewName: "file.txt",
+					TextFragments: []*TextFragment{
+						{
+							OldPosition:  1,
+							OldLines:     1,
+							NewPosition:  1,
+							NewLines:     1,
+							Lines:        []Line{{OpDelete, "one\n"}, {OpAdd, "1\n"}},
+							LinesAdded:   1,
+							LinesDeleted: 1,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := ParseAll(strings.NewReader(test.Input), test.Options...)
+			if test.Err != nil {
+				assertError(t, test.Err, err, "parsing patch")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			if len(files) != len(test.Files) {
+				t.Fatalf("incorrect number of files: expected %d, actual %d", len(test.Files), len(files))
+			}
+			for i, exp := range test.Files {
+				f := files[i]
+				if exp.OldName != f.OldName || exp.NewName != f.NewName {
+					t.Errorf("incorrect names of file %d: expected %q -> %q, actual %q -> %q", i, exp.OldName, exp.NewName, f.OldName, f.NewName)
+				}
+				if !reflect.DeepEqual(exp.TextFragments, f.TextFragments) {
+					t.Errorf("incorrect fragments of file %d\nexpected: %+v\n  actual: %+v", i, exp.TextFragments, f.TextFragments)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package gitdiff
+
+import (
+	"sort"
+)
+
+// DiffPosition describes a line of a text fragment at a position in the diff
+// of a file.
+type DiffPosition struct {
+	// Position is the one-indexed position of the line in the diff
+	Position int64
+	// Fragment is the zero-indexed index of the fragment in the file
+	Fragment int
+	// Line is the line from the fragment
+	Line Line
+	// OldLine is the line number in the old content, or zero if the line was
+	// added
+	OldLine int64
+	// NewLine is the line number in the new content, or zero if the line was
+	// deleted
+	NewLine int64
+}
+
+// PositionMap maps between line numbers in the content of a file and
+// positions in the diff of the file, like the positions used by the review
+// comment APIs of GitHub. The line after the header of the first fragment is
+// at position one and positions increase by one for each following line,
+// including the headers of later fragments and "\ No newline at end of file"
+// markers.
+type PositionMap struct {
+	lines []DiffPosition
+	old   map[int64]int
+	new   map[int64]int
+}
+
+// NewPositionMap creates a PositionMap for the text fragments of f, in the
+// order they appear in the file.
+func NewPositionMap(f *File) *PositionMap {
+	if l, err := f.loaded(); err == nil {
+		f = l
+	}
+	m := &PositionMap{
+		old: make(map[int64]int),
+		new: make(map[int64]int),
+	}
+
+	var pos int64
+	for i, frag := range f.TextFragments {
+		if i > 0 {
+			// the header of every fragment after the first has a position
+			pos++
+		}
+
+		oldLine, newLine := frag.OldPosition, frag.NewPosition
+		for _, line := range frag.Lines {
+			pos++
+			p := DiffPosition{Position: pos, Fragment: i, Line: line}
+			if line.Old() {
+				p.OldLine = oldLine
+				m.old[oldLine] = len(m.lines)
+				oldLine++
+			}
+			if line.New() {
+				p.NewLine = newLine
+				m.new[newLine] = len(m.lines)
+				newLine++
+			}
+			m.lines = append(m.lines, p)
+
+			if line.NoEOL() {
+				pos++
+			}
+		}
+	}
+	return m
+}
+
+// Lines returns the fragment lines of the file in order of position.
+func (m *PositionMap) Lines() []DiffPosition {
+	return m.lines
+}
+
+// Line returns the fragment line at the position in the diff. It returns
+// false if the position is a fragment header, a marker line, or is not in the
+// diff.
+func (m *PositionMap) Line(position int64) (DiffPosition, bool) {
+	i := sort.Search(len(m.lines), func(i int) bool {
+		return m.lines[i].Position >= position
+	})
+	if i < len(m.lines) && m.lines[i].Position == position {
+		return m.lines[i], true
+	}
+	return DiffPosition{}, false
+}
+
+// OldPosition returns the position in the diff of a line in the old content.
+// It returns false if the line is not in any fragment.
+func (m *PositionMap) OldPosition(line int64) (int64, bool) {
+	if i, ok := m.old[line]; ok {
+		return m.lines[i].Position, true
+	}
+	return 0, false
+}
+
+// NewPosition returns the position in the diff of a line in the new content.
+// It returns false if the line is not in any fragment.
+func (m *PositionMap) NewPosition(line int64) (int64, bool) {
+	if i, ok := m.new[line]; ok {
+		return m.lines[i].Position, true
+	}
+	return 0, false
+}
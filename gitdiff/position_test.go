@@ -0,0 +1,91 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPositionMap(t *testing.T) {
+	const patch = `diff --git a/lines.txt b/lines.txt
+--- a/lines.txt
++++ b/lines.txt
+@@ -1,4 +1,6 @@
++0
+ 1
+ 2
+-3
++3a
++3b
+ 4
+@@ -8,3 +10,2 @@
+ 8
+-9
+ 10
+@@ -12 +13,2 @@
+ 12
++13
+\ No newline at end of file
+`
+
+	files, _, err := ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	m := NewPositionMap(files[0])
+
+	expected := []struct {
+		Position         int64
+		Fragment         int
+		OldLine, NewLine int64
+	}{
+		{1, 0, 0, 1},
+		{2, 0, 1, 2},
+		{3, 0, 2, 3},
+		{4, 0, 3, 0},
+		{5, 0, 0, 4},
+		{6, 0, 0, 5},
+		{7, 0, 4, 6},
+		{9, 1, 8, 10},
+		{10, 1, 9, 0},
+		{11, 1, 10, 11},
+		{13, 2, 12, 13},
+		{14, 2, 0, 14},
+	}
+
+	lines := m.Lines()
+	if len(lines) != len(expected) {
+		t.Fatalf("incorrect number of lines: expected %d, actual %d", len(expected), len(lines))
+	}
+	for i, exp := range expected {
+		p := lines[i]
+		if p.Position != exp.Position || p.Fragment != exp.Fragment || p.OldLine != exp.OldLine || p.NewLine != exp.NewLine {
+			t.Errorf("incorrect line %d\nexpected: %+v\n  actual: %+v", i, exp, p)
+		}
+
+		if line, ok := m.Line(exp.Position); !ok || line != p {
+			t.Errorf("incorrect line at position %d: %+v (%t)", exp.Position, line, ok)
+		}
+		if exp.OldLine > 0 {
+			if pos, ok := m.OldPosition(exp.OldLine); !ok || pos != exp.Position {
+				t.Errorf("incorrect position for old line %d: expected %d, actual %d (%t)", exp.OldLine, exp.Position, pos, ok)
+			}
+		}
+		if exp.NewLine > 0 {
+			if pos, ok := m.NewPosition(exp.NewLine); !ok || pos != exp.Position {
+				t.Errorf("incorrect position for new line %d: expected %d, actual %d (%t)", exp.NewLine, exp.Position, pos, ok)
+			}
+		}
+	}
+
+	for _, pos := range []int64{0, 8, 12, 15, 16} {
+		if line, ok := m.Line(pos); ok {
+			t.Errorf("expected no line at position %d, but got %+v", pos, line)
+		}
+	}
+	if pos, ok := m.OldPosition(6); ok {
+		t.Errorf("expected no position for old line 6, but got %d", pos)
+	}
+	if pos, ok := m.NewPosition(20); ok {
+		t.Errorf("expected no position for new line 20, but got %d", pos)
+	}
+}
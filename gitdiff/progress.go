@@ -0,0 +1,114 @@
+package gitdiff
+
+import (
+	"io"
+)
+
+// Progress describes the state of a parse or apply operation in progress.
+type Progress struct {
+	// File is the name of the file being processed, or empty if no file has
+	// started. It is the new name of the file, or the old name for deleted
+	// files.
+	File string
+	// Fragment is the zero-indexed number of the fragment of File being
+	// processed, which is the number of fragments already processed
+	Fragment int
+	// Bytes is the number of bytes processed. When parsing, it is the number
+	// of bytes read from the input. When applying, it is the number of bytes
+	// of the current file written to the destination.
+	Bytes int64
+}
+
+// WithProgress configures Parse to call fn when it starts parsing each file
+// and after it reads each additional interval bytes of input. If interval is
+// not positive, Parse only calls fn for each file. Parse calls fn from the
+// goroutine that sends files, so fn can enforce a deadline by canceling the
+// context passed to ParseContext.
+func WithProgress(fn func(Progress), interval int64) ParseOption {
+	return func(p *parser) {
+		p.progress = progressReporter{fn: fn, interval: interval}
+	}
+}
+
+// WithApplyProgress configures an Applier to call fn when ApplyFile starts
+// applying a file and after it writes each additional interval bytes of the
+// result. If interval is not positive, the Applier only calls fn for each
+// file. ApplyFS, ApplyToMap, and ApplyCheck call fn for each file in the
+// patch.
+func WithApplyProgress(fn func(Progress), interval int64) ApplierOption {
+	return func(a *Applier) {
+		a.progress = progressReporter{
+			fn:        fn,
+			interval:  interval,
+			fragments: func() int { return len(a.matches) + len(a.rejects) },
+		}
+	}
+}
+
+// progressReporter calls a progress function when a file starts and when
+// the number of processed bytes reaches the next multiple of the interval.
+type progressReporter struct {
+	fn        func(Progress)
+	interval  int64
+	fragments func() int
+
+	file  *File
+	bytes int64
+	next  int64
+}
+
+// start reports the start of f.
+func (r *progressReporter) start(f *File) {
+	if r.fn == nil {
+		return
+	}
+	r.file = f
+	r.report()
+}
+
+// add records n processed bytes and reports progress if the total reaches
+// the next interval.
+func (r *progressReporter) add(n int) {
+	if r.fn == nil {
+		return
+	}
+	r.bytes += int64(n)
+	if r.interval > 0 && r.bytes >= r.next {
+		r.report()
+	}
+}
+
+func (r *progressReporter) report() {
+	p := Progress{Bytes: r.bytes}
+	if f := r.file; f != nil {
+		if p.File = f.NewName; p.File == "" {
+			p.File = f.OldName
+		}
+		p.Fragment = len(f.TextFragments) + len(f.CombinedFragments) + len(f.WordFragments)
+	}
+	if r.fragments != nil {
+		p.Fragment = r.fragments()
+	}
+	if r.interval > 0 {
+		r.next = (r.bytes/r.interval + 1) * r.interval
+	}
+	r.fn(p)
+}
+
+// reset clears the current file and the number of processed bytes.
+func (r *progressReporter) reset() {
+	r.file, r.bytes, r.next = nil, 0, 0
+}
+
+// progressWriter is a writer that records the bytes written in a
+// progressReporter.
+type progressWriter struct {
+	w io.Writer
+	r *progressReporter
+}
+
+func (w progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.r.add(n)
+	return n, err
+}
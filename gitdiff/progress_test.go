@@ -0,0 +1,98 @@
+package gitdiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseProgress(t *testing.T) {
+	patch, err := os.ReadFile(filepath.Join("testdata", "two_files.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+
+	t.Run("files", func(t *testing.T) {
+		var files []string
+		_, _, err := ParseAll(bytes.NewReader(patch), WithProgress(func(p Progress) {
+			if p.Fragment != 0 {
+				t.Errorf("incorrect fragment for start of file %s: %d", p.File, p.Fragment)
+			}
+			files = append(files, p.File)
+		}, 0))
+		if err != nil {
+			t.Fatalf("unexpected error parsing patch: %v", err)
+		}
+
+		expected := []string{"dir/file1.txt", "dir/file2.txt"}
+		if !reflect.DeepEqual(expected, files) {
+			t.Errorf("incorrect files\nexpected: %v\n  actual: %v", expected, files)
+		}
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		const interval = 100
+
+		var updates []Progress
+		_, _, err := ParseAll(bytes.NewReader(patch), WithProgress(func(p Progress) {
+			updates = append(updates, p)
+		}, interval))
+		if err != nil {
+			t.Fatalf("unexpected error parsing patch: %v", err)
+		}
+
+		if min := len(patch)/interval + 2; len(updates) < min {
+			t.Fatalf("expected at least %d updates, but got %d", min, len(updates))
+		}
+		var last int64
+		for _, p := range updates {
+			if p.Bytes < last || p.Bytes > int64(len(patch)) {
+				t.Fatalf("incorrect bytes in update: %+v", p)
+			}
+			last = p.Bytes
+		}
+		if last <= int64(len(patch)-interval) {
+			t.Errorf("incorrect bytes in last update: expected more than %d, actual %d", len(patch)-interval, last)
+		}
+	})
+}
+
+func TestApplyProgress(t *testing.T) {
+	src, patch, out := applyFiles{
+		Src:   "file_text.src",
+		Patch: "file_text_modify.patch",
+		Out:   "file_text_modify.out",
+	}.Load(t)
+
+	files, _, err := ParseAll(bytes.NewReader(patch))
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	var updates []Progress
+	var dst bytes.Buffer
+	err = Apply(&dst, bytes.NewReader(src), files[0], WithApplyProgress(func(p Progress) {
+		updates = append(updates, p)
+	}, 1000))
+	if err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+	if !bytes.Equal(out, dst.Bytes()) {
+		t.Fatalf("incorrect result after apply")
+	}
+
+	if len(updates) < len(out)/1000+1 {
+		t.Fatalf("expected at least %d updates, but got %d", len(out)/1000+1, len(updates))
+	}
+	if first := updates[0]; first != (Progress{File: files[0].NewName}) {
+		t.Errorf("incorrect first update: %+v", first)
+	}
+	for i := 1; i < len(updates); i++ {
+		prev, p := updates[i-1], updates[i]
+		if p.Bytes <= prev.Bytes || p.Fragment < prev.Fragment || p.Fragment > len(files[0].TextFragments) {
+			t.Errorf("incorrect update after %+v: %+v", prev, p)
+		}
+	}
+}
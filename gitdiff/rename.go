@@ -0,0 +1,274 @@
+package gitdiff
+
+import (
+	"bytes"
+	"sort"
+)
+
+const (
+	defaultRenameScore = 50
+
+	// like git, split content for similarity at newlines or after 64 bytes
+	similarityChunkSize = 64
+)
+
+// WithRenames configures DiffMap to detect renamed files, like the -M flag
+// of "git diff". A deleted file and a new file are a rename if their
+// similarity index, the percentage of content they share, is at least score.
+// If score is not between 1 and 100, DiffMap uses 50%, the default in Git.
+// Diff ignores this option.
+func WithRenames(score int) DiffOption {
+	return func(d *differ) {
+		d.renames = validRenameScore(score)
+	}
+}
+
+// WithCopies configures DiffMap to detect copied files, like the -C flag of
+// "git diff", in addition to renames. A new file is a copy if its similarity
+// index with any of the old files is at least score and it is not a rename.
+// Like the --find-copies-harder flag, DiffMap checks all old files, including
+// those that do not change. If score is not between 1 and 100, DiffMap uses
+// 50%. Diff ignores this option.
+func WithCopies(score int) DiffOption {
+	return func(d *differ) {
+		d.copies = validRenameScore(score)
+		if d.renames == 0 {
+			d.renames = d.copies
+		}
+	}
+}
+
+func validRenameScore(score int) int {
+	if score < 1 || score > 100 {
+		return defaultRenameScore
+	}
+	return score
+}
+
+// DiffMap computes the changes between two sets of files and returns a File
+// for each file that is created, deleted, or modified, like "git diff" for
+// two trees. The keys of old and new are slash separated paths and the values
+// are their content, like the map used by ApplyToMap. Options configure each
+// File in the same way as for Diff, except that DiffMap sets the names.
+//
+// The files are sorted by name, using the new name of renamed and copied
+// files. Like Git, if multiple new files are similar to the same deleted
+// file, the last is a rename and the others are copies, so that applying the
+// files in order creates the copies before removing the original file.
+func DiffMap(old, new map[string][]byte, opts ...DiffOption) ([]*File, error) {
+	d := differ{context: defaultDiffContext}
+	for _, opt := range opts {
+		opt(&d)
+	}
+
+	var deleted, added, modified []string
+	for name, data := range old {
+		newData, ok := new[name]
+		switch {
+		case !ok:
+			deleted = append(deleted, name)
+		case !bytes.Equal(data, newData):
+			modified = append(modified, name)
+		}
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(deleted)
+	sort.Strings(added)
+
+	var matches []renameMatch
+	if d.renames > 0 {
+		matches = findRenames(old, new, deleted, added, d.renames, d.copies)
+	}
+
+	used := make(map[string]bool)
+	var pairs []renameMatch
+	for _, m := range matches {
+		used[m.src], used[m.dst] = true, true
+		pairs = append(pairs, m)
+	}
+	for _, name := range modified {
+		pairs = append(pairs, renameMatch{src: name, dst: name})
+	}
+	for _, name := range deleted {
+		if !used[name] {
+			pairs = append(pairs, renameMatch{src: name})
+		}
+	}
+	for _, name := range added {
+		if !used[name] {
+			pairs = append(pairs, renameMatch{dst: name})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].sortName() < pairs[j].sortName() })
+
+	// the last file that uses a deleted file in the output is the rename
+	renamed := make(map[string]bool)
+	files := make([]*File, len(pairs))
+	for i := len(pairs) - 1; i >= 0; i-- {
+		p := pairs[i]
+		f, err := d.diffPair(old, new, p)
+		if err != nil {
+			return nil, err
+		}
+		if f.IsCopy {
+			if _, ok := new[p.src]; !ok && !renamed[p.src] {
+				f.IsCopy, f.IsRename = false, true
+				renamed[p.src] = true
+			}
+		}
+		files[i] = f
+	}
+	return files, nil
+}
+
+// diffPair computes the File for a matched pair of old and new names. An empty
+// name means the file does not exist in that set. Pairs with different names
+// are returned as copies.
+func (d differ) diffPair(old, new map[string][]byte, p renameMatch) (*File, error) {
+	d.oldName, d.newName = p.src, p.dst
+	f, err := d.diff(old[p.src], new[p.dst])
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.src == "":
+		f.IsNew, f.NewMode = true, 0100644
+	case p.dst == "":
+		f.IsDelete, f.OldMode = true, 0100644
+	case p.src != p.dst:
+		f.IsCopy, f.Score = true, p.score
+	}
+	return f, nil
+}
+
+// renameMatch is a pair of an old file and a new file with its similarity
+// score.
+type renameMatch struct {
+	src, dst string
+	score    int
+}
+
+func (m renameMatch) sortName() string {
+	if m.dst != "" {
+		return m.dst
+	}
+	return m.src
+}
+
+// findRenames matches added files with the most similar deleted files, or
+// with any old file if copies is non-zero, and returns the matches with a
+// score of at least the given threshold. Each deleted file matches at most
+// one added file unless copies is non-zero. Exact matches are preferred,
+// followed by matches with higher scores and then by name.
+func findRenames(old, new map[string][]byte, deleted, added []string, renames, copies int) []renameMatch {
+	sources := deleted
+	if copies > 0 {
+		sources = make([]string, 0, len(old))
+		for name := range old {
+			sources = append(sources, name)
+		}
+		sort.Strings(sources)
+	}
+
+	isDeleted := make(map[string]bool, len(deleted))
+	for _, name := range deleted {
+		isDeleted[name] = true
+	}
+
+	chunks := make(map[string]map[string]int)
+	similarity := func(name string, data []byte) map[string]int {
+		c, ok := chunks[name]
+		if !ok {
+			c = similarityChunks(data)
+			chunks[name] = c
+		}
+		return c
+	}
+
+	var candidates []renameMatch
+	for _, dst := range added {
+		dstData := new[dst]
+		for _, src := range sources {
+			threshold := copies
+			if isDeleted[src] {
+				threshold = renames
+			}
+
+			srcData := old[src]
+			var score int
+			if bytes.Equal(srcData, dstData) {
+				if len(srcData) == 0 {
+					continue
+				}
+				score = 100
+			} else {
+				if len(srcData) == 0 || len(dstData) == 0 || !similarSize(len(srcData), len(dstData), threshold) {
+					continue
+				}
+				srcChunks := similarity("a/"+src, srcData)
+				dstChunks := similarity("b/"+dst, dstData)
+				score = similarityScore(srcChunks, dstChunks, len(srcData), len(dstData))
+			}
+			if score >= threshold {
+				candidates = append(candidates, renameMatch{src: src, dst: dst, score: score})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	var matches []renameMatch
+	usedSrc, usedDst := make(map[string]bool), make(map[string]bool)
+	for _, c := range candidates {
+		if usedDst[c.dst] || (usedSrc[c.src] && copies == 0) {
+			continue
+		}
+		usedSrc[c.src], usedDst[c.dst] = true, true
+		matches = append(matches, c)
+	}
+	return matches
+}
+
+// similarSize returns true if files with the given sizes can have a
+// similarity score of at least threshold.
+func similarSize(a, b, threshold int) bool {
+	if a > b {
+		a, b = b, a
+	}
+	return a*100 >= b*threshold
+}
+
+// similarityChunks returns the number of bytes of each chunk of data.
+func similarityChunks(data []byte) map[string]int {
+	chunks := make(map[string]int)
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n <= 0 || n > similarityChunkSize {
+			n = int(min(int64(len(data)), similarityChunkSize))
+		}
+		chunks[string(data[:n])] += n
+		data = data[n:]
+	}
+	return chunks
+}
+
+// similarityScore returns the percentage of content shared by two files,
+// using the bytes of the chunks they have in common and the size of the
+// larger file, like the similarity index of Git.
+func similarityScore(a, b map[string]int, aSize, bSize int) int {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	common := 0
+	for chunk, n := range a {
+		common += int(min(int64(n), int64(b[chunk])))
+	}
+	return common * 100 / int(max(int64(aSize), int64(bSize)))
+}
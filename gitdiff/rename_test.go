@@ -0,0 +1,228 @@
+package gitdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffMap(t *testing.T) {
+	const (
+		content  = "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+		modified = "a\nb\nc\nd\ne\nF\ng\nh\ni\nj\n"
+		other    = "one\ntwo\nthree\n"
+	)
+
+	type result struct {
+		Old, New string
+		Op       string
+		Score    int
+	}
+
+	tests := map[string]struct {
+		Old, New map[string]string
+		Options  []DiffOption
+		Files    []result
+	}{
+		"changes": {
+			Old: map[string]string{"del.txt": content, "mod.txt": content, "same.txt": other},
+			New: map[string]string{"add.txt": other, "mod.txt": modified, "same.txt": other},
+			Files: []result{
+				{New: "add.txt", Op: "new"},
+				{Old: "del.txt", Op: "delete"},
+				{Old: "mod.txt", New: "mod.txt", Op: "modify"},
+			},
+		},
+		"noRenames": {
+			Old: map[string]string{"old.txt": content},
+			New: map[string]string{"new.txt": content},
+			Files: []result{
+				{New: "new.txt", Op: "new"},
+				{Old: "old.txt", Op: "delete"},
+			},
+		},
+		"exactRename": {
+			Old:     map[string]string{"old.txt": content},
+			New:     map[string]string{"new.txt": content},
+			Options: []DiffOption{WithRenames(0)},
+			Files: []result{
+				{Old: "old.txt", New: "new.txt", Op: "rename", Score: 100},
+			},
+		},
+		"similarRename": {
+			Old:     map[string]string{"dir/old.txt": content, "other.txt": other},
+			New:     map[string]string{"dir/new.txt": modified, "other.txt": other},
+			Options: []DiffOption{WithRenames(50)},
+			Files: []result{
+				{Old: "dir/old.txt", New: "dir/new.txt", Op: "rename", Score: 90},
+			},
+		},
+		"belowThreshold": {
+			Old:     map[string]string{"old.txt": content},
+			New:     map[string]string{"new.txt": modified},
+			Options: []DiffOption{WithRenames(95)},
+			Files: []result{
+				{New: "new.txt", Op: "new"},
+				{Old: "old.txt", Op: "delete"},
+			},
+		},
+		"bestMatch": {
+			Old:     map[string]string{"a.txt": modified, "b.txt": content},
+			New:     map[string]string{"c.txt": content},
+			Options: []DiffOption{WithRenames(50)},
+			Files: []result{
+				{Old: "a.txt", Op: "delete"},
+				{Old: "b.txt", New: "c.txt", Op: "rename", Score: 100},
+			},
+		},
+		"emptyFiles": {
+			Old:     map[string]string{"old.txt": ""},
+			New:     map[string]string{"new.txt": ""},
+			Options: []DiffOption{WithRenames(50)},
+			Files: []result{
+				{New: "new.txt", Op: "new"},
+				{Old: "old.txt", Op: "delete"},
+			},
+		},
+		"copyUnchanged": {
+			Old:     map[string]string{"src.txt": content},
+			New:     map[string]string{"src.txt": content, "dst.txt": modified},
+			Options: []DiffOption{WithCopies(50)},
+			Files: []result{
+				{Old: "src.txt", New: "dst.txt", Op: "copy", Score: 90},
+			},
+		},
+		"copiesNeedOption": {
+			Old:     map[string]string{"src.txt": content},
+			New:     map[string]string{"src.txt": content, "dst.txt": modified},
+			Options: []DiffOption{WithRenames(50)},
+			Files: []result{
+				{New: "dst.txt", Op: "new"},
+			},
+		},
+		"renameAndCopy": {
+			Old:     map[string]string{"old.txt": content},
+			New:     map[string]string{"a.txt": modified, "b.txt": content},
+			Options: []DiffOption{WithCopies(50)},
+			Files: []result{
+				{Old: "old.txt", New: "a.txt", Op: "copy", Score: 90},
+				{Old: "old.txt", New: "b.txt", Op: "rename", Score: 100},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			old, new := toByteMap(test.Old), toByteMap(test.New)
+
+			files, err := DiffMap(old, new, test.Options...)
+			if err != nil {
+				t.Fatalf("unexpected error computing diff: %v", err)
+			}
+
+			var results []result
+			for _, f := range files {
+				r := result{Old: f.OldName, New: f.NewName, Score: f.Score}
+				switch {
+				case f.IsNew:
+					r.Op = "new"
+				case f.IsDelete:
+					r.Op = "delete"
+				case f.IsRename:
+					r.Op = "rename"
+				case f.IsCopy:
+					r.Op = "copy"
+				default:
+					r.Op = "modify"
+				}
+				results = append(results, r)
+			}
+			if !reflect.DeepEqual(test.Files, results) {
+				t.Fatalf("incorrect files\nexpected: %+v\n  actual: %+v", test.Files, results)
+			}
+
+			applied, err := ApplyToMap(old, files)
+			if err != nil {
+				t.Fatalf("unexpected error applying diff: %v", err)
+			}
+			if result := toStringMap(applied); !reflect.DeepEqual(test.New, result) {
+				t.Errorf("applying the diff did not produce the new files\nexpected: %q\n  actual: %q", test.New, result)
+			}
+		})
+	}
+}
+
+func TestDiffMapFormat(t *testing.T) {
+	old := map[string][]byte{"old.txt": []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n")}
+	new := map[string][]byte{"new.txt": []byte("a\nb\nc\nd\ne\nF\ng\nh\ni\nj\n")}
+
+	files, err := DiffMap(old, new, WithRenames(50))
+	if err != nil {
+		t.Fatalf("unexpected error computing diff: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, but got %d", len(files))
+	}
+
+	expected := strings.Join([]string{
+		"diff --git a/old.txt b/new.txt",
+		"similarity index 90%",
+		"rename from old.txt",
+		"rename to new.txt",
+		"--- a/old.txt",
+		"+++ b/new.txt",
+		"@@ -3,7 +3,7 @@",
+		" c",
+		" d",
+		" e",
+		"-f",
+		"+F",
+		" g",
+		" h",
+		" i",
+		"",
+	}, "\n")
+	if actual := files[0].String(); actual != expected {
+		t.Errorf("incorrect formatted file\nexpected:\n%s\nactual:\n%s", expected, actual)
+	}
+}
+
+func TestSimilarityScore(t *testing.T) {
+	tests := map[string]struct {
+		A, B  string
+		Score int
+	}{
+		"identical":  {A: "a\nb\n", B: "a\nb\n", Score: 100},
+		"disjoint":   {A: "a\nb\n", B: "c\nd\n", Score: 0},
+		"half":       {A: "a\nb\n", B: "a\nc\n", Score: 50},
+		"reordered":  {A: "a\nb\nc\n", B: "c\nb\na\n", Score: 100},
+		"larger":     {A: "a\nb\n", B: "a\nb\nc\nd\n", Score: 50},
+		"duplicates": {A: "a\na\na\n", B: "a\n", Score: 33},
+		"longLine":   {A: strings.Repeat("x", 128), B: strings.Repeat("x", 64) + strings.Repeat("y", 64), Score: 50},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a, b := similarityChunks([]byte(test.A)), similarityChunks([]byte(test.B))
+			if score := similarityScore(a, b, len(test.A), len(test.B)); score != test.Score {
+				t.Errorf("incorrect score: expected %d, actual %d", test.Score, score)
+			}
+		})
+	}
+}
+
+func toByteMap(m map[string]string) map[string][]byte {
+	b := make(map[string][]byte, len(m))
+	for name, data := range m {
+		b[name] = []byte(data)
+	}
+	return b
+}
+
+func toStringMap(m map[string][]byte) map[string]string {
+	s := make(map[string]string, len(m))
+	for name, data := range m {
+		s[name] = string(data)
+	}
+	return s
+}
@@ -0,0 +1,85 @@
+package gitdiff
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// lineScanner reads lines from a string without copying them, so that lines
+// share the memory of the string. It fails if a line is longer than max bytes,
+// not including the delimiter, when max is positive.
+type lineScanner struct {
+	s   string
+	off int
+	max int
+}
+
+// ReadString returns the next line of the string, including the delimiter,
+// and io.EOF if the line is the last line and does not end with delim.
+func (l *lineScanner) ReadString(delim byte) (string, error) {
+	if l.off >= len(l.s) {
+		return "", io.EOF
+	}
+
+	line := l.s[l.off:]
+	n := strings.IndexByte(line, delim)
+	if n >= 0 {
+		line = line[:n+1]
+	} else {
+		n = len(line)
+	}
+	if l.max > 0 && n > l.max {
+		return "", &LimitExceededError{Limit: LimitLineLength, Max: int64(l.max)}
+	}
+
+	l.off += len(line)
+	if n == len(line) {
+		return line, io.EOF
+	}
+	return line, nil
+}
+
+func (l *lineScanner) Read(b []byte) (int, error) {
+	if l.off >= len(l.s) {
+		return 0, io.EOF
+	}
+	n := copy(b, l.s[l.off:])
+	l.off += n
+	return n, nil
+}
+
+// internedLines are lines that appear many times in most patches. An
+// internReader returns these strings instead of allocating a copy of each
+// occurrence.
+var internedLines = map[string]string{
+	"\n":                             "\n",
+	" \n":                            " \n",
+	"+\n":                            "+\n",
+	"-\n":                            "-\n",
+	"--\n":                           "--\n",
+	"-- \n":                          "-- \n",
+	"\\ No newline at end of file\n": "\\ No newline at end of file\n",
+}
+
+// internReader reads lines from a bufio.Reader like ReadString, but returns
+// shared strings for common lines, like blank context, added, and deleted
+// lines, instead of allocating a new string for each line. Other lines are
+// copied once from the buffer of the reader.
+type internReader struct {
+	r *bufio.Reader
+}
+
+func (r *internReader) ReadString(delim byte) (string, error) {
+	b, err := r.r.ReadSlice(delim)
+	if err == bufio.ErrBufferFull {
+		// the slice is only valid until the next read
+		head := string(b)
+		rest, err := r.r.ReadString(delim)
+		return head + rest, err
+	}
+	if line, ok := internedLines[string(b)]; ok {
+		return line, err
+	}
+	return string(b), err
+}
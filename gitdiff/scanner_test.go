@@ -0,0 +1,144 @@
+package gitdiff
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineScanner(t *testing.T) {
+	l := &lineScanner{s: "one\ntwo\n\nlast"}
+
+	for _, expected := range []struct {
+		Line string
+		Err  error
+	}{
+		{"one\n", nil},
+		{"two\n", nil},
+		{"\n", nil},
+		{"last", io.EOF},
+		{"", io.EOF},
+	} {
+		line, err := l.ReadString('\n')
+		if line != expected.Line || err != expected.Err {
+			t.Fatalf("incorrect line: expected %q, %v, actual %q, %v", expected.Line, expected.Err, line, err)
+		}
+	}
+
+	l = &lineScanner{s: "short\nlonger line\n", max: 5}
+	if line, err := l.ReadString('\n'); line != "short\n" || err != nil {
+		t.Fatalf("incorrect line: expected %q, actual %q, %v", "short\n", line, err)
+	}
+	if _, err := l.ReadString('\n'); !errors.Is(err, &LimitExceededError{}) {
+		t.Fatalf("expected limit error, but got %v", err)
+	}
+
+	l = &lineScanner{s: "one\ntwo\n"}
+	allocs := testing.AllocsPerRun(10, func() {
+		l.off = 0
+		_, _ = l.ReadString('\n')
+		_, _ = l.ReadString('\n')
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations reading lines, but got %.0f", allocs)
+	}
+}
+
+func TestInternReader(t *testing.T) {
+	content := "+\n-\n \nother\n" + strings.Repeat("x", 5000) + "\n\\ No newline at end of file\nlast"
+
+	r := &internReader{r: bufio.NewReaderSize(strings.NewReader(content), 16)}
+	for _, expected := range []struct {
+		Line string
+		Err  error
+	}{
+		{"+\n", nil},
+		{"-\n", nil},
+		{" \n", nil},
+		{"other\n", nil},
+		{strings.Repeat("x", 5000) + "\n", nil},
+		{"\\ No newline at end of file\n", nil},
+		{"last", io.EOF},
+		{"", io.EOF},
+	} {
+		line, err := r.ReadString('\n')
+		if line != expected.Line || err != expected.Err {
+			t.Fatalf("incorrect line: expected %q, %v, actual %q, %v", expected.Line, expected.Err, line, err)
+		}
+	}
+
+	sr := strings.NewReader("")
+	r = &internReader{r: bufio.NewReader(sr)}
+	allocs := testing.AllocsPerRun(10, func() {
+		sr.Reset("+\n \n-\n\n")
+		r.r.Reset(sr)
+		for i := 0; i < 4; i++ {
+			_, _ = r.ReadString('\n')
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations reading common lines, but got %.0f", allocs)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	for _, name := range []string{
+		"one_file.patch",
+		"two_files.patch",
+		"new_binary_file.patch",
+		"mailbox.patch",
+		"word_diff.patch",
+		"context_diff.patch",
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatalf("failed to read patch: %v", err)
+			}
+
+			expectedFiles, expectedPreamble, expectedErr := ParseAll(bytes.NewReader(data))
+			files, preamble, err := ParseBytes(data)
+			if !reflect.DeepEqual(expectedErr, err) {
+				t.Fatalf("incorrect error\nexpected: %v\n  actual: %v", expectedErr, err)
+			}
+			if preamble != expectedPreamble {
+				t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", expectedPreamble, preamble)
+			}
+			if !reflect.DeepEqual(expectedFiles, files) {
+				t.Errorf("incorrect files\nexpected: %+v\n  actual: %+v", expectedFiles, files)
+			}
+		})
+	}
+
+	t.Run("lineLimit", func(t *testing.T) {
+		_, _, err := ParseBytes([]byte("preamble\ntoo long line\n"), WithMaxLineLength(8))
+
+		var perr *ParseError
+		if !errors.As(err, &perr) || perr.Kind != KindLimitExceeded || perr.Line != 2 {
+			t.Fatalf("expected limit error at line 2, but got %v", err)
+		}
+	})
+
+	t.Run("allocations", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "apply", "file_text_modify.patch"))
+		if err != nil {
+			t.Fatalf("failed to read patch: %v", err)
+		}
+
+		readerAllocs := testing.AllocsPerRun(10, func() {
+			_, _, _ = ParseAll(bytes.NewReader(data))
+		})
+		bytesAllocs := testing.AllocsPerRun(10, func() {
+			_, _, _ = ParseBytes(data)
+		})
+		if bytesAllocs >= readerAllocs {
+			t.Errorf("expected ParseBytes to allocate less than ParseAll, but got %.0f and %.0f", bytesAllocs, readerAllocs)
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package gitdiff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// seriesFile is the name of the file that lists the patches in a quilt
+// patches directory.
+const seriesFile = "series"
+
+// SeriesEntry is a patch listed in a quilt series file.
+type SeriesEntry struct {
+	// Name is the slash-separated path of the patch file relative to the
+	// patches directory
+	Name string
+
+	// Strip is the number of leading components to remove from the file
+	// names in the patch, from the "-p" option. Quilt uses 1 if the option
+	// is not set.
+	Strip int
+
+	// Reverse is true if the patch is applied in reverse, from the "-R"
+	// option
+	Reverse bool
+}
+
+// SeriesPatch is a patch loaded from a quilt patches directory.
+type SeriesPatch struct {
+	SeriesEntry
+
+	// Preamble is the text before the first file in the patch, usually a
+	// description of the change
+	Preamble string
+
+	// Header is the header parsed from Preamble if the patch was generated
+	// by Git, or nil otherwise
+	Header *PatchHeader
+
+	// Files contains the files changed by the patch
+	Files []*File
+}
+
+// SeriesError is the error returned when reading or applying a patch in a
+// series fails. It identifies the first patch that failed.
+type SeriesError struct {
+	// Index is the zero-indexed position of the patch in the series
+	Index int
+	// Name is the name of the patch from the series file
+	Name string
+
+	err error
+}
+
+func (e *SeriesError) Error() string {
+	return fmt.Sprintf("gitdiff: series: patch %s: %v", e.Name, e.err)
+}
+
+// Unwrap returns the wrapped error.
+func (e *SeriesError) Unwrap() error {
+	return e.err
+}
+
+// ParseSeries parses a quilt series file, which lists the names of patches
+// in the order they apply, one per line, optionally followed by the "-p<n>"
+// and "-R" options. Empty lines and comments starting with "#" are ignored.
+func ParseSeries(r io.Reader) ([]SeriesEntry, error) {
+	var entries []SeriesEntry
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		e := SeriesEntry{Name: fields[0], Strip: 1}
+		for _, opt := range fields[1:] {
+			switch {
+			case opt == "-R":
+				e.Reverse = true
+			case strings.HasPrefix(opt, "-p"):
+				n, err := strconv.Atoi(opt[2:])
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("gitdiff: series: line %d: invalid strip option %q", lineno, opt)
+				}
+				e.Strip = n
+			default:
+				return nil, fmt.Errorf("gitdiff: series: line %d: unsupported option %q", lineno, opt)
+			}
+		}
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// WriteSeries writes entries to w in the format of a quilt series file. It
+// only writes the "-p<n>" option if Strip is not 1.
+func WriteSeries(w io.Writer, entries []SeriesEntry) error {
+	fm := newFormatter(w)
+	for _, e := range entries {
+		fm.WriteString(e.Name)
+		if e.Strip != 1 {
+			fm.Format(" -p%d", e.Strip)
+		}
+		if e.Reverse {
+			fm.WriteString(" -R")
+		}
+		fm.WriteByte('\n')
+	}
+	return fm.err
+}
+
+// ReadSeriesDir reads the patches listed in the series file of a quilt
+// patches directory, in order. Options configure the parsing of each patch in
+// the same way as for ParseAll, except that the strip option of each patch
+// replaces WithStripComponents. If a patch can't be read or parsed,
+// ReadSeriesDir returns a *SeriesError.
+func ReadSeriesDir(fsys fs.FS, opts ...ParseOption) ([]*SeriesPatch, error) {
+	f, err := fsys.Open(seriesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := ParseSeries(f)
+	if err != nil {
+		return nil, err
+	}
+
+	patches := make([]*SeriesPatch, 0, len(entries))
+	for i, e := range entries {
+		data, err := fs.ReadFile(fsys, path.Clean(e.Name))
+		if err != nil {
+			return nil, &SeriesError{Index: i, Name: e.Name, err: err}
+		}
+
+		files, preamble, err := ParseAll(strings.NewReader(string(data)), append(opts[:len(opts):len(opts)], WithStripComponents(e.Strip))...)
+		if err != nil {
+			return nil, &SeriesError{Index: i, Name: e.Name, err: err}
+		}
+
+		p := &SeriesPatch{SeriesEntry: e, Preamble: preamble, Files: files}
+		if h, err := ParsePatchHeader(preamble); err == nil {
+			p.Header = h
+			for _, f := range files {
+				f.PatchHeader = h
+			}
+		}
+		patches = append(patches, p)
+	}
+	return patches, nil
+}
+
+// WriteSeriesDir writes patches to fsys as a quilt patches directory. It
+// writes the preamble and files of each patch to the file with the name of the
+// patch and then writes the series file. Files are formatted like Git patches
+// with "a/" and "b/" prefixes, so the series file uses the default strip
+// option for all patches.
+func WriteSeriesDir(fsys WriteFS, patches []*SeriesPatch) error {
+	entries := make([]SeriesEntry, 0, len(patches))
+	for i, p := range patches {
+		var b strings.Builder
+		b.WriteString(p.Preamble)
+		for _, f := range p.Files {
+			if _, err := Format(&b, f); err != nil {
+				return &SeriesError{Index: i, Name: p.Name, err: err}
+			}
+		}
+		if err := fsys.WriteFile(path.Clean(p.Name), []byte(b.String()), 0644); err != nil {
+			return &SeriesError{Index: i, Name: p.Name, err: err}
+		}
+		entries = append(entries, SeriesEntry{Name: p.Name, Strip: 1, Reverse: p.Reverse})
+	}
+
+	var b strings.Builder
+	if err := WriteSeries(&b, entries); err != nil {
+		return err
+	}
+	return fsys.WriteFile(seriesFile, []byte(b.String()), 0644)
+}
+
+// ApplySeries applies patches to the files in fsys in order, like "quilt push
+// -a". Each patch is applied with ApplyFS, in reverse if the Reverse option of
+// the patch is set. Options configure the application of each patch in the
+// same way as for ApplyFS.
+//
+// If a patch does not apply, ApplySeries stops and returns a *SeriesError for
+// that patch that wraps the error from ApplyFS. The patches before it remain
+// applied and fsys does not contain any changes from the failing patch.
+func ApplySeries(fsys WriteFS, patches []*SeriesPatch, opts ...ApplierOption) error {
+	for i, p := range patches {
+		popts := opts
+		if p.Reverse {
+			popts = append(popts[:len(popts):len(popts)], WithReverse())
+		}
+		if err := ApplyFS(fsys, p.Files, popts...); err != nil {
+			return &SeriesError{Index: i, Name: p.Name, err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package gitdiff
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseSeries(t *testing.T) {
+	tests := map[string]struct {
+		Input   string
+		Entries []SeriesEntry
+		Err     interface{}
+	}{
+		"options": {
+			Input: "# comment\n\nfirst.patch\nsub/second.patch -p0 -R\nthird.patch -p2 # trailing comment\n",
+			Entries: []SeriesEntry{
+				{Name: "first.patch", Strip: 1},
+				{Name: "sub/second.patch", Strip: 0, Reverse: true},
+				{Name: "third.patch", Strip: 2},
+			},
+		},
+		"empty": {
+			Input: "\n# nothing\n",
+		},
+		"invalidStrip": {
+			Input: "first.patch -px\n",
+			Err:   "line 1: invalid strip option",
+		},
+		"unsupportedOption": {
+			Input: "first.patch\nsecond.patch --fuzz=2\n",
+			Err:   "line 2: unsupported option",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			entries, err := ParseSeries(strings.NewReader(test.Input))
+			if test.Err != nil {
+				assertError(t, test.Err, err, "parsing series")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing series: %v", err)
+			}
+			if !reflect.DeepEqual(test.Entries, entries) {
+				t.Errorf("incorrect entries\nexpected: %+v\n  actual: %+v", test.Entries, entries)
+			}
+
+			var b strings.Builder
+			if err := WriteSeries(&b, entries); err != nil {
+				t.Fatalf("unexpected error writing series: %v", err)
+			}
+			written, err := ParseSeries(strings.NewReader(b.String()))
+			if err != nil {
+				t.Fatalf("unexpected error parsing written series: %v", err)
+			}
+			if !reflect.DeepEqual(entries, written) {
+				t.Errorf("incorrect written series:\n%s", b.String())
+			}
+		})
+	}
+}
+
+func TestReadSeriesDir(t *testing.T) {
+	patches, err := ReadSeriesDir(os.DirFS(filepath.Join("testdata", "series")))
+	if err != nil {
+		t.Fatalf("unexpected error reading series: %v", err)
+	}
+	if len(patches) != 3 {
+		t.Fatalf("expected 3 patches, but got %d", len(patches))
+	}
+
+	for i, name := range []string{"mod.txt", "new.txt", "extra.txt"} {
+		if files := patches[i].Files; len(files) != 1 || files[0].NewName != name {
+			t.Errorf("incorrect files for patch %d: %+v", i, files)
+		}
+	}
+	if patches[0].Header != nil || patches[0].Preamble != "Capitalize the second line\n\n" {
+		t.Errorf("incorrect preamble for patch 0: %q", patches[0].Preamble)
+	}
+	if h := patches[1].Header; h == nil || h.Title != "Add a new file" {
+		t.Errorf("incorrect header for patch 1: %+v", h)
+	}
+	if !patches[2].Reverse {
+		t.Errorf("expected patch 2 to be reversed")
+	}
+
+	fsys := &memFS{MapFS: fstest.MapFS{}}
+	if err := WriteSeriesDir(fsys, patches); err != nil {
+		t.Fatalf("unexpected error writing series: %v", err)
+	}
+	if series := string(fsys.MapFS["series"].Data); series != "fix-mod.patch\nadd-file.patch\nundo-extra.patch -R\n" {
+		t.Errorf("incorrect series file:\n%s", series)
+	}
+
+	written, err := ReadSeriesDir(fsys.MapFS)
+	if err != nil {
+		t.Fatalf("unexpected error reading written series: %v", err)
+	}
+	for i, p := range patches {
+		w := written[i]
+		if w.Name != p.Name || w.Reverse != p.Reverse || w.Preamble != p.Preamble {
+			t.Errorf("incorrect written patch %d: %+v", i, w.SeriesEntry)
+		}
+		if len(w.Files) != len(p.Files) || w.Files[0].String() != p.Files[0].String() {
+			t.Errorf("incorrect files for written patch %d", i)
+		}
+	}
+}
+
+func TestApplySeries(t *testing.T) {
+	patches, err := ReadSeriesDir(os.DirFS(filepath.Join("testdata", "series")))
+	if err != nil {
+		t.Fatalf("unexpected error reading series: %v", err)
+	}
+
+	fsys := &memFS{MapFS: fstest.MapFS{
+		"mod.txt":   {Data: []byte("a\nb\nc\n"), Mode: 0644},
+		"extra.txt": {Data: []byte("keep\nremove me\n"), Mode: 0644},
+	}}
+	if err := ApplySeries(fsys, patches); err != nil {
+		t.Fatalf("unexpected error applying series: %v", err)
+	}
+	assertMapFS(t, fstest.MapFS{
+		"mod.txt":   {Data: []byte("a\nB\nc\n"), Mode: 0644},
+		"new.txt":   {Data: []byte("hello\n"), Mode: 0644},
+		"extra.txt": {Data: []byte("keep\n"), Mode: 0644},
+	}, fsys.MapFS)
+
+	fsys = &memFS{MapFS: fstest.MapFS{
+		"mod.txt": {Data: []byte("a\nb\nc\n"), Mode: 0644},
+		"new.txt": {Data: []byte("exists\n"), Mode: 0644},
+	}}
+	err = ApplySeries(fsys, patches)
+
+	var serr *SeriesError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected SeriesError, but got %v", err)
+	}
+	if serr.Index != 1 || serr.Name != "add-file.patch" {
+		t.Errorf("incorrect failing patch: %d %s", serr.Index, serr.Name)
+	}
+	assertError(t, &Conflict{}, err, "applying series")
+	assertMapFS(t, fstest.MapFS{
+		"mod.txt": {Data: []byte("a\nB\nc\n"), Mode: 0644},
+		"new.txt": {Data: []byte("exists\n"), Mode: 0644},
+	}, fsys.MapFS)
+}
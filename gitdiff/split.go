@@ -0,0 +1,139 @@
+package gitdiff
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// mailStatWidth is the width of the diffstat in "git format-patch" messages.
+const mailStatWidth = 72
+
+// SplitPatch is a standalone patch created by SplitBy.
+type SplitPatch struct {
+	// Key is the value returned by the grouping function for the files
+	Key string
+
+	// Files contains the files in the patch, in their original order
+	Files []*File
+
+	// Data is the formatted patch
+	Data []byte
+}
+
+// Split returns one standalone patch for each file, in the order of the
+// files. See SplitBy for the content of each patch.
+func Split(files []*File, header *PatchHeader) ([][]byte, error) {
+	data := make([][]byte, len(files))
+	for i, f := range files {
+		b, err := formatSplit(header, []*File{f})
+		if err != nil {
+			return nil, err
+		}
+		data[i] = b
+	}
+	return data, nil
+}
+
+// SplitBy groups files by the key returned by group and returns one
+// standalone patch for each group, in the order that the groups first appear
+// in files. Use ByDirectory to group files by directory.
+//
+// If header is nil, each patch contains only the files in its group.
+// Otherwise, each patch is a message in the format of "git format-patch"
+// that starts with the mail header, followed by the files and the signature
+// of the header, like the output of "git format-patch" limited to the files in
+// the group. The appendix of the header is replaced with the diffstat and the
+// summary of the files in the group, because the original appendix describes
+// all of the files. The base of the header is not included.
+func SplitBy(files []*File, header *PatchHeader, group func(f *File) string) ([]SplitPatch, error) {
+	var patches []SplitPatch
+	index := make(map[string]int)
+	for _, f := range files {
+		key := group(f)
+		i, ok := index[key]
+		if !ok {
+			i = len(patches)
+			index[key] = i
+			patches = append(patches, SplitPatch{Key: key})
+		}
+		patches[i].Files = append(patches[i].Files, f)
+	}
+
+	for i := range patches {
+		b, err := formatSplit(header, patches[i].Files)
+		if err != nil {
+			return nil, err
+		}
+		patches[i].Data = b
+	}
+	return patches, nil
+}
+
+// ByDirectory returns a grouping function for SplitBy that groups files by
+// the first depth components of the directory containing them, or by the
+// full directory if depth is zero or negative. Files at the root of the
+// repository have the key ".". Files are grouped by their new name, or by
+// their old name if they are deleted.
+func ByDirectory(depth int) func(f *File) string {
+	return func(f *File) string {
+		name := f.NewName
+		if f.IsDelete || name == "" {
+			name = f.OldName
+		}
+		dir := path.Dir(name)
+		if depth > 0 && dir != "." {
+			parts := strings.SplitN(dir, "/", depth+1)
+			if len(parts) > depth {
+				parts = parts[:depth]
+			}
+			dir = strings.Join(parts, "/")
+		}
+		return dir
+	}
+}
+
+// formatSplit formats a standalone patch containing files.
+func formatSplit(header *PatchHeader, files []*File) ([]byte, error) {
+	var b bytes.Buffer
+	if header != nil {
+		if _, err := FormatPatch(&b, header, files); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+	for _, f := range files {
+		if _, err := Format(&b, f); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// formatSummary returns the summary of the files in the format of "git diff
+// --summary", with a newline before each line instead of after it.
+func formatSummary(files []*File) string {
+	var b strings.Builder
+	for _, f := range files {
+		name := quoteName(f.NewName)
+		switch {
+		case f.IsNew:
+			fmt.Fprintf(&b, "\n create mode %06o %s", f.NewMode, name)
+		case f.IsDelete:
+			fmt.Fprintf(&b, "\n delete mode %06o %s", f.OldMode, quoteName(f.OldName))
+		case f.IsRename || f.IsCopy:
+			op := "rename"
+			if f.IsCopy {
+				op = "copy"
+			}
+			fmt.Fprintf(&b, "\n %s %s (%d%%)", op, formatRenameName(f.OldName, f.NewName), f.Score)
+			if f.OldMode != 0 && f.NewMode != 0 && f.OldMode != f.NewMode {
+				fmt.Fprintf(&b, "\n mode change %06o => %06o", f.OldMode, f.NewMode)
+			}
+		case f.OldMode != 0 && f.NewMode != 0 && f.OldMode != f.NewMode:
+			fmt.Fprintf(&b, "\n mode change %06o => %06o %s", f.OldMode, f.NewMode, name)
+		}
+	}
+	return b.String()
+}
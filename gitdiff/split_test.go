@@ -0,0 +1,118 @@
+package gitdiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitBy(t *testing.T) {
+	tests := map[string]struct {
+		Patch    string
+		Group    func(f *File) string
+		Expected map[string]string
+		Keys     []string
+	}{
+		"byDirectory": {
+			Patch: "split.patch",
+			Group: ByDirectory(0),
+			Keys:  []string{".", "lib/a", "lib/b"},
+			Expected: map[string]string{
+				".":     "split_root.patch",
+				"lib/a": "split_lib_a.patch",
+				"lib/b": "split_lib_b.patch",
+			},
+		},
+		"byTopDirectory": {
+			Patch: "split_rename.patch",
+			Group: ByDirectory(1),
+			Keys:  []string{"lib"},
+			Expected: map[string]string{
+				"lib": "split_rename.patch",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			patches := parseSplitPatch(t, test.Patch)
+
+			split, err := SplitBy(patches[0].Files, patches[0].Header, test.Group)
+			if err != nil {
+				t.Fatalf("unexpected error splitting patch: %v", err)
+			}
+			if len(split) != len(test.Keys) {
+				t.Fatalf("incorrect number of patches: expected %d, actual %d", len(test.Keys), len(split))
+			}
+			for i, p := range split {
+				if p.Key != test.Keys[i] {
+					t.Errorf("patch %d: incorrect key: expected %q, actual %q", i, test.Keys[i], p.Key)
+				}
+				expected, err := os.ReadFile(filepath.Join("testdata", test.Expected[p.Key]))
+				if err != nil {
+					t.Fatalf("failed to read expected patch: %v", err)
+				}
+				if !bytes.Equal(expected, p.Data) {
+					t.Errorf("patch %q: incorrect content\nexpected:\n%s\nactual:\n%s", p.Key, expected, p.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	patches := parseSplitPatch(t, "split.patch")
+	files := patches[0].Files
+
+	t.Run("header", func(t *testing.T) {
+		split, err := Split(files, patches[0].Header)
+		if err != nil {
+			t.Fatalf("unexpected error splitting patch: %v", err)
+		}
+		if len(split) != len(files) {
+			t.Fatalf("incorrect number of patches: expected %d, actual %d", len(files), len(split))
+		}
+		for i, data := range split {
+			parsed, err := ParseMailbox(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("patch %d: unexpected error parsing patch: %v", i, err)
+			}
+			if len(parsed) != 1 || len(parsed[0].Files) != 1 {
+				t.Fatalf("patch %d: expected 1 patch with 1 file", i)
+			}
+
+			h := parsed[0].Header
+			if h.Title != patches[0].Header.Title || h.Body != patches[0].Header.Body || h.Signature != patches[0].Header.Signature {
+				t.Errorf("patch %d: header does not match original\nexpected: %+v\n  actual: %+v", i, patches[0].Header, h)
+			}
+			if actual, expected := parsed[0].Files[0].String(), files[i].String(); actual != expected {
+				t.Errorf("patch %d: incorrect file\nexpected:\n%s\nactual:\n%s", i, expected, actual)
+			}
+		}
+	})
+
+	t.Run("noHeader", func(t *testing.T) {
+		split, err := Split(files, nil)
+		if err != nil {
+			t.Fatalf("unexpected error splitting patch: %v", err)
+		}
+		for i, data := range split {
+			if expected := files[i].String(); string(data) != expected {
+				t.Errorf("patch %d: incorrect content\nexpected:\n%s\nactual:\n%s", i, expected, data)
+			}
+		}
+	})
+}
+
+func parseSplitPatch(t *testing.T, name string) []*Patch {
+	patches, err := ParseMailbox(strings.NewReader(readTestPatch(t, name)))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(patches) != 1 {
+		t.Fatalf("expected 1 patch, but got %d", len(patches))
+	}
+	return patches
+}
@@ -0,0 +1,565 @@
+package gitdiff
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// defaultStatWidth is the width of the output of "git diff --stat" when it
+// does not write to a terminal.
+const defaultStatWidth = 80
+
+// FileStat summarizes the changes to a single file, like a line of the output
+// of "git diff --stat" or "git diff --numstat".
+type FileStat struct {
+	OldName string
+	NewName string
+
+	IsCopy   bool
+	IsRename bool
+	IsBinary bool
+
+	// LinesAdded and LinesDeleted are the number of lines added to and
+	// deleted from a text file. They are zero for binary files.
+	LinesAdded   int64
+	LinesDeleted int64
+
+	// OldSize and NewSize are the sizes in bytes of a binary file before and
+	// after the changes. Both are zero if either size is unknown.
+	OldSize int64
+	NewSize int64
+}
+
+// Stat returns a summary of the changes to the file. For combined files, the
+// counts compare the merge result to the first parent, like "git show --stat".
+// For binary files, Stat finds the sizes of the file from the binary fragments
+// if the patch contains enough data to determine both sizes. For files from
+// WithLazyFragments, Stat parses the fragments without keeping them and
+// returns a summary without line counts if the fragments are invalid.
+func (f *File) Stat() *FileStat {
+	if f.lazy != nil {
+		loaded := *f
+		if err := loaded.LoadFragments(); err == nil {
+			return loaded.Stat()
+		}
+	}
+
+	s := &FileStat{
+		OldName:  f.OldName,
+		NewName:  f.NewName,
+		IsCopy:   f.IsCopy,
+		IsRename: f.IsRename,
+		IsBinary: f.IsBinary,
+	}
+	switch {
+	case s.OldName == "":
+		s.OldName = s.NewName
+	case s.NewName == "":
+		s.NewName = s.OldName
+	}
+
+	if f.IsBinary {
+		s.OldSize, s.NewSize = f.binarySizes()
+		return s
+	}
+	for _, frag := range f.TextFragments {
+		s.LinesAdded += frag.LinesAdded
+		s.LinesDeleted += frag.LinesDeleted
+	}
+	for _, frag := range f.CombinedFragments {
+		parent := frag.Parent(0)
+		s.LinesAdded += parent.LinesAdded
+		s.LinesDeleted += parent.LinesDeleted
+	}
+	return s
+}
+
+// binarySizes returns the old and new sizes of a binary file or zero for both
+// if either size is unknown.
+func (f *File) binarySizes() (oldSize, newSize int64) {
+	oldSize, newSize = -1, -1
+	if f.IsNew {
+		oldSize = 0
+	}
+	if f.IsDelete {
+		newSize = 0
+	}
+
+	if frag := f.BinaryFragment; frag != nil {
+		switch frag.Method {
+		case BinaryPatchLiteral:
+			newSize = frag.Size
+		case BinaryPatchDelta:
+			src, rest := readBinaryDeltaSize(frag.Data)
+			dst, _ := readBinaryDeltaSize(rest)
+			oldSize, newSize = src, dst
+		}
+	}
+	if frag := f.ReverseBinaryFragment; frag != nil {
+		switch frag.Method {
+		case BinaryPatchLiteral:
+			oldSize = frag.Size
+		case BinaryPatchDelta:
+			src, rest := readBinaryDeltaSize(frag.Data)
+			dst, _ := readBinaryDeltaSize(rest)
+			oldSize, newSize = dst, src
+		}
+	}
+
+	if oldSize < 0 || newSize < 0 {
+		return 0, 0
+	}
+	return oldSize, newSize
+}
+
+// Name returns the name of the file as it appears in the output of "git diff
+// --stat". Renamed and copied files show both names with any common prefix
+// and suffix factored out, like "dir/{old.txt => new.txt}".
+func (s *FileStat) Name() string {
+	if !s.IsRename && !s.IsCopy {
+		return quoteName(s.NewName)
+	}
+	return formatRenameName(s.OldName, s.NewName)
+}
+
+// formatRenameName returns a string showing a change from name a to name b,
+// in the same format as Git.
+func formatRenameName(a, b string) string {
+	if quoteName(a) != a || quoteName(b) != b {
+		return quoteName(a) + " => " + quoteName(b)
+	}
+
+	// find the common prefix, ending at a slash
+	prefix := 0
+	for i := 0; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+		if a[i] == '/' {
+			prefix = i + 1
+		}
+	}
+
+	// find the common suffix, starting at a slash; if there is a prefix, the
+	// suffix may share its slash
+	suffix := 0
+	min := prefix
+	if prefix > 0 {
+		min--
+	}
+	for i, j := len(a), len(b); i >= min && j >= min && byteAt(a, i) == byteAt(b, j); i, j = i-1, j-1 {
+		if byteAt(a, i) == '/' {
+			suffix = len(a) - i
+		}
+	}
+
+	aMid := a[prefix:int(max(int64(prefix), int64(len(a)-suffix)))]
+	bMid := b[prefix:int(max(int64(prefix), int64(len(b)-suffix)))]
+	if prefix+suffix == 0 {
+		return aMid + " => " + bMid
+	}
+	return a[:prefix] + "{" + aMid + " => " + bMid + "}" + a[len(a)-suffix:]
+}
+
+// byteAt returns the byte at index i of s or zero if i is the length of s.
+func byteAt(s string, i int) byte {
+	if i == len(s) {
+		return 0
+	}
+	return s[i]
+}
+
+// Stat summarizes the changes to a set of files, like the output of "git diff
+// --stat".
+type Stat struct {
+	Files []*FileStat
+}
+
+// NewStat returns a summary of the changes to files.
+func NewStat(files []*File) *Stat {
+	s := &Stat{Files: make([]*FileStat, len(files))}
+	for i, f := range files {
+		s.Files[i] = f.Stat()
+	}
+	return s
+}
+
+// LinesAdded returns the total number of lines added to text files.
+func (s *Stat) LinesAdded() int64 {
+	var n int64
+	for _, f := range s.Files {
+		n += f.LinesAdded
+	}
+	return n
+}
+
+// LinesDeleted returns the total number of lines deleted from text files.
+func (s *Stat) LinesDeleted() int64 {
+	var n int64
+	for _, f := range s.Files {
+		n += f.LinesDeleted
+	}
+	return n
+}
+
+// String returns the summary in the format of "git diff --stat", using a
+// total width of 80 columns.
+func (s *Stat) String() string {
+	return s.FormatWidth(defaultStatWidth)
+}
+
+// FormatWidth returns the summary in the format of "git diff --stat=<width>".
+// Like Git, it scales the histogram of changes to fit in width columns and
+// shortens long names, but never makes the lines narrower than the minimum
+// Git allows.
+func (s *Stat) FormatWidth(width int) string {
+	var maxName, maxBin int
+	var maxChange int64
+	numberWidth := 0
+	for _, f := range s.Files {
+		if n := len(f.Name()); n > maxName {
+			maxName = n
+		}
+		if f.IsBinary {
+			// "Bin XXX -> YYY bytes"
+			if w := 14 + decimalWidth(f.OldSize) + decimalWidth(f.NewSize); w > maxBin {
+				maxBin = w
+			}
+			numberWidth = 3
+			continue
+		}
+		if change := f.LinesAdded + f.LinesDeleted; change > maxChange {
+			maxChange = change
+		}
+	}
+	if w := decimalWidth(maxChange); w > numberWidth {
+		numberWidth = w
+	}
+
+	// use the same widths as Git, which guarantees at least 6 columns for
+	// the histogram and 10 columns for the name
+	if width < 16+6+numberWidth {
+		width = 16 + 6 + numberWidth
+	}
+	graphWidth := int(maxChange)
+	if maxChange+4 <= int64(maxBin) {
+		graphWidth = maxBin - 4
+	}
+	nameWidth := maxName
+	if nameWidth+numberWidth+6+graphWidth > width {
+		if limit := width*3/8 - numberWidth - 6; graphWidth > limit {
+			graphWidth = int(max(int64(limit), 6))
+		}
+		if limit := width - numberWidth - 6 - graphWidth; nameWidth > limit {
+			nameWidth = limit
+		} else {
+			graphWidth = width - numberWidth - 6 - nameWidth
+		}
+	}
+
+	var b strings.Builder
+	for _, f := range s.Files {
+		prefix, name := "", f.Name()
+		if len(name) > nameWidth {
+			prefix, name = "...", name[len(name)-int(max(int64(nameWidth-3), 0)):]
+			if i := strings.IndexByte(name, '/'); i >= 0 {
+				name = name[i:]
+			}
+		}
+		fmt.Fprintf(&b, " %s%-*s |", prefix, nameWidth-len(prefix), name)
+
+		if f.IsBinary {
+			fmt.Fprintf(&b, " %*s", numberWidth, "Bin")
+			if f.OldSize != 0 || f.NewSize != 0 {
+				fmt.Fprintf(&b, " %d -> %d bytes", f.OldSize, f.NewSize)
+			}
+			b.WriteByte('\n')
+			continue
+		}
+
+		added, deleted := f.LinesAdded, f.LinesDeleted
+		fmt.Fprintf(&b, " %*d", numberWidth, added+deleted)
+		if added+deleted > 0 {
+			b.WriteByte(' ')
+		}
+		if int64(graphWidth) <= maxChange {
+			total := scaleStat(added+deleted, graphWidth, maxChange)
+			if total < 2 && added > 0 && deleted > 0 {
+				total = 2
+			}
+			if added < deleted {
+				added = scaleStat(added, graphWidth, maxChange)
+				deleted = total - added
+			} else {
+				deleted = scaleStat(deleted, graphWidth, maxChange)
+				added = total - deleted
+			}
+		}
+		b.WriteString(strings.Repeat("+", int(added)))
+		b.WriteString(strings.Repeat("-", int(deleted)))
+		b.WriteByte('\n')
+	}
+	b.WriteString(s.ShortStat())
+	return b.String()
+}
+
+// scaleStat scales n from the range [0, max] to the range [0, width], making
+// sure that it is at least one if n is not zero.
+func scaleStat(n int64, width int, max int64) int64 {
+	if n == 0 {
+		return 0
+	}
+	return 1 + n*int64(width-1)/max
+}
+
+// NumStat returns the summary in the format of "git diff --numstat". Binary
+// files show "-" for the number of added and deleted lines.
+func (s *Stat) NumStat() string {
+	var b strings.Builder
+	for _, f := range s.Files {
+		if f.IsBinary {
+			b.WriteString("-\t-\t")
+		} else {
+			fmt.Fprintf(&b, "%d\t%d\t", f.LinesAdded, f.LinesDeleted)
+		}
+		b.WriteString(f.Name())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// ShortStat returns the summary in the format of "git diff --shortstat", which
+// includes the number of changed files and the total number of added and
+// deleted lines in text files.
+func (s *Stat) ShortStat() string {
+	if len(s.Files) == 0 {
+		return " 0 files changed\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, " %d %s changed", len(s.Files), plural(int64(len(s.Files)), "file", "files"))
+
+	added, deleted := s.LinesAdded(), s.LinesDeleted()
+	if added > 0 || deleted == 0 {
+		fmt.Fprintf(&b, ", %d %s(+)", added, plural(added, "insertion", "insertions"))
+	}
+	if deleted > 0 || added == 0 {
+		fmt.Fprintf(&b, ", %d %s(-)", deleted, plural(deleted, "deletion", "deletions"))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+func plural(n int64, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+func decimalWidth(n int64) int {
+	w := 1
+	for ; n >= 10; n /= 10 {
+		w++
+	}
+	return w
+}
+
+// ParseNumStat parses the output of "git diff --numstat". Binary files, which
+// Git shows with "-" for the number of added and deleted lines, have IsBinary
+// set and unknown sizes. Files with both an old and a new name are reported
+// as renames, because numstat output does not distinguish renames from
+// copies. ParseNumStat ignores empty lines.
+func ParseNumStat(r io.Reader) (*Stat, error) {
+	return parseStat(r, parseNumStatLine)
+}
+
+// ParseStat parses the output of "git diff --stat", ignoring the summary line
+// and any empty lines. Because Git shortens long names, the name of a file may
+// be the end of the real name, starting with "...".
+//
+// Each line of the output reports the total number of changed lines and a
+// histogram of the added and deleted lines. ParseStat counts the exact number
+// of added and deleted lines if the histogram is not scaled. Otherwise, it
+// estimates the number of added and deleted lines from the histogram.
+func ParseStat(r io.Reader) (*Stat, error) {
+	return parseStat(r, parseStatLine)
+}
+
+func parseStat(r io.Reader, parseLine func(string) (*FileStat, error)) (*Stat, error) {
+	p := newParser(r)
+	s := &Stat{}
+	for {
+		if err := p.Next(); err != nil {
+			if err == io.EOF {
+				return s, nil
+			}
+			return nil, err
+		}
+
+		line := strings.TrimSuffix(p.Line(0), "\n")
+		if strings.TrimSpace(line) == "" || isStatSummary(line) {
+			continue
+		}
+
+		f, err := parseLine(line)
+		if err != nil {
+			return nil, p.Errorf(0, KindStat, "%w", err)
+		}
+		s.Files = append(s.Files, f)
+	}
+}
+
+func parseNumStatLine(line string) (*FileStat, error) {
+	parts := strings.SplitN(line, "\t", 3)
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("invalid numstat line: %q", line)
+	}
+
+	f := &FileStat{}
+	if parts[0] == "-" && parts[1] == "-" {
+		f.IsBinary = true
+	} else {
+		var err error
+		if f.LinesAdded, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid number of added lines: %q", parts[0])
+		}
+		if f.LinesDeleted, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid number of deleted lines: %q", parts[1])
+		}
+	}
+
+	if err := parseStatName(f, parts[2]); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parseStatLine(line string) (*FileStat, error) {
+	sep := strings.LastIndex(line, " | ")
+	if sep < 0 || line[0] != ' ' {
+		return nil, fmt.Errorf("invalid stat line: %q", line)
+	}
+
+	f := &FileStat{}
+	if err := parseStatName(f, strings.TrimRight(line[1:sep], " ")); err != nil {
+		return nil, err
+	}
+
+	changes := strings.TrimSpace(line[sep+3:])
+	if changes == "Bin" || strings.HasPrefix(changes, "Bin ") {
+		f.IsBinary = true
+		if changes != "Bin" {
+			if _, err := fmt.Sscanf(changes, "Bin %d -> %d bytes", &f.OldSize, &f.NewSize); err != nil {
+				return nil, fmt.Errorf("invalid binary file sizes: %q", changes)
+			}
+		}
+		return f, nil
+	}
+
+	count, graph := changes, ""
+	if i := strings.IndexByte(changes, ' '); i >= 0 {
+		count, graph = changes[:i], changes[i+1:]
+	}
+	total, err := strconv.ParseInt(count, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number of changed lines: %q", count)
+	}
+
+	added := int64(strings.Count(graph, "+"))
+	deleted := int64(strings.Count(graph, "-"))
+	switch {
+	case added+deleted != int64(len(graph)) || strings.Contains(graph, "-+"):
+		return nil, fmt.Errorf("invalid histogram: %q", graph)
+	case (added+deleted == 0) != (total == 0):
+		return nil, fmt.Errorf("histogram does not match %d changed lines", total)
+	case added+deleted != total:
+		// the histogram is scaled, so round to the closest proportion
+		added = (2*total*added + added + deleted) / (2 * (added + deleted))
+		deleted = total - added
+	}
+	f.LinesAdded, f.LinesDeleted = added, deleted
+	return f, nil
+}
+
+// parseStatName parses a name as formatted by FileStat.Name and sets the
+// names of f. Names of renamed files may use braces to show the changed part
+// of the name, like "dir/{old.txt => new.txt}".
+func parseStatName(f *FileStat, s string) error {
+	if s == "" {
+		return fmt.Errorf("missing name")
+	}
+
+	if s[0] == '"' {
+		name, n, err := parseQuotedName(s)
+		if err != nil {
+			return err
+		}
+		f.OldName, f.NewName = name, name
+		if n == len(s) {
+			return nil
+		}
+		if !strings.HasPrefix(s[n:], " => ") {
+			return fmt.Errorf("invalid name: %q", s)
+		}
+		f.IsRename = true
+		if f.NewName, err = parseStatUnquoteName(s[n+4:]); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	sep := strings.Index(s, " => ")
+	if sep < 0 {
+		f.OldName, f.NewName = s, s
+		return nil
+	}
+	f.IsRename = true
+
+	open := strings.LastIndexByte(s[:sep], '{')
+	end := strings.IndexByte(s[sep:], '}')
+	if open < 0 || end < 0 {
+		var err error
+		f.OldName = s[:sep]
+		f.NewName, err = parseStatUnquoteName(s[sep+4:])
+		return err
+	}
+
+	prefix, suffix := s[:open], s[sep+end+1:]
+	f.OldName = joinStatName(prefix, s[open+1:sep], suffix)
+	f.NewName = joinStatName(prefix, s[sep+4:sep+end], suffix)
+	return nil
+}
+
+func parseStatUnquoteName(s string) (string, error) {
+	if s == "" {
+		return "", fmt.Errorf("missing name")
+	}
+	if s[0] != '"' {
+		return s, nil
+	}
+	name, n, err := parseQuotedName(s)
+	if err == nil && n != len(s) {
+		err = fmt.Errorf("invalid name: %q", s)
+	}
+	return name, err
+}
+
+// joinStatName joins the parts of a name, removing the duplicate slash left
+// when the changed part of the name is empty.
+func joinStatName(prefix, mid, suffix string) string {
+	if mid == "" && (prefix == "" || strings.HasSuffix(prefix, "/")) {
+		suffix = strings.TrimPrefix(suffix, "/")
+	}
+	return prefix + mid + suffix
+}
+
+// isStatSummary returns true if line is the summary line of "git diff --stat"
+// or "git diff --shortstat".
+func isStatSummary(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || (fields[1] != "file" && fields[1] != "files") || !strings.HasPrefix(fields[2], "changed") {
+		return false
+	}
+	_, err := strconv.Atoi(fields[0])
+	return err == nil
+}
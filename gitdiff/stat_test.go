@@ -0,0 +1,327 @@
+package gitdiff
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStat(t *testing.T) {
+	patch, err := os.Open(filepath.Join("testdata", "stat.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer patch.Close()
+
+	files, _, err := ParseAll(patch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+	s := NewStat(files)
+
+	tests := map[string]struct {
+		Output   string
+		Expected string
+	}{
+		"stat": {
+			Output: s.String(),
+			Expected: ` big.txt                                            | 200 ++++++++++-----------
+ bin.dat                                            | Bin 3000 -> 4000 bytes
+ del.txt                                            |   5 -
+ dir/sub/{old.txt => new.txt}                       |   1 +
+ mode.sh                                            |   0
+ newbin.dat                                         | Bin 0 -> 500 bytes
+ small.txt                                          |   2 +-
+ sp ace.txt                                         |   1 +
+ .../that/goes/on/and/on/forever/and/ever/file.txt  |   1 +
+ 9 files changed, 104 insertions(+), 106 deletions(-)
+`,
+		},
+		"statNarrow": {
+			Output: s.FormatWidth(40),
+			Expected: ` big.txt                   | 200 +++---
+ bin.dat                   | Bin 3000 -> 4000 bytes
+ del.txt                   |   5 -
+ .../{old.txt => new.txt}  |   1 +
+ mode.sh                   |   0
+ newbin.dat                | Bin 0 -> 500 bytes
+ small.txt                 |   2 +-
+ sp ace.txt                |   1 +
+ .../and/ever/file.txt     |   1 +
+ 9 files changed, 104 insertions(+), 106 deletions(-)
+`,
+		},
+		"numStat": {
+			Output: s.NumStat(),
+			Expected: `100	100	big.txt
+-	-	bin.dat
+0	5	del.txt
+1	0	dir/sub/{old.txt => new.txt}
+0	0	mode.sh
+-	-	newbin.dat
+1	1	small.txt
+1	0	sp ace.txt
+1	0	very/long/directory/name/that/goes/on/and/on/forever/and/ever/file.txt
+`,
+		},
+		"shortStat": {
+			Output:   s.ShortStat(),
+			Expected: " 9 files changed, 104 insertions(+), 106 deletions(-)\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if test.Output != test.Expected {
+				t.Errorf("incorrect output\nexpected:\n%s\nactual:\n%s", test.Expected, test.Output)
+			}
+		})
+	}
+}
+
+func TestShortStat(t *testing.T) {
+	tests := map[string]struct {
+		Files  []*FileStat
+		Output string
+	}{
+		"empty": {
+			Output: " 0 files changed\n",
+		},
+		"singular": {
+			Files:  []*FileStat{{LinesAdded: 1, LinesDeleted: 1}},
+			Output: " 1 file changed, 1 insertion(+), 1 deletion(-)\n",
+		},
+		"onlyInsertions": {
+			Files:  []*FileStat{{LinesAdded: 2}, {LinesAdded: 3}},
+			Output: " 2 files changed, 5 insertions(+)\n",
+		},
+		"onlyDeletions": {
+			Files:  []*FileStat{{LinesDeleted: 2}},
+			Output: " 1 file changed, 2 deletions(-)\n",
+		},
+		"noLines": {
+			Files:  []*FileStat{{IsBinary: true, OldSize: 10, NewSize: 20}},
+			Output: " 1 file changed, 0 insertions(+), 0 deletions(-)\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := &Stat{Files: test.Files}
+			if out := s.ShortStat(); out != test.Output {
+				t.Errorf("incorrect output\nexpected: %q\n  actual: %q", test.Output, out)
+			}
+		})
+	}
+}
+
+func TestFileStatName(t *testing.T) {
+	tests := map[string]struct {
+		Stat FileStat
+		Name string
+	}{
+		"unchanged": {
+			Stat: FileStat{OldName: "dir/file.txt", NewName: "dir/file.txt"},
+			Name: "dir/file.txt",
+		},
+		"quoted": {
+			Stat: FileStat{OldName: "dir/\"file\".txt", NewName: "dir/\"file\".txt"},
+			Name: `"dir/\"file\".txt"`,
+		},
+		"renameFile": {
+			Stat: FileStat{OldName: "one.txt", NewName: "two.txt", IsRename: true},
+			Name: "one.txt => two.txt",
+		},
+		"renameDirectory": {
+			Stat: FileStat{OldName: "x/y/f", NewName: "z/y/f", IsRename: true},
+			Name: "{x => z}/y/f",
+		},
+		"renameInDirectory": {
+			Stat: FileStat{OldName: "dir/sub/old.txt", NewName: "dir/sub/new.txt", IsRename: true},
+			Name: "dir/sub/{old.txt => new.txt}",
+		},
+		"addDirectory": {
+			Stat: FileStat{OldName: "a/b", NewName: "a/c/b", IsRename: true},
+			Name: "a/{ => c}/b",
+		},
+		"removeDirectory": {
+			Stat: FileStat{OldName: "p/q.txt", NewName: "q.txt", IsCopy: true},
+			Name: "p/q.txt => q.txt",
+		},
+		"renameQuoted": {
+			Stat: FileStat{OldName: "dir/a\tb", NewName: "dir/ab", IsRename: true},
+			Name: `"dir/a\tb" => dir/ab`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if name := test.Stat.Name(); name != test.Name {
+				t.Errorf("incorrect name\nexpected: %s\n  actual: %s", test.Name, name)
+			}
+		})
+	}
+}
+
+func TestParseNumStat(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Output []*FileStat
+		Err    bool
+	}{
+		"text": {
+			Input: "100\t100\tbig.txt\n0\t5\tdel.txt\n",
+			Output: []*FileStat{
+				{OldName: "big.txt", NewName: "big.txt", LinesAdded: 100, LinesDeleted: 100},
+				{OldName: "del.txt", NewName: "del.txt", LinesDeleted: 5},
+			},
+		},
+		"binary": {
+			Input: "-\t-\tbin.dat\n",
+			Output: []*FileStat{
+				{OldName: "bin.dat", NewName: "bin.dat", IsBinary: true},
+			},
+		},
+		"rename": {
+			Input: "1\t0\tdir/sub/{old.txt => new.txt}\n0\t0\ta/{ => c}/b\n0\t0\tp/q.txt => q.txt\n",
+			Output: []*FileStat{
+				{OldName: "dir/sub/old.txt", NewName: "dir/sub/new.txt", IsRename: true, LinesAdded: 1},
+				{OldName: "a/b", NewName: "a/c/b", IsRename: true},
+				{OldName: "p/q.txt", NewName: "q.txt", IsRename: true},
+			},
+		},
+		"quoted": {
+			Input: "1\t0\t\"dir/a\\tb\"\n0\t0\t\"dir/a\\tb\" => dir/ab\n",
+			Output: []*FileStat{
+				{OldName: "dir/a\tb", NewName: "dir/a\tb", LinesAdded: 1},
+				{OldName: "dir/a\tb", NewName: "dir/ab", IsRename: true},
+			},
+		},
+		"emptyLines": {
+			Input: "\n1\t1\tfile.txt\n\n",
+			Output: []*FileStat{
+				{OldName: "file.txt", NewName: "file.txt", LinesAdded: 1, LinesDeleted: 1},
+			},
+		},
+		"missingName": {
+			Input: "1\t1\n",
+			Err:   true,
+		},
+		"invalidCount": {
+			Input: "1\tx\tfile.txt\n",
+			Err:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := ParseNumStat(strings.NewReader(test.Input))
+			if test.Err {
+				if err == nil {
+					t.Fatalf("expected error parsing numstat, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing numstat: %v", err)
+			}
+			if !reflect.DeepEqual(test.Output, s.Files) {
+				t.Errorf("incorrect stats\nexpected: %+v\n  actual: %+v", test.Output, s.Files)
+			}
+		})
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Output []*FileStat
+		Err    bool
+	}{
+		"exact": {
+			Input: ` dir/sub/{old.txt => new.txt} |  3 ++-
+ mode.sh                       |  0
+ small.txt                     | 12 +++++++-----
+ 3 files changed, 10 insertions(+), 6 deletions(-)
+`,
+			Output: []*FileStat{
+				{OldName: "dir/sub/old.txt", NewName: "dir/sub/new.txt", IsRename: true, LinesAdded: 2, LinesDeleted: 1},
+				{OldName: "mode.sh", NewName: "mode.sh"},
+				{OldName: "small.txt", NewName: "small.txt", LinesAdded: 7, LinesDeleted: 5},
+			},
+		},
+		"scaled": {
+			Input: ` big.txt   | 200 ++++++++++-----------
+ small.txt |   3 +-
+`,
+			Output: []*FileStat{
+				{OldName: "big.txt", NewName: "big.txt", LinesAdded: 95, LinesDeleted: 105},
+				{OldName: "small.txt", NewName: "small.txt", LinesAdded: 2, LinesDeleted: 1},
+			},
+		},
+		"binary": {
+			Input: ` bin.dat    | Bin 3000 -> 4000 bytes
+ other.dat  | Bin
+`,
+			Output: []*FileStat{
+				{OldName: "bin.dat", NewName: "bin.dat", IsBinary: true, OldSize: 3000, NewSize: 4000},
+				{OldName: "other.dat", NewName: "other.dat", IsBinary: true},
+			},
+		},
+		"shortened": {
+			Input: " .../and/ever/file.txt | 1 +\n",
+			Output: []*FileStat{
+				{OldName: ".../and/ever/file.txt", NewName: ".../and/ever/file.txt", LinesAdded: 1},
+			},
+		},
+		"invalidHistogram": {
+			Input: " file.txt | 2 -+\n",
+			Err:   true,
+		},
+		"missingHistogram": {
+			Input: " file.txt | 2\n",
+			Err:   true,
+		},
+		"invalidBinary": {
+			Input: " file.dat | Bin 1 to 2\n",
+			Err:   true,
+		},
+		"notStat": {
+			Input: "diff --git a/file.txt b/file.txt\n",
+			Err:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := ParseStat(strings.NewReader(test.Input))
+			if test.Err {
+				if err == nil {
+					t.Fatalf("expected error parsing stat, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing stat: %v", err)
+			}
+			if !reflect.DeepEqual(test.Output, s.Files) {
+				t.Errorf("incorrect stats\nexpected: %+v\n  actual: %+v", test.Output, s.Files)
+			}
+		})
+	}
+}
+
+func TestParseStatError(t *testing.T) {
+	_, err := ParseNumStat(strings.NewReader("1\t1\tfile.txt\n1\t1\n"))
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected *ParseError, but got %v", err)
+	}
+	if perr.Line != 2 || perr.Kind != KindStat {
+		t.Errorf("incorrect error: line %d, kind %v", perr.Line, perr.Kind)
+	}
+}
@@ -0,0 +1,198 @@
+package gitdiff
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const gitlinkMode os.FileMode = 0160000
+
+// SubmoduleChange describes a change to the commit of a submodule, which Git
+// records as a gitlink entry with mode 160000. Git describes submodule
+// changes with a "Subproject commit" line for each commit by default or with
+// a summary of the commits between them with "git diff --submodule=log".
+type SubmoduleChange struct {
+	// OldCommit and NewCommit are the IDs of the commits of the submodule
+	// before and after the change. They are empty if the submodule is
+	// created or deleted. IDs from the log format are abbreviated.
+	OldCommit string `json:"old_commit,omitempty"`
+	NewCommit string `json:"new_commit,omitempty"`
+
+	// Dirty is true if the new content of the submodule has modifications
+	// that are not committed. Untracked is true if it has untracked files,
+	// which Git only reports in the log format.
+	Dirty     bool `json:"dirty,omitempty"`
+	Untracked bool `json:"untracked,omitempty"`
+
+	// Log is true if the change uses the format of "git diff
+	// --submodule=log", which does not have a file header.
+	Log bool `json:"log,omitempty"`
+
+	// Status is the explanation after the commits in the log format, without
+	// parentheses, like "new submodule" or "rewind". Diverged is true if
+	// the log format separates the commits with "...", which Git uses when
+	// neither commit is an ancestor of the other or when the history is not
+	// available.
+	Status   string `json:"status,omitempty"`
+	Diverged bool   `json:"diverged,omitempty"`
+
+	// Commits are the commits between OldCommit and NewCommit in the log
+	// format, in the order Git lists them.
+	Commits []SubmoduleCommit `json:"commits,omitempty"`
+}
+
+// SubmoduleCommit is a commit in the log of a submodule change.
+type SubmoduleCommit struct {
+	// Op is OpAdd for commits that are only reachable from the new commit
+	// and OpDelete for commits that are only reachable from the old commit.
+	Op    LineOp `json:"op"`
+	Title string `json:"title"`
+}
+
+// Submodule status messages used by the log format.
+const (
+	SubmoduleStatusNew      = "new submodule"
+	SubmoduleStatusDeleted  = "submodule deleted"
+	SubmoduleStatusRewind   = "rewind"
+	SubmoduleStatusNotFound = "commits not present"
+)
+
+var (
+	submoduleHeaderRegexp   = regexp.MustCompile(`^Submodule (.+) ([0-9a-f]+)(\.\.\.?)([0-9a-f]+)(?: \(([a-z ]+)\))?(:?)\n$`)
+	submoduleContentRegexp  = regexp.MustCompile(`^Submodule (.+) contains (untracked|modified) content\n$`)
+	subprojectCommitRegexp  = regexp.MustCompile(`^Subproject commit ([0-9a-f]+)(-dirty)?\n$`)
+	submoduleCommitPrefixes = map[string]LineOp{"  > ": OpAdd, "  < ": OpDelete}
+)
+
+// ParseSubmoduleLog parses a submodule change in the format of "git diff
+// --submodule=log", which replaces the file header and fragments of the
+// gitlink. It returns nil if the current line does not start a submodule
+// change.
+func (p *parser) ParseSubmoduleLog() (*File, error) {
+	var f *File
+	start := func(name string) bool {
+		if f == nil {
+			f = &File{OldName: name, NewName: name, OldMode: gitlinkMode, NewMode: gitlinkMode, Submodule: &SubmoduleChange{Log: true}}
+		}
+		return f.NewName == name
+	}
+	next := func() error {
+		if err := p.Next(); err != nil && err != io.EOF {
+			return err
+		}
+		return nil
+	}
+
+	for {
+		m := submoduleContentRegexp.FindStringSubmatch(p.Line(0))
+		if m == nil || !start(m[1]) {
+			break
+		}
+		if m[2] == "untracked" {
+			f.Submodule.Untracked = true
+		} else {
+			f.Submodule.Dirty = true
+		}
+		if err := next(); err != nil {
+			return nil, err
+		}
+	}
+
+	m := submoduleHeaderRegexp.FindStringSubmatch(p.Line(0))
+	if m == nil || !start(m[1]) {
+		return f, nil
+	}
+
+	sm := f.Submodule
+	f.OldOIDPrefix, f.NewOIDPrefix = m[2], m[4]
+	sm.Diverged = m[3] == "..."
+	sm.Status = m[5]
+
+	switch sm.Status {
+	case SubmoduleStatusNew:
+		f.IsNew, f.OldName, f.OldMode = true, "", 0
+	case SubmoduleStatusDeleted:
+		f.IsDelete, f.NewName, f.NewMode = true, "", 0
+	}
+	if !f.IsNew {
+		sm.OldCommit = f.OldOIDPrefix
+	}
+	if !f.IsDelete {
+		sm.NewCommit = f.NewOIDPrefix
+	}
+	if err := next(); err != nil {
+		return nil, err
+	}
+
+	for {
+		line := p.Line(0)
+		if len(line) < 4 {
+			break
+		}
+		op, ok := submoduleCommitPrefixes[line[:4]]
+		if !ok {
+			break
+		}
+		sm.Commits = append(sm.Commits, SubmoduleCommit{Op: op, Title: strings.TrimSuffix(line[4:], "\n")})
+		if err := next(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// parseSubmoduleFragments replaces the text fragment of a gitlink with a
+// SubmoduleChange if the fragment only contains "Subproject commit" lines.
+// Gitlinks only changed by modifications in the submodule have no modes, so
+// fragments of files without modes are also checked.
+func parseSubmoduleFragments(f *File) {
+	if f.IsBinary || len(f.TextFragments) != 1 {
+		return
+	}
+	if f.OldMode != gitlinkMode && f.NewMode != gitlinkMode && (f.OldMode != 0 || f.NewMode != 0) {
+		return
+	}
+
+	var sm SubmoduleChange
+	for _, line := range f.TextFragments[0].Lines {
+		m := subprojectCommitRegexp.FindStringSubmatch(line.Line)
+		if m == nil {
+			return
+		}
+		switch {
+		case line.Op == OpDelete && sm.OldCommit == "":
+			sm.OldCommit = m[1]
+		case line.Op == OpAdd && sm.NewCommit == "":
+			sm.NewCommit, sm.Dirty = m[1], m[2] != ""
+		default:
+			return
+		}
+	}
+
+	f.Submodule = &sm
+	f.TextFragments = nil
+}
+
+// TextFragment returns the text fragment for the change in the default
+// format, with a "Subproject commit" line for each commit.
+func (sm *SubmoduleChange) TextFragment() *TextFragment {
+	var lines []Line
+	if sm.OldCommit != "" {
+		lines = append(lines, Line{OpDelete, "Subproject commit " + sm.OldCommit + "\n"})
+	}
+	if sm.NewCommit != "" {
+		suffix := ""
+		if sm.Dirty {
+			suffix = "-dirty"
+		}
+		lines = append(lines, Line{OpAdd, "Subproject commit " + sm.NewCommit + suffix + "\n"})
+	}
+
+	frag := &TextFragment{}
+	frag.setLines(lines)
+	frag.OldPosition = fragmentPosition(1, frag.OldLines)
+	frag.NewPosition = fragmentPosition(1, frag.NewLines)
+	return frag
+}
@@ -0,0 +1,136 @@
+package gitdiff
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSubmodule(t *testing.T) {
+	const (
+		first  = "38c70fc3d7ebf9f2646b886ca0b09998ae76418d"
+		second = "14aa4fcf88df0ad6b5c5f942fad3357490d5d471"
+		third  = "6926f887dfcaf5b69e697a5f261ba8ad9dcba703"
+	)
+
+	tests := map[string]struct {
+		Input      string
+		Submodules []*SubmoduleChange
+	}{
+		"default": {
+			Input: "testdata/submodule.patch",
+			Submodules: []*SubmoduleChange{
+				{NewCommit: first},
+				{OldCommit: first, NewCommit: second},
+				nil,
+				{OldCommit: second},
+				{OldCommit: second, NewCommit: third, Dirty: true},
+				{OldCommit: second, NewCommit: second, Dirty: true},
+			},
+		},
+		"log": {
+			Input: "testdata/submodule_log.patch",
+			Submodules: []*SubmoduleChange{
+				nil,
+				{NewCommit: "38c70fc", Log: true, Status: SubmoduleStatusNew, Diverged: true},
+				{
+					OldCommit: "38c70fc",
+					NewCommit: "14aa4fc",
+					Log:       true,
+					Commits: []SubmoduleCommit{
+						{OpAdd, "Commit 3"},
+						{OpAdd, "Commit 2"},
+					},
+				},
+				nil,
+				{
+					OldCommit: "14aa4fc",
+					NewCommit: "6926f88",
+					Dirty:     true,
+					Log:       true,
+					Status:    SubmoduleStatusRewind,
+					Commits: []SubmoduleCommit{
+						{OpDelete, "Commit 3"},
+					},
+				},
+				nil,
+				{OldCommit: "14aa4fc", Log: true, Status: SubmoduleStatusDeleted, Diverged: true},
+				{Dirty: true, Untracked: true, Log: true},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			patch, err := os.ReadFile(test.Input)
+			if err != nil {
+				t.Fatalf("unexpected error reading patch: %v", err)
+			}
+
+			files, _, err := ParseAll(strings.NewReader(string(patch)))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			if len(files) != len(test.Submodules) {
+				t.Fatalf("incorrect number of files: expected %d, actual %d", len(test.Submodules), len(files))
+			}
+
+			var b strings.Builder
+			for i, f := range files {
+				if !reflect.DeepEqual(test.Submodules[i], f.Submodule) {
+					t.Errorf("file %d: incorrect submodule\nexpected: %+v\n  actual: %+v", i, test.Submodules[i], f.Submodule)
+				}
+				if f.Submodule != nil && len(f.TextFragments) > 0 {
+					t.Errorf("file %d: submodule has %d text fragments", i, len(f.TextFragments))
+				}
+				b.WriteString(f.String())
+			}
+			if b.String() != string(patch) {
+				t.Errorf("incorrect formatted patch\nexpected:\n%s\nactual:\n%s", patch, b.String())
+			}
+		})
+	}
+}
+
+func TestParseSubmoduleLogFile(t *testing.T) {
+	const patch = "Submodule lib/dep 0000000...38c70fc (new submodule)\n"
+
+	files, _, err := ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("incorrect number of files: expected 1, actual %d", len(files))
+	}
+
+	f := files[0]
+	if !f.IsNew || f.OldName != "" || f.NewName != "lib/dep" || f.NewMode != 0160000 {
+		t.Errorf("incorrect file: %+v", f)
+	}
+	if f.OldOIDPrefix != "0000000" || f.NewOIDPrefix != "38c70fc" {
+		t.Errorf("incorrect object IDs: %s..%s", f.OldOIDPrefix, f.NewOIDPrefix)
+	}
+
+	err = Apply(&strings.Builder{}, strings.NewReader(""), f)
+	assertError(t, "cannot apply submodule change", err, "applying submodule change")
+}
+
+func TestParseSubprojectText(t *testing.T) {
+	const patch = `diff --git a/notes.txt b/notes.txt
+index 38c70fc..14aa4fc 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -1 +1 @@
+-Subproject commit 38c70fc3d7ebf9f2646b886ca0b09998ae76418d
++Subproject commit 14aa4fcf88df0ad6b5c5f942fad3357490d5d471
+`
+
+	files, _, err := ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if files[0].Submodule != nil || len(files[0].TextFragments) != 1 {
+		t.Errorf("regular file parsed as submodule: %+v", files[0])
+	}
+}
@@ -0,0 +1,149 @@
+package gitdiff
+
+import (
+	"path"
+	"sort"
+)
+
+// ChangeGroup is the total of the changes to a group of files in a
+// ChangeSummary.
+type ChangeGroup struct {
+	// Key identifies the group, like the author or the directory of the
+	// files
+	Key string
+
+	// Commits is the number of patches that change a file in the group
+	Commits int
+
+	// Files is the number of changes to files in the group. A file changed by
+	// several patches is counted once for each patch.
+	Files int
+
+	// LinesAdded and LinesDeleted are the number of lines added to and
+	// deleted from text files in the group
+	LinesAdded   int64
+	LinesDeleted int64
+}
+
+// ChangeSummary accumulates the changes in a series of patches, like the
+// commits from ParseCommits, by author, by directory, and by file extension.
+// Use NewChangeSummary to create a ChangeSummary.
+type ChangeSummary struct {
+	// Total is the total of the changes in all patches
+	Total ChangeGroup
+
+	directory  func(f *File) string
+	authors    map[string]*ChangeGroup
+	prefixes   map[string]*ChangeGroup
+	extensions map[string]*ChangeGroup
+}
+
+// NewChangeSummary returns an empty summary that groups files by the first
+// depth components of the directory containing them, or by the full
+// directory if depth is zero or negative, like ByDirectory.
+func NewChangeSummary(depth int) *ChangeSummary {
+	return &ChangeSummary{
+		directory:  ByDirectory(depth),
+		authors:    make(map[string]*ChangeGroup),
+		prefixes:   make(map[string]*ChangeGroup),
+		extensions: make(map[string]*ChangeGroup),
+	}
+}
+
+// SummarizeCommits adds all of the patches received from patches, like the
+// channel returned by ParseCommits, to a new summary with the given
+// directory depth and returns the summary after the channel is closed.
+func SummarizeCommits(patches <-chan *Patch, depth int) *ChangeSummary {
+	s := NewChangeSummary(depth)
+	for p := range patches {
+		s.Add(p)
+	}
+	return s
+}
+
+// Add adds the changes in a patch to the summary. The author of the patch is
+// the String of the Author in its header, or empty if the patch has no
+// header or author. Files are grouped by their new name, or by their old
+// name if they are deleted, and binary files count as changed files without
+// changed lines.
+func (s *ChangeSummary) Add(p *Patch) {
+	author := ""
+	if p.Header != nil && p.Header.Author != nil {
+		author = p.Header.Author.String()
+	}
+
+	seen := make(map[*ChangeGroup]bool)
+	add := func(groups map[string]*ChangeGroup, key string, stat *FileStat) {
+		g, ok := groups[key]
+		if !ok {
+			g = &ChangeGroup{Key: key}
+			groups[key] = g
+		}
+		addChangeStat(g, stat, seen)
+	}
+
+	for _, f := range p.Files {
+		stat := f.Stat()
+		name := f.NewName
+		if f.IsDelete || name == "" {
+			name = f.OldName
+		}
+
+		add(s.authors, author, stat)
+		add(s.prefixes, s.directory(f), stat)
+		add(s.extensions, path.Ext(name), stat)
+		addChangeStat(&s.Total, stat, seen)
+	}
+}
+
+// addChangeStat adds the changes to a file to g, counting the patch of the
+// file if g is not in seen.
+func addChangeStat(g *ChangeGroup, stat *FileStat, seen map[*ChangeGroup]bool) {
+	if !seen[g] {
+		seen[g] = true
+		g.Commits++
+	}
+	g.Files++
+	g.LinesAdded += stat.LinesAdded
+	g.LinesDeleted += stat.LinesDeleted
+}
+
+// Authors returns the changes by each author, sorted by the number of
+// changed lines with the most changes first.
+func (s *ChangeSummary) Authors() []ChangeGroup {
+	return sortChangeGroups(s.authors)
+}
+
+// Prefixes returns the changes in each directory, sorted by the number of
+// changed lines with the most changes first. Files at the root of the
+// repository have the key ".".
+func (s *ChangeSummary) Prefixes() []ChangeGroup {
+	return sortChangeGroups(s.prefixes)
+}
+
+// Extensions returns the changes to files with each extension, including the
+// dot, sorted by the number of changed lines with the most changes first.
+// Files without an extension have an empty key.
+func (s *ChangeSummary) Extensions() []ChangeGroup {
+	return sortChangeGroups(s.extensions)
+}
+
+// sortChangeGroups returns the groups sorted by the number of changed lines,
+// then by the number of changed files, and then by key.
+func sortChangeGroups(groups map[string]*ChangeGroup) []ChangeGroup {
+	sorted := make([]ChangeGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, *g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if na, nb := a.LinesAdded+a.LinesDeleted, b.LinesAdded+b.LinesDeleted; na != nb {
+			return na > nb
+		}
+		if a.Files != b.Files {
+			return a.Files > b.Files
+		}
+		return a.Key < b.Key
+	})
+	return sorted
+}
@@ -0,0 +1,91 @@
+package gitdiff
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeCommits(t *testing.T) {
+	tests := map[string]struct {
+		Depth      int
+		Authors    []ChangeGroup
+		Prefixes   []ChangeGroup
+		Extensions []ChangeGroup
+	}{
+		"fullDirectory": {
+			Authors: []ChangeGroup{
+				{Key: "Ada Quill <ada@example.com>", Commits: 1, Files: 3, LinesAdded: 5, LinesDeleted: 1},
+				{Key: "Morton Haypenny <dev@example.com>", Commits: 2, Files: 4, LinesAdded: 5},
+			},
+			Prefixes: []ChangeGroup{
+				{Key: "lib/a", Commits: 3, Files: 4, LinesAdded: 5, LinesDeleted: 1},
+				{Key: "docs", Commits: 1, Files: 1, LinesAdded: 3},
+				{Key: ".", Commits: 2, Files: 2, LinesAdded: 2},
+			},
+			Extensions: []ChangeGroup{
+				{Key: ".go", Commits: 2, Files: 2, LinesAdded: 5, LinesDeleted: 1},
+				{Key: ".md", Commits: 1, Files: 1, LinesAdded: 3},
+				{Key: "", Commits: 2, Files: 2, LinesAdded: 2},
+				{Key: ".bin", Commits: 2, Files: 2},
+			},
+		},
+		"topDirectory": {
+			Depth: 1,
+			Prefixes: []ChangeGroup{
+				{Key: "lib", Commits: 3, Files: 4, LinesAdded: 5, LinesDeleted: 1},
+				{Key: "docs", Commits: 1, Files: 1, LinesAdded: 3},
+				{Key: ".", Commits: 2, Files: 2, LinesAdded: 2},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", "summary_log.patch"))
+			if err != nil {
+				t.Fatalf("failed to open patch: %v", err)
+			}
+			defer f.Close()
+
+			ch, err := ParseCommits(f, WithErrorHandler(func(err error) {
+				t.Errorf("unexpected error parsing commits: %v", err)
+			}))
+			if err != nil {
+				t.Fatalf("unexpected error parsing commits: %v", err)
+			}
+			s := SummarizeCommits(ch, test.Depth)
+
+			expectedTotal := ChangeGroup{Commits: 3, Files: 7, LinesAdded: 10, LinesDeleted: 1}
+			if s.Total != expectedTotal {
+				t.Errorf("incorrect total\nexpected: %+v\n  actual: %+v", expectedTotal, s.Total)
+			}
+			if test.Authors != nil && !reflect.DeepEqual(test.Authors, s.Authors()) {
+				t.Errorf("incorrect authors\nexpected: %+v\n  actual: %+v", test.Authors, s.Authors())
+			}
+			if !reflect.DeepEqual(test.Prefixes, s.Prefixes()) {
+				t.Errorf("incorrect prefixes\nexpected: %+v\n  actual: %+v", test.Prefixes, s.Prefixes())
+			}
+			if test.Extensions != nil && !reflect.DeepEqual(test.Extensions, s.Extensions()) {
+				t.Errorf("incorrect extensions\nexpected: %+v\n  actual: %+v", test.Extensions, s.Extensions())
+			}
+		})
+	}
+}
+
+func TestChangeSummaryAdd(t *testing.T) {
+	s := NewChangeSummary(0)
+	s.Add(&Patch{Files: []*File{
+		{NewName: "a.txt", TextFragments: []*TextFragment{{LinesAdded: 2}}},
+	}})
+	s.Add(&Patch{})
+
+	expected := []ChangeGroup{{Key: "", Commits: 1, Files: 1, LinesAdded: 2}}
+	if !reflect.DeepEqual(expected, s.Authors()) {
+		t.Errorf("incorrect authors for patch without header\nexpected: %+v\n  actual: %+v", expected, s.Authors())
+	}
+	if s.Total.Commits != 1 {
+		t.Errorf("incorrect number of commits: expected 1, actual %d", s.Total.Commits)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "fmt"
+
+type server struct {
+	name string
+	port int
+	host string
+	address string
+}
+
+func (s *server) start() error {
+	fmt.Println("starting")
+	fmt.Println("one")
+	fmt.Println("two")
+	fmt.Println("THREE")
+	return nil
+}
+
+func main() {
+	s := &server{}
+	fmt.Println("a")
+	fmt.Println("b")
+	fmt.Println("C")
+	s.start()
+}
@@ -0,0 +1,15 @@
+import os
+
+
+class Thing:
+    def __init__(self):
+        self.a = 1
+        self.b = 2
+        self.c = 3
+        self.d = 5
+
+    async def run(self):
+        x = 1
+        y = 2
+        z = 4
+        return x + y + z
@@ -0,0 +1,15 @@
+import os
+
+
+class Thing:
+    def __init__(self):
+        self.a = 1
+        self.b = 2
+        self.c = 3
+        self.d = 4
+
+    async def run(self):
+        x = 1
+        y = 2
+        z = 3
+        return x + y + z
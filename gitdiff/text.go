@@ -1,6 +1,7 @@
 package gitdiff
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -20,11 +21,15 @@ func (p *parser) ParseTextFragments(f *File) (n int, err error) {
 			return n, nil
 		}
 
+		if err := p.checkFragmentLimit(-1, n); err != nil {
+			return n, err
+		}
+
 		if f.IsNew && frag.OldLines > 0 {
-			return n, p.Errorf(-1, "new file depends on old contents")
+			return n, p.Errorf(-1, KindInconsistentFragment, "new file depends on old contents")
 		}
 		if f.IsDelete && frag.NewLines > 0 {
-			return n, p.Errorf(-1, "deleted file still has contents")
+			return n, p.Errorf(-1, KindInconsistentFragment, "deleted file still has contents")
 		}
 
 		if err := p.ParseTextChunk(frag); err != nil {
@@ -42,30 +47,43 @@ func (p *parser) ParseTextFragmentHeader() (*TextFragment, error) {
 		endMark   = " @@"
 	)
 
+	if oldRange, newRange, comment, ok := p.matchFragmentHeader(p.Line(0)); ok {
+		return p.parseTextFragmentRanges(oldRange, newRange, comment)
+	}
+
 	if !strings.HasPrefix(p.Line(0), startMark) {
 		return nil, nil
 	}
 
 	parts := strings.SplitAfterN(p.Line(0), endMark, 2)
 	if len(parts) < 2 {
-		return nil, p.Errorf(0, "invalid fragment header")
+		return nil, p.Errorf(0, KindFragmentHeader, "invalid fragment header")
 	}
 
-	f := &TextFragment{}
-	f.Comment = strings.TrimSpace(parts[1])
-
 	header := parts[0][len(startMark) : len(parts[0])-len(endMark)]
 	ranges := strings.Split(header, " +")
 	if len(ranges) != 2 {
-		return nil, p.Errorf(0, "invalid fragment header")
+		return nil, p.Errorf(0, KindFragmentHeader, "invalid fragment header")
+	}
+	return p.parseTextFragmentRanges(ranges[0], ranges[1], parts[1])
+}
+
+// parseTextFragmentRanges creates a fragment from the ranges and comment of
+// the header at the current line and advances past the header.
+func (p *parser) parseTextFragmentRanges(oldRange, newRange, comment string) (*TextFragment, error) {
+	f := &TextFragment{}
+	f.Comment = strings.TrimSpace(comment)
+
+	if p.positions {
+		f.Origin = &FragmentOrigin{Header: p.position()}
 	}
 
 	var err error
-	if f.OldPosition, f.OldLines, err = parseRange(ranges[0]); err != nil {
-		return nil, p.Errorf(0, "invalid fragment header: %v", err)
+	if f.OldPosition, f.OldLines, err = parseRange(oldRange); err != nil {
+		return nil, p.Errorf(0, KindFragmentHeader, "invalid fragment header: %w", err)
 	}
-	if f.NewPosition, f.NewLines, err = parseRange(ranges[1]); err != nil {
-		return nil, p.Errorf(0, "invalid fragment header: %v", err)
+	if f.NewPosition, f.NewLines, err = parseRange(newRange); err != nil {
+		return nil, p.Errorf(0, KindFragmentHeader, "invalid fragment header: %w", err)
 	}
 
 	if err := p.Next(); err != nil && err != io.EOF {
@@ -75,13 +93,27 @@ func (p *parser) ParseTextFragmentHeader() (*TextFragment, error) {
 }
 
 func (p *parser) ParseTextChunk(frag *TextFragment) error {
+	// errors for the whole fragment are reported at the header, which is
+	// the line before the chunk
+	header := p.offset() - int64(p.prev)
+
 	if p.Line(0) == "" {
-		return p.Errorf(0, "no content following fragment header")
+		if !p.recount {
+			return p.truncatedErrorf(-1, header, frag, frag.OldLines, frag.NewLines)
+		}
+		return p.Errorf(0, KindTruncatedFragment, "no content following fragment header")
 	}
 
+	// count lines to find the header if the fragment miscounts lines
+	var n int64
+
 	oldLines, newLines := frag.OldLines, frag.NewLines
-	for oldLines > 0 || newLines > 0 {
+	for p.recount || oldLines > 0 || newLines > 0 {
 		line := p.Line(0)
+		if p.recount && !isFragmentLine(line) {
+			break
+		}
+		n++
 		op, data := line[0], line[1:]
 
 		switch op {
@@ -120,7 +152,12 @@ func (p *parser) ParseTextChunk(frag *TextFragment) error {
 			// either test for the common headers ("@@ -", "diff --git") or
 			// assume any invalid op ends the fragment; git returns the same
 			// generic error in all cases so either is compatible
-			return p.Errorf(0, "invalid line operation: %q", op)
+			e := p.Errorf(0, KindInvalidLine, "invalid line operation: %q", op)
+			e.Column = 1
+			return e
+		}
+		if frag.Origin != nil && len(frag.Origin.Lines) < len(frag.Lines) {
+			frag.Origin.Lines = append(frag.Origin.Lines, p.position())
 		}
 
 		if err := p.Next(); err != nil {
@@ -131,12 +168,22 @@ func (p *parser) ParseTextChunk(frag *TextFragment) error {
 		}
 	}
 
+	if p.recount {
+		frag.OldLines -= oldLines
+		frag.NewLines -= newLines
+		oldLines, newLines = 0, 0
+	}
+
+	if p.Line(0) == "" && oldLines >= 0 && newLines >= 0 && oldLines+newLines > 0 {
+		return p.truncatedErrorf(-(n + 1), header, frag, oldLines, newLines)
+	}
 	if oldLines != 0 || newLines != 0 {
-		hdr := max(frag.OldLines-oldLines, frag.NewLines-newLines) + 1
-		return p.Errorf(-hdr, "fragment header miscounts lines: %+d old, %+d new", -oldLines, -newLines)
+		e := p.Errorf(-(n + 1), KindTruncatedFragment, "fragment header miscounts lines: %+d old, %+d new", -oldLines, -newLines)
+		e.Offset = header
+		return e
 	}
 	if frag.LinesAdded == 0 && frag.LinesDeleted == 0 {
-		return p.Errorf(0, "fragment contains no changes")
+		return p.Errorf(0, KindEmptyFragment, "fragment contains no changes")
 	}
 
 	// check for a final "no newline" marker since it is not included in the
@@ -151,6 +198,57 @@ func (p *parser) ParseTextChunk(frag *TextFragment) error {
 	return nil
 }
 
+// ErrTruncatedPatch is the error wrapped by the *ParseError returned when a
+// patch ends in the middle of a text fragment, usually because the patch was
+// cut off while copying or uploading it. Use errors.Is to test for it and
+// errors.As with a *TruncatedPatchError to get the number of missing lines.
+var ErrTruncatedPatch = errors.New("patch ends in the middle of a fragment")
+
+// TruncatedPatchError is the error returned when a patch ends before all of
+// the lines of a text fragment. Parse wraps it in a *ParseError with the
+// KindTruncatedFragment kind at the header of the fragment.
+type TruncatedPatchError struct {
+	// OldLines and NewLines are the number of old and new lines in the
+	// header of the fragment
+	OldLines int64
+	NewLines int64
+
+	// FoundOldLines and FoundNewLines are the number of old and new lines
+	// of the fragment before the end of the patch
+	FoundOldLines int64
+	FoundNewLines int64
+}
+
+func (e *TruncatedPatchError) Error() string {
+	return fmt.Sprintf("%v: found %d of %d old lines and %d of %d new lines",
+		ErrTruncatedPatch, e.FoundOldLines, e.OldLines, e.FoundNewLines, e.NewLines)
+}
+
+// Is returns true if target is ErrTruncatedPatch.
+func (e *TruncatedPatchError) Is(target error) bool {
+	return target == ErrTruncatedPatch
+}
+
+// truncatedErrorf returns a *ParseError for the header of a fragment at
+// delta from the current line and at offset in the patch, for a fragment
+// that is missing oldLines old lines and newLines new lines.
+func (p *parser) truncatedErrorf(delta, offset int64, frag *TextFragment, oldLines, newLines int64) *ParseError {
+	e := p.Errorf(delta, KindTruncatedFragment, "%w", &TruncatedPatchError{
+		OldLines:      frag.OldLines,
+		NewLines:      frag.NewLines,
+		FoundOldLines: frag.OldLines - oldLines,
+		FoundNewLines: frag.NewLines - newLines,
+	})
+	e.Offset = offset
+	return e
+}
+
+// isFragmentLine returns true if s could be a line of a text fragment. It
+// does not check that marker lines starting with a backslash are valid.
+func isFragmentLine(s string) bool {
+	return s != "" && strings.IndexByte(" \n-+\\", s[0]) >= 0
+}
+
 func isNoNewlineMarker(s string) bool {
 	// test for "\ No newline at end of file" by prefix because the text
 	// changes by locale (git claims all versions are at least 12 chars)
@@ -190,3 +288,10 @@ func max(a, b int64) int64 {
 	}
 	return b
 }
+
+func min(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
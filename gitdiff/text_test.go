@@ -1,8 +1,10 @@
 package gitdiff
 
 import (
+	"errors"
 	"io"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -486,3 +488,97 @@ func TestParseTextFragments(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTruncatedPatch(t *testing.T) {
+	tests := map[string]struct {
+		Input     string
+		Truncated *TruncatedPatchError
+		Line      int64
+		Offset    int64
+	}{
+		"endsInFragment": {
+			Input: `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1,4 +1,5 @@
+ a
+-b
++c
+`,
+			Truncated: &TruncatedPatchError{OldLines: 4, NewLines: 5, FoundOldLines: 2, FoundNewLines: 2},
+			Line:      4,
+			Offset:    51,
+		},
+		"endsAfterHeader": {
+			Input: `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1 +1 @@
+`,
+			Truncated: &TruncatedPatchError{OldLines: 1, NewLines: 1},
+			Line:      4,
+			Offset:    51,
+		},
+		"endsInSecondFragment": {
+			Input: `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1 +1 @@
+-a
++b
+@@ -5,3 +5,3 @@
+ e
+`,
+			Truncated: &TruncatedPatchError{OldLines: 3, NewLines: 3, FoundOldLines: 1, FoundNewLines: 1},
+			Line:      7,
+			Offset:    69,
+		},
+		"miscounted": {
+			Input: `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1 +1,2 @@
+-a
+-b
++c
+`,
+			Line:   4,
+			Offset: 51,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ParseAll(strings.NewReader(test.Input))
+
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected *ParseError, but got %v", err)
+			}
+			if perr.Kind != KindTruncatedFragment {
+				t.Errorf("incorrect kind: expected %s, actual %s", KindTruncatedFragment, perr.Kind)
+			}
+			if perr.Line != test.Line || perr.Offset != test.Offset {
+				t.Errorf("incorrect location: expected line %d, offset %d; actual line %d, offset %d", test.Line, test.Offset, perr.Line, perr.Offset)
+			}
+
+			if test.Truncated == nil {
+				if errors.Is(err, ErrTruncatedPatch) {
+					t.Errorf("expected error to not be ErrTruncatedPatch: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrTruncatedPatch) {
+				t.Errorf("expected error to be ErrTruncatedPatch: %v", err)
+			}
+
+			var terr *TruncatedPatchError
+			if !errors.As(err, &terr) {
+				t.Fatalf("expected *TruncatedPatchError, but got %v", err)
+			}
+			if *terr != *test.Truncated {
+				t.Errorf("incorrect truncated error\nexpected: %+v\n  actual: %+v", *test.Truncated, *terr)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package gitdiff
+
+import (
+	"fmt"
+)
+
+// Transformer converts the bytes of a line of a patch. It has the same
+// methods as the Transformer interface in the golang.org/x/text/transform
+// package, so the decoders from the golang.org/x/text/encoding packages can be
+// used to parse patches that are not encoded with UTF-8.
+type Transformer interface {
+	Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error)
+	Reset()
+}
+
+// WithTransform configures Parse to convert each line of the input with t
+// before parsing it, for example to decode patches that use a legacy encoding
+// like ISO-8859-1 or Shift-JIS. Parse resets t before each line. If t fails,
+// Parse stops with an error. The patch header records the encoding declared in
+// the headers of an email patch as Charset.
+func WithTransform(t Transformer) ParseOption {
+	return func(p *parser) {
+		p.transform = t
+	}
+}
+
+// transformLine converts line with t. Transformers signal that the
+// destination is too small by returning an error without making progress, so
+// transformLine retries with a larger buffer before failing.
+func transformLine(t Transformer, line string) (string, error) {
+	t.Reset()
+
+	src := []byte(line)
+	dst := make([]byte, 4*len(src)+16)
+	out := make([]byte, 0, len(src))
+	for {
+		nDst, nSrc, err := t.Transform(dst, src, true)
+		out = append(out, dst[:nDst]...)
+		src = src[nSrc:]
+		switch {
+		case err == nil:
+			return string(out), nil
+		case nDst == 0 && nSrc == 0:
+			if len(dst) > 64*(len(line)+16) {
+				return "", fmt.Errorf("gitdiff: transform: %w", err)
+			}
+			dst = make([]byte, 2*len(dst))
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package gitdiff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var errShortDst = errors.New("short destination buffer")
+
+// latin1Decoder decodes ISO-8859-1 to UTF-8, like the decoder from the
+// golang.org/x/text/encoding/charmap package.
+type latin1Decoder struct{}
+
+func (latin1Decoder) Reset() {}
+
+func (latin1Decoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for _, c := range src {
+		if nDst+utf8.RuneLen(rune(c)) > len(dst) {
+			return nDst, nSrc, errShortDst
+		}
+		nDst += utf8.EncodeRune(dst[nDst:], rune(c))
+		nSrc++
+	}
+	return nDst, nSrc, nil
+}
+
+// repeatTransformer writes each byte n times and needs room for all of the
+// output of a byte before making progress.
+type repeatTransformer struct{ n int }
+
+func (repeatTransformer) Reset() {}
+
+func (t repeatTransformer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for _, c := range src {
+		if nDst+t.n > len(dst) {
+			return nDst, nSrc, errShortDst
+		}
+		for i := 0; i < t.n; i++ {
+			dst[nDst] = c
+			nDst++
+		}
+		nSrc++
+	}
+	return nDst, nSrc, nil
+}
+
+type failTransformer struct{}
+
+func (failTransformer) Reset() {}
+
+func (failTransformer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	return 0, 0, errors.New("invalid input")
+}
+
+func TestTransformLine(t *testing.T) {
+	tests := map[string]struct {
+		Transformer Transformer
+		Input       string
+		Output      string
+		Err         interface{}
+	}{
+		"latin1": {
+			Transformer: latin1Decoder{},
+			Input:       "caf\xe9\n",
+			Output:      "café\n",
+		},
+		"growBuffer": {
+			Transformer: repeatTransformer{n: 40},
+			Input:       "ab",
+			Output:      strings.Repeat("a", 40) + strings.Repeat("b", 40),
+		},
+		"error": {
+			Transformer: failTransformer{},
+			Input:       "line\n",
+			Err:         "invalid input",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, err := transformLine(test.Transformer, test.Input)
+			if test.Err != nil {
+				assertError(t, test.Err, err, "transforming line")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error transforming line: %v", err)
+			}
+			if out != test.Output {
+				t.Errorf("incorrect output: expected %q, actual %q", test.Output, out)
+			}
+		})
+	}
+}
+
+func TestParseWithTransform(t *testing.T) {
+	patch := "From: Jos\xe9 <jose@example.com>\n" +
+		"Subject: [PATCH] Fix caf\xe9\n" +
+		"Content-Type: text/plain; charset=ISO-8859-1\n" +
+		"\n" +
+		"---\n" +
+		"diff --git a/menu.txt b/menu.txt\n" +
+		"--- a/menu.txt\n" +
+		"+++ b/menu.txt\n" +
+		"@@ -1 +1 @@\n" +
+		"-cafe\n" +
+		"+caf\xe9\n"
+
+	files, preamble, err := ParseAll(strings.NewReader(patch), WithTransform(latin1Decoder{}))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) != 1 || len(files[0].TextFragments) != 1 {
+		t.Fatalf("incorrect files: %+v", files)
+	}
+	if line := files[0].TextFragments[0].Lines[1].Line; line != "café\n" {
+		t.Errorf("incorrect added line: expected %q, actual %q", "café\n", line)
+	}
+
+	h, err := ParsePatchHeader(preamble)
+	if err != nil {
+		t.Fatalf("unexpected error parsing header: %v", err)
+	}
+	if h.Author == nil || h.Author.Name != "José" {
+		t.Errorf("incorrect author: %+v", h.Author)
+	}
+	if h.Charset != "ISO-8859-1" {
+		t.Errorf("incorrect charset: expected %q, actual %q", "ISO-8859-1", h.Charset)
+	}
+
+	_, _, err = ParseAll(strings.NewReader(patch), WithTransform(failTransformer{}))
+	assertError(t, "invalid input", err, "parsing patch")
+}
@@ -0,0 +1,137 @@
+package gitdiff
+
+import "os"
+
+// FileType is the type of a file in Git, from its mode.
+type FileType int
+
+const (
+	// FileTypeUnknown indicates a file without a mode or with a mode that
+	// Git does not use
+	FileTypeUnknown FileType = iota
+	// FileTypeRegular indicates a regular file
+	FileTypeRegular
+	// FileTypeSymlink indicates a symbolic link
+	FileTypeSymlink
+	// FileTypeGitlink indicates a submodule
+	FileTypeGitlink
+	// FileTypeDirectory indicates a directory, which Git does not record as
+	// a file, but which can replace a file or be replaced by one
+	FileTypeDirectory
+)
+
+// FileTypeOf returns the type of a file with the given Git mode.
+func FileTypeOf(mode os.FileMode) FileType {
+	switch mode & fileTypeMask {
+	case 0100000:
+		return FileTypeRegular
+	case symlinkMode:
+		return FileTypeSymlink
+	case gitlinkMode:
+		return FileTypeGitlink
+	case 0040000:
+		return FileTypeDirectory
+	}
+	return FileTypeUnknown
+}
+
+func (t FileType) String() string {
+	switch t {
+	case FileTypeUnknown:
+		return "unknown"
+	case FileTypeRegular:
+		return "regular file"
+	case FileTypeSymlink:
+		return "symbolic link"
+	case FileTypeGitlink:
+		return "submodule"
+	case FileTypeDirectory:
+		return "directory"
+	}
+	return "unknown"
+}
+
+// TypeChange describes a change to the type of the file at a path, like a
+// regular file replaced by a symbolic link.
+type TypeChange struct {
+	// Name is the path of the file that changes type
+	Name string
+
+	OldType FileType
+	NewType FileType
+
+	// Old is the file that removes the old type and New is the file that
+	// creates the new type. For a directory, they are the first file in the
+	// patch that deletes or creates a file in the directory. Old and New are
+	// the same file if it changes the type with its modes.
+	Old *File
+	New *File
+}
+
+// FindTypeChanges returns the changes to the types of files in a patch, in
+// the order of the first file of each change. Git describes a type change
+// as a file that deletes the old file followed by a file that creates the new
+// file with the same name, or, if a directory replaces a file or a file
+// replaces a directory, as the deletion or creation of the file and the
+// files in the directory. FindTypeChanges also returns files that change the
+// type of a file with their old and new modes, which other tools may
+// generate.
+func FindTypeChanges(files []*File) []TypeChange {
+	deleted, created := make(map[string]int), make(map[string]int)
+	deletedDirs, createdDirs := make(map[string]int), make(map[string]int)
+	for i, f := range files {
+		switch {
+		case f.IsDelete:
+			addTypeChangeName(deleted, deletedDirs, f.OldName, i)
+		case f.IsNew:
+			addTypeChangeName(created, createdDirs, f.NewName, i)
+		}
+	}
+
+	var changes []TypeChange
+	seen := make(map[string]bool)
+	for _, f := range files {
+		var change TypeChange
+		switch {
+		case f.IsDelete:
+			change = TypeChange{Name: f.OldName, OldType: FileTypeOf(f.OldMode), Old: f}
+			if j, ok := created[f.OldName]; ok {
+				change.NewType, change.New = FileTypeOf(files[j].NewMode), files[j]
+			} else if j, ok := createdDirs[f.OldName]; ok {
+				change.NewType, change.New = FileTypeDirectory, files[j]
+			}
+		case f.IsNew:
+			change = TypeChange{Name: f.NewName, NewType: FileTypeOf(f.NewMode), New: f}
+			if j, ok := deleted[f.NewName]; ok {
+				change.OldType, change.Old = FileTypeOf(files[j].OldMode), files[j]
+			} else if j, ok := deletedDirs[f.NewName]; ok {
+				change.OldType, change.Old = FileTypeDirectory, files[j]
+			}
+		case f.OldMode != 0 && f.NewMode != 0:
+			change = TypeChange{Name: f.NewName, OldType: FileTypeOf(f.OldMode), NewType: FileTypeOf(f.NewMode), Old: f, New: f}
+		}
+
+		if change.Old == nil || change.New == nil || change.OldType == change.NewType || seen[change.Name] {
+			continue
+		}
+		seen[change.Name] = true
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// addTypeChangeName records the index of the first file with name in names
+// and the index of the first file in each leading directory of name in dirs.
+func addTypeChangeName(names, dirs map[string]int, name string, i int) {
+	if _, ok := names[name]; !ok {
+		names[name] = i
+	}
+	for j := 0; j < len(name); j++ {
+		if name[j] != '/' {
+			continue
+		}
+		if _, ok := dirs[name[:j]]; !ok {
+			dirs[name[:j]] = i
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package gitdiff
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindTypeChanges(t *testing.T) {
+	type change struct {
+		Name             string
+		OldType, NewType FileType
+		Old, New         int
+	}
+
+	tests := map[string]struct {
+		Patch   string
+		Changes []change
+	}{
+		"git": {
+			Patch: "typechange.patch",
+			Changes: []change{
+				{"d", FileTypeDirectory, FileTypeRegular, 1, 0},
+				{"f", FileTypeRegular, FileTypeDirectory, 3, 4},
+				{"l", FileTypeRegular, FileTypeSymlink, 5, 6},
+			},
+		},
+		"modes": {
+			Patch: `diff --git a/link b/link
+old mode 100644
+new mode 120000
+index 1f9d725..1de5659
+--- a/link
++++ b/link
+@@ -1 +1 @@
+-l
++target
+\ No newline at end of file
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`,
+			Changes: []change{
+				{"link", FileTypeRegular, FileTypeSymlink, 0, 0},
+			},
+		},
+		"submodule": {
+			Patch: `diff --git a/lib b/lib
+deleted file mode 100644
+index 1f9d725..0000000
+--- a/lib
++++ /dev/null
+@@ -1 +0,0 @@
+-lib
+diff --git a/lib b/lib
+new file mode 160000
+index 0000000..2a7f9e4
+--- /dev/null
++++ b/lib
+@@ -0,0 +1 @@
++Subproject commit 2a7f9e4d1c0b3a5e6f7081929394a5b6c7d8e9f0
+`,
+			Changes: []change{
+				{"lib", FileTypeRegular, FileTypeGitlink, 0, 1},
+			},
+		},
+		"sameType": {
+			Patch: `diff --git a/file.txt b/file.txt
+deleted file mode 100644
+index 1f9d725..0000000
+--- a/file.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-old
+diff --git a/file.txt b/file.txt
+new file mode 100755
+index 0000000..2a7f9e4
+--- /dev/null
++++ b/file.txt
+@@ -0,0 +1 @@
++new
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			patch := test.Patch
+			if strings.HasSuffix(patch, ".patch") {
+				data, err := os.ReadFile(filepath.Join("testdata", patch))
+				if err != nil {
+					t.Fatalf("failed to read patch: %v", err)
+				}
+				patch = string(data)
+			}
+
+			files, _, err := ParseAll(strings.NewReader(patch))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			index := make(map[*File]int)
+			for i, f := range files {
+				index[f] = i
+			}
+
+			var changes []change
+			for _, c := range FindTypeChanges(files) {
+				changes = append(changes, change{c.Name, c.OldType, c.NewType, index[c.Old], index[c.New]})
+			}
+			if !reflect.DeepEqual(test.Changes, changes) {
+				t.Errorf("incorrect type changes\nexpected: %+v\n  actual: %+v", test.Changes, changes)
+			}
+		})
+	}
+}
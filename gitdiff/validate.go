@@ -0,0 +1,308 @@
+package gitdiff
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Problem describes a problem that a Check finds in a file.
+type Problem struct {
+	// Check is the name of the check that found the problem
+	Check string
+	// Fragment is the zero-indexed index of the text fragment with the
+	// problem, or -1 if the problem applies to the whole file
+	Fragment int
+	// Line is the zero-indexed index of the line in the fragment, or -1 if
+	// the problem applies to the whole fragment or file
+	Line int
+	// Position is the location of the problem in the patch if the file was
+	// parsed with WithPatchPositions, or the zero value otherwise
+	Position PatchPosition
+	// Message describes the problem
+	Message string
+}
+
+func (p Problem) String() string {
+	var loc string
+	switch {
+	case p.Position.Line > 0:
+		loc = fmt.Sprintf("line %d: ", p.Position.Line)
+	case p.Fragment >= 0 && p.Line >= 0:
+		loc = fmt.Sprintf("fragment %d, line %d: ", p.Fragment+1, p.Line+1)
+	case p.Fragment >= 0:
+		loc = fmt.Sprintf("fragment %d: ", p.Fragment+1)
+	}
+	return fmt.Sprintf("%s%s (%s)", loc, p.Message, p.Check)
+}
+
+// Check is a named check for Validate. Run returns the problems in a file
+// with the Fragment, Line, and Message fields set. Validate sets the other
+// fields.
+type Check struct {
+	Name string
+	Run  func(f *File) []Problem
+}
+
+var (
+	// CheckFragmentOrder reports text fragments that start before the
+	// fragment before them in the old or new file.
+	CheckFragmentOrder = Check{Name: "fragment-order", Run: checkFragmentOrder}
+
+	// CheckOverlappingFragments reports text fragments that change lines
+	// included in the fragment before them.
+	CheckOverlappingFragments = Check{Name: "overlapping-fragments", Run: checkOverlappingFragments}
+
+	// CheckFragmentCounts reports text fragments with line counts that do not
+	// match their lines, as reported by TextFragment.Validate, and fragments
+	// with a new position that does not match the changes in the fragments
+	// before them.
+	CheckFragmentCounts = Check{Name: "fragment-counts", Run: checkFragmentCounts}
+
+	// CheckNewlines reports lines without a newline that are not at the end
+	// of the old or new file, and added lines at the end of the new file
+	// without a newline.
+	CheckNewlines = Check{Name: "newlines", Run: checkNewlines}
+
+	// CheckCRLF reports added lines that end with a carriage return and a
+	// line feed.
+	CheckCRLF = Check{Name: "crlf", Run: checkCRLF}
+
+	// CheckTrailingWhitespace reports added lines with spaces or tabs at the
+	// end of the line. Carriage returns before a line feed are reported by
+	// CheckCRLF instead.
+	CheckTrailingWhitespace = Check{Name: "trailing-whitespace", Run: checkTrailingWhitespace}
+
+	// CheckModes reports invalid file modes and modes or fragments that are
+	// inconsistent with the operation or type of the file, like a new file
+	// with an old mode or a mode change between a regular file and a
+	// symbolic link.
+	CheckModes = Check{Name: "modes", Run: checkModes}
+)
+
+// DefaultChecks are the checks Validate runs if it is called without checks.
+var DefaultChecks = []Check{
+	CheckFragmentOrder,
+	CheckOverlappingFragments,
+	CheckFragmentCounts,
+	CheckNewlines,
+	CheckCRLF,
+	CheckTrailingWhitespace,
+	CheckModes,
+}
+
+// Validate runs checks on f and returns the problems they find, in the order
+// of the checks. If there are no checks, Validate runs DefaultChecks.
+//
+// Unlike TextFragment.Validate, which only reports fragments that cannot be
+// applied, the checks also report valid patches with poor hygiene, like
+// additions with trailing whitespace. If f has lazy fragments, Validate loads
+// a copy of the fragments without modifying f; call LoadFragments first to
+// find the fragments that problems refer to.
+func Validate(f *File, checks ...Check) []Problem {
+	if f.lazy != nil {
+		loaded := *f
+		if err := loaded.LoadFragments(); err != nil {
+			return []Problem{{Check: "load", Fragment: -1, Line: -1, Message: err.Error()}}
+		}
+		f = &loaded
+	}
+	if len(checks) == 0 {
+		checks = DefaultChecks
+	}
+
+	var problems []Problem
+	for _, c := range checks {
+		for _, p := range c.Run(f) {
+			p.Check = c.Name
+			if p.Fragment >= 0 && p.Fragment < len(f.TextFragments) {
+				if o := f.TextFragments[p.Fragment].Origin; o != nil {
+					p.Position = o.Header
+					if pos, ok := o.Line(p.Line); ok {
+						p.Position = pos
+					}
+				}
+			}
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// fragmentRange returns the one-indexed first line and the line after the
+// end of a range in a fragment header. Empty ranges start after the line at
+// the reported position.
+func fragmentRange(position, lines int64) (start, end int64) {
+	start = fragmentStart(position, lines)
+	return start, start + lines
+}
+
+func fragmentProblem(frag int, format string, args ...interface{}) Problem {
+	return Problem{Fragment: frag, Line: -1, Message: fmt.Sprintf(format, args...)}
+}
+
+func lineProblem(frag, line int, format string, args ...interface{}) Problem {
+	return Problem{Fragment: frag, Line: line, Message: fmt.Sprintf(format, args...)}
+}
+
+func fileProblem(format string, args ...interface{}) Problem {
+	return Problem{Fragment: -1, Line: -1, Message: fmt.Sprintf(format, args...)}
+}
+
+func checkFragmentOrder(f *File) []Problem {
+	var problems []Problem
+	for i := 1; i < len(f.TextFragments); i++ {
+		prev, frag := f.TextFragments[i-1], f.TextFragments[i]
+		prevOld, _ := fragmentRange(prev.OldPosition, prev.OldLines)
+		prevNew, _ := fragmentRange(prev.NewPosition, prev.NewLines)
+		old, _ := fragmentRange(frag.OldPosition, frag.OldLines)
+		new, _ := fragmentRange(frag.NewPosition, frag.NewLines)
+
+		if old < prevOld {
+			problems = append(problems, fragmentProblem(i, "fragment starts at old line %d, before the previous fragment at line %d", old, prevOld))
+		} else if new < prevNew {
+			problems = append(problems, fragmentProblem(i, "fragment starts at new line %d, before the previous fragment at line %d", new, prevNew))
+		}
+	}
+	return problems
+}
+
+func checkOverlappingFragments(f *File) []Problem {
+	var problems []Problem
+	for i := 1; i < len(f.TextFragments); i++ {
+		prev, frag := f.TextFragments[i-1], f.TextFragments[i]
+		prevOldStart, prevOldEnd := fragmentRange(prev.OldPosition, prev.OldLines)
+		prevNewStart, prevNewEnd := fragmentRange(prev.NewPosition, prev.NewLines)
+		old, _ := fragmentRange(frag.OldPosition, frag.OldLines)
+		new, _ := fragmentRange(frag.NewPosition, frag.NewLines)
+
+		// fragments out of order are reported by CheckFragmentOrder
+		if old < prevOldStart || new < prevNewStart {
+			continue
+		}
+		if old < prevOldEnd {
+			problems = append(problems, fragmentProblem(i, "fragment overlaps the previous fragment at old line %d", old))
+		} else if new < prevNewEnd {
+			problems = append(problems, fragmentProblem(i, "fragment overlaps the previous fragment at new line %d", new))
+		}
+	}
+	return problems
+}
+
+func checkFragmentCounts(f *File) []Problem {
+	var problems []Problem
+	var delta int64
+	for i, frag := range f.TextFragments {
+		if err := frag.Validate(); err != nil {
+			problems = append(problems, fragmentProblem(i, "%v", err))
+		}
+
+		old, _ := fragmentRange(frag.OldPosition, frag.OldLines)
+		new, _ := fragmentRange(frag.NewPosition, frag.NewLines)
+		if new-old != delta {
+			problems = append(problems, fragmentProblem(i, "fragment starts at new line %d, but changes before it expect line %d", new, old+delta))
+		}
+		delta = new - old + frag.NewLines - frag.OldLines
+	}
+	return problems
+}
+
+func checkNewlines(f *File) []Problem {
+	var problems []Problem
+
+	// find the last line of each side so that other lines without newlines
+	// are reported
+	lastOld, lastNew := [2]int{-1, -1}, [2]int{-1, -1}
+	for i, frag := range f.TextFragments {
+		for j, line := range frag.Lines {
+			if line.Old() {
+				lastOld = [2]int{i, j}
+			}
+			if line.New() {
+				lastNew = [2]int{i, j}
+			}
+		}
+	}
+
+	for i, frag := range f.TextFragments {
+		for j, line := range frag.Lines {
+			if !line.NoEOL() {
+				continue
+			}
+			pos := [2]int{i, j}
+			switch {
+			case line.Old() && pos != lastOld:
+				problems = append(problems, lineProblem(i, j, "line without newline is not at the end of the old file"))
+			case line.New() && pos != lastNew:
+				problems = append(problems, lineProblem(i, j, "line without newline is not at the end of the new file"))
+			case line.Op == OpAdd:
+				problems = append(problems, lineProblem(i, j, "no newline at end of file"))
+			}
+		}
+	}
+	return problems
+}
+
+func checkCRLF(f *File) []Problem {
+	var problems []Problem
+	for i, frag := range f.TextFragments {
+		for j, line := range frag.Lines {
+			if line.Op == OpAdd && strings.HasSuffix(line.Line, "\r\n") {
+				problems = append(problems, lineProblem(i, j, "added line ends with CRLF"))
+			}
+		}
+	}
+	return problems
+}
+
+func checkTrailingWhitespace(f *File) []Problem {
+	var problems []Problem
+	for i, frag := range f.TextFragments {
+		for j, line := range frag.Lines {
+			if line.Op == OpAdd && hasTrailingWhitespace(normalizeLineEnding(line.Line)) {
+				problems = append(problems, lineProblem(i, j, "added line has trailing whitespace"))
+			}
+		}
+	}
+	return problems
+}
+
+// fileTypeMask selects the type bits of a Git file mode.
+const fileTypeMask os.FileMode = 0170000
+
+func isValidMode(mode os.FileMode) bool {
+	switch mode {
+	case 0100644, 0100755, symlinkMode, gitlinkMode:
+		return true
+	}
+	return false
+}
+
+func checkModes(f *File) []Problem {
+	var problems []Problem
+	if f.OldMode != 0 && !isValidMode(f.OldMode) {
+		problems = append(problems, fileProblem("invalid old mode %o", f.OldMode))
+	}
+	if f.NewMode != 0 && !isValidMode(f.NewMode) {
+		problems = append(problems, fileProblem("invalid new mode %o", f.NewMode))
+	}
+
+	switch {
+	case f.IsNew && f.IsDelete:
+		problems = append(problems, fileProblem("file is both new and deleted"))
+	case f.IsNew && f.OldMode != 0:
+		problems = append(problems, fileProblem("new file has old mode %o", f.OldMode))
+	case f.IsDelete && f.NewMode != 0:
+		problems = append(problems, fileProblem("deleted file has new mode %o", f.NewMode))
+	case f.OldMode != 0 && f.NewMode != 0 && (f.OldMode^f.NewMode)&fileTypeMask != 0:
+		problems = append(problems, fileProblem("file type changes from mode %o to mode %o", f.OldMode, f.NewMode))
+	}
+	if (f.IsNew || f.IsDelete) && (f.IsRename || f.IsCopy) {
+		problems = append(problems, fileProblem("new or deleted file is also a rename or copy"))
+	}
+
+	if f.IsBinary && len(f.TextFragments) > 0 {
+		problems = append(problems, fileProblem("binary file has text fragments"))
+	}
+	return problems
+}
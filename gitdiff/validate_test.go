@@ -0,0 +1,273 @@
+package gitdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	type result struct {
+		Check          string
+		Fragment, Line int
+	}
+
+	tests := map[string]struct {
+		Patch    string
+		Modify   func(f *File)
+		Checks   []Check
+		Problems []result
+	}{
+		"clean": {
+			Patch: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+@@ -10,2 +10,3 @@
+ ten
++ten and a half
+ eleven
+`,
+		},
+		"outOfOrder": {
+			Patch: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -10,2 +10,2 @@
+ ten
+-eleven
++ELEVEN
+@@ -1,2 +1,2 @@
+ one
+-two
++TWO
+`,
+			Checks:   []Check{CheckFragmentOrder, CheckOverlappingFragments},
+			Problems: []result{{"fragment-order", 1, -1}},
+		},
+		"overlapping": {
+			Patch: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+@@ -3,2 +3,2 @@
+ three
+-four
++FOUR
+`,
+			Checks:   []Check{CheckFragmentOrder, CheckOverlappingFragments},
+			Problems: []result{{"overlapping-fragments", 1, -1}},
+		},
+		"adjacentInsertions": {
+			Patch: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -0,0 +1 @@
++zero
+@@ -1,0 +3 @@
++one and a half
+`,
+			Checks: []Check{CheckOverlappingFragments, CheckFragmentCounts},
+		},
+		"positionMismatch": {
+			Patch: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,3 @@
+ one
++one and a half
+ two
+@@ -10,2 +10,2 @@
+ ten
+-eleven
++ELEVEN
+`,
+			Checks:   []Check{CheckFragmentCounts},
+			Problems: []result{{"fragment-counts", 1, -1}},
+		},
+		"countMismatch": {
+			Patch: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++TWO
+`,
+			Modify: func(f *File) {
+				f.TextFragments[0].TrailingContext = 1
+			},
+			Checks:   []Check{CheckFragmentCounts},
+			Problems: []result{{"fragment-counts", 0, -1}},
+		},
+		"addedNoNewline": {
+			Patch: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++TWO
+\ No newline at end of file
+`,
+			Problems: []result{{"newlines", 0, 2}},
+		},
+		"contextNoNewline": {
+			Patch: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,3 @@
+ one
++one and a half
+ two
+\ No newline at end of file
+`,
+		},
+		"misplacedNoNewline": {
+			Patch: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,2 @@
+-one
+\ No newline at end of file
++ONE
+ two
+`,
+			Problems: []result{{"newlines", 0, 0}},
+		},
+		"whitespace": {
+			Patch: "diff --git a/file.txt b/file.txt\n" +
+				"--- a/file.txt\n" +
+				"+++ b/file.txt\n" +
+				"@@ -1,3 +1,4 @@\n" +
+				" one \n" +
+				"-two \n" +
+				"+two\r\n" +
+				"+two and a half\t\r\n" +
+				" three\n" +
+				"@@ -5,1 +6,1 @@\n" +
+				"-five\n" +
+				"+five \n",
+			Problems: []result{
+				{"crlf", 0, 2},
+				{"crlf", 0, 3},
+				{"trailing-whitespace", 0, 3},
+				{"trailing-whitespace", 1, 1},
+			},
+		},
+		"newFileMode": {
+			Patch: `diff --git a/file.txt b/file.txt
+new file mode 100644
+--- /dev/null
++++ b/file.txt
+@@ -0,0 +1 @@
++one
+`,
+			Modify: func(f *File) {
+				f.OldMode = 0100644
+			},
+			Problems: []result{{"modes", -1, -1}},
+		},
+		"typeChange": {
+			Patch: `diff --git a/link b/link
+old mode 100644
+new mode 120000
+`,
+			Problems: []result{{"modes", -1, -1}},
+		},
+		"invalidMode": {
+			Patch: `diff --git a/file.txt b/file.txt
+old mode 100644
+new mode 100664
+`,
+			Problems: []result{{"modes", -1, -1}},
+		},
+		"modeChange": {
+			Patch: `diff --git a/script.sh b/script.sh
+old mode 100644
+new mode 100755
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := ParseAll(strings.NewReader(test.Patch))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, but got %d", len(files))
+			}
+			if test.Modify != nil {
+				test.Modify(files[0])
+			}
+
+			var results []result
+			for _, p := range Validate(files[0], test.Checks...) {
+				results = append(results, result{p.Check, p.Fragment, p.Line})
+			}
+			if !reflect.DeepEqual(test.Problems, results) {
+				t.Errorf("incorrect problems\nexpected: %+v\n  actual: %+v", test.Problems, results)
+			}
+		})
+	}
+}
+
+func TestValidateCustomCheck(t *testing.T) {
+	patch := `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++TODO
+`
+
+	check := Check{
+		Name: "todo",
+		Run: func(f *File) []Problem {
+			var problems []Problem
+			for i, frag := range f.TextFragments {
+				for j, line := range frag.Lines {
+					if line.Op == OpAdd && strings.Contains(line.Line, "TODO") {
+						problems = append(problems, Problem{Fragment: i, Line: j, Message: "added TODO"})
+					}
+				}
+			}
+			return problems
+		},
+	}
+
+	files, _, err := ParseAll(strings.NewReader(patch), WithPatchPositions(), WithLazyFragments())
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	problems := Validate(files[0], check)
+	if len(problems) != 1 {
+		t.Fatalf("expected 1 problem, but got %d: %v", len(problems), problems)
+	}
+
+	expected := Problem{
+		Check:    "todo",
+		Fragment: 0,
+		Line:     2,
+		Position: PatchPosition{Line: 7, Offset: 89},
+		Message:  "added TODO",
+	}
+	if !reflect.DeepEqual(expected, problems[0]) {
+		t.Errorf("incorrect problem\nexpected: %+v\n  actual: %+v", expected, problems[0])
+	}
+	if s := problems[0].String(); s != "line 7: added TODO (todo)" {
+		t.Errorf("incorrect string: %q", s)
+	}
+}
@@ -0,0 +1,549 @@
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WhitespaceAction is the action an Applier takes for added lines with
+// whitespace errors.
+type WhitespaceAction int
+
+const (
+	// WhitespaceNoWarn applies added lines unchanged and does not record
+	// whitespace errors
+	WhitespaceNoWarn WhitespaceAction = iota
+	// WhitespaceWarn applies added lines unchanged and records whitespace
+	// errors
+	WhitespaceWarn
+	// WhitespaceFix removes whitespace errors from added lines and records
+	// the lines it fixes
+	WhitespaceFix
+	// WhitespaceReject stops the apply at the first whitespace error, like
+	// the "error" action in Git
+	WhitespaceReject
+)
+
+func (act WhitespaceAction) String() string {
+	switch act {
+	case WhitespaceNoWarn:
+		return "nowarn"
+	case WhitespaceWarn:
+		return "warn"
+	case WhitespaceFix:
+		return "fix"
+	case WhitespaceReject:
+		return "error"
+	}
+	return "unknown"
+}
+
+// WithWhitespace configures the action an Applier takes for added lines with
+// whitespace errors, like the --whitespace flag of "git apply". The errors are
+// those in DefaultWhitespaceRules unless WithWhitespaceRules configures other
+// rules. Use WhitespaceErrors to find the lines with errors.
+//
+// With WhitespaceFix, the Applier also ignores trailing whitespace when
+// matching context and deleted lines to the source, so that fragments still
+// apply to content where the whitespace was already fixed. Context lines that
+// only match after ignoring trailing whitespace are fixed in the output.
+func WithWhitespace(act WhitespaceAction) ApplierOption {
+	return func(a *Applier) {
+		a.whitespace = act
+	}
+}
+
+// WithWhitespaceRules configures the whitespace errors an Applier checks for
+// in files with names that match any of the patterns, like the whitespace
+// attribute in a .gitattributes file. With no patterns, the rules apply to all
+// files. Like attributes, later options override earlier ones for files that
+// match both. Patterns use the syntax of .gitattributes files, where patterns
+// without a slash match the base name of files in any directory. Files that
+// do not match any option use DefaultWhitespaceRules.
+//
+// Fragments applied with ApplyTextFragment instead of ApplyFile do not have a
+// name and only use rules without patterns.
+func WithWhitespaceRules(rules WhitespaceRule, patterns ...string) ApplierOption {
+	return func(a *Applier) {
+		a.wsRules = append(a.wsRules, whitespaceRules{rules: rules, patterns: patterns})
+	}
+}
+
+// WithIgnoreWhitespace configures an Applier to ignore changes in the amount
+// of whitespace when matching context and deleted lines to the source, like
+// the --ignore-whitespace flag of "git apply". Like Git, lines must still have
+// whitespace in the same places and carriage returns at the end of lines are
+// ignored. Context lines keep the whitespace from the source in the output.
+func WithIgnoreWhitespace() ApplierOption {
+	return func(a *Applier) {
+		a.ignoreWhitespace = true
+	}
+}
+
+// WhitespaceRule is a set of whitespace errors, like the core.whitespace
+// setting in Git. The low bits of a rule are the tab width used by
+// WhitespaceIndentWithNonTab and WhitespaceTabInIndent, or zero for the
+// default width of 8.
+type WhitespaceRule int
+
+const (
+	// WhitespaceBlankAtEOL is whitespace at the end of a line
+	WhitespaceBlankAtEOL WhitespaceRule = 1 << (iota + 6)
+	// WhitespaceSpaceBeforeTab is a space before a tab in the indent of a
+	// line
+	WhitespaceSpaceBeforeTab
+	// WhitespaceIndentWithNonTab is an indent with at least as many spaces
+	// as the tab width, which could use a tab instead
+	WhitespaceIndentWithNonTab
+	// WhitespaceTabInIndent is a tab in the indent of a line
+	WhitespaceTabInIndent
+	// WhitespaceBlankAtEOF is a blank line added at the end of a file
+	WhitespaceBlankAtEOF
+	// WhitespaceCRAtEOL is not an error, but allows a carriage return at the
+	// end of a line with WhitespaceBlankAtEOL
+	WhitespaceCRAtEOL
+
+	// WhitespaceTabWidthMask selects the tab width of a rule
+	WhitespaceTabWidthMask WhitespaceRule = 077
+)
+
+// DefaultWhitespaceRules are the whitespace errors Git checks for by default:
+// trailing whitespace, spaces before tabs, and blank lines at the end of
+// files.
+const DefaultWhitespaceRules = WhitespaceBlankAtEOL | WhitespaceSpaceBeforeTab | WhitespaceBlankAtEOF
+
+// whitespaceRuleNames are the names of rules in Git, in the order Git
+// reports them.
+var whitespaceRuleNames = []struct {
+	name string
+	rule WhitespaceRule
+}{
+	{"blank-at-eol", WhitespaceBlankAtEOL},
+	{"space-before-tab", WhitespaceSpaceBeforeTab},
+	{"indent-with-non-tab", WhitespaceIndentWithNonTab},
+	{"tab-in-indent", WhitespaceTabInIndent},
+	{"blank-at-eof", WhitespaceBlankAtEOF},
+	{"cr-at-eol", WhitespaceCRAtEOL},
+}
+
+// ParseWhitespaceRules parses a list of rules separated by commas, like the
+// value of the core.whitespace setting in Git. Like Git, the list modifies
+// DefaultWhitespaceRules: rules with a "-" prefix are removed and other rules
+// are added. The list may also use "trailing-space" for both
+// "blank-at-eol" and "blank-at-eof" and "tabwidth=<n>" to set the tab width.
+func ParseWhitespaceRules(s string) (WhitespaceRule, error) {
+	rules := DefaultWhitespaceRules
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if strings.HasPrefix(name, "tabwidth=") {
+			width, err := strconv.Atoi(name[len("tabwidth="):])
+			if err != nil || width < 1 || width > int(WhitespaceTabWidthMask) {
+				return 0, fmt.Errorf("gitdiff: invalid whitespace tab width: %q", name)
+			}
+			rules = rules&^WhitespaceTabWidthMask | WhitespaceRule(width)
+			continue
+		}
+
+		negate := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+
+		var rule WhitespaceRule
+		if name == "trailing-space" {
+			rule = WhitespaceBlankAtEOL | WhitespaceBlankAtEOF
+		}
+		for _, r := range whitespaceRuleNames {
+			if r.name == name {
+				rule = r.rule
+			}
+		}
+		if rule == 0 {
+			return 0, fmt.Errorf("gitdiff: unknown whitespace rule: %q", name)
+		}
+
+		if negate {
+			rules &^= rule
+		} else {
+			rules |= rule
+		}
+	}
+
+	if rules&WhitespaceTabInIndent != 0 {
+		if rules&WhitespaceIndentWithNonTab != 0 {
+			return 0, errors.New("gitdiff: cannot enforce both tab-in-indent and indent-with-non-tab")
+		}
+		if rules&WhitespaceSpaceBeforeTab != 0 {
+			return 0, errors.New("gitdiff: cannot enforce both tab-in-indent and space-before-tab")
+		}
+	}
+	return rules, nil
+}
+
+// String returns the names of the rules separated by commas, in the syntax
+// accepted by ParseWhitespaceRules without the default rules.
+func (r WhitespaceRule) String() string {
+	var names []string
+	for _, rule := range whitespaceRuleNames {
+		if r&rule.rule != 0 {
+			names = append(names, rule.name)
+		}
+	}
+	if width := r & WhitespaceTabWidthMask; width != 0 {
+		names = append(names, fmt.Sprintf("tabwidth=%d", width))
+	}
+	return strings.Join(names, ",")
+}
+
+// tabWidth returns the tab width of the rule.
+func (r WhitespaceRule) tabWidth() int {
+	if width := r & WhitespaceTabWidthMask; width != 0 {
+		return int(width)
+	}
+	return 8
+}
+
+// describe returns the description of a set of errors used by Git.
+func (r WhitespaceRule) describe() string {
+	var errs []string
+	switch {
+	case r&WhitespaceBlankAtEOL != 0:
+		errs = append(errs, "trailing whitespace")
+	case r&WhitespaceBlankAtEOF != 0:
+		errs = append(errs, "new blank line at EOF")
+	}
+	if r&WhitespaceSpaceBeforeTab != 0 {
+		errs = append(errs, "space before tab in indent")
+	}
+	if r&WhitespaceIndentWithNonTab != 0 {
+		errs = append(errs, "indent with spaces")
+	}
+	if r&WhitespaceTabInIndent != 0 {
+		errs = append(errs, "tab in indent")
+	}
+	return strings.Join(errs, ", ")
+}
+
+// check returns the errors in the rule that line contains, except for
+// WhitespaceBlankAtEOF, which depends on the position of the line.
+func (r WhitespaceRule) check(line string) WhitespaceRule {
+	var errs WhitespaceRule
+
+	line = strings.TrimSuffix(line, "\n")
+	if r&WhitespaceCRAtEOL != 0 {
+		line = strings.TrimSuffix(line, "\r")
+	}
+
+	end := len(line)
+	if r&WhitespaceBlankAtEOL != 0 {
+		for end > 0 && isWhitespace(line[end-1]) {
+			end--
+		}
+		if end < len(line) {
+			errs |= WhitespaceBlankAtEOL
+		}
+	}
+
+	// like Git, written is the end of the indent that has no errors
+	written, i := 0, 0
+	for ; i < end; i++ {
+		if line[i] == ' ' {
+			continue
+		}
+		if line[i] != '\t' {
+			break
+		}
+		if r&WhitespaceSpaceBeforeTab != 0 && written < i {
+			errs |= WhitespaceSpaceBeforeTab
+		} else if r&WhitespaceTabInIndent != 0 {
+			errs |= WhitespaceTabInIndent
+		}
+		written = i + 1
+	}
+	if r&WhitespaceIndentWithNonTab != 0 && i-written >= r.tabWidth() {
+		errs |= WhitespaceIndentWithNonTab
+	}
+	return errs
+}
+
+// fix returns line with the errors in the rule removed, like Git. It does not
+// fix WhitespaceBlankAtEOF, which requires removing lines.
+func (r WhitespaceRule) fix(line string) string {
+	var eol string
+	if r&WhitespaceBlankAtEOL != 0 {
+		if strings.HasSuffix(line, "\n") {
+			line, eol = line[:len(line)-1], "\n"
+			if strings.HasSuffix(line, "\r") {
+				line = line[:len(line)-1]
+				if r&WhitespaceCRAtEOL != 0 {
+					eol = "\r\n"
+				}
+			}
+		}
+		line = strings.TrimRight(line, " \t\r")
+	}
+
+	lastTab, lastSpace, fixIndent := -1, -1, false
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\t' {
+			lastTab = i
+			if r&WhitespaceSpaceBeforeTab != 0 && lastSpace >= 0 {
+				fixIndent = true
+			}
+		} else if line[i] == ' ' {
+			lastSpace = i
+			if r&WhitespaceIndentWithNonTab != 0 && i-lastTab >= r.tabWidth() {
+				fixIndent = true
+			}
+		} else {
+			break
+		}
+	}
+
+	var b strings.Builder
+	switch {
+	case fixIndent:
+		// replace each full tab width of spaces in the indent with a tab
+		end := lastTab + 1
+		if r&WhitespaceIndentWithNonTab != 0 && lastSpace > lastTab {
+			end = lastSpace + 1
+		}
+		spaces := 0
+		for i := 0; i < end; i++ {
+			if line[i] != ' ' {
+				spaces = 0
+				b.WriteByte(line[i])
+			} else if spaces++; spaces == r.tabWidth() {
+				b.WriteByte('\t')
+				spaces = 0
+			}
+		}
+		b.WriteString(strings.Repeat(" ", spaces))
+		line = line[end:]
+
+	case r&WhitespaceTabInIndent != 0 && lastTab >= 0:
+		// expand the tabs in the indent to spaces
+		for i := 0; i <= lastTab; i++ {
+			if line[i] != '\t' {
+				b.WriteByte(line[i])
+				continue
+			}
+			for b.WriteByte(' '); b.Len()%r.tabWidth() != 0; {
+				b.WriteByte(' ')
+			}
+		}
+		line = line[lastTab+1:]
+	}
+
+	b.WriteString(line)
+	b.WriteString(eol)
+	return b.String()
+}
+
+// fixTrailingWhitespace returns true if the Applier fixes trailing whitespace
+// in the current file.
+func (a *Applier) fixTrailingWhitespace() bool {
+	return a.whitespace == WhitespaceFix && a.wsRule&WhitespaceBlankAtEOL != 0
+}
+
+// whitespaceRules are the whitespace rules for files that match patterns.
+type whitespaceRules struct {
+	rules    WhitespaceRule
+	patterns []string
+}
+
+// whitespaceRule returns the whitespace rules for the file with the given
+// name, or the rules without patterns if the name is empty.
+func (a *Applier) whitespaceRule(name string) WhitespaceRule {
+	rule := DefaultWhitespaceRules
+	for _, r := range a.wsRules {
+		if len(r.patterns) == 0 {
+			rule = r.rules
+			continue
+		}
+		for _, pattern := range r.patterns {
+			if name != "" && matchAttrPath(pattern, name) {
+				rule = r.rules
+				break
+			}
+		}
+	}
+	return rule
+}
+
+// WhitespaceError describes an added line with whitespace errors. With the
+// WhitespaceReject action, applying a fragment with whitespace errors fails
+// with a WhitespaceError. Users can test for this by using errors.Is with an
+// empty WhitespaceError.
+type WhitespaceError struct {
+	// Fragment is the one-indexed fragment number in the file
+	Fragment int
+	// FragmentLine is the one-indexed line number in the fragment
+	FragmentLine int
+	// Line is the content of the added line, including any whitespace errors
+	Line string
+	// Kind is the set of whitespace errors in the line
+	Kind WhitespaceRule
+	// Fixed is true if the Applier removed the whitespace errors
+	Fixed bool
+}
+
+func (e *WhitespaceError) Error() string {
+	return fmt.Sprintf("%s: %q", e.Kind.describe(), e.Line)
+}
+
+// Is implements error matching for WhitespaceError. Passing an empty instance
+// of WhitespaceError always returns true.
+func (e *WhitespaceError) Is(other error) bool {
+	if other, ok := other.(*WhitespaceError); ok {
+		return *other == WhitespaceError{} || *other == *e
+	}
+	return false
+}
+
+// WhitespaceErrors returns the added lines with whitespace errors in the text
+// fragments applied since the last call to Reset. It is always empty if the
+// whitespace action is WhitespaceNoWarn.
+func (a *Applier) WhitespaceErrors() []WhitespaceError {
+	return a.wsErrors
+}
+
+// checkWhitespace checks the added lines of f for whitespace errors and takes
+// the configured action. It returns f or a copy of f with fixed lines.
+//
+// Like Git, a fragment without trailing context adds blank lines at the end
+// of the file unless it also has no leading context, which is common for
+// fragments in patches without context that may be in the middle of a file.
+func (a *Applier) checkWhitespace(f *TextFragment) (*TextFragment, error) {
+	if a.whitespace == WhitespaceNoWarn {
+		return f, nil
+	}
+	rule := a.wsRule
+
+	blankAtEOF := len(f.Lines)
+	if rule&WhitespaceBlankAtEOF != 0 && f.TrailingContext == 0 && (f.LeadingContext > 0 || f.OldPosition == 0) {
+		for blankAtEOF > 0 {
+			line := f.Lines[blankAtEOF-1]
+			if line.Op != OpAdd || strings.TrimLeft(line.Line, " \t\r\n") != "" {
+				break
+			}
+			blankAtEOF--
+		}
+	}
+
+	fixed := f
+	for i, line := range f.Lines {
+		if line.Op != OpAdd {
+			continue
+		}
+		kind := rule.check(line.Line)
+		if i >= blankAtEOF {
+			kind |= WhitespaceBlankAtEOF
+		}
+		if kind == 0 {
+			continue
+		}
+
+		wsErr := WhitespaceError{
+			Fragment:     len(a.matches) + 1,
+			FragmentLine: i + 1,
+			Line:         line.Line,
+			Kind:         kind,
+			Fixed:        a.whitespace == WhitespaceFix,
+		}
+
+		switch a.whitespace {
+		case WhitespaceReject:
+			return nil, applyError(&wsErr, fragLineNum(i))
+		case WhitespaceFix:
+			if fixed == f {
+				frag := *f
+				frag.Lines = append([]Line(nil), f.Lines...)
+				fixed = &frag
+			}
+			fixed.Lines[i].Line = rule.fix(line.Line)
+		}
+		a.wsErrors = append(a.wsErrors, wsErr)
+	}
+
+	if fixed != f && blankAtEOF < len(f.Lines) {
+		// remove the blank lines, which are all additions
+		n := int64(len(f.Lines) - blankAtEOF)
+		fixed.Lines = fixed.Lines[:blankAtEOF]
+		fixed.NewLines -= n
+		fixed.LinesAdded -= n
+	}
+	return fixed, nil
+}
+
+// matchLine returns true if the source line matches the fragment line,
+// ignoring whitespace and line endings as configured.
+func (a *Applier) matchLine(src []byte, line string) bool {
+	s := string(src)
+	if s == line {
+		return true
+	}
+	if a.lineEndingPolicy() == LineEndingPreserve {
+		s, line = normalizeLineEnding(s), normalizeLineEnding(line)
+		if s == line {
+			return true
+		}
+	}
+	switch {
+	case a.ignoreWhitespace:
+		return matchLineIgnoreSpace(s, line)
+	case a.fixTrailingWhitespace():
+		return trimTrailingWhitespace(s) == trimTrailingWhitespace(line)
+	}
+	return false
+}
+
+// matchLineIgnoreSpace returns true if the lines are equal when treating all
+// sequences of whitespace as the same and ignoring carriage returns at the end
+// of the lines. The lines must both end with or without a newline.
+func matchLineIgnoreSpace(a, b string) bool {
+	if strings.HasSuffix(a, "\n") != strings.HasSuffix(b, "\n") {
+		return false
+	}
+	a, b = strings.TrimRight(a, "\r\n"), strings.TrimRight(b, "\r\n")
+
+	for len(a) > 0 && len(b) > 0 {
+		if isWhitespace(a[0]) {
+			// check both lines so that "a b" does not match "ab"
+			if !isWhitespace(b[0]) {
+				return false
+			}
+			a, b = strings.TrimLeft(a, " \t\r"), strings.TrimLeft(b, " \t\r")
+			continue
+		}
+		if a[0] != b[0] {
+			return false
+		}
+		a, b = a[1:], b[1:]
+	}
+	return a == "" && b == ""
+}
+
+// hasTrailingWhitespace returns true if line has whitespace before the end of
+// the line.
+func hasTrailingWhitespace(line string) bool {
+	line = strings.TrimSuffix(line, "\n")
+	return line != "" && isWhitespace(line[len(line)-1])
+}
+
+// trimTrailingWhitespace removes whitespace before the end of line, keeping
+// the newline if it is present.
+func trimTrailingWhitespace(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return strings.TrimRight(line[:len(line)-1], " \t\r") + "\n"
+	}
+	return strings.TrimRight(line, " \t\r")
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r'
+}
@@ -0,0 +1,274 @@
+package gitdiff
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchLineIgnoreSpace(t *testing.T) {
+	tests := map[string]struct {
+		A, B  string
+		Match bool
+	}{
+		"equal":            {A: "a b\n", B: "a b\n", Match: true},
+		"spaceAmount":      {A: "a  b\n", B: "a\tb\n", Match: true},
+		"leadingSpace":     {A: "\tfunc()\n", B: "    func()\n", Match: true},
+		"carriageReturn":   {A: "a b\r\n", B: "a b\n", Match: true},
+		"noEOL":            {A: "a b", B: "a  b", Match: true},
+		"missingSpace":     {A: "a b\n", B: "ab\n", Match: false},
+		"trailingSpace":    {A: "a b  \n", B: "a b\n", Match: false},
+		"differentContent": {A: "a b\n", B: "a c\n", Match: false},
+		"differentEOL":     {A: "a b\n", B: "a b", Match: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if match := matchLineIgnoreSpace(test.A, test.B); match != test.Match {
+				t.Errorf("incorrect match for %q and %q: expected %t, actual %t", test.A, test.B, test.Match, match)
+			}
+		})
+	}
+}
+
+func TestTrimTrailingWhitespace(t *testing.T) {
+	tests := map[string]string{
+		"line\n":       "line\n",
+		"line  \n":     "line\n",
+		"line \t\r\n":  "line\n",
+		"line\t":       "line",
+		"  \n":         "\n",
+		" lead space ": " lead space",
+	}
+
+	for input, expected := range tests {
+		if actual := trimTrailingWhitespace(input); actual != expected {
+			t.Errorf("incorrect trimmed line for %q: expected %q, actual %q", input, expected, actual)
+		}
+		if actual := hasTrailingWhitespace(input); actual != (input != expected) {
+			t.Errorf("incorrect trailing whitespace for %q: expected %t, actual %t", input, input != expected, actual)
+		}
+	}
+}
+
+// TestWhitespaceRules compares errors and fixes to the output of "git diff
+// --check" and "git apply --whitespace=fix" with the core.whitespace setting.
+func TestWhitespaceRules(t *testing.T) {
+	tests := map[string]struct {
+		Rules  string
+		Line   string
+		Errors string
+		Fixed  string
+	}{
+		"clean":                {Line: "x\t y\n", Fixed: "x\t y\n"},
+		"trailingSpace":        {Line: "trail \n", Errors: "trailing whitespace", Fixed: "trail\n"},
+		"trailingTab":          {Line: "trail\t", Errors: "trailing whitespace", Fixed: "trail"},
+		"trailingCR":           {Line: "crlf\r\n", Errors: "trailing whitespace", Fixed: "crlf\n"},
+		"blankLine":            {Line: "\t  \n", Errors: "trailing whitespace", Fixed: "\n"},
+		"spaceBeforeTab":       {Line: " \tsbt\n", Errors: "space before tab in indent", Fixed: "\tsbt\n"},
+		"spacesBeforeTabs":     {Line: "  \t \tsbt\n", Errors: "space before tab in indent", Fixed: "\t\tsbt\n"},
+		"multipleErrors":       {Line: "   \t x \n", Errors: "trailing whitespace, space before tab in indent", Fixed: "\t x\n"},
+		"spaceIndentAllowed":   {Line: "        eight\n", Fixed: "        eight\n"},
+		"indentWithSpaces":     {Rules: "indent-with-non-tab", Line: "        eight\n", Errors: "indent with spaces", Fixed: "\teight\n"},
+		"indentAfterTab":       {Rules: "indent-with-non-tab", Line: "\t        eight\n", Errors: "indent with spaces", Fixed: "\t\teight\n"},
+		"shortIndent":          {Rules: "indent-with-non-tab", Line: "    four\n", Fixed: "    four\n"},
+		"indentTabWidth":       {Rules: "indent-with-non-tab,tabwidth=4", Line: "    four\n", Errors: "indent with spaces", Fixed: "\tfour\n"},
+		"indentTabWidthDouble": {Rules: "indent-with-non-tab,tabwidth=4", Line: "        eight\n", Errors: "indent with spaces", Fixed: "\t\teight\n"},
+		"tabInIndent":          {Rules: "tab-in-indent,-space-before-tab", Line: " \tsbt\n", Errors: "tab in indent", Fixed: "        sbt\n"},
+		"tabsInIndent":         {Rules: "tab-in-indent,-space-before-tab", Line: "  \t \tsbt\n", Errors: "tab in indent", Fixed: strings.Repeat(" ", 16) + "sbt\n"},
+		"tabInIndentTrailing":  {Rules: "tab-in-indent,-space-before-tab", Line: "   \t x \n", Errors: "trailing whitespace, tab in indent", Fixed: strings.Repeat(" ", 9) + "x\n"},
+		"tabInIndentTabWidth":  {Rules: "tab-in-indent,-space-before-tab,tabwidth=4", Line: "\t        x\n", Errors: "tab in indent", Fixed: strings.Repeat(" ", 12) + "x\n"},
+		"crAtEOL":              {Rules: "cr-at-eol", Line: "crlf\r\n", Fixed: "crlf\r\n"},
+		"crAtEOLTrailing":      {Rules: "cr-at-eol", Line: "crlf \r\n", Errors: "trailing whitespace", Fixed: "crlf\r\n"},
+		"noBlankAtEOL":         {Rules: "-blank-at-eol", Line: "trail \n", Fixed: "trail \n"},
+		"noBlankAtEOLIndent":   {Rules: "-blank-at-eol", Line: "   \t x \n", Errors: "space before tab in indent", Fixed: "\t x \n"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			rules, err := ParseWhitespaceRules(test.Rules)
+			if err != nil {
+				t.Fatalf("unexpected error parsing rules: %v", err)
+			}
+			if errs := rules.check(test.Line).describe(); errs != test.Errors {
+				t.Errorf("incorrect errors: expected %q, actual %q", test.Errors, errs)
+			}
+			if fixed := rules.fix(test.Line); fixed != test.Fixed {
+				t.Errorf("incorrect fixed line: expected %q, actual %q", test.Fixed, fixed)
+			}
+		})
+	}
+}
+
+func TestParseWhitespaceRules(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Rules  WhitespaceRule
+		String string
+		Err    interface{}
+	}{
+		"empty": {
+			Rules:  DefaultWhitespaceRules,
+			String: "blank-at-eol,space-before-tab,blank-at-eof",
+		},
+		"addRemove": {
+			Input:  "indent-with-non-tab, -space-before-tab",
+			Rules:  WhitespaceBlankAtEOL | WhitespaceIndentWithNonTab | WhitespaceBlankAtEOF,
+			String: "blank-at-eol,indent-with-non-tab,blank-at-eof",
+		},
+		"trailingSpace": {
+			Input:  "-trailing-space,cr-at-eol",
+			Rules:  WhitespaceSpaceBeforeTab | WhitespaceCRAtEOL,
+			String: "space-before-tab,cr-at-eol",
+		},
+		"tabWidth": {
+			Input:  "tabwidth=4",
+			Rules:  DefaultWhitespaceRules | 4,
+			String: "blank-at-eol,space-before-tab,blank-at-eof,tabwidth=4",
+		},
+		"invalidTabWidth": {
+			Input: "tabwidth=0",
+			Err:   "invalid whitespace tab width",
+		},
+		"unknown": {
+			Input: "tab-after-space",
+			Err:   "unknown whitespace rule",
+		},
+		"conflict": {
+			Input: "tab-in-indent",
+			Err:   "cannot enforce both tab-in-indent and space-before-tab",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			rules, err := ParseWhitespaceRules(test.Input)
+			if test.Err != nil {
+				assertError(t, test.Err, err, "parsing rules")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing rules: %v", err)
+			}
+			if rules != test.Rules {
+				t.Errorf("incorrect rules: expected %s, actual %s", test.Rules, rules)
+			}
+			if s := rules.String(); s != test.String {
+				t.Errorf("incorrect string: expected %q, actual %q", test.String, s)
+			}
+		})
+	}
+}
+
+func TestApplyWhitespaceRules(t *testing.T) {
+	const (
+		src   = "base\n"
+		patch = `diff --git a/%[1]s b/%[1]s
+--- a/%[1]s
++++ b/%[1]s
+@@ -1 +1,4 @@
+ base
++ 	more
++
++  
+`
+	)
+
+	tests := map[string]struct {
+		Name    string
+		Options []ApplierOption
+		Out     string
+		Errors  []WhitespaceRule
+	}{
+		"warn": {
+			Options: []ApplierOption{WithWhitespace(WhitespaceWarn)},
+			Out:     "base\n \tmore\n\n  \n",
+			Errors: []WhitespaceRule{
+				WhitespaceSpaceBeforeTab,
+				WhitespaceBlankAtEOF,
+				WhitespaceBlankAtEOL | WhitespaceBlankAtEOF,
+			},
+		},
+		"fix": {
+			Options: []ApplierOption{WithWhitespace(WhitespaceFix)},
+			Out:     "base\n\tmore\n",
+			Errors: []WhitespaceRule{
+				WhitespaceSpaceBeforeTab,
+				WhitespaceBlankAtEOF,
+				WhitespaceBlankAtEOL | WhitespaceBlankAtEOF,
+			},
+		},
+		"allFiles": {
+			Options: []ApplierOption{
+				WithWhitespace(WhitespaceFix),
+				WithWhitespaceRules(WhitespaceBlankAtEOL),
+			},
+			Out:    "base\n \tmore\n\n\n",
+			Errors: []WhitespaceRule{WhitespaceBlankAtEOL},
+		},
+		"matchingPath": {
+			Name: "docs/README.md",
+			Options: []ApplierOption{
+				WithWhitespace(WhitespaceWarn),
+				WithWhitespaceRules(WhitespaceSpaceBeforeTab, "*.txt", "*.md"),
+			},
+			Out:    "base\n \tmore\n\n  \n",
+			Errors: []WhitespaceRule{WhitespaceSpaceBeforeTab},
+		},
+		"otherPath": {
+			Name: "docs/README.md",
+			Options: []ApplierOption{
+				WithWhitespace(WhitespaceWarn),
+				WithWhitespaceRules(WhitespaceSpaceBeforeTab, "/README.md"),
+			},
+			Out: "base\n \tmore\n\n  \n",
+			Errors: []WhitespaceRule{
+				WhitespaceSpaceBeforeTab,
+				WhitespaceBlankAtEOF,
+				WhitespaceBlankAtEOL | WhitespaceBlankAtEOF,
+			},
+		},
+		"laterRules": {
+			Name: "docs/README.md",
+			Options: []ApplierOption{
+				WithWhitespace(WhitespaceWarn),
+				WithWhitespaceRules(WhitespaceSpaceBeforeTab, "docs/*"),
+				WithWhitespaceRules(0, "*.md"),
+			},
+			Out: "base\n \tmore\n\n  \n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fname := test.Name
+			if fname == "" {
+				fname = "file.txt"
+			}
+
+			files, _, err := ParseAll(strings.NewReader(fmt.Sprintf(patch, fname)))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			var out bytes.Buffer
+			applier := NewApplier(strings.NewReader(src), test.Options...)
+			if err := applier.ApplyFile(&out, files[0]); err != nil {
+				t.Fatalf("unexpected error applying patch: %v", err)
+			}
+			if out.String() != test.Out {
+				t.Errorf("incorrect output: expected %q, actual %q", test.Out, out.String())
+			}
+
+			var errs []WhitespaceRule
+			for _, wsErr := range applier.WhitespaceErrors() {
+				errs = append(errs, wsErr.Kind)
+			}
+			if !reflect.DeepEqual(test.Errors, errs) {
+				t.Errorf("incorrect whitespace errors\nexpected: %v\n  actual: %v", test.Errors, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package gitdiff
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+// defaultWordRegexp matches words like "git diff --word-diff" without a
+// --word-diff-regex option: a word is a sequence of non-whitespace characters.
+var defaultWordRegexp = regexp.MustCompile(`\S+`)
+
+// WordChunk is a part of a line in a word diff. Deleted chunks appear only in
+// the old line, added chunks appear only in the new line, and context chunks
+// appear in both lines.
+type WordChunk struct {
+	Op   LineOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// Range is a range of bytes [Start, End) in a string.
+type Range struct {
+	Start, End int
+}
+
+// WordDiffOption configures the behavior of DiffWords.
+type WordDiffOption func(*wordDiffer)
+
+// WithWordRegexp sets the regular expression that matches words, like the
+// --word-diff-regex flag of "git diff". Text between matches is compared as
+// if it were a word. Use "." to compare individual characters.
+func WithWordRegexp(re *regexp.Regexp) WordDiffOption {
+	return func(d *wordDiffer) {
+		d.re = re
+	}
+}
+
+type wordDiffer struct {
+	re *regexp.Regexp
+}
+
+// DiffWords computes the changes between the words of an old and a new line
+// and returns the chunks of the lines in order. Concatenating the context and
+// deleted chunks produces old and concatenating the context and added chunks
+// produces new, without any trailing newline. Deleted chunks come before the
+// added chunks that replace them.
+func DiffWords(old, new string, opts ...WordDiffOption) []WordChunk {
+	d := wordDiffer{re: defaultWordRegexp}
+	for _, opt := range opts {
+		opt(&d)
+	}
+
+	a := d.split(strings.TrimSuffix(old, "\n"))
+	b := d.split(strings.TrimSuffix(new, "\n"))
+
+	var chunks []WordChunk
+	appendChunk := func(op LineOp, words []string) {
+		text := strings.Join(words, "")
+		if text == "" {
+			return
+		}
+		if n := len(chunks); n > 0 && chunks[n-1].Op == op {
+			chunks[n-1].Text += text
+			return
+		}
+		chunks = append(chunks, WordChunk{Op: op, Text: text})
+	}
+
+	i := 0
+	for _, h := range diffLines(a, b) {
+		appendChunk(OpContext, a[i:h.AStart])
+		appendChunk(OpDelete, a[h.AStart:h.AEnd])
+		appendChunk(OpAdd, b[h.BStart:h.BEnd])
+		i = h.AEnd
+	}
+	appendChunk(OpContext, a[i:])
+
+	return chunks
+}
+
+// split divides s into words and the text between the words.
+func (d *wordDiffer) split(s string) []string {
+	var words []string
+	last := 0
+	for _, m := range d.re.FindAllStringIndex(s, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		if m[0] > last {
+			words = append(words, s[last:m[0]])
+		}
+		words = append(words, s[m[0]:m[1]])
+		last = m[1]
+	}
+	if last < len(s) {
+		words = append(words, s[last:])
+	}
+	return words
+}
+
+// WordRanges returns the ranges of the deleted chunks in the old line and the
+// ranges of the added chunks in the new line.
+func WordRanges(chunks []WordChunk) (deleted, added []Range) {
+	var oldPos, newPos int
+	for _, c := range chunks {
+		switch c.Op {
+		case OpContext:
+			oldPos += len(c.Text)
+			newPos += len(c.Text)
+		case OpDelete:
+			deleted = append(deleted, Range{Start: oldPos, End: oldPos + len(c.Text)})
+			oldPos += len(c.Text)
+		case OpAdd:
+			added = append(added, Range{Start: newPos, End: newPos + len(c.Text)})
+			newPos += len(c.Text)
+		}
+	}
+	return deleted, added
+}
+
+// FormatWords returns the chunks as a single line in the same format as "git
+// diff --word-diff=plain", with deleted text in "[-" and "-]" and added text
+// in "{+" and "+}".
+func FormatWords(chunks []WordChunk) string {
+	var b strings.Builder
+	for _, c := range chunks {
+		switch c.Op {
+		case OpDelete:
+			b.WriteString("[-" + c.Text + "-]")
+		case OpAdd:
+			b.WriteString("{+" + c.Text + "+}")
+		default:
+			b.WriteString(c.Text)
+		}
+	}
+	return b.String()
+}
+
+// WordDiff is the word diff of a deleted line and the added line that
+// replaces it in a text fragment.
+type WordDiff struct {
+	// OldLine and NewLine are the indices of the deleted and added lines in
+	// the Lines of the fragment
+	OldLine, NewLine int
+	// Chunks are the chunks of the lines, as returned by DiffWords
+	Chunks []WordChunk
+}
+
+// WordDiffs computes word diffs for the replaced lines in the fragment. Like
+// diff-highlight, it pairs each run of deleted lines with the run of added
+// lines immediately after it, matching lines by their position in the runs.
+// Lines without a match in the other run have no word diff.
+func (f *TextFragment) WordDiffs(opts ...WordDiffOption) []WordDiff {
+	var diffs []WordDiff
+	for i := 0; i < len(f.Lines); {
+		if f.Lines[i].Op != OpDelete {
+			i++
+			continue
+		}
+
+		delStart := i
+		for i < len(f.Lines) && f.Lines[i].Op == OpDelete {
+			i++
+		}
+		addStart := i
+		for i < len(f.Lines) && f.Lines[i].Op == OpAdd {
+			i++
+		}
+
+		for j := 0; delStart+j < addStart && addStart+j < i; j++ {
+			oldLine, newLine := delStart+j, addStart+j
+			diffs = append(diffs, WordDiff{
+				OldLine: oldLine,
+				NewLine: newLine,
+				Chunks:  DiffWords(f.Lines[oldLine].Line, f.Lines[newLine].Line, opts...),
+			})
+		}
+	}
+	return diffs
+}
+
+// WordFragment describes changed lines in a word diff generated by "git diff
+// --word-diff=porcelain". Unlike a TextFragment, each line contains the
+// changed words instead of the line appearing once as deleted and once as
+// added.
+type WordFragment struct {
+	Comment string `json:"comment,omitempty"`
+
+	OldPosition int64 `json:"old_position,omitempty"`
+	OldLines    int64 `json:"old_lines,omitempty"`
+
+	NewPosition int64 `json:"new_position,omitempty"`
+	NewLines    int64 `json:"new_lines,omitempty"`
+
+	Lines []WordLine `json:"lines,omitempty"`
+}
+
+// WordLine is a line in a word fragment.
+type WordLine struct {
+	Chunks []WordChunk `json:"chunks,omitempty"`
+}
+
+// Old returns true if the line appears in the old content of the fragment.
+// Lines without chunks are empty lines and appear in both the old and the new
+// content.
+func (wl WordLine) Old() bool {
+	return !wl.only(OpAdd)
+}
+
+// New returns true if the line appears in the new content of the fragment.
+func (wl WordLine) New() bool {
+	return !wl.only(OpDelete)
+}
+
+// only returns true if the line has chunks and all of them have operation op.
+func (wl WordLine) only(op LineOp) bool {
+	for _, c := range wl.Chunks {
+		if c.Op != op {
+			return false
+		}
+	}
+	return len(wl.Chunks) > 0
+}
+
+// OldText returns the content of the line in the old content of the fragment,
+// without a trailing newline.
+func (wl WordLine) OldText() string {
+	return wl.text(OpAdd)
+}
+
+// NewText returns the content of the line in the new content of the
+// fragment, without a trailing newline.
+func (wl WordLine) NewText() string {
+	return wl.text(OpDelete)
+}
+
+func (wl WordLine) text(skip LineOp) string {
+	var b strings.Builder
+	for _, c := range wl.Chunks {
+		if c.Op != skip {
+			b.WriteString(c.Text)
+		}
+	}
+	return b.String()
+}
+
+// ParseWordDiff parses the output of "git diff --word-diff=porcelain", which
+// is not a valid patch. It returns the parsed files and any content before
+// the first file, like ParseAll. Text files have WordFragments instead of
+// TextFragments.
+//
+// Git compares words without regard to line boundaries, so the lines in a
+// word fragment may not match the line counts in its header. ParseWordDiff
+// does not check the counts.
+func ParseWordDiff(r io.Reader, opts ...ParseOption) ([]*File, string, error) {
+	return ParseAll(r, append(opts[:len(opts):len(opts)], func(p *parser) { p.wordDiff = true })...)
+}
+
+// ParseWordFragments parses word fragments until the next file header or the
+// end of the stream and attaches them to the given file. It returns the
+// number of fragments that were added.
+func (p *parser) ParseWordFragments(f *File) (n int, err error) {
+	for {
+		header, err := p.ParseTextFragmentHeader()
+		if err != nil {
+			return n, err
+		}
+		if header == nil {
+			return n, nil
+		}
+
+		if err := p.checkFragmentLimit(-1, n); err != nil {
+			return n, err
+		}
+
+		frag := &WordFragment{
+			Comment:     header.Comment,
+			OldPosition: header.OldPosition,
+			OldLines:    header.OldLines,
+			NewPosition: header.NewPosition,
+			NewLines:    header.NewLines,
+		}
+		if err := p.ParseWordChunk(frag); err != nil {
+			return n, err
+		}
+
+		f.WordFragments = append(f.WordFragments, frag)
+		n++
+	}
+}
+
+// ParseWordChunk parses the lines of a word fragment. Each line of content is
+// a sequence of chunks, one per line of input prefixed by the operation of the
+// chunk, followed by a line containing "~".
+func (p *parser) ParseWordChunk(frag *WordFragment) error {
+	if p.Line(0) == "" {
+		return p.Errorf(0, KindTruncatedFragment, "no content following fragment header")
+	}
+
+	var line WordLine
+lines:
+	for {
+		s := p.Line(0)
+		if s == "" {
+			break
+		}
+
+		op, data := s[0], strings.TrimSuffix(s[1:], "\n")
+		switch op {
+		case ' ':
+			line.Chunks = append(line.Chunks, WordChunk{OpContext, data})
+		case '-':
+			line.Chunks = append(line.Chunks, WordChunk{OpDelete, data})
+		case '+':
+			line.Chunks = append(line.Chunks, WordChunk{OpAdd, data})
+		case '~':
+			frag.Lines = append(frag.Lines, line)
+			line = WordLine{}
+		default:
+			break lines
+		}
+
+		if err := p.Next(); err != nil && err != io.EOF {
+			return err
+		}
+	}
+
+	if len(line.Chunks) > 0 {
+		return p.Errorf(0, KindTruncatedFragment, "word diff line does not end with \"~\"")
+	}
+	if len(frag.Lines) == 0 {
+		return p.Errorf(0, KindEmptyFragment, "fragment contains no lines")
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package gitdiff
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	tests := map[string]struct {
+		Old, New  string
+		Options   []WordDiffOption
+		Chunks    []WordChunk
+		Formatted string
+	}{
+		"unchanged": {
+			Old: "one two three\n",
+			New: "one two three\n",
+			Chunks: []WordChunk{
+				{OpContext, "one two three"},
+			},
+			Formatted: "one two three",
+		},
+		"replaceWord": {
+			Old: "one two three\n",
+			New: "one 2 three\n",
+			Chunks: []WordChunk{
+				{OpContext, "one "},
+				{OpDelete, "two"},
+				{OpAdd, "2"},
+				{OpContext, " three"},
+			},
+			Formatted: "one [-two-]{+2+} three",
+		},
+		"addWords": {
+			Old: "one two three",
+			New: "zero one two three four",
+			Chunks: []WordChunk{
+				{OpAdd, "zero "},
+				{OpContext, "one two three"},
+				{OpAdd, " four"},
+			},
+			Formatted: "{+zero +}one two three{+ four+}",
+		},
+		"deleteWords": {
+			Old: "one two three four",
+			New: "one four",
+			Chunks: []WordChunk{
+				{OpContext, "one "},
+				{OpDelete, "two three "},
+				{OpContext, "four"},
+			},
+			Formatted: "one [-two three -]four",
+		},
+		"whitespace": {
+			Old: "a b",
+			New: "a\tb",
+			Chunks: []WordChunk{
+				{OpContext, "a"},
+				{OpDelete, " "},
+				{OpAdd, "\t"},
+				{OpContext, "b"},
+			},
+			Formatted: "a[- -]{+\t+}b",
+		},
+		"punctuation": {
+			Old:     "call(x, y)",
+			New:     "call(x, z)",
+			Options: []WordDiffOption{WithWordRegexp(regexp.MustCompile(`\w+|[^\w\s]`))},
+			Chunks: []WordChunk{
+				{OpContext, "call(x, "},
+				{OpDelete, "y"},
+				{OpAdd, "z"},
+				{OpContext, ")"},
+			},
+			Formatted: "call(x, [-y-]{+z+})",
+		},
+		"characters": {
+			Old:     "color",
+			New:     "colour",
+			Options: []WordDiffOption{WithWordRegexp(regexp.MustCompile(`.`))},
+			Chunks: []WordChunk{
+				{OpContext, "colo"},
+				{OpAdd, "u"},
+				{OpContext, "r"},
+			},
+			Formatted: "colo{+u+}r",
+		},
+		"emptyOld": {
+			Old: "\n",
+			New: "new\n",
+			Chunks: []WordChunk{
+				{OpAdd, "new"},
+			},
+			Formatted: "{+new+}",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			chunks := DiffWords(test.Old, test.New, test.Options...)
+			if !reflect.DeepEqual(test.Chunks, chunks) {
+				t.Fatalf("incorrect chunks\nexpected: %+v\n  actual: %+v", test.Chunks, chunks)
+			}
+
+			var oldText, newText strings.Builder
+			for _, c := range chunks {
+				if c.Op != OpAdd {
+					oldText.WriteString(c.Text)
+				}
+				if c.Op != OpDelete {
+					newText.WriteString(c.Text)
+				}
+			}
+			if oldText.String() != strings.TrimSuffix(test.Old, "\n") || newText.String() != strings.TrimSuffix(test.New, "\n") {
+				t.Errorf("chunks do not reconstruct lines: %q, %q", oldText.String(), newText.String())
+			}
+
+			if formatted := FormatWords(chunks); formatted != test.Formatted {
+				t.Errorf("incorrect formatted words: expected %q, actual %q", test.Formatted, formatted)
+			}
+		})
+	}
+}
+
+func TestWordRanges(t *testing.T) {
+	chunks := DiffWords("one two three four", "one 2 three 4 five")
+
+	deleted, added := WordRanges(chunks)
+	if expected := []Range{{4, 7}, {14, 18}}; !reflect.DeepEqual(expected, deleted) {
+		t.Errorf("incorrect deleted ranges: expected %v, actual %v", expected, deleted)
+	}
+	if expected := []Range{{4, 5}, {12, 18}}; !reflect.DeepEqual(expected, added) {
+		t.Errorf("incorrect added ranges: expected %v, actual %v", expected, added)
+	}
+}
+
+func TestTextFragmentWordDiffs(t *testing.T) {
+	frag := &TextFragment{
+		Lines: []Line{
+			{OpContext, "keep\n"},
+			{OpDelete, "a b\n"},
+			{OpDelete, "c d\n"},
+			{OpDelete, "e f\n"},
+			{OpAdd, "a B\n"},
+			{OpAdd, "C d\n"},
+			{OpContext, "keep\n"},
+			{OpAdd, "new\n"},
+			{OpDelete, "old\n"},
+		},
+	}
+
+	expected := []WordDiff{
+		{OldLine: 1, NewLine: 4, Chunks: []WordChunk{{OpContext, "a "}, {OpDelete, "b"}, {OpAdd, "B"}}},
+		{OldLine: 2, NewLine: 5, Chunks: []WordChunk{{OpDelete, "c"}, {OpAdd, "C"}, {OpContext, " d"}}},
+	}
+
+	diffs := frag.WordDiffs()
+	if !reflect.DeepEqual(expected, diffs) {
+		t.Errorf("incorrect word diffs\nexpected: %+v\n  actual: %+v", expected, diffs)
+	}
+}
+
+func TestParseWordDiff(t *testing.T) {
+	f, err := os.Open("testdata/word_diff.patch")
+	if err != nil {
+		t.Fatalf("unexpected error opening patch: %v", err)
+	}
+	defer f.Close()
+
+	files, _, err := ParseWordDiff(f)
+	if err != nil {
+		t.Fatalf("unexpected error parsing word diff: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("incorrect number of files: expected 3, actual %d", len(files))
+	}
+
+	expected := []*WordFragment{
+		{
+			OldPosition: 1,
+			OldLines:    4,
+			NewPosition: 1,
+			NewLines:    4,
+			Lines: []WordLine{
+				{Chunks: []WordChunk{{OpContext, "one "}, {OpDelete, "two"}, {OpAdd, "2"}, {OpContext, " three"}}},
+				{Chunks: []WordChunk{{OpContext, "keep"}}},
+				{Chunks: []WordChunk{{OpDelete, "old line"}}},
+				{Chunks: []WordChunk{{OpContext, "last"}}},
+				{Chunks: []WordChunk{{OpAdd, "added line"}}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(expected, files[0].WordFragments) {
+		t.Errorf("incorrect word fragments\nexpected: %+v\n  actual: %+v", expected, files[0].WordFragments)
+	}
+	if len(files[0].TextFragments) > 0 {
+		t.Errorf("expected no text fragments, but got %d", len(files[0].TextFragments))
+	}
+	if !files[2].IsNew || len(files[2].WordFragments) != 1 {
+		t.Errorf("incorrect new file: %+v", files[2])
+	}
+
+	lines := files[0].WordFragments[0].Lines
+	if lines[0].OldText() != "one two three" || lines[0].NewText() != "one 2 three" {
+		t.Errorf("incorrect text of line 0: %q, %q", lines[0].OldText(), lines[0].NewText())
+	}
+	if !lines[2].Old() || lines[2].New() {
+		t.Errorf("deleted line should only be old: old=%t new=%t", lines[2].Old(), lines[2].New())
+	}
+	if lines[4].Old() || !lines[4].New() {
+		t.Errorf("added line should only be new: old=%t new=%t", lines[4].Old(), lines[4].New())
+	}
+
+	err = NewApplier(strings.NewReader("")).ApplyFile(io.Discard, files[0])
+	assertError(t, "cannot apply word diff", err, "applying word diff")
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("unexpected error seeking patch: %v", err)
+	}
+	patch, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("unexpected error reading patch: %v", err)
+	}
+
+	var b strings.Builder
+	for _, file := range files {
+		b.WriteString(file.String())
+	}
+	if b.String() != string(patch) {
+		t.Errorf("incorrect formatted word diff\nexpected:\n%s\nactual:\n%s", patch, b.String())
+	}
+}
+
+func TestParseWordDiffEmptyLine(t *testing.T) {
+	const patch = `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1 +1,2 @@
+ a
+~
+~
+`
+
+	files, _, err := ParseWordDiff(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing word diff: %v", err)
+	}
+
+	lines := files[0].WordFragments[0].Lines
+	if len(lines) != 2 || len(lines[1].Chunks) != 0 {
+		t.Fatalf("incorrect lines: %+v", lines)
+	}
+	if !lines[1].Old() || !lines[1].New() {
+		t.Errorf("empty line should be old and new: old=%t new=%t", lines[1].Old(), lines[1].New())
+	}
+}
+
+func TestParseWordDiffErrors(t *testing.T) {
+	tests := map[string]struct {
+		Input string
+		Err   interface{}
+	}{
+		"missingEndMarker": {
+			Input: `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1 +1 @@
+-a
++b
+`,
+			Err: "does not end with",
+		},
+		"noLines": {
+			Input: `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1 +1 @@
+`,
+			Err: "no content",
+		},
+		"emptyFragment": {
+			Input: `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1 +1 @@
+@@ -2 +2 @@
+-a
++b
+~
+`,
+			Err: "no lines",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ParseWordDiff(strings.NewReader(test.Input))
+			assertError(t, test.Err, err, "parsing word diff")
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package gogit
+
+import (
+	"errors"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// CommitTarget is a gitdiff.AmTarget that applies patches to the tree of a
+// commit with ApplyTree and records each patch as a new commit, like "git am"
+// in a repository without a worktree. The new commit has the author, date,
+// and message of the patch header and the previous commit as its parent.
+// Use it with gitdiff.Am or a gitdiff.AmSession and update a reference to
+// Head after the session.
+type CommitTarget struct {
+	// Storer contains the objects of Head and stores the new objects
+	Storer storer.EncodedObjectStorer
+
+	// Head is the commit that the next patch applies to, or nil to apply the
+	// first patch to an empty tree. ApplyPatch replaces Head with the new
+	// commit after each patch.
+	Head *object.Commit
+
+	// Committer is the committer of new commits. If the name is empty, new
+	// commits use the author of the patch as the committer.
+	Committer object.Signature
+}
+
+// ApplyPatch applies the files of p to the tree of Head and stores a commit
+// with the result. It returns an error if the patch header has no author,
+// like "git am".
+func (t *CommitTarget) ApplyPatch(p *gitdiff.Patch, opts ...gitdiff.ApplierOption) error {
+	h := p.Header
+	if h == nil || h.Author == nil || h.Author.Email == "" {
+		return errors.New("patch does not have a valid e-mail address")
+	}
+
+	var tree *object.Tree
+	var parents []plumbing.Hash
+	if t.Head != nil {
+		var err error
+		if tree, err = t.Head.Tree(); err != nil {
+			return err
+		}
+		parents = []plumbing.Hash{t.Head.Hash}
+	}
+
+	result, err := ApplyTree(t.Storer, tree, p.Files, opts...)
+	if err != nil {
+		return err
+	}
+
+	author := object.Signature{Name: h.Author.Name, Email: h.Author.Email, When: h.AuthorDate}
+	committer := t.Committer
+	if committer.Name == "" {
+		committer = author
+	}
+
+	commit := &object.Commit{
+		Author:       author,
+		Committer:    committer,
+		Message:      h.Message() + "\n",
+		TreeHash:     result.Hash,
+		ParentHashes: parents,
+	}
+	obj := t.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return err
+	}
+	hash, err := t.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+
+	head, err := object.GetCommit(t.Storer, hash)
+	if err != nil {
+		return err
+	}
+	t.Head = head
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package gogit applies patches parsed by the gitdiff package to go-git
+// objects: trees in an object store and worktrees in a billy file system.
+//
+// The functions in this package apply patches with gitdiff.ApplyFS, so they
+// handle files, renames, copies, modes, symbolic links, and options in the
+// same way, including the checks for files beyond a symbolic link. Unlike
+// gitdiff.ApplyFS, they always verify the abbreviated blob IDs from the
+// "index" line of each file with gitdiff.WithOIDCheck before applying
+// changes, so a patch only applies to the exact content it was generated
+// from. The check uses the hash algorithm of each file and also applies when
+// applying in reverse with gitdiff.WithReverse, where the source must match
+// the new blob ID. A file that does not match returns a gitdiff.Conflict.
+package gogit
+
+import (
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+)
+
+// applyOptions returns opts with the option that verifies blob IDs.
+func applyOptions(opts []gitdiff.ApplierOption) []gitdiff.ApplierOption {
+	return append(opts[:len(opts):len(opts)], gitdiff.WithOIDCheck())
+}
+
+// entryMode converts the mode of a tree entry to the mode of a file. Modes
+// that are not files, symbolic links, or directories are irregular.
+func entryMode(m filemode.FileMode) fs.FileMode {
+	switch m {
+	case filemode.Regular, filemode.Deprecated:
+		return 0644
+	case filemode.Executable:
+		return 0755
+	case filemode.Symlink:
+		return fs.ModeSymlink | 0777
+	case filemode.Dir:
+		return fs.ModeDir | 0755
+	}
+	return fs.ModeIrregular
+}
+
+// permMode converts file permissions to the mode of a tree entry.
+func permMode(perm fs.FileMode) filemode.FileMode {
+	if perm&0111 != 0 {
+		return filemode.Executable
+	}
+	return filemode.Regular
+}
+
+// fileInfo describes a file for gitdiff.ApplyFS.
+type fileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi fileInfo) Sys() interface{}   { return nil }
+
+// pathError returns an error for an operation on a file that does not exist.
+func pathError(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
@@ -0,0 +1,419 @@
+package gogit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// original is the content of the files before applying testdata/apply.patch.
+var original = map[string]string{
+	"mod.txt":      "a\nb\nc\n",
+	"del.txt":      "gone\n",
+	"old.txt":      "move\nme\n",
+	"run.sh":       "#!/bin/sh\n",
+	"dir/keep.txt": "keep\n",
+}
+
+// resultTree is the ID of the tree after applying testdata/apply.patch, as
+// computed by Git.
+const resultTree = "033d1f699a26cefc4d128d821d871011f814f015"
+
+func TestApplyTree(t *testing.T) {
+	tests := map[string]struct {
+		Files  map[string]string
+		Result string
+		Err    interface{}
+	}{
+		"apply": {
+			Files:  original,
+			Result: resultTree,
+		},
+		"errorOIDMismatch": {
+			Files: withFile(original, "mod.txt", "a\nb\nc\nd\n"),
+			Err:   &gitdiff.Conflict{},
+		},
+		"errorCreateExists": {
+			Files: withFile(original, "link", "other"),
+			Err:   "file already exists",
+		},
+		"errorDeleteMissing": {
+			Files: withFile(original, "del.txt", ""),
+			Err:   "file does not exist",
+		},
+	}
+
+	files := parsePatch(t)
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := memory.NewStorage()
+			tree := newTree(t, s, test.Files)
+
+			result, err := ApplyTree(s, tree, files)
+			if test.Err != nil {
+				assertError(t, test.Err, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error applying patch: %v", err)
+			}
+			if result.Hash.String() != test.Result {
+				t.Errorf("incorrect tree: expected %s, actual %s", test.Result, result.Hash)
+			}
+
+			f, err := result.File("new.txt")
+			if err != nil {
+				t.Fatalf("unexpected error reading new.txt: %v", err)
+			}
+			if content, _ := f.Contents(); content != "move\nme\ntoo\n" {
+				t.Errorf("incorrect content for new.txt: %q", content)
+			}
+		})
+	}
+}
+
+func TestApplyTreeEmpty(t *testing.T) {
+	const patch = `diff --git a/a/b.txt b/a/b.txt
+new file mode 100644
+index 0000000..ce01362
+--- /dev/null
++++ b/a/b.txt
+@@ -0,0 +1 @@
++hello
+`
+	files, _, err := gitdiff.ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	s := memory.NewStorage()
+	result, err := ApplyTree(s, nil, files)
+	if err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+
+	e, err := result.FindEntry("a/b.txt")
+	if err != nil {
+		t.Fatalf("unexpected error finding entry: %v", err)
+	}
+	if e.Mode != filemode.Regular || e.Hash.String() != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("incorrect entry: %o %s", e.Mode, e.Hash)
+	}
+}
+
+func TestApplyTreeReverse(t *testing.T) {
+	s := memory.NewStorage()
+	tree := newTree(t, s, original)
+
+	files := parsePatch(t)
+	result, err := ApplyTree(s, tree, files)
+	if err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+
+	reversed, err := ApplyTree(s, result, files, gitdiff.WithReverse())
+	if err != nil {
+		t.Fatalf("unexpected error applying patch in reverse: %v", err)
+	}
+	if reversed.Hash != tree.Hash {
+		t.Errorf("incorrect tree: expected %s, actual %s", tree.Hash, reversed.Hash)
+	}
+
+	// the source must match the new blob IDs when applying in reverse
+	_, err = ApplyTree(s, tree, files[3:4], gitdiff.WithReverse())
+	assertError(t, &gitdiff.Conflict{}, err)
+}
+
+func TestApplyTreeSHA256(t *testing.T) {
+	const patch = `diff --git a/mod.txt b/mod.txt
+index f31f5bf8254ac193075271029d1de6a470884e47fa3a870c9e4733e03fd302cc..635d2b0e5272a873d414e056d344be93cc3297ca90fd7887d8aacca58d2efd7a 100644
+--- a/mod.txt
++++ b/mod.txt
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+`
+	files, _, err := gitdiff.ParseAll(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	s := memory.NewStorage()
+	result, err := ApplyTree(s, newTree(t, s, map[string]string{"mod.txt": "a\nb\nc\n"}), files)
+	if err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+	f, err := result.File("mod.txt")
+	if err != nil {
+		t.Fatalf("unexpected error reading mod.txt: %v", err)
+	}
+	if content, _ := f.Contents(); content != "a\nB\nc\n" {
+		t.Errorf("incorrect content for mod.txt: %q", content)
+	}
+
+	_, err = ApplyTree(s, newTree(t, s, map[string]string{"mod.txt": "a\nb\nc\nd\n"}), files)
+	assertError(t, &gitdiff.Conflict{}, err)
+}
+
+func TestApplyWorktree(t *testing.T) {
+	fs := memfs.New()
+	for name, content := range original {
+		if err := util.WriteFile(fs, name, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error writing file: %v", err)
+		}
+	}
+
+	if err := ApplyWorktree(fs, parsePatch(t)); err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"mod.txt":             "a\nB\nc\n",
+		"new.txt":             "move\nme\ntoo\n",
+		"run.sh":              "#!/bin/sh\n",
+		"dir/keep.txt":        "keep\n",
+		"dir/sub/created.txt": "hello\n",
+	} {
+		data, err := util.ReadFile(fs, name)
+		if err != nil {
+			t.Errorf("failed to read %s: %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("incorrect content for %s\nexpected: %q\n  actual: %q", name, content, data)
+		}
+	}
+	for _, name := range []string{"del.txt", "old.txt"} {
+		if _, err := fs.Lstat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %s to be removed, but got %v", name, err)
+		}
+	}
+	if target, err := fs.Readlink("link"); err != nil || target != "mod.txt" {
+		t.Errorf("incorrect symlink: %q, %v", target, err)
+	}
+	if info, err := fs.Stat("run.sh"); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected run.sh to be executable, but got %v, %v", info.Mode(), err)
+	}
+}
+
+func TestApplyWorktreeOIDMismatch(t *testing.T) {
+	fs := memfs.New()
+	for name, content := range withFile(original, "old.txt", "moved\nme\n") {
+		if err := util.WriteFile(fs, name, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error writing file: %v", err)
+		}
+	}
+
+	err := ApplyWorktree(fs, parsePatch(t))
+	assertError(t, &gitdiff.Conflict{}, err)
+
+	if _, err := fs.Lstat("del.txt"); err != nil {
+		t.Errorf("expected del.txt to exist after failed apply, but got %v", err)
+	}
+}
+
+func TestApplyWorktreeUnsafePaths(t *testing.T) {
+	tests := map[string]struct {
+		Patch string
+		Err   string
+	}{
+		"beyondSymlink": {
+			Patch: `diff --git a/link/file.txt b/link/file.txt
+new file mode 100644
+index 0000000..ce01362
+--- /dev/null
++++ b/link/file.txt
+@@ -0,0 +1 @@
++hello
+`,
+			Err: "beyond a symbolic link",
+		},
+		"parentDirectory": {
+			Patch: `diff --git a/../file.txt b/../file.txt
+new file mode 100644
+index 0000000..ce01362
+--- /dev/null
++++ b/../file.txt
+@@ -0,0 +1 @@
++hello
+`,
+			Err: "invalid file name",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fs := memfs.New()
+			if err := fs.MkdirAll("dir", 0777); err != nil {
+				t.Fatalf("unexpected error creating directory: %v", err)
+			}
+			if err := fs.Symlink("dir", "link"); err != nil {
+				t.Fatalf("unexpected error creating symlink: %v", err)
+			}
+
+			files, _, err := gitdiff.ParseAll(strings.NewReader(test.Patch))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			err = ApplyWorktree(fs, files)
+			assertError(t, test.Err, err)
+
+			if _, err := fs.Lstat("dir/file.txt"); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("expected dir/file.txt to not exist, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestCommitTarget(t *testing.T) {
+	s := memory.NewStorage()
+	base := &object.Commit{
+		Author:    object.Signature{Name: "Base", Email: "base@example.com", When: time.Unix(0, 0)},
+		Committer: object.Signature{Name: "Base", Email: "base@example.com", When: time.Unix(0, 0)},
+		Message:   "base\n",
+		TreeHash:  newTree(t, s, map[string]string{"file.txt": "one\ntwo\nthree\n"}).Hash,
+	}
+	obj := s.NewEncodedObject()
+	if err := base.Encode(obj); err != nil {
+		t.Fatalf("unexpected error encoding commit: %v", err)
+	}
+	hash, err := s.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatalf("unexpected error storing commit: %v", err)
+	}
+	head, err := object.GetCommit(s, hash)
+	if err != nil {
+		t.Fatalf("unexpected error reading commit: %v", err)
+	}
+
+	mbox, err := os.Open(filepath.Join("testdata", "mailbox.patch"))
+	if err != nil {
+		t.Fatalf("failed to open mailbox: %v", err)
+	}
+	defer mbox.Close()
+
+	committer := object.Signature{Name: "Committer", Email: "committer@example.com", When: time.Unix(1700000000, 0)}
+	target := &CommitTarget{Storer: s, Head: head, Committer: committer}
+
+	session, err := gitdiff.Am(mbox, target)
+	if !errors.Is(err, gitdiff.ErrEmptyPatch) {
+		t.Fatalf("expected empty patch error for the cover letter, but got %v", err)
+	}
+	if target.Head != head {
+		t.Fatalf("expected head to be unchanged after the cover letter")
+	}
+	if err := session.Skip(); err != nil {
+		t.Fatalf("unexpected error skipping cover letter: %v", err)
+	}
+	if err := session.Continue(target); err != nil {
+		t.Fatalf("unexpected error applying patches: %v", err)
+	}
+
+	// the tree after applying the patches with "git am"
+	const expectedTree = "3aaf9520da5407fe37e2187bd775e8ef20d1beca"
+	if target.Head.TreeHash.String() != expectedTree {
+		t.Errorf("incorrect tree: expected %s, actual %s", expectedTree, target.Head.TreeHash)
+	}
+
+	var messages []string
+	for c := target.Head; c.Hash != head.Hash; {
+		if c.Author.Name != "Morton Haypenny" || c.Author.Email != "dev@example.com" || c.Committer.Name != committer.Name {
+			t.Errorf("incorrect signatures for commit %s: %v, %v", c.Hash, c.Author, c.Committer)
+		}
+		messages = append(messages, c.Message)
+		if c, err = c.Parent(0); err != nil {
+			t.Fatalf("unexpected error reading parent: %v", err)
+		}
+	}
+	expected := []string{
+		"Add a new file and change the third line\n",
+		"Change the second line\n\nThis replaces the word with a number.\nFrom now on, numbers are preferred.\n",
+	}
+	if !reflect.DeepEqual(expected, messages) {
+		t.Errorf("incorrect commit messages\nexpected: %q\n  actual: %q", expected, messages)
+	}
+	if when := target.Head.Author.When; !when.Equal(time.Date(2020, 4, 13, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("incorrect author date: %v", when)
+	}
+}
+
+func parsePatch(t *testing.T) []*gitdiff.File {
+	patch, err := os.Open(filepath.Join("testdata", "apply.patch"))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer patch.Close()
+
+	files, _, err := gitdiff.ParseAll(patch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+	return files
+}
+
+// newTree stores a tree with files in s. Files with empty content are omitted.
+func newTree(t *testing.T, s *memory.Storage, files map[string]string) *object.Tree {
+	entries := make(map[string]treeEntry)
+	for name, content := range files {
+		if content == "" {
+			continue
+		}
+		hash, err := writeObject(s, plumbing.BlobObject, []byte(content))
+		if err != nil {
+			t.Fatalf("unexpected error writing blob: %v", err)
+		}
+		entries[name] = treeEntry{mode: filemode.Regular, hash: hash}
+	}
+
+	hash, err := writeTree(s, entries)
+	if err != nil {
+		t.Fatalf("unexpected error writing tree: %v", err)
+	}
+	tree, err := object.GetTree(s, hash)
+	if err != nil {
+		t.Fatalf("unexpected error reading tree: %v", err)
+	}
+	return tree
+}
+
+func withFile(files map[string]string, name, content string) map[string]string {
+	c := make(map[string]string, len(files)+1)
+	for k, v := range files {
+		c[k] = v
+	}
+	c[name] = content
+	return c
+}
+
+func assertError(t *testing.T, expected interface{}, actual error) {
+	t.Helper()
+	if actual == nil {
+		t.Fatalf("expected error, but got nil")
+	}
+
+	switch exp := expected.(type) {
+	case string:
+		if !strings.Contains(actual.Error(), exp) {
+			t.Errorf("incorrect error: %q does not contain %q", actual.Error(), exp)
+		}
+	case error:
+		if !errors.Is(actual, exp) {
+			t.Errorf("incorrect error: expected %T, actual %v", exp, actual)
+		}
+	}
+}
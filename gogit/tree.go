@@ -0,0 +1,299 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// ApplyTree applies the changes in files to tree, like "git apply --cached"
+// with an index containing the tree, and returns the resulting tree. It stores
+// new blobs and trees in s, which must contain all objects of tree. A nil tree
+// is an empty tree. Files in the patch are applied in order, so a file may
+// depend on the changes made by earlier files. Only the blobs of files named
+// in the patch are read from s. Entries that are not files or symbolic links,
+// like submodules, can not be changed by the patch.
+func ApplyTree(s storer.EncodedObjectStorer, tree *object.Tree, files []*gitdiff.File, opts ...gitdiff.ApplierOption) (*object.Tree, error) {
+	t := &treeFS{s: s, entries: make(map[string]treeEntry)}
+	if tree != nil {
+		w := object.NewTreeWalker(tree, true, nil)
+		defer w.Close()
+
+		for {
+			name, e, err := w.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if e.Mode != filemode.Dir {
+				t.entries[name] = treeEntry{mode: e.Mode, hash: e.Hash}
+			}
+		}
+	}
+
+	if err := gitdiff.ApplyFS(t, files, applyOptions(opts)...); err != nil {
+		return nil, err
+	}
+
+	hash, err := writeTree(s, t.entries)
+	if err != nil {
+		return nil, err
+	}
+	return object.GetTree(s, hash)
+}
+
+// treeFS is a gitdiff.SymlinkFS and gitdiff.ChmodFS for the entries of a
+// tree. Writes store new blobs and update the entries. Directories are the
+// leading directories of the entries. Symbolic links are entries like any
+// other, so Stat does not follow them.
+type treeFS struct {
+	s       storer.EncodedObjectStorer
+	entries map[string]treeEntry
+	dirs    map[string]bool
+}
+
+func (t *treeFS) Open(name string) (fs.File, error) {
+	info, err := t.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if !info.IsDir() {
+		if data, err = t.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+	return &memFile{Reader: bytes.NewReader(data), info: info.(fileInfo)}, nil
+}
+
+func (t *treeFS) Stat(name string) (fs.FileInfo, error) {
+	return t.Lstat(name)
+}
+
+func (t *treeFS) Lstat(name string) (fs.FileInfo, error) {
+	if e, ok := t.entries[name]; ok {
+		return fileInfo{name: path.Base(name), mode: entryMode(e.mode)}, nil
+	}
+	if name == "." || t.isDir(name) {
+		return fileInfo{name: path.Base(name), mode: entryMode(filemode.Dir)}, nil
+	}
+	return nil, pathError("lstat", name)
+}
+
+func (t *treeFS) ReadFile(name string) ([]byte, error) {
+	e, ok := t.entries[name]
+	if !ok {
+		return nil, pathError("open", name)
+	}
+
+	blob, err := object.GetBlob(t.s, e.hash)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return data, nil
+}
+
+func (t *treeFS) ReadLink(name string) (string, error) {
+	data, err := t.ReadFile(name)
+	return string(data), err
+}
+
+func (t *treeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." && !t.isDir(name) {
+		return nil, pathError("readdir", name)
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	children := make(map[string]fs.FileInfo)
+	for entry, e := range t.entries {
+		if !strings.HasPrefix(entry, prefix) {
+			continue
+		}
+		child := entry[len(prefix):]
+		if i := strings.IndexByte(child, '/'); i >= 0 {
+			children[child[:i]] = fileInfo{name: child[:i], mode: entryMode(filemode.Dir)}
+		} else {
+			children[child] = fileInfo{name: child, mode: entryMode(e.mode)}
+		}
+	}
+
+	dirEntries := make([]fs.DirEntry, 0, len(children))
+	for _, info := range children {
+		dirEntries = append(dirEntries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(dirEntries, func(i, j int) bool { return dirEntries[i].Name() < dirEntries[j].Name() })
+	return dirEntries, nil
+}
+
+func (t *treeFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return t.write(name, data, permMode(perm))
+}
+
+func (t *treeFS) Symlink(target, name string) error {
+	return t.write(name, []byte(target), filemode.Symlink)
+}
+
+func (t *treeFS) Chmod(name string, perm fs.FileMode) error {
+	e, ok := t.entries[name]
+	if !ok {
+		return pathError("chmod", name)
+	}
+	t.entries[name] = treeEntry{mode: permMode(perm), hash: e.hash}
+	return nil
+}
+
+func (t *treeFS) Remove(name string) error {
+	if _, ok := t.entries[name]; ok {
+		delete(t.entries, name)
+		t.dirs = nil
+		return nil
+	}
+	if t.isDir(name) {
+		return fmt.Errorf("%s: directory is not empty", name)
+	}
+	return pathError("remove", name)
+}
+
+func (t *treeFS) write(name string, data []byte, mode filemode.FileMode) error {
+	hash, err := writeObject(t.s, plumbing.BlobObject, data)
+	if err != nil {
+		return err
+	}
+	t.entries[name] = treeEntry{mode: mode, hash: hash}
+	t.dirs = nil
+	return nil
+}
+
+// isDir returns true if name is a leading directory of an entry.
+func (t *treeFS) isDir(name string) bool {
+	if t.dirs == nil {
+		t.dirs = make(map[string]bool)
+		for entry := range t.entries {
+			for i := 0; i < len(entry); i++ {
+				if entry[i] == '/' {
+					t.dirs[entry[:i]] = true
+				}
+			}
+		}
+	}
+	return t.dirs[name]
+}
+
+type memFile struct {
+	*bytes.Reader
+	info fileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+// treeEntry is a non-directory entry in a tree.
+type treeEntry struct {
+	mode filemode.FileMode
+	hash plumbing.Hash
+}
+
+// treeDir is a directory in a tree that is not yet written.
+type treeDir struct {
+	entries map[string]treeEntry
+	dirs    map[string]*treeDir
+}
+
+// writeTree writes the trees that contain entries, which are keyed by full
+// path, and returns the ID of the root tree.
+func writeTree(s storer.EncodedObjectStorer, entries map[string]treeEntry) (plumbing.Hash, error) {
+	root := &treeDir{}
+	for name, e := range entries {
+		parts := strings.Split(name, "/")
+
+		d := root
+		for _, part := range parts[:len(parts)-1] {
+			if _, ok := d.entries[part]; ok {
+				return plumbing.ZeroHash, fmt.Errorf("%s: path conflicts with a file", name)
+			}
+			if d.dirs == nil {
+				d.dirs = make(map[string]*treeDir)
+			}
+			if d.dirs[part] == nil {
+				d.dirs[part] = &treeDir{}
+			}
+			d = d.dirs[part]
+		}
+
+		base := parts[len(parts)-1]
+		if _, ok := d.dirs[base]; ok {
+			return plumbing.ZeroHash, fmt.Errorf("%s: path conflicts with a directory", name)
+		}
+		if d.entries == nil {
+			d.entries = make(map[string]treeEntry)
+		}
+		d.entries[base] = e
+	}
+	return root.write(s)
+}
+
+func (d *treeDir) write(s storer.EncodedObjectStorer) (plumbing.Hash, error) {
+	var t object.Tree
+	for name, e := range d.entries {
+		t.Entries = append(t.Entries, object.TreeEntry{Name: name, Mode: e.mode, Hash: e.hash})
+	}
+	for name, sub := range d.dirs {
+		hash, err := sub.write(s)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		t.Entries = append(t.Entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+	sort.Sort(object.TreeEntrySorter(t.Entries))
+
+	obj := s.NewEncodedObject()
+	if err := t.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// writeObject stores an object with type t and content data in s.
+func writeObject(s storer.EncodedObjectStorer, t plumbing.ObjectType, data []byte) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(t)
+	obj.SetSize(int64(len(data)))
+
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
@@ -0,0 +1,124 @@
+package gogit
+
+import (
+	"bytes"
+	iofs "io/fs"
+	"path"
+	"sort"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// ApplyWorktree applies the changes in files to the files in fs, like running
+// "git apply" in a worktree. Use the Filesystem of a go-git Worktree to apply
+// a patch to a repository checkout. Files in the patch are applied in order,
+// so a file may depend on the changes made by earlier files. If fs does not
+// implement billy.Change, ApplyWorktree replaces modified files to set their
+// permissions.
+//
+// Like gitdiff.ApplyFS, ApplyWorktree computes the result of all files before
+// modifying fs. If any file does not apply, ApplyWorktree returns an error and
+// does not modify fs. If writing the result fails, ApplyWorktree attempts to
+// restore the original content of all files it wrote before returning the
+// error. It returns an error for files beyond a symbolic link unless
+// gitdiff.WithUnsafePaths is set, and names that are not valid paths for
+// fs.FS, like absolute paths and paths containing "..", are always errors.
+func ApplyWorktree(fs billy.Filesystem, files []*gitdiff.File, opts ...gitdiff.ApplierOption) error {
+	return gitdiff.ApplyFS(worktreeFS{fs: fs}, files, applyOptions(opts)...)
+}
+
+// worktreeFS is a gitdiff.SymlinkFS and gitdiff.ChmodFS for a billy file
+// system.
+type worktreeFS struct {
+	fs billy.Filesystem
+}
+
+func (w worktreeFS) Open(name string) (iofs.File, error) {
+	info, err := w.fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if !info.IsDir() {
+		if data, err = util.ReadFile(w.fs, name); err != nil {
+			return nil, err
+		}
+	}
+	return &memFile{Reader: bytes.NewReader(data), info: fileInfo{name: info.Name(), size: info.Size(), mode: info.Mode()}}, nil
+}
+
+func (w worktreeFS) Stat(name string) (iofs.FileInfo, error) {
+	return w.fs.Stat(name)
+}
+
+func (w worktreeFS) Lstat(name string) (iofs.FileInfo, error) {
+	return w.fs.Lstat(name)
+}
+
+func (w worktreeFS) ReadFile(name string) ([]byte, error) {
+	return util.ReadFile(w.fs, name)
+}
+
+func (w worktreeFS) ReadLink(name string) (string, error) {
+	return w.fs.Readlink(name)
+}
+
+func (w worktreeFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	infos, err := w.fs.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]iofs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = iofs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (w worktreeFS) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	if err := w.fs.MkdirAll(path.Dir(name), 0777); err != nil {
+		return err
+	}
+
+	// writing an existing file does not change its permissions, so replace
+	// the file if they can't be changed directly
+	ch, canChmod := w.fs.(billy.Change)
+	if _, err := w.fs.Lstat(name); err == nil && !canChmod {
+		if err := w.fs.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	if err := util.WriteFile(w.fs, name, data, perm); err != nil {
+		return err
+	}
+	if canChmod {
+		return ch.Chmod(name, perm)
+	}
+	return nil
+}
+
+func (w worktreeFS) Symlink(target, name string) error {
+	if err := w.fs.MkdirAll(path.Dir(name), 0777); err != nil {
+		return err
+	}
+	return w.fs.Symlink(target, name)
+}
+
+func (w worktreeFS) Chmod(name string, perm iofs.FileMode) error {
+	if ch, ok := w.fs.(billy.Change); ok {
+		return ch.Chmod(name, perm)
+	}
+	data, err := util.ReadFile(w.fs, name)
+	if err != nil {
+		return err
+	}
+	return w.WriteFile(name, data, perm)
+}
+
+func (w worktreeFS) Remove(name string) error {
+	return w.fs.Remove(name)
+}
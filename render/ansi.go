@@ -0,0 +1,173 @@
+package render
+
+import (
+	"io"
+	"strings"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+)
+
+// Reset is the ANSI escape sequence that clears all colors and attributes.
+const Reset = "\x1b[m"
+
+// Theme is the set of ANSI escape sequences that ANSI uses to color each part
+// of a file. An empty sequence leaves the part uncolored.
+type Theme struct {
+	// Meta colors the lines of file headers, like "diff --git" and "index"
+	Meta string
+	// Frag colors the line information of fragment headers
+	Frag string
+	// Func colors the comment after the line information of fragment headers
+	Func string
+	// Context colors context lines and "\ No newline at end of file" markers
+	Context string
+	// Old colors deleted lines
+	Old string
+	// New colors added lines
+	New string
+	// Whitespace colors trailing whitespace at the end of added lines
+	Whitespace string
+}
+
+// DefaultTheme uses the same colors as "git diff" with the default values of
+// the color.diff.* configuration options.
+var DefaultTheme = Theme{
+	Meta:       "\x1b[1m",
+	Frag:       "\x1b[36m",
+	Old:        "\x1b[31m",
+	New:        "\x1b[32m",
+	Whitespace: "\x1b[41m",
+}
+
+// ANSIOption configures the output of ANSI.
+type ANSIOption func(*ansiRenderer)
+
+// WithTheme configures ANSI to use the colors from theme instead of the
+// colors from DefaultTheme.
+func WithTheme(theme Theme) ANSIOption {
+	return func(r *ansiRenderer) {
+		r.theme = theme
+	}
+}
+
+// ANSI writes files to w in the same format as the String method of File,
+// with ANSI escape sequences coloring each line for display in a terminal.
+// Like Git, it highlights trailing whitespace at the end of added lines as a
+// whitespace error. It returns the first error from loading the fragments of
+// a file or writing to w.
+func ANSI(w io.Writer, files []*gitdiff.File, opts ...ANSIOption) error {
+	r := &ansiRenderer{writer: writer{w: w}, theme: DefaultTheme}
+	for _, opt := range opts {
+		opt(r)
+	}
+	for _, f := range files {
+		r.file(f)
+	}
+	return r.err
+}
+
+type ansiRenderer struct {
+	writer
+
+	theme Theme
+}
+
+type ansiSection int
+
+const (
+	sectionHeader ansiSection = iota
+	sectionFragment
+	sectionBinary
+)
+
+func (r *ansiRenderer) file(f *gitdiff.File) {
+	if r.err != nil {
+		return
+	}
+	var b strings.Builder
+	if _, err := gitdiff.Format(&b, f); err != nil {
+		r.err = err
+		return
+	}
+
+	section := sectionHeader
+	parents := 0
+
+	s := b.String()
+	for len(s) > 0 {
+		line := s
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			line, s = s[:i], s[i+1:]
+		} else {
+			s = ""
+		}
+
+		switch {
+		case section != sectionBinary && strings.HasPrefix(line, "@@"):
+			section = sectionFragment
+			parents = r.fragmentHeader(line)
+		case section == sectionHeader && (strings.HasPrefix(line, "GIT binary patch") || strings.HasPrefix(line, "Binary files ")):
+			section = sectionBinary
+			r.write(line)
+		case section == sectionHeader:
+			r.color(r.theme.Meta, line)
+		case section == sectionFragment:
+			r.line(line, parents)
+		default:
+			r.write(line)
+		}
+		r.write("\n")
+	}
+}
+
+// fragmentHeader writes the header of a text or combined fragment and returns
+// the number of operation characters at the start of each line.
+func (r *ansiRenderer) fragmentHeader(line string) int {
+	marker := line[:len(line)-len(strings.TrimLeft(line, "@"))]
+
+	end := strings.Index(line[len(marker):], marker)
+	if end < 0 {
+		r.color(r.theme.Frag, line)
+		return len(marker) - 1
+	}
+	end += 2 * len(marker)
+
+	r.color(r.theme.Frag, line[:end])
+	if comment := strings.TrimPrefix(line[end:], " "); comment != "" {
+		r.write(" ")
+		r.color(r.theme.Func, comment)
+	}
+	return len(marker) - 1
+}
+
+func (r *ansiRenderer) line(line string, parents int) {
+	if strings.HasPrefix(line, "\\") || len(line) < parents {
+		r.color(r.theme.Context, line)
+		return
+	}
+
+	ops := line[:parents]
+	switch {
+	case strings.ContainsRune(ops, '-'):
+		r.color(r.theme.Old, line)
+	case strings.ContainsRune(ops, '+'):
+		content := strings.TrimRight(line[parents:], " \t\r")
+		r.color(r.theme.New, ops)
+		r.color(r.theme.New, content)
+		r.color(r.theme.Whitespace, line[parents+len(content):])
+	default:
+		r.color(r.theme.Context, line)
+	}
+}
+
+// color writes s surrounded by the escape sequence c and Reset. It writes s
+// without escape sequences if c is empty and writes nothing if s is empty.
+func (r *ansiRenderer) color(c, s string) {
+	switch {
+	case s == "":
+	case c == "":
+		r.write(s)
+	default:
+		r.write(c, s, Reset)
+	}
+}
@@ -0,0 +1,74 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+)
+
+func TestANSI(t *testing.T) {
+	tests := map[string]struct {
+		ParseOptions []gitdiff.ParseOption
+		Options      []ANSIOption
+		Golden       string
+	}{
+		"defaultTheme": {
+			Golden: "ansi_default.out",
+		},
+		"customTheme": {
+			Options: []ANSIOption{WithTheme(Theme{
+				Meta:       "<meta>",
+				Frag:       "<frag>",
+				Func:       "<func>",
+				Context:    "<context>",
+				Old:        "<old>",
+				New:        "<new>",
+				Whitespace: "<ws>",
+			})},
+			Golden: "ansi_custom.out",
+		},
+		"noWhitespace": {
+			Options: []ANSIOption{WithTheme(Theme{
+				Old: "<old>",
+				New: "<new>",
+			})},
+			Golden: "ansi_no_whitespace.out",
+		},
+		"lazyFragments": {
+			ParseOptions: []gitdiff.ParseOption{gitdiff.WithLazyFragments()},
+			Golden:       "ansi_default.out",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files := parseFiles(t, "ansi.patch", test.ParseOptions...)
+
+			var b bytes.Buffer
+			if err := ANSI(&b, files, test.Options...); err != nil {
+				t.Fatalf("unexpected error rendering ANSI: %v", err)
+			}
+
+			expected, err := os.ReadFile(filepath.Join("testdata", test.Golden))
+			if err != nil {
+				t.Fatalf("failed to read golden file: %v", err)
+			}
+			if !bytes.Equal(expected, b.Bytes()) {
+				t.Errorf("incorrect ANSI output\nexpected:\n%q\nactual:\n%q", expected, b.String())
+			}
+		})
+	}
+}
+
+func TestANSIWriteError(t *testing.T) {
+	files := parseFiles(t, "ansi.patch")
+
+	err := ANSI(errorWriter{}, files)
+	if !errors.Is(err, errWrite) {
+		t.Fatalf("expected write error, but got: %v", err)
+	}
+}
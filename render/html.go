@@ -0,0 +1,284 @@
+// Package render writes files parsed by the gitdiff package in formats for
+// display, like HTML for web pages.
+package render
+
+import (
+	"html"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+)
+
+// Layout is the arrangement of the old and new content of a file.
+type Layout int
+
+const (
+	// Unified shows the old and new content in a single column, like the
+	// output of "git diff"
+	Unified Layout = iota
+	// SideBySide shows the old content in one column and the new content in
+	// another column, with deleted and added lines in the same rows
+	SideBySide
+)
+
+// HTMLOption configures the output of HTML.
+type HTMLOption func(*htmlRenderer)
+
+// WithLayout configures HTML to use the layout. The default is Unified.
+func WithLayout(layout Layout) HTMLOption {
+	return func(r *htmlRenderer) {
+		r.layout = layout
+	}
+}
+
+// WithClassPrefix configures HTML to add prefix to the start of all CSS class
+// names instead of the default prefix "diff-".
+func WithClassPrefix(prefix string) HTMLOption {
+	return func(r *htmlRenderer) {
+		r.prefix = prefix
+	}
+}
+
+// HTML writes files to w as HTML. Each file is a <div> with a header and a
+// <table> containing the lines of its fragments. Elements have CSS classes
+// for styling, which by default start with "diff-":
+//
+//	diff-file           the container for a file
+//	diff-file-header    the header with the names of a file
+//	diff-new            added to the container of a new file
+//	diff-deleted        added to the container of a deleted file
+//	diff-renamed        added to the container of a renamed or copied file
+//	diff-unified        the table of a file in the Unified layout
+//	diff-side-by-side   the table of a file in the SideBySide layout
+//	diff-hunk-header    the row with a fragment header
+//	diff-context        a context line
+//	diff-add            an added line
+//	diff-delete         a deleted line
+//	diff-empty          a side of a row with no line in the SideBySide layout
+//	diff-no-newline     a marker for a line without a trailing newline
+//	diff-line-number    a cell with a line number
+//	diff-code           a cell with the content of a line
+//	diff-binary         the row for a binary file
+//
+// HTML escapes all names and content from the files. It returns the first
+// error from loading the fragments of a file or writing to w.
+func HTML(w io.Writer, files []*gitdiff.File, opts ...HTMLOption) error {
+	r := &htmlRenderer{writer: writer{w: w}, prefix: "diff-"}
+	for _, opt := range opts {
+		opt(r)
+	}
+	for _, f := range files {
+		r.file(f)
+	}
+	return r.err
+}
+
+type htmlRenderer struct {
+	writer
+
+	layout Layout
+	prefix string
+}
+
+// class returns the value of a class attribute with the prefixed names.
+func (r *htmlRenderer) class(names ...string) string {
+	for i, name := range names {
+		names[i] = r.prefix + name
+	}
+	return `class="` + html.EscapeString(strings.Join(names, " ")) + `"`
+}
+
+func (r *htmlRenderer) file(f *gitdiff.File) {
+	if r.err != nil {
+		return
+	}
+	loaded := *f
+	if err := loaded.LoadFragments(); err != nil {
+		r.err = err
+		return
+	}
+	f = &loaded
+
+	classes := []string{"file"}
+	switch {
+	case f.IsNew:
+		classes = append(classes, "new")
+	case f.IsDelete:
+		classes = append(classes, "deleted")
+	case f.IsRename || f.IsCopy:
+		classes = append(classes, "renamed")
+	}
+
+	r.write("<div ", r.class(classes...), ">\n")
+	r.write("<div ", r.class("file-header"), ">", html.EscapeString(fileName(f)), "</div>\n")
+
+	layout := "unified"
+	if r.layout == SideBySide {
+		layout = "side-by-side"
+	}
+	r.write("<table ", r.class(layout), ">\n")
+
+	if f.IsBinary {
+		r.write("<tr ", r.class("binary"), `><td colspan="`, strconv.Itoa(r.columns()), `">Binary file</td></tr>`, "\n")
+	}
+	for _, frag := range f.TextFragments {
+		r.fragment(frag)
+	}
+
+	r.write("</table>\n</div>\n")
+}
+
+func (r *htmlRenderer) columns() int {
+	if r.layout == SideBySide {
+		return 4
+	}
+	return 3
+}
+
+func (r *htmlRenderer) fragment(frag *gitdiff.TextFragment) {
+	r.write("<tr ", r.class("hunk-header"), `><td colspan="`, strconv.Itoa(r.columns()), `">`)
+	r.write(html.EscapeString(fragmentHeader(frag)), "</td></tr>\n")
+
+	if r.layout == SideBySide {
+		r.sideBySide(frag)
+		return
+	}
+
+	oldLine, newLine := frag.OldPosition, frag.NewPosition
+	for _, line := range frag.Lines {
+		var oldNum, newNum int64
+		if line.Old() {
+			oldNum, oldLine = oldLine, oldLine+1
+		}
+		if line.New() {
+			newNum, newLine = newLine, newLine+1
+		}
+
+		r.write("<tr ", r.class(opClass(line.Op)), ">")
+		r.lineNumber(oldNum)
+		r.lineNumber(newNum)
+		r.code(line)
+		r.write("</tr>\n")
+	}
+}
+
+// sideBySide writes the lines of frag in two columns. Runs of deleted lines
+// and the added lines that follow them share rows.
+func (r *htmlRenderer) sideBySide(frag *gitdiff.TextFragment) {
+	oldLine, newLine := frag.OldPosition, frag.NewPosition
+
+	lines := frag.Lines
+	for len(lines) > 0 {
+		if lines[0].Op == gitdiff.OpContext {
+			r.write("<tr ", r.class("context"), ">")
+			r.side(&lines[0], oldLine)
+			r.side(&lines[0], newLine)
+			r.write("</tr>\n")
+			oldLine, newLine, lines = oldLine+1, newLine+1, lines[1:]
+			continue
+		}
+
+		var deleted, added []gitdiff.Line
+		for len(lines) > 0 && lines[0].Op == gitdiff.OpDelete {
+			deleted, lines = append(deleted, lines[0]), lines[1:]
+		}
+		for len(lines) > 0 && lines[0].Op == gitdiff.OpAdd {
+			added, lines = append(added, lines[0]), lines[1:]
+		}
+
+		for i := 0; i < len(deleted) || i < len(added); i++ {
+			r.write("<tr>")
+			if i < len(deleted) {
+				r.side(&deleted[i], oldLine)
+				oldLine++
+			} else {
+				r.side(nil, 0)
+			}
+			if i < len(added) {
+				r.side(&added[i], newLine)
+				newLine++
+			} else {
+				r.side(nil, 0)
+			}
+			r.write("</tr>\n")
+		}
+	}
+}
+
+// side writes the cells for one side of a row. If line is nil, the side is
+// empty.
+func (r *htmlRenderer) side(line *gitdiff.Line, num int64) {
+	if line == nil {
+		r.write("<td ", r.class("line-number", "empty"), "></td><td ", r.class("code", "empty"), "></td>")
+		return
+	}
+
+	op := opClass(line.Op)
+	r.write("<td ", r.class("line-number", op), ">", strconv.FormatInt(num, 10), "</td>")
+	r.write("<td ", r.class("code", op), ">")
+	r.content(*line)
+	r.write("</td>")
+}
+
+func (r *htmlRenderer) lineNumber(num int64) {
+	r.write("<td ", r.class("line-number"), ">")
+	if num > 0 {
+		r.write(strconv.FormatInt(num, 10))
+	}
+	r.write("</td>")
+}
+
+func (r *htmlRenderer) code(line gitdiff.Line) {
+	r.write("<td ", r.class("code"), ">", html.EscapeString(line.Op.String()))
+	r.content(line)
+	r.write("</td>")
+}
+
+func (r *htmlRenderer) content(line gitdiff.Line) {
+	r.write(html.EscapeString(strings.TrimSuffix(line.Line, "\n")))
+	if line.NoEOL() {
+		r.write("<span ", r.class("no-newline"), `>\ No newline at end of file</span>`)
+	}
+}
+
+func opClass(op gitdiff.LineOp) string {
+	switch op {
+	case gitdiff.OpAdd:
+		return "add"
+	case gitdiff.OpDelete:
+		return "delete"
+	}
+	return "context"
+}
+
+// fileName returns the name shown in the header of a file.
+func fileName(f *gitdiff.File) string {
+	switch {
+	case f.IsNew:
+		return f.NewName
+	case f.IsDelete:
+		return f.OldName
+	case f.OldName != f.NewName:
+		return f.OldName + " → " + f.NewName
+	}
+	return f.NewName
+}
+
+// fragmentHeader returns the header of a fragment in the same format as Git,
+// omitting line counts equal to one.
+func fragmentHeader(frag *gitdiff.TextFragment) string {
+	formatRange := func(start, lines int64) string {
+		if lines == 1 {
+			return strconv.FormatInt(start, 10)
+		}
+		return strconv.FormatInt(start, 10) + "," + strconv.FormatInt(lines, 10)
+	}
+
+	header := "@@ -" + formatRange(frag.OldPosition, frag.OldLines) + " +" + formatRange(frag.NewPosition, frag.NewLines) + " @@"
+	if frag.Comment != "" {
+		header += " " + frag.Comment
+	}
+	return header
+}
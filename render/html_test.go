@@ -0,0 +1,98 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gitleaks/go-gitdiff/gitdiff"
+)
+
+func TestHTML(t *testing.T) {
+	tests := map[string]struct {
+		ParseOptions []gitdiff.ParseOption
+		Options      []HTMLOption
+		Golden       string
+	}{
+		"unified": {
+			Golden: "changes_unified.html",
+		},
+		"sideBySide": {
+			Options: []HTMLOption{WithLayout(SideBySide)},
+			Golden:  "changes_side_by_side.html",
+		},
+		"classPrefix": {
+			Options: []HTMLOption{WithClassPrefix("gd-")},
+			Golden:  "changes_prefix.html",
+		},
+		"lazyFragments": {
+			ParseOptions: []gitdiff.ParseOption{gitdiff.WithLazyFragments()},
+			Golden:       "changes_unified.html",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files := parseFiles(t, "changes.patch", test.ParseOptions...)
+
+			var b bytes.Buffer
+			if err := HTML(&b, files, test.Options...); err != nil {
+				t.Fatalf("unexpected error rendering HTML: %v", err)
+			}
+
+			expected, err := os.ReadFile(filepath.Join("testdata", test.Golden))
+			if err != nil {
+				t.Fatalf("failed to read golden file: %v", err)
+			}
+			if !bytes.Equal(expected, b.Bytes()) {
+				t.Errorf("incorrect HTML output\nexpected:\n%s\nactual:\n%s", expected, b.String())
+			}
+		})
+	}
+}
+
+func TestHTMLWriteError(t *testing.T) {
+	files := parseFiles(t, "changes.patch")
+
+	err := HTML(errorWriter{}, files)
+	if !errors.Is(err, errWrite) {
+		t.Fatalf("expected write error, but got: %v", err)
+	}
+}
+
+func parseFiles(t *testing.T, name string, opts ...gitdiff.ParseOption) []*gitdiff.File {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to open patch: %v", err)
+	}
+	defer f.Close()
+
+	files, _, err := gitdiff.ParseAll(f, opts...)
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	return files
+}
+
+var errWrite = errors.New("write failed")
+
+type errorWriter struct{}
+
+func (errorWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestFileName(t *testing.T) {
+	files := parseFiles(t, "changes.patch")
+
+	var names []string
+	for _, f := range files {
+		names = append(names, fileName(f))
+	}
+	if expected := "main.go|old.txt → new.txt|image.png"; strings.Join(names, "|") != expected {
+		t.Errorf("incorrect file names: expected %q, actual %q", expected, strings.Join(names, "|"))
+	}
+}
@@ -0,0 +1,21 @@
+package render
+
+import (
+	"io"
+)
+
+// writer writes strings to an underlying writer. It records the first error
+// returned by the writer and stops writing after an error.
+type writer struct {
+	w   io.Writer
+	err error
+}
+
+func (w *writer) write(s ...string) {
+	for _, str := range s {
+		if w.err != nil {
+			return
+		}
+		_, w.err = io.WriteString(w.w, str)
+	}
+}